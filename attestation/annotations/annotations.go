@@ -0,0 +1,142 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package annotations
+
+import (
+	"crypto"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+)
+
+const (
+	Name    = "annotations"
+	Type    = "https://witness.dev/attestations/annotations/v0.1"
+	RunType = attestation.PreRunType
+
+	// ExpiresAtKey is the annotation that records when a collection may be deleted from stores that honor
+	// retention hints, as an RFC 3339 timestamp.
+	ExpiresAtKey = "expires-at"
+)
+
+var (
+	_ attestation.Attestor  = &Attestor{}
+	_ attestation.Subjecter = &Attestor{}
+)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+type Option func(*Attestor)
+
+// WithAnnotations sets the key value pairs that will be recorded.
+func WithAnnotations(annotations map[string]string) Option {
+	return func(a *Attestor) {
+		for k, v := range annotations {
+			a.Annotations[k] = v
+		}
+	}
+}
+
+// Attestor records user supplied key value pairs, such as the project, environment, or cost center a
+// collection belongs to, so attestations can be found and grouped by them later.
+type Attestor struct {
+	Annotations map[string]string `json:"annotations"`
+}
+
+func New(opts ...Option) *Attestor {
+	a := &Attestor{
+		Annotations: make(map[string]string),
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+// Parse parses annotations of the form key=value. Keys must be unique and not empty.
+func Parse(pairs []string) (map[string]string, error) {
+	annotations := make(map[string]string)
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid annotation %q, must be of the form key=value", pair)
+		}
+
+		if _, ok := annotations[key]; ok {
+			return nil, fmt.Errorf("annotation %v set more than once", key)
+		}
+
+		annotations[key] = value
+	}
+
+	return annotations, nil
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	return nil
+}
+
+// Subjects exposes each annotation as a subject so services that index subjects, such as Archivist, can
+// search for collections by annotation. The digest is the SHA256 of key=value.
+func (a *Attestor) Subjects() map[string]cryptoutil.DigestSet {
+	subjects := make(map[string]cryptoutil.DigestSet)
+	for key, value := range a.Annotations {
+		subjects[fmt.Sprintf("annotation:%v", key)] = Digest(key, value)
+	}
+
+	return subjects
+}
+
+// Digest returns the subject digest recorded for an annotation.
+func Digest(key, value string) cryptoutil.DigestSet {
+	digest, _ := cryptoutil.CalculateDigestSetFromBytes([]byte(key+"="+value), []crypto.Hash{crypto.SHA256})
+	return digest
+}
+
+// ExpiresAt returns the retention hint recorded with the ExpiresAtKey annotation, and false if there is none.
+func (a *Attestor) ExpiresAt() (time.Time, bool, error) {
+	value, ok := a.Annotations[ExpiresAtKey]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+
+	expiresAt, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid %v annotation: %w", ExpiresAtKey, err)
+	}
+
+	return expiresAt, true, nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package approval
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+)
+
+const (
+	Name    = "approval"
+	Type    = "https://witness.dev/attestations/approval/v0.1"
+	RunType = attestation.PreRunType
+
+	// CollectionName is the name of the collections witness approve records, which policies search for
+	// when they require approvals.
+	CollectionName = "approval"
+)
+
+var (
+	_ attestation.Attestor  = &Attestor{}
+	_ attestation.Subjecter = &Attestor{}
+)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+type Option func(*Attestor)
+
+// WithReason sets the approver's reason for approving the subjects.
+func WithReason(reason string) Option {
+	return func(a *Attestor) {
+		a.Reason = reason
+	}
+}
+
+// WithSubjects sets the artifacts that are approved.
+func WithSubjects(subjects map[string]cryptoutil.DigestSet) Option {
+	return func(a *Attestor) {
+		for name, digest := range subjects {
+			a.Approved[name] = digest
+		}
+	}
+}
+
+// Attestor records a person's approval of a set of artifacts. The approver is whoever signed the
+// collection, so the attestation only records what was approved, why, and when.
+type Attestor struct {
+	Reason     string                          `json:"reason"`
+	ApprovedAt time.Time                       `json:"approvedat"`
+	Approved   map[string]cryptoutil.DigestSet `json:"approved"`
+}
+
+func New(opts ...Option) *Attestor {
+	a := &Attestor{
+		Approved: make(map[string]cryptoutil.DigestSet),
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	if len(a.Approved) == 0 {
+		return fmt.Errorf("no subjects to approve, approvals are recorded with witness approve")
+	}
+
+	if a.Reason == "" {
+		return fmt.Errorf("an approval requires a reason")
+	}
+
+	a.ApprovedAt = time.Now().UTC()
+	return nil
+}
+
+// Subjects returns the approved artifacts, so the approval can be found by their digests.
+func (a *Attestor) Subjects() map[string]cryptoutil.DigestSet {
+	return a.Approved
+}
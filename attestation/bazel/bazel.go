@@ -0,0 +1,290 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bazel
+
+import (
+	"crypto"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/witness/pkg/bazel"
+)
+
+const (
+	Name    = "bazel"
+	Type    = "https://witness.dev/attestations/bazel/v0.1"
+	RunType = attestation.PostRunType
+)
+
+var (
+	_ attestation.Attestor  = &Attestor{}
+	_ attestation.Subjecter = &Attestor{}
+)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+// Log is a bazel log the attestation was read from
+type Log struct {
+	File   string               `json:"file"`
+	Digest cryptoutil.DigestSet `json:"digest"`
+}
+
+// Invocation describes the bazel command, from its build event protocol stream
+type Invocation struct {
+	ID        string    `json:"id"`
+	Command   string    `json:"command,omitempty"`
+	Version   string    `json:"version,omitempty"`
+	StartTime time.Time `json:"startTime,omitempty"`
+	Success   bool      `json:"success"`
+	ExitCode  string    `json:"exitCode,omitempty"`
+}
+
+// File is an input or output of a target or action
+type File struct {
+	Path   string               `json:"path"`
+	Digest cryptoutil.DigestSet `json:"digest,omitempty"`
+}
+
+// Target is a target bazel built and the files in its output groups
+type Target struct {
+	Label   string `json:"label"`
+	Success bool   `json:"success"`
+	Outputs []File `json:"outputs"`
+}
+
+// Action is an action bazel executed or fetched from a cache
+type Action struct {
+	Mnemonic    string   `json:"mnemonic,omitempty"`
+	TargetLabel string   `json:"targetLabel,omitempty"`
+	CommandArgs []string `json:"commandArgs,omitempty"`
+	Runner      string   `json:"runner,omitempty"`
+	CacheHit    bool     `json:"cacheHit"`
+	ExitCode    int      `json:"exitCode"`
+	Inputs      []File   `json:"inputs"`
+	Outputs     []File   `json:"outputs"`
+}
+
+type Option func(*Attestor)
+
+// WithBuildEventFile reads the invocation and the targets it built from a build event protocol stream written with
+// --build_event_json_file, relative to the working directory
+func WithBuildEventFile(path string) Option {
+	return func(a *Attestor) {
+		a.bepPath = path
+	}
+}
+
+// WithExecutionLog reads the actions bazel ran from an execution log written with --execution_log_json_file,
+// relative to the working directory
+func WithExecutionLog(path string) Option {
+	return func(a *Attestor) {
+		a.execLogPath = path
+	}
+}
+
+// Attestor records what a bazel invocation built from the logs bazel writes: the targets it built and the digests
+// of their outputs, and every action it ran or fetched from a cache with its inputs and outputs.
+type Attestor struct {
+	BuildEvents  *Log        `json:"buildEvents,omitempty"`
+	ExecutionLog *Log        `json:"executionLog,omitempty"`
+	Invocation   *Invocation `json:"invocation,omitempty"`
+	Targets      []Target    `json:"targets"`
+	Actions      []Action    `json:"actions"`
+
+	bepPath     string
+	execLogPath string
+}
+
+func New(opts ...Option) *Attestor {
+	a := &Attestor{
+		Targets: make([]Target, 0),
+		Actions: make([]Action, 0),
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	if a.bepPath != "" {
+		f, log, err := openLog(ctx, a.bepPath)
+		if err != nil {
+			return err
+		}
+
+		defer f.Close()
+		build, err := bazel.ParseBuildEvents(f)
+		if err != nil {
+			return fmt.Errorf("failed to read build events %v: %w", a.bepPath, err)
+		}
+
+		a.BuildEvents = &log
+		a.recordBuild(build)
+	}
+
+	if a.execLogPath != "" {
+		f, log, err := openLog(ctx, a.execLogPath)
+		if err != nil {
+			return err
+		}
+
+		defer f.Close()
+		spawns, err := bazel.ParseExecutionLog(f)
+		if err != nil {
+			return fmt.Errorf("failed to read execution log %v: %w", a.execLogPath, err)
+		}
+
+		a.ExecutionLog = &log
+		for _, spawn := range spawns {
+			action := Action{
+				Mnemonic:    spawn.Mnemonic,
+				TargetLabel: spawn.TargetLabel,
+				CommandArgs: spawn.CommandArgs,
+				Runner:      spawn.Runner,
+				CacheHit:    spawn.Hit(),
+				ExitCode:    spawn.ExitCode,
+			}
+
+			if action.Inputs, err = actionFiles(spawn.Inputs); err != nil {
+				return err
+			}
+
+			if action.Outputs, err = actionFiles(spawn.ActualOutputs); err != nil {
+				return err
+			}
+
+			a.Actions = append(a.Actions, action)
+		}
+	}
+
+	return nil
+}
+
+// Subjects names each output of the targets bazel built
+func (a *Attestor) Subjects() map[string]cryptoutil.DigestSet {
+	subjects := make(map[string]cryptoutil.DigestSet)
+	for _, target := range a.Targets {
+		for _, output := range target.Outputs {
+			if len(output.Digest) > 0 {
+				subjects[fmt.Sprintf("output:%v", output.Path)] = output.Digest
+			}
+		}
+	}
+
+	return subjects
+}
+
+func (a *Attestor) recordBuild(build bazel.Build) {
+	if build.Started != nil {
+		a.Invocation = &Invocation{
+			ID:      build.Started.UUID,
+			Command: build.Started.Command,
+			Version: build.Started.BuildToolVersion,
+		}
+
+		if millis, err := strconv.ParseInt(build.Started.StartTimeMillis.String(), 10, 64); err == nil {
+			a.Invocation.StartTime = time.UnixMilli(millis).UTC()
+		}
+
+		if build.Finished != nil {
+			a.Invocation.Success = build.Finished.OverallSuccess
+			if build.Finished.ExitCode != nil {
+				a.Invocation.ExitCode = build.Finished.ExitCode.Name
+			}
+		}
+	}
+
+	for _, built := range build.Targets {
+		target := Target{Label: built.Label, Success: built.Success, Outputs: make([]File, 0, len(built.Files))}
+		for _, file := range built.Files {
+			output := File{Path: file.Path()}
+			// the build event protocol doesn't name the digest function, so digests are assumed to be bazel's default
+			if file.Digest != "" {
+				output.Digest = cryptoutil.DigestSet{crypto.SHA256: file.Digest}
+			}
+
+			target.Outputs = append(target.Outputs, output)
+		}
+
+		a.Targets = append(a.Targets, target)
+	}
+}
+
+func openLog(ctx *attestation.AttestationContext, path string) (*os.File, Log, error) {
+	file := filepath.Clean(path)
+	filePath := filepath.Join(ctx.WorkingDir(), file)
+	digest, err := cryptoutil.CalculateDigestSetFromFile(filePath, hashes(ctx))
+	if err != nil {
+		return nil, Log{}, fmt.Errorf("failed to calculate digest of %v: %w", path, err)
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, Log{}, err
+	}
+
+	return f, Log{File: file, Digest: digest}, nil
+}
+
+func actionFiles(files []bazel.File) ([]File, error) {
+	converted := make([]File, 0, len(files))
+	for _, file := range files {
+		f := File{Path: file.Path}
+		if file.Digest != nil {
+			digest, err := file.Digest.DigestSet()
+			if err != nil {
+				return nil, fmt.Errorf("%v: %w", file.Path, err)
+			}
+
+			f.Digest = digest
+		}
+
+		converted = append(converted, f)
+	}
+
+	return converted, nil
+}
+
+func hashes(ctx *attestation.AttestationContext) []crypto.Hash {
+	if hashes := ctx.Hashes(); len(hashes) > 0 {
+		return hashes
+	}
+
+	return []crypto.Hash{crypto.SHA256}
+}
@@ -0,0 +1,341 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buildcache
+
+import (
+	"bufio"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/witness/pkg/bazel"
+)
+
+const (
+	Name    = "build-cache"
+	Type    = "https://witness.dev/attestations/build-cache/v0.1"
+	RunType = attestation.PostRunType
+
+	// FormatBazel is an execution log written by bazel with --execution_log_json_file
+	FormatBazel = "bazel"
+	// FormatGradle is the plain console output of gradle run with -Dorg.gradle.caching.debug=true
+	FormatGradle = "gradle"
+	// FormatJSON is a stream of JSON cache hits, for tools such as sccache that don't log their cache hits
+	FormatJSON = "json"
+)
+
+var (
+	_ attestation.Attestor   = &Attestor{}
+	_ attestation.Materialer = &Attestor{}
+
+	gradleKeyPattern     = regexp.MustCompile(`Build cache key for task '([^']+)' is ([0-9a-f]+)`)
+	gradleOutcomePattern = regexp.MustCompile(`^> Task (\S+) FROM-CACHE\s*$`)
+)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+// LogSpec is a build tool log to read cache hits from
+type LogSpec struct {
+	Format string
+	Path   string
+}
+
+// ParseLogSpecs parses logs given as format=path, such as bazel=exec.json
+func ParseLogSpecs(specs []string) ([]LogSpec, error) {
+	logs := make([]LogSpec, 0, len(specs))
+	for _, spec := range specs {
+		format, path, ok := strings.Cut(spec, "=")
+		if !ok || path == "" {
+			return nil, fmt.Errorf("build cache log %q must be format=path", spec)
+		}
+
+		switch format {
+		case FormatBazel, FormatGradle, FormatJSON:
+		default:
+			return nil, fmt.Errorf("unknown build cache log format %v", format)
+		}
+
+		logs = append(logs, LogSpec{Format: format, Path: path})
+	}
+
+	return logs, nil
+}
+
+// Log is a build tool log that cache hits were read from
+type Log struct {
+	Format string               `json:"format"`
+	File   string               `json:"file"`
+	Digest cryptoutil.DigestSet `json:"digest"`
+}
+
+// Hit is an output a build tool fetched from a cache instead of building
+type Hit struct {
+	Tool string `json:"tool"`
+	// Key is the cache key the output was stored under, such as a bazel action digest or a gradle task's cache key
+	Key string `json:"key,omitempty"`
+	// Name is the output path, or the task whose outputs were fetched
+	Name string `json:"name"`
+	// Digest is the digest of the cached content, if the tool recorded it
+	Digest cryptoutil.DigestSet `json:"digest,omitempty"`
+}
+
+type Option func(*Attestor)
+
+// WithLogs reads cache hits from logs, relative to the working directory
+func WithLogs(logs []LogSpec) Option {
+	return func(a *Attestor) {
+		a.logs = logs
+	}
+}
+
+// WithGradleCacheDir sets the local gradle build cache that the content of cached tasks is hashed from. Defaults to
+// caches/build-cache-1 in GRADLE_USER_HOME, or ~/.gradle if it isn't set.
+func WithGradleCacheDir(dir string) Option {
+	return func(a *Attestor) {
+		a.gradleCacheDir = dir
+	}
+}
+
+// Attestor records the outputs that bazel, gradle, and other build tools fetched from remote or local caches, so
+// outputs that weren't built by the command can still be traced to the cache entries they came from.
+type Attestor struct {
+	Logs []Log `json:"logs"`
+	Hits []Hit `json:"hits"`
+
+	logs           []LogSpec
+	gradleCacheDir string
+}
+
+func New(opts ...Option) *Attestor {
+	a := &Attestor{
+		Logs: make([]Log, 0),
+		Hits: make([]Hit, 0),
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	for _, spec := range a.logs {
+		file := filepath.Clean(spec.Path)
+		path := filepath.Join(ctx.WorkingDir(), file)
+		digest, err := cryptoutil.CalculateDigestSetFromFile(path, hashes(ctx))
+		if err != nil {
+			return fmt.Errorf("failed to calculate digest of %v: %w", spec.Path, err)
+		}
+
+		hits, err := a.readLog(spec.Format, path, hashes(ctx))
+		if err != nil {
+			return fmt.Errorf("failed to read %v log %v: %w", spec.Format, spec.Path, err)
+		}
+
+		a.Logs = append(a.Logs, Log{Format: spec.Format, File: file, Digest: digest})
+		a.Hits = append(a.Hits, hits...)
+	}
+
+	return nil
+}
+
+// Materials names each cache hit with a known digest by its tool and output, so a policy can require that cached
+// outputs were produced by an earlier step
+func (a *Attestor) Materials() map[string]cryptoutil.DigestSet {
+	materials := make(map[string]cryptoutil.DigestSet)
+	for _, hit := range a.Hits {
+		if len(hit.Digest) > 0 {
+			materials[fmt.Sprintf("%v-cache:%v", hit.Tool, hit.Name)] = hit.Digest
+		}
+	}
+
+	return materials
+}
+
+func (a *Attestor) readLog(format, path string, hashes []crypto.Hash) ([]Hit, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	switch format {
+	case FormatBazel:
+		return readBazelLog(f)
+	case FormatGradle:
+		return a.readGradleLog(f, hashes)
+	default:
+		return readJSONLog(f)
+	}
+}
+
+// readBazelLog records each output of the spawns bazel fetched from a cache, keyed by the spawn's action digest
+func readBazelLog(r io.Reader) ([]Hit, error) {
+	spawns, err := bazel.ParseExecutionLog(r)
+	if err != nil {
+		return nil, err
+	}
+
+	hits := []Hit{}
+	for _, spawn := range spawns {
+		if !spawn.Hit() {
+			continue
+		}
+
+		key := ""
+		if spawn.Digest != nil {
+			key = spawn.Digest.Hash
+		}
+
+		for _, output := range spawn.ActualOutputs {
+			hit := Hit{Tool: FormatBazel, Key: key, Name: output.Path}
+			if output.Digest != nil {
+				if hit.Digest, err = output.Digest.DigestSet(); err != nil {
+					return nil, fmt.Errorf("output %v: %w", output.Path, err)
+				}
+			}
+
+			hits = append(hits, hit)
+		}
+	}
+
+	return hits, nil
+}
+
+// readGradleLog records the tasks gradle took from its build cache. The content of a task's cache entry is hashed if
+// it's in the local build cache.
+func (a *Attestor) readGradleLog(r io.Reader, hashes []crypto.Hash) ([]Hit, error) {
+	keys := map[string]string{}
+	hits := []Hit{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if match := gradleKeyPattern.FindStringSubmatch(line); match != nil {
+			keys[match[1]] = match[2]
+			continue
+		}
+
+		if match := gradleOutcomePattern.FindStringSubmatch(line); match != nil {
+			hits = append(hits, Hit{Tool: FormatGradle, Name: match[1]})
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	cacheDir := a.gradleCacheDir
+	if cacheDir == "" {
+		cacheDir = defaultGradleCacheDir()
+	}
+
+	for i := range hits {
+		hits[i].Key = keys[hits[i].Name]
+		if hits[i].Key == "" || cacheDir == "" {
+			continue
+		}
+
+		digest, err := cryptoutil.CalculateDigestSetFromFile(filepath.Join(cacheDir, hits[i].Key), hashes)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+
+			return nil, fmt.Errorf("failed to calculate digest of cache entry for %v: %w", hits[i].Name, err)
+		}
+
+		hits[i].Digest = digest
+	}
+
+	return hits, nil
+}
+
+func defaultGradleCacheDir() string {
+	home := os.Getenv("GRADLE_USER_HOME")
+	if home == "" {
+		userHome, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+
+		home = filepath.Join(userHome, ".gradle")
+	}
+
+	return filepath.Join(home, "caches", "build-cache-1")
+}
+
+// readJSONLog reads a stream of cache hits in the attestation's own format
+func readJSONLog(r io.Reader) ([]Hit, error) {
+	hits := []Hit{}
+	dec := json.NewDecoder(r)
+	for {
+		hit := Hit{}
+		if err := dec.Decode(&hit); err != nil {
+			if errors.Is(err, io.EOF) {
+				return hits, nil
+			}
+
+			return nil, fmt.Errorf("failed to parse cache hit %d: %w", len(hits)+1, err)
+		}
+
+		if hit.Tool == "" {
+			return nil, fmt.Errorf("cache hit %d has no tool", len(hits)+1)
+		}
+
+		if hit.Name == "" {
+			hit.Name = hit.Key
+		}
+
+		if hit.Name == "" {
+			return nil, fmt.Errorf("cache hit %d has no name or key", len(hits)+1)
+		}
+
+		hits = append(hits, hit)
+	}
+}
+
+func hashes(ctx *attestation.AttestationContext) []crypto.Hash {
+	if hashes := ctx.Hashes(); len(hashes) > 0 {
+		return hashes
+	}
+
+	return []crypto.Hash{crypto.SHA256}
+}
@@ -0,0 +1,207 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buildkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/intoto"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/pkg/purl"
+)
+
+const (
+	Name    = "buildkit"
+	Type    = "https://witness.dev/attestations/buildkit/v0.1"
+	RunType = attestation.PostRunType
+
+	statementTypePrefix = "https://in-toto.io/Statement/"
+)
+
+var (
+	_ attestation.Attestor  = &Attestor{}
+	_ attestation.Subjecter = &Attestor{}
+)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+type Option func(*Attestor)
+
+// WithDirectories sets the local exporter output directories, relative to the working directory, to read
+// BuildKit's attestations from.
+func WithDirectories(dirs []string) Option {
+	return func(a *Attestor) {
+		a.dirs = dirs
+	}
+}
+
+// Statement is an in-toto statement, such as SLSA provenance or an SPDX SBOM, that BuildKit attached to a build
+type Statement struct {
+	File          string           `json:"file"`
+	PredicateType string           `json:"predicateType"`
+	Subject       []intoto.Subject `json:"subject"`
+	Predicate     json.RawMessage  `json:"predicate"`
+}
+
+type Attestor struct {
+	Statements []Statement `json:"statements"`
+
+	dirs []string
+}
+
+func New(opts ...Option) *Attestor {
+	a := &Attestor{
+		Statements: make([]Statement, 0),
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	for _, dir := range a.dirs {
+		path := dir
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(ctx.WorkingDir(), dir)
+		}
+
+		statements, err := readStatements(path)
+		if err != nil {
+			return fmt.Errorf("failed to read buildkit attestations from %v: %w", dir, err)
+		}
+
+		if len(statements) == 0 {
+			return fmt.Errorf("no buildkit attestations found in %v, was the image built with --provenance or --sbom?", dir)
+		}
+
+		for i := range statements {
+			statements[i].File = filepath.ToSlash(filepath.Join(filepath.Clean(dir), statements[i].File))
+		}
+
+		a.Statements = append(a.Statements, statements...)
+	}
+
+	return nil
+}
+
+func (a *Attestor) Subjects() map[string]cryptoutil.DigestSet {
+	subjects := make(map[string]cryptoutil.DigestSet)
+	for _, statement := range a.Statements {
+		for _, subject := range statement.Subject {
+			digestSet, err := cryptoutil.NewDigestSet(subject.Digest)
+			if err != nil {
+				log.Debugf("(attestation/buildkit) skipping subject %v: %v", subject.Name, err)
+				continue
+			}
+
+			// buildkit names images by their purl, which is kept as is so policies can match it
+			name := fmt.Sprintf("buildkit:%v", subject.Name)
+			if _, err := purl.Parse(subject.Name); err == nil {
+				name = subject.Name
+			}
+
+			subjects[name] = digestSet
+		}
+	}
+
+	return subjects
+}
+
+// readStatements reads the in-toto statements the local exporter writes to the root of its output directory,
+// or to one directory per platform for multi-platform builds.
+func readStatements(dir string) ([]Statement, error) {
+	candidates, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	platformCandidates, err := filepath.Glob(filepath.Join(dir, "*", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	candidates = append(candidates, platformCandidates...)
+	sort.Strings(candidates)
+	statements := make([]Statement, 0)
+	for _, candidate := range candidates {
+		statement, ok, err := readStatement(candidate)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			continue
+		}
+
+		rel, err := filepath.Rel(dir, candidate)
+		if err != nil {
+			return nil, err
+		}
+
+		statement.File = rel
+		statements = append(statements, statement)
+	}
+
+	return statements, nil
+}
+
+// readStatement parses path as an in-toto statement, reporting false for json files that aren't statements
+func readStatement(path string) (Statement, bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Statement{}, false, err
+	}
+
+	raw := intoto.Statement{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return Statement{}, false, nil
+	}
+
+	if !strings.HasPrefix(raw.Type, statementTypePrefix) || raw.PredicateType == "" {
+		return Statement{}, false, nil
+	}
+
+	return Statement{
+		PredicateType: raw.PredicateType,
+		Subject:       raw.Subject,
+		Predicate:     raw.Predicate,
+	}, true, nil
+}
@@ -0,0 +1,195 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checksums
+
+import (
+	"crypto"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/pkg/checksums"
+)
+
+const (
+	Name    = "checksums"
+	Type    = "https://witness.dev/attestations/checksums/v0.1"
+	RunType = attestation.PostRunType
+)
+
+var (
+	_ attestation.Attestor  = &Attestor{}
+	_ attestation.Subjecter = &Attestor{}
+)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+// Entry is the digest of one file listed in a checksum file
+type Entry struct {
+	Name   string               `json:"name"`
+	Digest cryptoutil.DigestSet `json:"digest"`
+}
+
+// File is a checksum file, such as SHA256SUMS, and the files it lists
+type File struct {
+	File    string               `json:"file"`
+	Digest  cryptoutil.DigestSet `json:"digest"`
+	Entries []Entry              `json:"entries"`
+}
+
+type Option func(*Attestor)
+
+// WithFiles records the checksum files at paths, relative to the working directory, in addition to checksum files
+// among the command's products
+func WithFiles(paths []string) Option {
+	return func(a *Attestor) {
+		a.paths = paths
+	}
+}
+
+// Attestor records checksum files such as SHA256SUMS and makes every file they list a subject, so artifacts
+// downloaded from a release that publishes checksums can be verified against the attested checksum file.
+type Attestor struct {
+	Files []File `json:"files"`
+
+	paths []string
+}
+
+func New(opts ...Option) *Attestor {
+	a := &Attestor{
+		Files: make([]File, 0),
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	seen := make(map[string]bool)
+	for _, path := range a.paths {
+		file := filepath.Clean(path)
+		filePath := filepath.Join(ctx.WorkingDir(), file)
+		digest, err := cryptoutil.CalculateDigestSetFromFile(filePath, hashes(ctx))
+		if err != nil {
+			return fmt.Errorf("failed to calculate digest of %v: %w", path, err)
+		}
+
+		entries, err := readFile(filePath)
+		if err != nil {
+			return fmt.Errorf("failed to read checksum file %v: %w", path, err)
+		}
+
+		a.Files = append(a.Files, File{File: file, Digest: digest, Entries: entries})
+		seen[file] = true
+	}
+
+	products := ctx.Products()
+	files := make([]string, 0, len(products))
+	for file := range products {
+		if !seen[filepath.Clean(file)] && IsChecksumFileName(file) {
+			files = append(files, file)
+		}
+	}
+
+	sort.Strings(files)
+	for _, file := range files {
+		entries, err := readFile(filepath.Join(ctx.WorkingDir(), file))
+		if err != nil || len(entries) == 0 {
+			log.Debugf("(attestation/checksums) skipping %v: %v", file, err)
+			continue
+		}
+
+		a.Files = append(a.Files, File{File: file, Digest: products[file].Digest, Entries: entries})
+	}
+
+	return nil
+}
+
+// Subjects names each checksum file and every file it lists, so the collection can be found from a downloaded
+// artifact as well as from the checksum file
+func (a *Attestor) Subjects() map[string]cryptoutil.DigestSet {
+	subjects := make(map[string]cryptoutil.DigestSet)
+	for _, file := range a.Files {
+		subjects[fmt.Sprintf("checksums:%v", file.File)] = file.Digest
+		for _, entry := range file.Entries {
+			subjects[fmt.Sprintf("checksum:%v", entry.Name)] = entry.Digest
+		}
+	}
+
+	return subjects
+}
+
+// IsChecksumFileName reports whether name is conventionally used for a checksum file, such as SHA256SUMS,
+// checksums.txt, or app.tar.gz.sha256
+func IsChecksumFileName(name string) bool {
+	base := filepath.Base(name)
+	lower := strings.ToLower(base)
+	return strings.HasSuffix(base, "SUMS") ||
+		strings.HasSuffix(lower, "checksums.txt") ||
+		strings.HasSuffix(lower, ".sha256") ||
+		strings.HasSuffix(lower, ".sha512")
+}
+
+func readFile(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	parsed, err := checksums.Parse(f)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(parsed))
+	for _, entry := range parsed {
+		entries = append(entries, Entry{Name: entry.Name, Digest: cryptoutil.DigestSet{entry.Hash: entry.Digest}})
+	}
+
+	return entries, nil
+}
+
+func hashes(ctx *attestation.AttestationContext) []crypto.Hash {
+	if hashes := ctx.Hashes(); len(hashes) > 0 {
+		return hashes
+	}
+
+	return []crypto.Hash{crypto.SHA256}
+}
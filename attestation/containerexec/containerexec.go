@@ -0,0 +1,103 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containerexec
+
+import (
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/witness/pkg/containerexec"
+	"github.com/testifysec/witness/pkg/imagedigest"
+)
+
+const (
+	Name    = "container-exec"
+	Type    = "https://witness.dev/attestations/container-exec/v0.1"
+	RunType = attestation.PreRunType
+)
+
+var (
+	_ attestation.Attestor   = &Attestor{}
+	_ attestation.Materialer = &Attestor{}
+)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+type Option func(*Attestor)
+
+// WithContainer sets the container the command runs in, and the command's arguments
+func WithContainer(container containerexec.Container, args []string) Option {
+	return func(a *Attestor) {
+		a.Runtime = container.Runtime
+		a.Image = container.Image
+		a.Mounts = container.Mounts
+		a.WorkingDir = container.WorkingDir
+		a.Args = args
+	}
+}
+
+// Attestor records the container image a command ran in with witness run --in-container, so the execution
+// environment is recorded as a material like the command's inputs
+type Attestor struct {
+	Runtime    string                `json:"runtime"`
+	Image      containerexec.Image   `json:"image"`
+	Mounts     []containerexec.Mount `json:"mounts"`
+	WorkingDir string                `json:"workingDir,omitempty"`
+	// Entrypoint is what ran in the container: the image's entrypoint, if it has one, followed by the command
+	Entrypoint []string `json:"entrypoint"`
+	Args       []string `json:"args"`
+}
+
+func New(opts ...Option) *Attestor {
+	a := &Attestor{}
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	if a.Image.ID == "" {
+		return attestation.ErrInvalidOption{
+			Option: "Container",
+			Reason: "the container-exec attestor requires the command to be run with --in-container",
+		}
+	}
+
+	a.Entrypoint = append(append([]string{}, a.Image.Entrypoint...), a.Args...)
+	return nil
+}
+
+// Materials returns the image by its manifest digests, and by its configuration digest, so policies can require
+// the image a step ran in like any other input
+func (a *Attestor) Materials() map[string]cryptoutil.DigestSet {
+	return imagedigest.Materials("container-image", a.Image.Reference, a.Image.ID, a.Image.RepoDigests)
+}
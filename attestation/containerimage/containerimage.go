@@ -0,0 +1,348 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containerimage
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/witness/pkg/imagedigest"
+)
+
+const (
+	Name    = "container-image"
+	Type    = "https://witness.dev/attestations/container-image/v0.1"
+	RunType = attestation.PreRunType
+
+	defaultProcDir          = "/proc"
+	defaultContainerEnvFile = "/run/.containerenv"
+
+	// gitLabImageEnv is the image GitLab runners start a job's container from, as written in .gitlab-ci.yml
+	gitLabImageEnv = "CI_JOB_IMAGE"
+
+	engineAPITimeout = 10 * time.Second
+)
+
+var (
+	_ attestation.Attestor   = &Attestor{}
+	_ attestation.Materialer = &Attestor{}
+
+	// defaultSockets are where docker and podman serve the Docker Engine API. Jobs see them when the socket is
+	// mounted into their container, as docker-in-docker and docker executor setups commonly do.
+	defaultSockets = []string{
+		"/var/run/docker.sock",
+		"/run/podman/podman.sock",
+	}
+
+	// containerIDPatterns match the ID container runtimes name a container's cgroup after, such as /docker/<id> and
+	// cri-containerd-<id>.scope, and the files they mount into it, such as /var/lib/docker/containers/<id>/hostname.
+	// Other mounts, like overlay layer directories, are named by 64 character IDs too, so mounts only match under
+	// a containers directory.
+	containerIDPatterns = map[string]*regexp.Regexp{
+		"cgroup":    regexp.MustCompile(`[/-]([0-9a-f]{64})(?:\.scope)?$`),
+		"mountinfo": regexp.MustCompile(`containers/([0-9a-f]{64})/`),
+	}
+)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+type Option func(*Attestor)
+
+// WithProcDir sets where procfs is mounted. Defaults to /proc.
+func WithProcDir(dir string) Option {
+	return func(a *Attestor) {
+		a.procDir = dir
+	}
+}
+
+// WithSockets sets the Docker Engine API sockets to look the container up with. Defaults to the socket in
+// DOCKER_HOST or CONTAINER_HOST, then docker's and podman's default sockets.
+func WithSockets(sockets []string) Option {
+	return func(a *Attestor) {
+		a.sockets = sockets
+	}
+}
+
+// WithContainerEnvFile sets where podman describes the container it runs. Defaults to /run/.containerenv.
+func WithContainerEnvFile(path string) Option {
+	return func(a *Attestor) {
+		a.containerEnvFile = path
+	}
+}
+
+// Image is the image the container witness runs in was started from
+type Image struct {
+	// Reference is the image as the container was started with it, such as golang:1.18
+	Reference string `json:"reference,omitempty"`
+	// ID is the digest of the image's configuration
+	ID string `json:"id,omitempty"`
+	// RepoDigests are the registry digests the engine reports for the job image, as name@sha256:digest
+	RepoDigests []string `json:"repoDigests,omitempty"`
+	Platform    string   `json:"platform,omitempty"`
+	// Layers are the digests of the image's uncompressed layers, from the base layer up
+	Layers []string `json:"layers,omitempty"`
+}
+
+// Attestor records the container image witness itself runs in, such as the image of a CI job, so the build
+// environment is recorded as a material and policies can pin it
+type Attestor struct {
+	ContainerID string `json:"containerId,omitempty"`
+	// Source is where the image was found: the Docker Engine API socket, podman's .containerenv file, or the
+	// environment variable the CI system set
+	Source string `json:"source"`
+	Image  Image  `json:"image"`
+
+	procDir          string
+	sockets          []string
+	containerEnvFile string
+}
+
+func New(opts ...Option) *Attestor {
+	a := &Attestor{
+		procDir:          defaultProcDir,
+		sockets:          defaultEngineSockets(),
+		containerEnvFile: defaultContainerEnvFile,
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	a.ContainerID = a.containerID()
+	containerEnv := readContainerEnv(a.containerEnvFile)
+	if a.ContainerID == "" {
+		a.ContainerID = containerEnv["id"]
+	}
+
+	if a.ContainerID != "" {
+		for _, socket := range a.sockets {
+			image, err := inspectContainer(ctx.Context(), socket, a.ContainerID)
+			if err != nil {
+				continue
+			}
+
+			a.Source = "unix://" + socket
+			a.Image = image
+			return nil
+		}
+	}
+
+	if containerEnv["imageid"] != "" {
+		a.Source = a.containerEnvFile
+		a.Image = Image{Reference: containerEnv["image"], ID: digestOf(containerEnv["imageid"])}
+		return nil
+	}
+
+	if reference := os.Getenv(gitLabImageEnv); reference != "" {
+		a.Source = gitLabImageEnv
+		a.Image = Image{Reference: reference}
+		if strings.Contains(reference, "@") {
+			a.Image.RepoDigests = []string{reference}
+		}
+
+		return nil
+	}
+
+	if a.ContainerID == "" {
+		return fmt.Errorf("witness doesn't appear to be running in a container")
+	}
+
+	return fmt.Errorf("failed to find the image of container %v: mount the docker or podman socket into the container, or set DOCKER_HOST to it", a.ContainerID)
+}
+
+// Materials returns the image by its manifest digests, and by its configuration digest, so policies can require
+// the image the job ran in like any other input
+func (a *Attestor) Materials() map[string]cryptoutil.DigestSet {
+	return imagedigest.Materials("job-image", a.Image.Reference, a.Image.ID, a.Image.RepoDigests)
+}
+
+// containerID returns the ID of the container witness runs in from its cgroups, or from the files the runtime
+// mounted into it on cgroup v2 hosts where the cgroup path is hidden
+func (a *Attestor) containerID() string {
+	for _, file := range []string{"cgroup", "mountinfo"} {
+		pattern := containerIDPatterns[file]
+		f, err := os.Open(filepath.Join(a.procDir, "self", file))
+		if err != nil {
+			continue
+		}
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			for _, field := range strings.Fields(scanner.Text()) {
+				if match := pattern.FindStringSubmatch(field); match != nil {
+					f.Close()
+					return match[1]
+				}
+			}
+		}
+
+		f.Close()
+	}
+
+	return ""
+}
+
+// defaultEngineSockets returns the socket in DOCKER_HOST or CONTAINER_HOST, if either is a unix socket, followed by
+// docker's and podman's default sockets
+func defaultEngineSockets() []string {
+	sockets := []string{}
+	for _, env := range []string{"DOCKER_HOST", "CONTAINER_HOST"} {
+		if u, err := url.Parse(os.Getenv(env)); err == nil && u.Scheme == "unix" && u.Path != "" {
+			sockets = append(sockets, u.Path)
+		}
+	}
+
+	return append(sockets, defaultSockets...)
+}
+
+// engineContainer is the part of the Docker Engine API's container inspect response that's recorded
+type engineContainer struct {
+	Image  string `json:"Image"`
+	Config struct {
+		Image string `json:"Image"`
+	} `json:"Config"`
+}
+
+// engineImage is the part of the Docker Engine API's image inspect response that's recorded
+type engineImage struct {
+	ID           string   `json:"Id"`
+	RepoDigests  []string `json:"RepoDigests"`
+	Os           string   `json:"Os"`
+	Architecture string   `json:"Architecture"`
+	Variant      string   `json:"Variant"`
+	RootFS       struct {
+		Layers []string `json:"Layers"`
+	} `json:"RootFS"`
+}
+
+// inspectContainer looks the container up with the Docker Engine API served on socket, and returns the image it
+// was started from
+func inspectContainer(ctx context.Context, socket, id string) (Image, error) {
+	client := &http.Client{
+		Timeout: engineAPITimeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+
+	container := engineContainer{}
+	if err := getEngineJSON(ctx, client, "/containers/"+url.PathEscape(id)+"/json", &container); err != nil {
+		return Image{}, err
+	}
+
+	image := engineImage{}
+	if err := getEngineJSON(ctx, client, "/images/"+url.PathEscape(container.Image)+"/json", &image); err != nil {
+		return Image{}, err
+	}
+
+	result := Image{
+		Reference:   container.Config.Image,
+		ID:          image.ID,
+		RepoDigests: image.RepoDigests,
+		Layers:      image.RootFS.Layers,
+	}
+
+	if image.Os != "" && image.Architecture != "" {
+		result.Platform = image.Os + "/" + image.Architecture
+		if image.Variant != "" {
+			result.Platform += "/" + image.Variant
+		}
+	}
+
+	return result, nil
+}
+
+func getEngineJSON(ctx context.Context, client *http.Client, path string, v interface{}) error {
+	// the host is ignored, since every request is sent over the socket
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://engine"+path, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("engine api returned %v for %v", resp.Status, path)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// readContainerEnv reads the key="value" pairs podman writes to .containerenv. Podman only fills them in for
+// privileged containers; otherwise the file is empty.
+func readContainerEnv(path string) map[string]string {
+	env := make(map[string]string)
+	f, err := os.Open(path)
+	if err != nil {
+		return env
+	}
+
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if key, value, ok := strings.Cut(scanner.Text(), "="); ok {
+			env[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+
+	return env
+}
+
+// digestOf returns id as an OCI digest. Podman records image IDs without the algorithm.
+func digestOf(id string) string {
+	if id == "" || strings.Contains(id, ":") {
+		return id
+	}
+
+	return "sha256:" + id
+}
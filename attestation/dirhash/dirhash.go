@@ -0,0 +1,107 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dirhash
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/witness/pkg/dirhash"
+)
+
+const (
+	Name    = "dirhash"
+	Type    = "https://witness.dev/attestations/dirhash/v0.1"
+	RunType = attestation.PostRunType
+)
+
+var (
+	_ attestation.Attestor  = &Attestor{}
+	_ attestation.Subjecter = &Attestor{}
+)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+type Option func(*Attestor)
+
+// WithDirectories sets the directories, relative to the working directory, that will be hashed.
+func WithDirectories(dirs []string) Option {
+	return func(a *Attestor) {
+		a.dirs = dirs
+	}
+}
+
+type Attestor struct {
+	Directories map[string]cryptoutil.DigestSet `json:"directories"`
+
+	dirs []string
+}
+
+func New(opts ...Option) *Attestor {
+	a := &Attestor{
+		Directories: make(map[string]cryptoutil.DigestSet),
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	for _, dir := range a.dirs {
+		path := dir
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(ctx.WorkingDir(), dir)
+		}
+
+		digestSet, err := dirhash.Hash(path, ctx.Hashes())
+		if err != nil {
+			return fmt.Errorf("failed to hash directory %v: %w", dir, err)
+		}
+
+		a.Directories[filepath.ToSlash(filepath.Clean(dir))] = digestSet
+	}
+
+	return nil
+}
+
+func (a *Attestor) Subjects() map[string]cryptoutil.DigestSet {
+	subjects := make(map[string]cryptoutil.DigestSet)
+	for dir, digestSet := range a.Directories {
+		subjects[fmt.Sprintf("dir:%v", dir)] = digestSet
+	}
+
+	return subjects
+}
@@ -0,0 +1,154 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package files provides the material and product attestors witness run records around a command. They record
+// the same predicates as go-witness's material and product attestors, but walk the working directory with
+// filehash so files are hashed concurrently and ignore files are respected. They aren't registered, so
+// recorded attestations are read back with go-witness's attestors.
+package files
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/attestation/material"
+	"github.com/testifysec/go-witness/attestation/product"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/witness/pkg/filehash"
+)
+
+var (
+	_ attestation.Attestor   = &Material{}
+	_ attestation.Materialer = &Material{}
+	_ attestation.Attestor   = &Product{}
+	_ attestation.Subjecter  = &Product{}
+	_ attestation.Producer   = &Product{}
+)
+
+// Material records the digests of every file in the working directory before the command runs
+type Material struct {
+	materials map[string]cryptoutil.DigestSet
+	opts      []filehash.Option
+}
+
+func NewMaterial(opts ...filehash.Option) *Material {
+	return &Material{opts: opts}
+}
+
+func (a *Material) Name() string {
+	return material.Name
+}
+
+func (a *Material) Type() string {
+	return material.Type
+}
+
+func (a *Material) RunType() attestation.RunType {
+	return material.RunType
+}
+
+func (a *Material) Attest(ctx *attestation.AttestationContext) error {
+	materials, err := filehash.Record(ctx.WorkingDir(), nil, ctx.Hashes(), append([]filehash.Option{filehash.WithContext(ctx.Context())}, a.opts...)...)
+	if err != nil {
+		return err
+	}
+
+	a.materials = materials
+	return nil
+}
+
+func (a *Material) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.materials)
+}
+
+func (a *Material) Materials() map[string]cryptoutil.DigestSet {
+	return a.materials
+}
+
+// Product records the files in the working directory that were created or changed by the command
+type Product struct {
+	products map[string]attestation.Product
+	opts     []filehash.Option
+}
+
+func NewProduct(opts ...filehash.Option) *Product {
+	return &Product{opts: opts}
+}
+
+func (a *Product) Name() string {
+	return product.Name
+}
+
+func (a *Product) Type() string {
+	return product.Type
+}
+
+func (a *Product) RunType() attestation.RunType {
+	return product.RunType
+}
+
+func (a *Product) Attest(ctx *attestation.AttestationContext) error {
+	digests, err := filehash.Record(ctx.WorkingDir(), ctx.Materials(), ctx.Hashes(), append([]filehash.Option{filehash.WithContext(ctx.Context())}, a.opts...)...)
+	if err != nil {
+		return err
+	}
+
+	a.products = make(map[string]attestation.Product, len(digests))
+	for path, digestSet := range digests {
+		a.products[path] = attestation.Product{
+			MimeType: mimeType(filepath.Join(ctx.WorkingDir(), path)),
+			Digest:   digestSet,
+		}
+	}
+
+	return nil
+}
+
+func (a *Product) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.products)
+}
+
+func (a *Product) Products() map[string]attestation.Product {
+	return a.products
+}
+
+func (a *Product) Subjects() map[string]cryptoutil.DigestSet {
+	subjects := make(map[string]cryptoutil.DigestSet)
+	for path, p := range a.products {
+		subjects[fmt.Sprintf("file:%v", path)] = p.Digest
+	}
+
+	return subjects
+}
+
+// mimeType sniffs the content type of the file at path from its first 512 bytes
+func mimeType(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return "unknown"
+	}
+
+	defer f.Close()
+	buffer := make([]byte, 512)
+	n, err := f.Read(buffer)
+	if err != nil {
+		return "unknown"
+	}
+
+	return http.DetectContentType(buffer[:n])
+}
@@ -0,0 +1,258 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package host
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+)
+
+const (
+	Name    = "host"
+	Type    = "https://witness.dev/attestations/host/v0.1"
+	RunType = attestation.PreRunType
+)
+
+var (
+	_ attestation.Attestor = &Attestor{}
+
+	// caBundlePaths are where distributions install the system CA bundle, in the order Go's crypto/x509 looks for it
+	caBundlePaths = []string{
+		"/etc/ssl/certs/ca-certificates.crt",
+		"/etc/pki/tls/certs/ca-bundle.crt",
+		"/etc/ssl/ca-bundle.pem",
+		"/etc/pki/tls/cacert.pem",
+		"/etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem",
+		"/etc/ssl/cert.pem",
+	}
+
+	// containerMarkers are files container runtimes create in the containers they run
+	containerMarkers = []struct {
+		path    string
+		runtime string
+	}{
+		{"/.dockerenv", "docker"},
+		{"/run/.containerenv", "podman"},
+	}
+
+	// cgroupMarkers are substrings of the cgroup paths container runtimes and orchestrators place processes in
+	cgroupMarkers = []struct {
+		marker  string
+		runtime string
+	}{
+		{"kubepods", "kubernetes"},
+		{"docker", "docker"},
+		{"libpod", "podman"},
+		{"containerd", "containerd"},
+		{"lxc", "lxc"},
+	}
+)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+type Kernel struct {
+	Release string `json:"release,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+type OSRelease struct {
+	ID         string `json:"id,omitempty"`
+	VersionID  string `json:"versionid,omitempty"`
+	PrettyName string `json:"prettyname,omitempty"`
+}
+
+type CPU struct {
+	Vendor    string   `json:"vendor,omitempty"`
+	Model     string   `json:"model,omitempty"`
+	Microcode string   `json:"microcode,omitempty"`
+	Flags     []string `json:"flags,omitempty"`
+}
+
+type CABundle struct {
+	Path   string               `json:"path"`
+	Digest cryptoutil.DigestSet `json:"digest"`
+}
+
+// Attestor records the host the command ran on in more detail than the environment attestor: the kernel, the
+// OS release, the container runtime it ran in, the CPU and its microcode, and the digest of the system CA bundle.
+// Fields the host doesn't expose, such as the kernel release outside of Linux, are left empty.
+type Attestor struct {
+	OS        string    `json:"os"`
+	Arch      string    `json:"arch"`
+	Kernel    Kernel    `json:"kernel"`
+	OSRelease OSRelease `json:"osrelease"`
+	Container string    `json:"container,omitempty"`
+	CPU       CPU       `json:"cpu"`
+	CABundle  *CABundle `json:"cabundle,omitempty"`
+}
+
+func New() *Attestor {
+	return &Attestor{}
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	a.OS = runtime.GOOS
+	a.Arch = runtime.GOARCH
+	a.Kernel = Kernel{
+		Release: readTrimmed("/proc/sys/kernel/osrelease"),
+		Version: readTrimmed("/proc/sys/kernel/version"),
+	}
+
+	a.OSRelease = readOSRelease()
+	a.Container = detectContainer()
+	a.CPU = readCPU()
+	for _, path := range caBundlePaths {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+
+		digest, err := cryptoutil.CalculateDigestSetFromFile(path, ctx.Hashes())
+		if err != nil {
+			return fmt.Errorf("failed to calculate digest of CA bundle %v: %w", path, err)
+		}
+
+		a.CABundle = &CABundle{Path: path, Digest: digest}
+		break
+	}
+
+	return nil
+}
+
+func readTrimmed(path string) string {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(contents))
+}
+
+// readFields calls fn with the key and value of each line in path separated by sep. Missing files are ignored.
+func readFields(path, sep string, fn func(key, value string) bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	// cpuinfo flags lines can be longer than the default token size
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), sep)
+		if !ok {
+			continue
+		}
+
+		if !fn(strings.TrimSpace(key), strings.TrimSpace(value)) {
+			return
+		}
+	}
+}
+
+// readOSRelease reads the os-release file described in os-release(5)
+func readOSRelease() OSRelease {
+	release := OSRelease{}
+	path := "/etc/os-release"
+	if _, err := os.Stat(path); err != nil {
+		path = "/usr/lib/os-release"
+	}
+
+	readFields(path, "=", func(key, value string) bool {
+		value = strings.Trim(value, `"'`)
+		switch key {
+		case "ID":
+			release.ID = value
+		case "VERSION_ID":
+			release.VersionID = value
+		case "PRETTY_NAME":
+			release.PrettyName = value
+		}
+
+		return true
+	})
+
+	return release
+}
+
+// readCPU reads the first processor in /proc/cpuinfo. Hosts with mixed processors are rare enough that the first
+// is representative.
+func readCPU() CPU {
+	cpu := CPU{}
+	seenProcessor := false
+	readFields("/proc/cpuinfo", ":", func(key, value string) bool {
+		switch key {
+		case "processor":
+			if seenProcessor {
+				return false
+			}
+
+			seenProcessor = true
+		case "vendor_id":
+			cpu.Vendor = value
+		case "model name":
+			cpu.Model = value
+		case "microcode":
+			cpu.Microcode = value
+		case "flags", "Features":
+			cpu.Flags = strings.Fields(value)
+		}
+
+		return true
+	})
+
+	return cpu
+}
+
+// detectContainer returns the container runtime witness is running in, or an empty string if it doesn't appear
+// to be running in a container
+func detectContainer() string {
+	for _, marker := range containerMarkers {
+		if _, err := os.Stat(marker.path); err == nil {
+			return marker.runtime
+		}
+	}
+
+	cgroups := readTrimmed("/proc/self/cgroup")
+	for _, marker := range cgroupMarkers {
+		if strings.Contains(cgroups, marker.marker) {
+			return marker.runtime
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,359 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8smanifest
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/log"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	Name    = "k8s-manifest"
+	Type    = "https://witness.dev/attestations/k8s-manifest/v0.1"
+	RunType = attestation.PostRunType
+)
+
+var (
+	_ attestation.Attestor  = &Attestor{}
+	_ attestation.Subjecter = &Attestor{}
+
+	// containerListKeys are the fields of a pod spec that hold containers with images
+	containerListKeys = []string{"containers", "initContainers", "ephemeralContainers"}
+)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+// Object is a Kubernetes object from a rendered manifest built by the command
+type Object struct {
+	File       string               `json:"file"`
+	APIVersion string               `json:"apiversion"`
+	Kind       string               `json:"kind"`
+	Namespace  string               `json:"namespace,omitempty"`
+	Name       string               `json:"name"`
+	Digest     cryptoutil.DigestSet `json:"digest"`
+	Images     []string             `json:"images,omitempty"`
+}
+
+// Chart is a Helm chart package built by the command
+type Chart struct {
+	File       string               `json:"file"`
+	Name       string               `json:"name"`
+	Version    string               `json:"version"`
+	AppVersion string               `json:"appversion,omitempty"`
+	Digest     cryptoutil.DigestSet `json:"digest"`
+}
+
+// Attestor records the Kubernetes objects in manifests the command renders, such as the output of helm template
+// or kustomize build, and the Helm charts it packages, so GitOps repositories can verify deployed manifests trace
+// back to attested builds.
+type Attestor struct {
+	Objects []Object `json:"objects"`
+	Charts  []Chart  `json:"charts"`
+}
+
+func New() *Attestor {
+	return &Attestor{
+		Objects: make([]Object, 0),
+		Charts:  make([]Chart, 0),
+	}
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	products := ctx.Products()
+	files := make([]string, 0, len(products))
+	for file := range products {
+		files = append(files, file)
+	}
+
+	sort.Strings(files)
+	for _, file := range files {
+		filePath := filepath.Join(ctx.WorkingDir(), file)
+		switch strings.ToLower(path.Ext(file)) {
+		case ".yaml", ".yml", ".json":
+			objects, err := readManifest(filePath)
+			if err != nil {
+				log.Debugf("(attestation/k8s-manifest) skipping %v: %v", file, err)
+				continue
+			}
+
+			for _, obj := range objects {
+				obj.File = file
+				a.Objects = append(a.Objects, obj)
+			}
+		case ".tgz":
+			chart, err := readChart(filePath)
+			if err != nil {
+				log.Debugf("(attestation/k8s-manifest) skipping %v: %v", file, err)
+				continue
+			}
+
+			chart.File = file
+			chart.Digest = products[file].Digest
+			a.Charts = append(a.Charts, chart)
+		}
+	}
+
+	return nil
+}
+
+// Subjects names each object by its kind, namespace, and name, each chart by its name and version, and each image
+// the objects reference by digest, so verifiers can find the collections that built what a manifest deploys.
+func (a *Attestor) Subjects() map[string]cryptoutil.DigestSet {
+	subjects := make(map[string]cryptoutil.DigestSet)
+	for _, obj := range a.Objects {
+		name := obj.Name
+		if obj.Namespace != "" {
+			name = obj.Namespace + "/" + obj.Name
+		}
+
+		subjects[fmt.Sprintf("k8s:%v:%v", obj.Kind, name)] = obj.Digest
+		for _, image := range obj.Images {
+			if digest, ok := imageDigest(image); ok {
+				subjects[fmt.Sprintf("image:%v", image)] = digest
+			}
+		}
+	}
+
+	for _, chart := range a.Charts {
+		subjects[fmt.Sprintf("helmchart:%v@%v", chart.Name, chart.Version)] = chart.Digest
+	}
+
+	return subjects
+}
+
+// ObjectDigest returns the digest recorded for a Kubernetes object: the SHA256 of its JSON encoding with sorted
+// keys and no insignificant whitespace, so the same object has the same digest whether it was written as YAML or JSON.
+func ObjectDigest(obj map[string]interface{}) (cryptoutil.DigestSet, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	return cryptoutil.CalculateDigestSetFromBytes(data, []crypto.Hash{crypto.SHA256})
+}
+
+// readManifest reads the Kubernetes objects from a file of YAML documents or JSON. Documents that aren't
+// Kubernetes objects are skipped, and List kinds are expanded into their items.
+func readManifest(filePath string) ([]Object, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := []Object{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		doc := map[string]interface{}{}
+		if err := decoder.Decode(&doc); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		docObjects, err := readObjects(doc)
+		if err != nil {
+			return nil, err
+		}
+
+		objects = append(objects, docObjects...)
+	}
+
+	return objects, nil
+}
+
+func readObjects(doc map[string]interface{}) ([]Object, error) {
+	apiVersion, _ := doc["apiVersion"].(string)
+	kind, _ := doc["kind"].(string)
+	if apiVersion == "" || kind == "" {
+		return nil, nil
+	}
+
+	if items, ok := doc["items"].([]interface{}); ok && strings.HasSuffix(kind, "List") {
+		objects := []Object{}
+		for _, item := range items {
+			itemDoc, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			itemObjects, err := readObjects(itemDoc)
+			if err != nil {
+				return nil, err
+			}
+
+			objects = append(objects, itemObjects...)
+		}
+
+		return objects, nil
+	}
+
+	metadata, _ := doc["metadata"].(map[string]interface{})
+	name, _ := metadata["name"].(string)
+	if name == "" {
+		return nil, nil
+	}
+
+	namespace, _ := metadata["namespace"].(string)
+	digest, err := ObjectDigest(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate digest of %v %v: %w", kind, name, err)
+	}
+
+	return []Object{{
+		APIVersion: apiVersion,
+		Kind:       kind,
+		Namespace:  namespace,
+		Name:       name,
+		Digest:     digest,
+		Images:     findImages(doc),
+	}}, nil
+}
+
+// findImages returns the sorted, unique images of every container in the object, wherever its pod spec is nested
+func findImages(value interface{}) []string {
+	seen := make(map[string]struct{})
+	var walk func(value interface{})
+	walk = func(value interface{}) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			for _, key := range containerListKeys {
+				containers, _ := v[key].([]interface{})
+				for _, container := range containers {
+					c, _ := container.(map[string]interface{})
+					if image, ok := c["image"].(string); ok && image != "" {
+						seen[image] = struct{}{}
+					}
+				}
+			}
+
+			for _, child := range v {
+				walk(child)
+			}
+		case []interface{}:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+
+	walk(value)
+	images := make([]string, 0, len(seen))
+	for image := range seen {
+		images = append(images, image)
+	}
+
+	sort.Strings(images)
+	return images
+}
+
+// imageDigest returns the digest an image reference is pinned to, such as nginx@sha256:abc
+func imageDigest(image string) (cryptoutil.DigestSet, bool) {
+	_, digest, ok := strings.Cut(image, "@")
+	if !ok {
+		return nil, false
+	}
+
+	algorithm, value, ok := strings.Cut(digest, ":")
+	if !ok {
+		return nil, false
+	}
+
+	hash, err := cryptoutil.HashFromString(algorithm)
+	if err != nil {
+		return nil, false
+	}
+
+	return cryptoutil.DigestSet{hash: value}, true
+}
+
+// readChart reads the name and version from the Chart.yaml at the root of a packaged Helm chart
+func readChart(filePath string) (Chart, error) {
+	chart := Chart{}
+	f, err := os.Open(filePath)
+	if err != nil {
+		return chart, err
+	}
+
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return chart, err
+	}
+
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return chart, fmt.Errorf("no Chart.yaml found")
+		} else if err != nil {
+			return chart, err
+		}
+
+		dir, file := path.Split(path.Clean(hdr.Name))
+		if file != "Chart.yaml" || strings.Count(dir, "/") != 1 {
+			continue
+		}
+
+		metadata := struct {
+			Name       string `yaml:"name"`
+			Version    string `yaml:"version"`
+			AppVersion string `yaml:"appVersion"`
+		}{}
+
+		if err := yaml.NewDecoder(tr).Decode(&metadata); err != nil {
+			return chart, fmt.Errorf("failed to parse Chart.yaml: %w", err)
+		}
+
+		if metadata.Name == "" || metadata.Version == "" {
+			return chart, fmt.Errorf("no chart name and version found")
+		}
+
+		chart.Name, chart.Version, chart.AppVersion = metadata.Name, metadata.Version, metadata.AppVersion
+		return chart, nil
+	}
+}
@@ -0,0 +1,296 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8spod
+
+import (
+	"context"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/log"
+)
+
+const (
+	Name    = "k8s-pod"
+	Type    = "https://witness.dev/attestations/k8s-pod/v0.1"
+	RunType = attestation.PreRunType
+
+	SourceAPIServer   = "api-server"
+	SourceDownwardAPI = "downward-api"
+
+	defaultServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+	apiServerTimeout = 10 * time.Second
+)
+
+// downwardAPIEnv are the environment variables the pod's spec is expected to set from the downward API when the
+// pod can't be read from the API server
+var downwardAPIEnv = struct {
+	PodName, Namespace, PodUID, ServiceAccount, NodeName string
+}{
+	PodName:        "POD_NAME",
+	Namespace:      "POD_NAMESPACE",
+	PodUID:         "POD_UID",
+	ServiceAccount: "POD_SERVICE_ACCOUNT",
+	NodeName:       "NODE_NAME",
+}
+
+var _ attestation.Attestor = &Attestor{}
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+type Option func(*Attestor)
+
+// WithServiceAccountDir sets where the pod's service account token, CA certificate, and namespace are mounted.
+// Defaults to /var/run/secrets/kubernetes.io/serviceaccount.
+func WithServiceAccountDir(dir string) Option {
+	return func(a *Attestor) {
+		a.serviceAccountDir = dir
+	}
+}
+
+type Owner struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	UID  string `json:"uid,omitempty"`
+}
+
+type Container struct {
+	Name  string `json:"name"`
+	Image string `json:"image"`
+	// ImageID is the image the container is running, by digest, as reported by the kubelet
+	ImageID string `json:"imageId,omitempty"`
+}
+
+// Attestor records the Kubernetes pod witness runs in: its namespace, name, service account, node, the Job that
+// owns it, and a digest of its spec, so policies can require a step was built in a cluster namespace such as
+// ci-prod. The pod is read from the API server with the pod's service account, which needs permission to get
+// pods, and otherwise from environment variables set with the downward API.
+type Attestor struct {
+	// Source is where the pod was described: the API server, or the downward API environment variables
+	Source         string            `json:"source"`
+	Namespace      string            `json:"namespace"`
+	PodName        string            `json:"podName"`
+	PodUID         string            `json:"podUid,omitempty"`
+	ServiceAccount string            `json:"serviceAccount,omitempty"`
+	NodeName       string            `json:"nodeName,omitempty"`
+	Job            string            `json:"job,omitempty"`
+	Owners         []Owner           `json:"owners,omitempty"`
+	Labels         map[string]string `json:"labels,omitempty"`
+	Containers     []Container       `json:"containers,omitempty"`
+	// PodSpecDigest is the digest of the pod's spec as the API server returned it, with its keys sorted
+	PodSpecDigest cryptoutil.DigestSet `json:"podSpecDigest,omitempty"`
+
+	serviceAccountDir string
+}
+
+func New(opts ...Option) *Attestor {
+	a := &Attestor{serviceAccountDir: defaultServiceAccountDir}
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	if os.Getenv("KUBERNETES_SERVICE_HOST") == "" {
+		return fmt.Errorf("witness doesn't appear to be running in a kubernetes pod")
+	}
+
+	a.Namespace = os.Getenv(downwardAPIEnv.Namespace)
+	if a.Namespace == "" {
+		a.Namespace = readTrimmed(filepath.Join(a.serviceAccountDir, "namespace"))
+	}
+
+	// pods are named after their hostname unless the spec sets one
+	a.PodName = os.Getenv(downwardAPIEnv.PodName)
+	if a.PodName == "" {
+		a.PodName, _ = os.Hostname()
+	}
+
+	if a.Namespace == "" || a.PodName == "" {
+		return fmt.Errorf("failed to find the pod's namespace and name: set %v and %v with the downward API", downwardAPIEnv.Namespace, downwardAPIEnv.PodName)
+	}
+
+	err := a.readPod(ctx)
+	if err == nil {
+		a.Source = SourceAPIServer
+		return nil
+	}
+
+	log.Warnf("failed to read the pod from the kubernetes api server, recording it from the downward api instead: %v", err)
+	a.Source = SourceDownwardAPI
+	a.PodUID = os.Getenv(downwardAPIEnv.PodUID)
+	a.ServiceAccount = os.Getenv(downwardAPIEnv.ServiceAccount)
+	a.NodeName = os.Getenv(downwardAPIEnv.NodeName)
+	return nil
+}
+
+// pod is the part of a pod object that's recorded. The spec is kept as it was returned so it can be digested.
+type pod struct {
+	Metadata struct {
+		UID             string            `json:"uid"`
+		Labels          map[string]string `json:"labels"`
+		OwnerReferences []Owner           `json:"ownerReferences"`
+	} `json:"metadata"`
+	Spec   json.RawMessage `json:"spec"`
+	Status struct {
+		ContainerStatuses []struct {
+			Name    string `json:"name"`
+			Image   string `json:"image"`
+			ImageID string `json:"imageID"`
+		} `json:"containerStatuses"`
+	} `json:"status"`
+}
+
+type podSpec struct {
+	NodeName           string `json:"nodeName"`
+	ServiceAccountName string `json:"serviceAccountName"`
+}
+
+// readPod gets the pod from the API server with the pod's service account
+func (a *Attestor) readPod(ctx *attestation.AttestationContext) error {
+	client, err := a.apiClient()
+	if err != nil {
+		return err
+	}
+
+	token, err := os.ReadFile(filepath.Join(a.serviceAccountDir, "token"))
+	if err != nil {
+		return fmt.Errorf("failed to read service account token: %w", err)
+	}
+
+	server := url.URL{
+		Scheme: "https",
+		Host:   net.JoinHostPort(os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")),
+		Path:   fmt.Sprintf("/api/v1/namespaces/%v/pods/%v", url.PathEscape(a.Namespace), url.PathEscape(a.PodName)),
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx.Context(), apiServerTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, server.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("api server returned %v", resp.Status)
+	}
+
+	p := pod{}
+	if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+		return fmt.Errorf("failed to parse pod: %w", err)
+	}
+
+	spec := podSpec{}
+	if err := json.Unmarshal(p.Spec, &spec); err != nil {
+		return fmt.Errorf("failed to parse pod spec: %w", err)
+	}
+
+	a.PodUID = p.Metadata.UID
+	a.ServiceAccount = spec.ServiceAccountName
+	a.NodeName = spec.NodeName
+	a.Labels = p.Metadata.Labels
+	a.Owners = p.Metadata.OwnerReferences
+	for _, owner := range a.Owners {
+		if owner.Kind == "Job" {
+			a.Job = owner.Name
+		}
+	}
+
+	for _, status := range p.Status.ContainerStatuses {
+		a.Containers = append(a.Containers, Container{Name: status.Name, Image: status.Image, ImageID: status.ImageID})
+	}
+
+	// the spec is decoded and encoded again so its keys are sorted, and its digest doesn't depend on the order the
+	// API server wrote them in
+	var decoded interface{}
+	if err := json.Unmarshal(p.Spec, &decoded); err != nil {
+		return fmt.Errorf("failed to parse pod spec: %w", err)
+	}
+
+	canonical, err := json.Marshal(decoded)
+	if err != nil {
+		return err
+	}
+
+	hashes := ctx.Hashes()
+	if len(hashes) == 0 {
+		hashes = []crypto.Hash{crypto.SHA256}
+	}
+
+	a.PodSpecDigest, err = cryptoutil.CalculateDigestSetFromBytes(canonical, hashes)
+	return err
+}
+
+// apiClient returns a client that trusts the cluster's CA
+func (a *Attestor) apiClient() (*http.Client, error) {
+	caPEM, err := os.ReadFile(filepath.Join(a.serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cluster ca: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("cluster ca doesn't contain any certificates")
+	}
+
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}}}, nil
+}
+
+func readTrimmed(path string) string {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(contents))
+}
@@ -0,0 +1,101 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package network
+
+import (
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/witness/pkg/ebpftrace"
+)
+
+const (
+	Name    = "network"
+	Type    = "https://witness.dev/attestations/network/v0.1"
+	RunType = attestation.PostRunType
+)
+
+var _ attestation.Attestor = &Attestor{}
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+// Source reports the connections the command's processes made
+type Source interface {
+	Connections() []ebpftrace.Connection
+}
+
+type Option func(*Attestor)
+
+// WithSource sets where the command's connections are read from, such as the eBPF tracer.
+func WithSource(source Source) Option {
+	return func(a *Attestor) {
+		a.source = source
+	}
+}
+
+// Attestor records the addresses the command's processes connected to, so policies can check which registries and
+// services a build reached
+type Attestor struct {
+	Connections []ebpftrace.Connection `json:"connections"`
+
+	source Source
+}
+
+func New(opts ...Option) *Attestor {
+	a := &Attestor{}
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	if a.source == nil {
+		return attestation.ErrInvalidOption{
+			Option: "Source",
+			Reason: "the network attestor requires the command to be traced with --trace --trace-backend ebpf",
+		}
+	}
+
+	// a process connecting to the same address many times, such as a package manager fetching from a registry, is
+	// recorded once
+	seen := make(map[ebpftrace.Connection]struct{})
+	a.Connections = []ebpftrace.Connection{}
+	for _, conn := range a.source.Connections() {
+		if _, ok := seen[conn]; ok {
+			continue
+		}
+
+		seen[conn] = struct{}{}
+		a.Connections = append(a.Connections, conn)
+	}
+
+	return nil
+}
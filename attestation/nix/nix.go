@@ -0,0 +1,494 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nix
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+)
+
+const (
+	Name    = "nix"
+	Type    = "https://witness.dev/attestations/nix/v0.1"
+	RunType = attestation.PostRunType
+
+	defaultStoreDir = "/nix/store"
+	defaultOutLink  = "result"
+	flakeLockFile   = "flake.lock"
+)
+
+var (
+	_ attestation.Attestor   = &Attestor{}
+	_ attestation.Subjecter  = &Attestor{}
+	_ attestation.Materialer = &Attestor{}
+)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+// Output is a store path the build produced
+type Output struct {
+	// Link is the out link in the working directory that points to the store path, such as result or result-dev
+	Link      string `json:"link"`
+	StorePath string `json:"storePath"`
+	// NarHash is the hash of the store path's NAR serialization, as reported by nix path-info
+	NarHash string               `json:"narHash,omitempty"`
+	Digest  cryptoutil.DigestSet `json:"digest,omitempty"`
+	Deriver string               `json:"deriver,omitempty"`
+}
+
+// Derivation is a derivation that built an output
+type Derivation struct {
+	Path   string               `json:"path"`
+	Digest cryptoutil.DigestSet `json:"digest,omitempty"`
+}
+
+// FlakeInput is an input locked in flake.lock
+type FlakeInput struct {
+	Name         string               `json:"name"`
+	Type         string               `json:"type,omitempty"`
+	URL          string               `json:"url,omitempty"`
+	Owner        string               `json:"owner,omitempty"`
+	Repo         string               `json:"repo,omitempty"`
+	Rev          string               `json:"rev,omitempty"`
+	LastModified int64                `json:"lastModified,omitempty"`
+	NarHash      string               `json:"narHash,omitempty"`
+	Digest       cryptoutil.DigestSet `json:"digest,omitempty"`
+}
+
+// FlakeLock is the flake.lock file in the working directory
+type FlakeLock struct {
+	File   string               `json:"file"`
+	Digest cryptoutil.DigestSet `json:"digest"`
+}
+
+type Option func(*Attestor)
+
+// WithCommand sets the command witness ran, so out links set with --out-link are found and commands that aren't a
+// nix build are skipped
+func WithCommand(args []string) Option {
+	return func(a *Attestor) {
+		a.command = args
+	}
+}
+
+// Attestor records the derivations, output store paths, and flake inputs of a nix build, so reproducible builds
+// made with nix carry provenance that can be checked against the store.
+type Attestor struct {
+	Derivations []Derivation `json:"derivations"`
+	Outputs     []Output     `json:"outputs"`
+	FlakeLock   *FlakeLock   `json:"flakeLock,omitempty"`
+	FlakeInputs []FlakeInput `json:"flakeInputs"`
+
+	command []string
+}
+
+func New(opts ...Option) *Attestor {
+	a := &Attestor{
+		Derivations: make([]Derivation, 0),
+		Outputs:     make([]Output, 0),
+		FlakeInputs: make([]FlakeInput, 0),
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	if len(a.command) > 0 && !IsBuildCommand(a.command) {
+		return nil
+	}
+
+	if err := a.recordFlakeLock(ctx); err != nil {
+		return err
+	}
+
+	if err := a.recordOutputs(ctx); err != nil {
+		return err
+	}
+
+	seen := map[string]bool{}
+	for _, output := range a.Outputs {
+		if output.Deriver == "" || seen[output.Deriver] {
+			continue
+		}
+
+		seen[output.Deriver] = true
+		drv := Derivation{Path: output.Deriver}
+		// the derivation may have been garbage collected or built on a remote store
+		if digest, err := cryptoutil.CalculateDigestSetFromFile(output.Deriver, hashes(ctx)); err == nil {
+			drv.Digest = digest
+		}
+
+		a.Derivations = append(a.Derivations, drv)
+	}
+
+	return nil
+}
+
+// Subjects names each output store path by its NAR hash and each derivation by the digest of its .drv file
+func (a *Attestor) Subjects() map[string]cryptoutil.DigestSet {
+	subjects := make(map[string]cryptoutil.DigestSet)
+	for _, output := range a.Outputs {
+		if len(output.Digest) > 0 {
+			subjects[fmt.Sprintf("output:%v", output.StorePath)] = output.Digest
+		}
+	}
+
+	for _, drv := range a.Derivations {
+		if len(drv.Digest) > 0 {
+			subjects[fmt.Sprintf("derivation:%v", drv.Path)] = drv.Digest
+		}
+	}
+
+	return subjects
+}
+
+// Materials names each locked flake input by its NAR hash
+func (a *Attestor) Materials() map[string]cryptoutil.DigestSet {
+	materials := make(map[string]cryptoutil.DigestSet)
+	for _, input := range a.FlakeInputs {
+		if len(input.Digest) > 0 {
+			materials[fmt.Sprintf("flake-input:%v", input.Name)] = input.Digest
+		}
+	}
+
+	return materials
+}
+
+// IsBuildCommand reports whether args runs nix-build or nix build
+func IsBuildCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	switch filepath.Base(args[0]) {
+	case "nix-build":
+		return true
+	case "nix":
+		subcommand, _ := nixSubcommand(args[1:])
+		return subcommand == "build"
+	default:
+		return false
+	}
+}
+
+// nixSubcommand returns the first argument that isn't an option to nix itself and the arguments after it
+func nixSubcommand(args []string) (string, []string) {
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "--option":
+			i += 2
+		case arg == "--extra-experimental-features" || arg == "--experimental-features" || arg == "--store" || arg == "--log-format":
+			i++
+		case strings.HasPrefix(arg, "-"):
+		default:
+			return arg, args[i+1:]
+		}
+	}
+
+	return "", nil
+}
+
+// outLink returns the out link the build was asked to create, or "" if it was asked not to create one
+func (a *Attestor) outLink() string {
+	var args []string
+	if len(a.command) > 0 && filepath.Base(a.command[0]) == "nix" {
+		_, args = nixSubcommand(a.command[1:])
+	} else if len(a.command) > 0 {
+		args = a.command[1:]
+	}
+
+	link := defaultOutLink
+	for i, arg := range args {
+		switch {
+		case arg == "--no-link" || arg == "--no-out-link":
+			return ""
+		case (arg == "-o" || arg == "--out-link") && i+1 < len(args):
+			link = args[i+1]
+		case strings.HasPrefix(arg, "--out-link="):
+			link = strings.TrimPrefix(arg, "--out-link=")
+		}
+	}
+
+	return link
+}
+
+// recordOutputs records the store paths the build's out links point to. Builds with more than one output create a
+// link for each output named after the out link, such as result-dev.
+func (a *Attestor) recordOutputs(ctx *attestation.AttestationContext) error {
+	link := a.outLink()
+	if link == "" {
+		return nil
+	}
+
+	storeDir := os.Getenv("NIX_STORE_DIR")
+	if storeDir == "" {
+		storeDir = defaultStoreDir
+	}
+
+	linkPath := link
+	if !filepath.IsAbs(linkPath) {
+		linkPath = filepath.Join(ctx.WorkingDir(), link)
+	}
+
+	candidates, err := filepath.Glob(linkPath + "*")
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(candidates)
+	paths := []string{}
+	for _, candidate := range candidates {
+		base := filepath.Base(candidate)
+		if base != filepath.Base(linkPath) && !strings.HasPrefix(base, filepath.Base(linkPath)+"-") {
+			continue
+		}
+
+		target, err := os.Readlink(candidate)
+		if err != nil || !strings.HasPrefix(target, storeDir+"/") {
+			continue
+		}
+
+		name := candidate
+		if rel, err := filepath.Rel(ctx.WorkingDir(), candidate); err == nil {
+			name = rel
+		}
+
+		a.Outputs = append(a.Outputs, Output{Link: name, StorePath: target})
+		paths = append(paths, target)
+	}
+
+	if len(paths) == 0 {
+		return nil
+	}
+
+	infos, err := pathInfo(ctx, paths)
+	if err != nil {
+		return err
+	}
+
+	for i, output := range a.Outputs {
+		info, ok := infos[output.StorePath]
+		if !ok {
+			continue
+		}
+
+		a.Outputs[i].NarHash = info.NarHash
+		a.Outputs[i].Deriver = info.Deriver
+		if digest, err := hashDigestSet(info.NarHash); err == nil {
+			a.Outputs[i].Digest = digest
+		}
+	}
+
+	return nil
+}
+
+type storePathInfo struct {
+	Path    string `json:"path"`
+	NarHash string `json:"narHash"`
+	Deriver string `json:"deriver"`
+}
+
+// pathInfo runs nix path-info for paths. Nix 2.19 and later report an object keyed by store path rather than an array.
+func pathInfo(ctx *attestation.AttestationContext, paths []string) (map[string]storePathInfo, error) {
+	args := append([]string{"--extra-experimental-features", "nix-command", "path-info", "--json"}, paths...)
+	cmd := exec.CommandContext(ctx.Context(), "nix", args...)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run nix path-info: %w: %v", err, strings.TrimSpace(stderr.String()))
+	}
+
+	infos := map[string]storePathInfo{}
+	list := []storePathInfo{}
+	if err := json.Unmarshal(out, &list); err == nil {
+		for _, info := range list {
+			infos[info.Path] = info
+		}
+
+		return infos, nil
+	}
+
+	byPath := map[string]*storePathInfo{}
+	if err := json.Unmarshal(out, &byPath); err != nil {
+		return nil, fmt.Errorf("failed to parse nix path-info output: %w", err)
+	}
+
+	for path, info := range byPath {
+		if info != nil {
+			infos[path] = *info
+		}
+	}
+
+	return infos, nil
+}
+
+type flakeLock struct {
+	Nodes map[string]struct {
+		Locked *struct {
+			Type         string `json:"type"`
+			URL          string `json:"url"`
+			Owner        string `json:"owner"`
+			Repo         string `json:"repo"`
+			Rev          string `json:"rev"`
+			LastModified int64  `json:"lastModified"`
+			NarHash      string `json:"narHash"`
+		} `json:"locked"`
+	} `json:"nodes"`
+	Root string `json:"root"`
+}
+
+// recordFlakeLock records the inputs locked in the working directory's flake.lock, if it has one
+func (a *Attestor) recordFlakeLock(ctx *attestation.AttestationContext) error {
+	path := filepath.Join(ctx.WorkingDir(), flakeLockFile)
+	lockBytes, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read %v: %w", flakeLockFile, err)
+	}
+
+	digest, err := cryptoutil.CalculateDigestSetFromBytes(lockBytes, hashes(ctx))
+	if err != nil {
+		return err
+	}
+
+	lock := flakeLock{}
+	if err := json.Unmarshal(lockBytes, &lock); err != nil {
+		return fmt.Errorf("failed to parse %v: %w", flakeLockFile, err)
+	}
+
+	a.FlakeLock = &FlakeLock{File: flakeLockFile, Digest: digest}
+	names := make([]string, 0, len(lock.Nodes))
+	for name, node := range lock.Nodes {
+		if name != lock.Root && node.Locked != nil {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		locked := lock.Nodes[name].Locked
+		input := FlakeInput{
+			Name:         name,
+			Type:         locked.Type,
+			URL:          locked.URL,
+			Owner:        locked.Owner,
+			Repo:         locked.Repo,
+			Rev:          locked.Rev,
+			LastModified: locked.LastModified,
+			NarHash:      locked.NarHash,
+		}
+
+		if digest, err := hashDigestSet(locked.NarHash); err == nil {
+			input.Digest = digest
+		}
+
+		a.FlakeInputs = append(a.FlakeInputs, input)
+	}
+
+	return nil
+}
+
+// hashDigestSet converts a nix SHA-256 hash, in SRI form (sha256-<base64>) or nix's own form (sha256:<base32>), to
+// a digest set
+func hashDigestSet(hash string) (cryptoutil.DigestSet, error) {
+	var sum []byte
+	var err error
+	switch {
+	case strings.HasPrefix(hash, "sha256-"):
+		sum, err = base64.StdEncoding.DecodeString(strings.TrimPrefix(hash, "sha256-"))
+	case strings.HasPrefix(hash, "sha256:"):
+		sum, err = decodeBase32(strings.TrimPrefix(hash, "sha256:"))
+	default:
+		return nil, fmt.Errorf("unsupported hash %q", hash)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sum) != crypto.SHA256.Size() {
+		return nil, fmt.Errorf("hash %q is not sha256", hash)
+	}
+
+	return cryptoutil.DigestSet{crypto.SHA256: hex.EncodeToString(sum)}, nil
+}
+
+// nixBase32Alphabet is the alphabet of nix's base32 encoding, which omits e, o, u, and t
+const nixBase32Alphabet = "0123456789abcdfghijklmnpqrsvwxyz"
+
+// decodeBase32 decodes nix's base32 encoding, which reads the string from its last character
+func decodeBase32(s string) ([]byte, error) {
+	out := make([]byte, len(s)*5/8)
+	for n := 0; n < len(s); n++ {
+		digit := strings.IndexByte(nixBase32Alphabet, s[len(s)-n-1])
+		if digit < 0 {
+			return nil, fmt.Errorf("invalid nix base32 character %q", s[len(s)-n-1])
+		}
+
+		b := n * 5
+		i, j := b/8, uint(b%8)
+		out[i] |= byte(digit << j)
+		if carry := byte(digit >> (8 - j)); i+1 < len(out) {
+			out[i+1] |= carry
+		} else if carry != 0 {
+			return nil, fmt.Errorf("invalid nix base32 hash %q", s)
+		}
+	}
+
+	return out, nil
+}
+
+func hashes(ctx *attestation.AttestationContext) []crypto.Hash {
+	if hashes := ctx.Hashes(); len(hashes) > 0 {
+		return hashes
+	}
+
+	return []crypto.Hash{crypto.SHA256}
+}
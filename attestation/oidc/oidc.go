@@ -0,0 +1,336 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oidc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"github.com/testifysec/go-witness/attestation"
+	"gopkg.in/square/go-jose.v2"
+)
+
+const (
+	Name    = "oidc"
+	Type    = "https://witness.dev/attestations/oidc/v0.1"
+	RunType = attestation.PreRunType
+
+	DefaultAudience = "witness"
+
+	ProviderGitHubActions = "github-actions"
+	ProviderBuildkite     = "buildkite"
+	ProviderCircleCI      = "circleci"
+	ProviderEnvironment   = "environment"
+	ProviderFile          = "file"
+
+	GitHubActionsIssuer = "https://token.actions.githubusercontent.com"
+	BuildkiteIssuer     = "https://agent.buildkite.com"
+	// CircleCIIssuerPrefix is followed by the ID of the organization CircleCI issued the token to
+	CircleCIIssuerPrefix = "https://oidc.circleci.com/org/"
+)
+
+var _ attestation.Attestor = &Attestor{}
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+type Option func(*Attestor)
+
+// WithAudience sets the audience tokens are requested for, and that tokens read from the environment or a file
+// must be issued to. Defaults to witness.
+func WithAudience(audience string) Option {
+	return func(a *Attestor) {
+		a.audience = audience
+	}
+}
+
+// WithTokenEnv reads the token from an environment variable, such as one GitLab sets from a job's id_tokens,
+// instead of detecting the CI system's identity provider
+func WithTokenEnv(name string) Option {
+	return func(a *Attestor) {
+		a.tokenEnv = name
+	}
+}
+
+// WithTokenFile reads the token from a file, such as a projected Kubernetes service account token, instead of
+// detecting the CI system's identity provider
+func WithTokenFile(path string) Option {
+	return func(a *Attestor) {
+		a.tokenFile = path
+	}
+}
+
+// WithIssuer sets the issuer the token must be issued by. It's required for tokens read from the environment or a
+// file, since anyone can issue a token. Tokens requested from a CI system must be issued by its issuer unless this is
+// set, such as for GitHub Enterprise Server.
+func WithIssuer(issuer string) Option {
+	return func(a *Attestor) {
+		a.issuer = issuer
+	}
+}
+
+// WithHTTPClient sets the client tokens are requested and verified with
+func WithHTTPClient(client *http.Client) Option {
+	return func(a *Attestor) {
+		a.client = client
+	}
+}
+
+type VerificationInfo struct {
+	JWKSURL string `json:"jwksUrl"`
+	KeyID   string `json:"keyId,omitempty"`
+}
+
+// Attestor records the claims of an OIDC identity token issued to the workload by its CI system, after verifying
+// the token against its issuer's published keys, so steps signed with long lived keys are still bound to a
+// verifiable workload identity. The token itself isn't recorded, since it could be replayed until it expires.
+type Attestor struct {
+	// Provider is where the token came from: the CI system it was requested from, or the environment variable or
+	// file it was read from
+	Provider   string                 `json:"provider"`
+	Issuer     string                 `json:"issuer"`
+	Subject    string                 `json:"subject"`
+	Audience   []string               `json:"audience"`
+	IssuedAt   time.Time              `json:"issuedAt"`
+	Expiry     time.Time              `json:"expiry"`
+	Claims     map[string]interface{} `json:"claims"`
+	VerifiedBy VerificationInfo       `json:"verifiedBy"`
+
+	audience  string
+	issuer    string
+	tokenEnv  string
+	tokenFile string
+	client    *http.Client
+}
+
+func New(opts ...Option) *Attestor {
+	a := &Attestor{
+		audience: DefaultAudience,
+		client:   http.DefaultClient,
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	provider, token, err := a.token(ctx.Context())
+	if err != nil {
+		return err
+	}
+
+	a.Provider = provider
+	return a.verify(ctx.Context(), token)
+}
+
+// checkIssuer checks issuer is the one tokens from the provider must be issued by. The token's issuer isn't trusted
+// until it's checked, since its keys are discovered from the issuer and anyone can serve OIDC discovery.
+func (a *Attestor) checkIssuer(issuer string) error {
+	expected := a.issuer
+	if expected == "" {
+		switch a.Provider {
+		case ProviderGitHubActions:
+			expected = GitHubActionsIssuer
+		case ProviderBuildkite:
+			expected = BuildkiteIssuer
+		case ProviderCircleCI:
+			orgID := strings.TrimPrefix(issuer, CircleCIIssuerPrefix)
+			if orgID == issuer || orgID == "" || strings.Contains(orgID, "/") {
+				return fmt.Errorf("identity token issuer %q isn't a circleci organization's issuer", issuer)
+			}
+
+			return nil
+		default:
+			return fmt.Errorf("the issuer of identity tokens read from the environment or a file must be set")
+		}
+	}
+
+	if issuer != expected {
+		return fmt.Errorf("identity token issuer %q isn't the expected issuer %v", issuer, expected)
+	}
+
+	return nil
+}
+
+// token returns an identity token for the workload and where it came from. Tokens are read from the environment
+// variable or file they were configured with, or requested from the CI system's identity provider.
+func (a *Attestor) token(ctx context.Context) (string, string, error) {
+	switch {
+	case a.tokenFile != "":
+		token, err := os.ReadFile(a.tokenFile)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to read identity token: %w", err)
+		}
+
+		return ProviderFile, strings.TrimSpace(string(token)), nil
+	case a.tokenEnv != "":
+		token := strings.TrimSpace(os.Getenv(a.tokenEnv))
+		if token == "" {
+			return "", "", fmt.Errorf("identity token environment variable %v isn't set", a.tokenEnv)
+		}
+
+		return ProviderEnvironment, token, nil
+	case os.Getenv("ACTIONS_ID_TOKEN_REQUEST_URL") != "":
+		token, err := a.requestGitHubToken(ctx)
+		return ProviderGitHubActions, token, err
+	case os.Getenv("BUILDKITE_AGENT_ACCESS_TOKEN") != "":
+		cmd := exec.CommandContext(ctx, "buildkite-agent", "oidc", "request-token", "--audience", a.audience)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		token, err := cmd.Output()
+		if err != nil {
+			return "", "", fmt.Errorf("failed to request identity token from buildkite: %w: %s", err, strings.TrimSpace(stderr.String()))
+		}
+
+		return ProviderBuildkite, strings.TrimSpace(string(token)), nil
+	}
+
+	// CircleCI issues every job a token for the organization, so the audience can't be chosen
+	for _, env := range []string{"CIRCLE_OIDC_TOKEN_V2", "CIRCLE_OIDC_TOKEN"} {
+		if token := os.Getenv(env); token != "" {
+			return ProviderCircleCI, token, nil
+		}
+	}
+
+	return "", "", fmt.Errorf("no identity provider was found: run in GitHub Actions with the id-token: write permission, Buildkite, or CircleCI, or set the token's environment variable or file")
+}
+
+// requestGitHubToken requests a token for the audience from GitHub Actions. Jobs need the id-token: write
+// permission for the request URL and token to be set.
+func (a *Attestor) requestGitHubToken(ctx context.Context) (string, error) {
+	requestURL, err := url.Parse(os.Getenv("ACTIONS_ID_TOKEN_REQUEST_URL"))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse ACTIONS_ID_TOKEN_REQUEST_URL: %w", err)
+	}
+
+	query := requestURL.Query()
+	query.Set("audience", a.audience)
+	requestURL.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL.String(), nil)
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Authorization", "bearer "+os.Getenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN"))
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request identity token from github actions: %w", err)
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to request identity token from github actions: %v", resp.Status)
+	}
+
+	body := struct {
+		Value string `json:"value"`
+	}{}
+
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to parse identity token response: %w", err)
+	}
+
+	return body.Value, nil
+}
+
+// verify checks the token was issued by the expected issuer, its signature against the keys the issuer publishes
+// through OIDC discovery, and that it hasn't expired, then records its claims. The audience isn't checked for
+// CircleCI tokens, which are issued to the organization.
+func (a *Attestor) verify(ctx context.Context, token string) error {
+	signed, err := jose.ParseSigned(token)
+	if err != nil {
+		return fmt.Errorf("failed to parse identity token: %w", err)
+	}
+
+	unverified := struct {
+		Issuer string `json:"iss"`
+	}{}
+
+	if err := json.Unmarshal(signed.UnsafePayloadWithoutVerification(), &unverified); err != nil {
+		return fmt.Errorf("failed to parse identity token claims: %w", err)
+	}
+
+	if !strings.HasPrefix(unverified.Issuer, "https://") {
+		return fmt.Errorf("identity token issuer %q must be an https url", unverified.Issuer)
+	}
+
+	if err := a.checkIssuer(unverified.Issuer); err != nil {
+		return err
+	}
+
+	ctx = gooidc.ClientContext(ctx, a.client)
+	provider, err := gooidc.NewProvider(ctx, unverified.Issuer)
+	if err != nil {
+		return fmt.Errorf("failed to discover identity token issuer %v: %w", unverified.Issuer, err)
+	}
+
+	config := &gooidc.Config{ClientID: a.audience, SkipClientIDCheck: a.Provider == ProviderCircleCI}
+	idToken, err := provider.Verifier(config).Verify(ctx, token)
+	if err != nil {
+		return fmt.Errorf("failed to verify identity token: %w", err)
+	}
+
+	a.Claims = make(map[string]interface{})
+	if err := idToken.Claims(&a.Claims); err != nil {
+		return fmt.Errorf("failed to parse identity token claims: %w", err)
+	}
+
+	discovery := struct {
+		JWKSURL string `json:"jwks_uri"`
+	}{}
+
+	if err := provider.Claims(&discovery); err != nil {
+		return fmt.Errorf("failed to parse identity token issuer's configuration: %w", err)
+	}
+
+	a.Issuer = idToken.Issuer
+	a.Subject = idToken.Subject
+	a.Audience = idToken.Audience
+	a.IssuedAt = idToken.IssuedAt
+	a.Expiry = idToken.Expiry
+	a.VerifiedBy = VerificationInfo{JWKSURL: discovery.JWKSURL}
+	if len(signed.Signatures) > 0 {
+		a.VerifiedBy.KeyID = signed.Signatures[0].Header.KeyID
+	}
+
+	return nil
+}
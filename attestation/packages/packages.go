@@ -0,0 +1,287 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packages
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/pkg/purl"
+)
+
+const (
+	Name    = "packages"
+	Type    = "https://witness.dev/attestations/packages/v0.1"
+	RunType = attestation.PostRunType
+
+	EcosystemNPM  = "npm"
+	EcosystemPyPI = "pypi"
+)
+
+var (
+	_ attestation.Attestor  = &Attestor{}
+	_ attestation.Subjecter = &Attestor{}
+)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+// Package is a registry package built by the command, such as an npm tarball or a python wheel or sdist
+type Package struct {
+	File      string               `json:"file"`
+	Ecosystem string               `json:"ecosystem"`
+	Name      string               `json:"name"`
+	Version   string               `json:"version"`
+	PURL      string               `json:"purl"`
+	Digest    cryptoutil.DigestSet `json:"digest"`
+}
+
+type Attestor struct {
+	Packages []Package `json:"packages"`
+}
+
+func New() *Attestor {
+	return &Attestor{
+		Packages: make([]Package, 0),
+	}
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	products := ctx.Products()
+	files := make([]string, 0, len(products))
+	for file := range products {
+		files = append(files, file)
+	}
+
+	sort.Strings(files)
+	for _, file := range files {
+		ecosystem, read := packageReader(file)
+		if read == nil {
+			continue
+		}
+
+		name, version, err := read(filepath.Join(ctx.WorkingDir(), file))
+		if err != nil {
+			log.Debugf("(attestation/packages) skipping %v: %v", file, err)
+			continue
+		}
+
+		pkgPURL := purl.NPM(name, version)
+		if ecosystem == EcosystemPyPI {
+			pkgPURL = purl.PyPI(name, version)
+		}
+
+		a.Packages = append(a.Packages, Package{
+			File:      file,
+			Ecosystem: ecosystem,
+			Name:      name,
+			Version:   version,
+			PURL:      pkgPURL.String(),
+			Digest:    products[file].Digest,
+		})
+	}
+
+	return nil
+}
+
+// Subjects names each package by its purl, so policies can refer to packages by their registry coordinates.
+// A python package may be built as both a wheel and an sdist, so the file name is added as a qualifier.
+func (a *Attestor) Subjects() map[string]cryptoutil.DigestSet {
+	subjects := make(map[string]cryptoutil.DigestSet)
+	for _, pkg := range a.Packages {
+		subject, err := purl.Parse(pkg.PURL)
+		if err != nil {
+			log.Debugf("(attestation/packages) skipping subject %v: %v", pkg.PURL, err)
+			continue
+		}
+
+		subject.Qualifiers = map[string]string{"file_name": path.Base(filepath.ToSlash(pkg.File))}
+		subjects[subject.String()] = pkg.Digest
+	}
+
+	return subjects
+}
+
+type metadataReader func(path string) (name, version string, err error)
+
+func packageReader(file string) (string, metadataReader) {
+	switch {
+	case strings.HasSuffix(file, ".tgz"):
+		return EcosystemNPM, readNPMTarball
+	case strings.HasSuffix(file, ".whl"):
+		return EcosystemPyPI, readWheel
+	case strings.HasSuffix(file, ".tar.gz"):
+		return EcosystemPyPI, readSdist
+	default:
+		return "", nil
+	}
+}
+
+// readNPMTarball reads the name and version from the package.json of an npm pack tarball
+func readNPMTarball(path string) (string, string, error) {
+	var name, version string
+	err := walkTarGz(path, func(hdr *tar.Header, r io.Reader) (bool, error) {
+		if hdr.Name != "package/package.json" {
+			return false, nil
+		}
+
+		manifest := struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		}{}
+
+		if err := json.NewDecoder(r).Decode(&manifest); err != nil {
+			return true, fmt.Errorf("failed to parse package.json: %w", err)
+		}
+
+		name, version = manifest.Name, manifest.Version
+		return true, nil
+	})
+
+	return requireNameVersion(name, version, err)
+}
+
+// readSdist reads the core metadata from the PKG-INFO at the root of a python source distribution
+func readSdist(path string) (string, string, error) {
+	var name, version string
+	err := walkTarGz(path, func(hdr *tar.Header, r io.Reader) (bool, error) {
+		dir, file := splitTopLevel(hdr.Name)
+		if dir == "" || file != "PKG-INFO" {
+			return false, nil
+		}
+
+		var err error
+		name, version, err = readCoreMetadata(r)
+		return true, err
+	})
+
+	return requireNameVersion(name, version, err)
+}
+
+// readWheel reads the core metadata from the METADATA file of a wheel's dist-info directory
+func readWheel(path string) (string, string, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return "", "", err
+	}
+
+	defer zr.Close()
+	for _, f := range zr.File {
+		dir, file := splitTopLevel(f.Name)
+		if !strings.HasSuffix(dir, ".dist-info") || file != "METADATA" {
+			continue
+		}
+
+		r, err := f.Open()
+		if err != nil {
+			return "", "", err
+		}
+
+		defer r.Close()
+		return requireNameVersion(readCoreMetadata(r))
+	}
+
+	return requireNameVersion("", "", nil)
+}
+
+func walkTarGz(path string, visit func(hdr *tar.Header, r io.Reader) (bool, error)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if done, err := visit(hdr, tr); done || err != nil {
+			return err
+		}
+	}
+}
+
+// splitTopLevel splits a path of the form dir/file, returning empty strings for deeper or shallower paths
+func splitTopLevel(name string) (string, string) {
+	dir, file := path.Split(path.Clean(name))
+	dir = strings.TrimSuffix(dir, "/")
+	if dir == "" || strings.Contains(dir, "/") {
+		return "", ""
+	}
+
+	return dir, file
+}
+
+// readCoreMetadata reads the Name and Version headers of python core metadata
+func readCoreMetadata(r io.Reader) (string, string, error) {
+	header, err := textproto.NewReader(bufio.NewReader(r)).ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return "", "", fmt.Errorf("failed to parse package metadata: %w", err)
+	}
+
+	return header.Get("Name"), header.Get("Version"), nil
+}
+
+func requireNameVersion(name, version string, err error) (string, string, error) {
+	if err != nil {
+		return "", "", err
+	}
+
+	if name == "" || version == "" {
+		return "", "", fmt.Errorf("no package name and version found")
+	}
+
+	return name, version, nil
+}
@@ -0,0 +1,209 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processtree
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// The kernel's process events connector reports every fork, exec, and exit on the system, with the exit status
+// and a monotonic timestamp. See include/uapi/linux/cn_proc.h.
+const (
+	cnIdxProc        = 1
+	cnValProc        = 1
+	procCnListen     = 1
+	procCnIgnore     = 2
+	procEventFork    = 0x00000001
+	procEventExec    = 0x00000002
+	procEventExit    = 0x80000000
+	nlmsgHeaderLen   = 16
+	cnMsgHeaderLen   = 20
+	procEventHdrLen  = 16
+	receiveTimeout   = 100 * time.Millisecond
+	receiveBufferLen = 1 << 16
+)
+
+// monitor records process events for the descendants of witness between start and stop
+type monitor struct {
+	fd      int
+	done    chan struct{}
+	stopped chan struct{}
+
+	mu        sync.Mutex
+	processes map[int]*event
+	dropped   bool
+}
+
+func startMonitor() (*monitor, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_DGRAM, syscall.NETLINK_CONNECTOR)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open process events connector: %w", err)
+	}
+
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: cnIdxProc}); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("failed to bind process events connector: %w", err)
+	}
+
+	tv := syscall.NsecToTimeval(receiveTimeout.Nanoseconds())
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("failed to set process events timeout: %w", err)
+	}
+
+	// a larger buffer makes dropped events less likely when a build starts many short lived processes
+	_ = syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_RCVBUF, 4<<20)
+	if err := sendControl(fd, procCnListen); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("failed to subscribe to process events: %w", err)
+	}
+
+	m := &monitor{
+		fd:        fd,
+		done:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+		processes: map[int]*event{os.Getpid(): {pid: os.Getpid()}},
+	}
+
+	go m.receive()
+	return m, nil
+}
+
+// stop waits for the events already queued to be read and returns what was recorded, keyed by pid
+func (m *monitor) stop() (map[int]*event, bool) {
+	close(m.done)
+	<-m.stopped
+	_ = sendControl(m.fd, procCnIgnore)
+	syscall.Close(m.fd)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.processes, os.Getpid())
+	return m.processes, m.dropped
+}
+
+func (m *monitor) receive() {
+	defer close(m.stopped)
+	buf := make([]byte, receiveBufferLen)
+	for {
+		n, _, err := syscall.Recvfrom(m.fd, buf, 0)
+		if err != nil {
+			switch {
+			case errors.Is(err, syscall.EINTR):
+				continue
+			case errors.Is(err, syscall.ENOBUFS):
+				m.mu.Lock()
+				m.dropped = true
+				m.mu.Unlock()
+				continue
+			case errors.Is(err, syscall.EAGAIN):
+				// the queue is empty, so every event from before stop was called has been read
+				select {
+				case <-m.done:
+					return
+				default:
+					continue
+				}
+			default:
+				return
+			}
+		}
+
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			continue
+		}
+
+		for _, msg := range msgs {
+			m.handle(msg.Data)
+		}
+	}
+}
+
+func (m *monitor) handle(data []byte) {
+	if len(data) < cnMsgHeaderLen+procEventHdrLen {
+		return
+	}
+
+	ev := data[cnMsgHeaderLen:]
+	what := binary.LittleEndian.Uint32(ev[0:])
+	timestamp := time.Duration(binary.LittleEndian.Uint64(ev[8:]))
+	body := ev[procEventHdrLen:]
+	field := func(i int) int {
+		if len(body) < (i+1)*4 {
+			return 0
+		}
+
+		return int(binary.LittleEndian.Uint32(body[i*4:]))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch what {
+	case procEventFork:
+		parent, childPid, child := field(1), field(2), field(3)
+		// new threads are reported as forks too, but only processes are recorded
+		if childPid != child {
+			return
+		}
+
+		if _, ok := m.processes[parent]; !ok {
+			return
+		}
+
+		m.processes[child] = &event{pid: child, parentPID: parent, start: timestamp}
+	case procEventExec:
+		pid := field(1)
+		p, ok := m.processes[pid]
+		if !ok {
+			return
+		}
+
+		p.execed = true
+		// the arguments are read as soon as the event arrives; a process that has already exited is recorded without them
+		if cmdline, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid)); err == nil && len(cmdline) > 0 {
+			p.argv = strings.Split(strings.TrimSuffix(string(cmdline), "\x00"), "\x00")
+		}
+	case procEventExit:
+		threadPid, pid, status := field(0), field(1), field(2)
+		if threadPid != pid {
+			return
+		}
+
+		if p, ok := m.processes[pid]; ok {
+			p.exited, p.status, p.end = true, status, timestamp
+		}
+	}
+}
+
+// sendControl subscribes to or unsubscribes from process events
+func sendControl(fd int, op uint32) error {
+	msg := make([]byte, nlmsgHeaderLen+cnMsgHeaderLen+4)
+	binary.LittleEndian.PutUint32(msg[0:], uint32(len(msg)))
+	binary.LittleEndian.PutUint16(msg[4:], syscall.NLMSG_DONE)
+	binary.LittleEndian.PutUint32(msg[12:], uint32(os.Getpid()))
+	binary.LittleEndian.PutUint32(msg[16:], cnIdxProc)
+	binary.LittleEndian.PutUint32(msg[20:], cnValProc)
+	binary.LittleEndian.PutUint16(msg[32:], 4)
+	binary.LittleEndian.PutUint32(msg[36:], op)
+	return syscall.Sendto(fd, msg, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK})
+}
@@ -0,0 +1,270 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processtree
+
+import (
+	"os"
+	"sort"
+	"time"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/attestation/commandrun"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/pkg/runhook"
+)
+
+const (
+	Name    = "process-tree"
+	Type    = "https://witness.dev/attestations/process-tree/v0.1"
+	RunType = attestation.PostRunType
+)
+
+var (
+	_ attestation.Attestor    = &Attestor{}
+	_ runhook.PreCommandHook  = &Attestor{}
+	_ runhook.PostCommandHook = &Attestor{}
+)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+// Process is a process the command started, directly or through its children
+type Process struct {
+	PID     int                  `json:"pid"`
+	Program string               `json:"program,omitempty"`
+	Digest  cryptoutil.DigestSet `json:"digest,omitempty"`
+	// Argv is the process' arguments as it was executed, when they could be read before it exited
+	Argv []string `json:"argv,omitempty"`
+	// ExitCode is set when the process exited normally, and Signal when it was killed by a signal
+	ExitCode *int `json:"exitCode,omitempty"`
+	Signal   int  `json:"signal,omitempty"`
+	// StartOffset is when the process started relative to the command, in nanoseconds
+	StartOffset time.Duration `json:"startOffset,omitempty"`
+	// Duration is how long the process ran for, in nanoseconds
+	Duration time.Duration `json:"duration,omitempty"`
+	Children []Process     `json:"children,omitempty"`
+}
+
+// Attestor records the tree of processes a traced command started
+type Attestor struct {
+	Root         *Process `json:"root,omitempty"`
+	ProcessCount int      `json:"processCount"`
+	// Monitored is true when exit statuses and timings were recorded from the kernel's process events, which
+	// requires CAP_NET_ADMIN. Otherwise only the traced processes and the command's exit code are recorded.
+	Monitored bool `json:"monitored"`
+	// DroppedEvents is true when the kernel dropped process events, so some exit statuses may be missing
+	DroppedEvents bool `json:"droppedEvents,omitempty"`
+
+	monitor *monitor
+	events  map[int]*event
+}
+
+// event is what the kernel reported about a process while the command ran
+type event struct {
+	pid, parentPID int
+	argv           []string
+	start, end     time.Duration
+	execed, exited bool
+	status         int
+}
+
+func New() *Attestor {
+	return &Attestor{}
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+// PreCommand starts listening for process events, continuing without them if they're unavailable
+func (a *Attestor) PreCommand(ctx *attestation.AttestationContext) error {
+	m, err := startMonitor()
+	if err != nil {
+		log.Debugf("(attestation/process-tree) recording without exit statuses or timings: %v", err)
+		return nil
+	}
+
+	a.monitor = m
+	return nil
+}
+
+func (a *Attestor) PostCommand(ctx *attestation.AttestationContext) error {
+	if a.monitor == nil {
+		return nil
+	}
+
+	a.events, a.DroppedEvents = a.monitor.stop()
+	a.monitor, a.Monitored = nil, true
+	return nil
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	var command *commandrun.CommandRun
+	for _, completed := range ctx.CompletedAttestors() {
+		if cr, ok := runhook.Unwrap(completed).(*commandrun.CommandRun); ok {
+			command = cr
+		}
+	}
+
+	if command == nil || len(command.Processes) == 0 {
+		return attestation.ErrInvalidOption{
+			Option: "Tracing",
+			Reason: "the process-tree attestor requires the command to be traced with --trace",
+		}
+	}
+
+	a.Root, a.ProcessCount = buildTree(command.Processes, a.events)
+	if a.Root == nil {
+		return nil
+	}
+
+	// the tracer starts after the command's own exec, so it's described by what witness ran
+	if len(a.Root.Argv) == 0 {
+		a.Root.Argv = command.Cmd
+	}
+
+	if a.Root.ExitCode == nil && a.Root.Signal == 0 {
+		exitCode := command.ExitCode
+		a.Root.ExitCode = &exitCode
+	}
+
+	return nil
+}
+
+// buildTree joins the traced processes with the process events into a tree rooted at the process witness started
+func buildTree(traced []commandrun.ProcessInfo, events map[int]*event) (*Process, int) {
+	nodes := make(map[int]*Process)
+	parents := make(map[int]int)
+	for _, info := range traced {
+		// the traced command line and comm are read as the process calls exec, so they're its parent's
+		nodes[info.ProcessID] = &Process{
+			PID:     info.ProcessID,
+			Program: info.Program,
+			Digest:  info.ProgramDigest,
+		}
+
+		parents[info.ProcessID] = info.ParentPID
+	}
+
+	for pid, ev := range events {
+		node, ok := nodes[pid]
+		if !ok {
+			node = &Process{PID: pid}
+			nodes[pid] = node
+		}
+
+		// forks are reported with the original parent, even if the child is reparented when its parent exits
+		parents[pid] = ev.parentPID
+		node.Argv = ev.argv
+
+		if ev.exited {
+			node.ExitCode, node.Signal = exitStatus(ev.status)
+			if ev.start > 0 {
+				node.Duration = ev.end - ev.start
+			}
+		}
+	}
+
+	// the command is the process witness started that ran a program, rather than one the go runtime forked
+	root := -1
+	for pid, ev := range events {
+		if ev.parentPID == os.Getpid() && ev.execed && (root < 0 || pid < root) {
+			root = pid
+		}
+	}
+
+	// without process events, the command is the traced process witness started, or if its own exec wasn't traced,
+	// the parent of the first processes it traced
+	if root < 0 {
+		for pid, parent := range parents {
+			if _, ok := nodes[pid]; ok && parent == os.Getpid() && (root < 0 || pid < root) {
+				root = pid
+			}
+		}
+	}
+
+	if root < 0 {
+		for _, parent := range parents {
+			if _, ok := nodes[parent]; !ok && parent > 0 && (root < 0 || parent < root) {
+				root = parent
+			}
+		}
+	}
+
+	if root < 0 {
+		return nil, 0
+	}
+
+	if _, ok := nodes[root]; !ok {
+		nodes[root] = &Process{PID: root}
+	}
+
+	children := make(map[int][]int)
+	for pid, parent := range parents {
+		if pid != root {
+			children[parent] = append(children[parent], pid)
+		}
+	}
+
+	var rootStart time.Duration
+	if ev, ok := events[root]; ok {
+		rootStart = ev.start
+	}
+
+	count := 0
+	var build func(pid int) Process
+	build = func(pid int) Process {
+		count++
+		node := *nodes[pid]
+		if ev, ok := events[pid]; ok && ev.start > rootStart {
+			node.StartOffset = ev.start - rootStart
+		}
+
+		pids := children[pid]
+		sort.Ints(pids)
+		for _, child := range pids {
+			if _, ok := nodes[child]; ok {
+				node.Children = append(node.Children, build(child))
+			}
+		}
+
+		return node
+	}
+
+	tree := build(root)
+	return &tree, count
+}
+
+// exitStatus decodes a wait status into an exit code, or the signal that killed the process
+func exitStatus(status int) (*int, int) {
+	if signal := status & 0x7f; signal != 0 {
+		return nil, signal
+	}
+
+	exitCode := (status >> 8) & 0xff
+	return &exitCode, 0
+}
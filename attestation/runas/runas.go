@@ -0,0 +1,76 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runas
+
+import (
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/witness/pkg/privdrop"
+)
+
+const (
+	Name    = "run-as"
+	Type    = "https://witness.dev/attestations/run-as/v0.1"
+	RunType = attestation.PreRunType
+)
+
+var (
+	_ attestation.Attestor = &Attestor{}
+)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+type Option func(*Attestor)
+
+// WithIdentity sets the user and group the command ran as.
+func WithIdentity(id privdrop.Identity) Option {
+	return func(a *Attestor) {
+		a.Identity = id
+	}
+}
+
+// Attestor records the user and group witness dropped to before running the command, so policies can require
+// that builds didn't run with witness' privileges.
+type Attestor struct {
+	privdrop.Identity
+}
+
+func New(opts ...Option) *Attestor {
+	a := &Attestor{}
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	return nil
+}
@@ -0,0 +1,157 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scai
+
+import (
+	"debug/elf"
+	"strings"
+)
+
+// Attributes detected in ELF binaries, named after the compiler and linker features that produce them
+const (
+	AttributeStackProtection = "WITH_STACK_PROTECTION"
+	AttributeFortifySource   = "WITH_FORTIFY_SOURCE"
+	AttributePIE             = "WITH_PIE"
+	AttributeRELRO           = "WITH_RELRO"
+	AttributeFullRELRO       = "WITH_FULL_RELRO"
+	AttributeNXStack         = "WITH_NX_STACK"
+)
+
+const (
+	dfBindNow = 0x8
+	df1Now    = 0x1
+)
+
+// elfHardening returns an assertion for each hardening feature an ELF binary was built with. It returns an error
+// if path isn't an ELF file.
+func elfHardening(path string) ([]AttributeAssertion, error) {
+	f, err := elf.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	attributes := []AttributeAssertion{}
+	add := func(attribute, detection string) {
+		attributes = append(attributes, AttributeAssertion{
+			Attribute:  attribute,
+			Conditions: map[string]interface{}{"detection": detection},
+		})
+	}
+
+	symbols := symbolNames(f)
+	if symbols["__stack_chk_fail"] || symbols["__stack_chk_guard"] {
+		add(AttributeStackProtection, "references __stack_chk_fail")
+	}
+
+	for symbol := range symbols {
+		if strings.HasSuffix(symbol, "_chk") && !strings.HasPrefix(symbol, "__stack_chk") {
+			add(AttributeFortifySource, "references fortified libc functions")
+			break
+		}
+	}
+
+	hasInterp, hasRELRO := false, false
+	for _, prog := range f.Progs {
+		switch prog.Type {
+		case elf.PT_INTERP:
+			hasInterp = true
+		case elf.PT_GNU_RELRO:
+			hasRELRO = true
+		case elf.PT_GNU_STACK:
+			if prog.Flags&elf.PF_X == 0 {
+				add(AttributeNXStack, "PT_GNU_STACK is not executable")
+			}
+		}
+	}
+
+	if f.Type == elf.ET_DYN && hasInterp {
+		add(AttributePIE, "position independent executable")
+	}
+
+	if hasRELRO {
+		add(AttributeRELRO, "has a PT_GNU_RELRO segment")
+		if bindNow(f) {
+			add(AttributeFullRELRO, "relocations are resolved at load time")
+		}
+	}
+
+	return attributes, nil
+}
+
+// symbolNames returns the names of the dynamic and static symbols of the binary, without symbol versions
+func symbolNames(f *elf.File) map[string]bool {
+	names := make(map[string]bool)
+	for _, load := range []func() ([]elf.Symbol, error){f.DynamicSymbols, f.Symbols} {
+		symbols, err := load()
+		if err != nil {
+			continue
+		}
+
+		for _, symbol := range symbols {
+			name, _, _ := strings.Cut(symbol.Name, "@")
+			names[name] = true
+		}
+	}
+
+	return names
+}
+
+// bindNow reports whether the dynamic section asks the loader to resolve every symbol at load time, which lets
+// the whole GOT be made read only
+func bindNow(f *elf.File) bool {
+	section := f.Section(".dynamic")
+	if section == nil {
+		return false
+	}
+
+	data, err := section.Data()
+	if err != nil {
+		return false
+	}
+
+	size := 16
+	if f.Class == elf.ELFCLASS32 {
+		size = 8
+	}
+
+	for i := 0; i+size <= len(data); i += size {
+		var tag, value uint64
+		if f.Class == elf.ELFCLASS32 {
+			tag = uint64(f.ByteOrder.Uint32(data[i:]))
+			value = uint64(f.ByteOrder.Uint32(data[i+4:]))
+		} else {
+			tag = f.ByteOrder.Uint64(data[i:])
+			value = f.ByteOrder.Uint64(data[i+8:])
+		}
+
+		switch elf.DynTag(tag) {
+		case elf.DT_BIND_NOW:
+			return true
+		case elf.DT_FLAGS:
+			if value&dfBindNow != 0 {
+				return true
+			}
+		case elf.DT_FLAGS_1:
+			if value&df1Now != 0 {
+				return true
+			}
+		case elf.DT_NULL:
+			return false
+		}
+	}
+
+	return false
+}
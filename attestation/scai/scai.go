@@ -0,0 +1,227 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scai
+
+import (
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/log"
+)
+
+const (
+	Name = "scai"
+	// Type is the in-toto SCAI attribute report predicate type, so the attestation can be read by any tool that
+	// understands SCAI
+	Type    = "https://in-toto.io/attestation/scai/attribute-report/v0.2"
+	RunType = attestation.PostRunType
+)
+
+var _ attestation.Attestor = &Attestor{}
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+// ResourceDescriptor identifies an artifact, such as the target of an attribute or the evidence for it
+type ResourceDescriptor struct {
+	Name             string                 `json:"name,omitempty"`
+	URI              string                 `json:"uri,omitempty"`
+	Digest           map[string]string      `json:"digest,omitempty"`
+	DownloadLocation string                 `json:"downloadLocation,omitempty"`
+	MediaType        string                 `json:"mediaType,omitempty"`
+	Annotations      map[string]interface{} `json:"annotations,omitempty"`
+}
+
+// AttributeAssertion asserts that a target has an attribute, such as being compiled with stack protection,
+// under some conditions and backed by optional evidence
+type AttributeAssertion struct {
+	Attribute  string                 `json:"attribute"`
+	Target     *ResourceDescriptor    `json:"target,omitempty"`
+	Conditions map[string]interface{} `json:"conditions,omitempty"`
+	Evidence   *ResourceDescriptor    `json:"evidence,omitempty"`
+}
+
+type Option func(*Attestor)
+
+// WithAttributesFile adds the assertions in a SCAI attribute report written by the command. The path is relative to
+// the working directory.
+func WithAttributesFile(path string) Option {
+	return func(a *Attestor) {
+		a.attributesFile = path
+	}
+}
+
+// Attestor records SCAI attribute assertions about the products of the command. The hardening of ELF products is
+// detected automatically, and other assertions can be read from an attribute report the build writes.
+type Attestor struct {
+	Attributes []AttributeAssertion `json:"attributes"`
+	Producer   *ResourceDescriptor  `json:"producer,omitempty"`
+
+	attributesFile string
+}
+
+func New(opts ...Option) *Attestor {
+	a := &Attestor{
+		Attributes: make([]AttributeAssertion, 0),
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	products := ctx.Products()
+	files := make([]string, 0, len(products))
+	for file := range products {
+		files = append(files, file)
+	}
+
+	sort.Strings(files)
+	for _, file := range files {
+		attributes, err := elfHardening(filepath.Join(ctx.WorkingDir(), file))
+		if err != nil {
+			log.Debugf("(attestation/scai) skipping %v: %v", file, err)
+			continue
+		}
+
+		digest, err := products[file].Digest.ToNameMap()
+		if err != nil {
+			return err
+		}
+
+		for _, attribute := range attributes {
+			attribute.Target = &ResourceDescriptor{Name: file, Digest: digest}
+			a.Attributes = append(a.Attributes, attribute)
+		}
+	}
+
+	if a.attributesFile == "" {
+		return nil
+	}
+
+	report, err := readReport(filepath.Join(ctx.WorkingDir(), a.attributesFile))
+	if err != nil {
+		return fmt.Errorf("failed to read scai attributes: %w", err)
+	}
+
+	for _, attribute := range report.Attributes {
+		for _, descriptor := range []*ResourceDescriptor{attribute.Target, attribute.Evidence} {
+			if err := resolveDigest(ctx, descriptor); err != nil {
+				return err
+			}
+		}
+
+		a.Attributes = append(a.Attributes, attribute)
+	}
+
+	a.Producer = report.Producer
+	return nil
+}
+
+// report is a SCAI attribute report written by the build
+type report struct {
+	Attributes []AttributeAssertion `json:"attributes"`
+	Producer   *ResourceDescriptor  `json:"producer,omitempty"`
+}
+
+func readReport(path string) (report, error) {
+	r := report{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return r, err
+	}
+
+	if err := json.Unmarshal(data, &r); err != nil {
+		return r, err
+	}
+
+	for i, attribute := range r.Attributes {
+		if attribute.Attribute == "" {
+			return r, fmt.Errorf("assertion %d has no attribute", i)
+		}
+	}
+
+	return r, nil
+}
+
+// resolveDigest fills in the digest of a descriptor that names a file but has no digest. Products use the digest
+// recorded by the product attestor, and other files in the working directory are hashed.
+func resolveDigest(ctx *attestation.AttestationContext, descriptor *ResourceDescriptor) error {
+	if descriptor == nil || descriptor.Name == "" || len(descriptor.Digest) > 0 {
+		return nil
+	}
+
+	digestSet, ok := cryptoutil.DigestSet(nil), false
+	if product, found := ctx.Products()[descriptor.Name]; found {
+		digestSet, ok = product.Digest, true
+	} else if path := filepath.Join(ctx.WorkingDir(), descriptor.Name); fileExists(path) {
+		var err error
+		digestSet, err = cryptoutil.CalculateDigestSetFromFile(path, hashes(ctx))
+		if err != nil {
+			return fmt.Errorf("failed to calculate digest of %v: %w", descriptor.Name, err)
+		}
+
+		ok = true
+	}
+
+	if !ok {
+		return nil
+	}
+
+	digest, err := digestSet.ToNameMap()
+	if err != nil {
+		return err
+	}
+
+	descriptor.Digest = digest
+	return nil
+}
+
+func hashes(ctx *attestation.AttestationContext) []crypto.Hash {
+	if hashes := ctx.Hashes(); len(hashes) > 0 {
+		return hashes
+	}
+
+	return []crypto.Hash{crypto.SHA256}
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
@@ -0,0 +1,316 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package securitycontext
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/testifysec/go-witness/attestation"
+)
+
+const (
+	Name    = "securitycontext"
+	Type    = "https://witness.dev/attestations/securitycontext/v0.1"
+	RunType = attestation.PreRunType
+
+	defaultProcDir = "/proc"
+	defaultSysDir  = "/sys"
+)
+
+var (
+	_ attestation.Attestor = &Attestor{}
+
+	// capabilityNames are indexed by capability number, as defined in linux/capability.h
+	capabilityNames = []string{
+		"CAP_CHOWN",
+		"CAP_DAC_OVERRIDE",
+		"CAP_DAC_READ_SEARCH",
+		"CAP_FOWNER",
+		"CAP_FSETID",
+		"CAP_KILL",
+		"CAP_SETGID",
+		"CAP_SETUID",
+		"CAP_SETPCAP",
+		"CAP_LINUX_IMMUTABLE",
+		"CAP_NET_BIND_SERVICE",
+		"CAP_NET_BROADCAST",
+		"CAP_NET_ADMIN",
+		"CAP_NET_RAW",
+		"CAP_IPC_LOCK",
+		"CAP_IPC_OWNER",
+		"CAP_SYS_MODULE",
+		"CAP_SYS_RAWIO",
+		"CAP_SYS_CHROOT",
+		"CAP_SYS_PTRACE",
+		"CAP_SYS_PACCT",
+		"CAP_SYS_ADMIN",
+		"CAP_SYS_BOOT",
+		"CAP_SYS_NICE",
+		"CAP_SYS_RESOURCE",
+		"CAP_SYS_TIME",
+		"CAP_SYS_TTY_CONFIG",
+		"CAP_MKNOD",
+		"CAP_LEASE",
+		"CAP_AUDIT_WRITE",
+		"CAP_AUDIT_CONTROL",
+		"CAP_SETFCAP",
+		"CAP_MAC_OVERRIDE",
+		"CAP_MAC_ADMIN",
+		"CAP_SYSLOG",
+		"CAP_WAKE_ALARM",
+		"CAP_BLOCK_SUSPEND",
+		"CAP_AUDIT_READ",
+		"CAP_PERFMON",
+		"CAP_BPF",
+		"CAP_CHECKPOINT_RESTORE",
+	}
+
+	seccompModes = map[string]string{
+		"0": "disabled",
+		"1": "strict",
+		"2": "filter",
+	}
+)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+type Option func(*Attestor)
+
+// WithProcDir sets where procfs is mounted. Defaults to /proc.
+func WithProcDir(dir string) Option {
+	return func(a *Attestor) {
+		a.procDir = dir
+	}
+}
+
+// WithSysDir sets where sysfs is mounted. Defaults to /sys.
+func WithSysDir(dir string) Option {
+	return func(a *Attestor) {
+		a.sysDir = dir
+	}
+}
+
+type Capabilities struct {
+	Effective   []string `json:"effective"`
+	Permitted   []string `json:"permitted"`
+	Inheritable []string `json:"inheritable"`
+	Bounding    []string `json:"bounding"`
+	Ambient     []string `json:"ambient"`
+}
+
+// Attestor records the Linux security context witness runs the command in: its seccomp mode, capabilities,
+// AppArmor profile or SELinux context, and namespaces. The command inherits this context, so policies can require
+// builds ran in a constrained sandbox.
+type Attestor struct {
+	Seccomp        string            `json:"seccomp"`
+	SeccompFilters int               `json:"seccompfilters"`
+	NoNewPrivs     bool              `json:"nonewprivs"`
+	Capabilities   Capabilities      `json:"capabilities"`
+	AppArmor       string            `json:"apparmor,omitempty"`
+	SELinux        string            `json:"selinux,omitempty"`
+	Namespaces     map[string]string `json:"namespaces"`
+
+	procDir string
+	sysDir  string
+}
+
+func New(opts ...Option) *Attestor {
+	a := &Attestor{
+		procDir: defaultProcDir,
+		sysDir:  defaultSysDir,
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	selfDir := filepath.Join(a.procDir, "self")
+	if err := a.readStatus(filepath.Join(selfDir, "status")); err != nil {
+		return err
+	}
+
+	a.AppArmor, a.SELinux = a.readLSMLabels(filepath.Join(selfDir, "attr"))
+	namespaces, err := readNamespaces(filepath.Join(selfDir, "ns"))
+	if err != nil {
+		return err
+	}
+
+	a.Namespaces = namespaces
+	return nil
+}
+
+// readStatus reads the seccomp, no_new_privs, and capability fields of a process' status file
+func (a *Attestor) readStatus(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read process status: %w", err)
+	}
+
+	defer f.Close()
+	fields := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if ok {
+			fields[key] = strings.TrimSpace(value)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read process status: %w", err)
+	}
+
+	// Seccomp is missing from the status of processes on kernels built without seccomp support
+	a.Seccomp = "disabled"
+	if mode, ok := fields["Seccomp"]; ok {
+		if a.Seccomp, ok = seccompModes[mode]; !ok {
+			return fmt.Errorf("unknown seccomp mode %q", mode)
+		}
+	}
+
+	if filters, ok := fields["Seccomp_filters"]; ok {
+		if a.SeccompFilters, err = strconv.Atoi(filters); err != nil {
+			return fmt.Errorf("failed to parse seccomp filter count: %w", err)
+		}
+	}
+
+	a.NoNewPrivs = fields["NoNewPrivs"] == "1"
+	for _, set := range []struct {
+		field string
+		names *[]string
+	}{
+		{"CapEff", &a.Capabilities.Effective},
+		{"CapPrm", &a.Capabilities.Permitted},
+		{"CapInh", &a.Capabilities.Inheritable},
+		{"CapBnd", &a.Capabilities.Bounding},
+		{"CapAmb", &a.Capabilities.Ambient},
+	} {
+		if *set.names, err = parseCapabilities(fields[set.field]); err != nil {
+			return fmt.Errorf("failed to parse %v: %w", set.field, err)
+		}
+	}
+
+	return nil
+}
+
+// parseCapabilities returns the names of the capabilities set in a hex capability mask. Capabilities newer than
+// witness are recorded by number.
+func parseCapabilities(mask string) ([]string, error) {
+	names := []string{}
+	if mask == "" {
+		return names, nil
+	}
+
+	bits, err := strconv.ParseUint(mask, 16, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < 64; i++ {
+		if bits&(1<<uint(i)) == 0 {
+			continue
+		}
+
+		if i < len(capabilityNames) {
+			names = append(names, capabilityNames[i])
+		} else {
+			names = append(names, fmt.Sprintf("CAP_%d", i))
+		}
+	}
+
+	return names, nil
+}
+
+// readLSMLabels returns the AppArmor profile and SELinux context of the process, if either is enabled
+func (a *Attestor) readLSMLabels(attrDir string) (appArmor, seLinux string) {
+	if readSysFlag(filepath.Join(a.sysDir, "module", "apparmor", "parameters", "enabled")) == "Y" {
+		// kernels that can stack LSMs expose the AppArmor profile in its own directory
+		appArmor = readLabel(filepath.Join(attrDir, "apparmor", "current"))
+		if appArmor == "" {
+			appArmor = readLabel(filepath.Join(attrDir, "current"))
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(a.sysDir, "fs", "selinux", "enforce")); err == nil {
+		seLinux = readLabel(filepath.Join(attrDir, "current"))
+	}
+
+	return appArmor, seLinux
+}
+
+func readSysFlag(path string) string {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(contents))
+}
+
+func readLabel(path string) string {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(bytes.TrimRight(contents, "\x00")))
+}
+
+// readNamespaces returns the namespace each link in a process' ns directory refers to, such as pid:[4026531836].
+// Processes sharing a namespace have the same link.
+func readNamespaces(nsDir string) (map[string]string, error) {
+	entries, err := os.ReadDir(nsDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read process namespaces: %w", err)
+	}
+
+	namespaces := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		target, err := os.Readlink(filepath.Join(nsDir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %v namespace: %w", entry.Name(), err)
+		}
+
+		namespaces[entry.Name()] = target
+	}
+
+	return namespaces, nil
+}
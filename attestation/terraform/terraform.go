@@ -0,0 +1,332 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package terraform
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/log"
+)
+
+const (
+	Name    = "terraform-plan"
+	Type    = "https://witness.dev/attestations/terraform-plan/v0.1"
+	RunType = attestation.PostRunType
+
+	// FormatUI is the stream of JSON messages written by terraform plan -json
+	FormatUI = "ui"
+	// FormatJSON is the plan representation written by terraform show -json
+	FormatJSON = "json"
+
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionReplace = "replace"
+	ActionRead    = "read"
+
+	// uiModule is the @module of every message terraform plan -json writes
+	uiModule = "terraform.ui"
+	// planFileEntry is the entry every saved terraform plan file contains
+	planFileEntry = "tfplan"
+)
+
+var (
+	_ attestation.Attestor  = &Attestor{}
+	_ attestation.Subjecter = &Attestor{}
+)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+// ResourceChange is a change terraform plans to make to a resource
+type ResourceChange struct {
+	Address string `json:"address"`
+	Type    string `json:"type"`
+	Action  string `json:"action"`
+}
+
+// Summary counts the resources a plan adds, changes, and removes. Replaced resources are counted as both added and
+// removed, as terraform does.
+type Summary struct {
+	Add    int `json:"add"`
+	Change int `json:"change"`
+	Remove int `json:"remove"`
+}
+
+// Plan is the machine readable output of a terraform plan built by the command
+type Plan struct {
+	File             string           `json:"file"`
+	Format           string           `json:"format"`
+	TerraformVersion string           `json:"terraformversion,omitempty"`
+	Summary          Summary          `json:"summary"`
+	Changes          []ResourceChange `json:"changes"`
+}
+
+// PlanFile is a saved plan built by the command with terraform plan -out, which terraform apply can apply
+type PlanFile struct {
+	File   string               `json:"file"`
+	Digest cryptoutil.DigestSet `json:"digest"`
+}
+
+// Attestor records the resource changes in terraform plans the command writes, and the digests of saved plan files,
+// so infrastructure changes can be gated on witness verification before they're applied.
+type Attestor struct {
+	Plans     []Plan     `json:"plans"`
+	PlanFiles []PlanFile `json:"planfiles"`
+}
+
+func New() *Attestor {
+	return &Attestor{
+		Plans:     make([]Plan, 0),
+		PlanFiles: make([]PlanFile, 0),
+	}
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	products := ctx.Products()
+	files := make([]string, 0, len(products))
+	for file := range products {
+		files = append(files, file)
+	}
+
+	sort.Strings(files)
+	for _, file := range files {
+		filePath := filepath.Join(ctx.WorkingDir(), file)
+		if isPlanFile(filePath) {
+			a.PlanFiles = append(a.PlanFiles, PlanFile{File: file, Digest: products[file].Digest})
+			continue
+		}
+
+		plan, ok, err := readPlan(filePath)
+		if err != nil {
+			log.Debugf("(attestation/terraform-plan) skipping %v: %v", file, err)
+			continue
+		}
+
+		if ok {
+			plan.File = file
+			a.Plans = append(a.Plans, plan)
+		}
+	}
+
+	return nil
+}
+
+// Subjects names each saved plan file so the collection can be found from the plan terraform apply is given
+func (a *Attestor) Subjects() map[string]cryptoutil.DigestSet {
+	subjects := make(map[string]cryptoutil.DigestSet)
+	for _, planFile := range a.PlanFiles {
+		subjects[fmt.Sprintf("tfplan:%v", planFile.File)] = planFile.Digest
+	}
+
+	return subjects
+}
+
+// isPlanFile reports whether path is a saved terraform plan, which is a zip archive containing a tfplan entry
+func isPlanFile(path string) bool {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return false
+	}
+
+	defer zr.Close()
+	for _, f := range zr.File {
+		if f.Name == planFileEntry {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readPlan reads a plan written by terraform plan -json or terraform show -json, and returns false if path is
+// neither
+func readPlan(path string) (Plan, bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Plan{}, false, err
+	}
+
+	defer f.Close()
+	reader := bufio.NewReader(f)
+	// plans are JSON objects, so other files can be skipped without reading them
+	start, err := reader.Peek(1)
+	if err != nil || start[0] != '{' {
+		return Plan{}, false, nil
+	}
+
+	decoder := json.NewDecoder(reader)
+	first := json.RawMessage{}
+	if err := decoder.Decode(&first); err != nil {
+		return Plan{}, false, nil
+	}
+
+	probe := struct {
+		Module          string          `json:"@module"`
+		FormatVersion   string          `json:"format_version"`
+		ResourceChanges json.RawMessage `json:"resource_changes"`
+	}{}
+
+	if err := json.Unmarshal(first, &probe); err != nil {
+		return Plan{}, false, nil
+	}
+
+	switch {
+	case probe.FormatVersion != "" && probe.ResourceChanges != nil:
+		plan, err := readJSONPlan(first)
+		return plan, err == nil, err
+	case probe.Module == uiModule:
+		plan, err := readUIPlan(first, decoder)
+		return plan, err == nil, err
+	default:
+		return Plan{}, false, nil
+	}
+}
+
+// uiMessage is a message written by terraform plan -json, as documented in terraform's machine readable UI docs
+type uiMessage struct {
+	Type    string `json:"type"`
+	Version string `json:"terraform"`
+	Change  struct {
+		Resource struct {
+			Addr         string `json:"addr"`
+			ResourceType string `json:"resource_type"`
+		} `json:"resource"`
+		Action string `json:"action"`
+	} `json:"change"`
+	Changes struct {
+		Add    int `json:"add"`
+		Change int `json:"change"`
+		Remove int `json:"remove"`
+	} `json:"changes"`
+}
+
+func readUIPlan(first json.RawMessage, decoder *json.Decoder) (Plan, error) {
+	plan := Plan{Format: FormatUI, Changes: make([]ResourceChange, 0)}
+	msg := uiMessage{}
+	if err := json.Unmarshal(first, &msg); err != nil {
+		return plan, fmt.Errorf("failed to parse plan message: %w", err)
+	}
+
+	for {
+		switch msg.Type {
+		case "version":
+			plan.TerraformVersion = msg.Version
+		case "planned_change":
+			plan.Changes = append(plan.Changes, ResourceChange{
+				Address: msg.Change.Resource.Addr,
+				Type:    msg.Change.Resource.ResourceType,
+				Action:  msg.Change.Action,
+			})
+		case "change_summary":
+			plan.Summary = Summary{Add: msg.Changes.Add, Change: msg.Changes.Change, Remove: msg.Changes.Remove}
+		}
+
+		msg = uiMessage{}
+		if !decoder.More() {
+			return plan, nil
+		}
+
+		if err := decoder.Decode(&msg); err != nil {
+			return plan, fmt.Errorf("failed to parse plan message: %w", err)
+		}
+	}
+}
+
+// jsonResourceChange is an entry of resource_changes in terraform's JSON plan representation
+type jsonResourceChange struct {
+	Address string `json:"address"`
+	Type    string `json:"type"`
+	Change  struct {
+		Actions []string `json:"actions"`
+	} `json:"change"`
+}
+
+func readJSONPlan(data []byte) (Plan, error) {
+	plan := Plan{Format: FormatJSON, Changes: make([]ResourceChange, 0)}
+	doc := struct {
+		TerraformVersion string               `json:"terraform_version"`
+		ResourceChanges  []jsonResourceChange `json:"resource_changes"`
+	}{}
+
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return plan, fmt.Errorf("failed to parse plan: %w", err)
+	}
+
+	plan.TerraformVersion = doc.TerraformVersion
+	for _, rc := range doc.ResourceChanges {
+		action := planAction(rc.Change.Actions)
+		switch action {
+		case "":
+			continue
+		case ActionCreate:
+			plan.Summary.Add++
+		case ActionUpdate:
+			plan.Summary.Change++
+		case ActionDelete:
+			plan.Summary.Remove++
+		case ActionReplace:
+			plan.Summary.Add++
+			plan.Summary.Remove++
+		}
+
+		plan.Changes = append(plan.Changes, ResourceChange{Address: rc.Address, Type: rc.Type, Action: action})
+	}
+
+	return plan, nil
+}
+
+// planAction converts the actions of a JSON plan resource change to the action terraform plan -json reports,
+// or an empty string if the resource doesn't change
+func planAction(actions []string) string {
+	switch strings.Join(actions, ",") {
+	case "create":
+		return ActionCreate
+	case "update":
+		return ActionUpdate
+	case "delete":
+		return ActionDelete
+	case "delete,create", "create,delete":
+		return ActionReplace
+	case "read":
+		return ActionRead
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timesource
+
+import "syscall"
+
+const (
+	// timeError is the clock state adjtimex returns when the clock isn't synchronized
+	timeError = 5
+	// staUnsync is the adjtimex status flag set while the clock isn't synchronized
+	staUnsync = 0x40
+)
+
+// readClock reads the clock synchronization state NTP daemons maintain in the kernel
+func readClock() (*Clock, error) {
+	tx := syscall.Timex{}
+	state, err := syscall.Adjtimex(&tx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Clock{
+		Synchronized:   state != timeError && tx.Status&staUnsync == 0,
+		MaxError:       int64(tx.Maxerror),
+		EstimatedError: int64(tx.Esterror),
+	}, nil
+}
@@ -0,0 +1,139 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timesource
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	tsp "github.com/digitorus/timestamp"
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/timestamp"
+)
+
+const (
+	Name    = "time-source"
+	Type    = "https://witness.dev/attestations/time-source/v0.1"
+	RunType = attestation.PreRunType
+)
+
+var (
+	_ attestation.Attestor = &Attestor{}
+)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+type Option func(*Attestor)
+
+// WithTimestampServers sets the timestamp authorities the system clock is compared against.
+func WithTimestampServers(urls []string) Option {
+	return func(a *Attestor) {
+		a.timestampServers = urls
+	}
+}
+
+// Clock is the synchronization state the kernel reports for the system clock. Errors are in microseconds.
+type Clock struct {
+	Synchronized   bool  `json:"synchronized"`
+	MaxError       int64 `json:"maxerror"`
+	EstimatedError int64 `json:"estimatederror"`
+}
+
+// Authority is the time a timestamp authority reported and how far it was from the system clock
+type Authority struct {
+	URL  string    `json:"url"`
+	Time time.Time `json:"time"`
+	// Offset is the authority's time minus the system time halfway through the request, in milliseconds
+	Offset int64 `json:"offset"`
+	// RoundTrip is how long the request took in milliseconds, which bounds how accurate Offset can be
+	RoundTrip int64 `json:"roundtrip"`
+}
+
+// Attestor records the system time, whether the system clock is synchronized, and how far it is from the
+// timestamp authorities the envelope is signed with, so verifiers can detect a manipulated clock that would
+// undermine checks of when certificates and attestations were valid.
+type Attestor struct {
+	SystemTime  time.Time   `json:"systemtime"`
+	Clock       *Clock      `json:"clock,omitempty"`
+	Authorities []Authority `json:"authorities,omitempty"`
+
+	timestampServers []string
+}
+
+func New(opts ...Option) *Attestor {
+	a := &Attestor{}
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	a.SystemTime = time.Now().UTC()
+	clock, err := readClock()
+	if err != nil {
+		return fmt.Errorf("failed to read clock synchronization state: %w", err)
+	}
+
+	a.Clock = clock
+	for _, url := range a.timestampServers {
+		authority, err := queryAuthority(ctx, url)
+		if err != nil {
+			return fmt.Errorf("failed to get time from timestamp authority %v: %w", url, err)
+		}
+
+		a.Authorities = append(a.Authorities, authority)
+	}
+
+	return nil
+}
+
+func queryAuthority(ctx *attestation.AttestationContext, url string) (Authority, error) {
+	authority := Authority{URL: url}
+	start := time.Now()
+	token, err := timestamp.NewTimestamper(timestamp.TimestampWithUrl(url)).Timestamp(ctx.Context(), bytes.NewReader([]byte(start.Format(time.RFC3339Nano))))
+	if err != nil {
+		return authority, err
+	}
+
+	roundTrip := time.Since(start)
+	ts, err := tsp.Parse(token)
+	if err != nil {
+		return authority, fmt.Errorf("failed to parse timestamp: %w", err)
+	}
+
+	authority.Time = ts.Time.UTC()
+	authority.Offset = ts.Time.Sub(start.Add(roundTrip / 2)).Milliseconds()
+	authority.RoundTrip = roundTrip.Milliseconds()
+	return authority, nil
+}
@@ -0,0 +1,284 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/attestation/commandrun"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/witness/pkg/runhook"
+)
+
+const (
+	Name    = "tools"
+	Type    = "https://witness.dev/attestations/tools/v0.1"
+	RunType = attestation.PostRunType
+)
+
+var (
+	_ attestation.Attestor   = &Attestor{}
+	_ attestation.Materialer = &Attestor{}
+)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+// Tool is an executable that ran during the step
+type Tool struct {
+	// Name is the name the executable was run by, such as gcc
+	Name string `json:"name"`
+	// Path is the absolute path the name resolved to
+	Path string `json:"path"`
+	// RealPath is the file Path points to if it's a symlink, such as the versioned binary behind an alternatives link
+	RealPath string               `json:"realPath,omitempty"`
+	Digest   cryptoutil.DigestSet `json:"digest,omitempty"`
+	// PathEntry is the PATH directory the name was found in
+	PathEntry string `json:"pathEntry,omitempty"`
+	// Shadowed are executables with the same name later in PATH that didn't run
+	Shadowed []string `json:"shadowed,omitempty"`
+}
+
+type Option func(*Attestor)
+
+// WithCommand sets the command whose executable is resolved.
+func WithCommand(args []string) Option {
+	return func(a *Attestor) {
+		a.args = args
+	}
+}
+
+// WithSearchPath sets the PATH the command's executable is resolved in, which may differ from witness' own when the
+// command's environment is overridden.
+func WithSearchPath(path string) Option {
+	return func(a *Attestor) {
+		a.searchPath = &path
+	}
+}
+
+// WithTracedExecutables records every executable the traced command started, not only the command's.
+func WithTracedExecutables(traced bool) Option {
+	return func(a *Attestor) {
+		a.traced = traced
+	}
+}
+
+// Attestor records which executable the command's name resolved to, and optionally every executable the command
+// started under tracing, so policies can check which compiler actually ran.
+type Attestor struct {
+	Command     *Tool    `json:"command,omitempty"`
+	SearchPath  []string `json:"searchPath,omitempty"`
+	Executables []Tool   `json:"executables,omitempty"`
+
+	args       []string
+	searchPath *string
+	traced     bool
+}
+
+func New(opts ...Option) *Attestor {
+	a := &Attestor{}
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	if len(a.args) == 0 {
+		return attestation.ErrInvalidOption{
+			Option: "Command",
+			Reason: "the tools attestor requires a command",
+		}
+	}
+
+	searchPath := os.Getenv("PATH")
+	if a.searchPath != nil {
+		searchPath = *a.searchPath
+	}
+
+	a.SearchPath = filepath.SplitList(searchPath)
+	command, err := resolve(a.args[0], a.SearchPath, ctx.WorkingDir())
+	if err != nil {
+		return err
+	}
+
+	if command.Digest, err = cryptoutil.CalculateDigestSetFromFile(command.Path, ctx.Hashes()); err != nil {
+		return fmt.Errorf("failed to digest %v: %w", command.Path, err)
+	}
+
+	a.Command = &command
+	if a.traced {
+		a.Executables = tracedExecutables(ctx)
+	}
+
+	return nil
+}
+
+// Materials returns the executables that ran, so policies can require them like any other input
+func (a *Attestor) Materials() map[string]cryptoutil.DigestSet {
+	materials := make(map[string]cryptoutil.DigestSet)
+	tools := append([]Tool{}, a.Executables...)
+	if a.Command != nil {
+		tools = append(tools, *a.Command)
+	}
+
+	for _, tool := range tools {
+		if len(tool.Digest) == 0 {
+			continue
+		}
+
+		path := tool.Path
+		if tool.RealPath != "" {
+			path = tool.RealPath
+		}
+
+		materials["tool:"+path] = tool.Digest
+	}
+
+	return materials
+}
+
+// resolve finds the executable name runs, the way a shell searches PATH. Relative names and PATH entries are
+// relative to workingDir.
+func resolve(name string, searchPath []string, workingDir string) (Tool, error) {
+	tool := Tool{Name: name}
+	if strings.ContainsRune(name, filepath.Separator) {
+		path := name
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(workingDir, path)
+		}
+
+		if !isExecutable(path) {
+			return tool, fmt.Errorf("%v is not an executable file", name)
+		}
+
+		tool.Path = path
+		tool.RealPath = realPath(path)
+		return tool, nil
+	}
+
+	for _, dir := range searchPath {
+		entry := dir
+		if dir == "" {
+			dir = "."
+		}
+
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(workingDir, dir)
+		}
+
+		candidate := filepath.Join(dir, name)
+		if !isExecutable(candidate) {
+			continue
+		}
+
+		// PATH often reaches the same file more than once, such as through /bin linking to /usr/bin
+		if tool.Path == "" {
+			tool.Path, tool.PathEntry = candidate, entry
+		} else if !sameFile(candidate, tool.Path) {
+			tool.Shadowed = append(tool.Shadowed, candidate)
+		}
+	}
+
+	if tool.Path == "" {
+		return tool, fmt.Errorf("%v: executable file not found in PATH", name)
+	}
+
+	tool.RealPath = realPath(tool.Path)
+	return tool, nil
+}
+
+// tracedExecutables returns the programs of the processes the command run attestor traced
+func tracedExecutables(ctx *attestation.AttestationContext) []Tool {
+	var processes []commandrun.ProcessInfo
+	for _, completed := range ctx.CompletedAttestors() {
+		if cr, ok := runhook.Unwrap(completed).(*commandrun.CommandRun); ok {
+			processes = cr.Processes
+		}
+	}
+
+	byPath := make(map[string]Tool)
+	for _, process := range processes {
+		if process.Program == "" {
+			continue
+		}
+
+		if _, ok := byPath[process.Program]; ok {
+			continue
+		}
+
+		tool := Tool{Name: filepath.Base(process.Program), Path: process.Program, Digest: process.ProgramDigest}
+		tool.RealPath = realPath(process.Program)
+		if len(tool.Digest) == 0 {
+			tool.Digest, _ = cryptoutil.CalculateDigestSetFromFile(process.Program, ctx.Hashes())
+		}
+
+		byPath[process.Program] = tool
+	}
+
+	tools := make([]Tool, 0, len(byPath))
+	for _, tool := range byPath {
+		tools = append(tools, tool)
+	}
+
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Path < tools[j].Path })
+	return tools
+}
+
+// realPath returns the file path points to if it's a symlink, or an empty string otherwise
+func realPath(path string) string {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil || resolved == path {
+		return ""
+	}
+
+	return resolved
+}
+
+func sameFile(a, b string) bool {
+	aInfo, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+
+	bInfo, err := os.Stat(b)
+	return err == nil && os.SameFile(aInfo, bInfo)
+}
+
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular() && info.Mode()&0111 != 0
+}
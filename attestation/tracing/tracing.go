@@ -0,0 +1,179 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"time"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/attestation/commandrun"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/pkg/ebpftrace"
+	"github.com/testifysec/witness/pkg/runhook"
+)
+
+const (
+	Name    = "tracing"
+	Type    = "https://witness.dev/attestations/tracing/v0.1"
+	RunType = attestation.PostRunType
+)
+
+var (
+	_ attestation.Attestor    = &Attestor{}
+	_ runhook.PreCommandHook  = &Attestor{}
+	_ runhook.PostCommandHook = &Attestor{}
+)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+// StatsSource reports what tracing the command cost, such as the eBPF tracer
+type StatsSource interface {
+	Stats() ebpftrace.Stats
+}
+
+type Option func(*Attestor)
+
+// WithBackend sets the backend the command was traced with
+func WithBackend(backend string) Option {
+	return func(a *Attestor) {
+		a.Backend = backend
+	}
+}
+
+// WithSampleRate records the rate file opens were sampled at
+func WithSampleRate(rate int) Option {
+	return func(a *Attestor) {
+		a.SampleRate = rate
+	}
+}
+
+// WithMaxFiles records the limit on the files recorded
+func WithMaxFiles(max int) Option {
+	return func(a *Attestor) {
+		a.MaxFiles = max
+	}
+}
+
+// WithStats sets where the backend's event counts and program run time are read from
+func WithStats(source StatsSource) Option {
+	return func(a *Attestor) {
+		a.stats = source
+	}
+}
+
+// Overhead is what tracing cost while the command ran
+type Overhead struct {
+	// CommandDuration is how long the command ran for, in nanoseconds
+	CommandDuration time.Duration `json:"commandDuration"`
+	// TracerCPUTime is the CPU time witness used while the command ran, in nanoseconds. The ptrace backend's cost
+	// is paid here.
+	TracerCPUTime time.Duration `json:"tracerCpuTime,omitempty"`
+	// ProgramTime is the time the kernel spent running the eBPF backend's programs, in nanoseconds, which is paid
+	// by the traced processes. It's only measured on Linux 5.8 and later.
+	ProgramTime time.Duration `json:"programTime,omitempty"`
+}
+
+// Attestor records how the command was traced, what was left out of the trace, and what tracing cost, so the
+// completeness of the commandrun attestation's processes can be judged
+type Attestor struct {
+	Backend string `json:"backend"`
+	// SampleRate is set when only one in SampleRate file opens was recorded
+	SampleRate int `json:"sampleRate,omitempty"`
+	// MaxFiles is set when no more than MaxFiles files were recorded
+	MaxFiles      int `json:"maxFiles,omitempty"`
+	FilesRecorded int `json:"filesRecorded"`
+	// FileLimitReached is true when files went unrecorded because MaxFiles was reached
+	FileLimitReached bool `json:"fileLimitReached,omitempty"`
+	// Events and LostEvents count the events the eBPF backend received from the kernel and the events the kernel
+	// dropped, in which case processes or files may be missing
+	Events     uint64   `json:"events,omitempty"`
+	LostEvents uint64   `json:"lostEvents,omitempty"`
+	Overhead   Overhead `json:"overhead"`
+
+	stats    StatsSource
+	start    time.Time
+	startCPU time.Duration
+}
+
+func New(opts ...Option) *Attestor {
+	a := &Attestor{}
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) PreCommand(ctx *attestation.AttestationContext) error {
+	a.start = time.Now()
+	a.startCPU = cpuTime()
+	return nil
+}
+
+func (a *Attestor) PostCommand(ctx *attestation.AttestationContext) error {
+	a.Overhead.CommandDuration = time.Since(a.start)
+	a.Overhead.TracerCPUTime = cpuTime() - a.startCPU
+	return nil
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	var command *commandrun.CommandRun
+	for _, completed := range ctx.CompletedAttestors() {
+		if cr, ok := runhook.Unwrap(completed).(*commandrun.CommandRun); ok {
+			command = cr
+		}
+	}
+
+	if command == nil || len(command.Processes) == 0 {
+		return attestation.ErrInvalidOption{
+			Option: "Tracing",
+			Reason: "the tracing attestor requires the command to be traced with --trace",
+		}
+	}
+
+	files := make(map[string]struct{})
+	for _, p := range command.Processes {
+		for path := range p.OpenedFiles {
+			files[path] = struct{}{}
+		}
+	}
+
+	a.FilesRecorded = len(files)
+	if a.stats != nil {
+		stats := a.stats.Stats()
+		a.Events, a.LostEvents = stats.Events, stats.LostEvents
+		a.FileLimitReached = stats.FileLimitReached
+		a.Overhead.ProgramTime = stats.ProgramTime
+	}
+
+	log.Debugf("(attestation/tracing) recorded %v files in %v, using %v of CPU time", a.FilesRecorded, a.Overhead.CommandDuration, a.Overhead.TracerCPUTime)
+	return nil
+}
@@ -0,0 +1,209 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vex
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+const csafVEXCategory = "csaf_vex"
+
+// csafStatuses maps the product status lists of a CSAF vulnerability to OpenVEX statuses
+var csafStatuses = []struct {
+	list   string
+	status string
+}{
+	{"known_not_affected", StatusNotAffected},
+	{"known_affected", StatusAffected},
+	{"fixed", StatusFixed},
+	{"first_fixed", StatusFixed},
+	{"under_investigation", StatusUnderInvestigation},
+}
+
+type csafDocument struct {
+	Document struct {
+		Publisher struct {
+			Name string `json:"name"`
+		} `json:"publisher"`
+		Tracking struct {
+			ID string `json:"id"`
+		} `json:"tracking"`
+	} `json:"document"`
+	ProductTree     csafBranch          `json:"product_tree"`
+	Vulnerabilities []csafVulnerability `json:"vulnerabilities"`
+}
+
+type csafBranch struct {
+	Branches         []csafBranch       `json:"branches"`
+	Product          *csafProduct       `json:"product"`
+	FullProductNames []csafProduct      `json:"full_product_names"`
+	Relationships    []csafRelationship `json:"relationships"`
+}
+
+type csafProduct struct {
+	ID                   string `json:"product_id"`
+	Name                 string `json:"name"`
+	IdentificationHelper struct {
+		Purl string `json:"purl"`
+	} `json:"product_identification_helper"`
+}
+
+type csafRelationship struct {
+	FullProductName csafProduct `json:"full_product_name"`
+}
+
+type csafVulnerability struct {
+	CVE string `json:"cve"`
+	IDs []struct {
+		Text string `json:"text"`
+	} `json:"ids"`
+	ProductStatus map[string][]string `json:"product_status"`
+	Flags         []csafProductNote   `json:"flags"`
+	Threats       []csafProductNote   `json:"threats"`
+	Remediations  []csafProductNote   `json:"remediations"`
+}
+
+// csafProductNote is a flag, threat, or remediation, which all apply a label or text to a set of products
+type csafProductNote struct {
+	Label      string   `json:"label"`
+	Category   string   `json:"category"`
+	Details    string   `json:"details"`
+	ProductIDs []string `json:"product_ids"`
+}
+
+func parseCSAF(data []byte) (Document, error) {
+	doc := csafDocument{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return Document{}, err
+	}
+
+	names := make(map[string]string)
+	doc.ProductTree.collect(names)
+	result := Document{
+		Format:     FormatCSAF,
+		ID:         doc.Document.Tracking.ID,
+		Author:     doc.Document.Publisher.Name,
+		Statements: make([]Statement, 0),
+	}
+
+	for _, vuln := range doc.Vulnerabilities {
+		name, aliases := vuln.CVE, []string{}
+		for _, id := range vuln.IDs {
+			if name == "" {
+				name = id.Text
+			} else if id.Text != name {
+				aliases = append(aliases, id.Text)
+			}
+		}
+
+		for _, s := range csafStatuses {
+			// a statement is made for each justification or action so products that differ aren't merged
+			grouped := make(map[[3]string][]string)
+			keys := make([][3]string, 0)
+			for _, id := range vuln.ProductStatus[s.list] {
+				key := [3]string{}
+				switch s.status {
+				case StatusNotAffected:
+					key[0] = noteFor(vuln.Flags, id, func(n csafProductNote) string { return n.Label })
+					key[1] = noteFor(vuln.Threats, id, func(n csafProductNote) string {
+						if n.Category == "impact" {
+							return n.Details
+						}
+
+						return ""
+					})
+				case StatusAffected:
+					key[2] = noteFor(vuln.Remediations, id, func(n csafProductNote) string { return n.Details })
+				}
+
+				if _, ok := grouped[key]; !ok {
+					keys = append(keys, key)
+				}
+
+				product := id
+				if name, ok := names[id]; ok {
+					product = name
+				}
+
+				grouped[key] = append(grouped[key], product)
+			}
+
+			for _, key := range keys {
+				products := grouped[key]
+				sort.Strings(products)
+				statement := Statement{
+					Vulnerability:   name,
+					Products:        products,
+					Status:          s.status,
+					Justification:   key[0],
+					ImpactStatement: key[1],
+					ActionStatement: key[2],
+				}
+
+				if len(aliases) > 0 {
+					statement.Aliases = aliases
+				}
+
+				result.Statements = append(result.Statements, statement)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// collect maps the ID of every product in the tree to its purl, or its name if it has no purl
+func (b csafBranch) collect(names map[string]string) {
+	products := append([]csafProduct{}, b.FullProductNames...)
+	if b.Product != nil {
+		products = append(products, *b.Product)
+	}
+
+	for _, relationship := range b.Relationships {
+		products = append(products, relationship.FullProductName)
+	}
+
+	for _, product := range products {
+		if product.ID == "" {
+			continue
+		}
+
+		if product.IdentificationHelper.Purl != "" {
+			names[product.ID] = product.IdentificationHelper.Purl
+		} else if product.Name != "" {
+			names[product.ID] = product.Name
+		}
+	}
+
+	for _, branch := range b.Branches {
+		branch.collect(names)
+	}
+}
+
+// noteFor returns the value of the first note that applies to product
+func noteFor(notes []csafProductNote, product string, value func(csafProductNote) string) string {
+	for _, note := range notes {
+		for _, id := range note.ProductIDs {
+			if id == product {
+				if v := value(note); v != "" {
+					return v
+				}
+			}
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,124 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vex
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const openVEXContextPrefix = "https://openvex.dev/ns"
+
+func isOpenVEXContext(context string) bool {
+	return strings.HasPrefix(context, openVEXContextPrefix)
+}
+
+type openVEXDocument struct {
+	ID         string             `json:"@id"`
+	Author     string             `json:"author"`
+	Statements []openVEXStatement `json:"statements"`
+}
+
+// openVEXStatement accepts both the v0.0 format, where vulnerabilities and products are strings, and the v0.2
+// format, where they're objects
+type openVEXStatement struct {
+	Vulnerability   json.RawMessage   `json:"vulnerability"`
+	Products        []json.RawMessage `json:"products"`
+	Status          string            `json:"status"`
+	Justification   string            `json:"justification"`
+	ImpactStatement string            `json:"impact_statement"`
+	ActionStatement string            `json:"action_statement"`
+}
+
+type openVEXVulnerability struct {
+	ID      string   `json:"@id"`
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases"`
+}
+
+type openVEXProduct struct {
+	ID          string `json:"@id"`
+	Identifiers struct {
+		Purl string `json:"purl"`
+	} `json:"identifiers"`
+}
+
+func parseOpenVEX(data []byte) (Document, error) {
+	doc := openVEXDocument{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return Document{}, err
+	}
+
+	result := Document{
+		Format:     FormatOpenVEX,
+		ID:         doc.ID,
+		Author:     doc.Author,
+		Statements: make([]Statement, 0, len(doc.Statements)),
+	}
+
+	for i, s := range doc.Statements {
+		statement := Statement{
+			Products:        make([]string, 0, len(s.Products)),
+			Status:          s.Status,
+			Justification:   s.Justification,
+			ImpactStatement: s.ImpactStatement,
+			ActionStatement: s.ActionStatement,
+		}
+
+		vuln := openVEXVulnerability{}
+		if err := json.Unmarshal(s.Vulnerability, &statement.Vulnerability); err != nil {
+			if err := json.Unmarshal(s.Vulnerability, &vuln); err != nil {
+				return Document{}, fmt.Errorf("statement %d has an invalid vulnerability: %w", i, err)
+			}
+
+			statement.Vulnerability, statement.Aliases = vuln.Name, vuln.Aliases
+			if statement.Vulnerability == "" {
+				statement.Vulnerability = vuln.ID
+			}
+		}
+
+		for _, p := range s.Products {
+			product := ""
+			if err := json.Unmarshal(p, &product); err != nil {
+				parsed := openVEXProduct{}
+				if err := json.Unmarshal(p, &parsed); err != nil {
+					return Document{}, fmt.Errorf("statement %d has an invalid product: %w", i, err)
+				}
+
+				product = parsed.ID
+				if product == "" {
+					product = parsed.Identifiers.Purl
+				}
+			}
+
+			statement.Products = append(statement.Products, product)
+		}
+
+		if statement.Vulnerability == "" {
+			return Document{}, fmt.Errorf("statement %d has no vulnerability", i)
+		}
+
+		switch statement.Status {
+		case StatusNotAffected, StatusAffected, StatusFixed, StatusUnderInvestigation:
+		default:
+			return Document{}, fmt.Errorf("statement %d has unknown status %q", i, statement.Status)
+		}
+
+		result.Statements = append(result.Statements, statement)
+	}
+
+	return result, nil
+}
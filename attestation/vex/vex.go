@@ -0,0 +1,221 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vex
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/log"
+)
+
+const (
+	Name    = "vex"
+	Type    = "https://witness.dev/attestations/vex/v0.1"
+	RunType = attestation.PostRunType
+
+	// FormatOpenVEX is an OpenVEX document
+	FormatOpenVEX = "openvex"
+	// FormatCSAF is a CSAF document with the csaf_vex profile
+	FormatCSAF = "csaf"
+
+	StatusNotAffected        = "not_affected"
+	StatusAffected           = "affected"
+	StatusFixed              = "fixed"
+	StatusUnderInvestigation = "under_investigation"
+)
+
+var (
+	_ attestation.Attestor  = &Attestor{}
+	_ attestation.Subjecter = &Attestor{}
+)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+// Statement is the status of a vulnerability in a set of products. Statements from every format are normalized to
+// the OpenVEX statuses and justifications so policies don't need to know which format a document used.
+type Statement struct {
+	Vulnerability   string   `json:"vulnerability"`
+	Aliases         []string `json:"aliases,omitempty"`
+	Products        []string `json:"products"`
+	Status          string   `json:"status"`
+	Justification   string   `json:"justification,omitempty"`
+	ImpactStatement string   `json:"impactstatement,omitempty"`
+	ActionStatement string   `json:"actionstatement,omitempty"`
+}
+
+// Document is a VEX document the command wrote or that was passed to the attestor
+type Document struct {
+	File       string               `json:"file"`
+	Format     string               `json:"format"`
+	ID         string               `json:"id,omitempty"`
+	Author     string               `json:"author,omitempty"`
+	Digest     cryptoutil.DigestSet `json:"digest"`
+	Statements []Statement          `json:"statements"`
+}
+
+type Option func(*Attestor)
+
+// WithDocuments records the VEX documents at paths, relative to the working directory, in addition to VEX
+// documents among the command's products
+func WithDocuments(paths []string) Option {
+	return func(a *Attestor) {
+		a.paths = paths
+	}
+}
+
+// Attestor records the statements in OpenVEX and CSAF VEX documents, so policies can accept vulnerabilities the
+// product's authors have stated don't affect it.
+type Attestor struct {
+	Documents []Document `json:"documents"`
+
+	paths []string
+}
+
+func New(opts ...Option) *Attestor {
+	a := &Attestor{
+		Documents: make([]Document, 0),
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	seen := make(map[string]bool)
+	for _, path := range a.paths {
+		file := filepath.Clean(path)
+		digest, err := cryptoutil.CalculateDigestSetFromFile(filepath.Join(ctx.WorkingDir(), file), hashes(ctx))
+		if err != nil {
+			return fmt.Errorf("failed to calculate digest of %v: %w", path, err)
+		}
+
+		doc, ok, err := readDocument(filepath.Join(ctx.WorkingDir(), file))
+		if err != nil {
+			return fmt.Errorf("failed to read vex document %v: %w", path, err)
+		} else if !ok {
+			return fmt.Errorf("%v is not an OpenVEX or CSAF VEX document", path)
+		}
+
+		doc.File, doc.Digest = file, digest
+		a.Documents = append(a.Documents, doc)
+		seen[file] = true
+	}
+
+	products := ctx.Products()
+	files := make([]string, 0, len(products))
+	for file := range products {
+		files = append(files, file)
+	}
+
+	sort.Strings(files)
+	for _, file := range files {
+		if seen[filepath.Clean(file)] {
+			continue
+		}
+
+		doc, ok, err := readDocument(filepath.Join(ctx.WorkingDir(), file))
+		if err != nil {
+			log.Debugf("(attestation/vex) skipping %v: %v", file, err)
+			continue
+		}
+
+		if ok {
+			doc.File, doc.Digest = file, products[file].Digest
+			a.Documents = append(a.Documents, doc)
+		}
+	}
+
+	return nil
+}
+
+// Subjects names each VEX document so the collection can be found from the document published with the product
+func (a *Attestor) Subjects() map[string]cryptoutil.DigestSet {
+	subjects := make(map[string]cryptoutil.DigestSet)
+	for _, doc := range a.Documents {
+		subjects[fmt.Sprintf("vex:%v", doc.File)] = doc.Digest
+	}
+
+	return subjects
+}
+
+// readDocument reads an OpenVEX or CSAF VEX document, and returns false if path is neither
+func readDocument(path string) (Document, bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Document{}, false, err
+	}
+
+	// VEX documents are JSON objects, so other files can be skipped without parsing them
+	if trimmed := bytes.TrimSpace(data); len(trimmed) == 0 || trimmed[0] != '{' {
+		return Document{}, false, nil
+	}
+
+	probe := struct {
+		Context  string `json:"@context"`
+		Document struct {
+			Category string `json:"category"`
+		} `json:"document"`
+	}{}
+
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return Document{}, false, nil
+	}
+
+	switch {
+	case isOpenVEXContext(probe.Context):
+		doc, err := parseOpenVEX(data)
+		return doc, err == nil, err
+	case probe.Document.Category == csafVEXCategory:
+		doc, err := parseCSAF(data)
+		return doc, err == nil, err
+	default:
+		return Document{}, false, nil
+	}
+}
+
+func hashes(ctx *attestation.AttestationContext) []crypto.Hash {
+	if hashes := ctx.Hashes(); len(hashes) > 0 {
+		return hashes
+	}
+
+	return []crypto.Hash{crypto.SHA256}
+}
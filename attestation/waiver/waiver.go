@@ -0,0 +1,144 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package waiver
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+)
+
+const (
+	Name    = "waiver"
+	Type    = "https://witness.dev/attestations/waiver/v0.1"
+	RunType = attestation.PreRunType
+
+	// CollectionName is the name of the collections witness waive records, which witness verify searches for
+	// when a policy trusts waivers.
+	CollectionName = "waiver"
+)
+
+var (
+	_ attestation.Attestor  = &Attestor{}
+	_ attestation.Subjecter = &Attestor{}
+)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+type Option func(*Attestor)
+
+// WithStep sets the policy step the waiver exempts.
+func WithStep(step string) Option {
+	return func(a *Attestor) {
+		a.Step = step
+	}
+}
+
+// WithAttestation narrows the waiver to one attestation type required by the step, instead of the whole step.
+func WithAttestation(attestationType string) Option {
+	return func(a *Attestor) {
+		a.Attestation = attestationType
+	}
+}
+
+// WithReason sets why the constraint is waived.
+func WithReason(reason string) Option {
+	return func(a *Attestor) {
+		a.Reason = reason
+	}
+}
+
+// WithExpires sets when the waiver stops applying.
+func WithExpires(expires time.Time) Option {
+	return func(a *Attestor) {
+		a.Expires = expires
+	}
+}
+
+// WithSubjects sets the artifacts the waiver applies to.
+func WithSubjects(subjects map[string]cryptoutil.DigestSet) Option {
+	return func(a *Attestor) {
+		for name, digest := range subjects {
+			a.Waived[name] = digest
+		}
+	}
+}
+
+// Attestor records a temporary exemption from a policy constraint for specific artifacts. A waiver exempts a
+// step of the policy, or only one of the attestations the step requires, until it expires.
+type Attestor struct {
+	Step        string                          `json:"step"`
+	Attestation string                          `json:"attestation,omitempty"`
+	Reason      string                          `json:"reason"`
+	IssuedAt    time.Time                       `json:"issuedat"`
+	Expires     time.Time                       `json:"expires"`
+	Waived      map[string]cryptoutil.DigestSet `json:"waived"`
+}
+
+func New(opts ...Option) *Attestor {
+	a := &Attestor{
+		Waived: make(map[string]cryptoutil.DigestSet),
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	if len(a.Waived) == 0 {
+		return fmt.Errorf("no subjects to waive, waivers are recorded with witness waive")
+	}
+
+	if a.Step == "" {
+		return fmt.Errorf("a waiver requires the step it exempts")
+	}
+
+	if a.Reason == "" {
+		return fmt.Errorf("a waiver requires a reason")
+	}
+
+	a.IssuedAt = time.Now().UTC()
+	if !a.Expires.After(a.IssuedAt) {
+		return fmt.Errorf("waiver expiry %v is not in the future", a.Expires.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// Subjects returns the waived artifacts, so the waiver can be found by their digests.
+func (a *Attestor) Subjects() map[string]cryptoutil.DigestSet {
+	return a.Waived
+}
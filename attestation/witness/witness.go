@@ -0,0 +1,130 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package witness
+
+import (
+	"fmt"
+	"os"
+	"runtime/debug"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+)
+
+const (
+	Name    = "witness"
+	Type    = "https://witness.dev/attestations/witness/v0.1"
+	RunType = attestation.PreRunType
+)
+
+var (
+	_ attestation.Attestor = &Attestor{}
+)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+type Option func(*Attestor)
+
+// WithVersion sets the version of witness that is recorded. The version from the binary's build info is used otherwise.
+func WithVersion(version string) Option {
+	return func(a *Attestor) {
+		a.Version = version
+	}
+}
+
+type Module struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+	Sum     string `json:"sum,omitempty"`
+}
+
+// Attestor records information about the witness binary that produced the attestation collection,
+// so policies can require evidence be produced by a known version of witness.
+type Attestor struct {
+	Version      string               `json:"version"`
+	Executable   string               `json:"executable"`
+	Digest       cryptoutil.DigestSet `json:"digest"`
+	GoVersion    string               `json:"goversion,omitempty"`
+	MainModule   Module               `json:"mainmodule,omitempty"`
+	Dependencies []Module             `json:"dependencies,omitempty"`
+	Settings     map[string]string    `json:"settings,omitempty"`
+}
+
+func New(opts ...Option) *Attestor {
+	a := &Attestor{}
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find witness executable: %w", err)
+	}
+
+	a.Executable = executable
+	a.Digest, err = cryptoutil.CalculateDigestSetFromFile(executable, ctx.Hashes())
+	if err != nil {
+		return fmt.Errorf("failed to calculate digest of witness executable: %w", err)
+	}
+
+	buildInfo, ok := debug.ReadBuildInfo()
+	if !ok {
+		return nil
+	}
+
+	a.GoVersion = buildInfo.GoVersion
+	a.MainModule = Module{Path: buildInfo.Main.Path, Version: buildInfo.Main.Version, Sum: buildInfo.Main.Sum}
+	if a.Version == "" {
+		a.Version = buildInfo.Main.Version
+	}
+
+	for _, dep := range buildInfo.Deps {
+		// replaced modules are recorded as the module that was actually built
+		if dep.Replace != nil {
+			dep = dep.Replace
+		}
+
+		a.Dependencies = append(a.Dependencies, Module{Path: dep.Path, Version: dep.Version, Sum: dep.Sum})
+	}
+
+	if len(buildInfo.Settings) > 0 {
+		a.Settings = make(map[string]string)
+		for _, setting := range buildInfo.Settings {
+			a.Settings[setting.Key] = setting.Value
+		}
+	}
+
+	return nil
+}
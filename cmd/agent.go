@@ -0,0 +1,286 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/agent"
+	"github.com/testifysec/witness/pkg/runner"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+func AgentCmd() *cobra.Command {
+	ao := options.AgentOptions{}
+	cmd := &cobra.Command{
+		Use:   "agent",
+		Short: "Runs commands dispatched by an orchestrator and streams their signed attestations back",
+		Long: "Serves a gRPC service that an orchestrator dispatches runs to, for build systems that can't wrap commands " +
+			"in witness run. Each run is recorded and signed like witness run, with the run flags given to the agent, then " +
+			"the signed envelope is streamed back. Runs are executed concurrently, each with its own attestors and options",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAgent(cmd.Context(), ao)
+		},
+		Args: cobra.NoArgs,
+	}
+
+	ao.AddFlags(cmd)
+	cmd.AddCommand(AgentDispatchCmd())
+	return cmd
+}
+
+func AgentDispatchCmd() *cobra.Command {
+	o := options.AgentDispatchOptions{}
+	cmd := &cobra.Command{
+		Use:               "dispatch [cmd]",
+		Short:             "Runs the provided command on a witness agent and writes the signed envelope it returns",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAgentDispatch(cmd.Context(), o, args)
+		},
+		Args: cobra.MinimumNArgs(1),
+	}
+
+	o.AddFlags(cmd)
+	return cmd
+}
+
+func runAgent(ctx context.Context, ao options.AgentOptions) error {
+	serverOpts, err := agentServerOptions(ao.TLSOptions)
+	if err != nil {
+		return err
+	}
+
+	lis, err := net.Listen("tcp", ao.Listen)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %v: %w", ao.Listen, err)
+	}
+
+	log.Infof("Agent listening on %v", lis.Addr())
+	return serveAgent(ctx, ao, lis, serverOpts...)
+}
+
+// serveAgent serves runs on lis until ctx is done, then waits for runs in progress to finish
+func serveAgent(ctx context.Context, ao options.AgentOptions, lis net.Listener, serverOpts ...grpc.ServerOption) error {
+	if ao.RunOptions.OutFilePath != "" {
+		return fmt.Errorf("--outfile cannot be used with witness agent, envelopes are streamed back to the orchestrator")
+	}
+
+	s := grpc.NewServer(serverOpts...)
+	agent.Register(s, agentRun(ao.RunOptions))
+	go func() {
+		<-ctx.Done()
+		s.GracefulStop()
+	}()
+
+	return s.Serve(lis)
+}
+
+// agentRun runs each request with the agent's run options
+func agentRun(base options.RunOptions) agent.RunFunc {
+	return func(ctx context.Context, req agent.RunRequest, send func(agent.RunResponse) error) error {
+		ro, err := agentRunOptions(base, req)
+		if err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+
+		if err := send(agent.RunResponse{State: agent.StateStarted}); err != nil {
+			return err
+		}
+
+		log.Infof("Running step %v: %v", ro.StepName, strings.Join(req.Args, " "))
+		result, err := runner.Run(ctx, ro, req.Args, runnerOptions()...)
+		if err != nil {
+			log.Errorf("step %v failed: %v", ro.StepName, err)
+			return status.Error(codes.Aborted, err.Error())
+		}
+
+		return send(agent.RunResponse{State: agent.StateCompleted, Envelope: &result.SignedEnvelope, Storage: result.Storage})
+	}
+}
+
+// agentRunOptions applies req to the agent's run options. The request's working directory must be inside the
+// agent's, so an orchestrator can only run commands where the agent was told to.
+func agentRunOptions(base options.RunOptions, req agent.RunRequest) (options.RunOptions, error) {
+	ro := base
+	if req.Step != "" {
+		ro.StepName = req.Step
+	}
+
+	if ro.StepName == "" {
+		return ro, fmt.Errorf("step name is required")
+	}
+
+	if len(req.Args) == 0 {
+		return ro, fmt.Errorf("a command is required")
+	}
+
+	root, err := filepath.Abs(base.WorkingDir)
+	if err != nil {
+		return ro, fmt.Errorf("failed to resolve the agent's working directory: %w", err)
+	}
+
+	ro.WorkingDir = filepath.Join(root, req.WorkingDir)
+	if rel, err := filepath.Rel(root, ro.WorkingDir); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ro, fmt.Errorf("working directory %v is outside the agent's working directory", req.WorkingDir)
+	}
+
+	ro.Attestations = append(append([]string{}, base.Attestations...), req.Attestations...)
+	ro.Env = append(append([]string{}, base.Env...), req.Env...)
+	ro.Annotations = append(append([]string{}, base.Annotations...), req.Annotations...)
+	ro.Tracing = base.Tracing || req.Tracing
+	return ro, nil
+}
+
+func runAgentDispatch(ctx context.Context, o options.AgentDispatchOptions, args []string) error {
+	creds, err := agentClientCredentials(o.TLSOptions)
+	if err != nil {
+		return err
+	}
+
+	conn, err := grpc.DialContext(ctx, o.Agent, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return fmt.Errorf("failed to connect to agent %v: %w", o.Agent, err)
+	}
+
+	defer conn.Close()
+	req := agent.RunRequest{
+		Step:         o.StepName,
+		Args:         args,
+		WorkingDir:   o.WorkingDir,
+		Attestations: o.Attestations,
+		Env:          o.Env,
+		Annotations:  o.Annotations,
+		Tracing:      o.Tracing,
+	}
+
+	var env *dsse.Envelope
+	err = agent.NewClient(conn).Run(ctx, req, func(resp agent.RunResponse) error {
+		switch resp.State {
+		case agent.StateStarted:
+			log.Infof("Agent %v started the run", o.Agent)
+		case agent.StateCompleted:
+			env = resp.Envelope
+			for _, storage := range resp.Storage {
+				log.Infof("Agent stored the envelope in %v", storage)
+			}
+		}
+
+		return nil
+	})
+
+	if s, ok := status.FromError(err); ok && err != nil {
+		return fmt.Errorf("agent run failed: %v", s.Message())
+	} else if err != nil {
+		return err
+	}
+
+	if env == nil {
+		return fmt.Errorf("agent %v didn't send a signed envelope", o.Agent)
+	}
+
+	return writeEnvelope(o.OutFilePath, *env)
+}
+
+func agentServerOptions(o options.AgentTLSOptions) ([]grpc.ServerOption, error) {
+	if o.Insecure {
+		log.Warnf("serving without TLS, anyone who can reach the agent can run commands")
+		return nil, nil
+	}
+
+	if o.CertPath == "" || o.KeyPath == "" {
+		return nil, fmt.Errorf("--tls-cert and --tls-key are required unless --insecure is set")
+	}
+
+	cert, err := tls.LoadX509KeyPair(o.CertPath, o.KeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tls certificate: %w", err)
+	}
+
+	config := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if o.CAPath != "" {
+		if config.ClientCAs, err = loadCertPool(o.CAPath); err != nil {
+			return nil, err
+		}
+
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	} else if o.NoClientAuth {
+		log.Warnf("serving without client authentication, anyone who can reach the agent can run commands")
+	} else {
+		return nil, fmt.Errorf("--tls-client-ca is required unless --tls-no-client-auth is set")
+	}
+
+	return []grpc.ServerOption{grpc.Creds(credentials.NewTLS(config))}, nil
+}
+
+func agentClientCredentials(o options.AgentTLSOptions) (credentials.TransportCredentials, error) {
+	if o.Insecure {
+		return insecure.NewCredentials(), nil
+	}
+
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if o.CertPath != "" || o.KeyPath != "" {
+		cert, err := tls.LoadX509KeyPair(o.CertPath, o.KeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load tls certificate: %w", err)
+		}
+
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	if o.CAPath != "" {
+		var err error
+		if config.RootCAs, err = loadCertPool(o.CAPath); err != nil {
+			return nil, err
+		}
+	}
+
+	return credentials.NewTLS(config), nil
+}
+
+// loadCertPool reads the PEM encoded certificates in path
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pemBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ca certificates: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pemBytes) {
+		return nil, fmt.Errorf("no certificates found in %v", path)
+	}
+
+	return pool, nil
+}
@@ -0,0 +1,119 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+	"github.com/testifysec/witness/options"
+)
+
+func TestAgent(t *testing.T) {
+	priv, _ := rsakeypair(t)
+	workingDir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(workingDir, "app"), 0755))
+	ao := options.AgentOptions{
+		RunOptions: options.RunOptions{
+			KeyOptions:   options.KeyOptions{KeyPath: priv.Name()},
+			WorkingDir:   workingDir,
+			Attestations: []string{},
+			OutFilePath:  "out.json",
+		},
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	require.ErrorContains(t, serveAgent(ctx, ao, lis), "--outfile cannot be used")
+
+	ao.RunOptions.OutFilePath = ""
+	lis, err = net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	served := make(chan error)
+	go func() {
+		served <- serveAgent(ctx, ao, lis)
+	}()
+
+	attestationPath := filepath.Join(t.TempDir(), "outfile.json")
+	o := options.AgentDispatchOptions{
+		Agent:       lis.Addr().String(),
+		TLSOptions:  options.AgentTLSOptions{Insecure: true},
+		StepName:    "build",
+		WorkingDir:  "app",
+		Annotations: []string{"team=platform"},
+		OutFilePath: attestationPath,
+	}
+
+	require.NoError(t, runAgentDispatch(context.Background(), o, []string{"sh", "-c", "echo built > out.txt"}))
+	out, err := os.ReadFile(filepath.Join(workingDir, "app", "out.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "built\n", string(out))
+
+	attestationBytes, err := os.ReadFile(attestationPath)
+	require.NoError(t, err)
+	env := dsse.Envelope{}
+	require.NoError(t, json.Unmarshal(attestationBytes, &env))
+	require.Len(t, env.Signatures, 1)
+	stmt := intoto.Statement{}
+	require.NoError(t, json.Unmarshal(env.Payload, &stmt))
+	collection := attestation.Collection{}
+	require.NoError(t, json.Unmarshal(stmt.Predicate, &collection))
+	assert.Equal(t, "build", collection.Name)
+	var subjects []string
+	for _, subject := range stmt.Subject {
+		subjects = append(subjects, subject.Name)
+	}
+
+	assert.Contains(t, subjects, "https://witness.dev/attestations/annotations/v0.1/annotation:team")
+	assert.Contains(t, subjects, "https://witness.dev/attestations/product/v0.1/file:out.txt")
+
+	o.WorkingDir = "../escape"
+	assert.ErrorContains(t, runAgentDispatch(context.Background(), o, []string{"true"}), "outside the agent's working directory")
+	o.WorkingDir, o.StepName = "app", ""
+	assert.ErrorContains(t, runAgentDispatch(context.Background(), o, []string{"true"}), "step name is required")
+	o.StepName = "build"
+	assert.ErrorContains(t, runAgentDispatch(context.Background(), o, []string{"false"}), "agent run failed")
+
+	cancel()
+	require.NoError(t, <-served)
+}
+
+func TestAgentServerOptions(t *testing.T) {
+	caPem, _, leafPem, leafKeyPem := fullChain(t)
+	o := options.AgentTLSOptions{CertPath: leafPem.Name(), KeyPath: leafKeyPem.Name()}
+	_, err := agentServerOptions(o)
+	assert.ErrorContains(t, err, "--tls-client-ca is required")
+
+	o.NoClientAuth = true
+	serverOpts, err := agentServerOptions(o)
+	require.NoError(t, err)
+	assert.Len(t, serverOpts, 1)
+
+	o.NoClientAuth = false
+	o.CAPath = caPem.Name()
+	serverOpts, err = agentServerOptions(o)
+	require.NoError(t, err)
+	assert.Len(t, serverOpts, 1)
+}
@@ -0,0 +1,162 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/attestation/approval"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/runner"
+	"github.com/testifysec/witness/pkg/spool"
+)
+
+const approveLong = `Records a signed approval of one or more artifacts, such as a release manager signing off on a build.
+The approval is an attestation collection named approval whose subjects are the approved digests, signed with the
+approver's key or identity. Policies that declare approval groups require a number of distinct functionaries from each
+group to have approved an artifact before witness verify accepts it.`
+
+func ApproveCmd() *cobra.Command {
+	ao := options.ApproveOptions{}
+	cmd := &cobra.Command{
+		Use:               "approve",
+		Short:             "Records a signed approval of an artifact",
+		Long:              approveLong,
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runApprove(cmd.Context(), ao)
+		},
+	}
+
+	ao.AddFlags(cmd)
+	return cmd
+}
+
+func runApprove(ctx context.Context, ao options.ApproveOptions) error {
+	if len(ao.Subjects) == 0 {
+		return fmt.Errorf("must supply at least one subject to approve")
+	}
+
+	if ao.Reason == "" {
+		return fmt.Errorf("must supply a reason for the approval")
+	}
+
+	subjects, err := parseSubjects(ao.Subjects)
+	if err != nil {
+		return err
+	}
+
+	attestor := approval.New(approval.WithReason(ao.Reason), approval.WithSubjects(subjects))
+	env, err := signAttestor(ctx, ao.KeyOptions, ao.TimestampServers, approval.CollectionName, attestor)
+	if err != nil {
+		return fmt.Errorf("failed to record approval: %w", err)
+	}
+
+	return publishEnvelope(ctx, ao.OutFilePath, ao.ArchivistOptions, env)
+}
+
+// parseSubjects parses digests such as sha256:abc123, naming each subject as it was given
+func parseSubjects(digests []string) (map[string]cryptoutil.DigestSet, error) {
+	subjects := make(map[string]cryptoutil.DigestSet)
+	for _, digest := range digests {
+		digestSet, err := parseSubjectDigest(digest)
+		if err != nil {
+			return nil, err
+		}
+
+		subjects[digest] = digestSet
+	}
+
+	return subjects, nil
+}
+
+// signAttestor runs a single attestor outside of witness run and signs a collection with the given name holding
+// its attestation
+func signAttestor(ctx context.Context, ko options.KeyOptions, timestampServers []string, collectionName string, attestor attestation.Attestor) (dsse.Envelope, error) {
+	signers, err := loadStatementSigners(ctx, ko)
+	if err != nil {
+		return dsse.Envelope{}, err
+	}
+
+	attestationCtx, err := attestation.NewContext([]attestation.Attestor{attestor})
+	if err != nil {
+		return dsse.Envelope{}, fmt.Errorf("failed to create attestation context: %w", err)
+	}
+
+	if err := attestationCtx.RunAttestors(); err != nil {
+		return dsse.Envelope{}, err
+	}
+
+	collection := attestation.NewCollection(collectionName, []attestation.Attestor{attestor})
+	subjects, err := sortedSubjects(collection.Subjects())
+	if err != nil {
+		return dsse.Envelope{}, err
+	}
+
+	return signStatement(subjects, attestation.CollectionType, collection, signers, timestampServers)
+}
+
+// publishEnvelope writes env to outFilePath, or stdout if it's empty, and stores it in every Archivist server if enabled
+func publishEnvelope(ctx context.Context, outFilePath string, ao options.ArchivistOptions, env dsse.Envelope) error {
+	if err := writeEnvelope(outFilePath, env); err != nil {
+		return err
+	}
+
+	if !ao.Enable {
+		return nil
+	}
+
+	for _, server := range ao.Urls {
+		gitoid, err := runner.StoreEnvelope(ctx, spool.BackendArchivist, server, "", env)
+		if err != nil {
+			return fmt.Errorf("failed to store envelope in archivist %v: %w", server, err)
+		}
+
+		log.Infof("Stored envelope in archivist %v with gitoid %v", server, gitoid)
+	}
+
+	return nil
+}
+
+// sortedSubjects converts subjects to in-toto subjects, sorted by name so the statement is stable
+func sortedSubjects(subjects map[string]cryptoutil.DigestSet) ([]intoto.Subject, error) {
+	names := make([]string, 0, len(subjects))
+	for name := range subjects {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	result := make([]intoto.Subject, 0, len(names))
+	for _, name := range names {
+		subject, err := intoto.DigestSetToSubject(name, subjects[name])
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, subject)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,98 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/approvals"
+)
+
+// newApprovalFixture returns a verify fixture whose policy requires two approvals from the approvers, and the paths
+// of the approvers' private keys
+func newApprovalFixture(t *testing.T) (verifyFixture, []string) {
+	approverPaths := []string{}
+	f := newVerifyFixtureWithPolicy(t, func(p map[string]interface{}) {
+		group := approvals.Group{Required: 2}
+		for _, name := range []string{"alice", "bob", "carol"} {
+			privPath, functionary := addPolicyKey(t, p, name)
+			group.Functionaries = append(group.Functionaries, functionary)
+			approverPaths = append(approverPaths, privPath)
+		}
+
+		p["approvals"] = map[string]approvals.Group{"release-managers": group}
+	})
+
+	return f, approverPaths
+}
+
+func TestApprove(t *testing.T) {
+	f, approvers := newApprovalFixture(t)
+	outsider, _ := rsakeypair(t)
+	step1 := f.run(t, "step01", f.funcPrivPath, "echo 'test01' > test.txt")
+	step1Digest, err := cryptoutil.CalculateDigestSetFromFile(f.artifactPath, []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+	step2 := f.run(t, "step02", f.funcPrivPath, "echo 'test02' >> test.txt")
+	artifactDigest, err := cryptoutil.CalculateDigestSetFromFile(f.artifactPath, []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+
+	approve := func(keyPath string) string {
+		outPath := filepath.Join(t.TempDir(), "approval.json")
+		require.NoError(t, runApprove(context.Background(), options.ApproveOptions{
+			KeyOptions:  options.KeyOptions{KeyPath: keyPath},
+			Subjects:    []string{"sha256:" + artifactDigest[crypto.SHA256]},
+			Reason:      "CHANGE-1234 reviewed",
+			OutFilePath: outPath,
+		}))
+
+		return outPath
+	}
+
+	verify := func(attestations ...string) error {
+		vo := f.verifyOptions(f.policyPubPath, append([]string{step1, step2}, attestations...)...)
+		vo.AdditionalSubjects = []string{step1Digest[crypto.SHA256]}
+		return runVerify(context.Background(), vo)
+	}
+
+	err = verify()
+	require.Error(t, err)
+	assert.Equal(t, ExitCodePolicy, ExitCode(err))
+	assert.Contains(t, err.Error(), "requires 2 approvals, found 0")
+
+	alice := approve(approvers[0])
+	err = verify(alice, approve(approvers[0]), approve(outsider.Name()))
+	require.Error(t, err)
+	assert.Equal(t, ExitCodePolicy, ExitCode(err))
+	assert.Contains(t, err.Error(), "requires 2 approvals, found 1")
+
+	require.NoError(t, verify(alice, approve(approvers[2])))
+}
+
+func TestApproveRequiresReason(t *testing.T) {
+	priv, _ := rsakeypair(t)
+	err := runApprove(context.Background(), options.ApproveOptions{
+		KeyOptions: options.KeyOptions{KeyPath: priv.Name()},
+		Subjects:   []string{"sha256:abc123"},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "reason")
+}
@@ -0,0 +1,326 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"crypto"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/pkg/cache"
+	"github.com/testifysec/witness/pkg/dirhash"
+)
+
+const (
+	artifactNamespace = "artifacts"
+
+	// stdinArtifact is the artifact path that reads the artifact from stdin
+	stdinArtifact = "-"
+)
+
+// expandArtifactPaths returns the artifact paths matched by pattern, which may be a glob, along with any
+// newline delimited paths read from the file at listPath. A listPath of "-" reads the list from stdin, and a pattern
+// of "-" is the artifact streamed over stdin.
+func expandArtifactPaths(pattern, listPath string, stdin io.Reader) ([]string, error) {
+	paths := []string{}
+	if pattern == stdinArtifact {
+		if listPath == "-" {
+			return nil, fmt.Errorf("the artifact and the artifact list can't both be read from stdin")
+		}
+
+		paths = append(paths, stdinArtifact)
+	} else if pattern != "" {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid artifact pattern %v: %w", pattern, err)
+		}
+
+		// a pattern without any glob characters is a plain path, and should fail later if it doesn't exist
+		if len(matches) == 0 && !strings.ContainsAny(pattern, "*?[") {
+			matches = []string{pattern}
+		}
+
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no artifacts matched %v", pattern)
+		}
+
+		paths = append(paths, matches...)
+	}
+
+	if listPath == "" {
+		return paths, nil
+	}
+
+	listReader := stdin
+	if listPath != "-" {
+		listFile, err := os.Open(listPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open artifact list: %w", err)
+		}
+
+		defer listFile.Close()
+		listReader = listFile
+	}
+
+	scanner := bufio.NewScanner(listReader)
+	for scanner.Scan() {
+		path := strings.TrimSpace(scanner.Text())
+		if path == "" {
+			continue
+		}
+
+		paths = append(paths, path)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read artifact list: %w", err)
+	}
+
+	return paths, nil
+}
+
+// subjectEntry is a subject digest to verify, read from a subjects file
+type subjectEntry struct {
+	name   string
+	digest cryptoutil.DigestSet
+}
+
+// readSubjectsFile reads the subjects in the file at path, or stdin if path is "-". Each line is a SHA-256 digest,
+// optionally prefixed with sha256:, and an optional name such as the image or artifact it's the digest of. Blank
+// lines and lines starting with # are skipped. Subjects without a name are named by their digest.
+func readSubjectsFile(path string, stdin io.Reader) ([]subjectEntry, error) {
+	r := stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open subjects file: %w", err)
+		}
+
+		defer f.Close()
+		r = f
+	}
+
+	entries := []subjectEntry{}
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		digest := strings.ToLower(strings.TrimPrefix(fields[0], "sha256:"))
+		if decoded, err := hex.DecodeString(digest); err != nil || len(decoded) != crypto.SHA256.Size() {
+			return nil, fmt.Errorf("subjects file line %d: %q is not a sha256 digest", lineNum, fields[0])
+		}
+
+		name := "sha256:" + digest
+		if len(fields) > 1 {
+			name = strings.Join(fields[1:], " ")
+		}
+
+		entries = append(entries, subjectEntry{name: name, digest: cryptoutil.DigestSet{crypto.SHA256: digest}})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read subjects file: %w", err)
+	}
+
+	return entries, nil
+}
+
+// artifactDigestFromPath calculates the digest set of a file, or the deterministic tree hash of a directory. The
+// path "-" hashes stdin as it's streamed, so large artifacts piped to witness never have to be written to disk.
+func artifactDigestFromPath(path string, hashes []crypto.Hash) (cryptoutil.DigestSet, error) {
+	if path == stdinArtifact {
+		return cryptoutil.CalculateDigestSet(bufio.NewReaderSize(os.Stdin, 1<<20), hashes)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		return dirhash.Hash(path, hashes)
+	}
+
+	return cryptoutil.CalculateDigestSetFromFile(path, hashes)
+}
+
+// artifactDigestsFromRef downloads the artifact referred to by ref and calculates digest sets that
+// can be used as subjects during verification. For OCI references both the manifest digest and the
+// image's config digest (image id) are returned.
+func artifactDigestsFromRef(ctx context.Context, ref string, hashes []crypto.Hash) ([]cryptoutil.DigestSet, error) {
+	switch {
+	case strings.HasPrefix(ref, "oci://"):
+		return ociArtifactDigests(ctx, strings.TrimPrefix(ref, "oci://"), hashes)
+	case strings.HasPrefix(ref, "https://"), strings.HasPrefix(ref, "http://"):
+		digestSet, err := httpArtifactDigest(ctx, ref, hashes)
+		if err != nil {
+			return nil, err
+		}
+
+		return []cryptoutil.DigestSet{digestSet}, nil
+	default:
+		return nil, fmt.Errorf("unsupported artifact reference %v, expected oci:// or https://", ref)
+	}
+}
+
+// cachedArtifactDigestsFromRef is artifactDigestsFromRef, but reuses digests from c while they haven't expired.
+// Only OCI references pinned to a digest are cached, since tags and URLs can start serving other content.
+func cachedArtifactDigestsFromRef(ctx context.Context, c *cache.Cache, ref string, hashes []crypto.Hash) ([]cryptoutil.DigestSet, error) {
+	if !digestPinnedRef(ref) {
+		return artifactDigestsFromRef(ctx, ref, hashes)
+	}
+
+	key := fmt.Sprintf("%v %v", ref, hashes)
+	digestSets := []cryptoutil.DigestSet{}
+	if c.GetJSON(artifactNamespace, key, &digestSets) {
+		log.Debugf("(cache) using cached digests for %v", ref)
+		return digestSets, nil
+	}
+
+	digestSets, err := artifactDigestsFromRef(ctx, ref, hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.PutJSON(artifactNamespace, key, digestSets); err != nil {
+		log.Debugf("(cache) failed to cache digests for %v: %v", ref, err)
+	}
+
+	return digestSets, nil
+}
+
+// digestPinnedRef reports whether ref is an OCI reference to a manifest digest, whose content can't change
+func digestPinnedRef(ref string) bool {
+	if !strings.HasPrefix(ref, "oci://") {
+		return false
+	}
+
+	parsed, err := parseOCIReference(strings.TrimPrefix(ref, "oci://"))
+	return err == nil && parsed.Digest != ""
+}
+
+func httpArtifactDigest(ctx context.Context, url string, hashes []crypto.Hash) (cryptoutil.DigestSet, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download artifact: %w", err)
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download artifact: %v", resp.Status)
+	}
+
+	log.Debugf("(artifact) calculating digest of %v", url)
+	return cryptoutil.CalculateDigestSet(resp.Body, hashes)
+}
+
+func ociArtifactDigests(ctx context.Context, ref string, hashes []crypto.Hash) ([]cryptoutil.DigestSet, error) {
+	parsedRef, err := parseOCIReference(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	client := newRegistryClient(parsedRef)
+	manifestBytes, _, err := client.Manifest(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch manifest for %v: %w", parsedRef, err)
+	}
+
+	manifestDigest, err := cryptoutil.CalculateDigestSetFromBytes(manifestBytes, hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	if parsedRef.Digest != "" {
+		if err := checkOCIDigest(parsedRef.Digest, manifestDigest); err != nil {
+			return nil, fmt.Errorf("manifest for %v failed digest check: %w", parsedRef, err)
+		}
+	}
+
+	digests := []cryptoutil.DigestSet{manifestDigest}
+	manifest := struct {
+		Config struct {
+			Digest string `json:"digest"`
+		} `json:"config"`
+	}{}
+
+	if err := json.Unmarshal(manifestBytes, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest for %v: %w", parsedRef, err)
+	}
+
+	// image indexes don't have a config, so there's no image id to record
+	if manifest.Config.Digest == "" {
+		return digests, nil
+	}
+
+	blob, err := client.Blob(ctx, manifest.Config.Digest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch config for %v: %w", parsedRef, err)
+	}
+
+	defer blob.Close()
+	configDigest, err := cryptoutil.CalculateDigestSet(blob, hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkOCIDigest(manifest.Config.Digest, configDigest); err != nil {
+		return nil, fmt.Errorf("config for %v failed digest check: %w", parsedRef, err)
+	}
+
+	return append(digests, configDigest), nil
+}
+
+// checkOCIDigest compares an OCI style digest (algorithm:hex) against a calculated digest set.
+func checkOCIDigest(expected string, actual cryptoutil.DigestSet) error {
+	parts := strings.SplitN(expected, ":", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid digest %v", expected)
+	}
+
+	hash, err := cryptoutil.HashFromString(parts[0])
+	if err != nil {
+		return err
+	}
+
+	calculated, ok := actual[hash]
+	if !ok {
+		return fmt.Errorf("no %v digest was calculated", parts[0])
+	}
+
+	if calculated != parts[1] {
+		return fmt.Errorf("expected digest %v but calculated %v:%v", expected, parts[0], calculated)
+	}
+
+	return nil
+}
@@ -0,0 +1,252 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/witness/pkg/cache"
+)
+
+func TestParseOCIReference(t *testing.T) {
+	tests := []struct {
+		ref      string
+		expected ociReference
+	}{
+		{"alpine", ociReference{Registry: "docker.io", Repository: "library/alpine", Tag: "latest"}},
+		{"testifysec/witness:v1", ociReference{Registry: "docker.io", Repository: "testifysec/witness", Tag: "v1"}},
+		{"ghcr.io/testifysec/witness@sha256:abcd", ociReference{Registry: "ghcr.io", Repository: "testifysec/witness", Digest: "sha256:abcd"}},
+		{"localhost:5000/app:dev@sha256:abcd", ociReference{Registry: "localhost:5000", Repository: "app", Tag: "dev", Digest: "sha256:abcd"}},
+	}
+
+	for _, test := range tests {
+		ref, err := parseOCIReference(test.ref)
+		require.NoError(t, err)
+		assert.Equal(t, test.expected, ref)
+	}
+
+	_, err := parseOCIReference("ghcr.io/app@notadigest")
+	assert.Error(t, err)
+}
+
+func TestArtifactDigestsFromHTTPRef(t *testing.T) {
+	content := []byte("some artifact")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/artifact" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = w.Write(content)
+	}))
+	defer server.Close()
+
+	expected, err := cryptoutil.CalculateDigestSetFromBytes(content, []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+	digests, err := artifactDigestsFromRef(context.Background(), server.URL+"/artifact", []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+	require.Len(t, digests, 1)
+	assert.True(t, expected.Equal(digests[0]))
+
+	_, err = artifactDigestsFromRef(context.Background(), server.URL+"/missing", []crypto.Hash{crypto.SHA256})
+	assert.Error(t, err)
+
+	_, err = artifactDigestsFromRef(context.Background(), "ftp://example.com/artifact", []crypto.Hash{crypto.SHA256})
+	assert.Error(t, err)
+}
+
+func TestCachedArtifactDigestsFromRef(t *testing.T) {
+	manifest := []byte(fmt.Sprintf(`{"schemaVersion":2,"mediaType":"%v","layers":[]}`, ociManifestType))
+	manifestDigest := fmt.Sprintf("sha256:%x", sha256.Sum256(manifest))
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v2/":
+		case strings.HasPrefix(r.URL.Path, "/v2/app/manifests/"):
+			requests++
+			w.Header().Set("Content-Type", ociManifestType)
+			_, _ = w.Write(manifest)
+		case r.URL.Path == "/artifact":
+			requests++
+			_, _ = w.Write([]byte("some artifact"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	c, err := cache.New(t.TempDir(), time.Hour)
+	require.NoError(t, err)
+	host := strings.TrimPrefix(server.URL, "http://")
+	pinned := fmt.Sprintf("oci://%v/app@%v", host, manifestDigest)
+	first, err := cachedArtifactDigestsFromRef(context.Background(), c, pinned, []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+	second, err := cachedArtifactDigestsFromRef(context.Background(), c, pinned, []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+	assert.Equal(t, 1, requests)
+	require.Len(t, second, 1)
+	assert.True(t, first[0].Equal(second[0]))
+
+	_, err = cachedArtifactDigestsFromRef(context.Background(), nil, pinned, []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+	assert.Equal(t, 2, requests)
+
+	// tags and URLs can serve other content later, so they're always fetched
+	for _, ref := range []string{fmt.Sprintf("oci://%v/app:latest", host), server.URL + "/artifact"} {
+		requests = 0
+		for i := 0; i < 2; i++ {
+			_, err = cachedArtifactDigestsFromRef(context.Background(), c, ref, []crypto.Hash{crypto.SHA256})
+			require.NoError(t, err)
+		}
+
+		assert.Equal(t, 2, requests, ref)
+	}
+}
+
+func TestArtifactDigestsFromOCIRef(t *testing.T) {
+	config := []byte(`{"architecture":"amd64","os":"linux"}`)
+	configDigest, err := cryptoutil.CalculateDigestSetFromBytes(config, []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+	manifest := []byte(fmt.Sprintf(`{"schemaVersion":2,"mediaType":"%v","config":{"digest":"sha256:%v"}}`, ociManifestType, configDigest[crypto.SHA256]))
+	manifestDigest, err := cryptoutil.CalculateDigestSetFromBytes(manifest, []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer testtoken" {
+			if r.URL.Path == "/token" {
+				_, _ = w.Write([]byte(`{"token":"testtoken"}`))
+				return
+			}
+
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="http://%v/token",service="test"`, r.Host))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/v2/app/manifests/"):
+			w.Header().Set("Content-Type", ociManifestType)
+			_, _ = w.Write(manifest)
+		case r.URL.Path == "/v2/app/blobs/sha256:"+configDigest[crypto.SHA256]:
+			_, _ = w.Write(config)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	digests, err := artifactDigestsFromRef(context.Background(), fmt.Sprintf("oci://%v/app:latest", host), []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+	require.Len(t, digests, 2)
+	assert.True(t, manifestDigest.Equal(digests[0]))
+	assert.True(t, configDigest.Equal(digests[1]))
+
+	_, err = artifactDigestsFromRef(context.Background(), fmt.Sprintf("oci://%v/app@sha256:%v", host, manifestDigest[crypto.SHA256]), []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+
+	_, err = artifactDigestsFromRef(context.Background(), fmt.Sprintf("oci://%v/app@sha256:0000", host), []crypto.Hash{crypto.SHA256})
+	assert.Error(t, err)
+}
+
+func TestExpandArtifactPaths(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"witness-linux-amd64", "witness-darwin-arm64", "checksums.txt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(name), 0644))
+	}
+
+	paths, err := expandArtifactPaths(filepath.Join(dir, "witness-*"), "", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "witness-darwin-arm64"), filepath.Join(dir, "witness-linux-amd64")}, paths)
+
+	stdin := strings.NewReader(filepath.Join(dir, "checksums.txt") + "\n\n  " + filepath.Join(dir, "witness-linux-amd64") + "\n")
+	paths, err = expandArtifactPaths("", "-", stdin)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "checksums.txt"), filepath.Join(dir, "witness-linux-amd64")}, paths)
+
+	paths, err = expandArtifactPaths(filepath.Join(dir, "missing.txt"), "", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "missing.txt")}, paths)
+
+	_, err = expandArtifactPaths(filepath.Join(dir, "*.tar.gz"), "", nil)
+	require.Error(t, err)
+
+	paths, err = expandArtifactPaths("-", "", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-"}, paths)
+
+	_, err = expandArtifactPaths("-", "-", strings.NewReader(""))
+	require.Error(t, err)
+}
+
+func TestArtifactDigestFromStdin(t *testing.T) {
+	content := []byte("streamed artifact")
+	path := filepath.Join(t.TempDir(), "artifact")
+	require.NoError(t, os.WriteFile(path, content, 0644))
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	stdin := os.Stdin
+	os.Stdin = f
+	defer func() { os.Stdin = stdin }()
+
+	digest, err := artifactDigestFromPath("-", []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+	expected, err := cryptoutil.CalculateDigestSetFromBytes(content, []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+	assert.True(t, expected.Equal(digest))
+}
+
+func TestPrintVerifyResults(t *testing.T) {
+	out := &strings.Builder{}
+	failed := printVerifyResults(out, []verifyTarget{
+		{name: "witness-linux-amd64"},
+		{name: "witness-darwin-arm64", err: fmt.Errorf("no collections found")},
+	})
+
+	assert.Equal(t, 1, failed)
+	assert.Contains(t, out.String(), "witness-linux-amd64   PASSED")
+	assert.Contains(t, out.String(), "witness-darwin-arm64  FAILED  no collections found")
+}
+
+func TestReadSubjectsFile(t *testing.T) {
+	digest := strings.Repeat("ab", 32)
+	entries, err := readSubjectsFile("-", strings.NewReader("# comment\n"+digest+"\n\nsha256:"+strings.ToUpper(digest)+"  registry.example.com/app:v1\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []subjectEntry{
+		{name: "sha256:" + digest, digest: cryptoutil.DigestSet{crypto.SHA256: digest}},
+		{name: "registry.example.com/app:v1", digest: cryptoutil.DigestSet{crypto.SHA256: digest}},
+	}, entries)
+
+	_, err = readSubjectsFile("-", strings.NewReader("sha1:abc\n"))
+	require.Error(t, err)
+
+	_, err = readSubjectsFile(filepath.Join(t.TempDir(), "missing.txt"), nil)
+	require.Error(t, err)
+}
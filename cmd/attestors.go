@@ -0,0 +1,55 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/testifysec/go-witness/attestation"
+	witnessattestor "github.com/testifysec/witness/attestation/witness"
+
+	// imported so their init functions run
+	_ "github.com/testifysec/witness/attestation/annotations"
+	_ "github.com/testifysec/witness/attestation/approval"
+	_ "github.com/testifysec/witness/attestation/bazel"
+	_ "github.com/testifysec/witness/attestation/buildcache"
+	_ "github.com/testifysec/witness/attestation/buildkit"
+	_ "github.com/testifysec/witness/attestation/checksums"
+	_ "github.com/testifysec/witness/attestation/containerexec"
+	_ "github.com/testifysec/witness/attestation/containerimage"
+	_ "github.com/testifysec/witness/attestation/dirhash"
+	_ "github.com/testifysec/witness/attestation/host"
+	_ "github.com/testifysec/witness/attestation/k8smanifest"
+	_ "github.com/testifysec/witness/attestation/k8spod"
+	_ "github.com/testifysec/witness/attestation/network"
+	_ "github.com/testifysec/witness/attestation/nix"
+	_ "github.com/testifysec/witness/attestation/oidc"
+	_ "github.com/testifysec/witness/attestation/packages"
+	_ "github.com/testifysec/witness/attestation/processtree"
+	_ "github.com/testifysec/witness/attestation/runas"
+	_ "github.com/testifysec/witness/attestation/scai"
+	_ "github.com/testifysec/witness/attestation/securitycontext"
+	_ "github.com/testifysec/witness/attestation/terraform"
+	_ "github.com/testifysec/witness/attestation/timesource"
+	_ "github.com/testifysec/witness/attestation/tools"
+	_ "github.com/testifysec/witness/attestation/tracing"
+	_ "github.com/testifysec/witness/attestation/vex"
+	_ "github.com/testifysec/witness/attestation/waiver"
+)
+
+func init() {
+	// record the version witness was released as rather than the module version from build info
+	attestation.RegisterAttestation(witnessattestor.Name, witnessattestor.Type, witnessattestor.RunType, func() attestation.Attestor {
+		return witnessattestor.New(witnessattestor.WithVersion(Version))
+	})
+}
@@ -0,0 +1,85 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/witness/attestation/bazel"
+	"github.com/testifysec/witness/options"
+)
+
+const bazelBuildEvents = `{"id":{"started":{}},"started":{"uuid":"c6d4e5f0-1a2b-4c3d-8e9f-0a1b2c3d4e5f","startTimeMillis":"1700000000000","buildToolVersion":"7.0.0","command":"build"}}
+{"id":{"namedSet":{"id":"0"}},"namedSetOfFiles":{"files":[{"name":"app","pathPrefix":["bazel-out","k8-fastbuild","bin"],"digest":"2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824","length":"5"}]}}
+{"id":{"targetCompleted":{"label":"//:app"}},"completed":{"success":true,"outputGroup":[{"name":"default","fileSets":[{"id":"0"}]}]}}
+{"id":{"buildFinished":{}},"finished":{"overallSuccess":true,"exitCode":{"name":"SUCCESS"}}}
+`
+
+func TestRunBazelAttestation(t *testing.T) {
+	priv, _ := rsakeypair(t)
+	workingDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(workingDir, "exec.json"), []byte(bazelExecutionLog), 0644))
+	attestationPath := filepath.Join(t.TempDir(), "outfile.txt")
+	runOptions := options.RunOptions{
+		KeyOptions:        options.KeyOptions{KeyPath: priv.Name()},
+		WorkingDir:        workingDir,
+		Attestations:      []string{},
+		BazelBEPPath:      "bep.json",
+		BazelExecutionLog: "exec.json",
+		OutFilePath:       attestationPath,
+		StepName:          "build",
+	}
+
+	require.NoError(t, runRun(context.Background(), runOptions, []string{"bash", "-c", "cat > bep.json <<'EOF'\n" + bazelBuildEvents + "EOF"}))
+	stmt, collection := readCollection(t, attestationPath)
+	attestor := findAttestor[*bazel.Attestor](collection)
+	require.NotNil(t, attestor)
+	require.NotNil(t, attestor.Invocation)
+	assert.Equal(t, "c6d4e5f0-1a2b-4c3d-8e9f-0a1b2c3d4e5f", attestor.Invocation.ID)
+	assert.Equal(t, "7.0.0", attestor.Invocation.Version)
+	assert.True(t, attestor.Invocation.Success)
+	assert.Equal(t, int64(1700000000), attestor.Invocation.StartTime.Unix())
+	require.NotNil(t, attestor.BuildEvents)
+	assert.Equal(t, "bep.json", attestor.BuildEvents.File)
+
+	appDigest := cryptoutil.DigestSet{crypto.SHA256: "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"}
+	assert.Equal(t, []bazel.Target{{
+		Label:   "//:app",
+		Success: true,
+		Outputs: []bazel.File{{Path: "bazel-out/k8-fastbuild/bin/app", Digest: appDigest}},
+	}}, attestor.Targets)
+
+	require.Len(t, attestor.Actions, 2)
+	assert.Equal(t, "CppCompile", attestor.Actions[0].Mnemonic)
+	assert.True(t, attestor.Actions[0].CacheHit)
+	require.Len(t, attestor.Actions[0].Outputs, 1)
+	assert.Equal(t, "bazel-out/k8-fastbuild/bin/_objs/app/main.o", attestor.Actions[0].Outputs[0].Path)
+	assert.Equal(t, "CppLink", attestor.Actions[1].Mnemonic)
+	assert.False(t, attestor.Actions[1].CacheHit)
+
+	subjects := map[string]map[string]string{}
+	for _, subject := range stmt.Subject {
+		subjects[subject.Name] = subject.Digest
+	}
+
+	assert.Equal(t, appDigest[crypto.SHA256], subjects[bazel.Type+"/output:bazel-out/k8-fastbuild/bin/app"]["sha256"])
+}
@@ -0,0 +1,121 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/witness/attestation/buildcache"
+	"github.com/testifysec/witness/options"
+)
+
+const bazelExecutionLog = `{
+  "commandArgs": ["gcc", "-c", "main.c"],
+  "mnemonic": "CppCompile",
+  "actualOutputs": [{
+    "path": "bazel-out/k8-fastbuild/bin/_objs/app/main.o",
+    "digest": {"hash": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08", "sizeBytes": "4", "hashFunctionName": "SHA-256"}
+  }],
+  "runner": "remote cache hit",
+  "remoteCacheHit": true,
+  "targetLabel": "//:app",
+  "digest": {"hash": "b5bb9d8014a0f9b1d61e21e796d78dccdf1352f23cd32812f4850b878ae4944c", "sizeBytes": "142", "hashFunctionName": "SHA-256"}
+}
+{
+  "commandArgs": ["gcc", "-o", "app"],
+  "mnemonic": "CppLink",
+  "runner": "linux-sandbox",
+  "targetLabel": "//:app"
+}
+`
+
+const gradleLog = `> Task :app:compileJava
+Build cache key for task ':app:compileJava' is 4d0b3d4e6f8e0c6c1b9d3a0e6e0b7e2f
+> Task :app:compileJava FROM-CACHE
+Build cache key for task ':app:test' is 0a1b2c3d4e5f60718293a4b5c6d7e8f9
+> Task :app:test
+
+BUILD SUCCESSFUL in 2s
+`
+
+func TestRunBuildCacheAttestation(t *testing.T) {
+	gradleHome := t.TempDir()
+	t.Setenv("GRADLE_USER_HOME", gradleHome)
+	cacheDir := filepath.Join(gradleHome, "caches", "build-cache-1")
+	require.NoError(t, os.MkdirAll(cacheDir, 0755))
+	entryPath := filepath.Join(cacheDir, "4d0b3d4e6f8e0c6c1b9d3a0e6e0b7e2f")
+	require.NoError(t, os.WriteFile(entryPath, []byte("cached classes"), 0644))
+	entryDigest, err := cryptoutil.CalculateDigestSetFromFile(entryPath, []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+
+	priv, _ := rsakeypair(t)
+	workingDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(workingDir, "exec.json"), []byte(bazelExecutionLog), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(workingDir, "sccache.json"), []byte(`{"tool":"sccache","key":"7f1e","digest":{"sha256":"abc123"}}`), 0644))
+	attestationPath := filepath.Join(t.TempDir(), "outfile.txt")
+	runOptions := options.RunOptions{
+		KeyOptions:     options.KeyOptions{KeyPath: priv.Name()},
+		WorkingDir:     workingDir,
+		Attestations:   []string{},
+		BuildCacheLogs: []string{"bazel=exec.json", "gradle=gradle.log", "json=sccache.json"},
+		OutFilePath:    attestationPath,
+		StepName:       "build",
+	}
+
+	require.NoError(t, runRun(context.Background(), runOptions, []string{"bash", "-c", "cat > gradle.log <<'EOF'\n" + gradleLog + "EOF"}))
+	_, collection := readCollection(t, attestationPath)
+	attestor := findAttestor[*buildcache.Attestor](collection)
+	require.NotNil(t, attestor)
+	require.Len(t, attestor.Logs, 3)
+	assert.Equal(t, "gradle.log", attestor.Logs[1].File)
+	assert.Equal(t, []buildcache.Hit{
+		{
+			Tool:   "bazel",
+			Key:    "b5bb9d8014a0f9b1d61e21e796d78dccdf1352f23cd32812f4850b878ae4944c",
+			Name:   "bazel-out/k8-fastbuild/bin/_objs/app/main.o",
+			Digest: cryptoutil.DigestSet{crypto.SHA256: "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"},
+		},
+		{Tool: "gradle", Key: "4d0b3d4e6f8e0c6c1b9d3a0e6e0b7e2f", Name: ":app:compileJava", Digest: entryDigest},
+		{Tool: "sccache", Key: "7f1e", Name: "7f1e", Digest: cryptoutil.DigestSet{crypto.SHA256: "abc123"}},
+	}, attestor.Hits)
+
+	materials := collection.Materials()
+	assert.Equal(t, entryDigest, materials["gradle-cache::app:compileJava"])
+	assert.Contains(t, materials, "bazel-cache:bazel-out/k8-fastbuild/bin/_objs/app/main.o")
+	assert.Contains(t, materials, "sccache-cache:7f1e")
+}
+
+func TestRunBuildCacheRejectsUnknownFormat(t *testing.T) {
+	priv, _ := rsakeypair(t)
+	runOptions := options.RunOptions{
+		KeyOptions:     options.KeyOptions{KeyPath: priv.Name()},
+		WorkingDir:     t.TempDir(),
+		Attestations:   []string{},
+		BuildCacheLogs: []string{"maven=build.log"},
+		OutFilePath:    filepath.Join(t.TempDir(), "outfile.txt"),
+		StepName:       "build",
+	}
+
+	err := runRun(context.Background(), runOptions, []string{"true"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown build cache log format maven")
+}
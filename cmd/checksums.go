@@ -0,0 +1,85 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"crypto"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/witness/pkg/checksums"
+)
+
+// checksumFile is a checksum file, such as SHA256SUMS, that verified artifacts must match
+type checksumFile struct {
+	path    string
+	digest  cryptoutil.DigestSet
+	entries []checksums.Entry
+}
+
+func loadChecksumFile(path string) (*checksumFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open checksum file: %w", err)
+	}
+
+	defer f.Close()
+	entries, err := checksums.Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse checksum file: %w", err)
+	}
+
+	digest, err := cryptoutil.CalculateDigestSetFromFile(path, []crypto.Hash{crypto.SHA256})
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate digest of checksum file: %w", err)
+	}
+
+	return &checksumFile{path: path, digest: digest, entries: entries}, nil
+}
+
+// check returns an error if the artifact at name isn't listed in the checksum file or its digest doesn't match
+func (c *checksumFile) check(name string, digest cryptoutil.DigestSet) error {
+	lookupName := name
+	if sumsDir, err := filepath.Abs(filepath.Dir(c.path)); err == nil {
+		if artifact, err := filepath.Abs(name); err == nil {
+			if rel, err := filepath.Rel(sumsDir, artifact); err == nil {
+				lookupName = rel
+			}
+		}
+	}
+
+	entry, ok := checksums.Lookup(c.entries, lookupName)
+	if !ok {
+		return fmt.Errorf("%v is not listed in checksum file %v", name, c.path)
+	}
+
+	actual, ok := digest[entry.Hash]
+	if !ok {
+		digestSet, err := cryptoutil.CalculateDigestSetFromFile(name, []crypto.Hash{entry.Hash})
+		if err != nil {
+			return fmt.Errorf("failed to calculate digest of %v: %w", name, err)
+		}
+
+		actual = digestSet[entry.Hash]
+	}
+
+	if actual != entry.Digest {
+		return fmt.Errorf("digest of %v does not match checksum file %v", name, c.path)
+	}
+
+	return nil
+}
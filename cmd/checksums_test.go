@@ -0,0 +1,91 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/witness/attestation/checksums"
+	"github.com/testifysec/witness/options"
+)
+
+func TestRunChecksumsAttestation(t *testing.T) {
+	priv, _ := rsakeypair(t)
+	workingDir := t.TempDir()
+	attestationPath := filepath.Join(t.TempDir(), "outfile.txt")
+	runOptions := options.RunOptions{
+		KeyOptions:    options.KeyOptions{KeyPath: priv.Name()},
+		WorkingDir:    workingDir,
+		Attestations:  []string{},
+		ChecksumFiles: []string{"SHA256SUMS"},
+		OutFilePath:   attestationPath,
+		StepName:      "release",
+	}
+
+	require.NoError(t, runRun(context.Background(), runOptions, []string{"bash", "-c", "echo app > app.tar && sha256sum app.tar > SHA256SUMS"}))
+	appDigest, err := cryptoutil.CalculateDigestSetFromFile(filepath.Join(workingDir, "app.tar"), []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+
+	stmt, collection := readCollection(t, attestationPath)
+	attestor := findAttestor[*checksums.Attestor](collection)
+	require.NotNil(t, attestor)
+	require.Len(t, attestor.Files, 1)
+	assert.Equal(t, "SHA256SUMS", attestor.Files[0].File)
+	assert.Equal(t, []checksums.Entry{{Name: "app.tar", Digest: appDigest}}, attestor.Files[0].Entries)
+
+	subjects := map[string]map[string]string{}
+	for _, subject := range stmt.Subject {
+		subjects[subject.Name] = subject.Digest
+	}
+
+	assert.Equal(t, appDigest[crypto.SHA256], subjects[checksums.Type+"/checksum:app.tar"]["sha256"])
+	assert.Contains(t, subjects, checksums.Type+"/checksums:SHA256SUMS")
+}
+
+func TestVerifyChecksums(t *testing.T) {
+	f := newVerifyFixture(t)
+	step1 := f.run(t, "step01", f.funcPrivPath, "echo 'test01' > test.txt")
+	step1Digest, err := cryptoutil.CalculateDigestSetFromFile(f.artifactPath, []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+	step2 := f.run(t, "step02", f.funcPrivPath, "echo 'test02' >> test.txt && sha256sum test.txt > SHA256SUMS")
+
+	vo := f.verifyOptions(f.policyPubPath, step1, step2)
+	vo.AdditionalSubjects = []string{step1Digest[crypto.SHA256]}
+	vo.ChecksumsFilePath = filepath.Join(f.workingDir, "SHA256SUMS")
+	require.NoError(t, runVerify(context.Background(), vo))
+
+	downloads := t.TempDir()
+	tampered := filepath.Join(downloads, "test.txt")
+	require.NoError(t, os.WriteFile(tampered, []byte("tampered\n"), 0644))
+	vo.ArtifactFilePath = tampered
+	err = runVerify(context.Background(), vo)
+	require.Error(t, err)
+	assert.Equal(t, ExitCodePolicy, ExitCode(err))
+	assert.Contains(t, err.Error(), "does not match checksum file")
+
+	unlisted := filepath.Join(downloads, "other.txt")
+	require.NoError(t, os.WriteFile(unlisted, []byte("other\n"), 0644))
+	vo.ArtifactFilePath = unlisted
+	err = runVerify(context.Background(), vo)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is not listed in checksum file")
+}
@@ -0,0 +1,67 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/options"
+)
+
+const (
+	ciModeTekton = "tekton"
+
+	tektonResultsDir = "/tekton/results"
+
+	// Tekton Chains treats results with these suffixes as an artifact to record in its own provenance
+	attestationURLResult    = "WITNESS_ATTESTATION_URL"
+	attestationDigestResult = "WITNESS_ATTESTATION_DIGEST"
+)
+
+// writeCIResults writes the digest of the signed envelope and where it was stored as CI task results.
+// The last storage location is preferred, so an Archivist URL is reported over the local out file.
+func writeCIResults(ro options.RunOptions, signedBytes []byte, storage []string) error {
+	dir := ro.CIResultsDir
+	if dir == "" && ro.CIMode == ciModeTekton {
+		dir = tektonResultsDir
+	}
+
+	location := ""
+	if len(storage) > 0 {
+		location = storage[len(storage)-1]
+	}
+
+	if location == "" {
+		log.Warnf("the attestation wasn't stored in a file or archivist, so the %v result will be empty", attestationURLResult)
+	}
+
+	digest := sha256.Sum256(signedBytes)
+	results := map[string]string{
+		attestationURLResult:    location,
+		attestationDigestResult: fmt.Sprintf("sha256:%x", digest),
+	}
+
+	for name, value := range results {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(value), 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
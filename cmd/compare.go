@@ -0,0 +1,286 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/attestation/environment"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/source"
+	"github.com/testifysec/witness/options"
+)
+
+func CompareCmd() *cobra.Command {
+	co := options.CompareOptions{}
+	cmd := &cobra.Command{
+		Use:   "compare [attestation file] [attestation file]",
+		Short: "Compares two attestation collections for the same step",
+		Long: "Compares the products, materials, and environment recorded in two attestation collections, such as two " +
+			"independent rebuilds of the same step, and exits with code 0 only if the products are bit-for-bit identical",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCompare(cmd.OutOrStdout(), co, args[0], args[1])
+		},
+		Args: cobra.ExactArgs(2),
+	}
+
+	co.AddFlags(cmd)
+	return cmd
+}
+
+const (
+	diffIdentical = "identical"
+	diffChanged   = "differs"
+	diffOnlyFirst = "only in first"
+	diffOnlySec   = "only in second"
+)
+
+// artifactDiff describes how a single named value, such as a product's digest, differs between two collections
+type artifactDiff struct {
+	name   string
+	status string
+	first  string
+	second string
+}
+
+// collectionComparison is the result of comparing two collections
+type collectionComparison struct {
+	products    []artifactDiff
+	materials   []artifactDiff
+	environment []artifactDiff
+}
+
+// reproducible is true if both collections produced exactly the same products
+func (c collectionComparison) reproducible() bool {
+	for _, diff := range c.products {
+		if diff.status != diffIdentical {
+			return false
+		}
+	}
+
+	return true
+}
+
+func runCompare(out io.Writer, co options.CompareOptions, firstPath, secondPath string) error {
+	first, err := loadCollectionEnvelope(firstPath)
+	if err != nil {
+		return err
+	}
+
+	second, err := loadCollectionEnvelope(secondPath)
+	if err != nil {
+		return err
+	}
+
+	if first.Collection.Name != second.Collection.Name {
+		return fmt.Errorf("collections are for different steps: %v and %v", first.Collection.Name, second.Collection.Name)
+	}
+
+	comparison := compareCollections(first.Collection, second.Collection, co.IgnoreProducts)
+	printComparison(out, comparison)
+	if !comparison.reproducible() {
+		return fmt.Errorf("products of step %v are not reproducible", first.Collection.Name)
+	}
+
+	fmt.Fprintf(out, "\nProducts of step %v are identical\n", first.Collection.Name)
+	return nil
+}
+
+// loadCollectionEnvelope reads a signed attestation collection from a file without verifying it
+func loadCollectionEnvelope(path string) (source.CollectionEnvelope, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return source.CollectionEnvelope{}, fmt.Errorf("failed to open attestation file: %w", err)
+	}
+
+	defer f.Close()
+	env := dsse.Envelope{}
+	if err := json.NewDecoder(f).Decode(&env); err != nil {
+		return source.CollectionEnvelope{}, fmt.Errorf("failed to parse envelope %v: %w", path, err)
+	}
+
+	collectionEnv, err := envelopeToCollectionEnvelope(path, env, nil, nil)
+	if err != nil {
+		return collectionEnv, fmt.Errorf("failed to parse collection %v: %w", path, err)
+	}
+
+	return collectionEnv, nil
+}
+
+func compareCollections(first, second attestation.Collection, ignoreProducts []string) collectionComparison {
+	ignored := make(map[string]struct{})
+	for _, product := range ignoreProducts {
+		ignored[product] = struct{}{}
+	}
+
+	firstProducts, secondProducts := collectionProducts(first), collectionProducts(second)
+	for product := range ignored {
+		delete(firstProducts, product)
+		delete(secondProducts, product)
+	}
+
+	firstEnv, secondEnv := collectionEnvironment(first), collectionEnvironment(second)
+	return collectionComparison{
+		products:    diffValues(firstProducts, secondProducts),
+		materials:   diffValues(collectionMaterials(first), collectionMaterials(second)),
+		environment: diffValues(firstEnv, secondEnv),
+	}
+}
+
+func collectionProducts(collection attestation.Collection) map[string]string {
+	products := make(map[string]string)
+	for _, att := range collection.Attestations {
+		if producer, ok := att.Attestation.(attestation.Producer); ok {
+			for name, product := range producer.Products() {
+				products[name] = formatDigestSet(product.Digest)
+			}
+		}
+	}
+
+	return products
+}
+
+func collectionMaterials(collection attestation.Collection) map[string]string {
+	materials := make(map[string]string)
+	for _, att := range collection.Attestations {
+		if materialer, ok := att.Attestation.(attestation.Materialer); ok {
+			for name, digest := range materialer.Materials() {
+				materials[name] = formatDigestSet(digest)
+			}
+		}
+	}
+
+	return materials
+}
+
+func collectionEnvironment(collection attestation.Collection) map[string]string {
+	env := make(map[string]string)
+	for _, att := range collection.Attestations {
+		envAttestor, ok := att.Attestation.(*environment.Attestor)
+		if !ok {
+			continue
+		}
+
+		env["os"] = envAttestor.OS
+		env["hostname"] = envAttestor.Hostname
+		env["username"] = envAttestor.Username
+		for name, value := range envAttestor.Variables {
+			env["$"+name] = value
+		}
+	}
+
+	return env
+}
+
+// formatDigestSet formats a digest set the same way regardless of map ordering, so formatted digest sets can be compared
+func formatDigestSet(ds cryptoutil.DigestSet) string {
+	parts := []string{}
+	for hash, digest := range ds {
+		name, err := cryptoutil.HashToString(hash)
+		if err != nil {
+			name = hash.String()
+		}
+
+		parts = append(parts, fmt.Sprintf("%v:%v", name, digest))
+	}
+
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+func diffValues(first, second map[string]string) []artifactDiff {
+	diffs := []artifactDiff{}
+	for name, firstValue := range first {
+		secondValue, ok := second[name]
+		switch {
+		case !ok:
+			diffs = append(diffs, artifactDiff{name: name, status: diffOnlyFirst, first: firstValue})
+		case firstValue == secondValue:
+			diffs = append(diffs, artifactDiff{name: name, status: diffIdentical, first: firstValue, second: secondValue})
+		default:
+			diffs = append(diffs, artifactDiff{name: name, status: diffChanged, first: firstValue, second: secondValue})
+		}
+	}
+
+	for name, secondValue := range second {
+		if _, ok := first[name]; !ok {
+			diffs = append(diffs, artifactDiff{name: name, status: diffOnlySec, second: secondValue})
+		}
+	}
+
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].name < diffs[j].name })
+	return diffs
+}
+
+func printComparison(out io.Writer, comparison collectionComparison) {
+	// the environment has many variables, so only the ones that differ are listed
+	sections := []struct {
+		title         string
+		diffs         []artifactDiff
+		hideIdentical bool
+	}{
+		{"Products", comparison.products, false},
+		{"Materials", comparison.materials, false},
+		{"Environment", comparison.environment, true},
+	}
+
+	for i, section := range sections {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+
+		fmt.Fprintf(out, "%v:\n", section.title)
+		tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		identical := 0
+		for _, diff := range section.diffs {
+			if diff.status == diffIdentical {
+				identical++
+				if !section.hideIdentical {
+					fmt.Fprintf(tw, "  %v\t%v\t\n", diff.status, diff.name)
+				}
+
+				continue
+			}
+
+			fmt.Fprintf(tw, "  %v\t%v\t%v != %v\n", diff.status, diff.name, orDash(diff.first), orDash(diff.second))
+		}
+
+		if section.hideIdentical && identical > 0 {
+			fmt.Fprintf(tw, "  %d identical\t\t\n", identical)
+		}
+
+		tw.Flush()
+	}
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
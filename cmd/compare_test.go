@@ -0,0 +1,60 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/witness/options"
+)
+
+func TestCompare(t *testing.T) {
+	priv, _ := rsakeypair(t)
+	attestationDir := t.TempDir()
+	build := func(name, content string) string {
+		outPath := filepath.Join(attestationDir, name+".json")
+		runOptions := options.RunOptions{
+			KeyOptions:   options.KeyOptions{KeyPath: priv.Name()},
+			WorkingDir:   t.TempDir(),
+			Attestations: []string{"environment"},
+			OutFilePath:  outPath,
+			StepName:     "build",
+		}
+
+		require.NoError(t, runRun(context.Background(), runOptions, []string{"bash", "-c", "echo '" + content + "' > app.bin && date +%s%N > build.log"}))
+		return outPath
+	}
+
+	first := build("first", "app")
+	second := build("second", "app")
+	third := build("third", "changed")
+
+	out := &bytes.Buffer{}
+	require.Error(t, runCompare(out, options.CompareOptions{}, first, second), "build.log includes the time, so it differs")
+
+	out.Reset()
+	require.NoError(t, runCompare(out, options.CompareOptions{IgnoreProducts: []string{"build.log"}}, first, second))
+	assert.Regexp(t, `identical\s+app.bin`, out.String())
+	assert.Contains(t, out.String(), "Products of step build are identical")
+
+	out.Reset()
+	require.Error(t, runCompare(out, options.CompareOptions{IgnoreProducts: []string{"build.log"}}, first, third))
+	assert.Regexp(t, `differs\s+app.bin`, out.String())
+}
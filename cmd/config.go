@@ -57,8 +57,15 @@ func initConfig(rootCmd *cobra.Command, rootOptions *options.RootOptions) error
 		flags := cm.Flags()
 		flags.VisitAll(func(f *pflag.Flag) {
 			configKey := fmt.Sprintf("%s.%s", cm.Name(), f.Name)
+			// doctor checks the configuration of witness run, so it falls back to the run section
+			if cm.Name() == "doctor" && !v.IsSet(configKey) {
+				configKey = fmt.Sprintf("run.%s", f.Name)
+			}
+
 			if !f.Changed {
-				if f.Value.Type() == "stringSlice" {
+				// array flags, like --archivist-server, take a list so values with commas or spaces aren't split
+				_, isList := v.Get(configKey).([]interface{})
+				if f.Value.Type() == "stringSlice" || (f.Value.Type() == "stringArray" && isList) {
 					configValue := v.GetStringSlice(configKey)
 					if len(configValue) > 0 {
 						for _, v := range configValue {
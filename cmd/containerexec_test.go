@@ -0,0 +1,108 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/attestation/commandrun"
+	"github.com/testifysec/witness/attestation/containerexec"
+	"github.com/testifysec/witness/options"
+)
+
+// fakeContainerRuntime writes a docker compatible CLI that runs commands on the host in the container's working
+// directory, with only the environment variables passed to the container
+func fakeContainerRuntime(t *testing.T) string {
+	runtime := filepath.Join(t.TempDir(), "docker")
+	script := `#!/bin/bash
+case "$1" in
+image)
+	echo '[{"Id":"sha256:1111111111111111111111111111111111111111111111111111111111111111","RepoDigests":["golang@sha256:2222222222222222222222222222222222222222222222222222222222222222"],"Os":"linux","Architecture":"amd64","Config":{"Entrypoint":null,"Cmd":["bash"]}}]'
+	;;
+run)
+	shift
+	env=()
+	while [ "${1#--}" != "$1" ]; do
+		case "$1" in
+		--workdir) cd "$2"; shift ;;
+		--env) env+=("$2=${!2}"); shift ;;
+		--mount) shift ;;
+		esac
+		shift
+	done
+	shift
+	exec env -i "${env[@]}" "$@"
+	;;
+esac
+`
+	require.NoError(t, os.WriteFile(runtime, []byte(script), 0755))
+	return runtime
+}
+
+func TestRunInContainer(t *testing.T) {
+	t.Setenv("WITNESS_TEST_HOST_ONLY", "host")
+	priv, _ := rsakeypair(t)
+	workingDir := t.TempDir()
+	cache := t.TempDir()
+	attestationPath := filepath.Join(t.TempDir(), "outfile.txt")
+	runOptions := options.RunOptions{
+		KeyOptions:       options.KeyOptions{KeyPath: priv.Name()},
+		WorkingDir:       workingDir,
+		Attestations:     []string{},
+		OutFilePath:      attestationPath,
+		StepName:         "build",
+		ContainerRuntime: fakeContainerRuntime(t),
+		ContainerMounts:  []string{cache + ":/cache:ro"},
+		Env:              []string{"GREETING=hello"},
+	}
+
+	args := []string{"sh", "-c", "echo $GREETING$WITNESS_TEST_HOST_ONLY > out.txt"}
+	require.ErrorContains(t, runRun(context.Background(), runOptions, args), "--container-mount requires --in-container")
+
+	runOptions.InContainer, runOptions.Tracing = "golang:1.18", true
+	require.ErrorContains(t, runRun(context.Background(), runOptions, args), "--trace")
+
+	runOptions.Tracing = false
+	require.NoError(t, runRun(context.Background(), runOptions, args))
+	out, err := os.ReadFile(filepath.Join(workingDir, "out.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello\n", string(out), "only the variables passed to the container are set")
+
+	_, collection := readCollection(t, attestationPath)
+	attestor := findAttestor[*containerexec.Attestor](collection)
+	command := findAttestor[*commandrun.CommandRun](collection)
+
+	require.NotNil(t, attestor)
+	assert.Equal(t, "golang:1.18", attestor.Image.Reference)
+	assert.Equal(t, "sha256:1111111111111111111111111111111111111111111111111111111111111111", attestor.Image.ID)
+	assert.Equal(t, "linux/amd64", attestor.Image.Platform)
+	assert.Equal(t, args, attestor.Entrypoint)
+	assert.Equal(t, workingDir, attestor.WorkingDir)
+	require.Len(t, attestor.Mounts, 2)
+	assert.Equal(t, workingDir, attestor.Mounts[0].Source)
+	assert.Equal(t, "/cache", attestor.Mounts[1].Destination)
+	assert.True(t, attestor.Mounts[1].ReadOnly)
+	require.NotNil(t, command)
+	assert.Equal(t, args, command.Cmd, "the command is recorded as given, not as the container runtime ran it")
+
+	materials := attestor.Materials()
+	require.Contains(t, materials, "container-image:golang")
+	assert.Contains(t, materials, "container-image-config:golang:1.18")
+}
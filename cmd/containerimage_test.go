@@ -0,0 +1,121 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"crypto"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/witness/attestation/containerimage"
+	"github.com/testifysec/witness/pkg/schema"
+)
+
+func TestContainerImageAttestor(t *testing.T) {
+	t.Setenv("CI_JOB_IMAGE", "")
+	containerID := strings.Repeat("ab", 32)
+	layerID := strings.Repeat("cd", 32)
+	procDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(procDir, "self"), 0755))
+	// the overlay layer directory must not be mistaken for the container
+	mountinfo := "1 0 0:1 / / rw - overlay overlay rw,upperdir=/var/lib/docker/overlay2/" + layerID + "/diff\n" +
+		"2 1 8:1 /var/lib/docker/containers/" + containerID + "/hostname /etc/hostname rw - ext4 /dev/sda1 rw\n"
+	require.NoError(t, os.WriteFile(filepath.Join(procDir, "self", "cgroup"), []byte("0::/\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(procDir, "self", "mountinfo"), []byte(mountinfo), 0644))
+
+	socket := filepath.Join(t.TempDir(), "engine.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/containers/" + containerID + "/json":
+			w.Write([]byte(`{"Image":"sha256:1111111111111111111111111111111111111111111111111111111111111111","Config":{"Image":"golang:1.18"}}`))
+		case "/images/sha256:1111111111111111111111111111111111111111111111111111111111111111/json":
+			w.Write([]byte(`{"Id":"sha256:1111111111111111111111111111111111111111111111111111111111111111","RepoDigests":["golang@sha256:2222222222222222222222222222222222222222222222222222222222222222"],"Os":"linux","Architecture":"arm64","Variant":"v8","RootFS":{"Layers":["sha256:3333333333333333333333333333333333333333333333333333333333333333"]}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	attest := func(opts ...containerimage.Option) (*containerimage.Attestor, error) {
+		opts = append([]containerimage.Option{
+			containerimage.WithProcDir(procDir),
+			containerimage.WithContainerEnvFile(filepath.Join(procDir, "containerenv")),
+		}, opts...)
+
+		a := containerimage.New(opts...)
+		ctx, err := attestation.NewContext([]attestation.Attestor{a})
+		require.NoError(t, err)
+		return a, a.Attest(ctx)
+	}
+
+	a, err := attest(containerimage.WithSockets([]string{filepath.Join(t.TempDir(), "missing.sock"), socket}))
+	require.NoError(t, err)
+	assert.Equal(t, containerID, a.ContainerID)
+	assert.Equal(t, "unix://"+socket, a.Source)
+	assert.Equal(t, "golang:1.18", a.Image.Reference)
+	assert.Equal(t, "linux/arm64/v8", a.Image.Platform)
+	assert.Equal(t, []string{"sha256:3333333333333333333333333333333333333333333333333333333333333333"}, a.Image.Layers)
+	materials := a.Materials()
+	assert.Equal(t, "2222222222222222222222222222222222222222222222222222222222222222", materials["job-image:golang"][crypto.SHA256])
+	assert.Equal(t, "1111111111111111111111111111111111111111111111111111111111111111", materials["job-image-config:golang:1.18"][crypto.SHA256])
+
+	s, ok, err := schema.ForType(containerimage.Type)
+	require.NoError(t, err)
+	require.True(t, ok)
+	predicate, err := json.Marshal(a)
+	require.NoError(t, err)
+	validationErrs, err := s.Validate(predicate)
+	require.NoError(t, err)
+	assert.Empty(t, validationErrs)
+
+	// without the runtime, podman's .containerenv and then the image GitLab started the job from are used
+	containerEnv := "engine=\"podman-4.4.1\"\nimage=\"docker.io/library/golang:1.18\"\nimageid=\"1111111111111111111111111111111111111111111111111111111111111111\"\n"
+	require.NoError(t, os.WriteFile(filepath.Join(procDir, "containerenv"), []byte(containerEnv), 0644))
+	a, err = attest(containerimage.WithSockets(nil))
+	require.NoError(t, err)
+	assert.Equal(t, "sha256:1111111111111111111111111111111111111111111111111111111111111111", a.Image.ID)
+	assert.Equal(t, "docker.io/library/golang:1.18", a.Image.Reference)
+
+	require.NoError(t, os.Remove(filepath.Join(procDir, "containerenv")))
+	t.Setenv("CI_JOB_IMAGE", "golang@sha256:2222222222222222222222222222222222222222222222222222222222222222")
+	a, err = attest(containerimage.WithSockets(nil))
+	require.NoError(t, err)
+	assert.Equal(t, "CI_JOB_IMAGE", a.Source)
+	assert.Contains(t, a.Materials(), "job-image:golang")
+
+	t.Setenv("CI_JOB_IMAGE", "")
+	_, err = attest(containerimage.WithSockets(nil))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "mount the docker or podman socket")
+
+	require.NoError(t, os.WriteFile(filepath.Join(procDir, "self", "mountinfo"), []byte("1 0 0:1 / / rw - ext4 /dev/sda1 rw\n"), 0644))
+	_, err = attest(containerimage.WithSockets(nil))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "doesn't appear to be running in a container")
+}
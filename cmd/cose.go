@@ -0,0 +1,73 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"crypto"
+	"fmt"
+	"os"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/cose"
+	"github.com/testifysec/witness/pkg/runner"
+)
+
+// signCOSE signs the input file in a COSE_Sign1 envelope. The payload is the file as is, with the data type as its
+// content type, and the signer's key ID and any certificate chain are protected headers.
+func signCOSE(so options.SignOptions) error {
+	key, certs, err := runner.LoadKeyFile(so.KeyOptions)
+	if err != nil {
+		return err
+	}
+
+	keyID, err := cryptoutil.GeneratePublicKeyID(key.Public(), crypto.SHA256)
+	if err != nil {
+		return fmt.Errorf("failed to generate key id: %w", err)
+	}
+
+	protected := cose.Headers{
+		cose.HeaderContentType: so.DataType,
+		cose.HeaderKeyID:       []byte(keyID),
+	}
+
+	if len(certs) > 0 {
+		chain := make([]interface{}, 0, len(certs))
+		for _, cert := range certs {
+			chain = append(chain, cert.Raw)
+		}
+
+		protected[cose.HeaderX5Chain] = chain
+	}
+
+	payload, err := os.ReadFile(so.InFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to open file to sign: %w", err)
+	}
+
+	msg, err := cose.Sign(key, protected, nil, payload)
+	if err != nil {
+		return err
+	}
+
+	out, err := loadOutfile(so.OutFilePath)
+	if err != nil {
+		return err
+	}
+
+	defer out.Close()
+	_, err = out.Write(msg)
+	return err
+}
@@ -0,0 +1,72 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/cose"
+)
+
+func TestSignCOSE(t *testing.T) {
+	signer := newNotationSigner(t, "signer")
+	dir := t.TempDir()
+	inPath := filepath.Join(dir, "policy.json")
+	require.NoError(t, os.WriteFile(inPath, []byte(`{"expires": "2030-01-01T00:00:00Z"}`), 0644))
+
+	for name, certPath := range map[string]string{"key": "", "certificate": signer.certPath} {
+		t.Run(name, func(t *testing.T) {
+			so := options.SignOptions{
+				KeyOptions:     options.KeyOptions{KeyPath: signer.keyPath, CertPath: certPath},
+				DataType:       "https://witness.testifysec.com/policy/v0.1",
+				InFilePath:     inPath,
+				OutFilePath:    filepath.Join(t.TempDir(), "policy.cose"),
+				EnvelopeFormat: envelopeFormatCOSE,
+			}
+
+			require.NoError(t, runSign(so))
+			signed, err := os.ReadFile(so.OutFilePath)
+			require.NoError(t, err)
+			msg, err := cose.Parse(signed)
+			require.NoError(t, err)
+			assert.Equal(t, so.DataType, msg.ContentType())
+			assert.Equal(t, []byte(`{"expires": "2030-01-01T00:00:00Z"}`), msg.Payload)
+
+			keyFile, err := os.Open(signer.keyPath)
+			require.NoError(t, err)
+			defer keyFile.Close()
+			key, err := cryptoutil.TryParseKeyFromReader(keyFile)
+			require.NoError(t, err)
+			pub := &key.(*ecdsa.PrivateKey).PublicKey
+			require.NoError(t, msg.Verify(pub))
+
+			keyID, err := cryptoutil.GeneratePublicKeyID(pub, crypto.SHA256)
+			require.NoError(t, err)
+			assert.Equal(t, []byte(keyID), msg.Protected[cose.HeaderKeyID])
+
+			certs, err := msg.Certificates()
+			require.NoError(t, err)
+			assert.Equal(t, certPath != "", len(certs) == 1)
+		})
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/pkg/cosign"
+)
+
+// checkCosignSignature checks that the image artifactRef refers to has a cosign signature from one of the
+// functionaries the policy trusts to sign images
+func checkCosignSignature(ctx context.Context, requirement *cosign.Requirement, artifactRef string) error {
+	if !strings.HasPrefix(artifactRef, "oci://") {
+		return withExitCode(ExitCodePolicy, fmt.Errorf("policy requires a cosign signature, which only images verified with --artifact-ref oci:// can have"))
+	}
+
+	ref, manifestDigest, err := imageManifestDigest(ctx, artifactRef)
+	if err != nil {
+		return err
+	}
+
+	signatures, err := fetchCosignSignatures(ctx, ref, manifestDigest)
+	if err != nil {
+		return err
+	}
+
+	if err := requirement.Check(signatures, manifestDigest); err != nil {
+		return withExitCode(ExitCodePolicy, err)
+	}
+
+	log.Infof("Verified cosign signature of %v", ref)
+	return nil
+}
+
+// imageManifestDigest returns the OCI digest of the manifest an oci:// artifact reference refers to
+func imageManifestDigest(ctx context.Context, artifactRef string) (ociReference, string, error) {
+	ref, err := parseOCIReference(strings.TrimPrefix(artifactRef, "oci://"))
+	if err != nil {
+		return ref, "", err
+	}
+
+	manifest, _, err := newRegistryClient(ref).Manifest(ctx)
+	if err != nil {
+		return ref, "", withExitCode(ExitCodeInfrastructure, fmt.Errorf("failed to fetch manifest for %v: %w", ref, err))
+	}
+
+	digestSet, err := cryptoutil.CalculateDigestSetFromBytes(manifest, []crypto.Hash{crypto.SHA256})
+	if err != nil {
+		return ref, "", err
+	}
+
+	if ref.Digest != "" {
+		if err := checkOCIDigest(ref.Digest, digestSet); err != nil {
+			return ref, "", withExitCode(ExitCodeInfrastructure, fmt.Errorf("manifest for %v failed digest check: %w", ref, err))
+		}
+	}
+
+	return ref, "sha256:" + digestSet[crypto.SHA256], nil
+}
+
+// fetchCosignSignatures fetches the signatures cosign stored for the image with manifestDigest
+func fetchCosignSignatures(ctx context.Context, ref ociReference, manifestDigest string) ([]cosign.Signature, error) {
+	sigRef := ociReference{Registry: ref.Registry, Repository: ref.Repository, Tag: cosign.SignatureTag(manifestDigest)}
+	client := newRegistryClient(sigRef)
+	manifest, _, err := client.Manifest(ctx)
+	if isRegistryNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, withExitCode(ExitCodeInfrastructure, fmt.Errorf("failed to fetch cosign signatures for %v: %w", ref, err))
+	}
+
+	layers, err := cosign.SignatureLayers(manifest)
+	if err != nil {
+		return nil, withExitCode(ExitCodePolicy, err)
+	}
+
+	signatures := make([]cosign.Signature, 0, len(layers))
+	for _, layer := range layers {
+		payload, err := fetchBlob(ctx, client, layer.Digest)
+		if err != nil {
+			return nil, withExitCode(ExitCodeInfrastructure, fmt.Errorf("failed to fetch cosign signature payload for %v: %w", ref, err))
+		}
+
+		sig, err := cosign.NewSignature(layer, payload)
+		if err != nil {
+			log.Debugf("(cosign) skipping signature layer %v: %v", layer.Digest, err)
+			continue
+		}
+
+		signatures = append(signatures, sig)
+	}
+
+	return signatures, nil
+}
+
+// fetchBlob reads the blob with digest and checks it matches the digest
+func fetchBlob(ctx context.Context, client *registryClient, digest string) ([]byte, error) {
+	blob, err := client.Blob(ctx, digest)
+	if err != nil {
+		return nil, err
+	}
+
+	defer blob.Close()
+	data, err := io.ReadAll(blob)
+	if err != nil {
+		return nil, err
+	}
+
+	digestSet, err := cryptoutil.CalculateDigestSetFromBytes(data, []crypto.Hash{crypto.SHA256})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkOCIDigest(digest, digestSet); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
@@ -0,0 +1,119 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/policy"
+	"github.com/testifysec/witness/pkg/cosign"
+)
+
+// newCosignRegistry serves an image and, if sign is set, a cosign signature of it made with sign
+func newCosignRegistry(t *testing.T, sign func(payload []byte) []byte) string {
+	manifest := []byte(fmt.Sprintf(`{"schemaVersion":2,"mediaType":"%v","layers":[]}`, ociManifestType))
+	manifestDigest := fmt.Sprintf("sha256:%x", sha256.Sum256(manifest))
+	payload := []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"app"},"image":{"docker-manifest-digest":"%v"},"type":"cosign container image signature"},"optional":null}`, manifestDigest))
+	payloadDigest := fmt.Sprintf("sha256:%x", sha256.Sum256(payload))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v2/":
+		case r.URL.Path == "/v2/app/manifests/latest":
+			w.Header().Set("Content-Type", ociManifestType)
+			_, _ = w.Write(manifest)
+		case r.URL.Path == "/v2/app/manifests/"+cosign.SignatureTag(manifestDigest) && sign != nil:
+			w.Header().Set("Content-Type", ociManifestType)
+			_, _ = fmt.Fprintf(w, `{"schemaVersion":2,"mediaType":"%v","layers":[{"mediaType":"%v","digest":"%v","annotations":{"%v":"%v"}}]}`,
+				ociManifestType, cosign.SimpleSigningMediaType, payloadDigest, cosign.SignatureAnnotation, base64.StdEncoding.EncodeToString(sign(payload)))
+		case r.URL.Path == "/v2/app/blobs/"+payloadDigest:
+			_, _ = w.Write(payload)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	t.Cleanup(server.Close)
+	return fmt.Sprintf("oci://%v/app:latest", strings.TrimPrefix(server.URL, "http://"))
+}
+
+func ecdsaCosignSigner(t *testing.T) (*ecdsa.PrivateKey, func([]byte) []byte) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return priv, func(payload []byte) []byte {
+		digest := sha256.Sum256(payload)
+		sig, err := ecdsa.SignASN1(rand.Reader, priv, digest[:])
+		require.NoError(t, err)
+		return sig
+	}
+}
+
+func TestVerifyCosignSignature(t *testing.T) {
+	trustedKey, trustedSign := ecdsaCosignSigner(t)
+	_, untrustedSign := ecdsaCosignSigner(t)
+	f := newVerifyFixtureWithPolicy(t, func(p map[string]interface{}) {
+		keyID, err := cryptoutil.NewECDSAVerifier(&trustedKey.PublicKey, crypto.SHA256).KeyID()
+		require.NoError(t, err)
+		keyBytes, err := x509.MarshalPKIXPublicKey(&trustedKey.PublicKey)
+		require.NoError(t, err)
+		p["publickeys"].(map[string]interface{})[keyID] = policy.PublicKey{KeyID: keyID, Key: pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: keyBytes})}
+		p["cosign"] = map[string]interface{}{
+			"functionaries": []policy.Functionary{{Type: "PublicKey", PublicKeyID: keyID}},
+		}
+	})
+
+	step1 := f.run(t, "step01", f.funcPrivPath, "echo 'test01' > test.txt")
+	step1Digest, err := cryptoutil.CalculateDigestSetFromFile(f.artifactPath, []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+	step2 := f.run(t, "step02", f.funcPrivPath, "echo 'test02' >> test.txt")
+
+	vo := f.verifyOptions(f.policyPubPath, step1, step2)
+	vo.AdditionalSubjects = []string{step1Digest[crypto.SHA256]}
+	vo.ArtifactRef = newCosignRegistry(t, trustedSign)
+	require.NoError(t, runVerify(context.Background(), vo))
+
+	vo.ArtifactRef = newCosignRegistry(t, untrustedSign)
+	err = runVerify(context.Background(), vo)
+	require.Error(t, err)
+	assert.Equal(t, ExitCodePolicy, ExitCode(err))
+	assert.Contains(t, err.Error(), "no valid cosign signature")
+
+	vo.ArtifactRef = newCosignRegistry(t, nil)
+	err = runVerify(context.Background(), vo)
+	require.Error(t, err)
+	assert.Equal(t, ExitCodePolicy, ExitCode(err))
+	assert.Contains(t, err.Error(), "no cosign signatures found")
+
+	vo.ArtifactRef = ""
+	err = runVerify(context.Background(), vo)
+	require.Error(t, err)
+	assert.Equal(t, ExitCodePolicy, ExitCode(err))
+}
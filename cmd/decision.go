@@ -0,0 +1,190 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+	"github.com/testifysec/go-witness/signer/file"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/fips"
+)
+
+const (
+	decisionPredicateType = "https://witness.dev/verification-decision/v0.1"
+	decisionPass          = "pass"
+	decisionFail          = "fail"
+)
+
+// decision is the predicate of a decision record, describing the inputs and outcome of a verification
+type decision struct {
+	Time         time.Time          `json:"time"`
+	Policy       decisionPolicy     `json:"policy"`
+	Attestations []string           `json:"attestations,omitempty"`
+	Archivist    string             `json:"archivist,omitempty"`
+	Result       string             `json:"result"`
+	ExitCode     int                `json:"exitCode"`
+	Error        string             `json:"error,omitempty"`
+	Artifacts    []decisionArtifact `json:"artifacts,omitempty"`
+}
+
+type decisionPolicy struct {
+	Path   string               `json:"path"`
+	Digest cryptoutil.DigestSet `json:"digest"`
+}
+
+type decisionArtifact struct {
+	Name     string                 `json:"name"`
+	Subjects []cryptoutil.DigestSet `json:"subjects"`
+	Passed   bool                   `json:"passed"`
+	Error    string                 `json:"error,omitempty"`
+	Evidence []string               `json:"evidence,omitempty"`
+	Waivers  []string               `json:"waivers,omitempty"`
+}
+
+// decisionLog signs a record of each verification decision and writes it to a file, a URL, or both
+type decisionLog struct {
+	options.DecisionLogOptions
+	signer cryptoutil.Signer
+}
+
+// newDecisionLog returns nil if no decision log is configured
+func newDecisionLog(ctx context.Context, o options.DecisionLogOptions) (*decisionLog, error) {
+	if o.Path == "" && o.URL == "" {
+		return nil, nil
+	}
+
+	if o.KeyPath == "" {
+		return nil, fmt.Errorf("a decision log key is required to sign decision records")
+	}
+
+	signer, err := file.Signer(ctx, o.KeyPath, "", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load decision log key: %w", err)
+	}
+
+	if ro.FIPS {
+		if err := fips.CheckSigner(signer); err != nil {
+			return nil, fmt.Errorf("decision log key is not FIPS compliant: %w", err)
+		}
+	}
+
+	return &decisionLog{DecisionLogOptions: o, signer: signer}, nil
+}
+
+// record signs the outcome of verifying targets, and appends it to the log file and posts it to the log URL
+func (l *decisionLog) record(ctx context.Context, vo options.VerifyOptions, targets []verifyTarget, verifyErr error) error {
+	policyDigest, err := cryptoutil.CalculateDigestSetFromFile(vo.PolicyFilePath, []crypto.Hash{crypto.SHA256})
+	if err != nil {
+		return fmt.Errorf("failed to calculate policy digest: %w", err)
+	}
+
+	d := decision{
+		Time:         time.Now().UTC(),
+		Policy:       decisionPolicy{Path: vo.PolicyFilePath, Digest: policyDigest},
+		Attestations: vo.AttestationFilePaths,
+		Result:       decisionPass,
+	}
+
+	if vo.ArchivistOptions.Enable {
+		d.Archivist = strings.Join(vo.ArchivistOptions.Urls, ",")
+	}
+
+	if verifyErr != nil {
+		d.Result = decisionFail
+		d.ExitCode = ExitCode(verifyErr)
+		d.Error = verifyErr.Error()
+	}
+
+	subjects := make(map[string]cryptoutil.DigestSet)
+	for _, target := range targets {
+		artifact := decisionArtifact{Name: target.name, Subjects: target.subjects, Passed: target.err == nil, Evidence: target.evidence}
+		if target.err != nil {
+			artifact.Error = target.err.Error()
+		}
+
+		for _, w := range target.waivers {
+			artifact.Waivers = append(artifact.Waivers, w.Reference)
+		}
+
+		d.Artifacts = append(d.Artifacts, artifact)
+		if len(target.subjects) > 0 {
+			subjects[target.name] = target.subjects[0]
+		}
+	}
+
+	predicate, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+
+	statement, err := intoto.NewStatement(decisionPredicateType, predicate, subjects)
+	if err != nil {
+		return err
+	}
+
+	statementBytes, err := json.Marshal(statement)
+	if err != nil {
+		return err
+	}
+
+	env, err := dsse.Sign(intoto.PayloadType, bytes.NewReader(statementBytes), dsse.SignWithSigners(l.signer))
+	if err != nil {
+		return fmt.Errorf("failed to sign decision record: %w", err)
+	}
+
+	envBytes, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+
+	if l.Path != "" {
+		if err := appendLine(l.Path, envBytes); err != nil {
+			return fmt.Errorf("failed to write decision log: %w", err)
+		}
+	}
+
+	if l.URL != "" {
+		if err := postWebhook(ctx, l.URL, envBytes); err != nil {
+			return fmt.Errorf("failed to send decision record to %v: %w", l.URL, err)
+		}
+	}
+
+	return nil
+}
+
+func appendLine(path string, line []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
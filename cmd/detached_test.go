@@ -0,0 +1,86 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/detached"
+)
+
+func TestVerifyDetachedPredicate(t *testing.T) {
+	f := newVerifyFixture(t)
+	step1 := f.run(t, "step01", f.funcPrivPath, "echo 'test01' > test.txt")
+	step1Digest, err := cryptoutil.CalculateDigestSetFromFile(f.artifactPath, []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+
+	step2 := filepath.Join(t.TempDir(), "step02.json")
+	predicatePath := filepath.Join(t.TempDir(), "step02.collection.json")
+	require.NoError(t, runRun(context.Background(), options.RunOptions{
+		KeyOptions:          options.KeyOptions{KeyPath: f.funcPrivPath},
+		WorkingDir:          f.workingDir,
+		OutFilePath:         step2,
+		StepName:            "step02",
+		DetachPredicatePath: predicatePath,
+	}, []string{"bash", "-c", "echo 'test02' >> test.txt"}))
+
+	envBytes, err := os.ReadFile(step2)
+	require.NoError(t, err)
+	env := dsse.Envelope{}
+	require.NoError(t, json.Unmarshal(envBytes, &env))
+	stmt := intoto.Statement{}
+	require.NoError(t, json.Unmarshal(env.Payload, &stmt))
+	assert.Equal(t, detached.PredicateType, stmt.PredicateType)
+	assert.NotContains(t, string(env.Payload), "echo 'test02'")
+	assert.NotEmpty(t, stmt.Subject)
+
+	collectionBytes, err := os.ReadFile(predicatePath)
+	require.NoError(t, err)
+	link, err := detached.Parse(stmt.Predicate)
+	require.NoError(t, err)
+	assert.Equal(t, "step02", link.Name)
+	assert.Equal(t, attestation.CollectionType, link.PredicateType)
+	expected, err := cryptoutil.CalculateDigestSetFromBytes(collectionBytes, []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+	assert.True(t, expected.Equal(link.Digest))
+
+	vo := f.verifyOptions(f.policyPubPath, step1, step2)
+	vo.AdditionalSubjects = []string{step1Digest[crypto.SHA256]}
+	err = runVerify(context.Background(), vo)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is detached")
+
+	vo.DetachedPredicatePaths = []string{predicatePath}
+	require.NoError(t, runVerify(context.Background(), vo))
+
+	tampered := filepath.Join(t.TempDir(), "tampered.json")
+	require.NoError(t, os.WriteFile(tampered, append(collectionBytes, ' '), 0644))
+	vo.DetachedPredicatePaths = []string{tampered}
+	err = runVerify(context.Background(), vo)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no body matching")
+}
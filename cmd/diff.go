@@ -0,0 +1,281 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/attestation/environment"
+	"github.com/testifysec/go-witness/attestation/material"
+	"github.com/testifysec/go-witness/attestation/product"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/witness/options"
+)
+
+func DiffCmd() *cobra.Command {
+	do := options.DiffOptions{}
+	cmd := &cobra.Command{
+		Use:   "diff [attestation file] [attestation file]",
+		Short: "Shows how two attestation collections differ",
+		Long: "Structurally diffs two attestation collections, given as signed envelopes or bare collections, ignoring " +
+			"signatures and timestamps. Changed materials, products, and environment variables are listed first, then " +
+			"every other attestation field that changed. Exits with code 1 if the collections differ",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDiff(cmd.OutOrStdout(), do, args[0], args[1])
+		},
+		Args: cobra.ExactArgs(2),
+	}
+
+	do.AddFlags(cmd)
+	return cmd
+}
+
+// diffSection is the changes to one part of the collections, named by their path
+type diffSection struct {
+	title string
+	diffs []artifactDiff
+}
+
+func runDiff(out io.Writer, do options.DiffOptions, firstPath, secondPath string) error {
+	first, err := loadCollectionFile(firstPath)
+	if err != nil {
+		return err
+	}
+
+	second, err := loadCollectionFile(secondPath)
+	if err != nil {
+		return err
+	}
+
+	sections := diffCollections(first, second, do.Ignore)
+	changes := 0
+	if first.Name != second.Name {
+		changes++
+		fmt.Fprintf(out, "Step: %v != %v\n\n", first.Name, second.Name)
+	}
+
+	for _, section := range sections {
+		changes += len(section.diffs)
+	}
+
+	if changes == 0 {
+		fmt.Fprintln(out, "Collections are identical, ignoring signatures and timestamps")
+		return nil
+	}
+
+	printDiffSections(out, sections)
+	return fmt.Errorf("collections differ in %d places", changes)
+}
+
+// loadCollectionFile reads an attestation collection from a signed envelope, without verifying it, or from a bare
+// collection
+func loadCollectionFile(path string) (attestation.Collection, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return attestation.Collection{}, fmt.Errorf("failed to read attestation file: %w", err)
+	}
+
+	env := dsse.Envelope{}
+	if err := json.Unmarshal(b, &env); err == nil && env.PayloadType != "" {
+		collectionEnv, err := envelopeToCollectionEnvelope(path, env, nil, nil)
+		if err != nil {
+			return attestation.Collection{}, fmt.Errorf("failed to parse collection %v: %w", path, err)
+		}
+
+		return collectionEnv.Collection, nil
+	}
+
+	collection := attestation.Collection{}
+	if err := json.Unmarshal(b, &collection); err != nil {
+		return collection, fmt.Errorf("failed to parse %v as an envelope or collection: %w", path, err)
+	}
+
+	return collection, nil
+}
+
+// diffCollections returns the changed materials, products, environment, and other attestation fields of two
+// collections. The material, product, and environment attestations are only diffed in their own sections.
+func diffCollections(first, second attestation.Collection, ignore []string) []diffSection {
+	firstEnv, secondEnv := collectionEnvironment(first), collectionEnvironment(second)
+	return []diffSection{
+		{"Materials", changedValues(material.Name, collectionMaterials(first), collectionMaterials(second), ignore)},
+		{"Products", changedValues(product.Name, collectionProducts(first), collectionProducts(second), ignore)},
+		{"Environment", changedValues(environment.Name, firstEnv, secondEnv, ignore)},
+		{"Attestations", diffAttestations(attestationFields(first), attestationFields(second), ignore)},
+	}
+}
+
+// diffAttestations returns the changed fields of attestations in both collections, and a single change for each
+// attestation that's only in one of them
+func diffAttestations(first, second map[string]map[string]string, ignore []string) []artifactDiff {
+	names := make(map[string]string)
+	for name := range first {
+		names[name] = diffOnlyFirst
+	}
+
+	for name := range second {
+		if _, ok := names[name]; ok {
+			names[name] = diffChanged
+		} else {
+			names[name] = diffOnlySec
+		}
+	}
+
+	changed := []artifactDiff{}
+	for name, status := range names {
+		if ignoredPath(name, ignore) {
+			continue
+		}
+
+		switch status {
+		case diffOnlyFirst:
+			changed = append(changed, artifactDiff{name: name, status: status, first: "present"})
+		case diffOnlySec:
+			changed = append(changed, artifactDiff{name: name, status: status, second: "present"})
+		default:
+			changed = append(changed, changedValues("", first[name], second[name], ignore)...)
+		}
+	}
+
+	sort.Slice(changed, func(i, j int) bool { return changed[i].name < changed[j].name })
+	return changed
+}
+
+// changedValues returns the values that differ, named by their path under prefix, leaving out ignored paths
+func changedValues(prefix string, first, second map[string]string, ignore []string) []artifactDiff {
+	changed := []artifactDiff{}
+	for _, diff := range diffValues(first, second) {
+		if prefix != "" {
+			diff.name = prefix + "." + diff.name
+		}
+
+		if diff.status == diffIdentical || ignoredPath(diff.name, ignore) {
+			continue
+		}
+
+		changed = append(changed, diff)
+	}
+
+	return changed
+}
+
+// ignoredPath is true if path is one of ignore, or a field or element under one of them
+func ignoredPath(path string, ignore []string) bool {
+	for _, i := range ignore {
+		if path == i || strings.HasPrefix(path, i+".") || strings.HasPrefix(path, i+"[") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// attestationFields flattens every attestation other than the material, product, and environment attestations into
+// their JSON fields, by attestor name. Fields are named by the attestor's name and the field's path.
+func attestationFields(collection attestation.Collection) map[string]map[string]string {
+	attestations := make(map[string]map[string]string)
+	for _, att := range collection.Attestations {
+		switch att.Type {
+		case material.Type, product.Type, environment.Type:
+			continue
+		}
+
+		name := att.Type
+		if att.Attestation != nil {
+			name = att.Attestation.Name()
+		}
+
+		fields := make(map[string]string)
+		attestations[name] = fields
+		b, err := json.Marshal(att.Attestation)
+		if err != nil {
+			fields[name] = fmt.Sprintf("unreadable attestation: %v", err)
+			continue
+		}
+
+		var v interface{}
+		if err := json.Unmarshal(b, &v); err != nil {
+			fields[name] = fmt.Sprintf("unreadable attestation: %v", err)
+			continue
+		}
+
+		flattenJSON(name, v, fields)
+	}
+
+	return attestations
+}
+
+// flattenJSON adds each scalar under v to fields, named by its path from prefix. Empty objects and arrays are kept
+// so they aren't lost.
+func flattenJSON(prefix string, v interface{}, fields map[string]string) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			fields[prefix] = "{}"
+		}
+
+		for key, value := range v {
+			flattenJSON(prefix+"."+key, value, fields)
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			fields[prefix] = "[]"
+		}
+
+		for i, value := range v {
+			flattenJSON(fmt.Sprintf("%v[%d]", prefix, i), value, fields)
+		}
+	default:
+		buf := &bytes.Buffer{}
+		enc := json.NewEncoder(buf)
+		enc.SetEscapeHTML(false)
+		_ = enc.Encode(v)
+		fields[prefix] = strings.TrimSuffix(buf.String(), "\n")
+	}
+}
+
+func printDiffSections(out io.Writer, sections []diffSection) {
+	printed := 0
+	for _, section := range sections {
+		if len(section.diffs) == 0 {
+			continue
+		}
+
+		if printed > 0 {
+			fmt.Fprintln(out)
+		}
+
+		printed++
+		fmt.Fprintf(out, "%v:\n", section.title)
+		tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		for _, diff := range section.diffs {
+			fmt.Fprintf(tw, "  %v\t%v\t%v != %v\n", diff.status, diff.name, orDash(diff.first), orDash(diff.second))
+		}
+
+		tw.Flush()
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+	"github.com/testifysec/witness/options"
+)
+
+func TestDiff(t *testing.T) {
+	priv, _ := rsakeypair(t)
+	attestationDir := t.TempDir()
+	build := func(name, content, envValue string) string {
+		t.Setenv("WITNESS_DIFF_TEST", envValue)
+		outPath := filepath.Join(attestationDir, name+".json")
+		runOptions := options.RunOptions{
+			KeyOptions:   options.KeyOptions{KeyPath: priv.Name()},
+			WorkingDir:   t.TempDir(),
+			Attestations: []string{"environment"},
+			OutFilePath:  outPath,
+			StepName:     "build",
+		}
+
+		require.NoError(t, runRun(context.Background(), runOptions, []string{"bash", "-c", "echo '" + content + "' > app.bin"}))
+		return outPath
+	}
+
+	first := build("first", "app", "yesterday")
+	second := build("second", "changed", "today")
+
+	out := &bytes.Buffer{}
+	require.NoError(t, runDiff(out, options.DiffOptions{}, first, first))
+	assert.Contains(t, out.String(), "Collections are identical")
+
+	out.Reset()
+	err := runDiff(out, options.DiffOptions{}, first, second)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "collections differ")
+	assert.Regexp(t, `differs\s+product.app.bin`, out.String())
+	assert.Regexp(t, `differs\s+environment.\$WITNESS_DIFF_TEST\s+yesterday != today`, out.String())
+	assert.Regexp(t, `differs\s+command-run.cmd\[2\]`, out.String())
+	assert.Contains(t, out.String(), `"echo 'app' > app.bin" != "echo 'changed' > app.bin"`)
+	assert.NotContains(t, out.String(), "starttime")
+	assert.NotContains(t, out.String(), "Materials:")
+
+	// bare collections can be diffed, and ignored fields are left out
+	env := dsse.Envelope{}
+	b, err := os.ReadFile(second)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(b, &env))
+	stmt := intoto.Statement{}
+	require.NoError(t, json.Unmarshal(env.Payload, &stmt))
+	collectionPath := filepath.Join(attestationDir, "collection.json")
+	require.NoError(t, os.WriteFile(collectionPath, stmt.Predicate, 0644))
+
+	out.Reset()
+	require.NoError(t, runDiff(out, options.DiffOptions{}, second, collectionPath))
+
+	out.Reset()
+	require.NoError(t, runDiff(out, options.DiffOptions{Ignore: []string{"product", "environment.$WITNESS_DIFF_TEST", "command-run"}}, first, second))
+}
@@ -0,0 +1,202 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/disclosure"
+)
+
+func DiscloseCmd() *cobra.Command {
+	do := options.DiscloseOptions{}
+	cmd := &cobra.Command{
+		Use:   "disclose [envelope file]",
+		Short: "Discloses selected fields of an attestation signed with witness run --disclosable",
+		Long: "Writes the signed envelope with the selected fields of its collection and proofs that they're part of the " +
+			"signed Merkle root, so evidence can be shared with third parties without disclosing the rest of the collection",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDisclose(cmd.OutOrStdout(), do, args[0])
+		},
+		Args: cobra.ExactArgs(1),
+	}
+
+	do.AddFlags(cmd)
+	cmd.AddCommand(DiscloseVerifyCmd())
+	return cmd
+}
+
+func DiscloseVerifyCmd() *cobra.Command {
+	dvo := options.DiscloseVerifyOptions{}
+	cmd := &cobra.Command{
+		Use:               "verify [disclosure file]",
+		Short:             "Verifies the signature and proofs of a disclosure and prints the disclosed fields",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDiscloseVerify(cmd.OutOrStdout(), dvo, args[0])
+		},
+		Args: cobra.ExactArgs(1),
+	}
+
+	dvo.AddFlags(cmd)
+	return cmd
+}
+
+func runDisclose(out io.Writer, do options.DiscloseOptions, envelopePath string) error {
+	if do.BundlePath == "" {
+		return fmt.Errorf("must supply the disclosure bundle with --bundle")
+	}
+
+	bundle, err := disclosure.LoadBundle(do.BundlePath)
+	if err != nil {
+		return err
+	}
+
+	if do.List {
+		leaves, err := bundle.Leaves()
+		if err != nil {
+			return err
+		}
+
+		for _, leaf := range leaves {
+			fmt.Fprintln(out, leaf.Path)
+		}
+
+		return nil
+	}
+
+	if len(do.Fields) == 0 {
+		return fmt.Errorf("must supply at least one field to disclose")
+	}
+
+	envBytes, err := os.ReadFile(envelopePath)
+	if err != nil {
+		return fmt.Errorf("failed to read envelope: %w", err)
+	}
+
+	env := dsse.Envelope{}
+	if err := json.Unmarshal(envBytes, &env); err != nil {
+		return fmt.Errorf("failed to parse envelope: %w", err)
+	}
+
+	signed, err := disclosablePredicate(env)
+	if err != nil {
+		return err
+	}
+
+	bundled, err := bundle.SignedPredicate()
+	if err != nil {
+		return err
+	}
+
+	if bundled.Root != signed.Root {
+		return fmt.Errorf("disclosure bundle is not for the predicate signed in %v", envelopePath)
+	}
+
+	fields, err := bundle.Disclose(do.Fields)
+	if err != nil {
+		return err
+	}
+
+	d := disclosure.Disclosure{Envelope: env, Fields: fields}
+	if do.OutFilePath == "" {
+		return json.NewEncoder(out).Encode(d)
+	}
+
+	f, err := loadOutfile(do.OutFilePath)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+	return json.NewEncoder(f).Encode(d)
+}
+
+func runDiscloseVerify(out io.Writer, dvo options.DiscloseVerifyOptions, path string) error {
+	if dvo.KeyPath == "" {
+		return fmt.Errorf("must supply the public key the attestation was signed with")
+	}
+
+	keyFile, err := os.Open(dvo.KeyPath)
+	if err != nil {
+		return fmt.Errorf("failed to open key file: %w", err)
+	}
+
+	defer keyFile.Close()
+	verifier, err := cryptoutil.NewVerifierFromReader(keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to create verifier: %w", err)
+	}
+
+	disclosureBytes, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read disclosure: %w", err)
+	}
+
+	d := disclosure.Disclosure{}
+	if err := json.Unmarshal(disclosureBytes, &d); err != nil {
+		return fmt.Errorf("failed to parse disclosure: %w", err)
+	}
+
+	if _, err := d.Envelope.Verify(dsse.VerifyWithVerifiers(verifier)); err != nil {
+		return withExitCode(ExitCodeSignature, fmt.Errorf("disclosure is not signed by a trusted key: %w", err))
+	}
+
+	signed, err := disclosablePredicate(d.Envelope)
+	if err != nil {
+		return err
+	}
+
+	if err := disclosure.VerifyFields(signed, d.Fields); err != nil {
+		return withExitCode(ExitCodePolicy, err)
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "Name:\t%v\n", signed.Name)
+	fmt.Fprintf(tw, "Predicate type:\t%v\n", signed.PredicateType)
+	for _, field := range d.Fields {
+		fmt.Fprintf(tw, "%v\t%s\n", field.Path, field.Value)
+	}
+
+	return tw.Flush()
+}
+
+// disclosablePredicate returns the disclosable predicate signed in env
+func disclosablePredicate(env dsse.Envelope) (disclosure.Predicate, error) {
+	statement := intoto.Statement{}
+	if err := json.Unmarshal(env.Payload, &statement); err != nil {
+		return disclosure.Predicate{}, fmt.Errorf("failed to parse statement: %w", err)
+	}
+
+	if statement.PredicateType != disclosure.PredicateType {
+		return disclosure.Predicate{}, fmt.Errorf("%v is not a disclosable predicate", statement.PredicateType)
+	}
+
+	return disclosure.Parse(statement.Predicate)
+}
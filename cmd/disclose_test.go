@@ -0,0 +1,107 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/disclosure"
+)
+
+func TestDisclose(t *testing.T) {
+	f := newVerifyFixture(t)
+	step1 := f.run(t, "step01", f.funcPrivPath, "echo 'test01' > test.txt")
+	step1Digest, err := cryptoutil.CalculateDigestSetFromFile(f.artifactPath, []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+
+	step2 := filepath.Join(t.TempDir(), "step02.json")
+	bundlePath := filepath.Join(t.TempDir(), "step02.bundle.json")
+	ro := options.RunOptions{
+		KeyOptions:          options.KeyOptions{KeyPath: f.funcPrivPath},
+		WorkingDir:          f.workingDir,
+		OutFilePath:         step2,
+		StepName:            "step02",
+		DetachPredicatePath: bundlePath,
+		Disclosable:         true,
+	}
+	require.NoError(t, runRun(context.Background(), ro, []string{"bash", "-c", "echo 'test02' >> test.txt"}))
+
+	envBytes, err := os.ReadFile(step2)
+	require.NoError(t, err)
+	assert.NotContains(t, string(envBytes), "test02")
+
+	vo := f.verifyOptions(f.policyPubPath, step1, step2)
+	vo.AdditionalSubjects = []string{step1Digest[crypto.SHA256]}
+	require.Error(t, runVerify(context.Background(), vo))
+	vo.DetachedPredicatePaths = []string{bundlePath}
+	require.NoError(t, runVerify(context.Background(), vo))
+
+	list := bytes.Buffer{}
+	require.NoError(t, runDisclose(&list, options.DiscloseOptions{BundlePath: bundlePath, List: true}, step2))
+	cmdPath := ""
+	for _, path := range strings.Split(list.String(), "\n") {
+		if strings.HasSuffix(path, "/attestation/cmd/2") {
+			cmdPath = path
+		}
+	}
+
+	require.NotEmpty(t, cmdPath, list.String())
+	disclosurePath := filepath.Join(t.TempDir(), "disclosure.json")
+	require.NoError(t, runDisclose(nil, options.DiscloseOptions{BundlePath: bundlePath, Fields: []string{cmdPath, "/name"}, OutFilePath: disclosurePath}, step2))
+
+	funcPriv, err := os.Open(f.funcPrivPath)
+	require.NoError(t, err)
+	defer funcPriv.Close()
+	signer, err := cryptoutil.NewSignerFromReader(funcPriv)
+	require.NoError(t, err)
+	verifier, err := signer.Verifier()
+	require.NoError(t, err)
+	funcPub, err := verifier.Bytes()
+	require.NoError(t, err)
+	funcPubPath := filepath.Join(t.TempDir(), "func-pub.pem")
+	require.NoError(t, os.WriteFile(funcPubPath, funcPub, 0644))
+
+	out := bytes.Buffer{}
+	require.NoError(t, runDiscloseVerify(&out, options.DiscloseVerifyOptions{KeyPath: funcPubPath}, disclosurePath))
+	assert.Contains(t, out.String(), "test02")
+	assert.Contains(t, out.String(), `"step02"`)
+
+	err = runDiscloseVerify(&out, options.DiscloseVerifyOptions{KeyPath: f.policyPubPath}, disclosurePath)
+	require.Error(t, err)
+	assert.Equal(t, ExitCodeSignature, ExitCode(err))
+
+	disclosureBytes, err := os.ReadFile(disclosurePath)
+	require.NoError(t, err)
+	d := disclosure.Disclosure{}
+	require.NoError(t, json.Unmarshal(disclosureBytes, &d))
+	d.Fields[0].Value = json.RawMessage(`"echo 'tampered'"`)
+	tamperedBytes, err := json.Marshal(d)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(disclosurePath, tamperedBytes, 0644))
+	err = runDiscloseVerify(&out, options.DiscloseVerifyOptions{KeyPath: funcPubPath}, disclosurePath)
+	require.Error(t, err)
+	assert.Equal(t, ExitCodePolicy, ExitCode(err))
+}
@@ -0,0 +1,460 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/testifysec/go-witness/archivist"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/timestamp"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/ebpftrace"
+	"github.com/testifysec/witness/pkg/ghattest"
+	"github.com/testifysec/witness/pkg/localstore"
+	"github.com/testifysec/witness/pkg/rekor"
+	"github.com/testifysec/witness/pkg/runner"
+	"github.com/testifysec/witness/pkg/spool"
+	"github.com/testifysec/witness/pkg/transport"
+)
+
+func DoctorCmd() *cobra.Command {
+	do := options.DoctorOptions{}
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Checks that witness run can sign and store attestations on this host",
+		Long: "Checks the signing keys, Archivist servers, timestamp authorities, registries, and other backends " +
+			"configured for witness run, and whether commands can be traced on this host, and explains how to fix " +
+			"what doesn't work. Takes the same flags as witness run and reads the run section of the config file",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDoctor(cmd.Context(), cmd.OutOrStdout(), do)
+		},
+		Args: cobra.NoArgs,
+	}
+
+	do.AddFlags(cmd)
+	return cmd
+}
+
+const (
+	doctorOK   = "OK"
+	doctorWarn = "WARN"
+	doctorFail = "FAIL"
+)
+
+// doctorCheck is the result of checking one backend or capability, with a hint of how to fix it if it failed
+type doctorCheck struct {
+	name   string
+	status string
+	detail string
+	hint   string
+}
+
+func runDoctor(ctx context.Context, out io.Writer, do options.DoctorOptions) error {
+	checks := doctorChecks(ctx, do)
+	failed := printDoctorChecks(out, checks)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(checks))
+	}
+
+	return nil
+}
+
+func doctorChecks(ctx context.Context, do options.DoctorOptions) []doctorCheck {
+	checkCtx := func() (context.Context, context.CancelFunc) {
+		if do.CheckTimeout <= 0 {
+			return context.WithCancel(ctx)
+		}
+
+		return context.WithTimeout(ctx, do.CheckTimeout)
+	}
+
+	runOptions := do.RunOptions
+	checks := checkSigners(ctx, runOptions)
+	if runOptions.KeyOptions.FulcioURL != "" {
+		c, cancel := checkCtx()
+		checks = append(checks, checkReachable(c, "fulcio "+runOptions.KeyOptions.FulcioURL, runOptions.KeyOptions.FulcioURL, "check --fulcio and that this host can reach it"))
+		cancel()
+	}
+
+	stores := 0
+	if runOptions.ArchivistOptions.Enable {
+		for _, server := range runOptions.ArchivistOptions.Urls {
+			stores++
+			c, cancel := checkCtx()
+			checks = append(checks, checkArchivist(c, server))
+			cancel()
+		}
+	}
+
+	if runOptions.GitHubOptions.Repo != "" {
+		stores++
+		c, cancel := checkCtx()
+		checks = append(checks, checkGitHub(c, runOptions.GitHubOptions))
+		cancel()
+	}
+
+	if runOptions.SCITTOptions.Server != "" {
+		stores++
+		c, cancel := checkCtx()
+		checks = append(checks, checkReachable(c, "scitt "+runOptions.SCITTOptions.Server, runOptions.SCITTOptions.Server, "check --scitt-server and that this host can reach it"))
+		cancel()
+	}
+
+	if runOptions.RekorOptions.Server != "" {
+		stores++
+		c, cancel := checkCtx()
+		checks = append(checks, checkReachable(c, "rekor "+runOptions.RekorOptions.Server, runOptions.RekorOptions.Server, "check --rekor-server and that this host can reach it"))
+		cancel()
+		checks = append(checks, checkRekorPublicKey(runOptions.RekorOptions.PublicKeyPath))
+	}
+
+	if stores == 0 && !runOptions.LocalStoreOptions.Enable {
+		checks = append(checks, doctorCheck{
+			name:   "storage",
+			status: doctorWarn,
+			detail: "attestations are only written to --outfile",
+			hint:   "set --enable-archivist, --github-attestations-repo, or --local-store to keep attestations",
+		})
+	}
+
+	if runOptions.LocalStoreOptions.Enable {
+		checks = append(checks, checkWritable("local store", func() (string, error) {
+			store, err := localstore.Open(runOptions.LocalStoreOptions.StoreDirOptions.Dir)
+			if err != nil {
+				return "", err
+			}
+
+			return store.Dir(), nil
+		}))
+	}
+
+	if runOptions.AsyncUpload || runOptions.StoreFailurePolicy == "retry-later" {
+		checks = append(checks, checkWritable("spool", func() (string, error) {
+			s, err := spool.Open(runOptions.SpoolOptions.Dir)
+			if err != nil {
+				return "", err
+			}
+
+			return s.Dir(), nil
+		}))
+	}
+
+	for _, server := range runOptions.TimestampServers {
+		c, cancel := checkCtx()
+		checks = append(checks, checkTimestampAuthority(c, server))
+		cancel()
+	}
+
+	for _, ref := range do.Registries {
+		c, cancel := checkCtx()
+		checks = append(checks, checkRegistry(c, ref))
+		cancel()
+	}
+
+	if runOptions.InContainer != "" {
+		checks = append(checks, checkContainerRuntime(runOptions.ContainerRuntime))
+	}
+
+	return append(checks, checkTracing(runOptions)...)
+}
+
+// checkSigners loads each configured signer and signs a test message with it. Fulcio signers need an interactive
+// login, so only the Fulcio server is checked.
+func checkSigners(ctx context.Context, runOptions options.RunOptions) []doctorCheck {
+	ko := runOptions.KeyOptions
+	ko.FulcioURL = ""
+	if ko.KeyPath == "" && ko.SpiffePath == "" {
+		if runOptions.KeyOptions.FulcioURL != "" {
+			return nil
+		}
+
+		if runOptions.EphemeralSigner {
+			return []doctorCheck{{name: "signer", status: doctorOK, detail: "a key is generated for each run"}}
+		}
+
+		return []doctorCheck{{name: "signer", status: doctorFail, detail: "no signer is configured", hint: "set --key, --spiffe-socket, --fulcio, or --signer-ephemeral"}}
+	}
+
+	signers, errs := runner.LoadSigners(ctx, ko, ro.FIPS)
+	checks := []doctorCheck{}
+	for _, err := range errs {
+		checks = append(checks, doctorCheck{name: "signer", status: doctorFail, detail: err.Error(), hint: "check the key and certificate paths and that the key isn't encrypted"})
+	}
+
+	for _, signer := range signers {
+		check := doctorCheck{name: "signer", status: doctorOK}
+		if err := checkSigner(signer); err != nil {
+			check.status, check.detail = doctorFail, err.Error()
+		} else if keyID, err := signer.KeyID(); err == nil {
+			check.detail = "signs and verifies with key id " + keyID
+		}
+
+		checks = append(checks, check)
+		if ko.PQKeyPath != "" {
+			pq := doctorCheck{name: "post-quantum key", status: doctorOK, detail: ko.PQKeyPath}
+			if _, err := runner.EnvelopeSigners(ko, signer, ro.FIPS); err != nil {
+				pq.status, pq.detail, pq.hint = doctorFail, err.Error(), "create a key with witness pq-keygen"
+			}
+
+			checks = append(checks, pq)
+		}
+	}
+
+	return checks
+}
+
+func checkSigner(signer cryptoutil.Signer) error {
+	message := []byte("witness doctor")
+	sig, err := signer.Sign(bytes.NewReader(message))
+	if err != nil {
+		return fmt.Errorf("failed to sign: %w", err)
+	}
+
+	verifier, err := signer.Verifier()
+	if err != nil {
+		return fmt.Errorf("failed to get verifier: %w", err)
+	}
+
+	// certificate verifiers also check the chain, which needs the policy's roots, so only the key is checked here
+	if x509Verifier, ok := verifier.(*cryptoutil.X509Verifier); ok {
+		if verifier, err = cryptoutil.NewVerifier(x509Verifier.Certificate().PublicKey); err != nil {
+			return fmt.Errorf("failed to get verifier: %w", err)
+		}
+	}
+
+	if err := verifier.Verify(bytes.NewReader(message), sig); err != nil {
+		return fmt.Errorf("signature doesn't verify: %w", err)
+	}
+
+	return nil
+}
+
+// checkArchivist runs a search that matches nothing, which exercises the same API verification uses
+func checkArchivist(ctx context.Context, server string) doctorCheck {
+	check := doctorCheck{name: "archivist " + server, status: doctorOK, detail: "search succeeded"}
+	resolved, err := transport.ResolveURL(server)
+	if err == nil {
+		_, err = archivist.New(resolved).SearchGitoids(ctx, archivist.SearchGitoidVariables{
+			SubjectDigests: []string{"0000000000000000000000000000000000000000000000000000000000000000"},
+			Attestations:   []string{},
+			ExcludeGitoids: []string{},
+		})
+	}
+
+	if err != nil {
+		check.status, check.detail = doctorFail, err.Error()
+		check.hint = "check --archivist-server, that this host can reach it, and any proxy set with HTTPS_PROXY"
+	}
+
+	return check
+}
+
+func checkGitHub(ctx context.Context, o options.GitHubOptions) doctorCheck {
+	check := doctorCheck{name: "github " + o.Repo, status: doctorOK}
+	token := o.Token
+	if token == "" {
+		token = os.Getenv("GITHUB_TOKEN")
+	}
+
+	if token == "" {
+		check.status, check.detail, check.hint = doctorFail, "no token is set", "set --github-token or GITHUB_TOKEN with the attestations: write permission"
+		return check
+	}
+
+	repoURL, err := ghattest.RepoURL(o.APIURL, o.Repo)
+	if err != nil {
+		check.status, check.detail = doctorFail, err.Error()
+		return check
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, repoURL, nil)
+	if err != nil {
+		check.status, check.detail = doctorFail, err.Error()
+		return check
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		check.status, check.detail, check.hint = doctorFail, err.Error(), "check --github-api-url and that this host can reach it"
+		return check
+	}
+
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		check.detail = "token can read the repository"
+	case resp.StatusCode == http.StatusUnauthorized:
+		check.status, check.detail, check.hint = doctorFail, "token was rejected", "check the token hasn't expired"
+	default:
+		check.status, check.detail, check.hint = doctorFail, fmt.Sprintf("repository request failed with status %v", resp.Status), "check --github-attestations-repo and that the token can access it"
+	}
+
+	return check
+}
+
+// checkTimestampAuthority requests a timestamp, as signing does
+func checkTimestampAuthority(ctx context.Context, server string) doctorCheck {
+	check := doctorCheck{name: "timestamp authority " + server, status: doctorOK, detail: "issued a timestamp"}
+	timestamper := timestamp.NewTimestamper(timestamp.TimestampWithUrl(server))
+	if _, err := timestamper.Timestamp(ctx, bytes.NewReader([]byte("witness doctor"))); err != nil {
+		check.status, check.detail, check.hint = doctorFail, err.Error(), "check --timestamp-servers and that this host can reach it"
+	}
+
+	return check
+}
+
+func checkRegistry(ctx context.Context, ref string) doctorCheck {
+	check := doctorCheck{name: "registry " + ref, status: doctorOK, detail: "read the manifest"}
+	parsed, err := parseOCIReference(ref)
+	if err != nil {
+		check.status, check.detail = doctorFail, err.Error()
+		return check
+	}
+
+	if _, _, err := newRegistryClient(parsed).Manifest(ctx); err != nil {
+		check.status, check.detail, check.hint = doctorFail, err.Error(), "check the reference exists and that docker is logged in to the registry if it requires credentials"
+	}
+
+	return check
+}
+
+// checkRekorPublicKey checks the key the Rekor log's entries are verified with loads
+func checkRekorPublicKey(path string) doctorCheck {
+	check := doctorCheck{name: "rekor public key", status: doctorOK, detail: "loaded"}
+	if path == "" {
+		check.status, check.detail, check.hint = doctorFail, "no public key for the log", "set --rekor-public-key to the log's public key"
+		return check
+	}
+
+	if _, err := rekor.LoadPublicKey(path); err != nil {
+		check.status, check.detail, check.hint = doctorFail, err.Error(), "check --rekor-public-key is the log's PEM public key"
+	}
+
+	return check
+}
+
+// checkReachable checks server answers HTTP requests. Any response will do, since these servers are only checked
+// for connectivity.
+func checkReachable(ctx context.Context, name, server, hint string) doctorCheck {
+	check := doctorCheck{name: name, status: doctorOK, detail: "reachable"}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server, nil)
+	if err != nil {
+		check.status, check.detail = doctorFail, err.Error()
+		return check
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		check.status, check.detail, check.hint = doctorFail, err.Error(), hint
+		return check
+	}
+
+	resp.Body.Close()
+	return check
+}
+
+// checkWritable checks a directory witness writes to can be opened and written
+func checkWritable(name string, open func() (string, error)) doctorCheck {
+	check := doctorCheck{name: name, status: doctorOK}
+	dir, err := open()
+	if err == nil {
+		var f *os.File
+		if f, err = os.CreateTemp(dir, ".doctor-*"); err == nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}
+
+	if err != nil {
+		check.status, check.detail, check.hint = doctorFail, err.Error(), "check the directory exists and is writable"
+		return check
+	}
+
+	check.detail = filepath.Clean(dir) + " is writable"
+	return check
+}
+
+func checkContainerRuntime(runtime string) doctorCheck {
+	if runtime == "" {
+		runtime = "docker"
+	}
+
+	check := doctorCheck{name: "container runtime", status: doctorOK}
+	path, err := exec.LookPath(runtime)
+	if err != nil {
+		check.status, check.detail, check.hint = doctorFail, err.Error(), "install "+runtime+" or set --container-runtime"
+		return check
+	}
+
+	check.detail = path
+	return check
+}
+
+// checkTracing checks whether commands can be traced on this host. ptrace fails the check only when --trace is set.
+func checkTracing(runOptions options.RunOptions) []doctorCheck {
+	ptraceCheck := doctorCheck{name: "tracing (ptrace)", status: doctorOK, detail: "commands can be traced"}
+	if err := ptraceSupported(); err != nil {
+		ptraceCheck.status, ptraceCheck.detail = doctorWarn, err.Error()
+		ptraceCheck.hint = "run on Linux with ptrace allowed by the seccomp profile and kernel.yama.ptrace_scope below 3"
+		if runOptions.Tracing {
+			ptraceCheck.status = doctorFail
+		}
+	}
+
+	// the eBPF backend falls back to ptrace, so it only warns
+	ebpfCheck := doctorCheck{name: "tracing (ebpf)", status: doctorOK, detail: "programs load"}
+	if tracer, err := ebpftrace.New(); err != nil {
+		ebpfCheck.status, ebpfCheck.detail = doctorWarn, err.Error()
+		ebpfCheck.hint = "run as root or with CAP_BPF and CAP_PERFMON on Linux; --trace-backend ebpf falls back to ptrace"
+	} else {
+		tracer.Close()
+	}
+
+	return []doctorCheck{ptraceCheck, ebpfCheck}
+}
+
+// printDoctorChecks writes a table of the checks and returns the number that failed
+func printDoctorChecks(out io.Writer, checks []doctorCheck) int {
+	failed := 0
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tSTATUS\tDETAILS")
+	for _, check := range checks {
+		if check.status == doctorFail {
+			failed++
+		}
+
+		fmt.Fprintf(tw, "%v\t%v\t%v\n", check.name, check.status, check.detail)
+		if check.hint != "" && check.status != doctorOK {
+			fmt.Fprintf(tw, "\t\t-> %v\n", check.hint)
+		}
+	}
+
+	tw.Flush()
+	return failed
+}
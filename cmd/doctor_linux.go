@@ -0,0 +1,45 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package cmd
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"syscall"
+)
+
+// ptraceSupported starts a traced process, which fails if ptrace is forbidden by seccomp or the Yama LSM
+func ptraceSupported() error {
+	path, err := exec.LookPath("true")
+	if err != nil {
+		return fmt.Errorf("failed to find a command to trace: %w", err)
+	}
+
+	// the tracee is attached to the thread that starts it, so it has to be killed and waited on from the same thread
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	cmd := exec.Command(path)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Ptrace: true}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start a traced process: %w", err)
+	}
+
+	_ = cmd.Process.Kill()
+	_ = cmd.Wait()
+	return nil
+}
@@ -0,0 +1,82 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/witness/options"
+)
+
+func TestDoctor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/query":
+			fmt.Fprint(w, `{"data": {"dsses": {"edges": []}}}`)
+		case "/repos/owner/repo":
+			if r.Header.Get("Authorization") != "Bearer token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			fmt.Fprint(w, `{}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	priv, _ := rsakeypair(t)
+	do := options.DoctorOptions{RunOptions: options.RunOptions{
+		KeyOptions:        options.KeyOptions{KeyPath: priv.Name()},
+		ArchivistOptions:  options.ArchivistOptions{Enable: true, Urls: []string{server.URL}},
+		GitHubOptions:     options.GitHubOptions{Repo: "owner/repo", APIURL: server.URL, Token: "token"},
+		LocalStoreOptions: options.LocalStoreOptions{Enable: true, StoreDirOptions: options.StoreDirOptions{Dir: t.TempDir()}},
+	}}
+
+	checks := doctorChecks(context.Background(), do)
+	statuses := map[string]string{}
+	for _, check := range checks {
+		statuses[check.name] = check.status
+	}
+
+	assert.Equal(t, doctorOK, statuses["signer"])
+	assert.Equal(t, doctorOK, statuses["archivist "+server.URL])
+	assert.Equal(t, doctorOK, statuses["github owner/repo"])
+	assert.Equal(t, doctorOK, statuses["local store"])
+	assert.NotContains(t, statuses, "storage")
+	out := &bytes.Buffer{}
+	require.NoError(t, runDoctor(context.Background(), out, do))
+	assert.Contains(t, out.String(), "signs and verifies with key id")
+
+	do.RunOptions.ArchivistOptions.Urls = append(do.RunOptions.ArchivistOptions.Urls, "http://127.0.0.1:1")
+	do.RunOptions.GitHubOptions.Token = "expired"
+	do.RunOptions.KeyOptions.KeyPath = ""
+	out.Reset()
+	err := runDoctor(context.Background(), out, do)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "3 of")
+	assert.Regexp(t, `archivist http://127.0.0.1:1\s+FAIL`, out.String())
+	assert.Regexp(t, `github owner/repo\s+FAIL\s+token was rejected`, out.String())
+	assert.Regexp(t, `signer\s+FAIL\s+no signer is configured`, out.String())
+	assert.Contains(t, out.String(), "-> set --key")
+}
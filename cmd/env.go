@@ -0,0 +1,194 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/testifysec/witness/options"
+)
+
+const envPrefix = "WITNESS"
+
+func EnvCmd() *cobra.Command {
+	eo := options.EnvOptions{}
+	cmd := &cobra.Command{
+		Use:   "env",
+		Short: "Prints the environment variables that configure witness",
+		Long: "Prints the environment variable corresponding to every flag, with the flag's default value. " +
+			"Flags set on the command line take precedence over environment variables, which take precedence over the config file",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runEnv(cmd.OutOrStdout(), cmd.Root(), eo)
+		},
+	}
+
+	eo.AddFlags(cmd)
+	return cmd
+}
+
+// envVar is the environment variable that sets a flag. Persistent flags of the root command are shared by
+// every command, so they aren't namespaced by command.
+type envVar struct {
+	name string
+	flag *pflag.Flag
+}
+
+func flagEnvName(cmdName, flagName string) string {
+	parts := []string{envPrefix}
+	if cmdName != "" {
+		parts = append(parts, cmdName)
+	}
+
+	parts = append(parts, flagName)
+	return strings.ToUpper(strings.ReplaceAll(strings.Join(parts, "_"), "-", "_"))
+}
+
+// commandEnvVars returns the environment variables for a subcommand's own flags, sorted by name
+func commandEnvVars(cmd *cobra.Command) []envVar {
+	vars := []envVar{}
+	cmd.LocalNonPersistentFlags().VisitAll(func(f *pflag.Flag) {
+		vars = append(vars, envVar{name: flagEnvName(cmd.Name(), f.Name), flag: f})
+	})
+
+	sort.Slice(vars, func(i, j int) bool { return vars[i].name < vars[j].name })
+	return vars
+}
+
+// globalEnvVars returns the environment variables for the root command's persistent flags, sorted by name
+func globalEnvVars(root *cobra.Command) []envVar {
+	vars := []envVar{}
+	root.PersistentFlags().VisitAll(func(f *pflag.Flag) {
+		vars = append(vars, envVar{name: flagEnvName("", f.Name), flag: f})
+	})
+
+	sort.Slice(vars, func(i, j int) bool { return vars[i].name < vars[j].name })
+	return vars
+}
+
+// configurableCommands are the subcommands that have flags, sorted by name
+func configurableCommands(root *cobra.Command) []*cobra.Command {
+	commands := []*cobra.Command{}
+	for _, c := range root.Commands() {
+		if c.HasLocalFlags() && c.Name() != "help" {
+			commands = append(commands, c)
+		}
+	}
+
+	sort.Slice(commands, func(i, j int) bool { return commands[i].Name() < commands[j].Name() })
+	return commands
+}
+
+// applyEnv sets any flags that weren't set on the command line from their environment variables. Flags set
+// from the environment are marked as changed, so the config file doesn't override them.
+func applyEnv(root *cobra.Command) error {
+	vars := globalEnvVars(root)
+	for _, c := range root.Commands() {
+		vars = append(vars, commandEnvVars(c)...)
+	}
+
+	for _, v := range vars {
+		value, ok := os.LookupEnv(v.name)
+		if !ok || v.flag.Changed {
+			continue
+		}
+
+		if err := v.flag.Value.Set(value); err != nil {
+			return fmt.Errorf("invalid value for %v: %w", v.name, err)
+		}
+
+		v.flag.Changed = true
+	}
+
+	return nil
+}
+
+func flagDefault(f *pflag.Flag) string {
+	if strings.HasSuffix(f.Value.Type(), "Slice") || strings.HasSuffix(f.Value.Type(), "Array") {
+		return strings.TrimSuffix(strings.TrimPrefix(f.DefValue, "["), "]")
+	}
+
+	return f.DefValue
+}
+
+func writeEnvVars(w io.Writer, vars []envVar) {
+	for _, v := range vars {
+		fmt.Fprintf(w, "# --%v: %v\n", v.flag.Name, v.flag.Usage)
+		fmt.Fprintf(w, "%v=%v\n", v.name, flagDefault(v.flag))
+	}
+}
+
+func runEnv(w io.Writer, root *cobra.Command, eo options.EnvOptions) error {
+	commands := configurableCommands(root)
+	if eo.For != "" {
+		found := false
+		for _, c := range commands {
+			if c.Name() == eo.For {
+				commands = []*cobra.Command{c}
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("unknown command %v", eo.For)
+		}
+	}
+
+	writeEnvVars(w, globalEnvVars(root))
+	for _, c := range commands {
+		fmt.Fprintln(w)
+		writeEnvVars(w, commandEnvVars(c))
+	}
+
+	return nil
+}
+
+// WriteEnvDocs writes a markdown reference of the environment variable for every flag
+func WriteEnvDocs(w io.Writer, root *cobra.Command) {
+	fmt.Fprint(w, "# Environment Variables\n\n")
+	fmt.Fprint(w, "Every flag may also be set with an environment variable. Flags set on the command line take precedence over ")
+	fmt.Fprint(w, "environment variables, which take precedence over the config file. `witness env --for <command>` prints ")
+	fmt.Fprint(w, "the variables for a command with their defaults.\n")
+	writeEnvTable(w, "Global", globalEnvVars(root))
+	for _, c := range configurableCommands(root) {
+		writeEnvTable(w, fmt.Sprintf("witness %v", c.Name()), commandEnvVars(c))
+	}
+}
+
+func writeEnvTable(w io.Writer, title string, vars []envVar) {
+	fmt.Fprintf(w, "\n## %v\n\n", title)
+	fmt.Fprint(w, "| Variable | Flag | Default | Description |\n")
+	fmt.Fprint(w, "| -------- | ---- | ------- | ----------- |\n")
+	for _, v := range vars {
+		fmt.Fprintf(w, "| `%v` | `--%v` | %v | %v |\n", v.name, v.flag.Name, markdownDefault(flagDefault(v.flag)), strings.ReplaceAll(v.flag.Usage, "|", "\\|"))
+	}
+}
+
+func markdownDefault(def string) string {
+	if def == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("`%v`", def)
+}
@@ -0,0 +1,63 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/witness/options"
+)
+
+func TestRunEnv(t *testing.T) {
+	out := &bytes.Buffer{}
+	require.NoError(t, runEnv(out, New(), options.EnvOptions{For: "run"}))
+	assert.Contains(t, out.String(), "WITNESS_LOG_LEVEL=info\n")
+	assert.Contains(t, out.String(), "# --step: Name of the step being run\nWITNESS_RUN_STEP=\n")
+	assert.Contains(t, out.String(), "WITNESS_RUN_ATTESTATIONS=environment,git\n")
+	assert.NotContains(t, out.String(), "WITNESS_VERIFY_")
+
+	second := &bytes.Buffer{}
+	require.NoError(t, runEnv(second, New(), options.EnvOptions{For: "run"}))
+	assert.Equal(t, out.String(), second.String())
+
+	assert.Error(t, runEnv(out, New(), options.EnvOptions{For: "nope"}))
+}
+
+func TestApplyEnv(t *testing.T) {
+	t.Setenv("WITNESS_RUN_STEP", "build")
+	t.Setenv("WITNESS_RUN_ATTESTATIONS", "git,maven")
+	t.Setenv("WITNESS_RUN_OUTFILE", "from-env.json")
+	root := New()
+	run, _, err := root.Find([]string{"run"})
+	require.NoError(t, err)
+	require.NoError(t, run.Flags().Set("outfile", "from-flag.json"))
+
+	require.NoError(t, applyEnv(root))
+	step, err := run.Flags().GetString("step")
+	require.NoError(t, err)
+	assert.Equal(t, "build", step)
+	attestations, err := run.Flags().GetStringSlice("attestations")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"git", "maven"}, attestations)
+	outfile, err := run.Flags().GetString("outfile")
+	require.NoError(t, err)
+	assert.Equal(t, "from-flag.json", outfile)
+
+	t.Setenv("WITNESS_RUN_TRACE", "maybe")
+	assert.Error(t, applyEnv(New()))
+}
@@ -0,0 +1,188 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/policy"
+	"github.com/testifysec/go-witness/source"
+	"github.com/testifysec/witness/pkg/trust"
+)
+
+// Exit codes let CI systems tell why witness failed, for instance to retry infrastructure errors
+// but fail hard when a signature doesn't verify.
+const (
+	ExitCodeError               = 1
+	ExitCodeSignature           = 2
+	ExitCodePolicy              = 3
+	ExitCodeMissingAttestations = 4
+	ExitCodeInfrastructure      = 5
+)
+
+// exitError is an error that should make witness exit with code
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e exitError) Unwrap() error {
+	return e.err
+}
+
+// withExitCode makes witness exit with code if err causes it to fail
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return exitError{code: code, err: err}
+}
+
+// ExitCode returns the code witness should exit with after failing with err
+func ExitCode(err error) int {
+	exitErr := exitError{}
+	if errors.As(err, &exitErr) {
+		return exitErr.code
+	}
+
+	return ExitCodeError
+}
+
+// exitCodeSeverity orders the verify exit codes so the most serious failure decides the exit code when
+// several artifacts fail to verify. Infrastructure errors are least serious, since retrying may fix them.
+var exitCodeSeverity = map[int]int{
+	ExitCodeSignature:           4,
+	ExitCodePolicy:              3,
+	ExitCodeMissingAttestations: 2,
+	ExitCodeError:               1,
+	ExitCodeInfrastructure:      0,
+}
+
+// mostSevereExitCode returns the exit code of the most serious of errs
+func mostSevereExitCode(errs []error) int {
+	code := -1
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+
+		if errCode := ExitCode(err); code == -1 || exitCodeSeverity[errCode] > exitCodeSeverity[code] {
+			code = errCode
+		}
+	}
+
+	if code == -1 {
+		return ExitCodeError
+	}
+
+	return code
+}
+
+// evidenceRecorder remembers the collections found for each step during verification, so a denied policy can be
+// attributed to missing attestations, bad signatures or failed constraints. Errors from the underlying source
+// are infrastructure errors.
+type evidenceRecorder struct {
+	source source.Sourcer
+	mu     sync.Mutex
+	found  map[string][]source.CollectionEnvelope
+}
+
+func newEvidenceRecorder(s source.Sourcer) *evidenceRecorder {
+	return &evidenceRecorder{source: s, found: make(map[string][]source.CollectionEnvelope)}
+}
+
+func (r *evidenceRecorder) Search(ctx context.Context, collectionName string, subjectDigests, attestations []string) ([]source.CollectionEnvelope, error) {
+	envelopes, err := r.source.Search(ctx, collectionName, subjectDigests, attestations)
+	if err != nil {
+		return envelopes, withExitCode(ExitCodeInfrastructure, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.found[collectionName] = append(r.found[collectionName], envelopes...)
+	return envelopes, nil
+}
+
+// verifyExitCode classifies an error returned by witness.Verify. A denied policy is a missing attestation
+// failure if no collection was found for a step and a signature failure if none of a step's collections were
+// signed by a key or root the policy trusts. Otherwise the collections failed the policy's constraints.
+func verifyExitCode(err error, policyEnvelope dsse.Envelope, recorder *evidenceRecorder) int {
+	exitErr := exitError{}
+	if errors.As(err, &exitErr) {
+		return exitErr.code
+	}
+
+	if errors.As(err, &dsse.ErrNoSignatures{}) || errors.As(err, &dsse.ErrNoMatchingSigs{}) || errors.As(err, &dsse.ErrThresholdNotMet{}) {
+		return ExitCodeSignature
+	}
+
+	var expired policy.ErrPolicyExpired
+	if errors.As(err, &expired) {
+		return ExitCodePolicy
+	}
+
+	if !errors.As(err, &policy.ErrPolicyDenied{}) || recorder == nil {
+		return ExitCodeError
+	}
+
+	pol := policy.Policy{}
+	if err := json.Unmarshal(policyEnvelope.Payload, &pol); err != nil {
+		return ExitCodeError
+	}
+
+	trusted, err := trust.FromPolicy(pol)
+	if err != nil {
+		return ExitCodeError
+	}
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	missing, unsigned := false, false
+	for stepName := range pol.Steps {
+		envelopes := recorder.found[stepName]
+		if len(envelopes) == 0 {
+			missing = true
+			continue
+		}
+
+		signed := false
+		for _, env := range envelopes {
+			if _, err := env.Envelope.Verify(trusted.VerifyOpts...); err == nil {
+				signed = true
+				break
+			}
+		}
+
+		unsigned = unsigned || !signed
+	}
+
+	switch {
+	case unsigned:
+		return ExitCodeSignature
+	case missing:
+		return ExitCodeMissingAttestations
+	default:
+		return ExitCodePolicy
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/policy"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/compliance"
+)
+
+// complianceExporter writes verification results as compliance evidence mapped to controls
+type complianceExporter struct {
+	options.ExportOptions
+	controls compliance.ControlMap
+}
+
+// newComplianceExporter returns nil if no export format is set
+func newComplianceExporter(o options.ExportOptions) (*complianceExporter, error) {
+	if o.Format == "" {
+		return nil, nil
+	}
+
+	if o.Format != compliance.FormatOSCAL && o.Format != compliance.FormatCSV {
+		return nil, fmt.Errorf("unsupported export format %q, must be %v or %v", o.Format, compliance.FormatOSCAL, compliance.FormatCSV)
+	}
+
+	if o.Path == "" {
+		return nil, fmt.Errorf("an export file is required to export verification results")
+	}
+
+	controls := compliance.SSDF
+	if o.ControlMapPath != "" {
+		var err error
+		controls, err = compliance.LoadControlMap(o.ControlMapPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &complianceExporter{ExportOptions: o, controls: controls}, nil
+}
+
+// export writes the outcome of verifying targets to the export file
+func (e *complianceExporter) export(vo options.VerifyOptions, targets []verifyTarget) error {
+	report, err := complianceReport(vo.PolicyFilePath, targets)
+	if err != nil {
+		return fmt.Errorf("failed to export verification results: %w", err)
+	}
+
+	out, err := os.Create(e.Path)
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %w", err)
+	}
+
+	defer out.Close()
+	if e.Format == compliance.FormatCSV {
+		return compliance.WriteCSV(out, report, e.controls)
+	}
+
+	return compliance.WriteOSCAL(out, report, e.controls)
+}
+
+// complianceReport summarizes the verification of targets against the policy at policyPath
+func complianceReport(policyPath string, targets []verifyTarget) (compliance.Report, error) {
+	report := compliance.Report{
+		Time:       time.Now().UTC(),
+		PolicyPath: policyPath,
+	}
+
+	policyDigest, err := cryptoutil.CalculateDigestSetFromFile(policyPath, []crypto.Hash{crypto.SHA256})
+	if err != nil {
+		return report, fmt.Errorf("failed to calculate policy digest: %w", err)
+	}
+
+	report.PolicyDigest = policyDigest
+	policyBytes, err := os.ReadFile(policyPath)
+	if err != nil {
+		return report, err
+	}
+
+	policyEnvelope := dsse.Envelope{}
+	if err := json.Unmarshal(policyBytes, &policyEnvelope); err != nil {
+		return report, fmt.Errorf("could not unmarshal policy envelope: %w", err)
+	}
+
+	pol := policy.Policy{}
+	if err := json.Unmarshal(policyEnvelope.Payload, &pol); err != nil {
+		return report, fmt.Errorf("failed to parse policy: %w", err)
+	}
+
+	required := make(map[string]struct{})
+	for _, step := range pol.Steps {
+		for _, a := range step.Attestations {
+			required[a.Type] = struct{}{}
+		}
+	}
+
+	for attestationType := range required {
+		report.Required = append(report.Required, attestationType)
+	}
+
+	sort.Strings(report.Required)
+	for _, target := range targets {
+		artifact := compliance.Artifact{Name: target.name, Subjects: target.subjects, Passed: target.err == nil}
+		if target.err != nil {
+			artifact.Error = target.err.Error()
+		}
+
+		for _, w := range target.waivers {
+			artifact.Waivers = append(artifact.Waivers, w.Reference)
+		}
+
+		steps := make([]string, 0, len(target.verified))
+		for step := range target.verified {
+			steps = append(steps, step)
+		}
+
+		sort.Strings(steps)
+		for _, step := range steps {
+			for _, collection := range target.verified[step] {
+				evidence := compliance.Evidence{Step: step, Reference: collection.Reference}
+				for _, a := range collection.Collection.Attestations {
+					evidence.Attestations = append(evidence.Attestations, a.Type)
+				}
+
+				artifact.Evidence = append(artifact.Evidence, evidence)
+			}
+		}
+
+		report.Artifacts = append(report.Artifacts, artifact)
+	}
+
+	return report, nil
+}
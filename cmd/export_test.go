@@ -0,0 +1,76 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/attestation/commandrun"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/compliance"
+)
+
+func TestVerifyExport(t *testing.T) {
+	f := newVerifyFixture(t)
+	step1 := f.run(t, "step01", f.funcPrivPath, "echo 'test01' > test.txt")
+	step1Digest, err := cryptoutil.CalculateDigestSetFromFile(f.artifactPath, []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+	step2 := f.run(t, "step02", f.funcPrivPath, "echo 'test02' >> test.txt")
+
+	exportPath := filepath.Join(t.TempDir(), "evidence.csv")
+	vo := f.verifyOptions(f.policyPubPath, step1, step2)
+	vo.AdditionalSubjects = []string{step1Digest[crypto.SHA256]}
+	vo.ExportOptions = options.ExportOptions{Format: compliance.FormatCSV, Path: exportPath}
+	require.NoError(t, runVerify(context.Background(), vo))
+
+	exported, err := os.Open(exportPath)
+	require.NoError(t, err)
+	defer exported.Close()
+	records, err := csv.NewReader(exported).ReadAll()
+	require.NoError(t, err)
+	assert.Contains(t, records, []string{"PO.3.3", f.artifactPath, compliance.StatusSatisfied, "step01", commandrun.Type, step1, ""})
+	assert.Contains(t, records, []string{"PO.3.3", f.artifactPath, compliance.StatusSatisfied, "step02", commandrun.Type, step2, ""})
+
+	// failed verifications are exported too, so auditors see the controls that weren't met
+	vo = f.verifyOptions(f.policyPubPath, step1)
+	vo.ExportOptions = options.ExportOptions{Format: compliance.FormatCSV, Path: exportPath}
+	require.Error(t, runVerify(context.Background(), vo))
+	exported2, err := os.Open(exportPath)
+	require.NoError(t, err)
+	defer exported2.Close()
+	records, err = csv.NewReader(exported2).ReadAll()
+	require.NoError(t, err)
+	assert.Contains(t, records, []string{"PO.3.3", f.artifactPath, compliance.StatusNotSatisfied, "", "", "", ""})
+}
+
+func TestNewComplianceExporter(t *testing.T) {
+	exporter, err := newComplianceExporter(options.ExportOptions{})
+	require.NoError(t, err)
+	assert.Nil(t, exporter)
+
+	_, err = newComplianceExporter(options.ExportOptions{Format: "xlsx", Path: "out.xlsx"})
+	assert.ErrorContains(t, err, "unsupported export format")
+
+	_, err = newComplianceExporter(options.ExportOptions{Format: compliance.FormatOSCAL})
+	assert.ErrorContains(t, err, "export file is required")
+}
@@ -0,0 +1,83 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/runner"
+	"github.com/testifysec/witness/pkg/spool"
+)
+
+func FlushCmd() *cobra.Command {
+	fo := options.FlushOptions{}
+	cmd := &cobra.Command{
+		Use:               "flush",
+		Short:             "Uploads envelopes queued by witness run --async-upload",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runFlush(cmd.Context(), fo)
+		},
+		Args: cobra.NoArgs,
+	}
+
+	fo.AddFlags(cmd)
+	return cmd
+}
+
+func runFlush(ctx context.Context, fo options.FlushOptions) error {
+	envSpool, err := spool.Open(fo.SpoolOptions.Dir)
+	if err != nil {
+		return err
+	}
+
+	entries, err := envSpool.Entries()
+	if err != nil {
+		return fmt.Errorf("failed to read spool: %w", err)
+	}
+
+	failed := 0
+	for _, entry := range entries {
+		ref, err := runner.StoreEnvelope(ctx, entry.Backend, entry.Server, "", entry.Envelope)
+		if err != nil {
+			failed++
+			entry.Attempts++
+			entry.LastError = err.Error()
+			log.Errorf("failed to upload %v to %v: %v", entry.ID, entry.Backend, err)
+			if err := envSpool.Update(entry); err != nil {
+				return fmt.Errorf("failed to update spool entry %v: %w", entry.ID, err)
+			}
+
+			continue
+		}
+
+		log.Infof("Stored %v in %v as %v", entry.ID, entry.Backend, ref)
+		if err := envSpool.Remove(entry); err != nil {
+			return fmt.Errorf("failed to remove spool entry %v: %w", entry.ID, err)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to upload %d of %d queued envelopes", failed, len(entries))
+	}
+
+	return nil
+}
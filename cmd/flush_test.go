@@ -0,0 +1,176 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/spool"
+)
+
+func TestRunAsyncUploadAndFlush(t *testing.T) {
+	available := false
+	uploads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !available {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		uploads++
+		fmt.Fprintf(w, `{"gitoid": "gitoid-%d"}`, uploads)
+	}))
+	defer server.Close()
+
+	priv, _ := rsakeypair(t)
+	workingDir := t.TempDir()
+	spoolDir := t.TempDir()
+	runOptions := options.RunOptions{
+		KeyOptions:       options.KeyOptions{KeyPath: priv.Name()},
+		ArchivistOptions: options.ArchivistOptions{Enable: true, Urls: []string{server.URL}},
+		SpoolOptions:     options.SpoolOptions{Dir: spoolDir},
+		WorkingDir:       workingDir,
+		Attestations:     []string{},
+		OutFilePath:      filepath.Join(workingDir, "outfile.txt"),
+		StepName:         "teststep",
+		AsyncUpload:      true,
+	}
+
+	require.NoError(t, runRun(context.Background(), runOptions, []string{"bash", "-c", "echo 'test' > test.txt"}))
+	envSpool, err := spool.New(spoolDir)
+	require.NoError(t, err)
+	entries, err := envSpool.Entries()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, server.URL, entries[0].Server)
+
+	flushOptions := options.FlushOptions{SpoolOptions: options.SpoolOptions{Dir: spoolDir}}
+	require.Error(t, runFlush(context.Background(), flushOptions))
+	entries, err = envSpool.Entries()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, 1, entries[0].Attempts)
+
+	available = true
+	require.NoError(t, runFlush(context.Background(), flushOptions))
+	assert.Equal(t, 1, uploads)
+	entries, err = envSpool.Entries()
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestRunStoreFailurePolicy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	priv, _ := rsakeypair(t)
+	workingDir := t.TempDir()
+	spoolDir := t.TempDir()
+	envSpool, err := spool.New(spoolDir)
+	require.NoError(t, err)
+	args := []string{"bash", "-c", "echo 'test' > test.txt"}
+	for _, tc := range []struct {
+		policy       string
+		expectErr    bool
+		expectQueued int
+	}{
+		{"fail", true, 0},
+		{"warn", false, 0},
+		{"retry-later", false, 1},
+		{"sometimes", true, 1},
+	} {
+		runOptions := options.RunOptions{
+			KeyOptions:         options.KeyOptions{KeyPath: priv.Name()},
+			ArchivistOptions:   options.ArchivistOptions{Enable: true, Urls: []string{server.URL}},
+			SpoolOptions:       options.SpoolOptions{Dir: spoolDir},
+			WorkingDir:         workingDir,
+			Attestations:       []string{},
+			OutFilePath:        filepath.Join(workingDir, "outfile.txt"),
+			StepName:           "teststep",
+			StoreFailurePolicy: tc.policy,
+		}
+
+		err := runRun(context.Background(), runOptions, args)
+		if tc.expectErr {
+			assert.Error(t, err, tc.policy)
+		} else {
+			assert.NoError(t, err, tc.policy)
+		}
+
+		entries, err := envSpool.Entries()
+		require.NoError(t, err)
+		assert.Len(t, entries, tc.expectQueued, tc.policy)
+	}
+}
+
+func TestRunGitHubUploadAndFlush(t *testing.T) {
+	available := false
+	uploads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/repos/owner/repo/attestations", r.URL.Path)
+		if !available {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		uploads++
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"id": %d}`, uploads)
+	}))
+	defer server.Close()
+
+	t.Setenv("GITHUB_TOKEN", "secret")
+	priv, _ := rsakeypair(t)
+	workingDir := t.TempDir()
+	spoolDir := t.TempDir()
+	runOptions := options.RunOptions{
+		KeyOptions:         options.KeyOptions{KeyPath: priv.Name()},
+		GitHubOptions:      options.GitHubOptions{Repo: "owner/repo", APIURL: server.URL},
+		SpoolOptions:       options.SpoolOptions{Dir: spoolDir},
+		WorkingDir:         workingDir,
+		Attestations:       []string{},
+		OutFilePath:        filepath.Join(workingDir, "outfile.txt"),
+		StepName:           "teststep",
+		StoreFailurePolicy: "retry-later",
+	}
+
+	require.NoError(t, runRun(context.Background(), runOptions, []string{"bash", "-c", "echo 'test' > test.txt"}))
+	envSpool, err := spool.New(spoolDir)
+	require.NoError(t, err)
+	entries, err := envSpool.Entries()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, spool.BackendGitHub, entries[0].Backend)
+
+	available = true
+	require.NoError(t, runFlush(context.Background(), options.FlushOptions{SpoolOptions: options.SpoolOptions{Dir: spoolDir}}))
+	assert.Equal(t, 1, uploads)
+
+	require.NoError(t, runRun(context.Background(), runOptions, []string{"bash", "-c", "echo 'test' > test.txt"}))
+	assert.Equal(t, 2, uploads)
+
+	runOptions.GitHubOptions.Repo = "repo"
+	assert.Error(t, runRun(context.Background(), runOptions, []string{"true"}))
+}
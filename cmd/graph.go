@@ -0,0 +1,284 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/testifysec/go-witness/source"
+	"github.com/testifysec/witness/options"
+)
+
+const (
+	graphFormatDOT     = "dot"
+	graphFormatMermaid = "mermaid"
+)
+
+func GraphCmd() *cobra.Command {
+	o := options.GraphOptions{}
+	cmd := &cobra.Command{
+		Use:   "graph [attestation file]...",
+		Short: "Draws the supply chain described by a set of attestation collections",
+		Long: "Draws a DOT or Mermaid graph of the steps in a set of attestation collections, the artifacts they consumed and produced, " +
+			"and their back references, such as git commits. Steps are linked when one consumed an artifact another produced, so " +
+			"missing links in the supply chain stand out. The collections are not verified",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runGraph(cmd.OutOrStdout(), o, args)
+		},
+		Args: cobra.MinimumNArgs(1),
+	}
+
+	o.AddFlags(cmd)
+	return cmd
+}
+
+func runGraph(out io.Writer, o options.GraphOptions, paths []string) error {
+	var write func(io.Writer, supplyChainGraph)
+	switch o.Format {
+	case graphFormatDOT:
+		write = writeDOT
+	case graphFormatMermaid:
+		write = writeMermaid
+	default:
+		return fmt.Errorf("unknown graph format %v, expected dot or mermaid", o.Format)
+	}
+
+	collections := make([]source.CollectionEnvelope, 0, len(paths))
+	for _, path := range paths {
+		collection, err := loadCollectionEnvelope(path)
+		if err != nil {
+			return err
+		}
+
+		collections = append(collections, collection)
+	}
+
+	if o.OutFilePath != "" {
+		f, err := os.Create(o.OutFilePath)
+		if err != nil {
+			return fmt.Errorf("failed to create graph file: %w", err)
+		}
+
+		defer f.Close()
+		out = f
+	}
+
+	write(out, buildGraph(collections, o.AllMaterials))
+	return nil
+}
+
+const (
+	graphNodeStep     = "step"
+	graphNodeArtifact = "artifact"
+	graphNodeBackRef  = "backref"
+
+	graphEdgeConsumes = "consumes"
+	graphEdgeProduces = "produces"
+	graphEdgeBackRef  = "backref"
+)
+
+type graphNode struct {
+	id    string
+	kind  string
+	label string
+}
+
+// graphEdge points from an artifact to the step that consumed it, from a step to the artifact it produced,
+// or from a step to one of its back references
+type graphEdge struct {
+	from string
+	to   string
+	kind string
+}
+
+type supplyChainGraph struct {
+	nodes []graphNode
+	edges []graphEdge
+}
+
+// buildGraph links collections through the artifacts they share. Artifacts are identified by digest, so a
+// product of one step and a material of another with the same digest are the same node.
+func buildGraph(collections []source.CollectionEnvelope, allMaterials bool) supplyChainGraph {
+	sort.SliceStable(collections, func(i, j int) bool {
+		return collections[i].Collection.Name < collections[j].Collection.Name
+	})
+
+	produced := make(map[string]struct{})
+	for _, collection := range collections {
+		for _, digest := range collectionProducts(collection.Collection) {
+			produced[digest] = struct{}{}
+		}
+	}
+
+	g := supplyChainGraph{}
+	artifactIDs := make(map[string]string)
+	artifactDigests := []string{}
+	artifactNames := make(map[string]map[string]struct{})
+	backRefIDs := make(map[string]string)
+	artifact := func(digest, name string) string {
+		id, ok := artifactIDs[digest]
+		if !ok {
+			id = fmt.Sprintf("artifact%d", len(artifactIDs))
+			artifactIDs[digest] = id
+			artifactDigests = append(artifactDigests, digest)
+			artifactNames[digest] = make(map[string]struct{})
+		}
+
+		artifactNames[digest][name] = struct{}{}
+		return id
+	}
+
+	for i, collection := range collections {
+		stepID := fmt.Sprintf("step%d", i)
+		label := collection.Collection.Name
+		if collection.Reference != "" {
+			label = fmt.Sprintf("%v\n%v", label, filepath.Base(collection.Reference))
+		}
+
+		g.nodes = append(g.nodes, graphNode{id: stepID, kind: graphNodeStep, label: label})
+		materials := collectionMaterials(collection.Collection)
+		for _, name := range sortedKeys(materials) {
+			if _, ok := produced[materials[name]]; !ok && !allMaterials {
+				continue
+			}
+
+			g.edges = append(g.edges, graphEdge{from: artifact(materials[name], name), to: stepID, kind: graphEdgeConsumes})
+		}
+
+		products := collectionProducts(collection.Collection)
+		for _, name := range sortedKeys(products) {
+			g.edges = append(g.edges, graphEdge{from: stepID, to: artifact(products[name], name), kind: graphEdgeProduces})
+		}
+
+		backRefs := make(map[string]string)
+		for name, digestSet := range collection.Collection.BackRefs() {
+			backRefs[name] = formatDigestSet(digestSet)
+		}
+
+		for _, name := range sortedKeys(backRefs) {
+			id, ok := backRefIDs[backRefs[name]]
+			if !ok {
+				id = fmt.Sprintf("backref%d", len(backRefIDs))
+				backRefIDs[backRefs[name]] = id
+				g.nodes = append(g.nodes, graphNode{id: id, kind: graphNodeBackRef, label: backRefLabel(name, backRefs[name])})
+			}
+
+			g.edges = append(g.edges, graphEdge{from: stepID, to: id, kind: graphEdgeBackRef})
+		}
+	}
+
+	for _, digest := range artifactDigests {
+		names := sortedKeys(artifactNames[digest])
+		g.nodes = append(g.nodes, graphNode{id: artifactIDs[digest], kind: graphNodeArtifact, label: strings.Join(names, "\n")})
+	}
+
+	return g
+}
+
+// backRefLabel names a back reference by the attribute it came from and a short form of its digest,
+// e.g. "commithash 1a2b3c4"
+func backRefLabel(name, digest string) string {
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+
+	if _, value, ok := strings.Cut(digest, ":"); ok {
+		digest = value
+	}
+
+	if len(digest) > 7 {
+		digest = digest[:7]
+	}
+
+	return fmt.Sprintf("%v %v", name, digest)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+func writeDOT(w io.Writer, g supplyChainGraph) {
+	shapes := map[string]string{
+		graphNodeStep:     "box",
+		graphNodeArtifact: "note",
+		graphNodeBackRef:  "ellipse",
+	}
+
+	fmt.Fprintln(w, "digraph witness {")
+	fmt.Fprintln(w, "  rankdir=LR;")
+	for _, node := range g.nodes {
+		fmt.Fprintf(w, "  %v [shape=%v, label=%v];\n", node.id, shapes[node.kind], dotQuote(node.label))
+	}
+
+	for _, edge := range g.edges {
+		style := ""
+		if edge.kind == graphEdgeBackRef {
+			style = ", style=dashed"
+		}
+
+		fmt.Fprintf(w, "  %v -> %v [label=%v%v];\n", edge.from, edge.to, dotQuote(edge.kind), style)
+	}
+
+	fmt.Fprintln(w, "}")
+}
+
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + strings.ReplaceAll(s, "\n", `\n`) + `"`
+}
+
+func writeMermaid(w io.Writer, g supplyChainGraph) {
+	shapes := map[string][2]string{
+		graphNodeStep:     {"[", "]"},
+		graphNodeArtifact: {"[/", "/]"},
+		graphNodeBackRef:  {"([", "])"},
+	}
+
+	fmt.Fprintln(w, "flowchart LR")
+	for _, node := range g.nodes {
+		shape := shapes[node.kind]
+		fmt.Fprintf(w, "  %v%v%v%v\n", node.id, shape[0], mermaidQuote(node.label), shape[1])
+	}
+
+	for _, edge := range g.edges {
+		arrow := "-->"
+		if edge.kind == graphEdgeBackRef {
+			arrow = "-.->"
+		}
+
+		fmt.Fprintf(w, "  %v %v|%v| %v\n", edge.from, arrow, edge.kind, edge.to)
+	}
+}
+
+func mermaidQuote(s string) string {
+	s = strings.ReplaceAll(s, `"`, "#quot;")
+	return `"` + strings.ReplaceAll(s, "\n", "<br/>") + `"`
+}
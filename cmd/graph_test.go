@@ -0,0 +1,91 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/source"
+	"github.com/testifysec/witness/options"
+)
+
+func TestRunGraph(t *testing.T) {
+	priv, _ := rsakeypair(t)
+	workingDir := t.TempDir()
+	attestationDir := t.TempDir()
+	run := func(step, script string) string {
+		outPath := filepath.Join(attestationDir, step+".json")
+		require.NoError(t, runRun(context.Background(), options.RunOptions{
+			KeyOptions:  options.KeyOptions{KeyPath: priv.Name()},
+			WorkingDir:  workingDir,
+			OutFilePath: outPath,
+			StepName:    step,
+		}, []string{"bash", "-c", script}))
+		return outPath
+	}
+
+	require.NoError(t, os.WriteFile(filepath.Join(workingDir, "README"), []byte("readme"), 0644))
+	build := run("build", "echo 'source' > source.txt && echo 'binary' > app")
+	pkg := run("package", "tar -cf app.tar app")
+
+	collections := []string{pkg, build}
+	first, err := loadCollectionEnvelope(build)
+	require.NoError(t, err)
+	second, err := loadCollectionEnvelope(pkg)
+	require.NoError(t, err)
+	g := buildGraph([]source.CollectionEnvelope{second, first}, false)
+
+	labels := map[string]string{}
+	for _, node := range g.nodes {
+		labels[node.id] = node.label
+	}
+
+	edges := []string{}
+	for _, edge := range g.edges {
+		edges = append(edges, labels[edge.from]+" "+edge.kind+" "+labels[edge.to])
+	}
+
+	assert.ElementsMatch(t, []string{
+		"build\nbuild.json produces app",
+		"build\nbuild.json produces source.txt",
+		"app consumes package\npackage.json",
+		"source.txt consumes package\npackage.json",
+		"package\npackage.json produces app.tar",
+	}, edges)
+
+	g = buildGraph([]source.CollectionEnvelope{first, second}, true)
+	// README isn't produced by any step, so it's only included with every material
+	assert.Len(t, g.edges, 7)
+
+	out := bytes.Buffer{}
+	require.NoError(t, runGraph(&out, options.GraphOptions{Format: graphFormatDOT}, collections))
+	assert.Contains(t, out.String(), "digraph witness {")
+	assert.Contains(t, out.String(), `step0 [shape=box, label="build\nbuild.json"];`)
+	assert.Contains(t, out.String(), `step0 -> artifact0 [label="produces"];`)
+
+	out.Reset()
+	require.NoError(t, runGraph(&out, options.GraphOptions{Format: graphFormatMermaid}, collections))
+	assert.Contains(t, out.String(), "flowchart LR")
+	assert.Contains(t, out.String(), `step0["build<br/>build.json"]`)
+	assert.Contains(t, out.String(), "step0 -->|produces| artifact0")
+
+	assert.Error(t, runGraph(&out, options.GraphOptions{Format: "svg"}, collections))
+}
@@ -0,0 +1,597 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/attestation/commandrun"
+	"github.com/testifysec/go-witness/attestation/material"
+	"github.com/testifysec/go-witness/attestation/product"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/policy"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/selfupdate"
+	"github.com/testifysec/witness/pkg/trust"
+)
+
+const (
+	initCIGitHub = "github"
+	initCIGitLab = "gitlab"
+	initCITekton = "tekton"
+	initCINone   = "none"
+
+	initSignerKey    = "key"
+	initSignerSPIFFE = "spiffe"
+
+	initStorageArchivist = "archivist"
+	initStorageLocal     = "local"
+	initStorageFile      = "file"
+
+	// initSPIFFESocket is where the SPIRE agent serves the Workload API by default
+	initSPIFFESocket = "/tmp/spire-agent/public/api.sock"
+)
+
+func InitCmd() *cobra.Command {
+	o := options.InitOptions{}
+	cmd := &cobra.Command{
+		Use:   "init",
+		Short: "Sets up witness for a repository",
+		Long: "Asks which CI system, signer, and storage backend to use, and writes a .witness.yaml, a sample policy for the " +
+			"step, and a CI snippet that records it. A key pair is generated to sign the policy, and the step with --signer key, " +
+			"if the key doesn't exist. Questions answered by flags aren't asked",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runInit(cmd.InOrStdin(), cmd.OutOrStdout(), o)
+		},
+		Args: cobra.NoArgs,
+	}
+
+	o.AddFlags(cmd)
+	return cmd
+}
+
+// initAnswers are the choices the generated files are written from
+type initAnswers struct {
+	CI        string
+	Signer    string
+	Storage   string
+	StepName  string
+	Command   string
+	KeyPath   string
+	PubPath   string
+	OutFile   string
+	Version   string
+	Archive   string
+	Attestors []string
+
+	SPIFFESocket string
+}
+
+// initFile is a file init writes, relative to the directory it was given
+type initFile struct {
+	path string
+	data []byte
+	perm os.FileMode
+}
+
+func runInit(in io.Reader, out io.Writer, o options.InitOptions) error {
+	p := &initPrompter{in: bufio.NewReader(in), out: out}
+	answers := initAnswers{Version: initWitnessVersion(), SPIFFESocket: initSPIFFESocket}
+	var err error
+	if answers.CI, err = p.choose(o.CI, "CI system", []string{initCIGitHub, initCIGitLab, initCITekton, initCINone}); err != nil {
+		return err
+	}
+
+	if answers.Signer, err = p.choose(o.Signer, "Signer", []string{initSignerKey, initSignerSPIFFE}); err != nil {
+		return err
+	}
+
+	if answers.Storage, err = p.choose(o.Storage, "Storage backend", []string{initStorageArchivist, initStorageLocal, initStorageFile}); err != nil {
+		return err
+	}
+
+	if answers.StepName, err = p.ask(o.StepName, "Step name", "build"); err != nil {
+		return err
+	}
+
+	if answers.Command, err = p.ask(o.Command, "Command to record", "make build"); err != nil {
+		return err
+	}
+
+	if answers.KeyPath, err = p.ask(o.KeyPath, "Signing key", "witness-key.pem"); err != nil {
+		return err
+	}
+
+	answers.PubPath = strings.TrimSuffix(answers.KeyPath, filepath.Ext(answers.KeyPath)) + ".pub"
+	answers.Archive = selfupdate.ArchiveName(answers.Version, "linux", "amd64")
+	answers.Attestors = []string{"environment", "git"}
+	if answers.CI == initCIGitLab {
+		answers.Attestors = append(answers.Attestors, "gitlab")
+	}
+
+	if answers.Storage == initStorageFile {
+		answers.OutFile = answers.StepName + ".json"
+	}
+
+	files, keyID, pubPEM, err := initKeyFiles(o.Dir, answers)
+	if err != nil {
+		return err
+	}
+
+	policyFile, err := initPolicy(answers, keyID, pubPEM)
+	if err != nil {
+		return err
+	}
+
+	files = append(files, initFile{path: "policy.json", data: policyFile, perm: 0644})
+	for _, tmpl := range []struct {
+		path string
+		tmpl *template.Template
+	}{
+		{".witness.yaml", initConfigTemplate},
+		{initCISnippetPath(answers.CI), initCITemplates[answers.CI]},
+	} {
+		if tmpl.tmpl == nil {
+			continue
+		}
+
+		buf := &bytes.Buffer{}
+		if err := tmpl.tmpl.Execute(buf, answers); err != nil {
+			return fmt.Errorf("failed to render %v: %w", tmpl.path, err)
+		}
+
+		files = append(files, initFile{path: tmpl.path, data: buf.Bytes(), perm: 0644})
+	}
+
+	if err := writeInitFiles(o.Dir, files, o.Force); err != nil {
+		return err
+	}
+
+	written := make([]string, 0, len(files))
+	for _, f := range files {
+		written = append(written, f.path)
+	}
+
+	fmt.Fprintf(out, "\nWrote %v\n\n", strings.Join(written, ", "))
+	printInitNextSteps(out, answers)
+	return nil
+}
+
+// initPrompter asks the questions flags didn't answer. A blank answer, or running out of input, takes the default.
+type initPrompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func (p *initPrompter) ask(flagValue, question, def string) (string, error) {
+	if flagValue != "" {
+		return flagValue, nil
+	}
+
+	fmt.Fprintf(p.out, "%v [%v]: ", question, def)
+	line, err := p.in.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+
+	if errors.Is(err, io.EOF) && line == "" {
+		fmt.Fprintln(p.out)
+	}
+
+	if answer := strings.TrimSpace(line); answer != "" {
+		return answer, nil
+	}
+
+	return def, nil
+}
+
+// choose asks question until one of choices is given. The first choice is the default.
+func (p *initPrompter) choose(flagValue, question string, choices []string) (string, error) {
+	if flagValue != "" {
+		if !contains(choices, flagValue) {
+			return "", fmt.Errorf("%v must be one of %v, got %q", strings.ToLower(question), strings.Join(choices, ", "), flagValue)
+		}
+
+		return flagValue, nil
+	}
+
+	for {
+		answer, err := p.ask("", fmt.Sprintf("%v (%v)", question, strings.Join(choices, ", ")), choices[0])
+		if err != nil {
+			return "", err
+		}
+
+		if contains(choices, answer) {
+			return answer, nil
+		}
+
+		fmt.Fprintf(p.out, "%q isn't one of %v\n", answer, strings.Join(choices, ", "))
+	}
+}
+
+// initWitnessVersion returns the release the CI snippets install, which is the release this binary was built from.
+// Development builds get a placeholder for the user to fill in.
+func initWitnessVersion() string {
+	if !strings.HasPrefix(Version, "v") {
+		return "REPLACE_WITH_WITNESS_VERSION"
+	}
+
+	version, _, _ := strings.Cut(strings.TrimPrefix(Version, "v"), "-")
+	return version
+}
+
+// initKeyFiles returns the key pair files to write, and the ID and PEM of the public key. An existing key is reused,
+// and its public key is only written if it's missing.
+func initKeyFiles(dir string, answers initAnswers) ([]initFile, string, []byte, error) {
+	keyPEM, err := os.ReadFile(initPath(dir, answers.KeyPath))
+	files := []initFile{}
+	if errors.Is(err, os.ErrNotExist) {
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("failed to generate key: %w", err)
+		}
+
+		der, err := x509.MarshalPKCS8PrivateKey(priv)
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("failed to encode key: %w", err)
+		}
+
+		keyPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+		files = append(files, initFile{path: answers.KeyPath, data: keyPEM, perm: 0600})
+	} else if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to read key: %w", err)
+	}
+
+	signer, err := cryptoutil.NewSignerFromReader(bytes.NewReader(keyPEM))
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to load key %v: %w", answers.KeyPath, err)
+	}
+
+	verifier, err := signer.Verifier()
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	keyID, err := verifier.KeyID()
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	pubPEM, err := verifier.Bytes()
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	if _, err := os.Stat(initPath(dir, answers.PubPath)); errors.Is(err, os.ErrNotExist) {
+		files = append(files, initFile{path: answers.PubPath, data: pubPEM, perm: 0644})
+	}
+
+	return files, keyID, pubPEM, nil
+}
+
+// initPolicyFile is a policy whose functionaries are written without the other functionary type's constraints
+type initPolicyFile struct {
+	Expires    time.Time                   `json:"expires"`
+	PublicKeys map[string]policy.PublicKey `json:"publickeys,omitempty"`
+	Steps      map[string]initPolicyStep   `json:"steps"`
+}
+
+type initPolicyStep struct {
+	Name          string               `json:"name"`
+	Functionaries []trust.Functionary  `json:"functionaries"`
+	Attestations  []policy.Attestation `json:"attestations"`
+}
+
+// initPolicy returns a policy that requires the step to be signed by the key, or by any SPIRE issued certificate
+// until the user adds their SPIRE CA, and to record the attestors the configuration runs.
+func initPolicy(answers initAnswers, keyID string, pubPEM []byte) ([]byte, error) {
+	p := initPolicyFile{
+		Expires: time.Now().AddDate(1, 0, 0).UTC().Truncate(time.Second),
+		Steps:   map[string]initPolicyStep{},
+	}
+
+	step := initPolicyStep{Name: answers.StepName}
+	for _, name := range append([]string{material.Name, commandrun.Name, product.Name}, answers.Attestors...) {
+		factory, ok := attestation.FactoryByName(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown attestor %v", name)
+		}
+
+		step.Attestations = append(step.Attestations, policy.Attestation{Type: factory().Type(), RegoPolicies: []policy.RegoPolicy{}})
+	}
+
+	switch answers.Signer {
+	case initSignerKey:
+		p.PublicKeys = map[string]policy.PublicKey{keyID: {KeyID: keyID, Key: pubPEM}}
+		step.Functionaries = []trust.Functionary{{Type: "PublicKey", PublicKeyID: keyID}}
+	case initSignerSPIFFE:
+		all := []string{policy.AllowAllConstraint}
+		step.Functionaries = []trust.Functionary{{
+			Type: "root",
+			CertConstraint: policy.CertConstraint{
+				CommonName:    policy.AllowAllConstraint,
+				DNSNames:      all,
+				Emails:        all,
+				Organizations: all,
+				URIs:          all,
+				Roots:         all,
+			},
+		}}
+	}
+
+	p.Steps[answers.StepName] = step
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(data, '\n'), nil
+}
+
+// initPath returns where path, as written in the generated files, is in dir
+func initPath(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+
+	return filepath.Join(dir, path)
+}
+
+// writeInitFiles writes files to dir. Nothing is written if any file already exists, unless force is set.
+func writeInitFiles(dir string, files []initFile, force bool) error {
+	if !force {
+		for _, f := range files {
+			if _, err := os.Stat(initPath(dir, f.path)); err == nil {
+				return fmt.Errorf("%v already exists, use --force to overwrite it", f.path)
+			}
+		}
+	}
+
+	for _, f := range files {
+		path := initPath(dir, f.path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+
+		write := os.WriteFile
+		if f.perm == 0600 {
+			// keys are never overwritten
+			write = writeNewFile
+		}
+
+		if err := write(path, f.data, f.perm); err != nil {
+			return fmt.Errorf("failed to write %v: %w", f.path, err)
+		}
+	}
+
+	return nil
+}
+
+func printInitNextSteps(out io.Writer, answers initAnswers) {
+	steps := []string{fmt.Sprintf("Keep %v out of version control, whoever holds it controls the policy", answers.KeyPath)}
+	if answers.Signer == initSignerKey {
+		switch answers.CI {
+		case initCIGitHub:
+			steps = append(steps, fmt.Sprintf("Add the contents of %v as the WITNESS_KEY secret of the GitHub repository", answers.KeyPath))
+		case initCIGitLab:
+			steps = append(steps, fmt.Sprintf("Add %v as a file type CI/CD variable named WITNESS_KEY", answers.KeyPath))
+		case initCITekton:
+			steps = append(steps, fmt.Sprintf("Create the key's secret: kubectl create secret generic witness-key --from-file=witness-key.pem=%v", answers.KeyPath))
+		}
+	} else {
+		steps = append(steps, "Add the certificate of your SPIRE server's CA under roots in policy.json, and narrow the uris constraint to the SPIFFE ID of the build workload")
+	}
+
+	if snippet := initCISnippetPath(answers.CI); snippet != "" && !strings.HasPrefix(Version, "v") {
+		steps = append(steps, fmt.Sprintf("Replace %v in %v with the witness release to install", answers.Version, snippet))
+	}
+
+	steps = append(steps,
+		fmt.Sprintf("Sign the policy: witness sign -f policy.json -k %v -o policy-signed.json", answers.KeyPath),
+		"Check the setup: witness doctor",
+		fmt.Sprintf("Record the step: witness run -- %v", answers.Command),
+		"Verify what it built: witness verify -f <artifact>",
+	)
+
+	fmt.Fprintln(out, "Next steps:")
+	for i, step := range steps {
+		fmt.Fprintf(out, "  %d. %v\n", i+1, step)
+	}
+}
+
+func initCISnippetPath(ci string) string {
+	switch ci {
+	case initCIGitHub:
+		return filepath.Join(".github", "workflows", "witness.yml")
+	case initCIGitLab:
+		return "witness.gitlab-ci.yml"
+	case initCITekton:
+		return "witness-task.yaml"
+	}
+
+	return ""
+}
+
+// initQuote quotes a string for YAML. JSON strings are valid YAML.
+func initQuote(s string) string {
+	quoted, _ := json.Marshal(s)
+	return string(quoted)
+}
+
+func newInitTemplate(text string) *template.Template {
+	return template.Must(template.New("").Funcs(template.FuncMap{"quote": initQuote}).Parse(text))
+}
+
+var initConfigTemplate = newInitTemplate(`# Written by witness init. Flags given on the command line override these values.
+run:
+  step: {{quote .StepName}}
+  attestations:
+{{- range .Attestors}}
+    - {{.}}
+{{- end}}
+{{- if eq .Signer "key"}}
+  key: {{quote .KeyPath}}
+{{- else}}
+  spiffe-socket: {{quote .SPIFFESocket}}
+{{- end}}
+{{- if eq .Storage "archivist"}}
+  enable-archivist: true
+{{- else if eq .Storage "local"}}
+  local-store: true
+{{- else}}
+  outfile: {{quote .OutFile}}
+{{- end}}
+verify:
+  policy: policy-signed.json
+  publickey: {{quote .PubPath}}
+{{- if eq .Storage "archivist"}}
+  enable-archivist: true
+{{- else if eq .Storage "local"}}
+  local-store: true
+{{- else}}
+  attestations:
+    - {{quote .OutFile}}
+{{- end}}
+`)
+
+var initCITemplates = map[string]*template.Template{
+	initCIGitHub: newInitTemplate(`# Records the {{.StepName}} step with witness. Written by witness init.
+name: witness
+
+on:
+  push:
+  pull_request:
+
+jobs:
+  witness:
+{{- if eq .Signer "spiffe"}}
+    # signing with SPIFFE needs a runner with a SPIRE agent
+    runs-on: self-hosted
+{{- else}}
+    runs-on: ubuntu-latest
+{{- end}}
+    steps:
+      - uses: actions/checkout@v4
+      - name: Install witness
+        run: curl -sSL https://github.com/testifysec/witness/releases/download/v{{.Version}}/{{.Archive}} | tar -xz -C "$RUNNER_TEMP" witness
+      - name: {{quote (printf "Record %v" .StepName)}}
+{{- if eq .Signer "key"}}
+        env:
+          WITNESS_KEY: {{"${{ secrets.WITNESS_KEY }}"}}
+        run: |
+          printf '%s\n' "$WITNESS_KEY" > "$RUNNER_TEMP/witness-key.pem"
+          "$RUNNER_TEMP/witness" run --key "$RUNNER_TEMP/witness-key.pem" -- {{.Command}}
+{{- else}}
+        run: |
+          "$RUNNER_TEMP/witness" run -- {{.Command}}
+{{- end}}
+{{- if eq .Storage "file"}}
+      - uses: actions/upload-artifact@v4
+        with:
+          name: witness-attestations
+          path: {{quote .OutFile}}
+{{- end}}
+`),
+	initCIGitLab: newInitTemplate(`# Records the {{.StepName}} step with witness. Include it from .gitlab-ci.yml with:
+#
+#   include:
+#     - local: witness.gitlab-ci.yml
+#
+# Written by witness init.
+witness:
+  stage: build
+{{- if eq .Signer "spiffe"}}
+  # signing with SPIFFE needs a runner with a SPIRE agent
+  tags:
+    - spire
+{{- end}}
+  script:
+    - curl -sSL https://github.com/testifysec/witness/releases/download/v{{.Version}}/{{.Archive}} | tar -xz -C /tmp witness
+{{- if eq .Signer "key"}}
+    - {{quote (printf "/tmp/witness run --key \"$WITNESS_KEY\" -- %v" .Command)}}
+{{- else}}
+    - {{quote (printf "/tmp/witness run -- %v" .Command)}}
+{{- end}}
+{{- if eq .Storage "file"}}
+  artifacts:
+    paths:
+      - {{quote .OutFile}}
+{{- end}}
+`),
+	initCITekton: newInitTemplate(`# Records the {{.StepName}} step with witness, and reports the attestation to Tekton Chains. Written by witness init.
+apiVersion: tekton.dev/v1
+kind: Task
+metadata:
+  name: witness
+spec:
+  workspaces:
+    - name: source
+  results:
+    - name: WITNESS_ATTESTATION_URL
+    - name: WITNESS_ATTESTATION_DIGEST
+  volumes:
+{{- if eq .Signer "key"}}
+    - name: witness-key
+      secret:
+        secretName: witness-key
+{{- else}}
+    - name: spire-agent-socket
+      csi:
+        driver: csi.spiffe.io
+        readOnly: true
+{{- end}}
+  steps:
+    - name: record
+      # an image with curl and the tools the command needs
+      image: registry.example.com/builder:latest
+      workingDir: $(workspaces.source.path)
+      volumeMounts:
+{{- if eq .Signer "key"}}
+        - name: witness-key
+          mountPath: /etc/witness
+          readOnly: true
+{{- else}}
+        - name: spire-agent-socket
+          mountPath: /tmp/spire-agent/public
+          readOnly: true
+{{- end}}
+      script: |
+        curl -sSL https://github.com/testifysec/witness/releases/download/v{{.Version}}/{{.Archive}} | tar -xz -C /tmp witness
+{{- if eq .Signer "key"}}
+        /tmp/witness run --ci-mode tekton --key /etc/witness/witness-key.pem -- {{.Command}}
+{{- else}}
+        /tmp/witness run --ci-mode tekton -- {{.Command}}
+{{- end}}
+`),
+}
@@ -0,0 +1,153 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/policy"
+	"github.com/testifysec/witness/options"
+	"gopkg.in/yaml.v3"
+)
+
+func TestInitPrompts(t *testing.T) {
+	dir := t.TempDir()
+	in := strings.NewReader("gitlab\nhsm\nspiffe\nlocal\ntest\n\n")
+	out := &bytes.Buffer{}
+	require.NoError(t, runInit(in, out, options.InitOptions{Dir: dir}))
+	assert.Contains(t, out.String(), `"hsm" isn't one of key, spiffe`)
+	assert.Contains(t, out.String(), "SPIFFE ID of the build workload")
+	assert.Contains(t, out.String(), "witness sign -f policy.json -k witness-key.pem -o policy-signed.json")
+
+	config := map[string]map[string]interface{}{}
+	b, err := os.ReadFile(filepath.Join(dir, ".witness.yaml"))
+	require.NoError(t, err)
+	require.NoError(t, yaml.Unmarshal(b, &config))
+	assert.Equal(t, "test", config["run"]["step"])
+	assert.Equal(t, initSPIFFESocket, config["run"]["spiffe-socket"])
+	assert.Equal(t, []interface{}{"environment", "git", "gitlab"}, config["run"]["attestations"])
+	assert.Equal(t, true, config["verify"]["local-store"])
+	assert.NotContains(t, config["run"], "key")
+
+	p := policy.Policy{}
+	b, err = os.ReadFile(filepath.Join(dir, "policy.json"))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(b, &p))
+	require.Len(t, p.Steps["test"].Functionaries, 1)
+	assert.Equal(t, "root", p.Steps["test"].Functionaries[0].Type)
+	assert.Equal(t, []string{policy.AllowAllConstraint}, p.Steps["test"].Functionaries[0].CertConstraint.Roots)
+	assert.Len(t, p.Steps["test"].Attestations, 6)
+
+	snippet, err := os.ReadFile(filepath.Join(dir, "witness.gitlab-ci.yml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(snippet), `"/tmp/witness run -- make build"`)
+
+	// running out of input takes the defaults, and existing files aren't overwritten
+	err = runInit(strings.NewReader(""), out, options.InitOptions{Dir: dir})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "already exists")
+}
+
+func TestInitGeneratesValidFiles(t *testing.T) {
+	for _, ci := range []string{initCIGitHub, initCIGitLab, initCITekton, initCINone} {
+		for _, signer := range []string{initSignerKey, initSignerSPIFFE} {
+			for _, storage := range []string{initStorageArchivist, initStorageLocal, initStorageFile} {
+				t.Run(strings.Join([]string{ci, signer, storage}, "-"), func(t *testing.T) {
+					dir := t.TempDir()
+					o := options.InitOptions{
+						CI:       ci,
+						Signer:   signer,
+						Storage:  storage,
+						StepName: "build: release",
+						Command:  `go build -ldflags "-X main.version=1" ./...`,
+						KeyPath:  "keys/signing.pem",
+						Dir:      dir,
+					}
+
+					require.NoError(t, os.Mkdir(filepath.Join(dir, "keys"), 0755))
+					require.NoError(t, runInit(strings.NewReader(""), &bytes.Buffer{}, o))
+					for _, path := range []string{".witness.yaml", initCISnippetPath(ci)} {
+						if path == "" {
+							continue
+						}
+
+						b, err := os.ReadFile(filepath.Join(dir, path))
+						require.NoError(t, err)
+						parsed := map[string]interface{}{}
+						require.NoError(t, yaml.Unmarshal(b, &parsed), path)
+					}
+
+					b, err := os.ReadFile(filepath.Join(dir, "policy.json"))
+					require.NoError(t, err)
+					p := policy.Policy{}
+					require.NoError(t, json.Unmarshal(b, &p))
+					require.Contains(t, p.Steps, "build: release")
+					verifiers, err := p.PublicKeyVerifiers()
+					require.NoError(t, err)
+					if signer == initSignerKey {
+						assert.Len(t, verifiers, 1)
+						assert.Contains(t, verifiers, p.Steps["build: release"].Functionaries[0].PublicKeyID)
+					} else {
+						assert.Empty(t, verifiers)
+					}
+
+					info, err := os.Stat(filepath.Join(dir, "keys", "signing.pem"))
+					require.NoError(t, err)
+					assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+					assert.FileExists(t, filepath.Join(dir, "keys", "signing.pub"))
+				})
+			}
+		}
+	}
+}
+
+func TestInitReusesKey(t *testing.T) {
+	dir := t.TempDir()
+	priv, _ := rsakeypair(t)
+	keyPEM, err := os.ReadFile(priv.Name())
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ci.pem"), keyPEM, 0600))
+
+	o := options.InitOptions{CI: initCINone, Signer: initSignerKey, Storage: initStorageFile, StepName: "build", Command: "make", KeyPath: "ci.pem", Dir: dir}
+	require.NoError(t, runInit(strings.NewReader(""), &bytes.Buffer{}, o))
+	b, err := os.ReadFile(filepath.Join(dir, "ci.pem"))
+	require.NoError(t, err)
+	assert.Equal(t, keyPEM, b)
+
+	// the public key written alongside it is the one the policy trusts
+	b, err = os.ReadFile(filepath.Join(dir, "policy.json"))
+	require.NoError(t, err)
+	p := policy.Policy{}
+	require.NoError(t, json.Unmarshal(b, &p))
+	pub, err := os.ReadFile(filepath.Join(dir, "ci.pub"))
+	require.NoError(t, err)
+	for _, key := range p.PublicKeys {
+		assert.Equal(t, pub, key.Key)
+	}
+
+	// --force overwrites the generated files but keeps the key
+	o.StepName, o.Force = "test", true
+	require.NoError(t, runInit(strings.NewReader(""), &bytes.Buffer{}, o))
+	b, err = os.ReadFile(filepath.Join(dir, ".witness.yaml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `step: "test"`)
+}
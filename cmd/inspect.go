@@ -0,0 +1,377 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/digitorus/timestamp"
+	"github.com/spf13/cobra"
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/revocation"
+	"github.com/testifysec/witness/pkg/schema"
+	"github.com/testifysec/witness/pkg/trust"
+)
+
+func InspectCmd() *cobra.Command {
+	ino := options.InspectOptions{}
+	cmd := &cobra.Command{
+		Use:   "inspect [envelope file]",
+		Short: "Prints the contents of a signed envelope without verifying it",
+		Long: "Prints the decoded payload, subjects, signers, and timestamps of a DSSE envelope. " +
+			"Nothing is verified, so the output must not be trusted until the envelope is verified against a policy",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runInspect(cmd.OutOrStdout(), ino, args[0])
+		},
+		Args: cobra.ExactArgs(1),
+	}
+
+	ino.AddFlags(cmd)
+	return cmd
+}
+
+type envelopeInspection struct {
+	PayloadType   string                `json:"payloadType"`
+	PayloadDigest string                `json:"payloadDigest"`
+	Gitoid        string                `json:"gitoid,omitempty"`
+	Type          string                `json:"type,omitempty"`
+	PredicateType string                `json:"predicateType,omitempty"`
+	Subjects      []intoto.Subject      `json:"subjects,omitempty"`
+	Step          string                `json:"step,omitempty"`
+	Attestations  []string              `json:"attestations,omitempty"`
+	Signatures    []signatureInspection `json:"signatures"`
+	SchemaResult  *schemaInspection     `json:"schema,omitempty"`
+	Payload       json.RawMessage       `json:"payload,omitempty"`
+}
+
+type schemaInspection struct {
+	Errors  []string `json:"errors,omitempty"`
+	Unknown []string `json:"unknown,omitempty"`
+}
+
+type signatureInspection struct {
+	KeyID         string                  `json:"keyid"`
+	Identity      *trust.Identity         `json:"identity,omitempty"`
+	Certificate   *certificateInspection  `json:"certificate,omitempty"`
+	Intermediates []certificateInspection `json:"intermediates,omitempty"`
+	Timestamps    []timestampInspection   `json:"timestamps,omitempty"`
+}
+
+type certificateInspection struct {
+	Subject   string    `json:"subject"`
+	Issuer    string    `json:"issuer"`
+	NotBefore time.Time `json:"notBefore"`
+	NotAfter  time.Time `json:"notAfter"`
+	URIs      []string  `json:"uris,omitempty"`
+	Emails    []string  `json:"emails,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+type timestampInspection struct {
+	Type  string    `json:"type"`
+	Time  time.Time `json:"time,omitempty"`
+	Error string    `json:"error,omitempty"`
+}
+
+func runInspect(out io.Writer, ino options.InspectOptions, path string) error {
+	envBytes, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read envelope: %w", err)
+	}
+
+	env := dsse.Envelope{}
+	if err := json.Unmarshal(envBytes, &env); err != nil {
+		return fmt.Errorf("failed to parse envelope: %w", err)
+	}
+
+	inspection := inspectEnvelope(env)
+	var schemaErr error
+	if ino.ValidateSchema {
+		schemaErr = validateInspectionSchema(env, &inspection)
+	}
+
+	switch ino.Output {
+	case "json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(inspection); err != nil {
+			return err
+		}
+	case "text":
+		printInspection(out, inspection)
+	default:
+		return fmt.Errorf("unknown output format %v", ino.Output)
+	}
+
+	return schemaErr
+}
+
+// validateInspectionSchema validates the collection in env against the published attestor schemas and records the result
+func validateInspectionSchema(env dsse.Envelope, inspection *envelopeInspection) error {
+	if inspection.PredicateType != attestation.CollectionType {
+		return fmt.Errorf("schema validation is only supported for attestation collections")
+	}
+
+	stmt := intoto.Statement{}
+	if err := json.Unmarshal(env.Payload, &stmt); err != nil {
+		return err
+	}
+
+	unknown, err := schema.ValidateCollection(stmt.Predicate)
+	inspection.SchemaResult = &schemaInspection{Unknown: unknown}
+	collectionErr := schema.CollectionError{}
+	if errors.As(err, &collectionErr) {
+		for attestorType, validationErrs := range collectionErr.Failures {
+			for _, validationErr := range validationErrs {
+				inspection.SchemaResult.Errors = append(inspection.SchemaResult.Errors, fmt.Sprintf("%v: %v", attestorType, validationErr))
+			}
+		}
+
+		sort.Strings(inspection.SchemaResult.Errors)
+		return fmt.Errorf("envelope contains attestations that do not match their schemas")
+	}
+
+	return err
+}
+
+func inspectEnvelope(env dsse.Envelope) envelopeInspection {
+	inspection := envelopeInspection{PayloadType: env.PayloadType, PayloadDigest: revocation.PayloadDigest(env)}
+	if gitoid, err := revocation.Gitoid(env); err == nil {
+		inspection.Gitoid = gitoid
+	}
+
+	if json.Valid(env.Payload) {
+		inspection.Payload = env.Payload
+	}
+
+	if env.PayloadType == intoto.PayloadType {
+		stmt := intoto.Statement{}
+		if err := json.Unmarshal(env.Payload, &stmt); err == nil {
+			inspection.Type = stmt.Type
+			inspection.PredicateType = stmt.PredicateType
+			inspection.Subjects = stmt.Subject
+			sort.Slice(inspection.Subjects, func(i, j int) bool { return inspection.Subjects[i].Name < inspection.Subjects[j].Name })
+			if stmt.PredicateType == attestation.CollectionType {
+				inspection.Step, inspection.Attestations = inspectCollection(stmt.Predicate)
+			}
+		}
+	}
+
+	for _, sig := range env.Signatures {
+		sigInspection := signatureInspection{KeyID: sig.KeyID}
+		if identity, err := trust.SignatureIdentity(sig); err == nil {
+			sigInspection.Identity = &identity
+		}
+
+		if len(sig.Certificate) > 0 {
+			cert := inspectCertificate(sig.Certificate)
+			sigInspection.Certificate = &cert
+		}
+
+		for _, intermediate := range sig.Intermediates {
+			sigInspection.Intermediates = append(sigInspection.Intermediates, inspectCertificate(intermediate))
+		}
+
+		for _, ts := range sig.Timestamps {
+			tsInspection := timestampInspection{Type: string(ts.Type)}
+			if ts.Type == dsse.TimestampRFC3161 {
+				if parsed, err := timestamp.Parse(ts.Data); err != nil {
+					tsInspection.Error = err.Error()
+				} else {
+					tsInspection.Time = parsed.Time
+				}
+			}
+
+			sigInspection.Timestamps = append(sigInspection.Timestamps, tsInspection)
+		}
+
+		inspection.Signatures = append(inspection.Signatures, sigInspection)
+	}
+
+	return inspection
+}
+
+// inspectCollection returns the step name and attestation types of a collection. The attestations are read
+// generically so collections containing attestors this build of witness doesn't know about can be inspected.
+func inspectCollection(predicate json.RawMessage) (string, []string) {
+	collection := struct {
+		Name         string `json:"name"`
+		Attestations []struct {
+			Type string `json:"type"`
+		} `json:"attestations"`
+	}{}
+
+	if err := json.Unmarshal(predicate, &collection); err != nil {
+		return "", nil
+	}
+
+	types := []string{}
+	for _, att := range collection.Attestations {
+		types = append(types, att.Type)
+	}
+
+	return collection.Name, types
+}
+
+func inspectCertificate(certBytes []byte) certificateInspection {
+	if block, _ := pem.Decode(certBytes); block != nil {
+		certBytes = block.Bytes
+	}
+
+	cert, err := x509.ParseCertificate(certBytes)
+	if err != nil {
+		return certificateInspection{Error: err.Error()}
+	}
+
+	inspection := certificateInspection{
+		Subject:   cert.Subject.String(),
+		Issuer:    cert.Issuer.String(),
+		NotBefore: cert.NotBefore,
+		NotAfter:  cert.NotAfter,
+		Emails:    cert.EmailAddresses,
+	}
+
+	for _, uri := range cert.URIs {
+		inspection.URIs = append(inspection.URIs, uri.String())
+	}
+
+	return inspection
+}
+
+func printInspection(out io.Writer, inspection envelopeInspection) {
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Payload type:\t%v\n", inspection.PayloadType)
+	fmt.Fprintf(tw, "Payload digest:\tsha256:%v\n", inspection.PayloadDigest)
+	if inspection.Gitoid != "" {
+		fmt.Fprintf(tw, "Gitoid:\t%v\n", inspection.Gitoid)
+	}
+	if inspection.PredicateType != "" {
+		fmt.Fprintf(tw, "Predicate type:\t%v\n", inspection.PredicateType)
+	}
+
+	if inspection.Step != "" {
+		fmt.Fprintf(tw, "Step:\t%v\n", inspection.Step)
+	}
+
+	tw.Flush()
+	if len(inspection.Attestations) > 0 {
+		fmt.Fprintln(out, "\nAttestations:")
+		for _, att := range inspection.Attestations {
+			fmt.Fprintf(out, "  %v\n", att)
+		}
+	}
+
+	if len(inspection.Subjects) > 0 {
+		fmt.Fprintln(out, "\nSubjects:")
+		tw = tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		for _, subject := range inspection.Subjects {
+			digests := []string{}
+			for alg, digest := range subject.Digest {
+				digests = append(digests, fmt.Sprintf("%v:%v", alg, digest))
+			}
+
+			sort.Strings(digests)
+			fmt.Fprintf(tw, "  %v\t%v\n", subject.Name, strings.Join(digests, ","))
+		}
+
+		tw.Flush()
+	}
+
+	if inspection.SchemaResult != nil {
+		fmt.Fprintln(out, "\nSchema validation:")
+		if len(inspection.SchemaResult.Errors) == 0 {
+			fmt.Fprintln(out, "  all attestations match their schemas")
+		}
+
+		for _, err := range inspection.SchemaResult.Errors {
+			fmt.Fprintf(out, "  %v\n", err)
+		}
+
+		for _, attestorType := range inspection.SchemaResult.Unknown {
+			fmt.Fprintf(out, "  no schema for %v\n", attestorType)
+		}
+	}
+
+	fmt.Fprintln(out, "\nSignatures:")
+	if len(inspection.Signatures) == 0 {
+		fmt.Fprintln(out, "  none")
+	}
+
+	for i, sig := range inspection.Signatures {
+		fmt.Fprintf(out, "  %d: key id %v\n", i, sig.KeyID)
+		if sig.Certificate != nil {
+			fmt.Fprintf(out, "     certificate: %v\n", describeCertificate(*sig.Certificate))
+		}
+
+		if sig.Identity != nil {
+			printIdentity(out, "     ", *sig.Identity)
+		}
+
+		for _, intermediate := range sig.Intermediates {
+			fmt.Fprintf(out, "     intermediate: %v\n", describeCertificate(intermediate))
+		}
+
+		for _, ts := range sig.Timestamps {
+			if ts.Error != "" {
+				fmt.Fprintf(out, "     timestamp (%v): %v\n", ts.Type, ts.Error)
+			} else {
+				fmt.Fprintf(out, "     timestamp (%v): %v\n", ts.Type, ts.Time.UTC().Format(time.RFC3339))
+			}
+		}
+	}
+}
+
+// printIdentity writes the computed key ID, OIDC issuer, and policy functionary of a signer, each line prefixed
+// with indent
+func printIdentity(out io.Writer, indent string, identity trust.Identity) {
+	fmt.Fprintf(out, "%vsigner key id: %v\n", indent, identity.KeyID)
+	if identity.Certificate != nil && identity.Certificate.OIDCIssuer != "" {
+		fmt.Fprintf(out, "%voidc issuer: %v\n", indent, identity.Certificate.OIDCIssuer)
+	}
+
+	if functionary, err := json.Marshal(identity.Functionary); err == nil {
+		fmt.Fprintf(out, "%vfunctionary: %s\n", indent, functionary)
+	}
+}
+
+func describeCertificate(cert certificateInspection) string {
+	if cert.Error != "" {
+		return fmt.Sprintf("invalid certificate: %v", cert.Error)
+	}
+
+	identities := append(append([]string{}, cert.URIs...), cert.Emails...)
+	description := fmt.Sprintf("subject %q issued by %q, valid %v to %v", cert.Subject, cert.Issuer, cert.NotBefore.UTC().Format(time.RFC3339), cert.NotAfter.UTC().Format(time.RFC3339))
+	if len(identities) > 0 {
+		description += fmt.Sprintf(", identities %v", strings.Join(identities, ", "))
+	}
+
+	return description
+}
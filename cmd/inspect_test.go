@@ -0,0 +1,109 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/attestation/commandrun"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/witness/options"
+)
+
+func TestInspect(t *testing.T) {
+	_, intermediates, leafcert, leafkey := fullChain(t)
+	workingDir := t.TempDir()
+	attestationPath := filepath.Join(workingDir, "outfile.txt")
+	runOptions := options.RunOptions{
+		KeyOptions: options.KeyOptions{
+			KeyPath:           leafkey.Name(),
+			CertPath:          leafcert.Name(),
+			IntermediatePaths: []string{intermediates[0].Name()},
+		},
+		WorkingDir:   workingDir,
+		Attestations: []string{},
+		OutFilePath:  attestationPath,
+		StepName:     "build",
+	}
+
+	require.NoError(t, runRun(context.Background(), runOptions, []string{"bash", "-c", "echo 'test' > test.txt"}))
+
+	out := &bytes.Buffer{}
+	require.NoError(t, runInspect(out, options.InspectOptions{Output: "text"}, attestationPath))
+	assert.Contains(t, out.String(), "Step:            build")
+	assert.Contains(t, out.String(), commandrun.Type)
+	assert.Contains(t, out.String(), "test.txt")
+	assert.Contains(t, out.String(), "certificate: subject")
+	assert.Contains(t, out.String(), "intermediate: subject")
+	assert.Contains(t, out.String(), "signer key id: ")
+	assert.Contains(t, out.String(), `functionary: {"type":"root","certConstraint":{"commonname":"Witness Testing Leaf"`)
+
+	out.Reset()
+	require.NoError(t, runInspect(out, options.InspectOptions{Output: "json"}, attestationPath))
+	inspection := envelopeInspection{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &inspection))
+	assert.Equal(t, attestation.CollectionType, inspection.PredicateType)
+	require.Len(t, inspection.Signatures, 1)
+	require.NotNil(t, inspection.Signatures[0].Certificate)
+	assert.Empty(t, inspection.Signatures[0].Certificate.Error)
+	require.NotNil(t, inspection.Signatures[0].Identity)
+	assert.Equal(t, inspection.Signatures[0].KeyID, inspection.Signatures[0].Identity.KeyID)
+	assert.Equal(t, "Witness Testing Leaf", inspection.Signatures[0].Identity.Certificate.CommonName)
+	assert.Equal(t, []string{"Witness Testing"}, inspection.Signatures[0].Identity.Functionary.CertConstraint.Organizations)
+
+	require.Error(t, runInspect(out, options.InspectOptions{Output: "yaml"}, attestationPath))
+}
+
+func TestInspectValidateSchema(t *testing.T) {
+	priv, _ := rsakeypair(t)
+	workingDir := t.TempDir()
+	attestationPath := filepath.Join(workingDir, "outfile.txt")
+	runOptions := options.RunOptions{
+		KeyOptions:   options.KeyOptions{KeyPath: priv.Name()},
+		WorkingDir:   workingDir,
+		Attestations: []string{"environment"},
+		OutFilePath:  attestationPath,
+		StepName:     "build",
+		DirSubjects:  []string{"."},
+	}
+
+	require.NoError(t, runRun(context.Background(), runOptions, []string{"bash", "-c", "echo 'test' > test.txt"}))
+	out := &bytes.Buffer{}
+	require.NoError(t, runInspect(out, options.InspectOptions{Output: "text", ValidateSchema: true}, attestationPath))
+	assert.Contains(t, out.String(), "all attestations match their schemas")
+
+	// inspect doesn't verify signatures, so the payload can be tampered with to produce a malformed predicate
+	env := dsse.Envelope{}
+	envBytes, err := os.ReadFile(attestationPath)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(envBytes, &env))
+	env.Payload = bytes.Replace(env.Payload, []byte(`"exitcode":0`), []byte(`"exitcode":"0"`), 1)
+	envBytes, err = json.Marshal(env)
+	require.NoError(t, err)
+	malformedPath := filepath.Join(workingDir, "malformed.json")
+	require.NoError(t, os.WriteFile(malformedPath, envBytes, 0644))
+
+	out.Reset()
+	require.Error(t, runInspect(out, options.InspectOptions{Output: "text", ValidateSchema: true}, malformedPath))
+	assert.Contains(t, out.String(), commandrun.Type+": $.exitcode: expected integer but found string")
+}
@@ -0,0 +1,112 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// junitTestSuites is a JUnit XML report with a test suite for each target
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+// junitTestSuite is the verification of one target, with a test case for each step of the policy
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Type    string `xml:"type,attr"`
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeVerifyJUnit writes the verification result of each target as a JUnit XML report and returns the number that
+// failed. Each step of the policy is a test case. A target that failed for a reason not attributed to a step, or
+// whose policy wasn't evaluated, gets a verification test case carrying its error.
+func writeVerifyJUnit(w io.Writer, targets []verifyTarget) (int, error) {
+	report := junitTestSuites{Name: "witness verify", Suites: make([]junitTestSuite, 0, len(targets))}
+	failed := 0
+	for _, target := range targets {
+		suite := junitTestSuite{Name: target.name}
+		attributed := false
+		for _, step := range target.steps {
+			tc := junitTestCase{Name: step.step, ClassName: target.name}
+			if !step.passed {
+				attributed = true
+				tc.Failure = &junitFailure{Type: step.check, Message: step.message, Text: step.message}
+			}
+
+			suite.Cases = append(suite.Cases, tc)
+		}
+
+		if target.err != nil && !attributed {
+			suite.Cases = append(suite.Cases, junitTestCase{
+				Name:      "verification",
+				ClassName: target.name,
+				Failure:   &junitFailure{Type: sarifRuleVerificationFailed, Message: target.err.Error(), Text: target.err.Error()},
+			})
+		} else if len(suite.Cases) == 0 {
+			suite.Cases = append(suite.Cases, junitTestCase{Name: "verification", ClassName: target.name})
+		}
+
+		for _, tc := range suite.Cases {
+			suite.Tests++
+			if tc.Failure != nil {
+				suite.Failures++
+			}
+		}
+
+		if target.err != nil {
+			failed++
+		}
+
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Suites = append(report.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return failed, fmt.Errorf("failed to write junit report: %w", err)
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return failed, fmt.Errorf("failed to write junit report: %w", err)
+	}
+
+	if _, err := io.WriteString(w, "\n"); err != nil {
+		return failed, fmt.Errorf("failed to write junit report: %w", err)
+	}
+
+	return failed, nil
+}
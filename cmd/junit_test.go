@@ -0,0 +1,64 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyJUnit(t *testing.T) {
+	f := newVerifyFixture(t)
+	step1 := f.run(t, "step01", f.funcPrivPath, "echo 'test01' > test.txt")
+
+	vo := f.verifyOptions(f.policyPubPath, step1)
+	targets, err := verifyPolicy(context.Background(), vo)
+	require.Error(t, err)
+	targets = append(targets, verifyTarget{name: "unreachable", err: errors.New("archivist is unreachable")})
+
+	out := &bytes.Buffer{}
+	vo.Output = verifyOutputJUnit
+	failed, err := writeVerifyResults(out, vo, targets)
+	require.NoError(t, err)
+	assert.Equal(t, 2, failed)
+
+	report := junitTestSuites{}
+	require.NoError(t, xml.Unmarshal(out.Bytes(), &report))
+	assert.Equal(t, 3, report.Tests)
+	assert.Equal(t, 2, report.Failures)
+	require.Len(t, report.Suites, 2)
+
+	suite := report.Suites[0]
+	assert.Equal(t, f.artifactPath, suite.Name)
+	require.Len(t, suite.Cases, 2)
+	assert.Equal(t, "step01", suite.Cases[0].Name)
+	assert.Nil(t, suite.Cases[0].Failure)
+	assert.Equal(t, "step02", suite.Cases[1].Name)
+	require.NotNil(t, suite.Cases[1].Failure)
+	assert.Equal(t, stepCheckMissingAttestations, suite.Cases[1].Failure.Type)
+	assert.Contains(t, suite.Cases[1].Failure.Message, "step02")
+
+	suite = report.Suites[1]
+	require.Len(t, suite.Cases, 1)
+	assert.Equal(t, "verification", suite.Cases[0].Name)
+	require.NotNil(t, suite.Cases[0].Failure)
+	assert.Equal(t, "archivist is unreachable", suite.Cases[0].Failure.Message)
+}
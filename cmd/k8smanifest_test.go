@@ -0,0 +1,99 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/witness/attestation/k8smanifest"
+	"github.com/testifysec/witness/options"
+)
+
+const testManifest = `# Source: web/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: prod
+spec:
+  template:
+    spec:
+      initContainers:
+        - name: migrate
+          image: registry.example.com/migrate:1.0
+      containers:
+        - name: web
+          image: registry.example.com/web@sha256:0d4ae6a10bc3e6f6e0e4d8ba4c21a7bce8b6ddcd9b5b8e1e54aa0fb2ce3a5e42
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web-config
+data:
+  key: value
+`
+
+func TestRunK8sManifestAttestations(t *testing.T) {
+	built := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(built, "rendered.yaml"), []byte(testManifest), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(built, "values.yaml"), []byte("replicas: 3\n"), 0644))
+	writeTarGz(t, filepath.Join(built, "web-1.2.0.tgz"), map[string]string{
+		"web/Chart.yaml":              "apiVersion: v2\nname: web\nversion: 1.2.0\nappVersion: \"2.0\"\n",
+		"web/charts/redis/Chart.yaml": "apiVersion: v2\nname: redis\nversion: 17.0.0\n",
+	})
+
+	priv, _ := rsakeypair(t)
+	attestationPath := filepath.Join(t.TempDir(), "outfile.txt")
+	runOptions := options.RunOptions{
+		KeyOptions:   options.KeyOptions{KeyPath: priv.Name()},
+		WorkingDir:   t.TempDir(),
+		Attestations: []string{k8smanifest.Name},
+		OutFilePath:  attestationPath,
+		StepName:     "teststep",
+	}
+
+	require.NoError(t, runRun(context.Background(), runOptions, []string{"bash", "-c", "cp " + built + "/* ."}))
+	stmt, collection := readCollection(t, attestationPath)
+	attestor := findAttestor[*k8smanifest.Attestor](collection)
+	require.NotNil(t, attestor)
+	require.Len(t, attestor.Objects, 2)
+	assert.Equal(t, "Deployment", attestor.Objects[0].Kind)
+	assert.Equal(t, "prod", attestor.Objects[0].Namespace)
+	assert.Equal(t, []string{
+		"registry.example.com/migrate:1.0",
+		"registry.example.com/web@sha256:0d4ae6a10bc3e6f6e0e4d8ba4c21a7bce8b6ddcd9b5b8e1e54aa0fb2ce3a5e42",
+	}, attestor.Objects[0].Images)
+	assert.Equal(t, "web-config", attestor.Objects[1].Name)
+	require.Len(t, attestor.Charts, 1)
+	assert.Equal(t, "web", attestor.Charts[0].Name)
+	assert.Equal(t, "2.0", attestor.Charts[0].AppVersion)
+
+	subjects := map[string]map[string]string{}
+	for _, subject := range stmt.Subject {
+		subjects[subject.Name] = subject.Digest
+	}
+
+	assert.Contains(t, subjects, k8smanifest.Type+"/k8s:Deployment:prod/web")
+	assert.Contains(t, subjects, k8smanifest.Type+"/k8s:ConfigMap:web-config")
+	assert.Contains(t, subjects, k8smanifest.Type+"/helmchart:web@1.2.0")
+	assert.NotContains(t, subjects, k8smanifest.Type+"/image:registry.example.com/migrate:1.0")
+	assert.Equal(t, "0d4ae6a10bc3e6f6e0e4d8ba4c21a7bce8b6ddcd9b5b8e1e54aa0fb2ce3a5e42",
+		subjects[k8smanifest.Type+"/image:registry.example.com/web@sha256:0d4ae6a10bc3e6f6e0e4d8ba4c21a7bce8b6ddcd9b5b8e1e54aa0fb2ce3a5e42"]["sha256"])
+}
@@ -0,0 +1,137 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/witness/attestation/k8spod"
+	"github.com/testifysec/witness/pkg/schema"
+)
+
+const testPod = `{
+  "metadata": {
+    "name": "build-x7k2p",
+    "namespace": "ci-prod",
+    "uid": "8f0c7c1e-1b7e-4f57-9c55-1d2d0d1f6f10",
+    "labels": {"job-name": "build", "team": "platform"},
+    "ownerReferences": [{"apiVersion": "batch/v1", "kind": "Job", "name": "build", "uid": "2b6a3f0e-0a5d-4c1e-8c0b-6f1f7e9d7a11"}]
+  },
+  "spec": {
+    "serviceAccountName": "builder",
+    "nodeName": "node-3",
+    "containers": [{"name": "build", "image": "golang:1.18"}]
+  },
+  "status": {
+    "containerStatuses": [{"name": "build", "image": "golang:1.18", "imageID": "docker.io/library/golang@sha256:2222222222222222222222222222222222222222222222222222222222222222"}]
+  }
+}`
+
+// reorderedTestPod is testPod's spec with its keys in another order
+const reorderedTestPod = `{"metadata": {"uid": "8f0c7c1e-1b7e-4f57-9c55-1d2d0d1f6f10"}, "spec": {"nodeName": "node-3", "containers": [{"image": "golang:1.18", "name": "build"}], "serviceAccountName": "builder"}}`
+
+func TestK8sPodAttestor(t *testing.T) {
+	pod := testPod
+	status := http.StatusOK
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/namespaces/ci-prod/pods/build-x7k2p" || r.Header.Get("Authorization") != "Bearer sa-token" {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.WriteHeader(status)
+		w.Write([]byte(pod))
+	}))
+
+	defer server.Close()
+	serviceAccountDir := t.TempDir()
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	require.NoError(t, os.WriteFile(filepath.Join(serviceAccountDir, "ca.crt"), caPEM, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(serviceAccountDir, "token"), []byte("sa-token\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(serviceAccountDir, "namespace"), []byte("ci-prod"), 0644))
+
+	host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	require.NoError(t, err)
+	for _, env := range []string{"POD_NAMESPACE", "POD_UID", "POD_SERVICE_ACCOUNT", "NODE_NAME"} {
+		t.Setenv(env, "")
+	}
+
+	t.Setenv("KUBERNETES_SERVICE_HOST", host)
+	t.Setenv("KUBERNETES_SERVICE_PORT", port)
+	t.Setenv("POD_NAME", "build-x7k2p")
+
+	attest := func() (*k8spod.Attestor, error) {
+		a := k8spod.New(k8spod.WithServiceAccountDir(serviceAccountDir))
+		ctx, err := attestation.NewContext([]attestation.Attestor{a})
+		require.NoError(t, err)
+		return a, a.Attest(ctx)
+	}
+
+	a, err := attest()
+	require.NoError(t, err)
+	assert.Equal(t, k8spod.SourceAPIServer, a.Source)
+	assert.Equal(t, "ci-prod", a.Namespace)
+	assert.Equal(t, "build-x7k2p", a.PodName)
+	assert.Equal(t, "8f0c7c1e-1b7e-4f57-9c55-1d2d0d1f6f10", a.PodUID)
+	assert.Equal(t, "builder", a.ServiceAccount)
+	assert.Equal(t, "node-3", a.NodeName)
+	assert.Equal(t, "build", a.Job)
+	assert.Equal(t, "platform", a.Labels["team"])
+	require.Len(t, a.Containers, 1)
+	assert.Equal(t, "docker.io/library/golang@sha256:2222222222222222222222222222222222222222222222222222222222222222", a.Containers[0].ImageID)
+	require.NotEmpty(t, a.PodSpecDigest)
+
+	s, ok, err := schema.ForType(k8spod.Type)
+	require.NoError(t, err)
+	require.True(t, ok)
+	predicate, err := json.Marshal(a)
+	require.NoError(t, err)
+	validationErrs, err := s.Validate(predicate)
+	require.NoError(t, err)
+	assert.Empty(t, validationErrs)
+
+	// the spec digest doesn't depend on the order the api server wrote its keys in
+	pod = reorderedTestPod
+	reordered, err := attest()
+	require.NoError(t, err)
+	assert.Equal(t, a.PodSpecDigest, reordered.PodSpecDigest)
+
+	// without permission to get pods, the downward api is used
+	status = http.StatusForbidden
+	t.Setenv("POD_SERVICE_ACCOUNT", "builder")
+	t.Setenv("NODE_NAME", "node-3")
+	a, err = attest()
+	require.NoError(t, err)
+	assert.Equal(t, k8spod.SourceDownwardAPI, a.Source)
+	assert.Equal(t, "ci-prod", a.Namespace)
+	assert.Equal(t, "builder", a.ServiceAccount)
+	assert.Equal(t, "node-3", a.NodeName)
+	assert.Empty(t, a.PodSpecDigest)
+
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	_, err = attest()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "doesn't appear to be running in a kubernetes pod")
+}
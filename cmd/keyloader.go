@@ -16,51 +16,16 @@ package cmd
 
 import (
 	"context"
-	"fmt"
 
 	"github.com/testifysec/go-witness/cryptoutil"
-	"github.com/testifysec/go-witness/signer/file"
-	"github.com/testifysec/go-witness/signer/fulcio"
-	"github.com/testifysec/go-witness/signer/spiffe"
 	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/runner"
 )
 
 func loadSigners(ctx context.Context, ko options.KeyOptions) ([]cryptoutil.Signer, []error) {
-	signers := []cryptoutil.Signer{}
-	errors := []error{}
-
-	//Load key from fulcio
-	if ko.FulcioURL != "" {
-		fulcioSigner, err := fulcio.Signer(ctx, ko.FulcioURL, ko.OIDCClientID, ko.OIDCIssuer)
-		if err != nil {
-			err := fmt.Errorf("failed to create signer from Fulcio: %w", err)
-			errors = append(errors, err)
-		} else {
-			signers = append(signers, fulcioSigner)
-		}
-	}
-
-	//Load key from file
-	if ko.KeyPath != "" {
-		fileSigner, err := file.Signer(ctx, ko.KeyPath, ko.CertPath, ko.IntermediatePaths)
-		if err != nil {
-			err := fmt.Errorf("failed to create signer from file: %w", err)
-			errors = append(errors, err)
-		} else {
-			signers = append(signers, fileSigner)
-		}
-	}
-
-	//Load key from spire agent
-	if ko.SpiffePath != "" {
-		spiffeSigner, err := spiffe.Signer(ctx, ko.SpiffePath)
-		if err != nil {
-			err := fmt.Errorf("failed to create signer from spiffe: %w", err)
-			errors = append(errors, err)
-		} else {
-			signers = append(signers, spiffeSigner)
-		}
-	}
+	return runner.LoadSigners(ctx, ko, ro.FIPS)
+}
 
-	return signers, errors
+func envelopeSigners(ko options.KeyOptions, signer cryptoutil.Signer) ([]cryptoutil.Signer, error) {
+	return runner.EnvelopeSigners(ko, signer, ro.FIPS)
 }
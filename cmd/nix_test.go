@@ -0,0 +1,131 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/witness/attestation/nix"
+	"github.com/testifysec/witness/options"
+)
+
+const (
+	// the sha256 of an empty string, in SRI and nix base32 form
+	emptySHA256    = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	emptySRIHash   = "sha256-47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="
+	emptyNix32Hash = "sha256:0mdqa9w1p6cmli6976v4wi0sw9r4p5prkj7lzfd1877wk11c9c73"
+)
+
+const flakeLock = `{
+  "nodes": {
+    "nixpkgs": {
+      "locked": {
+        "lastModified": 1700000000,
+        "narHash": "` + emptySRIHash + `",
+        "owner": "NixOS",
+        "repo": "nixpkgs",
+        "rev": "0123456789abcdef0123456789abcdef01234567",
+        "type": "github"
+      }
+    },
+    "root": {
+      "inputs": {
+        "nixpkgs": "nixpkgs"
+      }
+    }
+  },
+  "root": "root",
+  "version": 7
+}`
+
+// fakeNix puts a nix on PATH that links result outputs into store for nix build and describes them for nix path-info
+func fakeNix(t *testing.T, store string) {
+	bin := t.TempDir()
+	script := fmt.Sprintf(`#!/bin/sh
+case " $* " in
+*" build "*)
+  ln -s %[1]v/aaaa-app result
+  ln -s %[1]v/bbbb-app-dev result-dev
+  ;;
+*" path-info "*)
+  echo '{"%[1]v/aaaa-app": {"narHash": "%[2]v", "deriver": "%[1]v/cccc-app.drv"}, "%[1]v/bbbb-app-dev": {"narHash": "%[3]v", "deriver": "%[1]v/cccc-app.drv"}}'
+  ;;
+esac
+`, store, emptySRIHash, emptyNix32Hash)
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "nix"), []byte(script), 0755))
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("NIX_STORE_DIR", store)
+}
+
+func TestRunNixAttestation(t *testing.T) {
+	store := t.TempDir()
+	fakeNix(t, store)
+	drvPath := filepath.Join(store, "cccc-app.drv")
+	require.NoError(t, os.WriteFile(drvPath, []byte(`Derive([("out","/nix/store/aaaa-app","","")])`), 0644))
+	drvDigest, err := cryptoutil.CalculateDigestSetFromFile(drvPath, []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+
+	priv, _ := rsakeypair(t)
+	workingDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(workingDir, "flake.lock"), []byte(flakeLock), 0644))
+	attestationPath := filepath.Join(t.TempDir(), "outfile.txt")
+	runOptions := options.RunOptions{
+		KeyOptions:   options.KeyOptions{KeyPath: priv.Name()},
+		WorkingDir:   workingDir,
+		Attestations: []string{},
+		OutFilePath:  attestationPath,
+		StepName:     "build",
+	}
+
+	require.NoError(t, runRun(context.Background(), runOptions, []string{"nix", "build", ".#app"}))
+	stmt, collection := readCollection(t, attestationPath)
+	attestor := findAttestor[*nix.Attestor](collection)
+	require.NotNil(t, attestor)
+	narDigest := cryptoutil.DigestSet{crypto.SHA256: emptySHA256}
+	assert.Equal(t, []nix.Output{
+		{Link: "result", StorePath: filepath.Join(store, "aaaa-app"), NarHash: emptySRIHash, Digest: narDigest, Deriver: drvPath},
+		{Link: "result-dev", StorePath: filepath.Join(store, "bbbb-app-dev"), NarHash: emptyNix32Hash, Digest: narDigest, Deriver: drvPath},
+	}, attestor.Outputs)
+	assert.Equal(t, []nix.Derivation{{Path: drvPath, Digest: drvDigest}}, attestor.Derivations)
+	require.NotNil(t, attestor.FlakeLock)
+	require.Len(t, attestor.FlakeInputs, 1)
+	assert.Equal(t, "nixpkgs", attestor.FlakeInputs[0].Name)
+	assert.Equal(t, "0123456789abcdef0123456789abcdef01234567", attestor.FlakeInputs[0].Rev)
+
+	subjects := map[string]map[string]string{}
+	for _, subject := range stmt.Subject {
+		subjects[subject.Name] = subject.Digest
+	}
+
+	assert.Equal(t, emptySHA256, subjects[nix.Type+"/output:"+filepath.Join(store, "aaaa-app")]["sha256"])
+	assert.Equal(t, drvDigest[crypto.SHA256], subjects[nix.Type+"/derivation:"+drvPath]["sha256"])
+	assert.Equal(t, narDigest, collection.Materials()["flake-input:nixpkgs"])
+}
+
+func TestNixIsBuildCommand(t *testing.T) {
+	assert.True(t, nix.IsBuildCommand([]string{"nix", "build", ".#app"}))
+	assert.True(t, nix.IsBuildCommand([]string{"/usr/bin/nix", "--extra-experimental-features", "nix-command flakes", "build"}))
+	assert.True(t, nix.IsBuildCommand([]string{"nix-build", "default.nix"}))
+	assert.False(t, nix.IsBuildCommand([]string{"nix", "develop", "-c", "make", "build"}))
+	assert.False(t, nix.IsBuildCommand([]string{"make", "build"}))
+}
@@ -0,0 +1,172 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/notation"
+	"github.com/testifysec/witness/pkg/runner"
+)
+
+// signNotation signs the input file as an OCI artifact in a notation JWS or COSE envelope
+func signNotation(so options.SignOptions) error {
+	key, certs, err := runner.LoadKeyFile(so.KeyOptions)
+	if err != nil {
+		return err
+	}
+
+	if len(certs) == 0 {
+		return fmt.Errorf("notation signatures require a key and a certificate")
+	}
+
+	info, err := os.Stat(so.InFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to open file to sign: %w", err)
+	}
+
+	digest, err := cryptoutil.CalculateDigestSetFromFile(so.InFilePath, []crypto.Hash{crypto.SHA256})
+	if err != nil {
+		return fmt.Errorf("failed to calculate digest of file to sign: %w", err)
+	}
+
+	target := notation.Descriptor{MediaType: so.MediaType, Digest: "sha256:" + digest[crypto.SHA256], Size: info.Size()}
+	sign := notation.SignJWS
+	if so.EnvelopeFormat == envelopeFormatNotationCOSE {
+		sign = notation.SignCOSE
+	}
+
+	env, err := sign(key, certs, target, time.Now(), "witness/"+Version)
+	if err != nil {
+		return err
+	}
+
+	out, err := loadOutfile(so.OutFilePath)
+	if err != nil {
+		return err
+	}
+
+	defer out.Close()
+	_, err = out.Write(env)
+	return err
+}
+
+// checkNotationSignature checks that the image artifactRef refers to has a notation signature from one of the
+// functionaries the policy trusts to sign images
+func checkNotationSignature(ctx context.Context, requirement *notation.Requirement, artifactRef string) error {
+	if !strings.HasPrefix(artifactRef, "oci://") {
+		return withExitCode(ExitCodePolicy, fmt.Errorf("policy requires a notation signature, which only images verified with --artifact-ref oci:// can have"))
+	}
+
+	ref, manifestDigest, err := imageManifestDigest(ctx, artifactRef)
+	if err != nil {
+		return err
+	}
+
+	signatures, err := fetchNotationSignatures(ctx, ref, manifestDigest)
+	if err != nil {
+		return err
+	}
+
+	if err := requirement.Check(signatures, manifestDigest); err != nil {
+		return withExitCode(ExitCodePolicy, err)
+	}
+
+	log.Infof("Verified notation signature of %v", ref)
+	return nil
+}
+
+type ociDescriptor struct {
+	MediaType    string `json:"mediaType"`
+	ArtifactType string `json:"artifactType"`
+	Digest       string `json:"digest"`
+}
+
+// fetchNotationSignatures fetches the signatures notation stored for the image with manifestDigest. They're found
+// with the referrers API, or the referrers tag registries without the API serve instead.
+func fetchNotationSignatures(ctx context.Context, ref ociReference, manifestDigest string) ([]notation.Signature, error) {
+	client := newRegistryClient(ref)
+	referrers, err := fetchReferrers(ctx, client, manifestDigest)
+	if err != nil {
+		return nil, withExitCode(ExitCodeInfrastructure, fmt.Errorf("failed to fetch notation signatures for %v: %w", ref, err))
+	}
+
+	signatures := []notation.Signature{}
+	for _, referrer := range referrers {
+		if referrer.ArtifactType != notation.ArtifactType {
+			continue
+		}
+
+		manifestClient := newRegistryClient(ociReference{Registry: ref.Registry, Repository: ref.Repository, Digest: referrer.Digest})
+		manifestBytes, _, err := manifestClient.Manifest(ctx)
+		if err != nil {
+			return nil, withExitCode(ExitCodeInfrastructure, fmt.Errorf("failed to fetch notation signature manifest %v: %w", referrer.Digest, err))
+		}
+
+		manifest := struct {
+			Layers []ociDescriptor `json:"layers"`
+		}{}
+
+		if err := json.Unmarshal(manifestBytes, &manifest); err != nil || len(manifest.Layers) != 1 {
+			log.Debugf("(notation) skipping signature manifest %v: expected one layer", referrer.Digest)
+			continue
+		}
+
+		envelope, err := fetchBlob(ctx, manifestClient, manifest.Layers[0].Digest)
+		if err != nil {
+			return nil, withExitCode(ExitCodeInfrastructure, fmt.Errorf("failed to fetch notation signature %v: %w", manifest.Layers[0].Digest, err))
+		}
+
+		signatures = append(signatures, notation.Signature{MediaType: manifest.Layers[0].MediaType, Envelope: envelope})
+	}
+
+	return signatures, nil
+}
+
+// fetchReferrers returns the manifests that refer to the manifest with digest
+func fetchReferrers(ctx context.Context, client *registryClient, digest string) ([]ociDescriptor, error) {
+	index := struct {
+		Manifests []ociDescriptor `json:"manifests"`
+	}{}
+
+	indexBytes, err := client.Referrers(ctx, digest)
+	if isRegistryNotFound(err) {
+		// registries without the referrers API list referrers in an index tagged with the digest
+		tagClient := newRegistryClient(ociReference{Registry: client.ref.Registry, Repository: client.ref.Repository, Tag: strings.Replace(digest, ":", "-", 1)})
+		indexBytes, _, err = tagClient.Manifest(ctx)
+		if isRegistryNotFound(err) {
+			return nil, nil
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(indexBytes, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse referrers: %w", err)
+	}
+
+	return index.Manifests, nil
+}
@@ -0,0 +1,218 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/policy"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/notation"
+)
+
+// notationSigner is a root certificate and a P-256 key and leaf certificate it issued, written to files for witness sign
+type notationSigner struct {
+	rootPEM  []byte
+	keyPath  string
+	certPath string
+}
+
+func newNotationSigner(t *testing.T, commonName string) notationSigner {
+	dir := t.TempDir()
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	rootTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "root"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	rootDER, err := x509.CreateCertificate(rand.Reader, rootTemplate, rootTemplate, &rootKey.PublicKey, rootKey)
+	require.NoError(t, err)
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, rootTemplate, &leafKey.PublicKey, rootKey)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(leafKey)
+	require.NoError(t, err)
+
+	signer := notationSigner{
+		rootPEM:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: rootDER}),
+		keyPath:  filepath.Join(dir, "leaf.key"),
+		certPath: filepath.Join(dir, "leaf.pem"),
+	}
+
+	require.NoError(t, os.WriteFile(signer.keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600))
+	require.NoError(t, os.WriteFile(signer.certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDER}), 0644))
+	return signer
+}
+
+// sign signs manifest with witness sign --envelope-format and returns the envelope
+func (s notationSigner) sign(t *testing.T, manifest []byte, format string) []byte {
+	dir := t.TempDir()
+	manifestPath := filepath.Join(dir, "manifest.json")
+	require.NoError(t, os.WriteFile(manifestPath, manifest, 0644))
+	so := options.SignOptions{
+		KeyOptions:     options.KeyOptions{KeyPath: s.keyPath, CertPath: s.certPath},
+		InFilePath:     manifestPath,
+		OutFilePath:    filepath.Join(dir, "signature.jws"),
+		EnvelopeFormat: format,
+		MediaType:      ociManifestType,
+	}
+
+	require.NoError(t, runSign(so))
+	envelope, err := os.ReadFile(so.OutFilePath)
+	require.NoError(t, err)
+	return envelope
+}
+
+// newNotationRegistry serves an image and, if signer is set, a notation signature of it made by signer in a JWS
+// envelope, or COSE if useCOSE is set. Registries with referrersAPI set serve the referrers API, and the others the
+// referrers tag schema.
+func newNotationRegistry(t *testing.T, signer *notationSigner, useCOSE, referrersAPI bool) string {
+	manifest := []byte(fmt.Sprintf(`{"schemaVersion":2,"mediaType":"%v","layers":[]}`, ociManifestType))
+	manifestDigest := fmt.Sprintf("sha256:%x", sha256.Sum256(manifest))
+	var envelope, sigManifest []byte
+	var envelopeDigest, sigManifestDigest string
+	if signer != nil {
+		format, mediaType := envelopeFormatNotationJWS, notation.MediaTypeJWS
+		if useCOSE {
+			format, mediaType = envelopeFormatNotationCOSE, notation.MediaTypeCOSE
+		}
+
+		envelope = signer.sign(t, manifest, format)
+		envelopeDigest = fmt.Sprintf("sha256:%x", sha256.Sum256(envelope))
+		sigManifest = []byte(fmt.Sprintf(`{"schemaVersion":2,"mediaType":"%v","artifactType":"%v","layers":[{"mediaType":"%v","digest":"%v","size":%d}],"subject":{"mediaType":"%v","digest":"%v","size":%d}}`,
+			ociManifestType, notation.ArtifactType, mediaType, envelopeDigest, len(envelope), ociManifestType, manifestDigest, len(manifest)))
+		sigManifestDigest = fmt.Sprintf("sha256:%x", sha256.Sum256(sigManifest))
+	}
+
+	referrers := fmt.Sprintf(`{"schemaVersion":2,"mediaType":"%v","manifests":[{"mediaType":"%v","artifactType":"application/spdx+json","digest":"sha256:0000"}`, ociIndexType, ociManifestType)
+	if signer != nil {
+		referrers += fmt.Sprintf(`,{"mediaType":"%v","artifactType":"%v","digest":"%v"}`, ociManifestType, notation.ArtifactType, sigManifestDigest)
+	}
+
+	referrers += "]}"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v2/":
+		case r.URL.Path == "/v2/app/manifests/latest":
+			w.Header().Set("Content-Type", ociManifestType)
+			_, _ = w.Write(manifest)
+		case r.URL.Path == "/v2/app/referrers/"+manifestDigest && referrersAPI,
+			r.URL.Path == "/v2/app/manifests/"+strings.Replace(manifestDigest, ":", "-", 1) && !referrersAPI:
+			w.Header().Set("Content-Type", ociIndexType)
+			_, _ = w.Write([]byte(referrers))
+		case r.URL.Path == "/v2/app/manifests/"+sigManifestDigest && signer != nil:
+			w.Header().Set("Content-Type", ociManifestType)
+			_, _ = w.Write(sigManifest)
+		case r.URL.Path == "/v2/app/blobs/"+envelopeDigest && signer != nil:
+			_, _ = w.Write(envelope)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	t.Cleanup(server.Close)
+	return fmt.Sprintf("oci://%v/app:latest", strings.TrimPrefix(server.URL, "http://"))
+}
+
+func TestVerifyNotationSignature(t *testing.T) {
+	trusted := newNotationSigner(t, "release-signer")
+	untrusted := newNotationSigner(t, "release-signer")
+	f := newVerifyFixtureWithPolicy(t, func(p map[string]interface{}) {
+		p["roots"] = map[string]policy.Root{"notation-root": {Certificate: trusted.rootPEM}}
+		p["notation"] = map[string]interface{}{
+			"functionaries": []policy.Functionary{{
+				Type:           "root",
+				CertConstraint: policy.CertConstraint{CommonName: "release-signer", Roots: []string{"notation-root"}, DNSNames: []string{"*"}, Emails: []string{"*"}, Organizations: []string{"*"}, URIs: []string{"*"}},
+			}},
+		}
+	})
+
+	step1 := f.run(t, "step01", f.funcPrivPath, "echo 'test01' > test.txt")
+	step1Digest, err := cryptoutil.CalculateDigestSetFromFile(f.artifactPath, []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+	step2 := f.run(t, "step02", f.funcPrivPath, "echo 'test02' >> test.txt")
+
+	vo := f.verifyOptions(f.policyPubPath, step1, step2)
+	vo.AdditionalSubjects = []string{step1Digest[crypto.SHA256]}
+	vo.ArtifactRef = newNotationRegistry(t, &trusted, false, true)
+	require.NoError(t, runVerify(context.Background(), vo))
+
+	vo.ArtifactRef = newNotationRegistry(t, &trusted, false, false)
+	require.NoError(t, runVerify(context.Background(), vo))
+
+	vo.ArtifactRef = newNotationRegistry(t, &trusted, true, true)
+	require.NoError(t, runVerify(context.Background(), vo))
+
+	vo.ArtifactRef = newNotationRegistry(t, &untrusted, false, true)
+	err = runVerify(context.Background(), vo)
+	require.Error(t, err)
+	assert.Equal(t, ExitCodePolicy, ExitCode(err))
+	assert.Contains(t, err.Error(), "no valid notation signature")
+
+	vo.ArtifactRef = newNotationRegistry(t, nil, false, false)
+	err = runVerify(context.Background(), vo)
+	require.Error(t, err)
+	assert.Equal(t, ExitCodePolicy, ExitCode(err))
+	assert.Contains(t, err.Error(), "no notation signatures found")
+}
+
+func TestSignNotationRequiresCertificate(t *testing.T) {
+	signer := newNotationSigner(t, "release-signer")
+	err := runSign(options.SignOptions{
+		KeyOptions:     options.KeyOptions{KeyPath: signer.keyPath},
+		InFilePath:     signer.certPath,
+		EnvelopeFormat: envelopeFormatNotationJWS,
+	})
+
+	assert.ErrorContains(t, err, "require a key and a certificate")
+
+	err = runSign(options.SignOptions{EnvelopeFormat: "cms"})
+	assert.ErrorContains(t, err, "unknown envelope format")
+}
@@ -0,0 +1,135 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/options"
+)
+
+const (
+	notifyEventRun    = "run"
+	notifyEventVerify = "verify"
+
+	notifyTimeout = 10 * time.Second
+)
+
+// notifyEvent is the JSON document sent to notification webhooks when a run or verification completes
+type notifyEvent struct {
+	Event        string               `json:"event"`
+	Time         time.Time            `json:"time"`
+	Step         string               `json:"step,omitempty"`
+	Success      bool                 `json:"success"`
+	Error        string               `json:"error,omitempty"`
+	Subjects     []intoto.Subject     `json:"subjects,omitempty"`
+	Storage      []string             `json:"storage,omitempty"`
+	Verification []notifyVerification `json:"verification,omitempty"`
+}
+
+type notifyVerification struct {
+	Artifact string `json:"artifact"`
+	Passed   bool   `json:"passed"`
+	Error    string `json:"error,omitempty"`
+}
+
+// addEnvelopeSubjects records the subjects of the statement signed in env
+func (e *notifyEvent) addEnvelopeSubjects(env dsse.Envelope) {
+	statement := intoto.Statement{}
+	if err := json.Unmarshal(env.Payload, &statement); err != nil {
+		log.Debugf("(notify) failed to parse statement: %v", err)
+		return
+	}
+
+	e.Subjects = append(e.Subjects, statement.Subject...)
+}
+
+// addVerifyTargets records the subjects and verification result of each target
+func (e *notifyEvent) addVerifyTargets(targets []verifyTarget) {
+	for _, target := range targets {
+		result := notifyVerification{Artifact: target.name, Passed: target.err == nil}
+		if target.err != nil {
+			result.Error = target.err.Error()
+		}
+
+		e.Verification = append(e.Verification, result)
+		if len(target.subjects) == 0 {
+			continue
+		}
+
+		subject, err := intoto.DigestSetToSubject(target.name, target.subjects[0])
+		if err != nil {
+			log.Debugf("(notify) failed to record subject %v: %v", target.name, err)
+			continue
+		}
+
+		e.Subjects = append(e.Subjects, subject)
+	}
+}
+
+// notify sends the event to every configured webhook. Failing to notify is logged but never fails the command.
+func notify(ctx context.Context, o options.NotifyOptions, event notifyEvent, cmdErr error) {
+	if len(o.Webhooks) == 0 {
+		return
+	}
+
+	event.Time = time.Now().UTC()
+	event.Success = cmdErr == nil
+	if cmdErr != nil {
+		event.Error = cmdErr.Error()
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Warnf("failed to marshal notification: %v", err)
+		return
+	}
+
+	for _, url := range o.Webhooks {
+		if err := postWebhook(ctx, url, body); err != nil {
+			log.Warnf("failed to notify %v: %v", url, err)
+		}
+	}
+}
+
+func postWebhook(ctx context.Context, url string, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with %v", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/witness/options"
+)
+
+func TestRunNotifyWebhook(t *testing.T) {
+	events := []notifyEvent{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		event := notifyEvent{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+		events = append(events, event)
+	}))
+	defer server.Close()
+
+	priv, _ := rsakeypair(t)
+	workingDir := t.TempDir()
+	outFile := filepath.Join(workingDir, "outfile.txt")
+	runOptions := options.RunOptions{
+		KeyOptions:    options.KeyOptions{KeyPath: priv.Name()},
+		NotifyOptions: options.NotifyOptions{Webhooks: []string{server.URL}},
+		WorkingDir:    workingDir,
+		Attestations:  []string{},
+		OutFilePath:   outFile,
+		StepName:      "teststep",
+	}
+
+	require.NoError(t, runRun(context.Background(), runOptions, []string{"bash", "-c", "echo 'test' > test.txt"}))
+	require.Len(t, events, 1)
+	assert.Equal(t, notifyEventRun, events[0].Event)
+	assert.Equal(t, "teststep", events[0].Step)
+	assert.True(t, events[0].Success)
+	assert.NotEmpty(t, events[0].Subjects)
+	assert.Equal(t, []string{outFile}, events[0].Storage)
+
+	verifyOptions := options.VerifyOptions{NotifyOptions: options.NotifyOptions{Webhooks: []string{server.URL}}}
+	require.Error(t, runVerify(context.Background(), verifyOptions))
+	require.Len(t, events, 2)
+	assert.Equal(t, notifyEventVerify, events[1].Event)
+	assert.False(t, events[1].Success)
+	assert.NotEmpty(t, events[1].Error)
+}
@@ -0,0 +1,180 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/witness/attestation/oidc"
+	"github.com/testifysec/witness/pkg/schema"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+// oidcIssuer is an identity provider that publishes its keys with OIDC discovery and, like GitHub Actions, issues
+// tokens to callers presenting the request token
+type oidcIssuer struct {
+	server *httptest.Server
+	signer jose.Signer
+}
+
+func newOIDCIssuer(t *testing.T) *oidcIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: jose.JSONWebKey{Key: key, KeyID: "key-1"}}, (&jose.SignerOptions{}).WithType("JWT"))
+	require.NoError(t, err)
+
+	issuer := &oidcIssuer{signer: signer}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                issuer.server.URL,
+			"jwks_uri":                              issuer.server.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: &key.PublicKey, KeyID: "key-1", Algorithm: "RS256", Use: "sig"}}})
+	})
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "bearer request-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{"value": issuer.token(t, r.URL.Query().Get("audience"), time.Hour)})
+	})
+
+	issuer.server = httptest.NewTLSServer(mux)
+	t.Cleanup(issuer.server.Close)
+	return issuer
+}
+
+func (i *oidcIssuer) token(t *testing.T, audience string, expiresIn time.Duration) string {
+	now := time.Now()
+	token, err := jwt.Signed(i.signer).Claims(map[string]interface{}{
+		"iss":        i.server.URL,
+		"sub":        "repo:owner/repo:ref:refs/heads/main",
+		"aud":        audience,
+		"iat":        now.Unix(),
+		"exp":        now.Add(expiresIn).Unix(),
+		"repository": "owner/repo",
+		"run_id":     "42",
+	}).CompactSerialize()
+	require.NoError(t, err)
+	return token
+}
+
+func TestOIDCAttestor(t *testing.T) {
+	issuer := newOIDCIssuer(t)
+	for _, env := range []string{"ACTIONS_ID_TOKEN_REQUEST_URL", "ACTIONS_ID_TOKEN_REQUEST_TOKEN", "BUILDKITE_AGENT_ACCESS_TOKEN", "CIRCLE_OIDC_TOKEN_V2", "CIRCLE_OIDC_TOKEN"} {
+		t.Setenv(env, "")
+	}
+
+	attest := func(opts ...oidc.Option) (*oidc.Attestor, error) {
+		a := oidc.New(append([]oidc.Option{oidc.WithHTTPClient(issuer.server.Client())}, opts...)...)
+		ctx, err := attestation.NewContext([]attestation.Attestor{a})
+		require.NoError(t, err)
+		return a, a.Attest(ctx)
+	}
+
+	_, err := attest()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no identity provider was found")
+
+	// tokens are requested from github actions for the audience
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_URL", issuer.server.URL+"/token?api-version=2.0")
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN", "request-token")
+	_, err = attest(oidc.WithAudience("sigstore"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "isn't the expected issuer "+oidc.GitHubActionsIssuer)
+
+	// the test issuer stands in for github actions the way a GitHub Enterprise Server's issuer would
+	a, err := attest(oidc.WithAudience("sigstore"), oidc.WithIssuer(issuer.server.URL))
+	require.NoError(t, err)
+	assert.Equal(t, oidc.ProviderGitHubActions, a.Provider)
+	assert.Equal(t, issuer.server.URL, a.Issuer)
+	assert.Equal(t, "repo:owner/repo:ref:refs/heads/main", a.Subject)
+	assert.Equal(t, []string{"sigstore"}, a.Audience)
+	assert.Equal(t, "owner/repo", a.Claims["repository"])
+	assert.Equal(t, issuer.server.URL+"/keys", a.VerifiedBy.JWKSURL)
+	assert.Equal(t, "key-1", a.VerifiedBy.KeyID)
+
+	s, ok, err := schema.ForType(oidc.Type)
+	require.NoError(t, err)
+	require.True(t, ok)
+	predicate, err := json.Marshal(a)
+	require.NoError(t, err)
+	validationErrs, err := s.Validate(predicate)
+	require.NoError(t, err)
+	assert.Empty(t, validationErrs)
+	assert.NotContains(t, string(predicate), "eyJ", "the token itself must not be recorded")
+
+	// tokens read from the environment or a file must be issued by the issuer they're configured with, to the
+	// audience, and unexpired
+	t.Setenv("WITNESS_ID_TOKEN", issuer.token(t, "witness", time.Hour))
+	_, err = attest(oidc.WithTokenEnv("WITNESS_ID_TOKEN"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "issuer of identity tokens read from the environment or a file must be set")
+
+	a, err = attest(oidc.WithTokenEnv("WITNESS_ID_TOKEN"), oidc.WithIssuer(issuer.server.URL))
+	require.NoError(t, err)
+	assert.Equal(t, oidc.ProviderEnvironment, a.Provider)
+
+	// a token from another issuer that serves discovery is rejected before its keys are fetched
+	t.Setenv("WITNESS_ID_TOKEN", newOIDCIssuer(t).token(t, "witness", time.Hour))
+	_, err = attest(oidc.WithTokenEnv("WITNESS_ID_TOKEN"), oidc.WithIssuer(issuer.server.URL))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "isn't the expected issuer")
+
+	t.Setenv("WITNESS_ID_TOKEN", issuer.token(t, "another-service", time.Hour))
+	_, err = attest(oidc.WithTokenEnv("WITNESS_ID_TOKEN"), oidc.WithIssuer(issuer.server.URL))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "audience")
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte(issuer.token(t, "witness", -time.Minute)+"\n"), 0600))
+	_, err = attest(oidc.WithTokenFile(tokenFile), oidc.WithIssuer(issuer.server.URL))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "expired")
+
+	// a token signed by a key the issuer doesn't publish is rejected
+	forger := newOIDCIssuer(t)
+	forged := forger.token(t, "witness", time.Hour)
+	forgedParts, err := jose.ParseSigned(forged)
+	require.NoError(t, err)
+	claims := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(forgedParts.UnsafePayloadWithoutVerification(), &claims))
+	claims["iss"] = issuer.server.URL
+	forged, err = jwt.Signed(forger.signer).Claims(claims).CompactSerialize()
+	require.NoError(t, err)
+	t.Setenv("WITNESS_ID_TOKEN", forged)
+	_, err = attest(oidc.WithTokenEnv("WITNESS_ID_TOKEN"), oidc.WithIssuer(issuer.server.URL))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to verify identity token")
+}
@@ -0,0 +1,128 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+	"github.com/testifysec/witness/attestation/packages"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/runner"
+)
+
+func writeTarGz(t *testing.T, path string, files map[string]string) {
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+}
+
+func writeZip(t *testing.T, path string, files map[string]string) {
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, zw.Close())
+}
+
+func TestRunPackageAttestations(t *testing.T) {
+	built := t.TempDir()
+	writeTarGz(t, filepath.Join(built, "testifysec-witness-1.0.0.tgz"), map[string]string{
+		"package/package.json": `{"name": "@testifysec/witness", "version": "1.0.0"}`,
+	})
+	writeTarGz(t, filepath.Join(built, "Zope.Interface-5.4.0.tar.gz"), map[string]string{
+		"Zope.Interface-5.4.0/PKG-INFO": "Metadata-Version: 2.1\nName: Zope.Interface\nVersion: 5.4.0\n\nDescription",
+	})
+	writeZip(t, filepath.Join(built, "zope_interface-5.4.0-py3-none-any.whl"), map[string]string{
+		"zope_interface-5.4.0.dist-info/METADATA": "Metadata-Version: 2.1\nName: zope.interface\nVersion: 5.4.0\n",
+	})
+
+	priv, _ := rsakeypair(t)
+	workingDir := t.TempDir()
+	attestDir := filepath.Join(t.TempDir(), "publish")
+	attestationPath := filepath.Join(t.TempDir(), "outfile.txt")
+	runOptions := options.RunOptions{
+		KeyOptions:       options.KeyOptions{KeyPath: priv.Name()},
+		WorkingDir:       workingDir,
+		Attestations:     []string{},
+		OutFilePath:      attestationPath,
+		StepName:         "teststep",
+		PackageAttestDir: attestDir,
+	}
+
+	require.NoError(t, runRun(context.Background(), runOptions, []string{"bash", "-c", "cp " + built + "/* ."}))
+	stmt, collection := readCollection(t, attestationPath)
+	attestor := findAttestor[*packages.Attestor](collection)
+	require.NotNil(t, attestor)
+	purls := []string{}
+	for _, pkg := range attestor.Packages {
+		purls = append(purls, pkg.PURL)
+	}
+
+	assert.Equal(t, []string{"pkg:pypi/zope-interface@5.4.0", "pkg:npm/%40testifysec/witness@1.0.0", "pkg:pypi/zope-interface@5.4.0"}, purls)
+	subjects := []string{}
+	for _, subject := range stmt.Subject {
+		subjects = append(subjects, subject.Name)
+	}
+
+	assert.Contains(t, subjects, packages.Type+"/pkg:pypi/zope-interface@5.4.0?file_name=Zope.Interface-5.4.0.tar.gz")
+	assert.Contains(t, subjects, packages.Type+"/pkg:pypi/zope-interface@5.4.0?file_name=zope_interface-5.4.0-py3-none-any.whl")
+
+	npmEnvBytes, err := os.ReadFile(filepath.Join(attestDir, "testifysec-witness-1.0.0.tgz.publish.intoto.json"))
+	require.NoError(t, err)
+	npmEnv := dsse.Envelope{}
+	require.NoError(t, json.Unmarshal(npmEnvBytes, &npmEnv))
+	npmStmt := intoto.Statement{}
+	require.NoError(t, json.Unmarshal(npmEnv.Payload, &npmStmt))
+	assert.Equal(t, runner.NPMPublishPredicateType, npmStmt.PredicateType)
+	assert.Equal(t, "pkg:npm/%40testifysec/witness@1.0.0", npmStmt.Subject[0].Name)
+	assert.Len(t, npmStmt.Subject[0].Digest["sha512"], 128)
+
+	wheelEnvBytes, err := os.ReadFile(filepath.Join(attestDir, "zope_interface-5.4.0-py3-none-any.whl.publish.intoto.json"))
+	require.NoError(t, err)
+	wheelEnv := dsse.Envelope{}
+	require.NoError(t, json.Unmarshal(wheelEnvBytes, &wheelEnv))
+	wheelStmt := intoto.Statement{}
+	require.NoError(t, json.Unmarshal(wheelEnv.Payload, &wheelStmt))
+	assert.Equal(t, runner.PyPIPublishPredicateType, wheelStmt.PredicateType)
+	assert.Equal(t, "zope_interface-5.4.0-py3-none-any.whl", wheelStmt.Subject[0].Name)
+}
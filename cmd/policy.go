@@ -0,0 +1,352 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/testifysec/go-witness"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/source"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/fips"
+)
+
+const (
+	// policyTestManifest is the file in a fixture directory that lists the policy tests
+	policyTestManifest = "tests.json"
+
+	policyTestExpectPass = "pass"
+	policyTestExpectFail = "fail"
+)
+
+const policyTestLong = `Evaluates a signed policy against fixture attestations and checks each test gets the expected outcome, so
+policy changes can be tested in CI before they're rolled out.
+
+The fixture directory must contain a tests.json file listing the tests:
+
+  {
+    "tests": [
+      {
+        "name": "signed build and test pass",
+        "attestations": ["build.json", "test.json"],
+        "artifact": "app.tar",
+        "expect": "pass"
+      },
+      {
+        "name": "missing test step fails",
+        "attestations": ["build.json"],
+        "subjects": ["<sha256 digest>"],
+        "expect": "fail",
+        "error": "test"
+      }
+    ]
+  }
+
+Attestation and artifact paths are relative to the fixture directory. The policy is verified for the digest of
+artifact and the sha256 digests in subjects; if neither is set, every subject of the test's attestations is used.
+Tests expected to fail can set error to a substring the verification error must contain.`
+
+func PolicyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:               "policy",
+		Short:             "Tools for writing and rolling out witness policies",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+	}
+
+	cmd.AddCommand(PolicyTestCmd())
+	cmd.AddCommand(PolicyShadowCmd())
+	return cmd
+}
+
+func PolicyTestCmd() *cobra.Command {
+	po := options.PolicyTestOptions{}
+	cmd := &cobra.Command{
+		Use:               "test [fixture directory]",
+		Short:             "Tests a policy against fixture attestations",
+		Long:              policyTestLong,
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPolicyTest(cmd.Context(), cmd.OutOrStdout(), po, args[0])
+		},
+		Args: cobra.ExactArgs(1),
+	}
+
+	po.AddFlags(cmd)
+	return cmd
+}
+
+type policyTestCase struct {
+	Name         string   `json:"name"`
+	Attestations []string `json:"attestations"`
+	Artifact     string   `json:"artifact,omitempty"`
+	Subjects     []string `json:"subjects,omitempty"`
+	Expect       string   `json:"expect"`
+	Error        string   `json:"error,omitempty"`
+}
+
+type policyTestSuite struct {
+	Tests []policyTestCase `json:"tests"`
+}
+
+// policyTestResult is the outcome of a single policy test. err describes why the test failed, and is nil if it passed.
+type policyTestResult struct {
+	name string
+	err  error
+}
+
+func runPolicyTest(ctx context.Context, w io.Writer, po options.PolicyTestOptions, dir string) error {
+	if po.KeyPath == "" {
+		return fmt.Errorf("must supply the policy signer's public key")
+	}
+
+	verifier, err := loadPolicyVerifier(po.KeyPath)
+	if err != nil {
+		return err
+	}
+
+	policyEnvelope, err := loadPolicyEnvelope(po.PolicyFilePath)
+	if err != nil {
+		return err
+	}
+
+	suite, err := loadPolicyTestSuite(filepath.Join(dir, policyTestManifest))
+	if err != nil {
+		return err
+	}
+
+	results := make([]policyTestResult, 0, len(suite.Tests))
+	for _, test := range suite.Tests {
+		results = append(results, policyTestResult{
+			name: test.Name,
+			err:  runPolicyTestCase(ctx, po, policyEnvelope, verifier, dir, test),
+		})
+	}
+
+	failed := printPolicyTestResults(w, results)
+	if failed > 0 {
+		return withExitCode(ExitCodePolicy, fmt.Errorf("%d of %d policy tests failed", failed, len(results)))
+	}
+
+	return nil
+}
+
+func loadPolicyTestSuite(path string) (policyTestSuite, error) {
+	suite := policyTestSuite{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return suite, fmt.Errorf("failed to read policy tests: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &suite); err != nil {
+		return suite, fmt.Errorf("failed to parse policy tests %v: %w", path, err)
+	}
+
+	if len(suite.Tests) == 0 {
+		return suite, fmt.Errorf("no policy tests found in %v", path)
+	}
+
+	names := make(map[string]struct{})
+	for i, test := range suite.Tests {
+		if test.Name == "" {
+			return suite, fmt.Errorf("policy test %d has no name", i)
+		}
+
+		if _, ok := names[test.Name]; ok {
+			return suite, fmt.Errorf("policy test %q is defined more than once", test.Name)
+		}
+
+		names[test.Name] = struct{}{}
+		if test.Expect != policyTestExpectPass && test.Expect != policyTestExpectFail {
+			return suite, fmt.Errorf("policy test %q must expect %v or %v", test.Name, policyTestExpectPass, policyTestExpectFail)
+		}
+
+		if test.Error != "" && test.Expect != policyTestExpectFail {
+			return suite, fmt.Errorf("policy test %q expects an error but is expected to pass", test.Name)
+		}
+
+		if len(test.Attestations) == 0 {
+			return suite, fmt.Errorf("policy test %q has no attestations", test.Name)
+		}
+	}
+
+	return suite, nil
+}
+
+// runPolicyTestCase verifies the policy against the test's fixtures and returns an error if the outcome isn't
+// the one the test expects
+func runPolicyTestCase(ctx context.Context, po options.PolicyTestOptions, policyEnvelope dsse.Envelope, verifier cryptoutil.Verifier, dir string, test policyTestCase) error {
+	memSource := newCollectionMemorySource()
+	for _, path := range test.Attestations {
+		if err := memSource.LoadFile(fixturePath(dir, path)); err != nil {
+			return fmt.Errorf("failed to load attestation file %v: %w", path, err)
+		}
+	}
+
+	subjects, err := policyTestSubjects(dir, test, memSource)
+	if err != nil {
+		return err
+	}
+
+	var collectionSource source.Sourcer = memSource
+	if po.ValidateSchemas {
+		collectionSource = schemaValidatingSource{collectionSource}
+	}
+
+	if ro.FIPS {
+		collectionSource = fipsSource{collectionSource}
+	}
+
+	_, verifyErr := witness.Verify(
+		ctx,
+		policyEnvelope,
+		[]cryptoutil.Verifier{verifier},
+		witness.VerifyWithSubjectDigests(subjects),
+		witness.VerifyWithCollectionSource(collectionSource),
+	)
+
+	switch {
+	case test.Expect == policyTestExpectPass && verifyErr != nil:
+		return fmt.Errorf("expected the policy to pass: %w", verifyErr)
+	case test.Expect == policyTestExpectFail && verifyErr == nil:
+		return fmt.Errorf("expected the policy to fail, but it passed")
+	case test.Expect == policyTestExpectFail && !strings.Contains(verifyErr.Error(), test.Error):
+		return fmt.Errorf("expected an error containing %q: %w", test.Error, verifyErr)
+	}
+
+	return nil
+}
+
+// policyTestSubjects returns the subjects a test verifies the policy for: the digest of its artifact and its
+// subjects, or every subject of its attestations if it has neither
+func policyTestSubjects(dir string, test policyTestCase, memSource *collectionMemorySource) ([]cryptoutil.DigestSet, error) {
+	subjects := []cryptoutil.DigestSet{}
+	if test.Artifact != "" {
+		digestSet, err := artifactDigestFromPath(fixturePath(dir, test.Artifact), []crypto.Hash{crypto.SHA256})
+		if err != nil {
+			return nil, fmt.Errorf("failed to calculate artifact digest: %w", err)
+		}
+
+		subjects = append(subjects, digestSet)
+	}
+
+	for _, digest := range test.Subjects {
+		subjects = append(subjects, cryptoutil.DigestSet{crypto.SHA256: digest})
+	}
+
+	if len(subjects) > 0 {
+		return subjects, nil
+	}
+
+	for _, subject := range memSource.Subjects() {
+		digestSet, err := cryptoutil.NewDigestSet(subject.Digest)
+		if err != nil {
+			continue
+		}
+
+		subjects = append(subjects, digestSet)
+	}
+
+	return subjects, nil
+}
+
+// fixturePath resolves path relative to the fixture directory
+func fixturePath(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+
+	return filepath.Join(dir, path)
+}
+
+// printPolicyTestResults writes a table with the result of each policy test and a summary, and returns the number
+// that failed
+func printPolicyTestResults(w io.Writer, results []policyTestResult) int {
+	failed := 0
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TEST\tRESULT\tDETAILS")
+	for _, result := range results {
+		if result.err != nil {
+			failed++
+			// verification errors can span several lines, which would break the table
+			fmt.Fprintf(tw, "%s\tFAILED\t%v\n", result.name, strings.Join(strings.Fields(result.err.Error()), " "))
+			continue
+		}
+
+		fmt.Fprintf(tw, "%s\tPASSED\t\n", result.name)
+	}
+
+	tw.Flush()
+	fmt.Fprintf(w, "\n%d passed, %d failed\n", len(results)-failed, failed)
+	return failed
+}
+
+// loadPolicyVerifier loads the public key a policy is signed with
+func loadPolicyVerifier(path string) (cryptoutil.Verifier, error) {
+	keyFile, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open key file: %w", err)
+	}
+
+	defer keyFile.Close()
+	verifier, err := cryptoutil.NewVerifierFromReader(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create verifier: %w", err)
+	}
+
+	if ro.FIPS {
+		if err := fips.CheckVerifier(verifier); err != nil {
+			return nil, withExitCode(ExitCodeSignature, fmt.Errorf("policy signer is not FIPS compliant: %w", err))
+		}
+	}
+
+	return verifier, nil
+}
+
+// loadPolicyEnvelope reads a signed policy without verifying it
+func loadPolicyEnvelope(path string) (dsse.Envelope, error) {
+	policyEnvelope := dsse.Envelope{}
+	inFile, err := os.Open(path)
+	if err != nil {
+		return policyEnvelope, fmt.Errorf("failed to open policy: %w", err)
+	}
+
+	defer inFile.Close()
+	if err := json.NewDecoder(inFile).Decode(&policyEnvelope); err != nil {
+		return policyEnvelope, fmt.Errorf("could not unmarshal policy envelope: %w", err)
+	}
+
+	if ro.FIPS {
+		if err := checkPolicyFIPS(policyEnvelope); err != nil {
+			return policyEnvelope, withExitCode(ExitCodePolicy, err)
+		}
+	}
+
+	return policyEnvelope, nil
+}
@@ -0,0 +1,75 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/witness/options"
+)
+
+func TestPolicyTest(t *testing.T) {
+	f := newVerifyFixture(t)
+	step1 := f.run(t, "step01", f.funcPrivPath, "echo 'test01' > test.txt")
+	step1Digest, err := cryptoutil.CalculateDigestSetFromFile(f.artifactPath, []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+	step2 := f.run(t, "step02", f.funcPrivPath, "echo 'test02' >> test.txt")
+
+	fixtureDir := t.TempDir()
+	require.NoError(t, os.Rename(step1, filepath.Join(fixtureDir, "step01.json")))
+	require.NoError(t, os.Rename(step2, filepath.Join(fixtureDir, "step02.json")))
+	writeSuite := func(tests ...policyTestCase) {
+		data, err := json.Marshal(policyTestSuite{Tests: tests})
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(fixtureDir, policyTestManifest), data, 0644))
+	}
+
+	po := options.PolicyTestOptions{KeyPath: f.policyPubPath, PolicyFilePath: f.policyPath, ValidateSchemas: true}
+	writeSuite(
+		policyTestCase{Name: "both steps", Attestations: []string{"step01.json", "step02.json"}, Artifact: f.artifactPath, Subjects: []string{step1Digest[crypto.SHA256]}, Expect: policyTestExpectPass},
+		policyTestCase{Name: "every subject", Attestations: []string{"step01.json", "step02.json"}, Expect: policyTestExpectPass},
+		policyTestCase{Name: "missing step", Attestations: []string{"step01.json"}, Artifact: f.artifactPath, Expect: policyTestExpectFail, Error: "denied"},
+	)
+
+	out := bytes.Buffer{}
+	require.NoError(t, runPolicyTest(context.Background(), &out, po, fixtureDir), out.String())
+	assert.Contains(t, out.String(), "3 passed, 0 failed")
+
+	writeSuite(
+		policyTestCase{Name: "both steps", Attestations: []string{"step01.json", "step02.json"}, Expect: policyTestExpectFail},
+		policyTestCase{Name: "missing step", Attestations: []string{"step01.json"}, Artifact: f.artifactPath, Expect: policyTestExpectPass},
+		policyTestCase{Name: "missing file", Attestations: []string{"step03.json"}, Expect: policyTestExpectPass},
+	)
+
+	out.Reset()
+	err = runPolicyTest(context.Background(), &out, po, fixtureDir)
+	require.Error(t, err)
+	assert.Equal(t, ExitCodePolicy, ExitCode(err))
+	assert.Contains(t, out.String(), "expected the policy to fail")
+	assert.Contains(t, out.String(), "failed to load attestation file step03.json")
+	assert.Contains(t, out.String(), "0 passed, 3 failed")
+
+	writeSuite(policyTestCase{Name: "no outcome", Attestations: []string{"step01.json"}})
+	require.ErrorContains(t, runPolicyTest(context.Background(), &out, po, fixtureDir), "must expect pass or fail")
+}
@@ -0,0 +1,269 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/testifysec/go-witness"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/source"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/cache"
+	"github.com/testifysec/witness/pkg/transport"
+)
+
+const (
+	shadowUnchanged       = "unchanged"
+	shadowNewlyDenied     = "newly denied"
+	shadowNewlyAllowed    = "newly allowed"
+	shadowEvidenceChanged = "evidence changed"
+)
+
+const policyShadowLong = `Evaluates a candidate policy and the policy currently in use against the attestations already stored in
+Archivist for each subject, and reports where the candidate's decision or the evidence it accepts differs. Use it
+to see which artifacts a policy change would start denying before rolling it out.
+
+Neither policy is enforced: the command exits with code 0 whatever the policies decide, and only fails if the
+policies or attestations can't be read.`
+
+func PolicyShadowCmd() *cobra.Command {
+	po := options.PolicyShadowOptions{}
+	cmd := &cobra.Command{
+		Use:               "shadow",
+		Short:             "Compares a candidate policy with the current policy against stored attestations",
+		Long:              policyShadowLong,
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPolicyShadow(cmd.Context(), cmd.OutOrStdout(), po)
+		},
+	}
+
+	po.AddFlags(cmd)
+	return cmd
+}
+
+// shadowPolicy is a signed policy and the key it's verified with
+type shadowPolicy struct {
+	envelope dsse.Envelope
+	verifier cryptoutil.Verifier
+}
+
+// policyDecision is the outcome of verifying one policy for a subject
+type policyDecision struct {
+	err      error
+	evidence []string
+}
+
+// shadowResult compares the current and candidate policies' decisions for a subject
+type shadowResult struct {
+	subject   string
+	current   policyDecision
+	candidate policyDecision
+}
+
+func (r shadowResult) change() string {
+	switch {
+	case r.current.err == nil && r.candidate.err != nil:
+		return shadowNewlyDenied
+	case r.current.err != nil && r.candidate.err == nil:
+		return shadowNewlyAllowed
+	case r.current.err == nil && strings.Join(r.current.evidence, ",") != strings.Join(r.candidate.evidence, ","):
+		return shadowEvidenceChanged
+	}
+
+	return shadowUnchanged
+}
+
+func runPolicyShadow(ctx context.Context, w io.Writer, po options.PolicyShadowOptions) error {
+	if po.KeyPath == "" {
+		return fmt.Errorf("must supply the candidate policy signer's public key")
+	}
+
+	if po.CurrentPolicyFilePath == "" {
+		return fmt.Errorf("must supply the current policy to compare the candidate with")
+	}
+
+	if len(po.Subjects) == 0 {
+		return fmt.Errorf("must supply at least one subject to evaluate the policies for")
+	}
+
+	subjects := make([]cryptoutil.DigestSet, 0, len(po.Subjects))
+	for _, subject := range po.Subjects {
+		digestSet, err := parseSubjectDigest(subject)
+		if err != nil {
+			return err
+		}
+
+		subjects = append(subjects, digestSet)
+	}
+
+	candidate, err := loadShadowPolicy(po.PolicyFilePath, po.KeyPath)
+	if err != nil {
+		return err
+	}
+
+	currentKeyPath := po.CurrentKeyPath
+	if currentKeyPath == "" {
+		currentKeyPath = po.KeyPath
+	}
+
+	current, err := loadShadowPolicy(po.CurrentPolicyFilePath, currentKeyPath)
+	if err != nil {
+		return err
+	}
+
+	var shadowCache *cache.Cache
+	if po.CacheOptions.Dir != "" {
+		if shadowCache, err = cache.New(po.CacheOptions.Dir, po.CacheOptions.TTL); err != nil {
+			return err
+		}
+	}
+
+	archivistURL, err := transport.ResolveURL(po.ArchivistURL)
+	if err != nil {
+		return err
+	}
+
+	// the archivist source remembers which envelopes it has already returned, so each verification gets its own
+	newSource := func() source.Sourcer {
+		var collectionSource source.Sourcer = newCachingArchivistSource(archivistURL, shadowCache)
+		if po.ValidateSchemas {
+			collectionSource = schemaValidatingSource{collectionSource}
+		}
+
+		if ro.FIPS {
+			collectionSource = fipsSource{collectionSource}
+		}
+
+		return collectionSource
+	}
+
+	results, err := shadowPolicies(ctx, current, candidate, po.Subjects, subjects, newSource)
+	if err != nil {
+		return err
+	}
+
+	printShadowResults(w, results)
+	return nil
+}
+
+// shadowPolicies verifies the current and candidate policies for each subject, using a fresh source from
+// newSource for every verification
+func shadowPolicies(ctx context.Context, current, candidate shadowPolicy, names []string, subjects []cryptoutil.DigestSet, newSource func() source.Sourcer) ([]shadowResult, error) {
+	results := make([]shadowResult, 0, len(subjects))
+	for i, subject := range subjects {
+		result := shadowResult{subject: names[i]}
+		for _, eval := range []struct {
+			policy   shadowPolicy
+			decision *policyDecision
+		}{
+			{current, &result.current},
+			{candidate, &result.candidate},
+		} {
+			recorder := newEvidenceRecorder(newSource())
+			verifiedEvidence, err := witness.Verify(
+				ctx,
+				eval.policy.envelope,
+				[]cryptoutil.Verifier{eval.policy.verifier},
+				witness.VerifyWithSubjectDigests([]cryptoutil.DigestSet{subject}),
+				witness.VerifyWithCollectionSource(recorder),
+			)
+
+			// a decision can't be compared if the attestations couldn't be fetched
+			if err != nil && verifyExitCode(err, eval.policy.envelope, recorder) == ExitCodeInfrastructure {
+				return nil, withExitCode(ExitCodeInfrastructure, fmt.Errorf("failed to fetch attestations for %v: %w", names[i], err))
+			}
+
+			*eval.decision = policyDecision{err: err, evidence: evidenceReferences(verifiedEvidence)}
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// printShadowResults writes a table comparing the current and candidate policies' decisions for each subject
+func printShadowResults(w io.Writer, results []shadowResult) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SUBJECT\tCURRENT\tCANDIDATE\tCHANGE\tDETAILS")
+	changed := 0
+	for _, result := range results {
+		change := result.change()
+		details := ""
+		switch change {
+		case shadowNewlyDenied:
+			details = strings.Join(strings.Fields(result.candidate.err.Error()), " ")
+		case shadowEvidenceChanged:
+			details = fmt.Sprintf("current: %v; candidate: %v", strings.Join(result.current.evidence, ", "), strings.Join(result.candidate.evidence, ", "))
+		}
+
+		if change != shadowUnchanged {
+			changed++
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", result.subject, decisionResult(result.current), decisionResult(result.candidate), change, details)
+	}
+
+	tw.Flush()
+	fmt.Fprintf(w, "\n%d of %d subjects would change\n", changed, len(results))
+}
+
+func decisionResult(decision policyDecision) string {
+	if decision.err != nil {
+		return "DENIED"
+	}
+
+	return "ALLOWED"
+}
+
+func loadShadowPolicy(policyPath, keyPath string) (shadowPolicy, error) {
+	verifier, err := loadPolicyVerifier(keyPath)
+	if err != nil {
+		return shadowPolicy{}, err
+	}
+
+	envelope, err := loadPolicyEnvelope(policyPath)
+	if err != nil {
+		return shadowPolicy{}, err
+	}
+
+	return shadowPolicy{envelope: envelope, verifier: verifier}, nil
+}
+
+// parseSubjectDigest parses a digest such as sha256:abc123 into a digest set. Digests without an algorithm are sha256.
+func parseSubjectDigest(subject string) (cryptoutil.DigestSet, error) {
+	name, digest, ok := strings.Cut(subject, ":")
+	if !ok {
+		return cryptoutil.DigestSet{crypto.SHA256: subject}, nil
+	}
+
+	hash, err := cryptoutil.HashFromString(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subject %v: %w", subject, err)
+	}
+
+	return cryptoutil.DigestSet{hash: digest}, nil
+}
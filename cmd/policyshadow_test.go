@@ -0,0 +1,96 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/policy"
+	"github.com/testifysec/go-witness/source"
+)
+
+func TestShadowPolicies(t *testing.T) {
+	f := newVerifyFixture(t)
+	step1 := f.run(t, "step01", f.funcPrivPath, "echo 'test01' > test.txt")
+	step1Digest, err := cryptoutil.CalculateDigestSetFromFile(f.artifactPath, []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+	step2 := f.run(t, "step02", f.funcPrivPath, "echo 'test02' >> test.txt")
+	step2Digest, err := cryptoutil.CalculateDigestSetFromFile(f.artifactPath, []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+
+	current, err := loadShadowPolicy(f.policyPath, f.policyPubPath)
+	require.NoError(t, err)
+
+	// the candidate drops the requirement for step02
+	pol := policy.Policy{}
+	require.NoError(t, json.Unmarshal(current.envelope.Payload, &pol))
+	delete(pol.Steps, "step02")
+	polBytes, err := json.Marshal(pol)
+	require.NoError(t, err)
+	signedCandidate, candidatePub := signPolicyRSA(t, polBytes)
+	candidatePath, candidatePubPath := filepath.Join(f.workingDir, "candidate.json"), filepath.Join(f.workingDir, "candidate-pub.pem")
+	require.NoError(t, os.WriteFile(candidatePath, signedCandidate, 0644))
+	require.NoError(t, os.WriteFile(candidatePubPath, candidatePub, 0644))
+	candidate, err := loadShadowPolicy(candidatePath, candidatePubPath)
+	require.NoError(t, err)
+
+	newSource := func() source.Sourcer {
+		memSource := newCollectionMemorySource()
+		require.NoError(t, memSource.LoadFile(step1))
+		require.NoError(t, memSource.LoadFile(step2))
+		return memSource
+	}
+
+	results, err := shadowPolicies(context.Background(), current, candidate, []string{"step01", "step02"}, []cryptoutil.DigestSet{step1Digest, step2Digest}, newSource)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, shadowNewlyAllowed, results[0].change())
+	// neither policy finds step01 from the final artifact alone
+	assert.Equal(t, shadowUnchanged, results[1].change())
+	assert.Error(t, results[1].candidate.err)
+
+	results, err = shadowPolicies(context.Background(), candidate, current, []string{"step01"}, []cryptoutil.DigestSet{step1Digest}, newSource)
+	require.NoError(t, err)
+	assert.Equal(t, shadowNewlyDenied, results[0].change())
+
+	// comparing a policy with itself changes nothing
+	results, err = shadowPolicies(context.Background(), current, current, []string{"step01", "step02"}, []cryptoutil.DigestSet{step1Digest, step2Digest}, newSource)
+	require.NoError(t, err)
+	out := bytes.Buffer{}
+	printShadowResults(&out, results)
+	assert.Contains(t, out.String(), "0 of 2 subjects would change")
+}
+
+func TestParseSubjectDigest(t *testing.T) {
+	digestSet, err := parseSubjectDigest("sha256:abc")
+	require.NoError(t, err)
+	assert.Equal(t, cryptoutil.DigestSet{crypto.SHA256: "abc"}, digestSet)
+
+	digestSet, err = parseSubjectDigest("abc")
+	require.NoError(t, err)
+	assert.Equal(t, cryptoutil.DigestSet{crypto.SHA256: "abc"}, digestSet)
+
+	_, err = parseSubjectDigest("md5:abc")
+	require.Error(t, err)
+}
@@ -0,0 +1,79 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/pqsign"
+)
+
+func PQKeygenCmd() *cobra.Command {
+	o := options.PQKeygenOptions{}
+	cmd := &cobra.Command{
+		Use:   "pq-keygen",
+		Short: "Generates a post-quantum key pair for hybrid signatures",
+		Long: "Generates a Dilithium key pair. Pass the private key to witness run or witness sign with --pq-key to add a " +
+			"post-quantum signature alongside the classical one, and the public key to witness verify with --pq-publickey to require it",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPQKeygen(o)
+		},
+		Args: cobra.NoArgs,
+	}
+
+	o.AddFlags(cmd)
+	return cmd
+}
+
+func runPQKeygen(o options.PQKeygenOptions) error {
+	privPEM, pubPEM, err := pqsign.GenerateKey(o.Mode)
+	if err != nil {
+		return err
+	}
+
+	privPath, pubPath := o.OutFilePrefix+".key", o.OutFilePrefix+".pub"
+	if err := writeNewFile(privPath, privPEM, 0600); err != nil {
+		return fmt.Errorf("failed to write private key: %w", err)
+	}
+
+	if err := writeNewFile(pubPath, pubPEM, 0644); err != nil {
+		return fmt.Errorf("failed to write public key: %w", err)
+	}
+
+	log.Infof("Wrote %v private key to %v and public key to %v", o.Mode, privPath, pubPath)
+	return nil
+}
+
+// writeNewFile writes data to path, failing rather than overwriting an existing key
+func writeNewFile(path string, data []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
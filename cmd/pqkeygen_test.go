@@ -0,0 +1,113 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	witness "github.com/testifysec/go-witness"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/pqsign"
+)
+
+func TestVerifyHybridSignatures(t *testing.T) {
+	dir := t.TempDir()
+	pqPrefix := filepath.Join(dir, "pq")
+	require.NoError(t, runPQKeygen(options.PQKeygenOptions{Mode: pqsign.DefaultMode, OutFilePrefix: pqPrefix}))
+	assert.Error(t, runPQKeygen(options.PQKeygenOptions{Mode: pqsign.DefaultMode, OutFilePrefix: pqPrefix}))
+	pqKeyFile, err := os.Open(pqPrefix + ".key")
+	require.NoError(t, err)
+	defer pqKeyFile.Close()
+	pqSigner, err := pqsign.LoadSigner(pqKeyFile)
+	require.NoError(t, err)
+
+	policyBytes, funcPriv := makepolicyRSAPub(t)
+	policySigner, _, policyPub, _, err := createTestRSAKey()
+	require.NoError(t, err)
+	signPolicy := func(signers ...cryptoutil.Signer) string {
+		signed := &bytes.Buffer{}
+		require.NoError(t, witness.Sign(bytes.NewReader(policyBytes), "https://witness.testifysec.com/policy/v0.1", signed, dsse.SignWithSigners(signers...)))
+		path := filepath.Join(t.TempDir(), "policy.json")
+		require.NoError(t, os.WriteFile(path, signed.Bytes(), 0644))
+		return path
+	}
+
+	hybridPolicy := signPolicy(policySigner, pqSigner)
+	classicalPolicy := signPolicy(policySigner)
+	policyPubPath := filepath.Join(dir, "policy-pub.pem")
+	funcPrivPath := filepath.Join(dir, "func-priv.pem")
+	require.NoError(t, os.WriteFile(policyPubPath, policyPub, 0644))
+	require.NoError(t, os.WriteFile(funcPrivPath, funcPriv, 0600))
+
+	workingDir := t.TempDir()
+	run := func(step, pqKeyPath, script string) string {
+		outPath := filepath.Join(t.TempDir(), step+".json")
+		require.NoError(t, runRun(context.Background(), options.RunOptions{
+			KeyOptions:  options.KeyOptions{KeyPath: funcPrivPath, PQKeyPath: pqKeyPath},
+			WorkingDir:  workingDir,
+			OutFilePath: outPath,
+			StepName:    step,
+		}, []string{"bash", "-c", script}))
+		return outPath
+	}
+
+	step1 := run("step01", pqPrefix+".key", "echo 'test01' > test.txt")
+	step1Digest, err := cryptoutil.CalculateDigestSetFromFile(filepath.Join(workingDir, "test.txt"), []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+	classicalStep2 := run("step02", "", "echo 'test02' >> test.txt")
+	require.NoError(t, os.WriteFile(filepath.Join(workingDir, "test.txt"), []byte("test01\n"), 0644))
+	step2 := run("step02", pqPrefix+".key", "echo 'test02' >> test.txt")
+
+	env := dsse.Envelope{}
+	step2Bytes, err := os.ReadFile(step2)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(step2Bytes, &env))
+	assert.Len(t, env.Signatures, 2)
+
+	verifyOptions := func(policyPath string, attestations ...string) options.VerifyOptions {
+		return options.VerifyOptions{
+			KeyPath:              policyPubPath,
+			PolicyFilePath:       policyPath,
+			AttestationFilePaths: attestations,
+			ArtifactFilePath:     filepath.Join(workingDir, "test.txt"),
+			AdditionalSubjects:   []string{step1Digest[crypto.SHA256]},
+			PQKeyPaths:           []string{pqPrefix + ".pub"},
+		}
+	}
+
+	require.NoError(t, runVerify(context.Background(), verifyOptions(hybridPolicy, step1, step2)))
+
+	err = runVerify(context.Background(), verifyOptions(hybridPolicy, step1, classicalStep2))
+	require.Error(t, err)
+	assert.Equal(t, ExitCodeMissingAttestations, ExitCode(err))
+
+	err = runVerify(context.Background(), verifyOptions(classicalPolicy, step1, step2))
+	require.Error(t, err)
+	assert.Equal(t, ExitCodeSignature, ExitCode(err))
+
+	classicalOnly := verifyOptions(classicalPolicy, step1, classicalStep2)
+	classicalOnly.PQKeyPaths = nil
+	require.NoError(t, runVerify(context.Background(), classicalOnly))
+}
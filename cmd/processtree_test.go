@@ -0,0 +1,62 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/witness/attestation/processtree"
+	"github.com/testifysec/witness/options"
+)
+
+func TestRunProcessTreeAttestation(t *testing.T) {
+	priv, _ := rsakeypair(t)
+	attestationPath := filepath.Join(t.TempDir(), "outfile.txt")
+	runOptions := options.RunOptions{
+		KeyOptions:   options.KeyOptions{KeyPath: priv.Name()},
+		WorkingDir:   t.TempDir(),
+		Attestations: []string{"process-tree"},
+		OutFilePath:  attestationPath,
+		StepName:     "build",
+	}
+
+	args := []string{"sh", "-c", "sh -c 'exit 3' || true"}
+	require.ErrorContains(t, runRun(context.Background(), runOptions, args), "requires --trace")
+
+	runOptions.Tracing = true
+	attestor := runAndGetAttestor[*processtree.Attestor](t, runOptions, args)
+	require.NotNil(t, attestor.Root)
+	assert.Equal(t, "sh", filepath.Base(attestor.Root.Program))
+	require.NotNil(t, attestor.Root.ExitCode)
+	assert.Equal(t, 0, *attestor.Root.ExitCode)
+	if !attestor.Monitored {
+		t.Skip("process events are unavailable, so child exit codes aren't recorded")
+	}
+
+	require.Len(t, attestor.Root.Children, 1)
+	child := attestor.Root.Children[0]
+	assert.Equal(t, 2, attestor.ProcessCount)
+	require.NotNil(t, child.ExitCode)
+	assert.Equal(t, 3, *child.ExitCode)
+	assert.Positive(t, child.Duration)
+	assert.Positive(t, child.StartOffset)
+	if len(child.Argv) > 0 {
+		assert.Equal(t, []string{"sh", "-c", "exit 3"}, child.Argv)
+	}
+}
@@ -0,0 +1,239 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/go-witness/timestamp"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/runner"
+	"github.com/testifysec/witness/pkg/spool"
+)
+
+// PromotionPredicateType is the predicate type of the attestations witness promote signs
+const PromotionPredicateType = "https://witness.dev/promotion/v0.1"
+
+const promoteLong = `Verifies an artifact against a policy and, if it passes, signs an attestation recording that the artifact
+was promoted to an environment. The attestation is an in-toto statement whose subjects are the verified artifacts,
+and records the environment, the digest of the policy, and the collections that satisfied it, building an auditable
+trail of what was promoted where and why.
+
+Artifacts are selected with the same flags as witness verify. Nothing is signed if verification fails, and the command
+exits with the same codes as witness verify.`
+
+func PromoteCmd() *cobra.Command {
+	po := options.PromoteOptions{}
+	cmd := &cobra.Command{
+		Use:               "promote",
+		Short:             "Verifies an artifact and records its promotion to an environment",
+		Long:              promoteLong,
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPromote(cmd.Context(), po)
+		},
+	}
+
+	po.AddFlags(cmd)
+	return cmd
+}
+
+// PromotionPolicy identifies the policy an artifact was verified against before it was promoted
+type PromotionPolicy struct {
+	Digest cryptoutil.DigestSet `json:"digest"`
+}
+
+// Promotion is the predicate of a promotion attestation
+type Promotion struct {
+	Environment string          `json:"environment"`
+	Policy      PromotionPolicy `json:"policy"`
+	Evidence    []string        `json:"evidence"`
+	PromotedAt  time.Time       `json:"promotedat"`
+}
+
+func runPromote(ctx context.Context, po options.PromoteOptions) error {
+	if po.Environment == "" {
+		return fmt.Errorf("must supply the environment the artifact is promoted to")
+	}
+
+	if po.KeyOptions.KeyPath == "" {
+		return fmt.Errorf("must supply a key to sign the promotion attestation with")
+	}
+
+	// load the signer first so a bad key is reported before the artifact is verified
+	signers, err := loadStatementSigners(ctx, po.KeyOptions)
+	if err != nil {
+		return err
+	}
+
+	targets, err := verifyPolicy(ctx, po.VerifyOptions)
+	if err != nil {
+		return err
+	}
+
+	policyDigest, err := cryptoutil.CalculateDigestSetFromFile(po.VerifyOptions.PolicyFilePath, []crypto.Hash{crypto.SHA256})
+	if err != nil {
+		return fmt.Errorf("failed to calculate digest of policy: %w", err)
+	}
+
+	promotion := Promotion{
+		Environment: po.Environment,
+		Policy:      PromotionPolicy{Digest: policyDigest},
+		Evidence:    []string{},
+		PromotedAt:  time.Now().UTC(),
+	}
+
+	for _, target := range targets {
+		promotion.Evidence = append(promotion.Evidence, target.evidence...)
+	}
+
+	subjects, err := promotionSubjects(targets)
+	if err != nil {
+		return err
+	}
+
+	env, err := signStatement(subjects, PromotionPredicateType, promotion, signers, po.TimestampServers)
+	if err != nil {
+		return fmt.Errorf("failed to sign promotion attestation: %w", err)
+	}
+
+	if err := writeEnvelope(po.OutFilePath, env); err != nil {
+		return err
+	}
+
+	if po.VerifyOptions.ArchivistOptions.Enable {
+		for _, server := range po.VerifyOptions.ArchivistOptions.Urls {
+			gitoid, err := runner.StoreEnvelope(ctx, spool.BackendArchivist, server, "", env)
+			if err != nil {
+				return withExitCode(ExitCodeInfrastructure, fmt.Errorf("failed to store promotion attestation in archivist %v: %w", server, err))
+			}
+
+			log.Infof("Stored promotion attestation in archivist %v with gitoid %v", server, gitoid)
+		}
+	}
+
+	log.Infof("Promoted %d artifacts to %v", len(targets), po.Environment)
+	return nil
+}
+
+// promotionSubjects returns the artifacts that were promoted. A named target is promoted by the digest of the artifact
+// or reference it names, and other subjects only link it to the evidence. Without an artifact, every subject digest
+// passed on the command line is promoted and named by the digest itself.
+func promotionSubjects(targets []verifyTarget) ([]intoto.Subject, error) {
+	subjects := []intoto.Subject{}
+	seen := make(map[string]struct{})
+	for _, target := range targets {
+		digestSets := target.subjects
+		if target.name != "" && len(digestSets) > 0 {
+			digestSets = digestSets[:1]
+		}
+
+		for _, digestSet := range digestSets {
+			digests, err := digestSet.ToNameMap()
+			if err != nil {
+				return nil, err
+			}
+
+			name := filepath.Base(target.name)
+			if target.name == "" {
+				algorithms := make([]string, 0, len(digests))
+				for algorithm := range digests {
+					algorithms = append(algorithms, algorithm)
+				}
+
+				sort.Strings(algorithms)
+				name = fmt.Sprintf("%v:%v", algorithms[0], digests[algorithms[0]])
+			}
+
+			key := fmt.Sprint(name, digests)
+			if _, ok := seen[key]; ok {
+				continue
+			}
+
+			seen[key] = struct{}{}
+			subjects = append(subjects, intoto.Subject{Name: name, Digest: digests})
+		}
+	}
+
+	return subjects, nil
+}
+
+// loadStatementSigners loads the signer for an attestation witness signs on its own, outside of witness run
+func loadStatementSigners(ctx context.Context, ko options.KeyOptions) ([]cryptoutil.Signer, error) {
+	signers, errs := loadSigners(ctx, ko)
+	if len(errs) > 0 {
+		for _, err := range errs {
+			log.Error(err)
+		}
+
+		return nil, fmt.Errorf("failed to load signers")
+	}
+
+	if len(signers) != 1 {
+		return nil, fmt.Errorf("exactly one signer is required, found %d", len(signers))
+	}
+
+	return envelopeSigners(ko, signers[0])
+}
+
+// signStatement signs an in-toto statement with predicate about subjects
+func signStatement(subjects []intoto.Subject, predicateType string, predicate interface{}, signers []cryptoutil.Signer, timestampServers []string) (dsse.Envelope, error) {
+	predicateBytes, err := json.Marshal(predicate)
+	if err != nil {
+		return dsse.Envelope{}, err
+	}
+
+	statementBytes, err := json.Marshal(intoto.Statement{
+		Type:          intoto.StatementType,
+		Subject:       subjects,
+		PredicateType: predicateType,
+		Predicate:     predicateBytes,
+	})
+	if err != nil {
+		return dsse.Envelope{}, err
+	}
+
+	timestampers := []dsse.Timestamper{}
+	for _, url := range timestampServers {
+		timestampers = append(timestampers, timestamp.NewTimestamper(timestamp.TimestampWithUrl(url)))
+	}
+
+	return dsse.Sign(intoto.PayloadType, bytes.NewReader(statementBytes), dsse.SignWithSigners(signers...), dsse.SignWithTimestampers(timestampers...))
+}
+
+// writeEnvelope writes env to outFilePath, or stdout if it's empty
+func writeEnvelope(outFilePath string, env dsse.Envelope) error {
+	out, err := loadOutfile(outFilePath)
+	if err != nil {
+		return err
+	}
+
+	defer out.Close()
+	return json.NewEncoder(out).Encode(env)
+}
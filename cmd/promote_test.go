@@ -0,0 +1,98 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+	"github.com/testifysec/witness/options"
+)
+
+func TestPromote(t *testing.T) {
+	f := newVerifyFixture(t)
+	signingPriv, _ := rsakeypair(t)
+	step1 := f.run(t, "step01", f.funcPrivPath, "echo 'test01' > test.txt")
+	step1Digest, err := cryptoutil.CalculateDigestSetFromFile(f.artifactPath, []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+	step2 := f.run(t, "step02", f.funcPrivPath, "echo 'test02' >> test.txt")
+
+	vo := f.verifyOptions(f.policyPubPath, step1, step2)
+	vo.AdditionalSubjects = []string{step1Digest[crypto.SHA256]}
+	outPath := filepath.Join(t.TempDir(), "promotion.json")
+	require.NoError(t, runPromote(context.Background(), options.PromoteOptions{
+		VerifyOptions: vo,
+		KeyOptions:    options.KeyOptions{KeyPath: signingPriv.Name()},
+		Environment:   "prod",
+		OutFilePath:   outPath,
+	}))
+
+	envBytes, err := os.ReadFile(outPath)
+	require.NoError(t, err)
+	env := dsse.Envelope{}
+	require.NoError(t, json.Unmarshal(envBytes, &env))
+	require.Len(t, env.Signatures, 1)
+	assert.Equal(t, intoto.PayloadType, env.PayloadType)
+
+	statement := intoto.Statement{}
+	require.NoError(t, json.Unmarshal(env.Payload, &statement))
+	assert.Equal(t, PromotionPredicateType, statement.PredicateType)
+	require.Len(t, statement.Subject, 1)
+	assert.Equal(t, "test.txt", statement.Subject[0].Name)
+	assert.Contains(t, statement.Subject[0].Digest, "sha256")
+
+	promotion := Promotion{}
+	require.NoError(t, json.Unmarshal(statement.Predicate, &promotion))
+	assert.Equal(t, "prod", promotion.Environment)
+	assert.NotEmpty(t, promotion.Policy.Digest)
+	assert.Len(t, promotion.Evidence, 2)
+	assert.False(t, promotion.PromotedAt.IsZero())
+}
+
+func TestPromoteFailedVerification(t *testing.T) {
+	f := newVerifyFixture(t)
+	signingPriv, _ := rsakeypair(t)
+	step1 := f.run(t, "step01", f.funcPrivPath, "echo 'test01' > test.txt")
+
+	outPath := filepath.Join(t.TempDir(), "promotion.json")
+	err := runPromote(context.Background(), options.PromoteOptions{
+		VerifyOptions: f.verifyOptions(f.policyPubPath, step1),
+		KeyOptions:    options.KeyOptions{KeyPath: signingPriv.Name()},
+		Environment:   "prod",
+		OutFilePath:   outPath,
+	})
+	require.Error(t, err)
+	assert.NoFileExists(t, outPath)
+}
+
+func TestPromoteRequiresEnvironment(t *testing.T) {
+	f := newVerifyFixture(t)
+	signingPriv, _ := rsakeypair(t)
+	err := runPromote(context.Background(), options.PromoteOptions{
+		VerifyOptions: f.verifyOptions(f.policyPubPath),
+		KeyOptions:    options.KeyOptions{KeyPath: signingPriv.Name()},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "environment")
+}
@@ -0,0 +1,198 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/attestation/annotations"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/cache"
+	"github.com/testifysec/witness/pkg/spool"
+)
+
+func PruneCmd() *cobra.Command {
+	o := options.PruneOptions{}
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Removes expired attestations and cache entries from local storage",
+		Long: "Removes queued uploads and attestation files whose retention, set with witness run --retention, has passed, and " +
+			"verification cache entries older than the cache TTL. Only local storage is pruned. Envelopes already uploaded to " +
+			"Archivist, GitHub, or a transparency log are never touched",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPrune(cmd.OutOrStdout(), o, time.Now())
+		},
+		Args: cobra.NoArgs,
+	}
+
+	o.AddFlags(cmd)
+	return cmd
+}
+
+func runPrune(out io.Writer, o options.PruneOptions, now time.Time) error {
+	verb := "Removed"
+	if o.DryRun {
+		verb = "Would remove"
+	}
+
+	envSpool, err := spool.Open(o.SpoolOptions.Dir)
+	if err != nil {
+		return err
+	}
+
+	entries, err := envSpool.Entries()
+	if err != nil {
+		return fmt.Errorf("failed to read spool: %w", err)
+	}
+
+	for _, entry := range entries {
+		if !entry.Expired(now) {
+			continue
+		}
+
+		if !o.DryRun {
+			if err := envSpool.Remove(entry); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("failed to remove spool entry %v: %w", entry.ID, err)
+			}
+		}
+
+		fmt.Fprintf(out, "%v queued upload %v, expired %v\n", verb, entry.ID, entry.ExpiresAt.Format(time.RFC3339))
+	}
+
+	for _, dir := range o.AttestationDirs {
+		pruned, err := pruneAttestationDir(dir, now, o.DryRun)
+		if err != nil {
+			return err
+		}
+
+		for _, path := range pruned {
+			fmt.Fprintf(out, "%v attestation %v\n", verb, path)
+		}
+	}
+
+	if o.CacheOptions.Dir != "" {
+		verifyCache, err := cache.New(o.CacheOptions.Dir, o.CacheOptions.TTL)
+		if err != nil {
+			return err
+		}
+
+		pruned, err := verifyCache.Prune(o.DryRun)
+		if err != nil {
+			return fmt.Errorf("failed to prune cache: %w", err)
+		}
+
+		for _, path := range pruned {
+			fmt.Fprintf(out, "%v cache entry %v\n", verb, path)
+		}
+	}
+
+	return nil
+}
+
+// pruneAttestationDir removes the signed collections in dir whose expires-at annotation is at or before now. Files
+// that aren't collections, or that have no retention hint, are always kept.
+func pruneAttestationDir(dir string, now time.Time, dryRun bool) ([]string, error) {
+	pruned := []string{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		expiresAt, ok, err := envelopeExpiry(path)
+		if err != nil {
+			log.Warnf("keeping %v: %v", path, err)
+			return nil
+		}
+
+		if !ok || expiresAt.After(now) {
+			return nil
+		}
+
+		if !dryRun {
+			if err := os.Remove(path); err != nil {
+				return fmt.Errorf("failed to remove %v: %w", path, err)
+			}
+		}
+
+		pruned = append(pruned, path)
+		return nil
+	})
+
+	return pruned, err
+}
+
+// envelopeExpiry returns the retention hint recorded in the signed collection at path, and false if the file isn't a
+// collection or has no hint. Attestations are read without knowing every attestor type so unknown types don't matter.
+func envelopeExpiry(path string) (time.Time, bool, error) {
+	envBytes, err := os.ReadFile(path)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	env := dsse.Envelope{}
+	if err := json.Unmarshal(envBytes, &env); err != nil || env.PayloadType != intoto.PayloadType {
+		return time.Time{}, false, nil
+	}
+
+	stmt := intoto.Statement{}
+	if err := json.Unmarshal(env.Payload, &stmt); err != nil || stmt.PredicateType != attestation.CollectionType {
+		return time.Time{}, false, nil
+	}
+
+	collection := struct {
+		Attestations []struct {
+			Type        string          `json:"type"`
+			Attestation json.RawMessage `json:"attestation"`
+		} `json:"attestations"`
+	}{}
+
+	if err := json.Unmarshal(stmt.Predicate, &collection); err != nil {
+		return time.Time{}, false, nil
+	}
+
+	for _, att := range collection.Attestations {
+		if att.Type != annotations.Type {
+			continue
+		}
+
+		attestor := annotations.New()
+		if err := json.Unmarshal(att.Attestation, attestor); err != nil {
+			return time.Time{}, false, fmt.Errorf("failed to parse annotations: %w", err)
+		}
+
+		return attestor.ExpiresAt()
+	}
+
+	return time.Time{}, false, nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/cache"
+	"github.com/testifysec/witness/pkg/spool"
+)
+
+func TestRunPrune(t *testing.T) {
+	priv, _ := rsakeypair(t)
+	workingDir := t.TempDir()
+	attestationDir := t.TempDir()
+	kept := filepath.Join(attestationDir, "kept.json")
+	expiring := filepath.Join(attestationDir, "expiring.json")
+	require.NoError(t, os.WriteFile(filepath.Join(attestationDir, "notes.txt"), []byte("not an envelope"), 0600))
+	runOptions := options.RunOptions{
+		KeyOptions:   options.KeyOptions{KeyPath: priv.Name()},
+		WorkingDir:   workingDir,
+		Attestations: []string{},
+		OutFilePath:  kept,
+		StepName:     "teststep",
+	}
+
+	require.NoError(t, runRun(context.Background(), runOptions, []string{"true"}))
+	runOptions.OutFilePath = expiring
+	runOptions.Retention = time.Hour
+	require.NoError(t, runRun(context.Background(), runOptions, []string{"true"}))
+
+	spoolDir := t.TempDir()
+	envSpool, err := spool.New(spoolDir)
+	require.NoError(t, err)
+	expired, err := envSpool.Enqueue(spool.BackendArchivist, "https://archivist.example.com", dsse.Envelope{}, spool.WithExpiry(time.Now().Add(time.Hour)))
+	require.NoError(t, err)
+	_, err = envSpool.Enqueue(spool.BackendArchivist, "https://archivist.example.com", dsse.Envelope{})
+	require.NoError(t, err)
+
+	cacheDir := t.TempDir()
+	verifyCache, err := cache.New(cacheDir, time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, verifyCache.Put("archivist", "gitoid", []byte("envelope")))
+	past := time.Now().Add(-2 * time.Hour)
+	require.NoError(t, filepath.WalkDir(cacheDir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			err = os.Chtimes(path, past, past)
+		}
+
+		return err
+	}))
+
+	pruneOptions := options.PruneOptions{
+		SpoolOptions:    options.SpoolOptions{Dir: spoolDir},
+		CacheOptions:    options.CacheOptions{Dir: cacheDir, TTL: time.Hour},
+		AttestationDirs: []string{attestationDir},
+		DryRun:          true,
+	}
+
+	later := time.Now().Add(2 * time.Hour)
+	out := &bytes.Buffer{}
+	require.NoError(t, runPrune(out, pruneOptions, later))
+	assert.Contains(t, out.String(), "Would remove queued upload "+expired.ID)
+	assert.Contains(t, out.String(), "Would remove attestation "+expiring)
+	assert.Contains(t, out.String(), "Would remove cache entry archivist/")
+	assert.FileExists(t, expiring)
+
+	out.Reset()
+	require.NoError(t, runPrune(out, options.PruneOptions{SpoolOptions: pruneOptions.SpoolOptions, AttestationDirs: []string{attestationDir}}, time.Now()))
+	assert.Empty(t, out.String())
+
+	pruneOptions.DryRun = false
+	require.NoError(t, runPrune(out, pruneOptions, later))
+	assert.NoFileExists(t, expiring)
+	assert.FileExists(t, kept)
+	assert.FileExists(t, filepath.Join(attestationDir, "notes.txt"))
+	entries, err := envSpool.Entries()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Nil(t, entries[0].ExpiresAt)
+	_, ok := verifyCache.Get("archivist", "gitoid")
+	assert.False(t, ok)
+}
@@ -0,0 +1,206 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/fips"
+)
+
+// VerificationReceiptPredicateType is the predicate type of the receipts witness verify --receipt-out signs
+const VerificationReceiptPredicateType = "https://witness.dev/verification-receipt/v0.1"
+
+// VerificationReceipt is the predicate of a verification receipt. Its subjects are the artifacts that passed.
+type VerificationReceipt struct {
+	Policy     PromotionPolicy `json:"policy"`
+	VerifiedAt time.Time       `json:"verifiedat"`
+}
+
+// writeVerificationReceipt signs a receipt for the verified targets and writes it to the receipt out file
+func writeVerificationReceipt(vo options.VerifyOptions, targets []verifyTarget, signers []cryptoutil.Signer) error {
+	policyDigest, err := cryptoutil.CalculateDigestSetFromFile(vo.PolicyFilePath, []crypto.Hash{crypto.SHA256})
+	if err != nil {
+		return fmt.Errorf("failed to calculate digest of policy: %w", err)
+	}
+
+	subjects, err := promotionSubjects(targets)
+	if err != nil {
+		return err
+	}
+
+	receipt := VerificationReceipt{
+		Policy:     PromotionPolicy{Digest: policyDigest},
+		VerifiedAt: time.Now().UTC(),
+	}
+
+	env, err := signStatement(subjects, VerificationReceiptPredicateType, receipt, signers, nil)
+	if err != nil {
+		return fmt.Errorf("failed to sign verification receipt: %w", err)
+	}
+
+	if err := writeEnvelope(vo.ReceiptOptions.OutPath, env); err != nil {
+		return err
+	}
+
+	log.Infof("Wrote verification receipt for %d artifacts to %v", len(subjects), vo.ReceiptOptions.OutPath)
+	return nil
+}
+
+// acceptVerificationReceipt checks the artifacts are covered by a receipt from a trusted verifier, instead of
+// verifying them against the policy. The receipt must be for the given policy.
+func acceptVerificationReceipt(ctx context.Context, vo options.VerifyOptions) error {
+	if vo.ReceiptOptions.PublicKeyPath == "" {
+		return fmt.Errorf("must supply the public key of the verifier that signed the receipt")
+	}
+
+	if vo.PolicyFilePath == "" {
+		return fmt.Errorf("must supply the policy the verification receipt must be for")
+	}
+
+	if vo.ReceiptOptions.MaxAge <= 0 {
+		return fmt.Errorf("--receipt-max-age must be positive")
+	}
+
+	if vo.SubjectsFilePath != "" || vo.ChecksumsFilePath != "" || len(vo.SubjectPURLs) > 0 {
+		return fmt.Errorf("--subjects-file, --checksums, and --subject-purl can't be checked against a verification receipt")
+	}
+
+	keyFile, err := os.Open(vo.ReceiptOptions.PublicKeyPath)
+	if err != nil {
+		return fmt.Errorf("failed to open receipt public key: %w", err)
+	}
+
+	defer keyFile.Close()
+	verifier, err := cryptoutil.NewVerifierFromReader(keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to create receipt verifier: %w", err)
+	}
+
+	if ro.FIPS {
+		if err := fips.CheckVerifier(verifier); err != nil {
+			return fmt.Errorf("receipt public key is not FIPS compliant: %w", err)
+		}
+	}
+
+	receiptBytes, err := os.ReadFile(vo.ReceiptOptions.Path)
+	if err != nil {
+		return fmt.Errorf("failed to read verification receipt: %w", err)
+	}
+
+	env := dsse.Envelope{}
+	if err := json.Unmarshal(receiptBytes, &env); err != nil {
+		return fmt.Errorf("failed to parse verification receipt: %w", err)
+	}
+
+	if _, err := env.Verify(dsse.VerifyWithVerifiers(verifier)); err != nil {
+		return withExitCode(ExitCodeSignature, fmt.Errorf("verification receipt is not signed by a trusted verifier: %w", err))
+	}
+
+	statement := intoto.Statement{}
+	if err := json.Unmarshal(env.Payload, &statement); err != nil {
+		return fmt.Errorf("failed to parse verification receipt statement: %w", err)
+	}
+
+	if statement.PredicateType != VerificationReceiptPredicateType {
+		return withExitCode(ExitCodePolicy, fmt.Errorf("%v is not a verification receipt", statement.PredicateType))
+	}
+
+	receipt := VerificationReceipt{}
+	if err := json.Unmarshal(statement.Predicate, &receipt); err != nil {
+		return fmt.Errorf("failed to parse verification receipt: %w", err)
+	}
+
+	if time.Since(receipt.VerifiedAt) > vo.ReceiptOptions.MaxAge {
+		return withExitCode(ExitCodePolicy, fmt.Errorf("verification receipt from %v is older than %v", receipt.VerifiedAt, vo.ReceiptOptions.MaxAge))
+	}
+
+	policyDigest, err := cryptoutil.CalculateDigestSetFromFile(vo.PolicyFilePath, []crypto.Hash{crypto.SHA256})
+	if err != nil {
+		return fmt.Errorf("failed to calculate digest of policy: %w", err)
+	}
+
+	if !policyDigest.Equal(receipt.Policy.Digest) {
+		return withExitCode(ExitCodePolicy, fmt.Errorf("verification receipt is for a different policy"))
+	}
+
+	digests, err := receiptArtifactDigests(ctx, vo)
+	if err != nil {
+		return err
+	}
+
+	covered := map[string]struct{}{}
+	for _, subject := range statement.Subject {
+		covered[subject.Digest["sha256"]] = struct{}{}
+	}
+
+	for name, digest := range digests {
+		if _, ok := covered[digest]; !ok {
+			return withExitCode(ExitCodePolicy, fmt.Errorf("%v is not covered by the verification receipt", name))
+		}
+	}
+
+	log.Infof("Accepted verification receipt from %v for %d artifacts", receipt.VerifiedAt.Format(time.RFC3339), len(digests))
+	return nil
+}
+
+// receiptArtifactDigests returns the sha256 digest of each artifact selected by the verify options, by name
+func receiptArtifactDigests(ctx context.Context, vo options.VerifyOptions) (map[string]string, error) {
+	digests := map[string]string{}
+	artifactPaths, err := expandArtifactPaths(vo.ArtifactFilePath, vo.ArtifactListPath, os.Stdin)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range artifactPaths {
+		digestSet, err := artifactDigestFromPath(path, []crypto.Hash{crypto.SHA256})
+		if err != nil {
+			return nil, fmt.Errorf("failed to calculate artifact digest: %w", err)
+		}
+
+		digests[path] = digestSet[crypto.SHA256]
+	}
+
+	if vo.ArtifactRef != "" {
+		refDigestSets, err := artifactDigestsFromRef(ctx, vo.ArtifactRef, []crypto.Hash{crypto.SHA256})
+		if err != nil {
+			return nil, withExitCode(ExitCodeInfrastructure, fmt.Errorf("failed to calculate digest of artifact reference: %w", err))
+		}
+
+		if len(refDigestSets) > 0 {
+			digests[vo.ArtifactRef] = refDigestSets[0][crypto.SHA256]
+		}
+	}
+
+	for _, subject := range vo.AdditionalSubjects {
+		digests[subject] = subject
+	}
+
+	if len(digests) == 0 {
+		return nil, fmt.Errorf("must supply an artifact file, artifact reference, or subject digest to check against the receipt")
+	}
+
+	return digests, nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/witness/options"
+)
+
+func TestVerificationReceipt(t *testing.T) {
+	f := newVerifyFixture(t)
+	step1 := f.run(t, "step01", f.funcPrivPath, "echo 'test01' > test.txt")
+	step1Digest, err := cryptoutil.CalculateDigestSetFromFile(f.artifactPath, []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+	step2 := f.run(t, "step02", f.funcPrivPath, "echo 'test02' >> test.txt")
+
+	receiptPriv, receiptPub := rsakeypair(t)
+	receiptPath := filepath.Join(t.TempDir(), "receipt.json")
+	vo := f.verifyOptions(f.policyPubPath, step1, step2)
+	vo.AdditionalSubjects = []string{step1Digest[crypto.SHA256]}
+	vo.ReceiptOptions = options.ReceiptOptions{OutPath: receiptPath, KeyPath: receiptPriv.Name()}
+	require.NoError(t, runVerify(context.Background(), vo))
+
+	// the receipt is accepted at deploy time without the attestations or the policy's key
+	accept := options.VerifyOptions{
+		ArtifactFilePath: f.artifactPath,
+		PolicyFilePath:   f.policyPath,
+		ReceiptOptions:   options.ReceiptOptions{Path: receiptPath, PublicKeyPath: receiptPub.Name(), MaxAge: time.Hour},
+	}
+	require.NoError(t, runVerify(context.Background(), accept))
+
+	withoutPolicy := accept
+	withoutPolicy.PolicyFilePath = ""
+	require.ErrorContains(t, runVerify(context.Background(), withoutPolicy), "must supply the policy")
+
+	anyAge := accept
+	anyAge.ReceiptOptions.MaxAge = 0
+	require.ErrorContains(t, runVerify(context.Background(), anyAge), "--receipt-max-age must be positive")
+
+	withChecksums := accept
+	withChecksums.ChecksumsFilePath = "SHA256SUMS"
+	require.ErrorContains(t, runVerify(context.Background(), withChecksums), "can't be checked against a verification receipt")
+
+	_, otherPub := rsakeypair(t)
+	untrusted := accept
+	untrusted.ReceiptOptions.PublicKeyPath = otherPub.Name()
+	err = runVerify(context.Background(), untrusted)
+	require.Error(t, err)
+	assert.Equal(t, ExitCodeSignature, ExitCode(err))
+
+	ro.FIPS = true
+	err = runVerify(context.Background(), accept)
+	ro.FIPS = false
+	require.ErrorContains(t, err, "receipt public key is not FIPS compliant")
+
+	expired := accept
+	expired.ReceiptOptions.MaxAge = time.Nanosecond
+	err = runVerify(context.Background(), expired)
+	require.Error(t, err)
+	assert.Equal(t, ExitCodePolicy, ExitCode(err))
+	assert.Contains(t, err.Error(), "is older than")
+
+	otherPolicy := accept
+	otherPolicy.PolicyFilePath = step1
+	err = runVerify(context.Background(), otherPolicy)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "different policy")
+
+	require.NoError(t, os.WriteFile(f.artifactPath, []byte("tampered"), 0644))
+	err = runVerify(context.Background(), accept)
+	require.Error(t, err)
+	assert.Equal(t, ExitCodePolicy, ExitCode(err))
+	assert.Contains(t, err.Error(), "not covered by the verification receipt")
+}
+
+func TestVerificationReceiptNotWrittenOnFailure(t *testing.T) {
+	f := newVerifyFixture(t)
+	step1 := f.run(t, "step01", f.funcPrivPath, "echo 'test01' > test.txt")
+	receiptPriv, _ := rsakeypair(t)
+	receiptPath := filepath.Join(t.TempDir(), "receipt.json")
+	vo := f.verifyOptions(f.policyPubPath, step1)
+	vo.ReceiptOptions = options.ReceiptOptions{OutPath: receiptPath, KeyPath: receiptPriv.Name()}
+	require.Error(t, runVerify(context.Background(), vo))
+	assert.NoFileExists(t, receiptPath)
+
+	vo.ReceiptOptions.KeyPath = ""
+	assert.ErrorContains(t, runVerify(context.Background(), vo), "must supply a key to sign the verification receipt")
+}
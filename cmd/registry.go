@@ -0,0 +1,219 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+const (
+	dockerHubRegistry = "docker.io"
+	ociManifestType   = "application/vnd.oci.image.manifest.v1+json"
+	ociIndexType      = "application/vnd.oci.image.index.v1+json"
+)
+
+type ociReference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// parseOCIReference parses references of the form registry/repository[:tag][@digest]. References
+// without a registry are assumed to refer to Docker Hub.
+func parseOCIReference(ref string) (ociReference, error) {
+	parsed := ociReference{}
+	if ref == "" {
+		return parsed, fmt.Errorf("empty oci reference")
+	}
+
+	if idx := strings.Index(ref, "@"); idx >= 0 {
+		parsed.Digest = ref[idx+1:]
+		ref = ref[:idx]
+		if !strings.Contains(parsed.Digest, ":") {
+			return parsed, fmt.Errorf("invalid digest in oci reference: %v", parsed.Digest)
+		}
+	}
+
+	if idx := strings.LastIndex(ref, ":"); idx > strings.LastIndex(ref, "/") {
+		parsed.Tag = ref[idx+1:]
+		ref = ref[:idx]
+	}
+
+	parts := strings.SplitN(ref, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		parsed.Registry = parts[0]
+		parsed.Repository = parts[1]
+	} else {
+		parsed.Registry = dockerHubRegistry
+		parsed.Repository = ref
+	}
+
+	if parsed.Registry == dockerHubRegistry && !strings.Contains(parsed.Repository, "/") {
+		parsed.Repository = "library/" + parsed.Repository
+	}
+
+	if parsed.Repository == "" {
+		return parsed, fmt.Errorf("oci reference is missing a repository")
+	}
+
+	if parsed.Tag == "" && parsed.Digest == "" {
+		parsed.Tag = "latest"
+	}
+
+	return parsed, nil
+}
+
+// Identifier returns the digest of the reference if one exists, otherwise the tag.
+func (r ociReference) Identifier() string {
+	if r.Digest != "" {
+		return r.Digest
+	}
+
+	return r.Tag
+}
+
+func (r ociReference) String() string {
+	s := fmt.Sprintf("%v/%v", r.Registry, r.Repository)
+	if r.Tag != "" {
+		s = fmt.Sprintf("%v:%v", s, r.Tag)
+	}
+
+	if r.Digest != "" {
+		s = fmt.Sprintf("%v@%v", s, r.Digest)
+	}
+
+	return s
+}
+
+// registryClient reads manifests and blobs from the repository of an OCI reference. Credentials are looked
+// up in the default keychain, which reads the docker config file and falls back to anonymous access.
+type registryClient struct {
+	ref ociReference
+}
+
+func newRegistryClient(ref ociReference) *registryClient {
+	return &registryClient{ref: ref}
+}
+
+func (c *registryClient) repository() (name.Repository, error) {
+	return name.NewRepository(fmt.Sprintf("%v/%v", c.ref.Registry, c.ref.Repository))
+}
+
+func (c *registryClient) reference() (name.Reference, error) {
+	repo, err := c.repository()
+	if err != nil {
+		return nil, err
+	}
+
+	if c.ref.Digest != "" {
+		return name.NewDigest(fmt.Sprintf("%v@%v", repo, c.ref.Digest))
+	}
+
+	return repo.Tag(c.ref.Tag), nil
+}
+
+func (c *registryClient) options(ctx context.Context) []remote.Option {
+	return []remote.Option{remote.WithAuthFromKeychain(authn.DefaultKeychain), remote.WithContext(ctx)}
+}
+
+// Manifest fetches the manifest the reference points to and returns its raw bytes and media type.
+func (c *registryClient) Manifest(ctx context.Context) ([]byte, string, error) {
+	ref, err := c.reference()
+	if err != nil {
+		return nil, "", err
+	}
+
+	desc, err := remote.Get(ref, c.options(ctx)...)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return desc.Manifest, string(desc.MediaType), nil
+}
+
+// Blob opens the blob with the provided digest. Callers are responsible for closing the returned reader.
+func (c *registryClient) Blob(ctx context.Context, digest string) (io.ReadCloser, error) {
+	repo, err := c.repository()
+	if err != nil {
+		return nil, err
+	}
+
+	ref, err := name.NewDigest(fmt.Sprintf("%v@%v", repo, digest))
+	if err != nil {
+		return nil, err
+	}
+
+	layer, err := remote.Layer(ref, c.options(ctx)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return layer.Compressed()
+}
+
+// Referrers fetches the index the referrers API serves for the manifest with digest.
+func (c *registryClient) Referrers(ctx context.Context, digest string) ([]byte, error) {
+	repo, err := c.repository()
+	if err != nil {
+		return nil, err
+	}
+
+	auth, err := authn.DefaultKeychain.Resolve(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	rt, err := transport.NewWithContext(ctx, repo.Registry, auth, http.DefaultTransport, []string{repo.Scope(transport.PullScope)})
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := url.URL{Scheme: repo.Registry.Scheme(), Host: repo.RegistryStr(), Path: fmt.Sprintf("/v2/%v/referrers/%v", repo.RepositoryStr(), digest)}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", ociIndexType)
+	resp, err := (&http.Client{Transport: rt}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	if err := transport.CheckError(resp, http.StatusOK); err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// isRegistryNotFound reports whether err is the registry responding that what was requested doesn't exist
+func isRegistryNotFound(err error) bool {
+	terr := &transport.Error{}
+	return errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound
+}
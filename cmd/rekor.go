@@ -0,0 +1,71 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/pkg/rekor"
+	"github.com/testifysec/witness/pkg/transport"
+)
+
+// checkRekorEntries checks each attestation file has an entry in the Rekor log at server that's signed by the log's
+// key at keyPath and whose public key signed the attestation
+func checkRekorEntries(ctx context.Context, paths []string, server, keyPath string) error {
+	if server == "" {
+		return nil
+	}
+
+	if keyPath == "" {
+		return fmt.Errorf("--rekor-server requires --rekor-public-key to check the log's entries")
+	}
+
+	logKey, err := rekor.LoadPublicKey(keyPath)
+	if err != nil {
+		return err
+	}
+
+	resolved, err := transport.ResolveURL(server)
+	if err != nil {
+		return err
+	}
+
+	client := rekor.New(resolved, logKey)
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read attestation file: %w", err)
+		}
+
+		env := dsse.Envelope{}
+		if err := json.Unmarshal(data, &env); err != nil {
+			return fmt.Errorf("failed to parse attestation file %v: %w", path, err)
+		}
+
+		entry, err := client.Find(ctx, env)
+		if err != nil {
+			return withExitCode(ExitCodeSignature, fmt.Errorf("attestation %v isn't in rekor: %w", path, err))
+		}
+
+		log.Debugf("(rekor) %v is at log index %v of %v", path, entry.LogIndex, server)
+	}
+
+	return nil
+}
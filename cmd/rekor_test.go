@@ -0,0 +1,70 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/rekor/rekortest"
+)
+
+func TestVerifyRekorEntries(t *testing.T) {
+	f := newVerifyFixture(t)
+	server := rekortest.NewServer(t)
+	rekorOptions := options.RekorOptions{Server: server.URL, PublicKeyPath: server.WritePublicKey(t)}
+	runLogged := func(step, script string) string {
+		outPath := filepath.Join(t.TempDir(), step+".json")
+		require.NoError(t, runRun(context.Background(), options.RunOptions{
+			KeyOptions:   options.KeyOptions{KeyPath: f.funcPrivPath},
+			RekorOptions: rekorOptions,
+			WorkingDir:   f.workingDir,
+			OutFilePath:  outPath,
+			StepName:     step,
+		}, []string{"bash", "-c", script}))
+		return outPath
+	}
+
+	step1 := runLogged("step01", "echo 'test01' > test.txt")
+	step1Digest, err := cryptoutil.CalculateDigestSetFromFile(f.artifactPath, []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+	step2 := runLogged("step02", "echo 'test02' >> test.txt")
+	vo := f.verifyOptions(f.policyPubPath, step1, step2)
+	vo.AdditionalSubjects = []string{step1Digest[crypto.SHA256]}
+	vo.RekorServer, vo.RekorPublicKeyPath = rekorOptions.Server, rekorOptions.PublicKeyPath
+	require.NoError(t, runVerify(context.Background(), vo))
+
+	other := rekortest.NewServer(t)
+	vo.RekorPublicKeyPath = other.WritePublicKey(t)
+	err = runVerify(context.Background(), vo)
+	require.Error(t, err)
+	assert.Equal(t, ExitCodeSignature, ExitCode(err))
+	assert.ErrorContains(t, err, "signed entry timestamp wasn't signed by the log's key")
+
+	vo.RekorServer = other.URL
+	err = runVerify(context.Background(), vo)
+	require.Error(t, err)
+	assert.Equal(t, ExitCodeSignature, ExitCode(err))
+	assert.ErrorContains(t, err, "no rekor entry found")
+
+	vo.RekorPublicKeyPath = ""
+	assert.ErrorContains(t, runVerify(context.Background(), vo), "requires --rekor-public-key")
+}
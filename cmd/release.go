@@ -0,0 +1,243 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/checksums"
+	"github.com/testifysec/witness/pkg/runner"
+	"github.com/testifysec/witness/pkg/spool"
+)
+
+// ReleasePredicateType is the predicate type of the release manifests witness release signs
+const ReleasePredicateType = "https://witness.dev/release/v0.1"
+
+const releaseLong = `Verifies every artifact of a release against a policy and, if they all pass, signs a release manifest listing
+the artifacts, their digests, and links to the evidence that satisfied the policy for each of them. The manifest is an
+in-toto statement whose subjects are the artifacts, suitable for publishing alongside the release.
+
+Artifacts are selected with the same flags as witness verify, usually a glob such as -f 'dist/*' or --artifact-list.
+Evidence from attestation files is linked by file name, relative to --evidence-url if it's set, so the links resolve
+once the attestation files are published with the release. Evidence found in Archivist is linked to its download URL.
+Nothing is signed if any artifact fails verification, and the command exits with the same codes as witness verify.`
+
+func ReleaseCmd() *cobra.Command {
+	ro := options.ReleaseOptions{}
+	cmd := &cobra.Command{
+		Use:               "release",
+		Short:             "Verifies the artifacts of a release and signs a manifest of them and their evidence",
+		Long:              releaseLong,
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runRelease(cmd.Context(), ro)
+		},
+	}
+
+	ro.AddFlags(cmd)
+	return cmd
+}
+
+// ReleaseEvidence links to a collection that satisfied the policy for an artifact
+type ReleaseEvidence struct {
+	Step      string `json:"step"`
+	Reference string `json:"reference"`
+	URI       string `json:"uri,omitempty"`
+}
+
+// ReleaseArtifact is an artifact of the release and the evidence it was verified with
+type ReleaseArtifact struct {
+	Name     string            `json:"name"`
+	Digest   map[string]string `json:"digest"`
+	Evidence []ReleaseEvidence `json:"evidence"`
+}
+
+// ReleaseManifest is the predicate of a release manifest
+type ReleaseManifest struct {
+	Name      string            `json:"name,omitempty"`
+	Policy    PromotionPolicy   `json:"policy"`
+	Artifacts []ReleaseArtifact `json:"artifacts"`
+	CreatedAt time.Time         `json:"createdat"`
+}
+
+func runRelease(ctx context.Context, ro options.ReleaseOptions) error {
+	if ro.KeyOptions.KeyPath == "" {
+		return fmt.Errorf("must supply a key to sign the release manifest with")
+	}
+
+	// load the signer first so a bad key is reported before the artifacts are verified
+	signers, err := loadStatementSigners(ctx, ro.KeyOptions)
+	if err != nil {
+		return err
+	}
+
+	targets, err := verifyPolicy(ctx, ro.VerifyOptions)
+	if err != nil {
+		return err
+	}
+
+	policyDigest, err := cryptoutil.CalculateDigestSetFromFile(ro.VerifyOptions.PolicyFilePath, []crypto.Hash{crypto.SHA256})
+	if err != nil {
+		return fmt.Errorf("failed to calculate digest of policy: %w", err)
+	}
+
+	manifest := ReleaseManifest{
+		Name:      ro.Name,
+		Policy:    PromotionPolicy{Digest: policyDigest},
+		Artifacts: []ReleaseArtifact{},
+		CreatedAt: time.Now().UTC(),
+	}
+
+	links := newEvidenceLinker(ro)
+	for _, target := range targets {
+		subjects, err := promotionSubjects([]verifyTarget{target})
+		if err != nil {
+			return err
+		}
+
+		evidence := releaseEvidence(target, links)
+		for _, subject := range subjects {
+			manifest.Artifacts = append(manifest.Artifacts, ReleaseArtifact{
+				Name:     subject.Name,
+				Digest:   subject.Digest,
+				Evidence: evidence,
+			})
+		}
+	}
+
+	if ro.ChecksumsOutPath != "" {
+		if err := writeReleaseChecksums(ro.ChecksumsOutPath, manifest.Artifacts); err != nil {
+			return err
+		}
+	}
+
+	subjects, err := promotionSubjects(targets)
+	if err != nil {
+		return err
+	}
+
+	env, err := signStatement(subjects, ReleasePredicateType, manifest, signers, ro.TimestampServers)
+	if err != nil {
+		return fmt.Errorf("failed to sign release manifest: %w", err)
+	}
+
+	if err := writeEnvelope(ro.OutFilePath, env); err != nil {
+		return err
+	}
+
+	if ro.VerifyOptions.ArchivistOptions.Enable {
+		for _, server := range ro.VerifyOptions.ArchivistOptions.Urls {
+			gitoid, err := runner.StoreEnvelope(ctx, spool.BackendArchivist, server, "", env)
+			if err != nil {
+				return withExitCode(ExitCodeInfrastructure, fmt.Errorf("failed to store release manifest in archivist %v: %w", server, err))
+			}
+
+			log.Infof("Stored release manifest in archivist %v with gitoid %v", server, gitoid)
+		}
+	}
+
+	log.Infof("Signed release manifest for %d artifacts", len(manifest.Artifacts))
+	return nil
+}
+
+// releaseEvidence returns the collections that satisfied the policy for target, sorted by step
+func releaseEvidence(target verifyTarget, links evidenceLinker) []ReleaseEvidence {
+	steps := make([]string, 0, len(target.verified))
+	for step := range target.verified {
+		steps = append(steps, step)
+	}
+
+	sort.Strings(steps)
+	evidence := []ReleaseEvidence{}
+	for _, step := range steps {
+		for _, collection := range target.verified[step] {
+			evidence = append(evidence, ReleaseEvidence{
+				Step:      step,
+				Reference: collection.Reference,
+				URI:       links(collection.Reference),
+			})
+		}
+	}
+
+	return evidence
+}
+
+// evidenceLinker returns the link to the collection with reference, or an empty string if it can't be linked
+type evidenceLinker func(reference string) string
+
+// newEvidenceLinker links evidence loaded from attestation files by file name, relative to the evidence URL, and
+// other evidence to its download URL in Archivist
+func newEvidenceLinker(ro options.ReleaseOptions) evidenceLinker {
+	files := make(map[string]bool)
+	for _, path := range ro.VerifyOptions.AttestationFilePaths {
+		files[path] = true
+	}
+
+	return func(reference string) string {
+		if files[reference] {
+			name := filepath.Base(reference)
+			if ro.EvidenceURL == "" {
+				return name
+			}
+
+			return strings.TrimSuffix(ro.EvidenceURL, "/") + "/" + url.PathEscape(name)
+		}
+
+		if !ro.VerifyOptions.ArchivistOptions.Enable {
+			return ""
+		}
+
+		archivistURL, err := url.Parse(ro.VerifyOptions.ArchivistOptions.Url())
+		if err != nil || (archivistURL.Scheme != "http" && archivistURL.Scheme != "https") {
+			return ""
+		}
+
+		archivistURL.Path = path.Join(archivistURL.Path, "download", reference)
+		return archivistURL.String()
+	}
+}
+
+// writeReleaseChecksums writes a SHA256SUMS file of the release artifacts
+func writeReleaseChecksums(path string, artifacts []ReleaseArtifact) error {
+	entries := make([]checksums.Entry, 0, len(artifacts))
+	for _, artifact := range artifacts {
+		if digest, ok := artifact.Digest["sha256"]; ok {
+			entries = append(entries, checksums.Entry{Name: artifact.Name, Hash: crypto.SHA256, Digest: digest})
+		}
+	}
+
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create checksum file: %w", err)
+	}
+
+	defer out.Close()
+	return checksums.Write(out, entries)
+}
@@ -0,0 +1,113 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+	"github.com/testifysec/witness/options"
+)
+
+func TestRelease(t *testing.T) {
+	f := newVerifyFixture(t)
+	signingPriv, _ := rsakeypair(t)
+	step1 := f.run(t, "step01", f.funcPrivPath, "echo 'test01' > test.txt")
+	step1Digest, err := cryptoutil.CalculateDigestSetFromFile(f.artifactPath, []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+	step2 := f.run(t, "step02", f.funcPrivPath, "echo 'test02' >> test.txt")
+	artifactDigest, err := cryptoutil.CalculateDigestSetFromFile(f.artifactPath, []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+
+	vo := f.verifyOptions(f.policyPubPath, step1, step2)
+	vo.AdditionalSubjects = []string{step1Digest[crypto.SHA256]}
+	outPath := filepath.Join(t.TempDir(), "release.json")
+	sumsPath := filepath.Join(t.TempDir(), "SHA256SUMS")
+	require.NoError(t, runRelease(context.Background(), options.ReleaseOptions{
+		VerifyOptions:    vo,
+		KeyOptions:       options.KeyOptions{KeyPath: signingPriv.Name()},
+		Name:             "v1.0.0",
+		EvidenceURL:      "https://example.com/releases/download/v1.0.0/",
+		ChecksumsOutPath: sumsPath,
+		OutFilePath:      outPath,
+	}))
+
+	sums, err := os.ReadFile(sumsPath)
+	require.NoError(t, err)
+	assert.Equal(t, artifactDigest[crypto.SHA256]+"  test.txt\n", string(sums))
+
+	envBytes, err := os.ReadFile(outPath)
+	require.NoError(t, err)
+	env := dsse.Envelope{}
+	require.NoError(t, json.Unmarshal(envBytes, &env))
+	require.Len(t, env.Signatures, 1)
+
+	statement := intoto.Statement{}
+	require.NoError(t, json.Unmarshal(env.Payload, &statement))
+	assert.Equal(t, ReleasePredicateType, statement.PredicateType)
+	require.Len(t, statement.Subject, 1)
+	assert.Equal(t, "test.txt", statement.Subject[0].Name)
+
+	manifest := ReleaseManifest{}
+	require.NoError(t, json.Unmarshal(statement.Predicate, &manifest))
+	assert.Equal(t, "v1.0.0", manifest.Name)
+	assert.NotEmpty(t, manifest.Policy.Digest)
+	require.Len(t, manifest.Artifacts, 1)
+	artifact := manifest.Artifacts[0]
+	assert.Equal(t, "test.txt", artifact.Name)
+	assert.Equal(t, artifactDigest[crypto.SHA256], artifact.Digest["sha256"])
+	assert.Equal(t, []ReleaseEvidence{
+		{Step: "step01", Reference: step1, URI: "https://example.com/releases/download/v1.0.0/" + filepath.Base(step1)},
+		{Step: "step02", Reference: step2, URI: "https://example.com/releases/download/v1.0.0/" + filepath.Base(step2)},
+	}, artifact.Evidence)
+}
+
+func TestReleaseFailedVerification(t *testing.T) {
+	f := newVerifyFixture(t)
+	signingPriv, _ := rsakeypair(t)
+	step1 := f.run(t, "step01", f.funcPrivPath, "echo 'test01' > test.txt")
+
+	outPath := filepath.Join(t.TempDir(), "release.json")
+	err := runRelease(context.Background(), options.ReleaseOptions{
+		VerifyOptions: f.verifyOptions(f.policyPubPath, step1),
+		KeyOptions:    options.KeyOptions{KeyPath: signingPriv.Name()},
+		OutFilePath:   outPath,
+	})
+
+	require.Error(t, err)
+	assert.NoFileExists(t, outPath)
+}
+
+func TestReleaseEvidenceLinks(t *testing.T) {
+	links := newEvidenceLinker(options.ReleaseOptions{
+		VerifyOptions: options.VerifyOptions{
+			AttestationFilePaths: []string{"attestations/build.json"},
+			ArchivistOptions:     options.ArchivistOptions{Enable: true, Urls: []string{"https://archivist.example.com"}},
+		},
+	})
+
+	assert.Equal(t, "build.json", links("attestations/build.json"))
+	assert.Equal(t, "https://archivist.example.com/download/abc123", links("abc123"))
+	assert.Empty(t, newEvidenceLinker(options.ReleaseOptions{})("abc123"))
+}
@@ -15,8 +15,11 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/spf13/cobra"
 	"github.com/testifysec/go-witness/log"
@@ -33,6 +36,9 @@ func New() *cobra.Command {
 		Short:             "Collect and verify attestations about your build environments",
 		DisableAutoGenTag: true,
 		SilenceErrors:     true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return expandFlagTemplates(cmd)
+		},
 	}
 
 	logger := newLogger()
@@ -40,8 +46,29 @@ func New() *cobra.Command {
 
 	ro.AddFlags(cmd)
 	cmd.AddCommand(SignCmd())
+	cmd.AddCommand(PQKeygenCmd())
 	cmd.AddCommand(VerifyCmd())
+	cmd.AddCommand(PolicyCmd())
+	cmd.AddCommand(ApproveCmd())
+	cmd.AddCommand(PromoteCmd())
+	cmd.AddCommand(ReleaseCmd())
+	cmd.AddCommand(WaiveCmd())
 	cmd.AddCommand(RunCmd())
+	cmd.AddCommand(WatchCmd())
+	cmd.AddCommand(AgentCmd())
+	cmd.AddCommand(DiscloseCmd())
+	cmd.AddCommand(UploadCmd())
+	cmd.AddCommand(FlushCmd())
+	cmd.AddCommand(StoreCmd())
+	cmd.AddCommand(PruneCmd())
+	cmd.AddCommand(CompareCmd())
+	cmd.AddCommand(DiffCmd())
+	cmd.AddCommand(GraphCmd())
+	cmd.AddCommand(InspectCmd())
+	cmd.AddCommand(DoctorCmd())
+	cmd.AddCommand(InitCmd())
+	cmd.AddCommand(EnvCmd())
+	cmd.AddCommand(SelfUpdateCmd())
 	cmd.AddCommand(CompletionCmd())
 	cmd.AddCommand(versionCmd())
 	cobra.OnInitialize(func() { preRoot(cmd, ro, logger) })
@@ -49,13 +76,29 @@ func New() *cobra.Command {
 }
 
 func Execute() {
-	if err := New().Execute(); err != nil {
+	if err := New().ExecuteContext(interruptContext()); err != nil {
 		log.Error(err)
-		os.Exit(1)
+		os.Exit(ExitCode(err))
 	}
 }
 
+// interruptContext returns a context that's canceled when witness is interrupted or terminated, so commands can stop
+// the command they wrap and any uploads in flight. A second signal exits immediately.
+func interruptContext() context.Context {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	return ctx
+}
+
 func preRoot(cmd *cobra.Command, ro *options.RootOptions, logger *logrusLogger) {
+	if err := applyEnv(cmd); err != nil {
+		logger.l.Fatal(err)
+	}
+
 	if err := logger.SetLevel(ro.LogLevel); err != nil {
 		logger.l.Fatal(err)
 	}
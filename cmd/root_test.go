@@ -91,6 +91,17 @@ func Test_loadSignersCertificate(t *testing.T) {
 	}
 }
 
+func Test_loadSignersFIPS(t *testing.T) {
+	ro.FIPS = true
+	t.Cleanup(func() { ro.FIPS = false })
+	privatePem, _ := rsakeypair(t)
+
+	signers, errors := loadSigners(context.Background(), options.KeyOptions{KeyPath: privatePem.Name()})
+	if len(signers) != 0 || len(errors) != 1 {
+		t.Errorf("expected the %d bit key to be rejected, got %d signers and %d errors", keybits, len(signers), len(errors))
+	}
+}
+
 func rsakeypair(t *testing.T) (privatePem *os.File, publicPem *os.File) {
 	privatekey, err := rsa.GenerateKey(rand.Reader, keybits)
 	if err != nil {
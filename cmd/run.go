@@ -1,4 +1,4 @@
-// Copyright 2021 The Witness Contributors
+// Copyright 2022 The Witness Contributors
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -18,15 +18,14 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
 
 	"github.com/spf13/cobra"
-	witness "github.com/testifysec/go-witness"
-	"github.com/testifysec/go-witness/archivist"
-	"github.com/testifysec/go-witness/attestation"
 	"github.com/testifysec/go-witness/dsse"
 	"github.com/testifysec/go-witness/log"
-	"github.com/testifysec/go-witness/timestamp"
 	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/runner"
+	"github.com/testifysec/witness/pkg/trust"
 )
 
 func RunCmd() *cobra.Command {
@@ -46,67 +45,86 @@ func RunCmd() *cobra.Command {
 	return cmd
 }
 
-func runRun(ctx context.Context, ro options.RunOptions, args []string) error {
-	signers, errors := loadSigners(ctx, ro.KeyOptions)
-	if len(errors) > 0 {
-		for _, err := range errors {
-			log.Error(err)
-		}
-		return fmt.Errorf("failed to load signers")
-	}
+func runRun(ctx context.Context, ro options.RunOptions, args []string) (err error) {
+	event := notifyEvent{Event: notifyEventRun, Step: ro.StepName}
+	defer func() {
+		notify(ctx, ro.NotifyOptions, event, err)
+	}()
 
-	if len(signers) > 1 {
-		log.Error("only one signer is supported")
-		return fmt.Errorf("only one signer is supported")
-	}
+	defer func() {
+		if ro.SARIFPath == "" {
+			return
+		}
 
-	if len(signers) == 0 {
-		log.Error("no signers found")
-		return fmt.Errorf("no signers found")
-	}
+		if sarifErr := writeRunSARIF(ro, args, err); sarifErr != nil {
+			if err != nil {
+				log.Errorf("failed to write sarif log: %v", sarifErr)
+				return
+			}
 
-	out, err := loadOutfile(ro.OutFilePath)
-	if err != nil {
-		return fmt.Errorf("failed to open out file: %w", err)
-	}
+			err = sarifErr
+		}
+	}()
 
-	timestampers := []dsse.Timestamper{}
-	for _, url := range ro.TimestampServers {
-		timestampers = append(timestampers, timestamp.NewTimestamper(timestamp.TimestampWithUrl(url)))
+	switch ro.CIMode {
+	case "", ciModeTekton:
+	default:
+		return fmt.Errorf("unknown ci mode %v", ro.CIMode)
 	}
 
-	defer out.Close()
-	result, err := witness.Run(
-		ro.StepName,
-		signers[0],
-		witness.RunWithTracing(ro.Tracing),
-		witness.RunWithCommand(args),
-		witness.RunWithAttestors(ro.Attestations),
-		witness.RunWithAttestationOpts(attestation.WithWorkingDir(ro.WorkingDir)),
-		witness.RunWithTimestampers(timestampers...),
-	)
-
+	result, err := runner.Run(ctx, ro, args, runnerOptions()...)
+	event.addEnvelopeSubjects(result.SignedEnvelope)
+	event.Storage = result.Storage
 	if err != nil {
 		return err
 	}
 
+	logSignerIdentities(result.SignedEnvelope)
 	signedBytes, err := json.Marshal(&result.SignedEnvelope)
 	if err != nil {
 		return fmt.Errorf("failed to marshal envelope: %w", err)
 	}
 
-	if _, err := out.Write(signedBytes); err != nil {
-		return fmt.Errorf("failed to write envelope to out file: %w", err)
+	if ro.OutFilePath == "" {
+		if _, err := os.Stdout.Write(signedBytes); err != nil {
+			return fmt.Errorf("failed to write envelope: %w", err)
+		}
 	}
 
-	if ro.ArchivistOptions.Enable {
-		archivistClient := archivist.New(ro.ArchivistOptions.Url)
-		if gitoid, err := archivistClient.Store(ctx, result.SignedEnvelope); err != nil {
-			return fmt.Errorf("failed to store artifact in archivist: %w", err)
-		} else {
-			log.Infof("Stored in archivist as %v\n", gitoid)
+	if ro.CIMode != "" {
+		if err := writeCIResults(ro, signedBytes, event.Storage); err != nil {
+			return fmt.Errorf("failed to write ci results: %w", err)
 		}
 	}
 
 	return nil
 }
+
+// logSignerIdentities logs who signed env and the functionary that matches them, so it can be copied into a policy
+func logSignerIdentities(env dsse.Envelope) {
+	for _, sig := range env.Signatures {
+		identity, err := trust.SignatureIdentity(sig)
+		if err != nil {
+			log.Debugf("failed to get signer identity: %v", err)
+			continue
+		}
+
+		log.Infof("Signed by key id %v", identity.KeyID)
+		if cert := identity.Certificate; cert != nil {
+			names := append(append(append([]string{}, cert.DNSNames...), cert.Emails...), cert.URIs...)
+			log.Infof("Signer certificate common name %q, organizations %v, alternative names %v", cert.CommonName, cert.Organizations, names)
+			if cert.OIDCIssuer != "" {
+				log.Infof("Signer OIDC issuer %v", cert.OIDCIssuer)
+			}
+		}
+
+		if functionary, err := json.Marshal(identity.Functionary); err == nil {
+			log.Infof("Policy functionary for this signer: %s", functionary)
+		}
+	}
+}
+
+// runnerOptions configures the runner with the global flags and the CLI's logger
+func runnerOptions() []runner.Option {
+	return []runner.Option{runner.WithFIPS(ro.FIPS), runner.WithLogger(log.GetLogger())}
+}
@@ -20,18 +20,78 @@ import (
 	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
 	"encoding/pem"
+	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/attestation/commandrun"
+	"github.com/testifysec/go-witness/attestation/environment"
+	"github.com/testifysec/go-witness/attestation/material"
+	"github.com/testifysec/go-witness/attestation/product"
 	"github.com/testifysec/go-witness/cryptoutil"
 	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+	"github.com/testifysec/witness/attestation/annotations"
+	"github.com/testifysec/witness/attestation/buildkit"
+	witnessattestor "github.com/testifysec/witness/attestation/witness"
 	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/envshim"
+	"github.com/testifysec/witness/pkg/ptyshim"
 )
 
+func TestMain(m *testing.M) {
+	// runs that set the command's environment or give it a terminal start the test binary as a shim
+	envshim.Init()
+	ptyshim.Init()
+	os.Exit(m.Run())
+}
+
+// runAndGetAttestor runs args and returns the attestor of type T from the collection the run wrote to
+// runOptions.OutFilePath, failing the test if the run fails or didn't record one.
+func runAndGetAttestor[T attestation.Attestor](t *testing.T, runOptions options.RunOptions, args []string) T {
+	require.NoError(t, runRun(context.Background(), runOptions, args))
+	_, collection := readCollection(t, runOptions.OutFilePath)
+	attestor := findAttestor[T](collection)
+	require.NotNil(t, attestor)
+	return attestor
+}
+
+// readCollection reads the signed statement at path and the attestation collection it carries
+func readCollection(t *testing.T, path string) (intoto.Statement, attestation.Collection) {
+	attestationBytes, err := os.ReadFile(path)
+	require.NoError(t, err)
+	env := dsse.Envelope{}
+	require.NoError(t, json.Unmarshal(attestationBytes, &env))
+	stmt := intoto.Statement{}
+	require.NoError(t, json.Unmarshal(env.Payload, &stmt))
+	collection := attestation.Collection{}
+	require.NoError(t, json.Unmarshal(stmt.Predicate, &collection))
+	return stmt, collection
+}
+
+// findAttestor returns the attestor of type T in collection, or T's zero value if there isn't one
+func findAttestor[T attestation.Attestor](collection attestation.Collection) T {
+	var attestor T
+	for _, att := range collection.Attestations {
+		if a, ok := att.Attestation.(T); ok {
+			attestor = a
+		}
+	}
+
+	return attestor
+}
+
 func TestRunRSAKeyPair(t *testing.T) {
 	priv, _ := rsakeypair(t)
 	keyOptions := options.KeyOptions{
@@ -141,6 +201,237 @@ func Test_runRunRSACA(t *testing.T) {
 
 }
 
+func TestRunRecordsWitnessAttestor(t *testing.T) {
+	priv, _ := rsakeypair(t)
+	workingDir := t.TempDir()
+	attestationPath := filepath.Join(workingDir, "outfile.txt")
+	runOptions := options.RunOptions{
+		KeyOptions:   options.KeyOptions{KeyPath: priv.Name()},
+		WorkingDir:   workingDir,
+		Attestations: []string{},
+		OutFilePath:  attestationPath,
+		StepName:     "teststep",
+	}
+
+	witnessAttestor := runAndGetAttestor[*witnessattestor.Attestor](t, runOptions, []string{"bash", "-c", "echo 'test' > test.txt"})
+	require.Equal(t, Version, witnessAttestor.Version)
+	require.NotEmpty(t, witnessAttestor.Digest)
+}
+
+func TestRunBuildKitAttestations(t *testing.T) {
+	priv, _ := rsakeypair(t)
+	workingDir := t.TempDir()
+	attestationPath := filepath.Join(workingDir, "outfile.txt")
+	runOptions := options.RunOptions{
+		KeyOptions:   options.KeyOptions{KeyPath: priv.Name()},
+		WorkingDir:   workingDir,
+		Attestations: []string{},
+		OutFilePath:  attestationPath,
+		StepName:     "teststep",
+		BuildKitDirs: []string{"out"},
+	}
+
+	provenance := `{"_type":"https://in-toto.io/Statement/v0.1","predicateType":"https://slsa.dev/provenance/v0.2",` +
+		`"subject":[{"name":"pkg:docker/app@latest","digest":{"sha256":"` + strings.Repeat("a", 64) + `"}}],"predicate":{"builder":{"id":""}}}`
+	script := fmt.Sprintf("mkdir -p out/linux_amd64 && echo '%v' > out/linux_amd64/provenance.json && echo '{}' > out/other.json", provenance)
+	require.NoError(t, runRun(context.Background(), runOptions, []string{"bash", "-c", script}))
+	stmt, collection := readCollection(t, attestationPath)
+	buildkitAttestor := findAttestor[*buildkit.Attestor](collection)
+	require.NotNil(t, buildkitAttestor)
+	require.Len(t, buildkitAttestor.Statements, 1)
+	require.Equal(t, "out/linux_amd64/provenance.json", buildkitAttestor.Statements[0].File)
+	require.Equal(t, "https://slsa.dev/provenance/v0.2", buildkitAttestor.Statements[0].PredicateType)
+
+	subjects := []string{}
+	for _, subject := range stmt.Subject {
+		subjects = append(subjects, subject.Name)
+	}
+
+	require.Contains(t, subjects, buildkit.Type+"/pkg:docker/app@latest")
+}
+
+func TestRunAnnotations(t *testing.T) {
+	priv, _ := rsakeypair(t)
+	workingDir := t.TempDir()
+	attestationPath := filepath.Join(workingDir, "outfile.txt")
+	runOptions := options.RunOptions{
+		KeyOptions:   options.KeyOptions{KeyPath: priv.Name()},
+		WorkingDir:   workingDir,
+		Attestations: []string{},
+		OutFilePath:  attestationPath,
+		StepName:     "teststep",
+		Annotations:  []string{"project=payments", "cost-center=a=b"},
+	}
+
+	require.NoError(t, runRun(context.Background(), runOptions, []string{"bash", "-c", "echo 'test' > test.txt"}))
+	stmt, collection := readCollection(t, attestationPath)
+	annotationsAttestor := findAttestor[*annotations.Attestor](collection)
+	require.NotNil(t, annotationsAttestor)
+	require.Equal(t, map[string]string{"project": "payments", "cost-center": "a=b"}, annotationsAttestor.Annotations)
+
+	subjects := map[string]map[string]string{}
+	for _, subject := range stmt.Subject {
+		subjects[subject.Name] = subject.Digest
+	}
+
+	sum := sha256.Sum256([]byte("project=payments"))
+	require.Equal(t, map[string]string{"sha256": hex.EncodeToString(sum[:])}, subjects[annotations.Type+"/annotation:project"])
+
+	runOptions.Annotations = []string{"project=payments", "project=billing"}
+	require.Error(t, runRun(context.Background(), runOptions, []string{"true"}))
+	runOptions.Annotations = []string{"=payments"}
+	require.Error(t, runRun(context.Background(), runOptions, []string{"true"}))
+}
+
+func TestRunDeterministic(t *testing.T) {
+	priv, _ := rsakeypair(t)
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	run := func(variable string, attestations []string) intoto.Statement {
+		t.Setenv("WITNESS_TEST_VARIABLE", variable)
+		workingDir := t.TempDir()
+		attestationPath := filepath.Join(workingDir, "outfile.txt")
+		require.NoError(t, runRun(context.Background(), options.RunOptions{
+			KeyOptions:    options.KeyOptions{KeyPath: priv.Name()},
+			WorkingDir:    workingDir,
+			Attestations:  attestations,
+			OutFilePath:   attestationPath,
+			StepName:      "teststep",
+			Annotations:   []string{"project=payments"},
+			Retention:     time.Hour,
+			Deterministic: true,
+		}, []string{"bash", "-c", "echo 'test' > test.txt"}))
+
+		stmt, _ := readCollection(t, attestationPath)
+		return stmt
+	}
+
+	first := run("first", []string{"environment", "annotations"})
+	second := run("second", []string{"annotations", "environment"})
+	require.Equal(t, first, second)
+
+	collection := attestation.Collection{}
+	require.NoError(t, json.Unmarshal(first.Predicate, &collection))
+	for _, att := range collection.Attestations {
+		switch a := att.Attestation.(type) {
+		case *environment.Attestor:
+			require.Equal(t, "witness", a.Hostname)
+			require.Empty(t, a.Variables)
+		case *annotations.Attestor:
+			require.Equal(t, "2023-11-14T23:13:20Z", a.Annotations[annotations.ExpiresAtKey])
+		}
+	}
+
+	t.Setenv("SOURCE_DATE_EPOCH", "yesterday")
+	require.Error(t, runRun(context.Background(), options.RunOptions{
+		KeyOptions:    options.KeyOptions{KeyPath: priv.Name()},
+		WorkingDir:    t.TempDir(),
+		StepName:      "teststep",
+		Deterministic: true,
+	}, []string{"true"}))
+}
+
+func TestRunAttestorRunTypes(t *testing.T) {
+	priv, _ := rsakeypair(t)
+	workingDir := t.TempDir()
+	attestationPath := filepath.Join(workingDir, "outfile.txt")
+	runOptions := options.RunOptions{
+		KeyOptions:   options.KeyOptions{KeyPath: priv.Name()},
+		WorkingDir:   workingDir,
+		Attestations: []string{"environment:post", "material:pre", "product:post"},
+		OutFilePath:  attestationPath,
+		StepName:     "teststep",
+	}
+
+	require.NoError(t, runRun(context.Background(), runOptions, []string{"bash", "-c", "echo test > test.txt"}))
+	_, collection := readCollection(t, attestationPath)
+
+	types := []string{}
+	for _, att := range collection.Attestations {
+		types = append(types, att.Type)
+	}
+
+	require.Equal(t, []string{witnessattestor.Type, material.Type, commandrun.Type, product.Type, environment.Type}, types)
+
+	runOptions.Attestations = []string{"product:pre"}
+	require.Error(t, runRun(context.Background(), runOptions, []string{"true"}))
+	runOptions.Attestations = []string{"environment:during"}
+	require.Error(t, runRun(context.Background(), runOptions, []string{"true"}))
+}
+
+func TestRunTektonResults(t *testing.T) {
+	priv, _ := rsakeypair(t)
+	workingDir := t.TempDir()
+	resultsDir := t.TempDir()
+	attestationPath := filepath.Join(workingDir, "outfile.txt")
+	runOptions := options.RunOptions{
+		KeyOptions:   options.KeyOptions{KeyPath: priv.Name()},
+		WorkingDir:   workingDir,
+		Attestations: []string{},
+		OutFilePath:  attestationPath,
+		StepName:     "teststep",
+		CIMode:       "tekton",
+		CIResultsDir: resultsDir,
+	}
+
+	require.NoError(t, runRun(context.Background(), runOptions, []string{"bash", "-c", "echo 'test' > test.txt"}))
+	attestationBytes, err := os.ReadFile(attestationPath)
+	require.NoError(t, err)
+	url, err := os.ReadFile(filepath.Join(resultsDir, "WITNESS_ATTESTATION_URL"))
+	require.NoError(t, err)
+	require.Equal(t, attestationPath, string(url))
+	digest, err := os.ReadFile(filepath.Join(resultsDir, "WITNESS_ATTESTATION_DIGEST"))
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf("sha256:%x", sha256.Sum256(attestationBytes)), string(digest))
+
+	runOptions.CIMode = "jenkins"
+	require.Error(t, runRun(context.Background(), runOptions, []string{"true"}))
+}
+
+func TestRunCapsule(t *testing.T) {
+	priv, _ := rsakeypair(t)
+	workingDir := t.TempDir()
+	capsulePath := filepath.Join(workingDir, "capsule.tar")
+	attestationPath := filepath.Join(workingDir, "outfile.txt")
+	runOptions := options.RunOptions{
+		KeyOptions:   options.KeyOptions{KeyPath: priv.Name()},
+		WorkingDir:   workingDir,
+		Attestations: []string{},
+		OutFilePath:  attestationPath,
+		StepName:     "teststep",
+		CapsulePath:  capsulePath,
+	}
+
+	args := []string{
+		"bash",
+		"-c",
+		"echo 'test' > test.txt",
+	}
+
+	require.NoError(t, runRun(context.Background(), runOptions, args))
+	original := dsse.Envelope{}
+	attestationBytes, err := os.ReadFile(attestationPath)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(attestationBytes, &original))
+
+	rotatedPriv, _ := rsakeypair(t)
+	resignedPath := filepath.Join(workingDir, "resigned.txt")
+	resignOptions := options.RunOptions{
+		KeyOptions:        options.KeyOptions{KeyPath: rotatedPriv.Name()},
+		OutFilePath:       resignedPath,
+		AttestFromCapsule: capsulePath,
+	}
+
+	require.NoError(t, runRun(context.Background(), resignOptions, []string{}))
+	resigned := dsse.Envelope{}
+	attestationBytes, err = os.ReadFile(resignedPath)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(attestationBytes, &resigned))
+	require.Equal(t, original.Payload, resigned.Payload)
+	require.NotEqual(t, original.Signatures[0].KeyID, resigned.Signatures[0].KeyID)
+
+	require.Error(t, runRun(context.Background(), resignOptions, args))
+}
+
 func createTestRSAKey() (cryptoutil.Signer, cryptoutil.Verifier, []byte, []byte, error) {
 	privKey, err := rsa.GenerateKey(rand.Reader, keybits)
 	if err != nil {
@@ -166,3 +457,81 @@ func createTestRSAKey() (cryptoutil.Signer, cryptoutil.Verifier, []byte, []byte,
 
 	return signer, verifier, pemBytes, privKeyBytes, nil
 }
+
+func TestRunCommandEnv(t *testing.T) {
+	priv, _ := rsakeypair(t)
+	workingDir := t.TempDir()
+	envFile := filepath.Join(t.TempDir(), "build.env")
+	require.NoError(t, os.WriteFile(envFile, []byte("# from the env file\nWITNESS_RUN_A=file\nWITNESS_RUN_B=file\n"), 0600))
+	t.Setenv("WITNESS_RUN_INHERITED", "inherited")
+	attestationPath := filepath.Join(workingDir, "outfile.txt")
+	runOptions := options.RunOptions{
+		KeyOptions:   options.KeyOptions{KeyPath: priv.Name()},
+		WorkingDir:   workingDir,
+		Attestations: []string{},
+		OutFilePath:  attestationPath,
+		StepName:     "teststep",
+		EnvFile:      envFile,
+		Env:          []string{"WITNESS_RUN_B=flag"},
+	}
+
+	script := `echo "$WITNESS_RUN_A $WITNESS_RUN_B ${WITNESS_RUN_INHERITED-unset}" > env.txt`
+	require.NoError(t, runRun(context.Background(), runOptions, []string{"bash", "-c", script}))
+	out, err := os.ReadFile(filepath.Join(workingDir, "env.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "file flag inherited\n", string(out))
+	require.Equal(t, "inherited", os.Getenv("WITNESS_RUN_INHERITED"))
+
+	_, collection := readCollection(t, attestationPath)
+	cr := findAttestor[*commandrun.CommandRun](collection)
+	require.NotNil(t, cr)
+	require.Equal(t, []string{"bash", "-c", script}, cr.Cmd)
+
+	runOptions.CleanEnv = true
+	require.NoError(t, runRun(context.Background(), runOptions, []string{"bash", "-c", script}))
+	out, err = os.ReadFile(filepath.Join(workingDir, "env.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "file flag unset\n", string(out))
+
+	runOptions.Env = []string{"NOT_A_VARIABLE"}
+	require.Error(t, runRun(context.Background(), runOptions, []string{"true"}))
+}
+
+func TestRunTTY(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("--tty is only supported on linux")
+	}
+
+	priv, _ := rsakeypair(t)
+	workingDir := t.TempDir()
+	attestationPath := filepath.Join(workingDir, "outfile.txt")
+	runOptions := options.RunOptions{
+		KeyOptions:   options.KeyOptions{KeyPath: priv.Name()},
+		WorkingDir:   workingDir,
+		Attestations: []string{},
+		OutFilePath:  attestationPath,
+		StepName:     "teststep",
+	}
+
+	// stdout and stderr are recorded separately without a terminal, and both as stdout with one
+	args := []string{"bash", "-c", "test -t 1 && echo terminal; echo error >&2"}
+	tests := []struct {
+		tty    bool
+		stdout string
+		stderr string
+	}{
+		{false, "", "error\n"},
+		{true, "terminal\r\nerror\r\n", ""},
+	}
+
+	for _, test := range tests {
+		runOptions.TTY = test.tty
+		cr := runAndGetAttestor[*commandrun.CommandRun](t, runOptions, args)
+		require.Equal(t, args, cr.Cmd)
+		require.Equal(t, test.stdout, cr.Stdout, "tty: %v", test.tty)
+		require.Equal(t, test.stderr, cr.Stderr, "tty: %v", test.tty)
+	}
+
+	runOptions.InContainer = "alpine"
+	require.Error(t, runRun(context.Background(), runOptions, args))
+}
@@ -0,0 +1,156 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/sarif"
+)
+
+// Rules of the findings witness reports in SARIF, in addition to the step checks
+const (
+	sarifRuleVerificationFailed = "verification-failed"
+	sarifRuleCommandFailed      = "command-failed"
+	sarifRuleRunFailed          = "run-failed"
+)
+
+var sarifRules = []sarif.Rule{
+	sarifRule(stepCheckMissingAttestations, "MissingAttestations", "No attestations were found for a step of the policy"),
+	sarifRule(stepCheckUntrustedSignature, "UntrustedSignature", "A step's attestations weren't signed by a key or root the policy trusts"),
+	sarifRule(stepCheckMissingAttestor, "MissingAttestor", "A step's attestations are missing an attestation the policy requires"),
+	sarifRule(stepCheckRegoDenied, "RegoDenied", "A step's attestation was denied by the policy's rego"),
+	sarifRule(sarifRuleVerificationFailed, "VerificationFailed", "An artifact failed verification for a reason not attributed to one step, such as its artifacts not matching between steps, a checksum mismatch, or missing approvals"),
+	sarifRule(sarifRuleCommandFailed, "CommandFailed", "The command run by witness run exited with an error"),
+	sarifRule(sarifRuleRunFailed, "RunFailed", "witness run failed to record, sign, or store attestations"),
+}
+
+func sarifRule(id, name, description string) sarif.Rule {
+	return sarif.Rule{
+		ID:                   id,
+		Name:                 name,
+		ShortDescription:     sarif.Message{Text: description},
+		DefaultConfiguration: &sarif.Configuration{Level: sarif.LevelError},
+	}
+}
+
+func newSARIFLog() *sarif.Log {
+	return sarif.New(sarif.Driver{
+		Name:           "witness",
+		Version:        Version,
+		InformationURI: "https://github.com/testifysec/witness",
+		Rules:          sarifRules,
+	})
+}
+
+// writeVerifySARIF writes a SARIF log with a result for each failed step of each target that failed verification,
+// and returns the number that failed. Results are located at the artifact if it's a file, and otherwise at the
+// policy.
+func writeVerifySARIF(w io.Writer, policyPath string, targets []verifyTarget) (int, error) {
+	log := newSARIFLog()
+	failed := 0
+	for _, target := range targets {
+		if target.err == nil {
+			continue
+		}
+
+		failed++
+		location := policyPath
+		if info, err := os.Stat(target.name); err == nil && info.Mode().IsRegular() {
+			location = target.name
+		}
+
+		attributed := false
+		for _, step := range target.steps {
+			if step.passed {
+				continue
+			}
+
+			attributed = true
+			log.Add(sarifResult(step.check, fmt.Sprintf("%v: %v", target.name, step.message), location, target.name, step.step))
+		}
+
+		if !attributed {
+			log.Add(sarifResult(sarifRuleVerificationFailed, fmt.Sprintf("%v: %v", target.name, target.err), location, target.name, ""))
+		}
+	}
+
+	if err := log.Write(w); err != nil {
+		return failed, fmt.Errorf("failed to write sarif log: %w", err)
+	}
+
+	return failed, nil
+}
+
+func sarifResult(rule, message, location, artifact, step string) sarif.Result {
+	result := sarif.Result{
+		RuleID:              rule,
+		Level:               sarif.LevelError,
+		Message:             sarif.Message{Text: message},
+		PartialFingerprints: map[string]string{"witnessFinding/v1": fmt.Sprintf("%v:%v:%v", rule, artifact, step)},
+		Properties:          map[string]interface{}{},
+	}
+
+	if artifact != "" {
+		result.Properties["artifact"] = artifact
+	}
+
+	if step != "" {
+		result.Properties["step"] = step
+	}
+
+	if location != "" {
+		result.Locations = []sarif.Location{sarif.FileLocation(filepath.ToSlash(location))}
+	}
+
+	return result
+}
+
+// writeRunSARIF writes a SARIF log to ro.SARIFPath with a result if the run failed, so a passing run clears the
+// finding from dashboards. Results are located at the command if it's a file in the working directory, such as a
+// build script.
+func writeRunSARIF(ro options.RunOptions, args []string, runErr error) error {
+	log := newSARIFLog()
+	if runErr != nil {
+		rule := sarifRuleRunFailed
+		exitErr := &exec.ExitError{}
+		if errors.As(runErr, &exitErr) {
+			rule = sarifRuleCommandFailed
+		}
+
+		location := ""
+		if len(args) > 0 {
+			if info, err := os.Stat(filepath.Join(ro.WorkingDir, args[0])); err == nil && info.Mode().IsRegular() && !filepath.IsAbs(args[0]) {
+				location = filepath.Join(ro.WorkingDir, args[0])
+			}
+		}
+
+		log.Add(sarifResult(rule, fmt.Sprintf("step %v: %v", ro.StepName, runErr), location, "", ro.StepName))
+	}
+
+	f, err := os.Create(ro.SARIFPath)
+	if err != nil {
+		return fmt.Errorf("failed to create sarif file: %w", err)
+	}
+
+	defer f.Close()
+	return log.Write(f)
+}
@@ -0,0 +1,92 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/sarif"
+)
+
+func readSARIF(t *testing.T, b []byte) sarif.Log {
+	log := sarif.Log{}
+	require.NoError(t, json.Unmarshal(b, &log))
+	assert.Equal(t, sarif.Version, log.Version)
+	require.Len(t, log.Runs, 1)
+	assert.Equal(t, "witness", log.Runs[0].Tool.Driver.Name)
+	return log
+}
+
+func TestVerifySARIF(t *testing.T) {
+	f := newVerifyFixture(t)
+	step1 := f.run(t, "step01", f.funcPrivPath, "echo 'test01' > test.txt")
+
+	vo := f.verifyOptions(f.policyPubPath, step1)
+	targets, err := verifyPolicy(context.Background(), vo)
+	require.Error(t, err)
+
+	out := &bytes.Buffer{}
+	vo.Output = verifyOutputSARIF
+	failed, err := writeVerifyResults(out, vo, targets)
+	require.NoError(t, err)
+	assert.Equal(t, 1, failed)
+
+	log := readSARIF(t, out.Bytes())
+	results := log.Runs[0].Results
+	require.Len(t, results, 1)
+	assert.Equal(t, stepCheckMissingAttestations, results[0].RuleID)
+	assert.Equal(t, sarif.LevelError, results[0].Level)
+	assert.Equal(t, "step02", results[0].Properties["step"])
+	assert.NotEmpty(t, results[0].PartialFingerprints)
+	require.Len(t, results[0].Locations, 1)
+	assert.Equal(t, filepath.ToSlash(f.artifactPath), results[0].Locations[0].PhysicalLocation.ArtifactLocation.URI)
+}
+
+func TestRunSARIF(t *testing.T) {
+	priv, _ := rsakeypair(t)
+	workingDir := t.TempDir()
+	sarifPath := filepath.Join(t.TempDir(), "witness.sarif")
+	ro := options.RunOptions{
+		KeyOptions:  options.KeyOptions{KeyPath: priv.Name()},
+		WorkingDir:  workingDir,
+		OutFilePath: filepath.Join(t.TempDir(), "out.json"),
+		StepName:    "build",
+		SARIFPath:   sarifPath,
+	}
+
+	require.NoError(t, os.WriteFile(filepath.Join(workingDir, "build.sh"), []byte("#!/bin/sh\nexit 3\n"), 0755))
+	require.Error(t, runRun(context.Background(), ro, []string{"./build.sh"}))
+	b, err := os.ReadFile(sarifPath)
+	require.NoError(t, err)
+	results := readSARIF(t, b).Runs[0].Results
+	require.Len(t, results, 1)
+	assert.Equal(t, sarifRuleCommandFailed, results[0].RuleID)
+	assert.Equal(t, "build", results[0].Properties["step"])
+	require.Len(t, results[0].Locations, 1)
+	assert.Equal(t, filepath.ToSlash(filepath.Join(workingDir, "build.sh")), results[0].Locations[0].PhysicalLocation.ArtifactLocation.URI)
+
+	require.NoError(t, runRun(context.Background(), ro, []string{"sh", "-c", "true"}))
+	b, err = os.ReadFile(sarifPath)
+	require.NoError(t, err)
+	assert.Empty(t, readSARIF(t, b).Runs[0].Results)
+}
@@ -0,0 +1,79 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/witness/attestation/scai"
+	"github.com/testifysec/witness/options"
+)
+
+func runScai(t *testing.T, runOptions options.RunOptions, args []string) *scai.Attestor {
+	priv, _ := rsakeypair(t)
+	attestationPath := filepath.Join(t.TempDir(), "outfile.txt")
+	runOptions.KeyOptions = options.KeyOptions{KeyPath: priv.Name()}
+	runOptions.OutFilePath = attestationPath
+	runOptions.StepName = "build"
+	return runAndGetAttestor[*scai.Attestor](t, runOptions, args)
+}
+
+func TestRunScaiHardening(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc is required to build test binaries")
+	}
+
+	src := filepath.Join(t.TempDir(), "app.c")
+	require.NoError(t, os.WriteFile(src, []byte("#include <string.h>\nint main(int argc, char **argv) { char buf[64]; strcpy(buf, argv[0]); return buf[0] == 0; }\n"), 0644))
+	attestor := runScai(t, options.RunOptions{
+		WorkingDir:   t.TempDir(),
+		Attestations: []string{scai.Name},
+	}, []string{"bash", "-c", "gcc -O2 -fstack-protector-all -fPIE -pie -Wl,-z,relro,-z,now -o hardened " + src + " && gcc -O0 -fno-stack-protector -no-pie -o plain " + src})
+
+	attributes := make(map[string][]string)
+	for _, assertion := range attestor.Attributes {
+		require.NotNil(t, assertion.Target)
+		assert.NotEmpty(t, assertion.Target.Digest["sha256"])
+		attributes[assertion.Target.Name] = append(attributes[assertion.Target.Name], assertion.Attribute)
+	}
+
+	assert.Subset(t, attributes["hardened"], []string{scai.AttributeStackProtection, scai.AttributePIE, scai.AttributeRELRO, scai.AttributeFullRELRO, scai.AttributeNXStack})
+	assert.NotContains(t, attributes["plain"], scai.AttributeStackProtection)
+	assert.NotContains(t, attributes["plain"], scai.AttributePIE)
+}
+
+func TestRunScaiAttributesFile(t *testing.T) {
+	report := `{"producer": {"name": "rebuilder"}, "attributes": [{"attribute": "REPRODUCIBLE", "target": {"name": "app.txt"}, "evidence": {"name": "rebuild.log"}, "conditions": {"builds": 2}}]}`
+	attestor := runScai(t, options.RunOptions{
+		WorkingDir:         t.TempDir(),
+		Attestations:       []string{},
+		ScaiAttributesPath: "scai.json",
+	}, []string{"bash", "-c", "echo app > app.txt && echo rebuilt > rebuild.log && echo '" + report + "' > scai.json"})
+
+	require.Len(t, attestor.Attributes, 1)
+	assertion := attestor.Attributes[0]
+	assert.Equal(t, "REPRODUCIBLE", assertion.Attribute)
+	assert.Equal(t, "app.txt", assertion.Target.Name)
+	assert.NotEmpty(t, assertion.Target.Digest["sha256"])
+	assert.NotEmpty(t, assertion.Evidence.Digest["sha256"])
+	assert.Equal(t, float64(2), assertion.Conditions["builds"])
+	require.NotNil(t, attestor.Producer)
+	assert.Equal(t, "rebuilder", attestor.Producer.Name)
+}
@@ -0,0 +1,76 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/pkg/scitt"
+)
+
+// loadSCITTStatements loads the envelopes carried by transparent statements into src, once each statement's receipt
+// proves it was registered with the transparency service whose public key is at serviceKeyPath
+func loadSCITTStatements(src *collectionMemorySource, paths []string, serviceKeyPath string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+
+	if serviceKeyPath == "" {
+		return fmt.Errorf("--scitt-statements requires --scitt-service-key to check their receipts")
+	}
+
+	keyFile, err := os.Open(serviceKeyPath)
+	if err != nil {
+		return fmt.Errorf("failed to open scitt service key: %w", err)
+	}
+
+	defer keyFile.Close()
+	serviceKey, err := cryptoutil.TryParseKeyFromReader(keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to parse scitt service key: %w", err)
+	}
+
+	if cert, ok := serviceKey.(*x509.Certificate); ok {
+		serviceKey = cert.PublicKey
+	}
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read transparent statement: %w", err)
+		}
+
+		ts, err := scitt.ParseTransparentStatement(data)
+		if err != nil {
+			return fmt.Errorf("failed to load transparent statement %v: %w", path, err)
+		}
+
+		if err := ts.Verify(serviceKey); err != nil {
+			return withExitCode(ExitCodeSignature, fmt.Errorf("transparent statement %v: %w", path, err))
+		}
+
+		if err := src.LoadEnvelope(path, ts.Envelope); err != nil {
+			return fmt.Errorf("failed to load transparent statement %v: %w", path, err)
+		}
+
+		log.Debugf("(scitt) loaded %v from issuer %v with a valid receipt", path, ts.Issuer)
+	}
+
+	return nil
+}
@@ -0,0 +1,143 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/policy"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/cose"
+	"github.com/testifysec/witness/pkg/scitt"
+)
+
+// newSCITTService serves a transparency service that logs each statement in a tree of its own, and returns its URL
+// and the path of its public key
+func newSCITTService(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/entries" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		statement, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		proof, err := scitt.InclusionProof{TreeSize: 1, LeafIndex: 0}.Encode()
+		require.NoError(t, err)
+		receipt, err := cose.SignDetached(key,
+			cose.Headers{scitt.HeaderVerifiableDataStructure: scitt.RFC9162SHA256},
+			cose.Headers{scitt.HeaderVerifiableDataProofs: cose.Headers{scitt.ProofTypeInclusion: []interface{}{proof}}},
+			scitt.LeafHash(statement))
+		require.NoError(t, err)
+		w.Header().Set("Location", "/entries/42")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write(receipt)
+	}))
+
+	t.Cleanup(server.Close)
+	return server.URL, writePublicKey(t, &key.PublicKey)
+}
+
+func writePublicKey(t *testing.T, pub crypto.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "pub.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644))
+	return path
+}
+
+func TestSCITTRegistration(t *testing.T) {
+	// signed statements need a key COSE allows, so step02 is signed with a P-256 key
+	stepKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	stepKeyDER, err := x509.MarshalPKCS8PrivateKey(stepKey)
+	require.NoError(t, err)
+	stepKeyPath := filepath.Join(t.TempDir(), "step-key.pem")
+	require.NoError(t, os.WriteFile(stepKeyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: stepKeyDER}), 0600))
+
+	f := newVerifyFixtureWithPolicy(t, func(p map[string]interface{}) {
+		keyID, err := cryptoutil.NewECDSAVerifier(&stepKey.PublicKey, crypto.SHA256).KeyID()
+		require.NoError(t, err)
+		pubPEM, err := os.ReadFile(writePublicKey(t, &stepKey.PublicKey))
+		require.NoError(t, err)
+		p["publickeys"].(map[string]interface{})[keyID] = policy.PublicKey{KeyID: keyID, Key: pubPEM}
+		p["steps"].(map[string]interface{})["step02"].(map[string]interface{})["functionaries"] = []policy.Functionary{{Type: "PublicKey", PublicKeyID: keyID}}
+	})
+
+	serviceURL, serviceKeyPath := newSCITTService(t)
+	step1 := f.run(t, "step01", f.funcPrivPath, "echo 'test01' > test.txt")
+	step1Digest, err := cryptoutil.CalculateDigestSetFromFile(f.artifactPath, []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+
+	step2 := filepath.Join(t.TempDir(), "step02.json")
+	require.NoError(t, runRun(context.Background(), options.RunOptions{
+		KeyOptions:   options.KeyOptions{KeyPath: stepKeyPath},
+		SCITTOptions: options.SCITTOptions{Server: serviceURL, Issuer: "ci"},
+		WorkingDir:   f.workingDir,
+		OutFilePath:  step2,
+		StepName:     "step02",
+	}, []string{"bash", "-c", "echo 'test02' >> test.txt"}))
+
+	statementBytes, err := os.ReadFile(step2 + ".scitt")
+	require.NoError(t, err)
+	ts, err := scitt.ParseTransparentStatement(statementBytes)
+	require.NoError(t, err)
+	assert.Equal(t, "ci", ts.Issuer)
+	assert.Equal(t, "step02", ts.Subject)
+
+	// step02's attestation is only loaded from the transparent statement
+	vo := f.verifyOptions(f.policyPubPath, step1)
+	vo.AdditionalSubjects = []string{step1Digest[crypto.SHA256]}
+	vo.SCITTStatementPaths = []string{step2 + ".scitt"}
+	vo.SCITTServiceKeyPath = serviceKeyPath
+	require.NoError(t, runVerify(context.Background(), vo))
+
+	vo.SCITTServiceKeyPath = writePublicKey(t, &stepKey.PublicKey)
+	err = runVerify(context.Background(), vo)
+	require.Error(t, err)
+	assert.Equal(t, ExitCodeSignature, ExitCode(err))
+
+	vo.SCITTServiceKeyPath = ""
+	assert.ErrorContains(t, runVerify(context.Background(), vo), "requires --scitt-service-key")
+}
+
+func TestSCITTRequiresStatementPath(t *testing.T) {
+	priv, _ := rsakeypair(t)
+	err := runRun(context.Background(), options.RunOptions{
+		KeyOptions:   options.KeyOptions{KeyPath: priv.Name()},
+		SCITTOptions: options.SCITTOptions{Server: "https://scitt.example.com"},
+		WorkingDir:   t.TempDir(),
+		StepName:     "step01",
+	}, []string{"true"})
+
+	assert.ErrorContains(t, err, "--scitt-statement must be set")
+}
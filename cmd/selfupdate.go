@@ -0,0 +1,155 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/selfupdate"
+)
+
+func SelfUpdateCmd() *cobra.Command {
+	o := options.SelfUpdateOptions{}
+	cmd := &cobra.Command{
+		Use:   "self-update",
+		Short: "Installs a witness release after verifying it against its attestations",
+		Long: "Downloads the witness release for this OS and architecture and verifies its binary against a signed policy, " +
+			"using the attestations recorded when the release was built, before installing it over the running binary. " +
+			"Releases don't publish a policy or attestations, so both must be provided, along with the policy signer's key " +
+			"or CA so the release is only trusted if the policy is",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSelfUpdate(cmd.Context(), o)
+		},
+		Args: cobra.NoArgs,
+	}
+
+	o.AddFlags(cmd)
+	return cmd
+}
+
+func runSelfUpdate(ctx context.Context, o options.SelfUpdateOptions) error {
+	if o.KeyPath == "" && len(o.CAPaths) == 0 {
+		return fmt.Errorf("must supply the release policy's public key or ca paths")
+	}
+
+	// releases don't publish a signed policy or their attestations, so there's nothing to default to
+	if o.PolicyFilePath == "" {
+		return fmt.Errorf("--policy is required")
+	}
+
+	if len(o.AttestationFilePaths) == 0 && !o.ArchivistOptions.Enable {
+		return fmt.Errorf("--attestations or --enable-archivist is required to find the release's attestations")
+	}
+
+	tag := o.Version
+	if tag == "" {
+		var err error
+		if tag, err = selfupdate.LatestTag(ctx, o.ReleaseURL); err != nil {
+			return withExitCode(ExitCodeInfrastructure, err)
+		}
+	}
+
+	if !o.Force && isRunningVersion(tag) {
+		log.Infof("witness %v is already installed", tag)
+		return nil
+	}
+
+	installPath := o.InstallPath
+	if installPath == "" {
+		executable, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("failed to find witness executable: %w", err)
+		}
+
+		if installPath, err = filepath.EvalSymlinks(executable); err != nil {
+			return fmt.Errorf("failed to resolve witness executable: %w", err)
+		}
+	}
+
+	tmpDir, err := os.MkdirTemp("", "witness-self-update-")
+	if err != nil {
+		return err
+	}
+
+	defer os.RemoveAll(tmpDir)
+	archiveName := selfupdate.ArchiveName(tag, runtime.GOOS, runtime.GOARCH)
+	archivePath := filepath.Join(tmpDir, archiveName)
+	if err := downloadReleaseAsset(ctx, o.ReleaseURL, tag, archiveName, archivePath); err != nil {
+		return err
+	}
+
+	binaryName := selfupdate.BinaryName(runtime.GOOS)
+	binaryPath := filepath.Join(tmpDir, binaryName)
+	if err := selfupdate.ExtractBinary(archivePath, binaryName, binaryPath); err != nil {
+		return err
+	}
+
+	err = runVerify(ctx, options.VerifyOptions{
+		ArchivistOptions:     o.ArchivistOptions,
+		KeyPath:              o.KeyPath,
+		CAPaths:              o.CAPaths,
+		PolicyFilePath:       o.PolicyFilePath,
+		AttestationFilePaths: o.AttestationFilePaths,
+		AdditionalSubjects:   o.AdditionalSubjects,
+		ArtifactFilePath:     binaryPath,
+		ValidateSchemas:      true,
+	})
+
+	if err != nil {
+		return fmt.Errorf("witness %v failed verification: %w", tag, err)
+	}
+
+	if o.DryRun {
+		log.Infof("witness %v passed verification and was not installed", tag)
+		return nil
+	}
+
+	if err := selfupdate.Replace(binaryPath, installPath); err != nil {
+		return fmt.Errorf("failed to install witness %v: %w", tag, err)
+	}
+
+	log.Infof("Installed witness %v to %v", tag, installPath)
+	return nil
+}
+
+func downloadReleaseAsset(ctx context.Context, releaseURL, tag, name, dest string) error {
+	assetURL, err := selfupdate.AssetURL(releaseURL, tag, name)
+	if err != nil {
+		return err
+	}
+
+	if err := selfupdate.Download(ctx, assetURL, dest); err != nil {
+		return withExitCode(ExitCodeInfrastructure, err)
+	}
+
+	return nil
+}
+
+// isRunningVersion returns true if tag is the release this binary was built from. Release builds are versioned
+// as the tag followed by the short commit.
+func isRunningVersion(tag string) bool {
+	return Version == tag || strings.HasPrefix(Version, tag+"-")
+}
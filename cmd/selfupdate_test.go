@@ -0,0 +1,128 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/selfupdate"
+)
+
+func releaseArchive(t *testing.T, binary []byte) []byte {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "README.md", Mode: 0644, Size: 6, Typeflag: tar.TypeReg}))
+	_, err := tw.Write([]byte("readme"))
+	require.NoError(t, err)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: selfupdate.BinaryName(runtime.GOOS), Mode: 0755, Size: int64(len(binary)), Typeflag: tar.TypeReg}))
+	_, err = tw.Write(binary)
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func TestRunSelfUpdate(t *testing.T) {
+	f := newVerifyFixture(t)
+	step1 := f.run(t, "step01", f.funcPrivPath, "echo 'test01' > test.txt")
+	step1Digest, err := cryptoutil.CalculateDigestSetFromFile(f.artifactPath, []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+	step2 := f.run(t, "step02", f.funcPrivPath, "echo 'test02' >> test.txt")
+	binary, err := os.ReadFile(f.artifactPath)
+	require.NoError(t, err)
+
+	archives := map[string][]byte{
+		"v1.2.3": releaseArchive(t, binary),
+		"v1.2.4": releaseArchive(t, []byte("tampered")),
+	}
+
+	latest := "v1.2.3"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/releases/latest" {
+			http.Redirect(w, r, "/releases/tag/"+latest, http.StatusFound)
+			return
+		}
+
+		for tag, archive := range archives {
+			if r.URL.Path == "/releases/download/"+tag+"/"+selfupdate.ArchiveName(tag, runtime.GOOS, runtime.GOARCH) {
+				_, _ = w.Write(archive)
+				return
+			}
+		}
+
+		http.NotFound(w, r)
+	}))
+
+	defer server.Close()
+
+	installPath := filepath.Join(t.TempDir(), "witness")
+	require.NoError(t, os.WriteFile(installPath, []byte("old"), 0755))
+	o := options.SelfUpdateOptions{
+		ReleaseURL:           server.URL + "/releases",
+		InstallPath:          installPath,
+		KeyPath:              f.policyPubPath,
+		PolicyFilePath:       f.policyPath,
+		AttestationFilePaths: []string{step1, step2},
+		AdditionalSubjects:   []string{step1Digest[crypto.SHA256]},
+		DryRun:               true,
+	}
+
+	noPolicy := o
+	noPolicy.PolicyFilePath = ""
+	assert.ErrorContains(t, runSelfUpdate(context.Background(), noPolicy), "--policy is required")
+	noAttestations := o
+	noAttestations.AttestationFilePaths = nil
+	assert.ErrorContains(t, runSelfUpdate(context.Background(), noAttestations), "--attestations or --enable-archivist is required")
+
+	require.NoError(t, runSelfUpdate(context.Background(), o))
+	installed, err := os.ReadFile(installPath)
+	require.NoError(t, err)
+	assert.Equal(t, "old", string(installed))
+
+	o.DryRun = false
+	require.NoError(t, runSelfUpdate(context.Background(), o))
+	installed, err = os.ReadFile(installPath)
+	require.NoError(t, err)
+	assert.Equal(t, binary, installed)
+
+	latest = "v1.2.4"
+	require.NoError(t, os.WriteFile(installPath, []byte("old"), 0755))
+	err = runSelfUpdate(context.Background(), o)
+	require.Error(t, err)
+	assert.Equal(t, ExitCodeMissingAttestations, ExitCode(err))
+	installed, err = os.ReadFile(installPath)
+	require.NoError(t, err)
+	assert.Equal(t, "old", string(installed))
+
+	o.Version = "v9.9.9"
+	err = runSelfUpdate(context.Background(), o)
+	require.Error(t, err)
+	assert.Equal(t, ExitCodeInfrastructure, ExitCode(err))
+}
@@ -27,6 +27,13 @@ import (
 	"github.com/testifysec/witness/options"
 )
 
+const (
+	envelopeFormatDSSE         = "dsse"
+	envelopeFormatCOSE         = "cose"
+	envelopeFormatNotationJWS  = "notation-jws"
+	envelopeFormatNotationCOSE = "notation-cose"
+)
+
 func SignCmd() *cobra.Command {
 	so := options.SignOptions{}
 	cmd := &cobra.Command{
@@ -50,6 +57,16 @@ func SignCmd() *cobra.Command {
 func runSign(so options.SignOptions) error {
 	ctx := context.Background()
 
+	switch so.EnvelopeFormat {
+	case "", envelopeFormatDSSE:
+	case envelopeFormatCOSE:
+		return signCOSE(so)
+	case envelopeFormatNotationJWS, envelopeFormatNotationCOSE:
+		return signNotation(so)
+	default:
+		return fmt.Errorf("unknown envelope format %v", so.EnvelopeFormat)
+	}
+
 	if so.KeyOptions.FulcioURL != "" {
 		err := fmt.Errorf("fulcio url is not supported for signing")
 		return err
@@ -73,6 +90,11 @@ func runSign(so options.SignOptions) error {
 		return fmt.Errorf("no signers found")
 	}
 
+	envSigners, err := envelopeSigners(so.KeyOptions, signers[0])
+	if err != nil {
+		return err
+	}
+
 	timestampers := []dsse.Timestamper{}
 	for _, url := range so.TimestampServers {
 		timestampers = append(timestampers, timestamp.NewTimestamper(timestamp.TimestampWithUrl(url)))
@@ -89,5 +111,5 @@ func runSign(so options.SignOptions) error {
 	}
 
 	defer outFile.Close()
-	return witness.Sign(inFile, so.DataType, outFile, dsse.SignWithSigners(signers[0]), dsse.SignWithTimestampers(timestampers...))
+	return witness.Sign(inFile, so.DataType, outFile, dsse.SignWithSigners(envSigners...), dsse.SignWithTimestampers(timestampers...))
 }
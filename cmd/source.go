@@ -0,0 +1,513 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/testifysec/go-witness/archivist"
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/go-witness/source"
+	"github.com/testifysec/witness/pkg/cache"
+	"github.com/testifysec/witness/pkg/detached"
+	"github.com/testifysec/witness/pkg/disclosure"
+	"github.com/testifysec/witness/pkg/fips"
+	"github.com/testifysec/witness/pkg/keywindow"
+	"github.com/testifysec/witness/pkg/localstore"
+	"github.com/testifysec/witness/pkg/migrate"
+	"github.com/testifysec/witness/pkg/revocation"
+	"github.com/testifysec/witness/pkg/schema"
+	"github.com/testifysec/witness/pkg/sct"
+)
+
+const (
+	archivistSearchNamespace   = "archivist-search"
+	archivistEnvelopeNamespace = "archivist-envelopes"
+)
+
+// cachingArchivistSource behaves like source.ArchivistSource but stores search results and downloaded
+// envelopes in a local cache, so repeated verifications of the same artifacts don't query Archivist again.
+type cachingArchivistSource struct {
+	client      *archivist.Client
+	cache       *cache.Cache
+	url         string
+	seenGitoids []string
+}
+
+func newCachingArchivistSource(url string, c *cache.Cache) *cachingArchivistSource {
+	return &cachingArchivistSource{
+		client:      archivist.New(url),
+		cache:       c,
+		url:         url,
+		seenGitoids: make([]string, 0),
+	}
+}
+
+func (s *cachingArchivistSource) Search(ctx context.Context, collectionName string, subjectDigests, attestations []string) ([]source.CollectionEnvelope, error) {
+	// archivist indexes the attestation types as they were recorded, so collections with attestations that
+	// will be migrated to a requested type can't be filtered by type on the server
+	for _, attestationType := range attestations {
+		if migrate.IsTarget(attestationType) {
+			return s.search(ctx, collectionName, subjectDigests, attestations, nil)
+		}
+	}
+
+	return s.search(ctx, collectionName, subjectDigests, attestations, attestations)
+}
+
+func (s *cachingArchivistSource) search(ctx context.Context, collectionName string, subjectDigests, attestations, attestationFilter []string) ([]source.CollectionEnvelope, error) {
+	vars := archivist.SearchGitoidVariables{
+		CollectionName: collectionName,
+		SubjectDigests: subjectDigests,
+		Attestations:   attestationFilter,
+		ExcludeGitoids: s.seenGitoids,
+	}
+
+	searchKey, err := json.Marshal(struct {
+		Url  string
+		Vars archivist.SearchGitoidVariables
+	}{s.url, vars})
+	if err != nil {
+		return nil, err
+	}
+
+	gitoids := []string{}
+	if !s.cache.GetJSON(archivistSearchNamespace, string(searchKey), &gitoids) {
+		gitoids, err = s.client.SearchGitoids(ctx, vars)
+		if err != nil {
+			return []source.CollectionEnvelope{}, err
+		}
+
+		if err := s.cache.PutJSON(archivistSearchNamespace, string(searchKey), gitoids); err != nil {
+			log.Debugf("(cache) failed to cache archivist search: %v", err)
+		}
+	} else {
+		log.Debugf("(cache) using cached archivist search for %v", collectionName)
+	}
+
+	envelopes := make([]source.CollectionEnvelope, 0, len(gitoids))
+	for _, gitoid := range gitoids {
+		env, err := s.download(ctx, gitoid)
+		if err != nil {
+			return envelopes, err
+		}
+
+		s.seenGitoids = append(s.seenGitoids, gitoid)
+		collectionEnv, err := envelopeToCollectionEnvelope(gitoid, env, nil, nil)
+		if err != nil {
+			return envelopes, err
+		}
+
+		if !hasAttestations(collectionEnv.Collection, attestations) {
+			continue
+		}
+
+		envelopes = append(envelopes, collectionEnv)
+	}
+
+	return envelopes, nil
+}
+
+func (s *cachingArchivistSource) download(ctx context.Context, gitoid string) (dsse.Envelope, error) {
+	env := dsse.Envelope{}
+	key := strings.Join([]string{s.url, gitoid}, "/")
+	if s.cache.GetJSON(archivistEnvelopeNamespace, key, &env) {
+		return env, nil
+	}
+
+	env, err := s.client.Download(ctx, gitoid)
+	if err != nil {
+		return env, err
+	}
+
+	if err := s.cache.PutJSON(archivistEnvelopeNamespace, key, env); err != nil {
+		log.Debugf("(cache) failed to cache envelope %v: %v", gitoid, err)
+	}
+
+	return env, nil
+}
+
+// localStoreSource finds collections in the local attestation store by their subject digests
+type localStoreSource struct {
+	store *localstore.Store
+}
+
+func (s localStoreSource) Search(ctx context.Context, collectionName string, subjectDigests, attestations []string) ([]source.CollectionEnvelope, error) {
+	seen := make(map[string]struct{})
+	envelopes := []source.CollectionEnvelope{}
+	for _, digest := range subjectDigests {
+		gitoids, err := s.store.Search(digest)
+		if err != nil {
+			return envelopes, err
+		}
+
+		for _, gitoid := range gitoids {
+			if _, ok := seen[gitoid]; ok {
+				continue
+			}
+
+			seen[gitoid] = struct{}{}
+			env, err := s.store.Get(gitoid)
+			if err != nil {
+				return envelopes, err
+			}
+
+			collectionEnv, err := envelopeToCollectionEnvelope(gitoid, env, nil, nil)
+			if err != nil {
+				return envelopes, err
+			}
+
+			if collectionEnv.Collection.Name == collectionName && hasAttestations(collectionEnv.Collection, attestations) {
+				envelopes = append(envelopes, collectionEnv)
+			}
+		}
+	}
+
+	return envelopes, nil
+}
+
+// schemaValidatingSource fails a search if any collection it finds contains an attestation that doesn't match
+// the attestor's published schema, so malformed predicates are reported explicitly instead of failing policy
+type schemaValidatingSource struct {
+	source source.Sourcer
+}
+
+func (s schemaValidatingSource) Search(ctx context.Context, collectionName string, subjectDigests, attestations []string) ([]source.CollectionEnvelope, error) {
+	envelopes, err := s.source.Search(ctx, collectionName, subjectDigests, attestations)
+	if err != nil {
+		return envelopes, err
+	}
+
+	for _, env := range envelopes {
+		unknown, err := schema.ValidateCollection(env.Statement.Predicate)
+		if err != nil {
+			return nil, fmt.Errorf("collection %v is malformed: %w", env.Reference, err)
+		}
+
+		for _, attestorType := range unknown {
+			log.Debugf("(schema) no schema for attestation %v in %v", attestorType, env.Reference)
+		}
+	}
+
+	return envelopes, nil
+}
+
+// revokingSource drops collections that appear on a revocation list, so they can't satisfy a policy
+type revokingSource struct {
+	source     source.Sourcer
+	revocation *revocation.List
+}
+
+func (s revokingSource) Search(ctx context.Context, collectionName string, subjectDigests, attestations []string) ([]source.CollectionEnvelope, error) {
+	envelopes, err := s.source.Search(ctx, collectionName, subjectDigests, attestations)
+	if err != nil {
+		return envelopes, err
+	}
+
+	trusted := make([]source.CollectionEnvelope, 0, len(envelopes))
+	for _, env := range envelopes {
+		entry, revoked, err := s.revocation.Check(env.Envelope)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check revocation of %v: %w", env.Reference, err)
+		}
+
+		if revoked {
+			log.Warnf("rejecting revoked collection %v: %v", env.Reference, entry.Reason)
+			continue
+		}
+
+		trusted = append(trusted, env)
+	}
+
+	return trusted, nil
+}
+
+// keyWindowSource removes signatures made outside of their key's validity window from the collections it finds
+type keyWindowSource struct {
+	source  source.Sourcer
+	windows *keywindow.Windows
+}
+
+func (s keyWindowSource) Search(ctx context.Context, collectionName string, subjectDigests, attestations []string) ([]source.CollectionEnvelope, error) {
+	envelopes, err := s.source.Search(ctx, collectionName, subjectDigests, attestations)
+	if err != nil {
+		return envelopes, err
+	}
+
+	for i := range envelopes {
+		var rejected []string
+		envelopes[i].Envelope, rejected = s.windows.Filter(ctx, envelopes[i].Envelope)
+		for _, keyID := range rejected {
+			log.Warnf("ignoring signature on %v from key %v, which isn't timestamped within the key's validity window", envelopes[i].Reference, keyID)
+		}
+	}
+
+	return envelopes, nil
+}
+
+// sctSource removes signatures made with certificates that don't carry a valid SCT from the certificate
+// transparency logs the policy trusts for their root from the collections it finds
+type sctSource struct {
+	source       source.Sourcer
+	requirements *sct.Requirements
+}
+
+func (s sctSource) Search(ctx context.Context, collectionName string, subjectDigests, attestations []string) ([]source.CollectionEnvelope, error) {
+	envelopes, err := s.source.Search(ctx, collectionName, subjectDigests, attestations)
+	if err != nil {
+		return envelopes, err
+	}
+
+	for i := range envelopes {
+		var rejected []sct.Rejection
+		envelopes[i].Envelope, rejected = s.requirements.Filter(envelopes[i].Envelope)
+		for _, rejection := range rejected {
+			log.Warnf("ignoring signature on %v from key %v: %v", envelopes[i].Reference, rejection.KeyID, rejection.Reason)
+		}
+	}
+
+	return envelopes, nil
+}
+
+// fipsSource removes signatures made with certificates that aren't FIPS compliant from the collections it finds
+type fipsSource struct {
+	source source.Sourcer
+}
+
+func (s fipsSource) Search(ctx context.Context, collectionName string, subjectDigests, attestations []string) ([]source.CollectionEnvelope, error) {
+	envelopes, err := s.source.Search(ctx, collectionName, subjectDigests, attestations)
+	if err != nil {
+		return envelopes, err
+	}
+
+	for i := range envelopes {
+		var rejected []string
+		envelopes[i].Envelope, rejected = fips.FilterSignatures(envelopes[i].Envelope)
+		for _, keyID := range rejected {
+			log.Warnf("ignoring signature on %v from key %v, whose certificate chain isn't FIPS compliant", envelopes[i].Reference, keyID)
+		}
+	}
+
+	return envelopes, nil
+}
+
+// pqSignedSource drops collections that aren't also signed by one of the trusted post-quantum keys, so a
+// collection is only trusted if both its classical and post-quantum signatures verify
+type pqSignedSource struct {
+	source    source.Sourcer
+	verifiers []cryptoutil.Verifier
+}
+
+func (s pqSignedSource) Search(ctx context.Context, collectionName string, subjectDigests, attestations []string) ([]source.CollectionEnvelope, error) {
+	envelopes, err := s.source.Search(ctx, collectionName, subjectDigests, attestations)
+	if err != nil {
+		return envelopes, err
+	}
+
+	signed := make([]source.CollectionEnvelope, 0, len(envelopes))
+	for _, env := range envelopes {
+		if _, err := env.Envelope.Verify(dsse.VerifyWithVerifiers(s.verifiers...)); err != nil {
+			log.Warnf("rejecting collection %v without a trusted post-quantum signature", env.Reference)
+			continue
+		}
+
+		signed = append(signed, env)
+	}
+
+	return signed, nil
+}
+
+func envelopeToCollectionEnvelope(reference string, env dsse.Envelope, predicates *detached.Store, bundles *disclosure.Store) (source.CollectionEnvelope, error) {
+	statement := intoto.Statement{}
+	if err := json.Unmarshal(env.Payload, &statement); err != nil {
+		return source.CollectionEnvelope{}, err
+	}
+
+	// like migrations, the detached collection is bound here rather than in the envelope, so the signature over its
+	// digest still verifies
+	if statement.PredicateType == detached.PredicateType {
+		link, err := detached.Parse(statement.Predicate)
+		if err != nil {
+			return source.CollectionEnvelope{}, fmt.Errorf("%v: %w", reference, err)
+		}
+
+		if link.PredicateType != attestation.CollectionType {
+			return source.CollectionEnvelope{}, fmt.Errorf("%v: detached predicate is a %v, not a collection", reference, link.PredicateType)
+		}
+
+		body, err := predicates.Resolve(link)
+		if err != nil {
+			return source.CollectionEnvelope{}, fmt.Errorf("%v: %w", reference, err)
+		}
+
+		statement.PredicateType, statement.Predicate = link.PredicateType, body
+	}
+
+	if statement.PredicateType == disclosure.PredicateType {
+		signed, err := disclosure.Parse(statement.Predicate)
+		if err != nil {
+			return source.CollectionEnvelope{}, fmt.Errorf("%v: %w", reference, err)
+		}
+
+		if signed.PredicateType != attestation.CollectionType {
+			return source.CollectionEnvelope{}, fmt.Errorf("%v: disclosable predicate is a %v, not a collection", reference, signed.PredicateType)
+		}
+
+		body, err := bundles.Resolve(signed)
+		if err != nil {
+			return source.CollectionEnvelope{}, fmt.Errorf("%v: %w", reference, err)
+		}
+
+		statement.PredicateType, statement.Predicate = signed.PredicateType, body
+	}
+
+	// older predicate versions are upgraded here rather than in the envelope, so the signatures still verify
+	predicate, migrated, err := migrate.Collection(statement.Predicate)
+	if err != nil {
+		return source.CollectionEnvelope{}, fmt.Errorf("failed to migrate collection %v: %w", reference, err)
+	}
+
+	if migrated {
+		log.Debugf("(migrate) upgraded attestations in %v", reference)
+		statement.Predicate = predicate
+	}
+
+	collection := attestation.Collection{}
+	if err := json.Unmarshal(statement.Predicate, &collection); err != nil {
+		return source.CollectionEnvelope{}, err
+	}
+
+	return source.CollectionEnvelope{
+		Reference:  reference,
+		Envelope:   env,
+		Statement:  statement,
+		Collection: collection,
+	}, nil
+}
+
+// collectionMemorySource behaves like source.MemorySource, but reads envelopes with envelopeToCollectionEnvelope
+// so attestations recorded with older predicate versions are migrated before they are indexed
+type collectionMemorySource struct {
+	envelopesByReference       map[string]source.CollectionEnvelope
+	referencesByCollectionName map[string][]string
+	// predicates are the detached collections of envelopes signed with witness run --detach-predicate
+	predicates *detached.Store
+	// bundles are the disclosure bundles of envelopes signed with witness run --disclosable
+	bundles *disclosure.Store
+}
+
+func newCollectionMemorySource() *collectionMemorySource {
+	return &collectionMemorySource{
+		envelopesByReference:       make(map[string]source.CollectionEnvelope),
+		referencesByCollectionName: make(map[string][]string),
+	}
+}
+
+func (s *collectionMemorySource) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	env := dsse.Envelope{}
+	if err := json.Unmarshal(data, &env); err != nil {
+		return err
+	}
+
+	return s.LoadEnvelope(path, env)
+}
+
+func (s *collectionMemorySource) LoadEnvelope(reference string, env dsse.Envelope) error {
+	if _, ok := s.envelopesByReference[reference]; ok {
+		return source.ErrDuplicateReference(reference)
+	}
+
+	collectionEnv, err := envelopeToCollectionEnvelope(reference, env, s.predicates, s.bundles)
+	if err != nil {
+		return err
+	}
+
+	s.envelopesByReference[reference] = collectionEnv
+	s.referencesByCollectionName[collectionEnv.Collection.Name] = append(s.referencesByCollectionName[collectionEnv.Collection.Name], reference)
+	return nil
+}
+
+func (s *collectionMemorySource) Search(ctx context.Context, collectionName string, subjectDigests, attestations []string) ([]source.CollectionEnvelope, error) {
+	matches := make([]source.CollectionEnvelope, 0)
+	for _, reference := range s.referencesByCollectionName[collectionName] {
+		env := s.envelopesByReference[reference]
+		if hasSubjectDigest(env.Statement, subjectDigests) && hasAttestations(env.Collection, attestations) {
+			matches = append(matches, env)
+		}
+	}
+
+	return matches, nil
+}
+
+// Subjects returns the subjects of every loaded collection
+func (s *collectionMemorySource) Subjects() []intoto.Subject {
+	references := make([]string, 0, len(s.envelopesByReference))
+	for reference := range s.envelopesByReference {
+		references = append(references, reference)
+	}
+
+	sort.Strings(references)
+	subjects := []intoto.Subject{}
+	for _, reference := range references {
+		subjects = append(subjects, s.envelopesByReference[reference].Statement.Subject...)
+	}
+
+	return subjects
+}
+
+// hasSubjectDigest reports whether at least one of the digests is a subject of the statement
+func hasSubjectDigest(statement intoto.Statement, digests []string) bool {
+	for _, subject := range statement.Subject {
+		for _, subjectDigest := range subject.Digest {
+			for _, digest := range digests {
+				if subjectDigest == digest {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// hasAttestations reports whether every one of the attestation types appears in the collection
+func hasAttestations(collection attestation.Collection, attestationTypes []string) bool {
+	found := make(map[string]struct{})
+	for _, att := range collection.Attestations {
+		found[att.Attestation.Type()] = struct{}{}
+	}
+
+	for _, attestationType := range attestationTypes {
+		if _, ok := found[attestationType]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,52 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/attestation/environment"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+	"github.com/testifysec/witness/pkg/migrate"
+)
+
+func TestCollectionMemorySourceMigrates(t *testing.T) {
+	const oldType = "https://witness.dev/attestations/environment/v0.0"
+	migrate.Register(migrate.Migration{From: oldType, To: environment.Type, Migrate: migrate.RenameType()})
+	defer migrate.Unregister(oldType)
+
+	statement := []byte(`{"_type":"https://in-toto.io/Statement/v0.1","subject":[{"name":"file:out","digest":{"sha256":"abc"}}],` +
+		`"predicateType":"https://witness.testifysec.com/attestation-collection/v0.1",` +
+		`"predicate":{"name":"build","attestations":[{"type":"` + oldType + `","attestation":{"os":"linux"}}]}}`)
+	env := dsse.Envelope{Payload: statement, PayloadType: intoto.PayloadType}
+
+	memSource := newCollectionMemorySource()
+	require.NoError(t, memSource.LoadEnvelope("old", env))
+	assert.Error(t, memSource.LoadEnvelope("old", env))
+
+	found, err := memSource.Search(context.Background(), "build", []string{"abc"}, []string{environment.Type})
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, environment.Type, found[0].Collection.Attestations[0].Type)
+	assert.Equal(t, statement, found[0].Envelope.Payload)
+
+	found, err = memSource.Search(context.Background(), "build", []string{"def"}, []string{environment.Type})
+	require.NoError(t, err)
+	assert.Empty(t, found)
+}
@@ -0,0 +1,206 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/localstore"
+)
+
+func StoreCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "store",
+		Short: "Manages the local attestation store",
+		Long: "Manages the local attestation store, where envelopes are saved by gitoid with an index of their subject " +
+			"digests. witness run --local-store saves envelopes in it and witness verify --local-store searches it",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+	}
+
+	cmd.AddCommand(StoreListCmd())
+	cmd.AddCommand(StoreGetCmd())
+	cmd.AddCommand(StoreAddCmd())
+	return cmd
+}
+
+func StoreListCmd() *cobra.Command {
+	so := options.StoreListOptions{}
+	cmd := &cobra.Command{
+		Use:               "ls",
+		Short:             "Lists the envelopes in the local attestation store",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runStoreList(cmd.OutOrStdout(), so)
+		},
+		Args: cobra.NoArgs,
+	}
+
+	so.AddFlags(cmd)
+	return cmd
+}
+
+func StoreGetCmd() *cobra.Command {
+	so := options.StoreGetOptions{}
+	cmd := &cobra.Command{
+		Use:               "get [gitoid]",
+		Short:             "Writes an envelope from the local attestation store",
+		Long:              "Writes the envelope with the gitoid from the local attestation store. The gitoid may be abbreviated to a unique prefix",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runStoreGet(cmd.OutOrStdout(), so, args[0])
+		},
+		Args: cobra.ExactArgs(1),
+	}
+
+	so.AddFlags(cmd)
+	return cmd
+}
+
+func StoreAddCmd() *cobra.Command {
+	so := options.StoreAddOptions{}
+	cmd := &cobra.Command{
+		Use:               "add [envelope files]",
+		Short:             "Saves signed envelopes in the local attestation store",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runStoreAdd(cmd.OutOrStdout(), so, args)
+		},
+		Args: cobra.MinimumNArgs(1),
+	}
+
+	so.AddFlags(cmd)
+	return cmd
+}
+
+func runStoreList(out io.Writer, so options.StoreListOptions) error {
+	if so.Output != "text" && so.Output != "json" {
+		return fmt.Errorf("unknown output format %v, must be text or json", so.Output)
+	}
+
+	store, err := localstore.Open(so.StoreDirOptions.Dir)
+	if err != nil {
+		return err
+	}
+
+	entries, err := store.Entries()
+	if err != nil {
+		return err
+	}
+
+	if so.Subject != "" {
+		digestSet, err := parseSubjectDigest(so.Subject)
+		if err != nil {
+			return err
+		}
+
+		matches := make(map[string]struct{})
+		for _, digest := range digestSet {
+			gitoids, err := store.Search(digest)
+			if err != nil {
+				return err
+			}
+
+			for _, gitoid := range gitoids {
+				matches[gitoid] = struct{}{}
+			}
+		}
+
+		filtered := []localstore.Entry{}
+		for _, entry := range entries {
+			if _, ok := matches[entry.Gitoid]; ok {
+				filtered = append(filtered, entry)
+			}
+		}
+
+		entries = filtered
+	}
+
+	if so.Output == "json" {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "GITOID\tSTEP\tSTORED\tSUBJECTS")
+	for _, entry := range entries {
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%d\n", entry.Gitoid, entry.Name, entry.StoredAt.Format("2006-01-02T15:04:05Z"), len(entry.Subjects))
+	}
+
+	return tw.Flush()
+}
+
+func runStoreGet(out io.Writer, so options.StoreGetOptions, gitoid string) error {
+	store, err := localstore.Open(so.StoreDirOptions.Dir)
+	if err != nil {
+		return err
+	}
+
+	env, err := store.Get(gitoid)
+	if err != nil {
+		return err
+	}
+
+	if so.OutFilePath == "" {
+		return json.NewEncoder(out).Encode(env)
+	}
+
+	return writeEnvelope(so.OutFilePath, env)
+}
+
+func runStoreAdd(out io.Writer, so options.StoreAddOptions, paths []string) error {
+	store, err := localstore.Open(so.StoreDirOptions.Dir)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		envBytes, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		env := dsse.Envelope{}
+		if err := json.Unmarshal(envBytes, &env); err != nil {
+			return fmt.Errorf("failed to parse envelope %v: %w", path, err)
+		}
+
+		gitoid, err := store.Put(env)
+		if err != nil {
+			return fmt.Errorf("failed to save %v: %w", path, err)
+		}
+
+		log.Debugf("(store) saved %v in %v", path, store.Dir())
+		fmt.Fprintf(out, "%v\t%v\n", gitoid, path)
+	}
+
+	return nil
+}
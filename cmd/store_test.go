@@ -0,0 +1,89 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/localstore"
+)
+
+func TestLocalStore(t *testing.T) {
+	f := newVerifyFixture(t)
+	step1Digest, err := cryptoutil.CalculateDigestSetFromBytes([]byte("test01\n"), []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+	storeDir := t.TempDir()
+	localStore := options.LocalStoreOptions{Enable: true, StoreDirOptions: options.StoreDirOptions{Dir: storeDir}}
+	for _, step := range []struct{ name, script string }{
+		{"step01", "echo 'test01' > test.txt"},
+		{"step02", "echo 'test02' >> test.txt"},
+	} {
+		require.NoError(t, runRun(context.Background(), options.RunOptions{
+			KeyOptions:        options.KeyOptions{KeyPath: f.funcPrivPath},
+			LocalStoreOptions: localStore,
+			WorkingDir:        f.workingDir,
+			OutFilePath:       filepath.Join(t.TempDir(), step.name+".json"),
+			StepName:          step.name,
+		}, []string{"bash", "-c", step.script}))
+	}
+
+	vo := f.verifyOptions(f.policyPubPath)
+	vo.AdditionalSubjects = []string{step1Digest[crypto.SHA256]}
+	require.Error(t, runVerify(context.Background(), vo))
+	vo.LocalStoreOptions = localStore
+	require.NoError(t, runVerify(context.Background(), vo))
+
+	artifactDigest, err := cryptoutil.CalculateDigestSetFromFile(f.artifactPath, []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+	out := bytes.Buffer{}
+	require.NoError(t, runStoreList(&out, options.StoreListOptions{
+		StoreDirOptions: localStore.StoreDirOptions,
+		Subject:         "sha256:" + artifactDigest[crypto.SHA256],
+		Output:          "json",
+	}))
+
+	entries := []localstore.Entry{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &entries))
+	require.Len(t, entries, 1)
+	assert.Equal(t, "step02", entries[0].Name)
+
+	envPath := filepath.Join(t.TempDir(), "step02.json")
+	require.NoError(t, runStoreGet(nil, options.StoreGetOptions{StoreDirOptions: localStore.StoreDirOptions, OutFilePath: envPath}, entries[0].Gitoid[:12]))
+	envBytes, err := os.ReadFile(envPath)
+	require.NoError(t, err)
+	env := dsse.Envelope{}
+	require.NoError(t, json.Unmarshal(envBytes, &env))
+
+	otherStore := options.StoreDirOptions{Dir: t.TempDir()}
+	out.Reset()
+	require.NoError(t, runStoreAdd(&out, options.StoreAddOptions{StoreDirOptions: otherStore}, []string{envPath}))
+	assert.Contains(t, out.String(), entries[0].Gitoid)
+
+	out.Reset()
+	require.NoError(t, runStoreList(&out, options.StoreListOptions{StoreDirOptions: otherStore, Output: "text"}))
+	assert.Contains(t, out.String(), entries[0].Gitoid)
+	assert.Contains(t, out.String(), "step02")
+}
@@ -0,0 +1,205 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// templateData is what flag templates are executed against. Git information is only looked up when a
+// template refers to it, so commands run outside of a repository don't fail unless they need it.
+type templateData struct {
+	Env    map[string]string
+	dir    string
+	git    *gitTemplateData
+	gitErr error
+}
+
+type gitTemplateData struct {
+	Branch      string
+	Commit      string
+	ShortCommit string
+	Tag         string
+}
+
+func newTemplateData(dir string) *templateData {
+	env := map[string]string{}
+	for _, kv := range os.Environ() {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			env[k] = v
+		}
+	}
+
+	return &templateData{Env: env, dir: dir}
+}
+
+// Git returns the branch, commit, and tag checked out in the working directory. Branch is empty when HEAD
+// is detached, and Tag is empty when no tag points at HEAD.
+func (d *templateData) Git() (gitTemplateData, error) {
+	if d.git == nil && d.gitErr == nil {
+		d.git, d.gitErr = loadGitTemplateData(d.dir)
+	}
+
+	if d.gitErr != nil {
+		return gitTemplateData{}, d.gitErr
+	}
+
+	return *d.git, nil
+}
+
+func loadGitTemplateData(dir string) (*gitTemplateData, error) {
+	if dir == "" {
+		dir = "."
+	}
+
+	repo, err := git.PlainOpenWithOptions(dir, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open git repository: %w", err)
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get git HEAD: %w", err)
+	}
+
+	data := &gitTemplateData{Commit: head.Hash().String()}
+	data.ShortCommit = data.Commit[:7]
+	if head.Name().IsBranch() {
+		data.Branch = head.Name().Short()
+	}
+
+	tags, err := repo.Tags()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list git tags: %w", err)
+	}
+
+	err = tags.ForEach(func(ref *plumbing.Reference) error {
+		target := ref.Hash()
+		if tag, err := repo.TagObject(target); err == nil {
+			target = tag.Target
+		} else if err != plumbing.ErrObjectNotFound {
+			return err
+		}
+
+		if target == head.Hash() {
+			data.Tag = ref.Name().Short()
+			return storer.ErrStop
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read git tags: %w", err)
+	}
+
+	return data, nil
+}
+
+// expandTemplate executes value as a template if it contains one. Referring to a missing field is an error,
+// so a typo doesn't silently become part of a step name.
+func expandTemplate(value string, data *templateData) (string, error) {
+	if !strings.Contains(value, "{{") {
+		return value, nil
+	}
+
+	tmpl, err := template.New("").Option("missingkey=error").Parse(value)
+	if err != nil {
+		return "", err
+	}
+
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, data); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// expandFlagTemplates expands templates in the string flags of the command being run, after they've been
+// set from the command line, environment, and config file. The working directory is expanded first so
+// git information is read from the right repository.
+func expandFlagTemplates(cmd *cobra.Command) error {
+	flags := cmd.Flags()
+	data := newTemplateData("")
+	if wd := flags.Lookup("workingdir"); wd != nil {
+		if err := expandFlagTemplate(wd, data); err != nil {
+			return err
+		}
+
+		data.dir = wd.Value.String()
+	}
+
+	var err error
+	flags.VisitAll(func(f *pflag.Flag) {
+		if err == nil && f.Name != "workingdir" {
+			err = expandFlagTemplate(f, data)
+		}
+	})
+
+	return err
+}
+
+func expandFlagTemplate(f *pflag.Flag, data *templateData) error {
+	switch f.Value.Type() {
+	case "string":
+		value := f.Value.String()
+		expanded, err := expandTemplate(value, data)
+		if err != nil {
+			return fmt.Errorf("invalid template in --%v: %w", f.Name, err)
+		}
+
+		if expanded == value {
+			return nil
+		}
+
+		return f.Value.Set(expanded)
+	case "stringSlice", "stringArray":
+		sv, ok := f.Value.(pflag.SliceValue)
+		if !ok {
+			return nil
+		}
+
+		values := sv.GetSlice()
+		changed := false
+		for i := range values {
+			expanded, err := expandTemplate(values[i], data)
+			if err != nil {
+				return fmt.Errorf("invalid template in --%v: %w", f.Name, err)
+			}
+
+			changed = changed || expanded != values[i]
+			values[i] = expanded
+		}
+
+		if !changed {
+			return nil
+		}
+
+		return sv.Replace(values)
+	}
+
+	return nil
+}
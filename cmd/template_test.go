@@ -0,0 +1,69 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandFlagTemplates(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	require.NoError(t, err)
+	worktree, err := repo.Worktree()
+	require.NoError(t, err)
+	commit, err := worktree.Commit("initial", &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+
+	require.NoError(t, err)
+	_, err = repo.CreateTag("v1.0.0", commit, &git.CreateTagOptions{
+		Tagger:  &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		Message: "v1.0.0",
+	})
+
+	require.NoError(t, err)
+
+	t.Setenv("GITHUB_JOB", "build")
+	root := New()
+	run, _, err := root.Find([]string{"run"})
+	require.NoError(t, err)
+	require.NoError(t, run.Flags().Set("workingdir", dir))
+	require.NoError(t, run.Flags().Set("step", "{{.Env.GITHUB_JOB}}-{{.Git.Branch}}"))
+	require.NoError(t, run.Flags().Set("dir-subjects", "dist/{{.Git.Tag}},out"))
+	require.NoError(t, run.Flags().Set("outfile", "{{.Git.ShortCommit}}.json"))
+	require.NoError(t, expandFlagTemplates(run))
+
+	step, err := run.Flags().GetString("step")
+	require.NoError(t, err)
+	assert.Equal(t, "build-master", step)
+	dirSubjects, err := run.Flags().GetStringSlice("dir-subjects")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dist/v1.0.0", "out"}, dirSubjects)
+	outfile, err := run.Flags().GetString("outfile")
+	require.NoError(t, err)
+	assert.Equal(t, commit.String()[:7]+".json", outfile)
+
+	require.NoError(t, run.Flags().Set("step", "{{.Env.NOT_SET_ANYWHERE}}"))
+	assert.Error(t, expandFlagTemplates(run))
+	require.NoError(t, run.Flags().Set("step", `{{index .Env "NOT_SET_ANYWHERE"}}`))
+	assert.NoError(t, expandFlagTemplates(run))
+}
@@ -0,0 +1,84 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/witness/attestation/terraform"
+	"github.com/testifysec/witness/options"
+)
+
+const testTerraformUIPlan = `{"@level":"info","@message":"Terraform 1.5.7","@module":"terraform.ui","type":"version","terraform":"1.5.7","ui":"1.1"}
+{"@level":"info","@message":"aws_s3_bucket.logs: Plan to create","@module":"terraform.ui","type":"planned_change","change":{"resource":{"addr":"aws_s3_bucket.logs","resource_type":"aws_s3_bucket"},"action":"create"}}
+{"@level":"info","@message":"aws_instance.web: Plan to replace","@module":"terraform.ui","type":"planned_change","change":{"resource":{"addr":"aws_instance.web","resource_type":"aws_instance"},"action":"replace"}}
+{"@level":"info","@message":"Plan: 2 to add, 0 to change, 1 to destroy.","@module":"terraform.ui","type":"change_summary","changes":{"add":2,"change":0,"remove":1,"operation":"plan"}}
+`
+
+const testTerraformJSONPlan = `{"format_version":"1.2","terraform_version":"1.5.7","resource_changes":[
+{"address":"aws_s3_bucket.logs","type":"aws_s3_bucket","change":{"actions":["create"]}},
+{"address":"aws_instance.web","type":"aws_instance","change":{"actions":["delete","create"]}},
+{"address":"aws_iam_role.ci","type":"aws_iam_role","change":{"actions":["no-op"]}}]}`
+
+func TestRunTerraformPlanAttestations(t *testing.T) {
+	built := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(built, "plan.jsonl"), []byte(testTerraformUIPlan), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(built, "plan.json"), []byte(testTerraformJSONPlan), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(built, "other.json"), []byte(`{"name": "not a plan"}`), 0644))
+	writeZip(t, filepath.Join(built, "tfplan"), map[string]string{"tfplan": "plan", "tfstate": "state"})
+
+	priv, _ := rsakeypair(t)
+	attestationPath := filepath.Join(t.TempDir(), "outfile.txt")
+	runOptions := options.RunOptions{
+		KeyOptions:   options.KeyOptions{KeyPath: priv.Name()},
+		WorkingDir:   t.TempDir(),
+		Attestations: []string{terraform.Name},
+		OutFilePath:  attestationPath,
+		StepName:     "plan",
+	}
+
+	require.NoError(t, runRun(context.Background(), runOptions, []string{"bash", "-c", "cp " + built + "/* ."}))
+	stmt, collection := readCollection(t, attestationPath)
+	attestor := findAttestor[*terraform.Attestor](collection)
+	require.NotNil(t, attestor)
+	require.Len(t, attestor.Plans, 2)
+	expectedChanges := []terraform.ResourceChange{
+		{Address: "aws_s3_bucket.logs", Type: "aws_s3_bucket", Action: terraform.ActionCreate},
+		{Address: "aws_instance.web", Type: "aws_instance", Action: terraform.ActionReplace},
+	}
+
+	for _, plan := range attestor.Plans {
+		assert.Equal(t, "1.5.7", plan.TerraformVersion)
+		assert.Equal(t, terraform.Summary{Add: 2, Remove: 1}, plan.Summary, plan.File)
+		assert.Equal(t, expectedChanges, plan.Changes, plan.File)
+	}
+
+	assert.Equal(t, terraform.FormatJSON, attestor.Plans[0].Format)
+	assert.Equal(t, terraform.FormatUI, attestor.Plans[1].Format)
+	require.Len(t, attestor.PlanFiles, 1)
+	assert.Equal(t, "tfplan", attestor.PlanFiles[0].File)
+
+	subjects := []string{}
+	for _, subject := range stmt.Subject {
+		subjects = append(subjects, subject.Name)
+	}
+
+	assert.Contains(t, subjects, terraform.Type+"/tfplan:tfplan")
+}
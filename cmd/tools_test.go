@@ -0,0 +1,74 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/witness/attestation/tools"
+	"github.com/testifysec/witness/options"
+)
+
+func TestRunToolsAttestation(t *testing.T) {
+	pinned, shadowed := t.TempDir(), t.TempDir()
+	for _, dir := range []string{pinned, shadowed} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "witness-test-cc"), []byte("#!/bin/sh\necho "+dir+"\n"), 0755))
+	}
+
+	pinnedDigest, err := cryptoutil.CalculateDigestSetFromFile(filepath.Join(pinned, "witness-test-cc"), []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+	t.Setenv("PATH", pinned+string(os.PathListSeparator)+shadowed+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	priv, _ := rsakeypair(t)
+	attestationPath := filepath.Join(t.TempDir(), "outfile.txt")
+	runOptions := options.RunOptions{
+		KeyOptions:   options.KeyOptions{KeyPath: priv.Name()},
+		WorkingDir:   t.TempDir(),
+		Attestations: []string{"tools"},
+		OutFilePath:  attestationPath,
+		StepName:     "build",
+	}
+
+	require.NoError(t, runRun(context.Background(), runOptions, []string{"witness-test-cc"}))
+	_, collection := readCollection(t, attestationPath)
+	attestor := findAttestor[*tools.Attestor](collection)
+	require.NotNil(t, attestor)
+	require.NotNil(t, attestor.Command)
+	assert.Equal(t, "witness-test-cc", attestor.Command.Name)
+	assert.Equal(t, filepath.Join(pinned, "witness-test-cc"), attestor.Command.Path)
+	assert.Equal(t, pinned, attestor.Command.PathEntry)
+	assert.Equal(t, pinnedDigest, attestor.Command.Digest)
+	assert.Equal(t, []string{filepath.Join(shadowed, "witness-test-cc")}, attestor.Command.Shadowed)
+	assert.Equal(t, pinned, attestor.SearchPath[0])
+	assert.Equal(t, pinnedDigest, collection.Materials()["tool:"+filepath.Join(pinned, "witness-test-cc")])
+
+	// the command is resolved in the PATH it runs with, not witness'
+	runOptions.Env = []string{"PATH=" + shadowed}
+	attestor = runAndGetAttestor[*tools.Attestor](t, runOptions, []string{"witness-test-cc"})
+	assert.Equal(t, filepath.Join(shadowed, "witness-test-cc"), attestor.Command.Path)
+	assert.Empty(t, attestor.Command.Shadowed)
+	assert.Equal(t, []string{shadowed}, attestor.SearchPath)
+
+	runOptions.Env = nil
+	runOptions.ToolsTraced = true
+	require.Error(t, runRun(context.Background(), runOptions, []string{"witness-test-cc"}))
+}
@@ -0,0 +1,75 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/attestation/commandrun"
+	"github.com/testifysec/witness/attestation/network"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/ebpftrace"
+)
+
+func TestRunTraceBackend(t *testing.T) {
+	priv, _ := rsakeypair(t)
+	workingDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(workingDir, "input.txt"), []byte("input\n"), 0644))
+	attestationPath := filepath.Join(t.TempDir(), "outfile.txt")
+	runOptions := options.RunOptions{
+		KeyOptions:   options.KeyOptions{KeyPath: priv.Name()},
+		WorkingDir:   workingDir,
+		Attestations: []string{},
+		OutFilePath:  attestationPath,
+		StepName:     "build",
+		TraceBackend: "dtrace",
+		Tracing:      true,
+	}
+
+	args := []string{"sh", "-c", "cat input.txt"}
+	require.ErrorContains(t, runRun(context.Background(), runOptions, args), "unknown trace backend")
+	runOptions.TraceBackend, runOptions.Tracing = "ebpf", false
+	require.ErrorContains(t, runRun(context.Background(), runOptions, args), "requires --trace")
+
+	tracer, err := ebpftrace.New()
+	if err != nil {
+		t.Skipf("eBPF tracing is unavailable: %v", err)
+	}
+
+	tracer.Close()
+	runOptions.Tracing = true
+	require.NoError(t, runRun(context.Background(), runOptions, args))
+	_, collection := readCollection(t, attestationPath)
+	command := findAttestor[*commandrun.CommandRun](collection)
+	connections := findAttestor[*network.Attestor](collection)
+
+	require.NotNil(t, command)
+	require.NotNil(t, connections, "the network attestor is recorded with the ebpf backend")
+	var cat *commandrun.ProcessInfo
+	for i, p := range command.Processes {
+		if filepath.Base(p.Program) == "cat" {
+			cat = &command.Processes[i]
+		}
+	}
+
+	require.NotNil(t, cat)
+	assert.Equal(t, "cat input.txt", cat.Cmdline)
+	assert.Contains(t, cat.OpenedFiles, filepath.Join(workingDir, "input.txt"))
+}
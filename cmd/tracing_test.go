@@ -0,0 +1,72 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/witness/attestation/tracing"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/ebpftrace"
+)
+
+func TestRunTracingAttestation(t *testing.T) {
+	priv, _ := rsakeypair(t)
+	workingDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(workingDir, "a.txt"), []byte("a\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(workingDir, "b.txt"), []byte("b\n"), 0644))
+	attestationPath := filepath.Join(t.TempDir(), "outfile.txt")
+	runOptions := options.RunOptions{
+		KeyOptions:      options.KeyOptions{KeyPath: priv.Name()},
+		WorkingDir:      workingDir,
+		Attestations:    []string{},
+		OutFilePath:     attestationPath,
+		StepName:        "build",
+		Tracing:         true,
+		TraceSampleRate: -1,
+	}
+
+	args := []string{"sh", "-c", "cat a.txt b.txt"}
+	require.ErrorContains(t, runRun(context.Background(), runOptions, args), "--trace-sample-rate")
+
+	// the limits are ignored by ptrace, and left out of the attestation
+	runOptions.TraceSampleRate, runOptions.TraceMaxFiles = 1, 1
+	attestor := runAndGetAttestor[*tracing.Attestor](t, runOptions, args)
+	assert.Equal(t, "ptrace", attestor.Backend)
+	assert.Zero(t, attestor.MaxFiles)
+	assert.False(t, attestor.FileLimitReached)
+	assert.GreaterOrEqual(t, attestor.FilesRecorded, 2)
+	assert.Positive(t, attestor.Overhead.CommandDuration)
+	assert.Positive(t, attestor.Overhead.TracerCPUTime)
+
+	tracer, err := ebpftrace.New()
+	if err != nil {
+		t.Skipf("eBPF tracing is unavailable: %v", err)
+	}
+
+	tracer.Close()
+	runOptions.TraceBackend = "ebpf"
+	attestor = runAndGetAttestor[*tracing.Attestor](t, runOptions, args)
+	assert.Equal(t, "ebpf", attestor.Backend)
+	assert.Equal(t, 1, attestor.MaxFiles)
+	assert.Equal(t, 1, attestor.FilesRecorded)
+	assert.True(t, attestor.FileLimitReached)
+	assert.Positive(t, attestor.Events)
+}
@@ -0,0 +1,307 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/testifysec/go-witness/archivist"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/transport"
+)
+
+func UploadCmd() *cobra.Command {
+	uo := options.UploadOptions{}
+	cmd := &cobra.Command{
+		Use:   "upload [envelope files or directories]",
+		Short: "Uploads signed envelopes to Archivist",
+		Long: "Uploads signed envelopes to Archivist. Directories are walked for .json envelope files, so existing " +
+			"evidence can be migrated in bulk. Progress is recorded in a state file so an interrupted upload can be " +
+			"resumed with --resume, retrying only the envelopes that were not stored",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runUpload(cmd.Context(), uo, args)
+		},
+		Args: cobra.ArbitraryArgs,
+	}
+
+	uo.AddFlags(cmd)
+	return cmd
+}
+
+// uploadState records which envelopes of an upload have been stored in Archivist
+type uploadState struct {
+	ArchivistServer string           `json:"archivistserver"`
+	Envelopes       []envelopeUpload `json:"envelopes"`
+}
+
+type envelopeUpload struct {
+	Path   string `json:"path"`
+	Sha256 string `json:"sha256"`
+	Gitoid string `json:"gitoid,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+func runUpload(ctx context.Context, uo options.UploadOptions, args []string) error {
+	state := uploadState{}
+	statePath := uo.StateFilePath
+	if uo.ResumePath != "" {
+		if len(args) > 0 {
+			return fmt.Errorf("envelope files cannot be provided when resuming an upload")
+		}
+
+		stateBytes, err := os.ReadFile(uo.ResumePath)
+		if err != nil {
+			return fmt.Errorf("failed to read upload state: %w", err)
+		}
+
+		if err := json.Unmarshal(stateBytes, &state); err != nil {
+			return fmt.Errorf("failed to parse upload state: %w", err)
+		}
+
+		statePath = uo.ResumePath
+	} else {
+		if len(args) == 0 {
+			return fmt.Errorf("no envelope files provided")
+		}
+
+		paths, err := expandUploadPaths(args)
+		if err != nil {
+			return err
+		}
+
+		state.ArchivistServer = uo.ArchivistServer
+		for _, path := range paths {
+			state.Envelopes = append(state.Envelopes, envelopeUpload{Path: path})
+		}
+	}
+
+	server, err := transport.ResolveURL(state.ArchivistServer)
+	if err != nil {
+		return err
+	}
+
+	concurrency := uo.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	client := archivist.New(server)
+	failed := 0
+	var mu sync.Mutex
+	var stateErr error
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				upload := state.Envelopes[i]
+				gitoid, err := uploadEnvelope(ctx, client, &upload, uo.Retries)
+
+				// uploads finish out of order, so the state is only changed and written under the lock
+				mu.Lock()
+				if err != nil {
+					failed++
+					upload.Error = err.Error()
+					log.Errorf("failed to upload %v: %v", upload.Path, err)
+				} else {
+					upload.Gitoid = gitoid
+					upload.Error = ""
+					log.Infof("Stored %v in archivist as %v", upload.Path, gitoid)
+				}
+
+				state.Envelopes[i] = upload
+				if err := writeUploadState(statePath, state); err != nil && stateErr == nil {
+					stateErr = err
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for i, upload := range state.Envelopes {
+		if upload.Gitoid != "" {
+			log.Debugf("(upload) %v already stored as %v", upload.Path, upload.Gitoid)
+			continue
+		}
+
+		jobs <- i
+	}
+
+	close(jobs)
+	wg.Wait()
+	if stateErr != nil {
+		return stateErr
+	}
+
+	if err := writeUploadManifest(uo.ManifestPath, state); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		if statePath != "" {
+			return fmt.Errorf("failed to upload %d of %d envelopes, resume with --resume %v", failed, len(state.Envelopes), statePath)
+		}
+
+		return fmt.Errorf("failed to upload %d of %d envelopes", failed, len(state.Envelopes))
+	}
+
+	return nil
+}
+
+// expandUploadPaths replaces each directory in paths with the .json files under it
+func expandUploadPaths(paths []string) ([]string, error) {
+	expanded := []string{}
+	seen := make(map[string]struct{})
+	add := func(path string) {
+		if _, ok := seen[path]; !ok {
+			seen[path] = struct{}{}
+			expanded = append(expanded, path)
+		}
+	}
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			add(path)
+			continue
+		}
+
+		err = filepath.WalkDir(path, func(walked string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if d.Type().IsRegular() && strings.HasSuffix(d.Name(), ".json") {
+				add(walked)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to walk %v: %w", path, err)
+		}
+	}
+
+	if len(expanded) == 0 {
+		return nil, fmt.Errorf("no envelope files found")
+	}
+
+	return expanded, nil
+}
+
+// writeUploadManifest writes the gitoid and path of each stored envelope to path, one per line
+func writeUploadManifest(path string, state uploadState) error {
+	if path == "" {
+		return nil
+	}
+
+	manifest := strings.Builder{}
+	for _, upload := range state.Envelopes {
+		if upload.Gitoid != "" {
+			fmt.Fprintf(&manifest, "%v  %v\n", upload.Gitoid, upload.Path)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte(manifest.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write upload manifest: %w", err)
+	}
+
+	return nil
+}
+
+// uploadEnvelope stores a single envelope, retrying with a backoff. The envelope's digest is recorded the first
+// time it is read so a resumed upload fails rather than storing a file that changed since the upload began.
+func uploadEnvelope(ctx context.Context, client *archivist.Client, upload *envelopeUpload, retries int) (string, error) {
+	envBytes, err := os.ReadFile(upload.Path)
+	if err != nil {
+		return "", err
+	}
+
+	digest, err := cryptoutil.CalculateDigestSetFromBytes(envBytes, []crypto.Hash{crypto.SHA256})
+	if err != nil {
+		return "", err
+	}
+
+	if upload.Sha256 == "" {
+		upload.Sha256 = digest[crypto.SHA256]
+	} else if upload.Sha256 != digest[crypto.SHA256] {
+		return "", fmt.Errorf("envelope changed since the upload began")
+	}
+
+	env := dsse.Envelope{}
+	if err := json.Unmarshal(envBytes, &env); err != nil {
+		return "", fmt.Errorf("failed to parse envelope: %w", err)
+	}
+
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		gitoid, err := client.Store(ctx, env)
+		if err == nil {
+			return gitoid, nil
+		}
+
+		if attempt >= retries {
+			return "", err
+		}
+
+		log.Debugf("(upload) attempt %d for %v failed, retrying in %v: %v", attempt+1, upload.Path, backoff, err)
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+	}
+}
+
+func writeUploadState(path string, state uploadState) error {
+	if path == "" {
+		return nil
+	}
+
+	stateBytes, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(path, stateBytes, 0600); err != nil {
+		return fmt.Errorf("failed to write upload state: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/witness/options"
+)
+
+func TestUploadResume(t *testing.T) {
+	available := false
+	stored := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		env := dsse.Envelope{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&env))
+		if string(env.Payload) == "flaky" && !available {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		stored = append(stored, string(env.Payload))
+		fmt.Fprintf(w, `{"gitoid": "gitoid-%v"}`, string(env.Payload))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	paths := []string{}
+	for _, payload := range []string{"first", "flaky", "last"} {
+		envBytes, err := json.Marshal(dsse.Envelope{Payload: []byte(payload), PayloadType: "test"})
+		require.NoError(t, err)
+		path := filepath.Join(dir, payload+".json")
+		require.NoError(t, os.WriteFile(path, envBytes, 0644))
+		paths = append(paths, path)
+	}
+
+	statePath := filepath.Join(dir, "upload-state.json")
+	uo := options.UploadOptions{ArchivistServer: server.URL, StateFilePath: statePath}
+	require.Error(t, runUpload(context.Background(), uo, paths))
+	assert.Equal(t, []string{"first", "last"}, stored)
+
+	available = true
+	require.NoError(t, runUpload(context.Background(), options.UploadOptions{ResumePath: statePath}, nil))
+	assert.Equal(t, []string{"first", "last", "flaky"}, stored)
+
+	state := uploadState{}
+	stateBytes, err := os.ReadFile(statePath)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(stateBytes, &state))
+	for _, upload := range state.Envelopes {
+		assert.NotEmpty(t, upload.Gitoid)
+		assert.Empty(t, upload.Error)
+	}
+
+	require.NoError(t, os.WriteFile(paths[0], []byte(`{"payload": "Y2hhbmdlZA=="}`), 0644))
+	state.Envelopes[0].Gitoid = ""
+	stateBytes, err = json.Marshal(state)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(statePath, stateBytes, 0644))
+	require.Error(t, runUpload(context.Background(), options.UploadOptions{ResumePath: statePath}, nil))
+}
+
+func TestUploadDirectory(t *testing.T) {
+	mu := sync.Mutex{}
+	stored := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		env := dsse.Envelope{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&env))
+		mu.Lock()
+		stored = append(stored, string(env.Payload))
+		mu.Unlock()
+		fmt.Fprintf(w, `{"gitoid": "gitoid-%v"}`, string(env.Payload))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "2023", "build"), 0755))
+	payloads := []string{"a", "b", "c", "d", "e"}
+	for i, payload := range payloads {
+		envBytes, err := json.Marshal(dsse.Envelope{Payload: []byte(payload), PayloadType: "test"})
+		require.NoError(t, err)
+		subdir := dir
+		if i%2 == 0 {
+			subdir = filepath.Join(dir, "2023", "build")
+		}
+
+		require.NoError(t, os.WriteFile(filepath.Join(subdir, payload+".json"), envBytes, 0644))
+	}
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("not an envelope"), 0644))
+	manifestPath := filepath.Join(t.TempDir(), "manifest.txt")
+	uo := options.UploadOptions{ArchivistServer: server.URL, Concurrency: 3, ManifestPath: manifestPath}
+	require.NoError(t, runUpload(context.Background(), uo, []string{dir}))
+	assert.ElementsMatch(t, payloads, stored)
+
+	manifest, err := os.ReadFile(manifestPath)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(manifest)), "\n")
+	require.Len(t, lines, len(payloads))
+	assert.Contains(t, string(manifest), "gitoid-a  "+filepath.Join(dir, "2023", "build", "a.json"))
+
+	_, err = expandUploadPaths([]string{t.TempDir()})
+	assert.Error(t, err)
+}
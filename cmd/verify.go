@@ -18,25 +18,58 @@ import (
 	"context"
 	"crypto"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/testifysec/go-witness"
-	"github.com/testifysec/go-witness/archivist"
 	"github.com/testifysec/go-witness/cryptoutil"
 	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
 	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/go-witness/policy"
 	"github.com/testifysec/go-witness/source"
 	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/approvals"
+	"github.com/testifysec/witness/pkg/cache"
+	"github.com/testifysec/witness/pkg/cosign"
+	"github.com/testifysec/witness/pkg/detached"
+	"github.com/testifysec/witness/pkg/disclosure"
+	"github.com/testifysec/witness/pkg/fips"
+	"github.com/testifysec/witness/pkg/keywindow"
+	"github.com/testifysec/witness/pkg/localstore"
+	"github.com/testifysec/witness/pkg/notation"
+	"github.com/testifysec/witness/pkg/pqsign"
+	"github.com/testifysec/witness/pkg/purl"
+	"github.com/testifysec/witness/pkg/revocation"
+	"github.com/testifysec/witness/pkg/sct"
+	"github.com/testifysec/witness/pkg/transport"
+	"github.com/testifysec/witness/pkg/waivers"
 )
 
+const verifyLong = `Verifies a policy provided key source and exits with code 0 if verification succeeds.
+
+When verification fails the exit code describes why:
+  1  any other error, such as invalid flags or unreadable files
+  2  a signature didn't verify, on the policy or on every collection for a step
+  3  the collections failed the policy's constraints, or the policy has expired
+  4  no collection was found for a step
+  5  an infrastructure error, such as Archivist or an artifact reference being unreachable`
+
 func VerifyCmd() *cobra.Command {
 	vo := options.VerifyOptions{}
 	cmd := &cobra.Command{
 		Use:               "verify",
 		Short:             "Verifies a witness policy",
-		Long:              "Verifies a policy provided key source and exits with code 0 if verification succeeds",
+		Long:              verifyLong,
 		SilenceErrors:     true,
 		SilenceUsage:      true,
 		DisableAutoGenTag: true,
@@ -45,93 +78,852 @@ func VerifyCmd() *cobra.Command {
 		},
 	}
 	vo.AddFlags(cmd)
+	vo.ReceiptOptions.AddFlags(cmd)
 	return cmd
 }
 
 const (
 	MAX_DEPTH = 4
+
+	verifyOutputTable = "table"
+	verifyOutputJSON  = "json"
+	verifyOutputSARIF = "sarif"
+	verifyOutputJUnit = "junit"
 )
 
+// verifyTarget is a set of subjects that are verified against the policy together, such as a single artifact
+type verifyTarget struct {
+	name     string
+	subjects []cryptoutil.DigestSet
+	evidence []string
+	verified map[string][]source.VerifiedCollection
+	waivers  []waivers.Waiver
+	// steps are how each step of the policy fared, if the policy was evaluated
+	steps []stepResult
+	err   error
+}
+
+// forEachConcurrently calls fn for every index in [0, count) using up to concurrency goroutines.
+// A concurrency less than 1 uses one goroutine per CPU.
+func forEachConcurrently(concurrency, count int, fn func(i int)) {
+	if concurrency < 1 {
+		concurrency = runtime.NumCPU()
+	}
+
+	indexes := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < concurrency && w < count; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+
+	for i := 0; i < count; i++ {
+		indexes <- i
+	}
+
+	close(indexes)
+	wg.Wait()
+}
+
+// writeVerifyResults writes the verification result of each target in the format set by vo.Output and returns the
+// number that failed
+func writeVerifyResults(w io.Writer, vo options.VerifyOptions, targets []verifyTarget) (int, error) {
+	switch vo.Output {
+	case verifyOutputJSON:
+		return writeVerifyReport(w, targets)
+	case verifyOutputSARIF:
+		return writeVerifySARIF(w, vo.PolicyFilePath, targets)
+	case verifyOutputJUnit:
+		return writeVerifyJUnit(w, targets)
+	}
+
+	return printVerifyResults(w, targets), nil
+}
+
+// printVerifyResults writes a table with the verification result of each target and returns the number that failed
+func printVerifyResults(w io.Writer, targets []verifyTarget) int {
+	failed := 0
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ARTIFACT\tRESULT\tDETAILS")
+	for _, target := range targets {
+		if target.err != nil {
+			failed++
+			fmt.Fprintf(tw, "%s\tFAILED\t%v\n", target.name, target.err)
+			continue
+		}
+
+		if len(target.waivers) > 0 {
+			constraints := make([]string, 0, len(target.waivers))
+			for _, w := range target.waivers {
+				constraints = append(constraints, w.Constraint())
+			}
+
+			fmt.Fprintf(tw, "%s\tWAIVED\t%s\n", target.name, strings.Join(constraints, ", "))
+			continue
+		}
+
+		fmt.Fprintf(tw, "%s\tPASSED\t\n", target.name)
+	}
+
+	tw.Flush()
+	return failed
+}
+
+// verifyResult is the result of one target in a JSON verification report
+type verifyResult struct {
+	Name     string   `json:"name"`
+	Subject  string   `json:"subject,omitempty"`
+	Result   string   `json:"result"`
+	Error    string   `json:"error,omitempty"`
+	ExitCode int      `json:"exitCode,omitempty"`
+	Waivers  []string `json:"waivers,omitempty"`
+	Evidence []string `json:"evidence"`
+}
+
+// verifyReport is the JSON verification report of every target
+type verifyReport struct {
+	Passed  int            `json:"passed"`
+	Failed  int            `json:"failed"`
+	Results []verifyResult `json:"results"`
+}
+
+// writeVerifyReport writes the verification result of each target as a JSON report and returns the number that failed
+func writeVerifyReport(w io.Writer, targets []verifyTarget) (int, error) {
+	report := verifyReport{Results: make([]verifyResult, 0, len(targets))}
+	for _, target := range targets {
+		result := verifyResult{Name: target.name, Result: "PASSED", Evidence: target.evidence}
+		if result.Evidence == nil {
+			result.Evidence = []string{}
+		}
+
+		if len(target.subjects) > 0 {
+			if digest, ok := target.subjects[0][crypto.SHA256]; ok {
+				result.Subject = "sha256:" + digest
+			}
+		}
+
+		for _, w := range target.waivers {
+			result.Waivers = append(result.Waivers, w.Constraint())
+		}
+
+		switch {
+		case target.err != nil:
+			result.Result, result.Error, result.ExitCode = "FAILED", target.err.Error(), ExitCode(target.err)
+			report.Failed++
+		case len(target.waivers) > 0:
+			result.Result = "WAIVED"
+			report.Passed++
+		default:
+			report.Passed++
+		}
+
+		report.Results = append(report.Results, result)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return report.Failed, fmt.Errorf("failed to write verification report: %w", err)
+	}
+
+	return report.Failed, nil
+}
+
+func runVerify(ctx context.Context, vo options.VerifyOptions) error {
+	if vo.ReceiptOptions.Path != "" {
+		return acceptVerificationReceipt(ctx, vo)
+	}
+
+	var signers []cryptoutil.Signer
+	if vo.ReceiptOptions.OutPath != "" {
+		// load the signer first so a bad key is reported before the artifacts are verified
+		if vo.ReceiptOptions.KeyPath == "" {
+			return fmt.Errorf("must supply a key to sign the verification receipt with")
+		}
+
+		var err error
+		if signers, err = loadStatementSigners(ctx, options.KeyOptions{KeyPath: vo.ReceiptOptions.KeyPath}); err != nil {
+			return err
+		}
+	}
+
+	targets, err := verifyPolicy(ctx, vo)
+	if err != nil || signers == nil {
+		return err
+	}
+
+	return writeVerificationReceipt(vo, targets, signers)
+}
+
+// verifyPolicy verifies the policy for each artifact and returns the verified targets with the evidence that
+// satisfied the policy.
 // todo: this logic should be broken out and moved to pkg/
 // we need to abstract where keys are coming from, etc
-func runVerify(ctx context.Context, vo options.VerifyOptions) error {
+func verifyPolicy(ctx context.Context, vo options.VerifyOptions) (targets []verifyTarget, err error) {
+	event := notifyEvent{Event: notifyEventVerify}
+	defer func() {
+		notify(ctx, vo.NotifyOptions, event, err)
+	}()
+
 	if vo.KeyPath == "" && len(vo.CAPaths) == 0 {
-		return fmt.Errorf("must suply public key or ca paths")
+		return targets, fmt.Errorf("must suply public key or ca paths")
+	}
+
+	switch vo.Output {
+	case "", verifyOutputTable, verifyOutputJSON, verifyOutputSARIF, verifyOutputJUnit:
+	default:
+		return targets, fmt.Errorf("unknown verify output format %v", vo.Output)
+	}
+
+	decisions, err := newDecisionLog(ctx, vo.DecisionLogOptions)
+	if err != nil {
+		return targets, err
+	}
+
+	if decisions != nil {
+		defer func() {
+			if logErr := decisions.record(ctx, vo, targets, err); logErr != nil && err == nil {
+				err = withExitCode(ExitCodeInfrastructure, logErr)
+			} else if logErr != nil {
+				log.Error(logErr)
+			}
+		}()
+	}
+
+	exporter, err := newComplianceExporter(vo.ExportOptions)
+	if err != nil {
+		return targets, err
+	}
+
+	if exporter != nil {
+		defer func() {
+			if exportErr := exporter.export(vo, targets); exportErr != nil && err == nil {
+				err = exportErr
+			} else if exportErr != nil {
+				log.Error(exportErr)
+			}
+		}()
 	}
 
 	var verifier cryptoutil.Verifier
 	if vo.KeyPath != "" {
 		keyFile, err := os.Open(vo.KeyPath)
 		if err != nil {
-			return fmt.Errorf("failed to open key file: %w", err)
+			return targets, fmt.Errorf("failed to open key file: %w", err)
 		}
 		defer keyFile.Close()
 
 		verifier, err = cryptoutil.NewVerifierFromReader(keyFile)
 		if err != nil {
-			return fmt.Errorf("failed to create verifier: %w", err)
+			return targets, fmt.Errorf("failed to create verifier: %w", err)
 		}
 
+		if ro.FIPS {
+			if err := fips.CheckVerifier(verifier); err != nil {
+				return targets, withExitCode(ExitCodeSignature, fmt.Errorf("policy signer is not FIPS compliant: %w", err))
+			}
+		}
 	}
 
 	inFile, err := os.Open(vo.PolicyFilePath)
 	if err != nil {
-		return fmt.Errorf("failed to open file to sign: %v", err)
+		return targets, fmt.Errorf("failed to open file to sign: %v", err)
 	}
 
 	defer inFile.Close()
 	policyEnvelope := dsse.Envelope{}
 	decoder := json.NewDecoder(inFile)
 	if err := decoder.Decode(&policyEnvelope); err != nil {
-		return fmt.Errorf("could not unmarshal policy envelope: %w", err)
+		return targets, fmt.Errorf("could not unmarshal policy envelope: %w", err)
+	}
+
+	if ro.FIPS {
+		if err := checkPolicyFIPS(policyEnvelope); err != nil {
+			return targets, withExitCode(ExitCodePolicy, err)
+		}
 	}
 
-	artifactDigestSet, err := cryptoutil.CalculateDigestSetFromFile(vo.ArtifactFilePath, []crypto.Hash{crypto.SHA256})
+	pqVerifiers, err := loadPQVerifiers(vo.PQKeyPaths)
 	if err != nil {
-		return fmt.Errorf("failed to calculate artifact digest: %w", err)
+		return targets, err
 	}
 
-	subjects := []cryptoutil.DigestSet{artifactDigestSet}
-	for _, subDigest := range vo.AdditionalSubjects {
-		subjects = append(subjects, cryptoutil.DigestSet{crypto.SHA256: subDigest})
+	if len(pqVerifiers) > 0 {
+		if _, err := policyEnvelope.Verify(dsse.VerifyWithVerifiers(pqVerifiers...)); err != nil {
+			return targets, withExitCode(ExitCodeSignature, fmt.Errorf("policy does not have a post-quantum signature from a trusted key: %w", err))
+		}
+	}
+
+	artifactPaths, err := expandArtifactPaths(vo.ArtifactFilePath, vo.ArtifactListPath, os.Stdin)
+	if err != nil {
+		return targets, err
+	}
+
+	var subjectEntries []subjectEntry
+	if vo.SubjectsFilePath != "" {
+		if vo.SubjectsFilePath == "-" && (vo.ArtifactListPath == "-" || vo.ArtifactFilePath == stdinArtifact) {
+			return targets, fmt.Errorf("only one of the subjects file, artifact, or artifact list can be read from stdin")
+		}
+
+		if subjectEntries, err = readSubjectsFile(vo.SubjectsFilePath, os.Stdin); err != nil {
+			return targets, err
+		}
+	}
+
+	var verifyCache *cache.Cache
+	if vo.CacheOptions.Dir != "" {
+		verifyCache, err = cache.New(vo.CacheOptions.Dir, vo.CacheOptions.TTL)
+		if err != nil {
+			return targets, err
+		}
+	}
+
+	extraSubjects := []cryptoutil.DigestSet{}
+	if vo.ArtifactRef != "" {
+		refDigestSets, err := cachedArtifactDigestsFromRef(ctx, verifyCache, vo.ArtifactRef, []crypto.Hash{crypto.SHA256})
+		if err != nil {
+			return targets, withExitCode(ExitCodeInfrastructure, fmt.Errorf("failed to calculate digest of artifact reference: %w", err))
+		}
+
+		extraSubjects = append(extraSubjects, refDigestSets...)
+	}
+
+	memSource := newCollectionMemorySource()
+	if len(vo.DetachedPredicatePaths) > 0 {
+		memSource.predicates = detached.NewStore()
+		memSource.bundles = disclosure.NewStore()
+		for _, path := range vo.DetachedPredicatePaths {
+			if err := loadDetachedPredicate(memSource, path); err != nil {
+				return targets, err
+			}
+		}
 	}
 
-	var collectionSource source.Sourcer
-	memSource := source.NewMemorySource()
 	for _, path := range vo.AttestationFilePaths {
 		if err := memSource.LoadFile(path); err != nil {
-			return fmt.Errorf("failed to load attestation file: %w", err)
+			return targets, fmt.Errorf("failed to load attestation file: %w", err)
+		}
+	}
+
+	if err := loadSCITTStatements(memSource, vo.SCITTStatementPaths, vo.SCITTServiceKeyPath); err != nil {
+		return targets, err
+	}
+
+	if err := checkRekorEntries(ctx, vo.AttestationFilePaths, vo.RekorServer, vo.RekorPublicKeyPath); err != nil {
+		return targets, err
+	}
+
+	for _, subjectPURL := range vo.SubjectPURLs {
+		purlDigestSets, err := purlSubjectDigests(memSource.Subjects(), subjectPURL)
+		if err != nil {
+			return targets, withExitCode(ExitCodeMissingAttestations, err)
+		}
+
+		extraSubjects = append(extraSubjects, purlDigestSets...)
+	}
+
+	for _, subDigest := range vo.AdditionalSubjects {
+		extraSubjects = append(extraSubjects, cryptoutil.DigestSet{crypto.SHA256: subDigest})
+	}
+
+	targets = make([]verifyTarget, len(artifactPaths))
+	forEachConcurrently(vo.Concurrency, len(artifactPaths), func(i int) {
+		targets[i].name = artifactPaths[i]
+		artifactDigestSet, err := artifactDigestFromPath(artifactPaths[i], []crypto.Hash{crypto.SHA256})
+		if err != nil {
+			targets[i].err = err
+			return
+		}
+
+		targets[i].subjects = append([]cryptoutil.DigestSet{artifactDigestSet}, extraSubjects...)
+	})
+
+	for _, target := range targets {
+		if target.err != nil {
+			return targets, fmt.Errorf("failed to calculate artifact digest: %w", target.err)
+		}
+	}
+
+	for _, entry := range subjectEntries {
+		targets = append(targets, verifyTarget{name: entry.name, subjects: append([]cryptoutil.DigestSet{entry.digest}, extraSubjects...)})
+	}
+
+	if len(targets) == 0 && len(extraSubjects) > 0 {
+		targets = append(targets, verifyTarget{name: vo.ArtifactRef, subjects: extraSubjects})
+	}
+
+	if len(targets) == 0 {
+		return targets, fmt.Errorf("must supply an artifact file, artifact reference, or subject digest to verify")
+	}
+
+	var sums *checksumFile
+	if vo.ChecksumsFilePath != "" {
+		if sums, err = loadChecksumFile(vo.ChecksumsFilePath); err != nil {
+			return targets, err
+		}
+
+		if len(artifactPaths) == 0 {
+			return targets, fmt.Errorf("must supply artifact files to check against the checksum file")
+		}
+
+		if len(subjectEntries) > 0 {
+			return targets, fmt.Errorf("subjects from a subjects file can't be checked against a checksum file")
+		}
+
+		// the checksum file is a subject so a collection that attested it is found for the artifacts it lists
+		for i := range targets {
+			targets[i].subjects = append(targets[i].subjects, sums.digest)
+		}
+	}
+
+	keyWindows, err := keywindow.FromPolicy(policyEnvelope.Payload)
+	if err != nil {
+		return targets, err
+	}
+
+	sctRequirements, err := sct.FromPolicy(policyEnvelope.Payload)
+	if err != nil {
+		return targets, withExitCode(ExitCodePolicy, err)
+	}
+
+	approvalRequirements, err := approvals.FromPolicy(policyEnvelope.Payload)
+	if err != nil {
+		return targets, withExitCode(ExitCodePolicy, err)
+	}
+
+	waiverEvaluator, err := waivers.FromPolicy(policyEnvelope.Payload)
+	if err != nil {
+		return targets, withExitCode(ExitCodePolicy, err)
+	}
+
+	// waivers are evaluated against the policy directly, so its signature is checked before any waiver applies
+	if waiverEvaluator != nil {
+		if _, err := policyEnvelope.Verify(dsse.VerifyWithVerifiers(verifier)); err != nil {
+			return targets, withExitCode(ExitCodeSignature, fmt.Errorf("could not verify policy: %w", err))
+		}
+	}
+
+	cosignRequirement, err := cosign.FromPolicy(policyEnvelope.Payload)
+	if err != nil {
+		return targets, withExitCode(ExitCodePolicy, err)
+	}
+
+	notationRequirement, err := notation.FromPolicy(policyEnvelope.Payload)
+	if err != nil {
+		return targets, withExitCode(ExitCodePolicy, err)
+	}
+
+	// image signatures belong to the artifact reference, so they're checked once for every target
+	var imageSignatureErr error
+	if cosignRequirement != nil || notationRequirement != nil {
+		if _, err := policyEnvelope.Verify(dsse.VerifyWithVerifiers(verifier)); err != nil {
+			return targets, withExitCode(ExitCodeSignature, fmt.Errorf("could not verify policy: %w", err))
+		}
+
+		if cosignRequirement != nil {
+			imageSignatureErr = checkCosignSignature(ctx, cosignRequirement, vo.ArtifactRef)
 		}
+
+		if notationRequirement != nil && imageSignatureErr == nil {
+			imageSignatureErr = checkNotationSignature(ctx, notationRequirement, vo.ArtifactRef)
+		}
+	}
+
+	var revocations *revocation.List
+	if vo.RevocationList != "" {
+		revocations, err = loadRevocationList(ctx, vo, verifier)
+		if err != nil {
+			return targets, err
+		}
+	}
+
+	archivistURLs := make([]string, 0, len(vo.ArchivistOptions.Urls))
+	for _, server := range vo.ArchivistOptions.Urls {
+		archivistURL, err := transport.ResolveURL(server)
+		if err != nil {
+			return targets, err
+		}
+
+		archivistURLs = append(archivistURLs, archivistURL)
+	}
+
+	var localStore *localstore.Store
+	if vo.LocalStoreOptions.Enable {
+		if localStore, err = localstore.Open(vo.LocalStoreOptions.StoreDirOptions.Dir); err != nil {
+			return targets, err
+		}
+	}
+
+	// the archivist source remembers which envelopes it has already returned, so each target gets its own
+	newCollectionSource := func() *evidenceRecorder {
+		sources := []source.Sourcer{memSource}
+		if localStore != nil {
+			sources = append(sources, localStoreSource{localStore})
+		}
+
+		if vo.ArchivistOptions.Enable {
+			for _, archivistURL := range archivistURLs {
+				sources = append(sources, newCachingArchivistSource(archivistURL, verifyCache))
+			}
+		}
+
+		var collectionSource source.Sourcer = memSource
+		if len(sources) > 1 {
+			collectionSource = source.NewMultiSource(sources...)
+		}
+
+		if vo.ValidateSchemas {
+			collectionSource = schemaValidatingSource{collectionSource}
+		}
+
+		if revocations != nil {
+			collectionSource = revokingSource{collectionSource, revocations}
+		}
+
+		if keyWindows != nil {
+			collectionSource = keyWindowSource{collectionSource, keyWindows}
+		}
+
+		if sctRequirements != nil {
+			collectionSource = sctSource{collectionSource, sctRequirements}
+		}
+
+		if ro.FIPS {
+			collectionSource = fipsSource{collectionSource}
+		}
+
+		if len(pqVerifiers) > 0 {
+			collectionSource = pqSignedSource{collectionSource, pqVerifiers}
+		}
+
+		return newEvidenceRecorder(collectionSource)
+	}
+
+	// verifyOne verifies the policy for one target. If the policy fails and it trusts waivers, it's evaluated
+	// again with the constraints any active waivers for the target exempt.
+	verifyOne := func(target *verifyTarget) map[string][]source.VerifiedCollection {
+		if sums != nil {
+			if err := sums.check(target.name, target.subjects[0]); err != nil {
+				target.evidence, target.err = []string{}, withExitCode(ExitCodePolicy, err)
+				return nil
+			}
+		}
+
+		if imageSignatureErr != nil {
+			target.evidence, target.err = []string{}, imageSignatureErr
+			return nil
+		}
+
+		recorder := newCollectionSource()
+		verifiedEvidence, err := witness.Verify(
+			ctx,
+			policyEnvelope,
+			[]cryptoutil.Verifier{verifier},
+			witness.VerifyWithSubjectDigests(target.subjects),
+			witness.VerifyWithCollectionSource(recorder),
+		)
+
+		if err != nil {
+			err = withExitCode(verifyExitCode(err, policyEnvelope, recorder), err)
+			if waivedEvidence, waived := verifyWaived(ctx, waiverEvaluator, newCollectionSource(), target.subjects, err); waivedEvidence != nil {
+				verifiedEvidence, target.waivers, err = waivedEvidence, waived, nil
+			}
+		}
+
+		target.steps = stepResults(policyEnvelope, verifiedEvidence, recorder)
+		if err == nil {
+			err = withExitCode(ExitCodeMissingAttestations, checkPURLEvidence(verifiedEvidence, vo.SubjectPURLs))
+		}
+
+		if err == nil {
+			err = checkApprovals(ctx, approvalRequirements, newCollectionSource(), target.subjects)
+		}
+
+		target.evidence = evidenceReferences(verifiedEvidence)
+		target.verified = verifiedEvidence
+		target.err = err
+		return verifiedEvidence
+	}
+
+	if len(targets) == 1 && vo.Output == "" {
+		verifiedEvidence := verifyOne(&targets[0])
+		event.addVerifyTargets(targets)
+		if targets[0].err != nil {
+			return targets, fmt.Errorf("failed to verify policy: %w", targets[0].err)
+		}
+
+		if len(targets[0].waivers) > 0 {
+			log.Warn("Verification succeeded with waivers:")
+			for _, w := range targets[0].waivers {
+				log.Warnf("%v (%v)", w, w.Reference)
+			}
+		} else {
+			log.Info("Verification succeeded")
+		}
+
+		log.Info("Evidence:")
+		num := 0
+		for _, stepEvidence := range verifiedEvidence {
+			for _, e := range stepEvidence {
+				log.Info(fmt.Sprintf("%d: %s", num, e.Reference))
+				num++
+			}
+		}
+
+		return targets, nil
+	}
+
+	forEachConcurrently(vo.Concurrency, len(targets), func(i int) {
+		verifyOne(&targets[i])
+	})
+
+	event.addVerifyTargets(targets)
+	failed, err := writeVerifyResults(os.Stdout, vo, targets)
+	if err != nil {
+		return targets, err
+	}
+
+	if failed > 0 {
+		errs := make([]error, 0, len(targets))
+		for _, target := range targets {
+			errs = append(errs, target.err)
+		}
+
+		return targets, withExitCode(mostSevereExitCode(errs), fmt.Errorf("failed to verify policy for %d of %d artifacts", failed, len(targets)))
+	}
+
+	log.Infof("Verification succeeded for %d artifacts", len(targets))
+	return targets, nil
+
+}
+
+// evidenceReferences returns the references of the collections that satisfied the policy, sorted by step
+func evidenceReferences(verifiedEvidence map[string][]source.VerifiedCollection) []string {
+	steps := make([]string, 0, len(verifiedEvidence))
+	for step := range verifiedEvidence {
+		steps = append(steps, step)
+	}
+
+	sort.Strings(steps)
+	references := []string{}
+	for _, step := range steps {
+		for _, collection := range verifiedEvidence[step] {
+			references = append(references, collection.Reference)
+		}
+	}
+
+	return references
+}
+
+// loadRevocationList loads the signed revocation list, trusting the revocation key or else the policy key
+func loadRevocationList(ctx context.Context, vo options.VerifyOptions, policyVerifier cryptoutil.Verifier) (*revocation.List, error) {
+	verifier := policyVerifier
+	if vo.RevocationKeyPath != "" {
+		keyFile, err := os.Open(vo.RevocationKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open revocation list key: %w", err)
+		}
+
+		defer keyFile.Close()
+		verifier, err = cryptoutil.NewVerifierFromReader(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create revocation list verifier: %w", err)
+		}
+
+		if ro.FIPS {
+			if err := fips.CheckVerifier(verifier); err != nil {
+				return nil, fmt.Errorf("revocation list key is not FIPS compliant: %w", err)
+			}
+		}
+	}
+
+	if verifier == nil {
+		return nil, fmt.Errorf("a revocation list key is required when the policy is verified with a CA")
+	}
+
+	return revocation.Load(ctx, vo.RevocationList, []cryptoutil.Verifier{verifier})
+}
+
+// loadPQVerifiers loads the post-quantum public keys that policies and collections must also be signed with
+func loadPQVerifiers(paths []string) ([]cryptoutil.Verifier, error) {
+	if len(paths) > 0 && ro.FIPS {
+		return nil, fmt.Errorf("post-quantum keys are not supported in FIPS mode")
 	}
 
-	collectionSource = memSource
-	if vo.ArchivistOptions.Enable {
-		collectionSource = source.NewMultiSource(collectionSource, source.NewArchvistSource(archivist.New(vo.ArchivistOptions.Url)))
+	verifiers := make([]cryptoutil.Verifier, 0, len(paths))
+	for _, path := range paths {
+		keyFile, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open post-quantum key: %w", err)
+		}
+
+		verifier, err := pqsign.LoadVerifier(keyFile)
+		keyFile.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load post-quantum key %v: %w", path, err)
+		}
+
+		verifiers = append(verifiers, verifier)
 	}
 
-	verifiedEvidence, err := witness.Verify(
-		ctx,
-		policyEnvelope,
-		[]cryptoutil.Verifier{verifier},
-		witness.VerifyWithSubjectDigests(subjects),
-		witness.VerifyWithCollectionSource(collectionSource),
-	)
+	return verifiers, nil
+}
 
+// checkPolicyFIPS makes sure every key and root a policy trusts is FIPS compliant, so collections can only
+// satisfy it with approved signatures
+func checkPolicyFIPS(policyEnvelope dsse.Envelope) error {
+	pol := policy.Policy{}
+	if err := json.Unmarshal(policyEnvelope.Payload, &pol); err != nil {
+		return fmt.Errorf("failed to parse policy: %w", err)
+	}
+
+	if err := fips.CheckPolicy(pol); err != nil {
+		return fmt.Errorf("policy is not FIPS compliant: %w", err)
+	}
+
+	return nil
+}
+
+// verifyWaived evaluates the policy again with the constraints exempted by the target's active waivers, if the
+// policy trusts waivers and verification failed because of the policy rather than infrastructure. It returns the
+// evidence and the waivers that were applied if the policy then passes, and nil otherwise.
+func verifyWaived(ctx context.Context, evaluator *waivers.Evaluator, src source.Sourcer, subjects []cryptoutil.DigestSet, verifyErr error) (map[string][]source.VerifiedCollection, []waivers.Waiver) {
+	if evaluator == nil {
+		return nil, nil
+	}
+
+	switch ExitCode(verifyErr) {
+	case ExitCodePolicy, ExitCodeMissingAttestations, ExitCodeSignature:
+	default:
+		return nil, nil
+	}
+
+	digests := subjectDigestValues(subjects)
+	active, expired, err := evaluator.Find(ctx, src, digests, time.Now())
 	if err != nil {
-		return fmt.Errorf("failed to verify policy: %w", err)
+		log.Errorf("failed to find waivers: %v", err)
+		return nil, nil
+	}
+
+	for _, w := range expired {
+		log.Warnf("ignoring expired waiver %v: %v", w.Reference, w)
+	}
+
+	if len(active) == 0 {
+		return nil, nil
+	}
+
+	verifiedEvidence, err := evaluator.Verify(ctx, src, digests, active)
+	if err != nil {
+		log.Debugf("(waivers) policy failed with waivers applied: %v", err)
+		return nil, nil
+	}
 
+	return verifiedEvidence, active
+}
+
+// subjectDigestValues returns the digests of the subjects, as collection sources search for them
+func subjectDigestValues(subjects []cryptoutil.DigestSet) []string {
+	digests := []string{}
+	for _, digestSet := range subjects {
+		for _, digest := range digestSet {
+			digests = append(digests, digest)
+		}
+	}
+
+	return digests
+}
+
+// checkApprovals makes sure every approval group in the policy has approved one of the subjects
+func checkApprovals(ctx context.Context, requirements *approvals.Requirements, src source.Sourcer, subjects []cryptoutil.DigestSet) error {
+	if requirements == nil {
+		return nil
+	}
+
+	err := requirements.Check(ctx, src, subjectDigestValues(subjects))
+	if errors.As(err, &approvals.ErrInsufficientApprovals{}) {
+		return withExitCode(ExitCodePolicy, err)
+	}
+
+	return withExitCode(ExitCodeInfrastructure, err)
+}
+
+// checkPURLEvidence makes sure each purl names a subject of a collection that passed verification. Digests for
+// purls are looked up before the attestation files are verified, so this keeps an unsigned file from claiming a
+// purl for some other artifact's digest.
+func checkPURLEvidence(evidence map[string][]source.VerifiedCollection, patterns []string) error {
+	subjects := []intoto.Subject{}
+	for _, collections := range evidence {
+		for _, collection := range collections {
+			subjects = append(subjects, collection.Statement.Subject...)
+		}
 	}
 
-	log.Info("Verification succeeded")
-	log.Info("Evidence:")
-	num := 0
-	for _, stepEvidence := range verifiedEvidence {
-		for _, e := range stepEvidence {
-			log.Info(fmt.Sprintf("%d: %s", num, e.Reference))
-			num++
+	for _, pattern := range patterns {
+		if _, err := purlSubjectDigests(subjects, pattern); err != nil {
+			return fmt.Errorf("no verified attestation names %v as a subject", pattern)
 		}
 	}
 
 	return nil
+}
+
+// purlSubjectDigests returns the digests of subjects named by a purl that matches pattern. Subject names are
+// prefixed by the type of attestation that recorded them, so the purl is found by its pkg: scheme.
+func purlSubjectDigests(subjects []intoto.Subject, pattern string) ([]cryptoutil.DigestSet, error) {
+	patternPURL, err := purl.Parse(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	digestSets := []cryptoutil.DigestSet{}
+	for _, subject := range subjects {
+		name := subject.Name
+		if i := strings.Index(name, "/pkg:"); i >= 0 {
+			name = name[i+1:]
+		}
+
+		subjectPURL, err := purl.Parse(name)
+		if err != nil || !patternPURL.Matches(subjectPURL) {
+			continue
+		}
+
+		digestSet, err := cryptoutil.NewDigestSet(subject.Digest)
+		if err != nil {
+			log.Debugf("skipping subject %v: %v", subject.Name, err)
+			continue
+		}
+
+		digestSets = append(digestSets, digestSet)
+	}
+
+	if len(digestSets) == 0 {
+		return nil, fmt.Errorf("no attestation subjects match %v", pattern)
+	}
+
+	return digestSets, nil
+}
+
+// loadDetachedPredicate adds the detached collection or disclosure bundle in the file at path to memSource
+func loadDetachedPredicate(memSource *collectionMemorySource, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read detached predicate: %w", err)
+	}
+
+	if !disclosure.IsBundle(data) {
+		return memSource.predicates.Add(data)
+	}
+
+	bundle, err := disclosure.ParseBundle(data)
+	if err != nil {
+		return fmt.Errorf("failed to load %v: %w", path, err)
+	}
 
+	return memSource.bundles.Add(bundle)
 }
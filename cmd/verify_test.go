@@ -19,17 +19,25 @@ import (
 	"context"
 	"crypto"
 	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	witness "github.com/testifysec/go-witness"
 	"github.com/testifysec/go-witness/attestation/commandrun"
 	"github.com/testifysec/go-witness/cryptoutil"
 	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
 	"github.com/testifysec/go-witness/policy"
+	"github.com/testifysec/go-witness/source"
 	"github.com/testifysec/witness/options"
 )
 
@@ -219,6 +227,7 @@ func TestRunVerifyKeyPair(t *testing.T) {
 		PolicyFilePath:       policyFilePath,
 		ArtifactFilePath:     artifactPath,
 		AdditionalSubjects:   subjects,
+		ValidateSchemas:      true,
 	}
 
 	err = runVerify(context.Background(), vo)
@@ -228,6 +237,254 @@ func TestRunVerifyKeyPair(t *testing.T) {
 
 }
 
+// verifyFixture is a signed policy requiring step01 and step02, with the functionary's key
+type verifyFixture struct {
+	workingDir    string
+	policyPath    string
+	policyPubPath string
+	funcPrivPath  string
+	artifactPath  string
+}
+
+func newVerifyFixture(t *testing.T) verifyFixture {
+	policy, funcPriv := makepolicyRSAPub(t)
+	signedPolicy, pub := signPolicyRSA(t, policy)
+	workingDir := t.TempDir()
+	f := verifyFixture{
+		workingDir:    workingDir,
+		policyPath:    filepath.Join(workingDir, "signed-policy.json"),
+		policyPubPath: filepath.Join(workingDir, "policy-pub.pem"),
+		funcPrivPath:  filepath.Join(workingDir, "func-priv.pem"),
+		artifactPath:  filepath.Join(workingDir, "test.txt"),
+	}
+
+	require.NoError(t, os.WriteFile(f.policyPath, signedPolicy, 0644))
+	require.NoError(t, os.WriteFile(f.policyPubPath, pub, 0644))
+	require.NoError(t, os.WriteFile(f.funcPrivPath, funcPriv, 0644))
+	return f
+}
+
+// newVerifyFixtureWithPolicy returns a fixture like newVerifyFixture, with the policy changed by edit before it's signed
+func newVerifyFixtureWithPolicy(t *testing.T, edit func(p map[string]interface{})) verifyFixture {
+	policyBytes, funcPriv := makepolicyRSAPub(t)
+	p := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(policyBytes, &p))
+	edit(p)
+	policyBytes, err := json.Marshal(p)
+	require.NoError(t, err)
+	signedPolicy, pub := signPolicyRSA(t, policyBytes)
+	workingDir := t.TempDir()
+	f := verifyFixture{
+		workingDir:    workingDir,
+		policyPath:    filepath.Join(workingDir, "signed-policy.json"),
+		policyPubPath: filepath.Join(workingDir, "policy-pub.pem"),
+		funcPrivPath:  filepath.Join(workingDir, "func-priv.pem"),
+		artifactPath:  filepath.Join(workingDir, "test.txt"),
+	}
+
+	require.NoError(t, os.WriteFile(f.policyPath, signedPolicy, 0644))
+	require.NoError(t, os.WriteFile(f.policyPubPath, pub, 0644))
+	require.NoError(t, os.WriteFile(f.funcPrivPath, funcPriv, 0644))
+	return f
+}
+
+// addPolicyKey adds a new public key to the policy p and returns the path of its private key and a functionary for it
+func addPolicyKey(t *testing.T, p map[string]interface{}, name string) (string, policy.Functionary) {
+	_, verifier, pub, priv, err := createTestRSAKey()
+	require.NoError(t, err)
+	keyID, err := verifier.KeyID()
+	require.NoError(t, err)
+	p["publickeys"].(map[string]interface{})[keyID] = policy.PublicKey{KeyID: keyID, Key: pub}
+	privPath := filepath.Join(t.TempDir(), name+".pem")
+	require.NoError(t, os.WriteFile(privPath, priv, 0600))
+	return privPath, policy.Functionary{Type: "PublicKey", PublicKeyID: keyID}
+}
+
+// run records step in the fixture's working directory, signed with keyPath, and returns the attestation's path
+func (f verifyFixture) run(t *testing.T, step, keyPath, script string) string {
+	outPath := filepath.Join(t.TempDir(), step+".json")
+	require.NoError(t, runRun(context.Background(), options.RunOptions{
+		KeyOptions:  options.KeyOptions{KeyPath: keyPath},
+		WorkingDir:  f.workingDir,
+		OutFilePath: outPath,
+		StepName:    step,
+	}, []string{"bash", "-c", script}))
+	return outPath
+}
+
+func (f verifyFixture) verifyOptions(keyPath string, attestations ...string) options.VerifyOptions {
+	return options.VerifyOptions{
+		KeyPath:              keyPath,
+		AttestationFilePaths: attestations,
+		PolicyFilePath:       f.policyPath,
+		ArtifactFilePath:     f.artifactPath,
+	}
+}
+
+func TestVerifyFIPS(t *testing.T) {
+	f := newVerifyFixture(t)
+	step1 := f.run(t, "step01", f.funcPrivPath, "echo 'test01' > test.txt")
+	step2 := f.run(t, "step02", f.funcPrivPath, "echo 'test02' >> test.txt")
+
+	ro.FIPS = true
+	t.Cleanup(func() { ro.FIPS = false })
+	err := runVerify(context.Background(), f.verifyOptions(f.policyPubPath, step1, step2))
+	require.Error(t, err)
+	assert.Equal(t, ExitCodeSignature, ExitCode(err))
+	assert.Contains(t, err.Error(), "not FIPS compliant")
+}
+
+func TestVerifyExitCodes(t *testing.T) {
+	f := newVerifyFixture(t)
+	otherPriv, otherPub := rsakeypair(t)
+	step1 := f.run(t, "step01", f.funcPrivPath, "echo 'test01' > test.txt")
+	step2 := f.run(t, "step02", f.funcPrivPath, "echo 'test02' >> test.txt")
+	unsignedStep2 := f.run(t, "step02", otherPriv.Name(), "echo 'test02' >> test.txt")
+
+	err := runVerify(context.Background(), f.verifyOptions(f.policyPubPath, step1))
+	require.Error(t, err)
+	assert.Equal(t, ExitCodeMissingAttestations, ExitCode(err))
+
+	err = runVerify(context.Background(), f.verifyOptions(f.policyPubPath, step1, unsignedStep2))
+	require.Error(t, err)
+	assert.Equal(t, ExitCodeSignature, ExitCode(err))
+
+	err = runVerify(context.Background(), f.verifyOptions(otherPub.Name(), step1, step2))
+	require.Error(t, err)
+	assert.Equal(t, ExitCodeSignature, ExitCode(err))
+
+	err = runVerify(context.Background(), options.VerifyOptions{
+		KeyPath:        f.policyPubPath,
+		PolicyFilePath: f.policyPath,
+		ArtifactRef:    "http://127.0.0.1:1/artifact",
+	})
+	require.Error(t, err)
+	assert.Equal(t, ExitCodeInfrastructure, ExitCode(err))
+}
+
+func TestVerifyDecisionLog(t *testing.T) {
+	f := newVerifyFixture(t)
+	logPriv, logPub := rsakeypair(t)
+	step1 := f.run(t, "step01", f.funcPrivPath, "echo 'test01' > test.txt")
+	step1Digest, err := cryptoutil.CalculateDigestSetFromFile(f.artifactPath, []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+	step2 := f.run(t, "step02", f.funcPrivPath, "echo 'test02' >> test.txt")
+	posted := [][]byte{}
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		posted = append(posted, body)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	logPath := filepath.Join(t.TempDir(), "decisions.jsonl")
+	decisionOptions := options.DecisionLogOptions{Path: logPath, URL: server.URL, KeyPath: logPriv.Name()}
+	vo := f.verifyOptions(f.policyPubPath, step1, step2)
+	vo.AdditionalSubjects = []string{step1Digest[crypto.SHA256]}
+	vo.DecisionLogOptions = decisionOptions
+	require.NoError(t, runVerify(context.Background(), vo))
+
+	vo = f.verifyOptions(f.policyPubPath, step1)
+	vo.DecisionLogOptions = decisionOptions
+	require.Error(t, runVerify(context.Background(), vo))
+
+	logBytes, err := os.ReadFile(logPath)
+	require.NoError(t, err)
+	lines := bytes.Split(bytes.TrimSpace(logBytes), []byte("\n"))
+	require.Len(t, lines, 2)
+	require.Len(t, posted, 2)
+	assert.Equal(t, lines[0], posted[0])
+
+	logPubFile, err := os.Open(logPub.Name())
+	require.NoError(t, err)
+	defer logPubFile.Close()
+	verifier, err := cryptoutil.NewVerifierFromReader(logPubFile)
+	require.NoError(t, err)
+	policyDigest, err := cryptoutil.CalculateDigestSetFromFile(f.policyPath, []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+	decisions := []decision{}
+	for _, line := range lines {
+		env := dsse.Envelope{}
+		require.NoError(t, json.Unmarshal(line, &env))
+		_, err := env.Verify(dsse.VerifyWithVerifiers(verifier))
+		require.NoError(t, err)
+		stmt := intoto.Statement{}
+		require.NoError(t, json.Unmarshal(env.Payload, &stmt))
+		assert.Equal(t, decisionPredicateType, stmt.PredicateType)
+		d := decision{}
+		require.NoError(t, json.Unmarshal(stmt.Predicate, &d))
+		assert.Equal(t, policyDigest, d.Policy.Digest)
+		decisions = append(decisions, d)
+	}
+
+	assert.Equal(t, decisionPass, decisions[0].Result)
+	require.Len(t, decisions[0].Artifacts, 1)
+	assert.Len(t, decisions[0].Artifacts[0].Evidence, 2)
+	assert.Equal(t, decisionFail, decisions[1].Result)
+	assert.Equal(t, ExitCodeMissingAttestations, decisions[1].ExitCode)
+
+	// a decision that can't be recorded fails verification
+	status = http.StatusInternalServerError
+	vo = f.verifyOptions(f.policyPubPath, step1, step2)
+	vo.AdditionalSubjects = []string{step1Digest[crypto.SHA256]}
+	vo.DecisionLogOptions = decisionOptions
+	err = runVerify(context.Background(), vo)
+	require.Error(t, err)
+	assert.Equal(t, ExitCodeInfrastructure, ExitCode(err))
+
+	vo.DecisionLogOptions.KeyPath = ""
+	require.Error(t, runVerify(context.Background(), vo))
+}
+
+func TestMostSevereExitCode(t *testing.T) {
+	infraErr := withExitCode(ExitCodeInfrastructure, errors.New("timeout"))
+	missingErr := withExitCode(ExitCodeMissingAttestations, errors.New("missing"))
+	signatureErr := withExitCode(ExitCodeSignature, errors.New("bad signature"))
+	assert.Equal(t, ExitCodeInfrastructure, mostSevereExitCode([]error{nil, infraErr}))
+	assert.Equal(t, ExitCodeMissingAttestations, mostSevereExitCode([]error{infraErr, missingErr, nil}))
+	assert.Equal(t, ExitCodeSignature, mostSevereExitCode([]error{missingErr, signatureErr, infraErr}))
+	assert.Equal(t, ExitCodeError, mostSevereExitCode([]error{errors.New("other"), infraErr}))
+	assert.Equal(t, ExitCodeError, ExitCode(errors.New("other")))
+	assert.Nil(t, withExitCode(ExitCodeSignature, nil))
+}
+
+func TestPURLSubjectDigests(t *testing.T) {
+	sha := strings.Repeat("a", 64)
+	subjects := []intoto.Subject{
+		{Name: "https://witness.dev/attestations/packages/v0.1/pkg:npm/%40scope/app@1.0.0?file_name=app-1.0.0.tgz", Digest: map[string]string{"sha256": sha}},
+		{Name: "https://witness.dev/attestations/product/v0.1/file:app-1.0.0.tgz", Digest: map[string]string{"sha256": sha}},
+		{Name: "https://witness.dev/attestations/packages/v0.1/pkg:npm/other@1.0.0", Digest: map[string]string{"sha256": strings.Repeat("b", 64)}},
+	}
+
+	digestSets, err := purlSubjectDigests(subjects, "pkg:npm/%40scope/app@1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, []cryptoutil.DigestSet{{crypto.SHA256: sha}}, digestSets)
+
+	_, err = purlSubjectDigests(subjects, "pkg:npm/%40scope/app@2.0.0")
+	assert.Error(t, err)
+
+	evidence := map[string][]source.VerifiedCollection{
+		"build": {{CollectionEnvelope: source.CollectionEnvelope{Statement: intoto.Statement{Subject: subjects[1:]}}}},
+	}
+
+	assert.NoError(t, checkPURLEvidence(evidence, []string{"pkg:npm/other"}))
+	assert.Error(t, checkPURLEvidence(evidence, []string{"pkg:npm/%40scope/app"}))
+}
+
+func TestForEachConcurrently(t *testing.T) {
+	for _, concurrency := range []int{0, 1, 4, 100} {
+		results := make([]int, 50)
+		forEachConcurrently(concurrency, len(results), func(i int) {
+			results[i] = i * i
+		})
+
+		for i, result := range results {
+			assert.Equal(t, i*i, result)
+		}
+	}
+}
+
 func signPolicyRSA(t *testing.T, p []byte) (signedPolicy []byte, pub []byte) {
 	sign, _, pub, _, err := createTestRSAKey()
 	if err != nil {
@@ -347,3 +604,55 @@ func makepolicy(t *testing.T, functionary policy.Functionary, publicKey policy.P
 
 	return pb
 }
+
+func TestVerifySubjectsFile(t *testing.T) {
+	f := newVerifyFixture(t)
+	step1 := f.run(t, "step01", f.funcPrivPath, "echo 'test01' > test.txt")
+	step1Digest, err := cryptoutil.CalculateDigestSetFromFile(f.artifactPath, []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+	step2 := f.run(t, "step02", f.funcPrivPath, "echo 'test02' >> test.txt")
+	artifactDigest, err := cryptoutil.CalculateDigestSetFromFile(f.artifactPath, []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+
+	subjectsPath := filepath.Join(t.TempDir(), "subjects.txt")
+	missing := strings.Repeat("0", 64)
+	require.NoError(t, os.WriteFile(subjectsPath, []byte("# nightly sweep\nsha256:"+artifactDigest[crypto.SHA256]+" registry.example.com/app\n\n"+missing+"\n"), 0644))
+
+	vo := f.verifyOptions(f.policyPubPath, step1, step2)
+	vo.ArtifactFilePath = ""
+	vo.SubjectsFilePath = subjectsPath
+	vo.AdditionalSubjects = []string{step1Digest[crypto.SHA256]}
+	targets, err := verifyPolicy(context.Background(), vo)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to verify policy for 1 of 2 artifacts")
+	require.Len(t, targets, 2)
+	assert.Equal(t, "registry.example.com/app", targets[0].name)
+	assert.NoError(t, targets[0].err)
+	assert.Equal(t, "sha256:"+missing, targets[1].name)
+	assert.Error(t, targets[1].err)
+
+	out := &bytes.Buffer{}
+	failed, err := writeVerifyResults(out, options.VerifyOptions{Output: verifyOutputJSON}, targets)
+	require.NoError(t, err)
+	assert.Equal(t, 1, failed)
+	report := verifyReport{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &report))
+	assert.Equal(t, 1, report.Passed)
+	assert.Equal(t, 1, report.Failed)
+	require.Len(t, report.Results, 2)
+	assert.Equal(t, "PASSED", report.Results[0].Result)
+	assert.Equal(t, "sha256:"+artifactDigest[crypto.SHA256], report.Results[0].Subject)
+	assert.Len(t, report.Results[0].Evidence, 2)
+	assert.Equal(t, "FAILED", report.Results[1].Result)
+	assert.NotEmpty(t, report.Results[1].Error)
+
+	vo.ChecksumsFilePath = subjectsPath
+	_, err = verifyPolicy(context.Background(), vo)
+	require.Error(t, err)
+
+	vo.ChecksumsFilePath = ""
+	vo.Output = "xml"
+	_, err = verifyPolicy(context.Background(), vo)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown verify output format xml")
+}
@@ -0,0 +1,144 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/policy"
+	"github.com/testifysec/go-witness/source"
+	"github.com/testifysec/witness/pkg/trust"
+)
+
+// The checks a step of the policy can fail, in the order they're made
+const (
+	stepCheckMissingAttestations = "missing-attestations"
+	stepCheckUntrustedSignature  = "untrusted-signature"
+	stepCheckMissingAttestor     = "missing-attestor"
+	stepCheckRegoDenied          = "rego-denied"
+)
+
+// stepResult is how a step of the policy fared for a target
+type stepResult struct {
+	step   string
+	passed bool
+	// check is the check the step failed, and message explains why
+	check   string
+	message string
+}
+
+// stepResults explains how each step of the policy fared for a target. Steps with verified evidence passed.
+// witness.Verify only reports that a policy was denied, so the other steps are checked again against the
+// collections the recorder found: a step fails the first check that none of its collections passed. A step whose
+// collections pass every check failed only because its artifacts didn't match the steps it depends on, which
+// isn't attributed to a step. Nothing is returned if the policy can't be read.
+func stepResults(policyEnvelope dsse.Envelope, verified map[string][]source.VerifiedCollection, recorder *evidenceRecorder) []stepResult {
+	pol := policy.Policy{}
+	if err := json.Unmarshal(policyEnvelope.Payload, &pol); err != nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(pol.Steps))
+	for name := range pol.Steps {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	trusted, trustErr := trust.FromPolicy(pol)
+	results := make([]stepResult, 0, len(names))
+	for _, name := range names {
+		if len(verified[name]) > 0 || recorder == nil {
+			results = append(results, stepResult{step: name, passed: len(verified[name]) > 0})
+			continue
+		}
+
+		recorder.mu.Lock()
+		envelopes := recorder.found[name]
+		recorder.mu.Unlock()
+		results = append(results, checkStep(pol.Steps[name], envelopes, trusted, trustErr))
+	}
+
+	return results
+}
+
+func checkStep(step policy.Step, envelopes []source.CollectionEnvelope, trusted trust.Policy, trustErr error) stepResult {
+	result := stepResult{step: step.Name}
+	if len(envelopes) == 0 {
+		result.check, result.message = stepCheckMissingAttestations, fmt.Sprintf("no attestations were found for step %v", step.Name)
+		return result
+	}
+
+	signed := make([]source.CollectionEnvelope, 0, len(envelopes))
+	for _, env := range envelopes {
+		if trustErr != nil {
+			break
+		}
+
+		if _, err := env.Envelope.Verify(trusted.VerifyOpts...); err == nil {
+			signed = append(signed, env)
+		}
+	}
+
+	if len(signed) == 0 {
+		result.check, result.message = stepCheckUntrustedSignature, fmt.Sprintf("none of the %d collections found for step %v were signed by a key or root the policy trusts", len(envelopes), step.Name)
+		return result
+	}
+
+	for i, env := range signed {
+		check, message := checkCollection(step, env.Collection)
+		if check == "" {
+			return stepResult{step: step.Name, passed: true}
+		}
+
+		// the first collection's failure is reported, since it's usually the step's only collection
+		if i == 0 {
+			result.check, result.message = check, fmt.Sprintf("%v (collection %v)", message, env.Reference)
+		}
+	}
+
+	return result
+}
+
+// checkCollection returns the check collection fails for step, or an empty check if it passes
+func checkCollection(step policy.Step, collection attestation.Collection) (string, string) {
+	found := make(map[string]attestation.Attestor)
+	for _, a := range collection.Attestations {
+		found[a.Type] = a.Attestation
+	}
+
+	for _, expected := range step.Attestations {
+		attestor, ok := found[expected.Type]
+		if !ok {
+			return stepCheckMissingAttestor, fmt.Sprintf("step %v is missing the %v attestation", step.Name, expected.Type)
+		}
+
+		if err := policy.EvaluateRegoPolicy(attestor, expected.RegoPolicies); err != nil {
+			denied := policy.ErrPolicyDenied{}
+			if errors.As(err, &denied) {
+				return stepCheckRegoDenied, fmt.Sprintf("the %v attestation of step %v was denied: %v", expected.Type, step.Name, strings.Join(denied.Reasons, "; "))
+			}
+
+			return stepCheckRegoDenied, fmt.Sprintf("the rego policies for the %v attestation of step %v failed: %v", expected.Type, step.Name, err)
+		}
+	}
+
+	return "", ""
+}
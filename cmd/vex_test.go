@@ -0,0 +1,107 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/witness/attestation/vex"
+	"github.com/testifysec/witness/options"
+)
+
+const testOpenVEX = `{
+  "@context": "https://openvex.dev/ns/v0.2.0",
+  "@id": "https://example.com/vex/2023-001",
+  "author": "Example Security",
+  "statements": [
+    {
+      "vulnerability": {"name": "CVE-2023-44487", "aliases": ["GHSA-qppj-fm5r-hxr3"]},
+      "products": [{"@id": "pkg:golang/example.com/app@v1.0.0"}],
+      "status": "not_affected",
+      "justification": "vulnerable_code_not_in_execute_path"
+    }
+  ]
+}`
+
+const testCSAFVEX = `{
+  "document": {
+    "category": "csaf_vex",
+    "publisher": {"name": "Example PSIRT"},
+    "tracking": {"id": "EX-2023-002"}
+  },
+  "product_tree": {
+    "branches": [{"branches": [{"product": {"product_id": "CSAFPID-1", "name": "app 1.0.0", "product_identification_helper": {"purl": "pkg:golang/example.com/app@v1.0.0"}}}]}],
+    "full_product_names": [{"product_id": "CSAFPID-2", "name": "app 0.9.0"}]
+  },
+  "vulnerabilities": [
+    {
+      "cve": "CVE-2023-39325",
+      "product_status": {"known_not_affected": ["CSAFPID-1"], "known_affected": ["CSAFPID-2"]},
+      "flags": [{"label": "vulnerable_code_not_present", "product_ids": ["CSAFPID-1"]}],
+      "remediations": [{"category": "vendor_fix", "details": "Upgrade to 1.0.0", "product_ids": ["CSAFPID-2"]}]
+    }
+  ]
+}`
+
+func TestRunVEXAttestations(t *testing.T) {
+	workingDir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(workingDir, "vex"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(workingDir, "vex", "app.openvex.json"), []byte(testOpenVEX), 0644))
+	built := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(built, "app.csaf.json"), []byte(testCSAFVEX), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(built, "other.json"), []byte(`{"name": "not vex"}`), 0644))
+
+	priv, _ := rsakeypair(t)
+	attestationPath := filepath.Join(t.TempDir(), "outfile.txt")
+	runOptions := options.RunOptions{
+		KeyOptions:   options.KeyOptions{KeyPath: priv.Name()},
+		WorkingDir:   workingDir,
+		Attestations: []string{},
+		VEXDocuments: []string{"vex/app.openvex.json"},
+		OutFilePath:  attestationPath,
+		StepName:     "scan",
+	}
+
+	attestor := runAndGetAttestor[*vex.Attestor](t, runOptions, []string{"bash", "-c", "cp " + built + "/* ."})
+	require.Len(t, attestor.Documents, 2)
+	openVEX := attestor.Documents[0]
+	assert.Equal(t, filepath.Join("vex", "app.openvex.json"), openVEX.File)
+	assert.Equal(t, vex.FormatOpenVEX, openVEX.Format)
+	assert.Equal(t, "Example Security", openVEX.Author)
+	assert.Equal(t, []vex.Statement{{
+		Vulnerability: "CVE-2023-44487",
+		Aliases:       []string{"GHSA-qppj-fm5r-hxr3"},
+		Products:      []string{"pkg:golang/example.com/app@v1.0.0"},
+		Status:        vex.StatusNotAffected,
+		Justification: "vulnerable_code_not_in_execute_path",
+	}}, openVEX.Statements)
+
+	csaf := attestor.Documents[1]
+	assert.Equal(t, "app.csaf.json", csaf.File)
+	assert.Equal(t, vex.FormatCSAF, csaf.Format)
+	assert.Equal(t, "EX-2023-002", csaf.ID)
+	assert.Equal(t, []vex.Statement{
+		{Vulnerability: "CVE-2023-39325", Products: []string{"pkg:golang/example.com/app@v1.0.0"}, Status: vex.StatusNotAffected, Justification: "vulnerable_code_not_present"},
+		{Vulnerability: "CVE-2023-39325", Products: []string{"app 0.9.0"}, Status: vex.StatusAffected, ActionStatement: "Upgrade to 1.0.0"},
+	}, csaf.Statements)
+
+	subjects := attestor.Subjects()
+	assert.Contains(t, subjects, "vex:app.csaf.json")
+	assert.Contains(t, subjects, "vex:"+filepath.Join("vex", "app.openvex.json"))
+}
@@ -0,0 +1,105 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/testifysec/witness/attestation/waiver"
+	"github.com/testifysec/witness/options"
+)
+
+const waiveLong = `Records a signed waiver that temporarily exempts artifacts from a policy constraint, such as a step that
+can't run while a scanner is down. A waiver exempts a policy step, or with --attestation one attestation the step
+requires, for the given subjects until it expires. witness verify only applies waivers signed by a functionary the
+policy trusts to sign waivers, and only if the policy fails without them, and reports the artifacts as waived.`
+
+func WaiveCmd() *cobra.Command {
+	wo := options.WaiveOptions{}
+	cmd := &cobra.Command{
+		Use:               "waive",
+		Short:             "Records a signed waiver of a policy constraint for an artifact",
+		Long:              waiveLong,
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runWaive(cmd.Context(), wo)
+		},
+	}
+
+	wo.AddFlags(cmd)
+	return cmd
+}
+
+func runWaive(ctx context.Context, wo options.WaiveOptions) error {
+	if len(wo.Subjects) == 0 {
+		return fmt.Errorf("must supply at least one subject to waive")
+	}
+
+	if wo.Step == "" {
+		return fmt.Errorf("must supply the step to waive")
+	}
+
+	if wo.Reason == "" {
+		return fmt.Errorf("must supply a reason for the waiver")
+	}
+
+	if wo.Expires == "" {
+		return fmt.Errorf("must supply when the waiver expires")
+	}
+
+	expires, err := parseExpiry(wo.Expires, time.Now())
+	if err != nil {
+		return err
+	}
+
+	subjects, err := parseSubjects(wo.Subjects)
+	if err != nil {
+		return err
+	}
+
+	attestor := waiver.New(
+		waiver.WithStep(wo.Step),
+		waiver.WithAttestation(wo.Attestation),
+		waiver.WithReason(wo.Reason),
+		waiver.WithExpires(expires),
+		waiver.WithSubjects(subjects),
+	)
+
+	env, err := signAttestor(ctx, wo.KeyOptions, wo.TimestampServers, waiver.CollectionName, attestor)
+	if err != nil {
+		return fmt.Errorf("failed to record waiver: %w", err)
+	}
+
+	return publishEnvelope(ctx, wo.OutFilePath, wo.ArchivistOptions, env)
+}
+
+// parseExpiry parses an RFC 3339 time, or a duration added to now
+func parseExpiry(value string, now time.Time) (time.Time, error) {
+	if expires, err := time.Parse(time.RFC3339, value); err == nil {
+		return expires.UTC(), nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid expiry %q, must be an RFC 3339 time or a duration", value)
+	}
+
+	return now.Add(duration).UTC(), nil
+}
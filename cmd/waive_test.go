@@ -0,0 +1,84 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/policy"
+	"github.com/testifysec/witness/options"
+)
+
+func TestWaive(t *testing.T) {
+	waiverSigner := ""
+	f := newVerifyFixtureWithPolicy(t, func(p map[string]interface{}) {
+		privPath, functionary := addPolicyKey(t, p, "security")
+		waiverSigner = privPath
+		p["waivers"] = map[string][]policy.Functionary{"functionaries": {functionary}}
+	})
+
+	step1 := f.run(t, "step01", f.funcPrivPath, "echo 'test01' > test.txt")
+	artifactDigest, err := cryptoutil.CalculateDigestSetFromFile(f.artifactPath, []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+
+	waive := func(keyPath string) string {
+		outPath := filepath.Join(t.TempDir(), "waiver.json")
+		require.NoError(t, runWaive(context.Background(), options.WaiveOptions{
+			KeyOptions:  options.KeyOptions{KeyPath: keyPath},
+			Subjects:    []string{"sha256:" + artifactDigest[crypto.SHA256]},
+			Step:        "step02",
+			Reason:      "EXC-42 scanner outage",
+			Expires:     "24h",
+			OutFilePath: outPath,
+		}))
+
+		return outPath
+	}
+
+	_, err = verifyPolicy(context.Background(), f.verifyOptions(f.policyPubPath, step1))
+	require.Error(t, err)
+
+	// only the policy's waiver functionaries may waive a constraint
+	_, err = verifyPolicy(context.Background(), f.verifyOptions(f.policyPubPath, step1, waive(f.funcPrivPath)))
+	require.Error(t, err)
+
+	targets, err := verifyPolicy(context.Background(), f.verifyOptions(f.policyPubPath, step1, waive(waiverSigner)))
+	require.NoError(t, err)
+	require.Len(t, targets, 1)
+	require.Len(t, targets[0].waivers, 1)
+	assert.Equal(t, "step step02", targets[0].waivers[0].Constraint())
+	assert.Equal(t, "EXC-42 scanner outage", targets[0].waivers[0].Reason)
+}
+
+func TestParseExpiry(t *testing.T) {
+	now := time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC)
+	expires, err := parseExpiry("72h", now)
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(72*time.Hour), expires)
+
+	expires, err = parseExpiry("2022-12-01T00:00:00Z", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2022, 12, 1, 0, 0, 0, 0, time.UTC), expires)
+
+	_, err = parseExpiry("next week", now)
+	assert.Error(t, err)
+}
@@ -0,0 +1,242 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/options"
+)
+
+// watchSequenceAnnotation records which run of witness watch produced a collection
+const watchSequenceAnnotation = "watch-sequence"
+
+func WatchCmd() *cobra.Command {
+	wo := options.WatchOptions{}
+	cmd := &cobra.Command{
+		Use:               "watch [cmd]",
+		Short:             "Runs the provided command under witness again whenever the files it watches change",
+		Long:              "Runs the provided command under witness, then runs it again each time a watched file changes until witness is interrupted. The signed attestation of each run is written to the output directory with a sequence number that's also recorded as an annotation, so provenance accumulates while iterating locally.",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runWatch(cmd.Context(), wo, args)
+		},
+		Args: cobra.MinimumNArgs(1),
+	}
+
+	wo.AddFlags(cmd)
+	return cmd
+}
+
+func runWatch(ctx context.Context, wo options.WatchOptions, args []string) error {
+	if wo.OutDir == "" {
+		return fmt.Errorf("an output directory is required")
+	}
+
+	if wo.RunOptions.OutFilePath != "" {
+		return fmt.Errorf("--outfile cannot be used with witness watch, attestations are written to the output directory")
+	}
+
+	if wo.RunOptions.StepName == "" {
+		return fmt.Errorf("step name is required")
+	}
+
+	if wo.PollInterval <= 0 {
+		return fmt.Errorf("poll interval must be positive")
+	}
+
+	if err := os.MkdirAll(wo.OutDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	seq, err := nextWatchSequence(wo.OutDir, wo.RunOptions.StepName)
+	if err != nil {
+		return err
+	}
+
+	paths := append([]string{}, wo.Paths...)
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	for i, path := range paths {
+		if !filepath.IsAbs(path) {
+			paths[i] = filepath.Join(wo.RunOptions.WorkingDir, path)
+		}
+	}
+
+	ticker := time.NewTicker(wo.PollInterval)
+	defer ticker.Stop()
+	for {
+		outPath := watchOutPath(wo.OutDir, wo.RunOptions.StepName, seq)
+		ro := wo.RunOptions
+		ro.OutFilePath = outPath
+		ro.Annotations = append(append([]string{}, wo.RunOptions.Annotations...), fmt.Sprintf("%v=%d", watchSequenceAnnotation, seq))
+		if err := runRun(ctx, ro, args); err != nil {
+			os.Remove(outPath)
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			// a failing build is part of iterating, so keep watching
+			log.Errorf("run %d failed: %v", seq, err)
+		} else {
+			log.Infof("Wrote attestation for run %d to %v", seq, outPath)
+		}
+
+		seq++
+		// snapshot after the run so files the command writes don't trigger another run
+		snapshot, err := watchSnapshot(paths, wo.OutDir)
+		if err != nil {
+			return err
+		}
+
+		log.Infof("Watching for changes...")
+		for changed := false; !changed; {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+
+			current, err := watchSnapshot(paths, wo.OutDir)
+			if err != nil {
+				return err
+			}
+
+			changed = !snapshot.equal(current)
+		}
+	}
+}
+
+// watchOutPath returns the file the attestation of a run is written to
+func watchOutPath(dir, step string, seq int) string {
+	return filepath.Join(dir, fmt.Sprintf("%v-%d.json", step, seq))
+}
+
+// nextWatchSequence returns the sequence number after the last run of step written to dir, so restarting witness
+// watch keeps accumulating attestations rather than overwriting them
+func nextWatchSequence(dir, step string) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read output directory: %w", err)
+	}
+
+	next := 1
+	prefix := step + "-"
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+
+		seq, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".json"))
+		if err != nil || seq < next {
+			continue
+		}
+
+		next = seq + 1
+	}
+
+	return next, nil
+}
+
+type watchFileState struct {
+	size    int64
+	modTime time.Time
+	mode    fs.FileMode
+}
+
+// watchState is the size, modification time, and mode of every watched file
+type watchState map[string]watchFileState
+
+func (s watchState) equal(other watchState) bool {
+	if len(s) != len(other) {
+		return false
+	}
+
+	for path, state := range s {
+		otherState, ok := other[path]
+		if !ok || !state.modTime.Equal(otherState.modTime) || state.size != otherState.size || state.mode != otherState.mode {
+			return false
+		}
+	}
+
+	return true
+}
+
+// watchSnapshot records the state of the files under paths, skipping .git directories and the output directory
+func watchSnapshot(paths []string, outDir string) (watchState, error) {
+	absOutDir, err := filepath.Abs(outDir)
+	if err != nil {
+		return nil, err
+	}
+
+	state := watchState{}
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				// files can be removed while they're being walked
+				if os.IsNotExist(err) {
+					return nil
+				}
+
+				return err
+			}
+
+			if d.IsDir() {
+				absPath, err := filepath.Abs(path)
+				if err != nil {
+					return err
+				}
+
+				if d.Name() == ".git" || absPath == absOutDir {
+					return filepath.SkipDir
+				}
+
+				return nil
+			}
+
+			info, err := d.Info()
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+
+				return err
+			}
+
+			state[path] = watchFileState{size: info.Size(), modTime: info.ModTime(), mode: info.Mode()}
+			return nil
+		})
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to watch %v: %w", root, err)
+		}
+	}
+
+	return state, nil
+}
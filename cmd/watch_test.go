@@ -0,0 +1,87 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/witness/attestation/annotations"
+	"github.com/testifysec/witness/options"
+)
+
+func TestWatch(t *testing.T) {
+	priv, _ := rsakeypair(t)
+	workingDir := t.TempDir()
+	outDir := filepath.Join(workingDir, "attestations")
+	src := filepath.Join(workingDir, "main.c")
+	require.NoError(t, os.WriteFile(src, []byte("int main() {}"), 0644))
+	wo := options.WatchOptions{
+		RunOptions: options.RunOptions{
+			KeyOptions:   options.KeyOptions{KeyPath: priv.Name()},
+			WorkingDir:   workingDir,
+			Attestations: []string{},
+			StepName:     "build",
+		},
+		OutDir:       outDir,
+		PollInterval: 10 * time.Millisecond,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- runWatch(ctx, wo, []string{"bash", "-c", "cp main.c main.o"})
+	}()
+
+	waitForFile := func(path string) {
+		require.Eventually(t, func() bool {
+			envBytes, err := os.ReadFile(path)
+			return err == nil && json.Valid(envBytes)
+		}, 10*time.Second, 10*time.Millisecond)
+	}
+
+	waitForFile(filepath.Join(outDir, "build-1.json"))
+	// the files the command writes don't trigger another run
+	time.Sleep(100 * time.Millisecond)
+	_, err := os.Stat(filepath.Join(outDir, "build-2.json"))
+	require.True(t, os.IsNotExist(err))
+
+	require.NoError(t, os.WriteFile(src, []byte("int main() { return 1; }"), 0644))
+	waitForFile(filepath.Join(outDir, "build-2.json"))
+	cancel()
+	require.NoError(t, <-done)
+
+	for seq, path := range map[string]string{"1": "build-1.json", "2": "build-2.json"} {
+		_, collection := readCollection(t, filepath.Join(outDir, path))
+		attestor := findAttestor[*annotations.Attestor](collection)
+		require.NotNil(t, attestor)
+		assert.Equal(t, seq, attestor.Annotations[watchSequenceAnnotation])
+	}
+
+	seq, err := nextWatchSequence(outDir, "build")
+	require.NoError(t, err)
+	assert.Equal(t, 3, seq)
+}
+
+func TestWatchRequiresOutDir(t *testing.T) {
+	err := runWatch(context.Background(), options.WatchOptions{RunOptions: options.RunOptions{StepName: "build"}, PollInterval: time.Second}, []string{"true"})
+	require.Error(t, err)
+}
@@ -17,6 +17,8 @@ package main
 import (
 	"flag"
 	"log"
+	"os"
+	"path/filepath"
 
 	"github.com/spf13/cobra/doc"
 	"github.com/testifysec/witness/cmd"
@@ -34,4 +36,13 @@ func main() {
 	if err := doc.GenMarkdownTree(cmd.New(), directory); err != nil {
 		log.Fatalf("Error generating docs: %s", err)
 	}
+
+	// Generate the flag to environment variable mapping
+	envDocs, err := os.Create(filepath.Join(directory, "witness_env_variables.md"))
+	if err != nil {
+		log.Fatalf("Error generating environment variable docs: %s", err)
+	}
+
+	defer envDocs.Close()
+	cmd.WriteEnvDocs(envDocs, cmd.New())
 }
@@ -16,8 +16,17 @@ package main
 
 import (
 	"github.com/testifysec/witness/cmd"
+	"github.com/testifysec/witness/pkg/envshim"
+	"github.com/testifysec/witness/pkg/privdrop"
+	"github.com/testifysec/witness/pkg/ptyshim"
 )
 
 func main() {
+	// witness re-executes itself to drop privileges before running a command with --user or --group
+	privdrop.Init()
+	// to set the command's environment with --env, --env-file, or --clean-env
+	envshim.Init()
+	// and to run the command under a pseudo-terminal with --tty
+	ptyshim.Init()
 	cmd.Execute()
 }
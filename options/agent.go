@@ -0,0 +1,71 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import "github.com/spf13/cobra"
+
+// AgentTLSOptions configure TLS between witness agent and the orchestrator dispatching runs to it
+type AgentTLSOptions struct {
+	CertPath string
+	KeyPath  string
+	CAPath   string
+	Insecure bool
+	// NoClientAuth serves TLS without requiring client certificates when CAPath is empty
+	NoClientAuth bool
+}
+
+type AgentOptions struct {
+	// RunOptions are used for every run the agent is sent, such as how envelopes are signed and stored
+	RunOptions RunOptions
+	Listen     string
+	TLSOptions AgentTLSOptions
+}
+
+func (o *AgentOptions) AddFlags(cmd *cobra.Command) {
+	o.RunOptions.AddFlags(cmd)
+	cmd.Flags().StringVar(&o.Listen, "listen", "127.0.0.1:9210", "Address to serve the agent's gRPC service on")
+	cmd.Flags().StringVar(&o.TLSOptions.CertPath, "tls-cert", "", "Certificate the agent serves TLS with")
+	cmd.Flags().StringVar(&o.TLSOptions.KeyPath, "tls-key", "", "Private key of --tls-cert")
+	cmd.Flags().StringVar(&o.TLSOptions.CAPath, "tls-client-ca", "", "CA certificates that orchestrators' client certificates must be issued by. Required unless --tls-no-client-auth is set")
+	cmd.Flags().BoolVar(&o.TLSOptions.NoClientAuth, "tls-no-client-auth", false, "Serve TLS without requiring client certificates. Anyone who can reach the agent can run commands as it")
+	cmd.Flags().BoolVar(&o.TLSOptions.Insecure, "insecure", false, "Serve without TLS. Anyone who can reach the agent can run commands as it")
+}
+
+type AgentDispatchOptions struct {
+	Agent        string
+	TLSOptions   AgentTLSOptions
+	StepName     string
+	WorkingDir   string
+	Attestations []string
+	Env          []string
+	Annotations  []string
+	Tracing      bool
+	OutFilePath  string
+}
+
+func (o *AgentDispatchOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.Agent, "agent", "127.0.0.1:9210", "Address of the agent to run the command on")
+	cmd.Flags().StringVar(&o.TLSOptions.CertPath, "tls-cert", "", "Client certificate to authenticate to the agent with")
+	cmd.Flags().StringVar(&o.TLSOptions.KeyPath, "tls-key", "", "Private key of --tls-cert")
+	cmd.Flags().StringVar(&o.TLSOptions.CAPath, "tls-ca", "", "CA certificates to verify the agent's certificate with. Defaults to the system's")
+	cmd.Flags().BoolVar(&o.TLSOptions.Insecure, "insecure", false, "Connect to the agent without TLS")
+	cmd.Flags().StringVarP(&o.StepName, "step", "s", "", "Name of the step being run")
+	cmd.Flags().StringVarP(&o.WorkingDir, "workingdir", "d", "", "Directory to run the command in, relative to the agent's working directory")
+	cmd.Flags().StringSliceVarP(&o.Attestations, "attestations", "a", []string{}, "Attestors to run in addition to the agent's")
+	cmd.Flags().StringArrayVar(&o.Env, "env", []string{}, "Environment variable to set for the command as KEY=VALUE")
+	cmd.Flags().StringSliceVar(&o.Annotations, "annotation", []string{}, "Annotations to record in the attestation collection as key=value, in addition to the agent's")
+	cmd.Flags().BoolVar(&o.Tracing, "trace", false, "Enable tracing for the command")
+	cmd.Flags().StringVarP(&o.OutFilePath, "outfile", "o", "", "File to write the signed envelope to. Defaults to stdout")
+}
@@ -0,0 +1,35 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import "github.com/spf13/cobra"
+
+type ApproveOptions struct {
+	KeyOptions       KeyOptions
+	ArchivistOptions ArchivistOptions
+	Subjects         []string
+	Reason           string
+	OutFilePath      string
+	TimestampServers []string
+}
+
+func (ao *ApproveOptions) AddFlags(cmd *cobra.Command) {
+	ao.KeyOptions.AddFlags(cmd)
+	ao.ArchivistOptions.AddFlags(cmd)
+	cmd.Flags().StringSliceVarP(&ao.Subjects, "subject", "s", []string{}, "Digest of an artifact to approve, such as sha256:abc123. May be given more than once")
+	cmd.Flags().StringVar(&ao.Reason, "reason", "", "Why the artifacts are approved, such as a change ticket or review")
+	cmd.Flags().StringVarP(&ao.OutFilePath, "outfile", "o", "", "File to write the signed approval to. Defaults to stdout")
+	cmd.Flags().StringSliceVar(&ao.TimestampServers, "timestamp-servers", []string{}, "Timestamp Authority Servers to use when signing the approval")
+}
@@ -0,0 +1,29 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import "github.com/spf13/cobra"
+
+type DecisionLogOptions struct {
+	Path    string
+	URL     string
+	KeyPath string
+}
+
+func (o *DecisionLogOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.Path, "decision-log", "", "File to append a signed record of the verification decision to, one envelope per line")
+	cmd.Flags().StringVar(&o.URL, "decision-log-url", "", "URL to POST a signed record of the verification decision to")
+	cmd.Flags().StringVar(&o.KeyPath, "decision-log-key", "", "Path to the private key used to sign decision records. Required if a decision log is set")
+}
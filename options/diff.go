@@ -0,0 +1,25 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import "github.com/spf13/cobra"
+
+type DiffOptions struct {
+	Ignore []string
+}
+
+func (do *DiffOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&do.Ignore, "ignore", []string{}, "Fields to leave out of the diff, as an attestor name followed by the field's path such as command-run.stdout, a material, product, or environment entry such as product.build.log or environment.$HOME, or an attestor name to leave out the whole attestation")
+}
@@ -0,0 +1,39 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import "github.com/spf13/cobra"
+
+type DiscloseOptions struct {
+	BundlePath  string
+	Fields      []string
+	List        bool
+	OutFilePath string
+}
+
+func (o *DiscloseOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&o.BundlePath, "bundle", "b", "", "Path to the disclosure bundle written by witness run --detach-predicate --disclosable")
+	cmd.Flags().StringSliceVar(&o.Fields, "field", []string{}, "JSON pointer of a field to disclose, such as /attestations/0/attestation/commithash. Fields nested under it are disclosed too")
+	cmd.Flags().BoolVar(&o.List, "list", false, "List the paths of the fields that can be disclosed instead of disclosing them")
+	cmd.Flags().StringVarP(&o.OutFilePath, "outfile", "o", "", "File to write the disclosure to. Defaults to stdout")
+}
+
+type DiscloseVerifyOptions struct {
+	KeyPath string
+}
+
+func (o *DiscloseVerifyOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&o.KeyPath, "publickey", "k", "", "Path to the public key the attestation was signed with")
+}
@@ -0,0 +1,33 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type DoctorOptions struct {
+	RunOptions   RunOptions
+	Registries   []string
+	CheckTimeout time.Duration
+}
+
+func (do *DoctorOptions) AddFlags(cmd *cobra.Command) {
+	do.RunOptions.AddFlags(cmd)
+	cmd.Flags().StringSliceVar(&do.Registries, "registry", []string{}, "Image references, such as ghcr.io/org/app:latest, to check the registry can be reached and the manifest read")
+	cmd.Flags().DurationVar(&do.CheckTimeout, "check-timeout", 10*time.Second, "How long each check may take")
+}
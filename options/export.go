@@ -0,0 +1,29 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import "github.com/spf13/cobra"
+
+type ExportOptions struct {
+	Format         string
+	Path           string
+	ControlMapPath string
+}
+
+func (o *ExportOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.Format, "export-format", "", "Export the verification results as compliance evidence mapped to controls, as oscal assessment-results or a csv evidence matrix")
+	cmd.Flags().StringVar(&o.Path, "export-file", "", "File to write the exported verification results to. Required if an export format is set")
+	cmd.Flags().StringVar(&o.ControlMapPath, "control-map", "", "Path to a JSON file mapping attestation types to control IDs for the export. Defaults to NIST SSDF practices")
+}
@@ -0,0 +1,33 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import "github.com/spf13/cobra"
+
+type SpoolOptions struct {
+	Dir string
+}
+
+func (o *SpoolOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.Dir, "spool-dir", "", "Directory where envelopes waiting to be uploaded are queued. Defaults to a witness directory in the user's cache directory")
+}
+
+type FlushOptions struct {
+	SpoolOptions SpoolOptions
+}
+
+func (fo *FlushOptions) AddFlags(cmd *cobra.Command) {
+	fo.SpoolOptions.AddFlags(cmd)
+}
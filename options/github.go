@@ -0,0 +1,29 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import "github.com/spf13/cobra"
+
+type GitHubOptions struct {
+	Repo   string
+	APIURL string
+	Token  string
+}
+
+func (o *GitHubOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.Repo, "github-attestations-repo", "", "Repository, as owner/repo, to upload the attestation to with GitHub's artifact attestation API")
+	cmd.Flags().StringVar(&o.APIURL, "github-api-url", "https://api.github.com", "URL of the GitHub API")
+	cmd.Flags().StringVar(&o.Token, "github-token", "", "Token used to upload attestations to GitHub. Defaults to the GITHUB_TOKEN environment variable")
+}
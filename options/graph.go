@@ -0,0 +1,29 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import "github.com/spf13/cobra"
+
+type GraphOptions struct {
+	Format       string
+	OutFilePath  string
+	AllMaterials bool
+}
+
+func (o *GraphOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.Format, "format", "dot", "Graph format to write: dot or mermaid")
+	cmd.Flags().StringVarP(&o.OutFilePath, "outfile", "o", "", "File to write the graph to. Defaults to stdout")
+	cmd.Flags().BoolVar(&o.AllMaterials, "all-materials", false, "Include every material. By default only materials produced by another step in the graph are included")
+}
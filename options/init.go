@@ -0,0 +1,39 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import "github.com/spf13/cobra"
+
+type InitOptions struct {
+	CI       string
+	Signer   string
+	Storage  string
+	StepName string
+	Command  string
+	KeyPath  string
+	Dir      string
+	Force    bool
+}
+
+func (o *InitOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.CI, "ci", "", "CI system to write a snippet for: github, gitlab, tekton, or none. Asked for if not set")
+	cmd.Flags().StringVar(&o.Signer, "signer", "", "How steps are signed: key for a key pair, or spiffe for SPIRE issued certificates. Asked for if not set")
+	cmd.Flags().StringVar(&o.Storage, "storage", "", "Where attestations are stored: archivist, local for the local attestation store, or file. Asked for if not set")
+	cmd.Flags().StringVarP(&o.StepName, "step", "s", "", "Name of the step to record. Asked for if not set")
+	cmd.Flags().StringVar(&o.Command, "command", "", "Command the CI snippet records. Asked for if not set")
+	cmd.Flags().StringVarP(&o.KeyPath, "key", "k", "", "Private key that signs the policy, and the step with --signer key. Generated if it doesn't exist. Asked for if not set")
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "Directory to write the configuration, policy, and CI snippet to")
+	cmd.Flags().BoolVar(&o.Force, "force", false, "Overwrite files that already exist. Existing keys are always reused")
+}
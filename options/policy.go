@@ -0,0 +1,51 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import "github.com/spf13/cobra"
+
+type PolicyTestOptions struct {
+	KeyPath         string
+	PolicyFilePath  string
+	ValidateSchemas bool
+}
+
+func (po *PolicyTestOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&po.KeyPath, "publickey", "k", "", "Path to the policy signer's public key")
+	cmd.Flags().StringVarP(&po.PolicyFilePath, "policy", "p", "", "Path to the signed policy to test")
+	cmd.Flags().BoolVar(&po.ValidateSchemas, "validate-schemas", true, "Fail verification if an attestation doesn't match its attestor's published schema")
+}
+
+type PolicyShadowOptions struct {
+	CacheOptions          CacheOptions
+	ArchivistURL          string
+	KeyPath               string
+	PolicyFilePath        string
+	CurrentKeyPath        string
+	CurrentPolicyFilePath string
+	Subjects              []string
+	ValidateSchemas       bool
+}
+
+func (po *PolicyShadowOptions) AddFlags(cmd *cobra.Command) {
+	po.CacheOptions.AddFlags(cmd)
+	cmd.Flags().StringVar(&po.ArchivistURL, "archivist-server", "https://archivist.testifysec.io", "URL of the Archivist server to retrieve attestations from, or unix:///path/to/socket to connect over a Unix domain socket")
+	cmd.Flags().StringVarP(&po.KeyPath, "publickey", "k", "", "Path to the candidate policy signer's public key")
+	cmd.Flags().StringVarP(&po.PolicyFilePath, "policy", "p", "", "Path to the signed candidate policy")
+	cmd.Flags().StringVar(&po.CurrentKeyPath, "current-publickey", "", "Path to the current policy signer's public key. Defaults to the candidate policy signer's public key")
+	cmd.Flags().StringVar(&po.CurrentPolicyFilePath, "current-policy", "", "Path to the signed policy currently in use")
+	cmd.Flags().StringSliceVar(&po.Subjects, "subject", []string{}, "Subject digests to evaluate both policies for, such as sha256:abc123. Digests without an algorithm are sha256")
+	cmd.Flags().BoolVar(&po.ValidateSchemas, "validate-schemas", true, "Fail verification if an attestation doesn't match its attestor's published schema")
+}
@@ -0,0 +1,30 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/testifysec/witness/pkg/pqsign"
+)
+
+type PQKeygenOptions struct {
+	Mode          string
+	OutFilePrefix string
+}
+
+func (o *PQKeygenOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.Mode, "mode", pqsign.DefaultMode, "Dilithium parameter set to generate a key for: Dilithium2, Dilithium3, or Dilithium5")
+	cmd.Flags().StringVarP(&o.OutFilePrefix, "outfile-prefix", "o", "witness-pq", "Prefix of the files to write. The private key is written to <prefix>.key and the public key to <prefix>.pub")
+}
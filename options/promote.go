@@ -0,0 +1,36 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import "github.com/spf13/cobra"
+
+type PromoteOptions struct {
+	VerifyOptions    VerifyOptions
+	KeyOptions       KeyOptions
+	Environment      string
+	OutFilePath      string
+	TimestampServers []string
+}
+
+func (po *PromoteOptions) AddFlags(cmd *cobra.Command) {
+	po.VerifyOptions.AddFlags(cmd)
+	// the verify flags already use -k and -i, so the signing key's flags are prefixed
+	cmd.Flags().StringVar(&po.KeyOptions.KeyPath, "signing-key", "", "Path to the key to sign the promotion attestation with")
+	cmd.Flags().StringVar(&po.KeyOptions.CertPath, "signing-certificate", "", "Path to the signing key's certificate")
+	cmd.Flags().StringSliceVar(&po.KeyOptions.IntermediatePaths, "signing-intermediates", []string{}, "Intermediates that link trust in the signing key back to a root of trust")
+	cmd.Flags().StringVar(&po.Environment, "environment", "", "Name of the environment the artifact is promoted to, such as staging or prod")
+	cmd.Flags().StringVarP(&po.OutFilePath, "outfile", "o", "", "File to write the signed promotion attestation to. Defaults to stdout")
+	cmd.Flags().StringSliceVar(&po.TimestampServers, "timestamp-servers", []string{}, "Timestamp Authority Servers to use when signing the promotion attestation")
+}
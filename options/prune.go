@@ -0,0 +1,31 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import "github.com/spf13/cobra"
+
+type PruneOptions struct {
+	SpoolOptions    SpoolOptions
+	CacheOptions    CacheOptions
+	AttestationDirs []string
+	DryRun          bool
+}
+
+func (o *PruneOptions) AddFlags(cmd *cobra.Command) {
+	o.SpoolOptions.AddFlags(cmd)
+	o.CacheOptions.AddFlags(cmd)
+	cmd.Flags().StringSliceVar(&o.AttestationDirs, "attestation-dir", []string{}, "Directories of signed attestation collections to remove expired envelopes from, using the expires-at annotation recorded by witness run --retention")
+	cmd.Flags().BoolVar(&o.DryRun, "dry-run", false, "List what would be removed without removing anything")
+}
@@ -0,0 +1,27 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import "github.com/spf13/cobra"
+
+type RekorOptions struct {
+	Server        string
+	PublicKeyPath string
+}
+
+func (o *RekorOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.Server, "rekor-server", "", "URL of a Rekor transparency log to upload the signed attestation to, with the public key or certificate that signed it. Uploading happens during the run, even with --async-upload")
+	cmd.Flags().StringVar(&o.PublicKeyPath, "rekor-public-key", "", "Path to the public key of --rekor-server. Required with --rekor-server, since the log's entries are only trusted once they're verified to be signed by it")
+}
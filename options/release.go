@@ -0,0 +1,40 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import "github.com/spf13/cobra"
+
+type ReleaseOptions struct {
+	VerifyOptions    VerifyOptions
+	KeyOptions       KeyOptions
+	Name             string
+	EvidenceURL      string
+	ChecksumsOutPath string
+	OutFilePath      string
+	TimestampServers []string
+}
+
+func (ro *ReleaseOptions) AddFlags(cmd *cobra.Command) {
+	ro.VerifyOptions.AddFlags(cmd)
+	// the verify flags already use -k and -i, so the signing key's flags are prefixed
+	cmd.Flags().StringVar(&ro.KeyOptions.KeyPath, "signing-key", "", "Path to the key to sign the release manifest with")
+	cmd.Flags().StringVar(&ro.KeyOptions.CertPath, "signing-certificate", "", "Path to the signing key's certificate")
+	cmd.Flags().StringSliceVar(&ro.KeyOptions.IntermediatePaths, "signing-intermediates", []string{}, "Intermediates that link trust in the signing key back to a root of trust")
+	cmd.Flags().StringVar(&ro.Name, "name", "", "Name of the release, such as its version or tag")
+	cmd.Flags().StringVar(&ro.EvidenceURL, "evidence-url", "", "URL the attestation files are published at, such as the release's download URL. Evidence from attestation files is linked relative to it")
+	cmd.Flags().StringVar(&ro.ChecksumsOutPath, "write-checksums", "", "File to write a SHA256SUMS checksum file of the release artifacts to, which sha256sum -c can check")
+	cmd.Flags().StringVarP(&ro.OutFilePath, "outfile", "o", "", "File to write the signed release manifest to. Defaults to stdout")
+	cmd.Flags().StringSliceVar(&ro.TimestampServers, "timestamp-servers", []string{}, "Timestamp Authority Servers to use when signing the release manifest")
+}
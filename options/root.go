@@ -19,9 +19,11 @@ import "github.com/spf13/cobra"
 type RootOptions struct {
 	Config   string
 	LogLevel string
+	FIPS     bool
 }
 
 func (ro *RootOptions) AddFlags(cmd *cobra.Command) {
 	cmd.PersistentFlags().StringVarP(&ro.Config, "config", "c", ".witness.yaml", "Path to the witness config file")
 	cmd.PersistentFlags().StringVarP(&ro.LogLevel, "log-level", "l", "info", "Level of logging to output (debug, info, warn, error)")
+	cmd.PersistentFlags().BoolVar(&ro.FIPS, "fips", false, "Only allow ECDSA with P-256 or P-384, RSA with at least 3072 bit keys, and certificates signed with SHA-2. Keys, certificates, and policies that use anything else are rejected")
 }
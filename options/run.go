@@ -14,36 +14,153 @@
 
 package options
 
-import "github.com/spf13/cobra"
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+)
 
 type RunOptions struct {
-	KeyOptions       KeyOptions
-	ArchivistOptions ArchivistOptions
-	WorkingDir       string
-	Attestations     []string
-	OutFilePath      string
-	StepName         string
-	Tracing          bool
-	TimestampServers []string
+	KeyOptions           KeyOptions
+	ArchivistOptions     ArchivistOptions
+	GitHubOptions        GitHubOptions
+	SCITTOptions         SCITTOptions
+	RekorOptions         RekorOptions
+	SpoolOptions         SpoolOptions
+	LocalStoreOptions    LocalStoreOptions
+	NotifyOptions        NotifyOptions
+	EphemeralSigner      bool
+	WorkingDir           string
+	Attestations         []string
+	OutFilePath          string
+	StepName             string
+	Tracing              bool
+	TraceBackend         string
+	TraceSampleRate      int
+	TraceMaxFiles        int
+	HashWorkers          int
+	Gitignore            bool
+	HashCacheDir         string
+	Symlinks             string
+	EscapingSymlinks     string
+	NormalizeLineEndings bool
+	TimestampServers     []string
+	DirSubjects          []string
+	BuildKitDirs         []string
+	PackageAttestDir     string
+	ScaiAttributesPath   string
+	VEXDocuments         []string
+	ChecksumFiles        []string
+	BuildCacheLogs       []string
+	BazelBEPPath         string
+	BazelExecutionLog    string
+	ToolsTraced          bool
+	OIDCAudience         string
+	OIDCTokenEnv         string
+	OIDCTokenFile        string
+	OIDCIssuer           string
+	DetachPredicatePath  string
+	Disclosable          bool
+	CapsulePath          string
+	AttestFromCapsule    string
+	AsyncUpload          bool
+	StoreFailurePolicy   string
+	StoreRequirement     string
+	CIMode               string
+	CIResultsDir         string
+	Annotations          []string
+	Retention            time.Duration
+	Deterministic        bool
+	User                 string
+	Group                string
+	Env                  []string
+	EnvFile              string
+	CleanEnv             bool
+	TTY                  bool
+	InContainer          string
+	ContainerRuntime     string
+	ContainerMounts      []string
+	SARIFPath            string
 }
 
 func (ro *RunOptions) AddFlags(cmd *cobra.Command) {
 	ro.KeyOptions.AddFlags(cmd)
 	ro.ArchivistOptions.AddFlags(cmd)
+	ro.GitHubOptions.AddFlags(cmd)
+	ro.SCITTOptions.AddFlags(cmd)
+	ro.RekorOptions.AddFlags(cmd)
+	ro.SpoolOptions.AddFlags(cmd)
+	ro.LocalStoreOptions.AddFlags(cmd)
+	ro.NotifyOptions.AddFlags(cmd)
+	cmd.Flags().BoolVar(&ro.EphemeralSigner, "signer-ephemeral", false, "Sign with a key generated for this run and discarded afterwards, instead of a key, SPIFFE, or Fulcio signer. The envelope and public key are uploaded to --rekor-server, whose entry is what binds the key to the attestation")
 	cmd.Flags().StringVarP(&ro.WorkingDir, "workingdir", "d", "", "Directory from which commands will run")
-	cmd.Flags().StringSliceVarP(&ro.Attestations, "attestations", "a", []string{"environment", "git"}, "Attestations to record")
+	cmd.Flags().StringSliceVarP(&ro.Attestations, "attestations", "a", []string{"environment", "git"}, "Attestations to record, optionally with :pre or :post to set when they run (e.g. git:pre,environment:post)")
 	cmd.Flags().StringVarP(&ro.OutFilePath, "outfile", "o", "", "File to which to write signed data.  Defaults to stdout")
 	cmd.Flags().StringVarP(&ro.StepName, "step", "s", "", "Name of the step being run")
 	cmd.Flags().BoolVar(&ro.Tracing, "trace", false, "Enable tracing for the command")
+	cmd.Flags().StringVar(&ro.TraceBackend, "trace-backend", "ptrace", "How the command is traced with --trace: ptrace, or ebpf for lower overhead and network connections on Linux, falling back to ptrace when eBPF is unavailable")
+	cmd.Flags().IntVar(&ro.TraceSampleRate, "trace-sample-rate", 1, "Record one in every n file opens with --trace-backend ebpf, chosen at random, to reduce the cost of tracing builds that open many files. Processes and connections are always recorded")
+	cmd.Flags().IntVar(&ro.TraceMaxFiles, "trace-max-files", 0, "Stop recording file opens with --trace-backend ebpf once this many files have been recorded. Unlimited if 0")
+	cmd.Flags().IntVar(&ro.HashWorkers, "hash-workers", 0, "Number of files to hash at once when recording materials and products. Defaults to the number of CPUs")
+	cmd.Flags().BoolVar(&ro.Gitignore, "gitignore", false, "Skip files matched by .gitignore files when recording materials and products. Files matched by .witnessignore files are always skipped")
+	cmd.Flags().StringVar(&ro.HashCacheDir, "hash-cache-dir", "", "Directory to cache file digests in, so later runs in the same working directory only hash files whose size or modification time changed. Caching is disabled if empty")
+	cmd.Flags().StringVar(&ro.Symlinks, "symlinks", "follow", "How symlinks are recorded as materials and products: follow them and record what they point to, record the digest of their target path, or skip them")
+	cmd.Flags().StringVar(&ro.EscapingSymlinks, "escaping-symlinks", "record", "What to do with followed symlinks that point outside the working directory: record the digest of their target path, follow them, or error")
+	cmd.Flags().BoolVar(&ro.NormalizeLineEndings, "normalize-line-endings", false, "Hash text files as if their CRLF line endings were LF when recording materials and products, so digests match across Windows and POSIX checkouts")
 	cmd.Flags().StringSliceVar(&ro.TimestampServers, "timestamp-servers", []string{}, "Timestamp Authority Servers to use when signing envelope")
+	cmd.Flags().StringSliceVar(&ro.DirSubjects, "dir-subjects", []string{}, "Directories, relative to the working directory, to record as subjects using a deterministic tree hash")
+	cmd.Flags().StringSliceVar(&ro.BuildKitDirs, "buildkit-dir", []string{}, "Local exporter output directories, relative to the working directory, to import BuildKit provenance and SBOM attestations from")
+	cmd.Flags().StringVar(&ro.PackageAttestDir, "package-attestations", "", "Directory to write signed npm and PyPI publish attestations to for packages the command builds. Enables the packages attestor")
+	cmd.Flags().StringVar(&ro.ScaiAttributesPath, "scai-attributes", "", "SCAI attribute report, relative to the working directory, written by the command with assertions to record in the scai attestation. Enables the scai attestor")
+	cmd.Flags().StringSliceVar(&ro.VEXDocuments, "vex", []string{}, "OpenVEX or CSAF VEX documents, relative to the working directory, to record in the vex attestation along with VEX documents the command writes. Enables the vex attestor")
+	cmd.Flags().StringSliceVar(&ro.ChecksumFiles, "checksums", []string{}, "Checksum files such as SHA256SUMS, relative to the working directory, to record in the checksums attestation along with checksum files the command writes. Every file they list becomes a subject. Enables the checksums attestor")
+	cmd.Flags().StringSliceVar(&ro.BuildCacheLogs, "build-cache-log", []string{}, "Build tool logs, relative to the working directory, to read remote and local cache hits from as format=path. Formats: bazel for a --execution_log_json_file log, gradle for plain console output with -Dorg.gradle.caching.debug=true, or json for a stream of build-cache hits. Enables the build-cache attestor")
+	cmd.Flags().StringVar(&ro.BazelBEPPath, "attestor-bazel-bep", "", "Build event protocol stream written by bazel with --build_event_json_file, relative to the working directory, to record the invocation and the targets it built. Enables the bazel attestor")
+	cmd.Flags().StringVar(&ro.BazelExecutionLog, "attestor-bazel-execution-log", "", "Execution log written by bazel with --execution_log_json_file, relative to the working directory, to record the actions bazel ran with their inputs and outputs. Enables the bazel attestor")
+	cmd.Flags().BoolVar(&ro.ToolsTraced, "attestor-tools-traced", false, "Record the path and digest of every executable the command starts under --trace in the tools attestation, not only the command's. Enables the tools attestor")
+	cmd.Flags().StringVar(&ro.OIDCAudience, "attestor-oidc-audience", "witness", "Audience the oidc attestor requests the workload's identity token for, and that tokens read with --attestor-oidc-token-env or --attestor-oidc-token-file must be issued to")
+	cmd.Flags().StringVar(&ro.OIDCTokenEnv, "attestor-oidc-token-env", "", "Environment variable to read the workload's identity token from, such as one set by a GitLab job's id_tokens, instead of requesting one from the CI system. Enables the oidc attestor")
+	cmd.Flags().StringVar(&ro.OIDCTokenFile, "attestor-oidc-token-file", "", "File to read the workload's identity token from, such as a projected Kubernetes service account token, instead of requesting one from the CI system. Enables the oidc attestor")
+	cmd.Flags().StringVar(&ro.OIDCIssuer, "attestor-oidc-issuer", "", "Issuer the workload's identity token must be issued by. Required with --attestor-oidc-token-env or --attestor-oidc-token-file. Defaults to the CI system's issuer for tokens requested from it")
+	cmd.Flags().StringVar(&ro.DetachPredicatePath, "detach-predicate", "", "Path to write the attestation collection to, signing only its digest, so the signed envelope can be published without the collection's content. witness verify needs the collection back with --detached-predicates")
+	cmd.Flags().BoolVar(&ro.Disclosable, "disclosable", false, "With --detach-predicate, write a disclosure bundle instead of the collection and sign the Merkle root of its fields, so fields can be disclosed individually with witness disclose")
+	cmd.Flags().StringVar(&ro.CapsulePath, "capsule", "", "Path to write a tarball of the unsigned attestor output for debugging or re-signing")
+	cmd.Flags().StringVar(&ro.AttestFromCapsule, "attest-from-capsule", "", "Sign a capsule previously written with --capsule instead of running attestors")
+	cmd.Flags().BoolVar(&ro.AsyncUpload, "async-upload", false, "Queue uploads in the spool directory instead of uploading during the run. Queued uploads are sent by witness flush")
+	cmd.Flags().StringVar(&ro.StoreFailurePolicy, "store-failure-policy", "fail", "What to do when uploading the envelope fails: fail the run, warn and continue, or retry-later by queueing the upload for witness flush")
+	cmd.Flags().StringVar(&ro.StoreRequirement, "store-require", "all", "How many of the Archivist servers and other stores the envelope is uploaded to must succeed: all, any, or a quorum of more than half. Uploads that fail when the requirement is met only warn, or are queued with --store-failure-policy retry-later")
+	cmd.Flags().StringVar(&ro.CIMode, "ci-mode", "", "Write the location and digest of the signed attestation as results for a CI system. Supported: tekton")
+	cmd.Flags().StringVar(&ro.CIResultsDir, "ci-results-dir", "", "Directory to write CI results to. Defaults to /tekton/results in tekton mode")
+	cmd.Flags().StringSliceVar(&ro.Annotations, "annotation", []string{}, "Annotations to record in the attestation collection as key=value, such as project, environment, or cost center. Each annotation is also added as a subject so stored attestations can be searched by it")
+	cmd.Flags().DurationVar(&ro.Retention, "retention", 0, "How long the attestation should be kept. Recorded as an expires-at annotation so stores can apply retention policies, and lets witness prune remove the envelope locally once it expires")
+	cmd.Flags().BoolVar(&ro.Deterministic, "deterministic", false, "Fix timestamps, hostnames, process IDs, and the order of attestations so the collection is reproducible across runs, for golden-file testing of attestors and policies. Timestamps are taken from SOURCE_DATE_EPOCH, or the Unix epoch if it isn't set")
+	cmd.Flags().StringVar(&ro.User, "user", "", "User name or ID to run the command as, so witness can keep the privileges it needs for tracing while the command doesn't. Recorded by the run-as attestor. Linux only")
+	cmd.Flags().StringVar(&ro.Group, "group", "", "Group name or ID to run the command as. Defaults to the primary group of --user")
+	cmd.Flags().StringArrayVar(&ro.Env, "env", []string{}, "Environment variable to set for the command as KEY=VALUE, overriding witness's environment and --env-file. Doesn't change the environment of witness itself")
+	cmd.Flags().StringVar(&ro.EnvFile, "env-file", "", "File of KEY=VALUE environment variables to set for the command, one per line")
+	cmd.Flags().BoolVar(&ro.CleanEnv, "clean-env", false, "Start the command from an empty environment, with only the variables set by --env and --env-file, for hermetic steps")
+	cmd.Flags().BoolVar(&ro.TTY, "tty", false, "Run the command under a pseudo-terminal, so tools that check for a terminal print colors and progress bars, or run at all. The command's output is still recorded, but stderr is merged into stdout and recorded empty. Input is forwarded from witness' terminal if it has one. Linux only")
+	cmd.Flags().StringVar(&ro.SARIFPath, "sarif-out", "", "File to write a SARIF log to, with a finding if the run fails, so code scanning dashboards show failed steps")
+	cmd.Flags().StringVar(&ro.InContainer, "in-container", "", "Container image to run the command in, with the working directory mounted at the same path. The image's digest, mounts, and entrypoint are recorded by the container-exec attestor and the image is recorded as a material")
+	cmd.Flags().StringVar(&ro.ContainerRuntime, "container-runtime", "docker", "Docker compatible CLI that runs the command with --in-container, such as docker, podman, or nerdctl")
+	cmd.Flags().StringArrayVar(&ro.ContainerMounts, "container-mount", []string{}, "Directory to mount into the container with --in-container as source:destination, optionally followed by :ro")
 }
 
 type ArchivistOptions struct {
 	Enable bool
-	Url    string
+	Urls   []string
 }
 
 func (o *ArchivistOptions) AddFlags(cmd *cobra.Command) {
 	cmd.Flags().BoolVar(&o.Enable, "enable-archivist", false, "Use Archivist to store or retrieve attestations")
-	cmd.Flags().StringVar(&o.Url, "archivist-server", "https://archivist.testifysec.io", "URL of the Archivist server to store or retrieve attestations")
+	cmd.Flags().StringArrayVar(&o.Urls, "archivist-server", []string{"https://archivist.testifysec.io"}, "URL of the Archivist server to store or retrieve attestations, or unix:///path/to/socket to connect over a Unix domain socket. Repeat to store attestations in every server or search every server for them")
+}
+
+// Url returns the first Archivist server, for commands that read from a single server
+func (o ArchivistOptions) Url() string {
+	if len(o.Urls) == 0 {
+		return ""
+	}
+
+	return o.Urls[0]
 }
@@ -0,0 +1,29 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import "github.com/spf13/cobra"
+
+type SCITTOptions struct {
+	Server        string
+	Issuer        string
+	StatementPath string
+}
+
+func (o *SCITTOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.Server, "scitt-server", "", "URL of a SCITT transparency service to register the signed attestation with, as a COSE signed statement signed with --key. Registration happens during the run, even with --async-upload")
+	cmd.Flags().StringVar(&o.Issuer, "scitt-issuer", "", "Issuer of SCITT signed statements. Defaults to the ID of the signing key")
+	cmd.Flags().StringVar(&o.StatementPath, "scitt-statement", "", "File to write the transparent statement to, which is the signed statement with the receipt of its registration. Defaults to the out file with a .scitt extension")
+}
@@ -0,0 +1,48 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/testifysec/witness/pkg/selfupdate"
+)
+
+type SelfUpdateOptions struct {
+	ArchivistOptions     ArchivistOptions
+	Version              string
+	ReleaseURL           string
+	InstallPath          string
+	KeyPath              string
+	CAPaths              []string
+	PolicyFilePath       string
+	AttestationFilePaths []string
+	AdditionalSubjects   []string
+	Force                bool
+	DryRun               bool
+}
+
+func (o *SelfUpdateOptions) AddFlags(cmd *cobra.Command) {
+	o.ArchivistOptions.AddFlags(cmd)
+	cmd.Flags().StringVar(&o.Version, "version", "", "Release tag to install. Defaults to the latest release")
+	cmd.Flags().StringVar(&o.ReleaseURL, "release-url", selfupdate.DefaultReleaseURL, "URL of the releases page to download witness from")
+	cmd.Flags().StringVar(&o.InstallPath, "install-path", "", "Path to install witness to. Defaults to the running witness binary")
+	cmd.Flags().StringVarP(&o.KeyPath, "publickey", "k", "", "Path to the public key of the release policy's signer")
+	cmd.Flags().StringSliceVar(&o.CAPaths, "policy-ca", []string{}, "Paths to CA certificates to use for verifying the release policy")
+	cmd.Flags().StringVarP(&o.PolicyFilePath, "policy", "p", "", "Path to the signed policy the release must satisfy")
+	cmd.Flags().StringSliceVarP(&o.AttestationFilePaths, "attestations", "a", []string{}, "Attestation files for the release. Attestations are also looked up in Archivist if it's enabled, and one of the two is required")
+	cmd.Flags().StringSliceVarP(&o.AdditionalSubjects, "subjects", "s", []string{}, "Additional subjects to lookup attestations, such as the digest of the release's git commit")
+	cmd.Flags().BoolVar(&o.Force, "force", false, "Install the release even if it's the version already running")
+	cmd.Flags().BoolVar(&o.DryRun, "dry-run", false, "Download and verify the release without installing it")
+}
@@ -22,6 +22,8 @@ type SignOptions struct {
 	OutFilePath      string
 	InFilePath       string
 	TimestampServers []string
+	EnvelopeFormat   string
+	MediaType        string
 }
 
 func (so *SignOptions) AddFlags(cmd *cobra.Command) {
@@ -30,4 +32,6 @@ func (so *SignOptions) AddFlags(cmd *cobra.Command) {
 	cmd.Flags().StringVarP(&so.OutFilePath, "outfile", "o", "", "File to write signed data. Defaults to stdout")
 	cmd.Flags().StringVarP(&so.InFilePath, "infile", "f", "", "Witness policy file to sign")
 	cmd.Flags().StringSliceVar(&so.TimestampServers, "timestamp-servers", []string{}, "Timestamp Authority Servers to use when signing envelope")
+	cmd.Flags().StringVar(&so.EnvelopeFormat, "envelope-format", "dsse", "Format of the signature envelope: dsse, cose for a COSE_Sign1 message, or notation-jws or notation-cose to sign the file as an OCI artifact the way notation does. COSE and notation envelopes require --key, and notation envelopes --certificate")
+	cmd.Flags().StringVar(&so.MediaType, "media-type", "application/vnd.oci.image.manifest.v1+json", "Media type of the file recorded in notation signatures")
 }
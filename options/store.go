@@ -0,0 +1,65 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import "github.com/spf13/cobra"
+
+type StoreDirOptions struct {
+	Dir string
+}
+
+func (o *StoreDirOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.Dir, "store-dir", "", "Directory of the local attestation store. Defaults to .witness/store in the user's home directory")
+}
+
+type LocalStoreOptions struct {
+	Enable          bool
+	StoreDirOptions StoreDirOptions
+}
+
+func (o *LocalStoreOptions) AddFlags(cmd *cobra.Command) {
+	o.StoreDirOptions.AddFlags(cmd)
+	cmd.Flags().BoolVar(&o.Enable, "local-store", false, "Use the local attestation store to save or retrieve attestations")
+}
+
+type StoreListOptions struct {
+	StoreDirOptions StoreDirOptions
+	Subject         string
+	Output          string
+}
+
+func (o *StoreListOptions) AddFlags(cmd *cobra.Command) {
+	o.StoreDirOptions.AddFlags(cmd)
+	cmd.Flags().StringVar(&o.Subject, "subject", "", "Only list envelopes with a subject that has this digest, such as sha256:abc123")
+	cmd.Flags().StringVarP(&o.Output, "output", "o", "text", "Output format, text or json")
+}
+
+type StoreGetOptions struct {
+	StoreDirOptions StoreDirOptions
+	OutFilePath     string
+}
+
+func (o *StoreGetOptions) AddFlags(cmd *cobra.Command) {
+	o.StoreDirOptions.AddFlags(cmd)
+	cmd.Flags().StringVarP(&o.OutFilePath, "outfile", "o", "", "File to write the envelope to. Defaults to stdout")
+}
+
+type StoreAddOptions struct {
+	StoreDirOptions StoreDirOptions
+}
+
+func (o *StoreAddOptions) AddFlags(cmd *cobra.Command) {
+	o.StoreDirOptions.AddFlags(cmd)
+}
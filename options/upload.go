@@ -0,0 +1,35 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import "github.com/spf13/cobra"
+
+type UploadOptions struct {
+	ArchivistServer string
+	StateFilePath   string
+	ResumePath      string
+	Retries         int
+	Concurrency     int
+	ManifestPath    string
+}
+
+func (uo *UploadOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&uo.ArchivistServer, "archivist-server", "https://archivist.testifysec.io", "URL of the Archivist server to store attestations, or unix:///path/to/socket to connect over a Unix domain socket")
+	cmd.Flags().StringVar(&uo.StateFilePath, "state-file", "", "Path to record upload progress so a failed upload can be resumed")
+	cmd.Flags().StringVar(&uo.ResumePath, "resume", "", "Resume the upload recorded in the provided state file, retrying only envelopes that have not been stored")
+	cmd.Flags().IntVar(&uo.Retries, "retries", 3, "Number of times to retry each envelope before giving up")
+	cmd.Flags().IntVar(&uo.Concurrency, "concurrency", 4, "Number of envelopes to upload at once")
+	cmd.Flags().StringVar(&uo.ManifestPath, "manifest", "", "Path to write the gitoid and path of every stored envelope to, one per line")
+}
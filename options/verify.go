@@ -14,25 +14,99 @@
 
 package options
 
-import "github.com/spf13/cobra"
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+)
 
 type VerifyOptions struct {
-	ArchivistOptions     ArchivistOptions
-	KeyPath              string
-	AttestationFilePaths []string
-	PolicyFilePath       string
-	ArtifactFilePath     string
-	AdditionalSubjects   []string
-	CAPaths              []string
+	ArchivistOptions       ArchivistOptions
+	LocalStoreOptions      LocalStoreOptions
+	CacheOptions           CacheOptions
+	NotifyOptions          NotifyOptions
+	DecisionLogOptions     DecisionLogOptions
+	ExportOptions          ExportOptions
+	KeyPath                string
+	AttestationFilePaths   []string
+	PolicyFilePath         string
+	ArtifactFilePath       string
+	ArtifactListPath       string
+	SubjectsFilePath       string
+	ArtifactRef            string
+	AdditionalSubjects     []string
+	SubjectPURLs           []string
+	CAPaths                []string
+	Concurrency            int
+	ValidateSchemas        bool
+	RevocationList         string
+	RevocationKeyPath      string
+	PQKeyPaths             []string
+	ChecksumsFilePath      string
+	SCITTStatementPaths    []string
+	SCITTServiceKeyPath    string
+	RekorServer            string
+	RekorPublicKeyPath     string
+	DetachedPredicatePaths []string
+	ReceiptOptions         ReceiptOptions
+	Output                 string
 }
 
 func (vo *VerifyOptions) AddFlags(cmd *cobra.Command) {
 	vo.ArchivistOptions.AddFlags(cmd)
+	vo.LocalStoreOptions.AddFlags(cmd)
+	vo.CacheOptions.AddFlags(cmd)
+	vo.NotifyOptions.AddFlags(cmd)
+	vo.DecisionLogOptions.AddFlags(cmd)
+	vo.ExportOptions.AddFlags(cmd)
 	cmd.Flags().StringVarP(&vo.KeyPath, "publickey", "k", "", "Path to the policy signer's public key")
 	cmd.Flags().StringSliceVarP(&vo.AttestationFilePaths, "attestations", "a", []string{}, "Attestation files to test against the policy")
 	cmd.Flags().StringVarP(&vo.PolicyFilePath, "policy", "p", "", "Path to the policy to verify")
-	cmd.Flags().StringVarP(&vo.ArtifactFilePath, "artifactfile", "f", "", "Path to the artifact to verify")
+	cmd.Flags().StringVarP(&vo.ArtifactFilePath, "artifactfile", "f", "", "Path to the artifact to verify. May be a glob pattern to verify multiple artifacts, or - to hash an artifact streamed over stdin without writing it to disk")
+	cmd.Flags().StringVar(&vo.ArtifactListPath, "artifact-list", "", "Path to a file of newline delimited artifact paths to verify, or - to read them from stdin")
+	cmd.Flags().StringVar(&vo.SubjectsFilePath, "subjects-file", "", "Path to a file of subject digests to verify one by one, or - to read them from stdin. Each line is a sha256 digest followed by an optional name, such as the image it's the digest of")
+	cmd.Flags().StringVar(&vo.ArtifactRef, "artifact-ref", "", "Reference to a remote artifact to download and verify (oci://registry/repository:tag or https://...)")
 	cmd.Flags().StringSliceVarP(&vo.AdditionalSubjects, "subjects", "s", []string{}, "Additional subjects to lookup attestations")
+	cmd.Flags().StringSliceVar(&vo.SubjectPURLs, "subject-purl", []string{}, "Package URLs, such as pkg:npm/name@1.0.0, to verify. Digests are looked up from subjects named by matching purls in the attestation files")
 	cmd.Flags().StringSliceVarP(&vo.CAPaths, "policy-ca", "", []string{}, "Paths to CA certificates to use for verifying the policy")
+	cmd.Flags().StringVar(&vo.Output, "verify-output", "", "Format to write the result of each artifact or subject to stdout in: table, json, sarif for code scanning dashboards, or junit for CI test reports. Defaults to a table when more than one is verified")
+	cmd.Flags().IntVar(&vo.Concurrency, "concurrency", 0, "Number of artifacts to digest and verify in parallel. Defaults to the number of CPUs")
+	cmd.Flags().BoolVar(&vo.ValidateSchemas, "validate-schemas", true, "Fail verification if an attestation doesn't match its attestor's published schema")
+	cmd.Flags().StringVar(&vo.RevocationList, "revocation-list", "", "Path or URL of a signed list of revoked attestations to reject during verification")
+	cmd.Flags().StringVar(&vo.RevocationKeyPath, "revocation-list-key", "", "Path to the public key that signed the revocation list. Defaults to the policy signer's public key")
+	cmd.Flags().StringSliceVar(&vo.PQKeyPaths, "pq-publickey", []string{}, "Paths to Dilithium public keys. If set, the policy and every collection must also carry a post-quantum signature from one of these keys")
+	cmd.Flags().StringVar(&vo.ChecksumsFilePath, "checksums", "", "Checksum file such as SHA256SUMS that each artifact's digest must match. The checksum file is also looked up as a subject, so a collection attesting it satisfies the policy for the files it lists")
+	cmd.Flags().StringSliceVar(&vo.DetachedPredicatePaths, "detached-predicates", []string{}, "Collections written by witness run --detach-predicate, or disclosure bundles written with --disclosable, to bind to the attestations that signed only their digests")
+	cmd.Flags().StringSliceVar(&vo.SCITTStatementPaths, "scitt-statements", []string{}, "Transparent statements written by witness run --scitt-server to test against the policy. Each must carry a receipt from the transparency service with --scitt-service-key")
+	cmd.Flags().StringVar(&vo.SCITTServiceKeyPath, "scitt-service-key", "", "Path to the public key of the SCITT transparency service whose receipts are trusted")
+	cmd.Flags().StringVar(&vo.RekorServer, "rekor-server", "", "URL of a Rekor transparency log that every attestation file must have an entry in, signed by --rekor-public-key, whose public key signed the attestation")
+	cmd.Flags().StringVar(&vo.RekorPublicKeyPath, "rekor-public-key", "", "Path to the public key of --rekor-server")
+}
+
+// ReceiptOptions configure verification receipts. They're only added to witness verify, since commands that sign
+// their own attestation after verifying don't hand off to other verifiers.
+type ReceiptOptions struct {
+	OutPath       string
+	KeyPath       string
+	Path          string
+	PublicKeyPath string
+	MaxAge        time.Duration
+}
+
+func (o *ReceiptOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.OutPath, "receipt-out", "", "File to write a signed verification receipt to when verification succeeds, binding the verified artifacts' digests to the policy's digest and the time")
+	cmd.Flags().StringVar(&o.KeyPath, "receipt-signing-key", "", "Path to the key to sign verification receipts with")
+	cmd.Flags().StringVar(&o.Path, "receipt", "", "Verification receipt from a trusted verifier to accept instead of verifying the policy. The artifacts must be among the receipt's subjects")
+	cmd.Flags().StringVar(&o.PublicKeyPath, "receipt-publickey", "", "Path to the public key of the verifier whose receipts are trusted")
+	cmd.Flags().DurationVar(&o.MaxAge, "receipt-max-age", 24*time.Hour, "Oldest verification receipt to accept")
+}
+
+type CacheOptions struct {
+	Dir string
+	TTL time.Duration
+}
 
+func (o *CacheOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.Dir, "cache-dir", "", "Directory to cache attestations fetched from remote services, and the digests of artifact references pinned to an OCI digest. Caching is disabled if empty")
+	cmd.Flags().DurationVar(&o.TTL, "cache-ttl", time.Hour, "How long cached entries are used before they are fetched again")
 }
@@ -0,0 +1,41 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import "github.com/spf13/cobra"
+
+type WaiveOptions struct {
+	KeyOptions       KeyOptions
+	ArchivistOptions ArchivistOptions
+	Subjects         []string
+	Step             string
+	Attestation      string
+	Reason           string
+	Expires          string
+	OutFilePath      string
+	TimestampServers []string
+}
+
+func (wo *WaiveOptions) AddFlags(cmd *cobra.Command) {
+	wo.KeyOptions.AddFlags(cmd)
+	wo.ArchivistOptions.AddFlags(cmd)
+	cmd.Flags().StringSliceVarP(&wo.Subjects, "subject", "s", []string{}, "Digest of an artifact the waiver applies to, such as sha256:abc123. May be given more than once")
+	cmd.Flags().StringVar(&wo.Step, "step", "", "Policy step the waiver exempts")
+	cmd.Flags().StringVar(&wo.Attestation, "attestation", "", "Attestation type required by the step to exempt, instead of the whole step")
+	cmd.Flags().StringVar(&wo.Reason, "reason", "", "Why the constraint is waived, such as an exception ticket")
+	cmd.Flags().StringVar(&wo.Expires, "expires", "", "When the waiver stops applying, as an RFC 3339 time or a duration from now such as 72h")
+	cmd.Flags().StringVarP(&wo.OutFilePath, "outfile", "o", "", "File to write the signed waiver to. Defaults to stdout")
+	cmd.Flags().StringSliceVar(&wo.TimestampServers, "timestamp-servers", []string{}, "Timestamp Authority Servers to use when signing the waiver")
+}
@@ -0,0 +1,35 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type WatchOptions struct {
+	RunOptions   RunOptions
+	Paths        []string
+	OutDir       string
+	PollInterval time.Duration
+}
+
+func (wo *WatchOptions) AddFlags(cmd *cobra.Command) {
+	wo.RunOptions.AddFlags(cmd)
+	cmd.Flags().StringSliceVar(&wo.Paths, "watch", []string{}, "Files or directories to watch for changes. Defaults to the working directory")
+	cmd.Flags().StringVar(&wo.OutDir, "out-dir", "", "Directory to write the signed attestation of each run to, named by step and sequence number")
+	cmd.Flags().DurationVar(&wo.PollInterval, "poll-interval", time.Second, "How often watched files are checked for changes")
+}
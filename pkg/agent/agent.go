@@ -0,0 +1,169 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package agent lets an orchestrator dispatch witness runs to agents on build machines over gRPC. The agent runs
+// the command, records and signs its attestations, and streams the signed envelope back.
+//
+// There's no protobuf definition for the service. Messages are JSON encoded, so clients must use the json content
+// subtype, sending requests with the content type application/grpc+json. Client does this for Go orchestrators.
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/testifysec/go-witness/dsse"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+)
+
+const (
+	// ServiceName is the full name of the agent's gRPC service
+	ServiceName = "witness.agent.v1.Agent"
+	// RunMethod is the full name of the streaming method that runs a command
+	RunMethod = "/" + ServiceName + "/Run"
+
+	codecName = "json"
+)
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+// jsonCodec encodes the service's messages as JSON
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) Name() string {
+	return codecName
+}
+
+// RunRequest asks an agent to run a command as a step
+type RunRequest struct {
+	Step string   `json:"step"`
+	Args []string `json:"args"`
+	// WorkingDir is the directory the command runs in, relative to the agent's working directory
+	WorkingDir string `json:"workingDir,omitempty"`
+	// Attestations are the attestors to run in addition to the agent's
+	Attestations []string `json:"attestations,omitempty"`
+	// Env are KEY=VALUE environment variables to set for the command
+	Env         []string `json:"env,omitempty"`
+	Annotations []string `json:"annotations,omitempty"`
+	Tracing     bool     `json:"tracing,omitempty"`
+}
+
+// States of a run reported in RunResponse
+const (
+	// StateStarted is sent when the agent starts the command. Agents run one command at a time, so requests wait
+	// for earlier runs to finish before they're started.
+	StateStarted = "started"
+	// StateCompleted is sent with the signed envelope once the command has run and its attestations are signed
+	StateCompleted = "completed"
+)
+
+// RunResponse reports the progress of a run. The run's errors are returned as the stream's status.
+type RunResponse struct {
+	State    string         `json:"state"`
+	Envelope *dsse.Envelope `json:"envelope,omitempty"`
+	// Storage lists where the agent stored the envelope, such as Archivist
+	Storage []string `json:"storage,omitempty"`
+}
+
+// RunFunc runs a request, calling send to report its progress
+type RunFunc func(ctx context.Context, req RunRequest, send func(RunResponse) error) error
+
+// service is the interface registered with gRPC, which only checks that the implementation satisfies it
+type service interface {
+	run(ctx context.Context, req RunRequest, send func(RunResponse) error) error
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*service)(nil),
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Run",
+			Handler:       runHandler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "witness/agent",
+}
+
+func runHandler(srv interface{}, stream grpc.ServerStream) error {
+	var req RunRequest
+	if err := stream.RecvMsg(&req); err != nil {
+		return err
+	}
+
+	return srv.(service).run(stream.Context(), req, func(resp RunResponse) error {
+		return stream.SendMsg(&resp)
+	})
+}
+
+func (f RunFunc) run(ctx context.Context, req RunRequest, send func(RunResponse) error) error {
+	return f(ctx, req, send)
+}
+
+// Register serves the agent service on s, running requests with run
+func Register(s *grpc.Server, run RunFunc) {
+	s.RegisterService(&serviceDesc, run)
+}
+
+// Client dispatches runs to an agent
+type Client struct {
+	conn *grpc.ClientConn
+}
+
+func NewClient(conn *grpc.ClientConn) *Client {
+	return &Client{conn: conn}
+}
+
+// Run asks the agent to run req, calling handle with each response it streams back
+func (c *Client) Run(ctx context.Context, req RunRequest, handle func(RunResponse) error) error {
+	stream, err := c.conn.NewStream(ctx, &serviceDesc.Streams[0], RunMethod, grpc.CallContentSubtype(codecName))
+	if err != nil {
+		return err
+	}
+
+	if err := stream.SendMsg(&req); err != nil {
+		return err
+	}
+
+	if err := stream.CloseSend(); err != nil {
+		return err
+	}
+
+	for {
+		var resp RunResponse
+		if err := stream.RecvMsg(&resp); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if err := handle(resp); err != nil {
+			return err
+		}
+	}
+}
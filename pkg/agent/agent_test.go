@@ -0,0 +1,75 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/dsse"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+func TestRun(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := grpc.NewServer()
+	Register(s, func(ctx context.Context, req RunRequest, send func(RunResponse) error) error {
+		if req.Step == "" {
+			return status.Error(codes.InvalidArgument, "a step is required")
+		}
+
+		if err := send(RunResponse{State: StateStarted}); err != nil {
+			return err
+		}
+
+		return send(RunResponse{State: StateCompleted, Envelope: &dsse.Envelope{PayloadType: req.Step + ":" + req.Args[0]}})
+	})
+
+	go s.Serve(lis)
+	defer s.Stop()
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := NewClient(conn)
+
+	var responses []RunResponse
+	require.NoError(t, client.Run(context.Background(), RunRequest{Step: "build", Args: []string{"make"}}, func(resp RunResponse) error {
+		responses = append(responses, resp)
+		return nil
+	}))
+
+	require.Len(t, responses, 2)
+	assert.Equal(t, StateStarted, responses[0].State)
+	assert.Equal(t, StateCompleted, responses[1].State)
+	require.NotNil(t, responses[1].Envelope)
+	assert.Equal(t, "build:make", responses[1].Envelope.PayloadType)
+
+	err = client.Run(context.Background(), RunRequest{Args: []string{"make"}}, func(RunResponse) error { return nil })
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	err = client.Run(context.Background(), RunRequest{Step: "build", Args: []string{"make"}}, func(RunResponse) error {
+		return fmt.Errorf("stop")
+	})
+	assert.EqualError(t, err, "stop")
+}
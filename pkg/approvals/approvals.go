@@ -0,0 +1,155 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package approvals enforces the approvals a policy requires before an artifact is trusted. A policy may name
+// groups of functionaries and require a number of distinct members of each group to have signed an approval
+// of the artifact with witness approve.
+package approvals
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/go-witness/policy"
+	"github.com/testifysec/go-witness/source"
+	"github.com/testifysec/witness/attestation/approval"
+	"github.com/testifysec/witness/pkg/trust"
+)
+
+// Group is a set of functionaries, a number of whom must approve an artifact
+type Group struct {
+	Required      int                  `json:"required"`
+	Functionaries []policy.Functionary `json:"functionaries"`
+}
+
+// policyApprovals is the subset of a witness policy that declares approval groups. approvals isn't part of
+// the go-witness policy type, so it's read separately from the same policy document.
+type policyApprovals struct {
+	Approvals map[string]Group `json:"approvals,omitempty"`
+}
+
+// ErrInsufficientApprovals is returned when an approval group has fewer distinct approvers than it requires
+type ErrInsufficientApprovals struct {
+	Group    string
+	Required int
+	Found    int
+}
+
+func (e ErrInsufficientApprovals) Error() string {
+	return fmt.Sprintf("approval group %v requires %d approvals, found %d", e.Group, e.Required, e.Found)
+}
+
+// Requirements checks artifacts against the approval groups of a policy
+type Requirements struct {
+	groups  map[string]Group
+	trusted trust.Policy
+}
+
+// FromPolicy reads the approval groups from a policy document. A nil Requirements is returned if the policy
+// doesn't require any approvals.
+func FromPolicy(policyBytes []byte) (*Requirements, error) {
+	p := policyApprovals{}
+	if err := json.Unmarshal(policyBytes, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse policy: %w", err)
+	}
+
+	if len(p.Approvals) == 0 {
+		return nil, nil
+	}
+
+	for name, group := range p.Approvals {
+		if group.Required < 1 {
+			return nil, fmt.Errorf("approval group %v must require at least one approval", name)
+		}
+
+		if len(group.Functionaries) < group.Required {
+			return nil, fmt.Errorf("approval group %v requires %d approvals but only has %d functionaries", name, group.Required, len(group.Functionaries))
+		}
+	}
+
+	pol := policy.Policy{}
+	if err := json.Unmarshal(policyBytes, &pol); err != nil {
+		return nil, fmt.Errorf("failed to parse policy: %w", err)
+	}
+
+	trusted, err := trust.FromPolicy(pol)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Requirements{
+		groups:  p.Approvals,
+		trusted: trusted,
+	}, nil
+}
+
+// Check searches src for approvals of any of the subject digests and returns an error if any group has fewer
+// distinct approvers than it requires. Each approver is counted once per group, no matter how many approvals
+// they signed.
+func (r *Requirements) Check(ctx context.Context, src source.Sourcer, subjectDigests []string) error {
+	if r == nil {
+		return nil
+	}
+
+	verified, err := source.NewVerifiedSource(src, r.trusted.VerifyOpts...).Search(ctx, approval.CollectionName, subjectDigests, []string{approval.Type})
+	if err != nil {
+		return fmt.Errorf("failed to search for approvals: %w", err)
+	}
+
+	names := make([]string, 0, len(r.groups))
+	for name := range r.groups {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		group := r.groups[name]
+		approvers := r.approvers(group, verified)
+		if len(approvers) < group.Required {
+			return ErrInsufficientApprovals{Group: name, Required: group.Required, Found: len(approvers)}
+		}
+	}
+
+	return nil
+}
+
+// approvers returns the key IDs of the group's functionaries that signed one of the approvals
+func (r *Requirements) approvers(group Group, approvals []source.VerifiedCollection) map[string]struct{} {
+	approvers := make(map[string]struct{})
+	for _, collection := range approvals {
+		if collection.Statement.PredicateType != attestation.CollectionType {
+			continue
+		}
+
+		for _, verifier := range collection.Verifiers {
+			if !r.trusted.IsFunctionary(group.Functionaries, verifier) {
+				continue
+			}
+
+			keyID, err := verifier.KeyID()
+			if err != nil {
+				log.Debugf("(approvals) skipping verifier: could not get key id: %v", err)
+				continue
+			}
+
+			approvers[keyID] = struct{}{}
+		}
+	}
+
+	return approvers
+}
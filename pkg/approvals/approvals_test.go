@@ -0,0 +1,38 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package approvals
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromPolicy(t *testing.T) {
+	requirements, err := FromPolicy([]byte(`{"steps": {}}`))
+	require.NoError(t, err)
+	assert.Nil(t, requirements)
+
+	_, err = FromPolicy([]byte(`{"approvals": {"release": {"required": 0, "functionaries": [{"publickeyid": "a"}]}}}`))
+	assert.ErrorContains(t, err, "at least one approval")
+
+	_, err = FromPolicy([]byte(`{"approvals": {"release": {"required": 2, "functionaries": [{"publickeyid": "a"}]}}}`))
+	assert.ErrorContains(t, err, "only has 1 functionaries")
+
+	requirements, err = FromPolicy([]byte(`{"approvals": {"release": {"required": 1, "functionaries": [{"publickeyid": "a"}]}}}`))
+	require.NoError(t, err)
+	assert.Equal(t, 1, requirements.groups["release"].Required)
+}
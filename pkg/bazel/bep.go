@@ -0,0 +1,174 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bazel
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+)
+
+// BuildEvent is an event from the Build Event Protocol, as written with --build_event_json_file. Only the events
+// witness records are parsed.
+type BuildEvent struct {
+	ID              BuildEventID     `json:"id"`
+	Started         *BuildStarted    `json:"started,omitempty"`
+	NamedSetOfFiles *NamedSetOfFiles `json:"namedSetOfFiles,omitempty"`
+	Completed       *TargetComplete  `json:"completed,omitempty"`
+	Finished        *BuildFinished   `json:"finished,omitempty"`
+}
+
+// BuildEventID identifies an event. Only the IDs of the events witness records are parsed.
+type BuildEventID struct {
+	TargetCompleted *TargetCompletedID `json:"targetCompleted,omitempty"`
+	NamedSet        *NamedSetID        `json:"namedSet,omitempty"`
+}
+
+type TargetCompletedID struct {
+	Label string `json:"label"`
+}
+
+type NamedSetID struct {
+	ID string `json:"id"`
+}
+
+// BuildStarted is the first event of an invocation
+type BuildStarted struct {
+	UUID             string      `json:"uuid"`
+	StartTimeMillis  json.Number `json:"startTimeMillis,omitempty"`
+	BuildToolVersion string      `json:"buildToolVersion,omitempty"`
+	Command          string      `json:"command,omitempty"`
+	WorkingDirectory string      `json:"workingDirectory,omitempty"`
+}
+
+// NamedSetOfFiles is a set of files, and other sets it includes, that targets refer to by ID
+type NamedSetOfFiles struct {
+	Files    []BuildEventFile `json:"files,omitempty"`
+	FileSets []NamedSetID     `json:"fileSets,omitempty"`
+}
+
+// BuildEventFile is a file reported in the build event protocol. Digest is hex encoded with the build's digest
+// function, SHA-256 by default.
+type BuildEventFile struct {
+	Name       string      `json:"name"`
+	URI        string      `json:"uri,omitempty"`
+	PathPrefix []string    `json:"pathPrefix,omitempty"`
+	Digest     string      `json:"digest,omitempty"`
+	Length     json.Number `json:"length,omitempty"`
+}
+
+// Path returns the file's path relative to the execution root, such as bazel-out/k8-fastbuild/bin/app
+func (f BuildEventFile) Path() string {
+	return path.Join(append(append([]string{}, f.PathPrefix...), f.Name)...)
+}
+
+// TargetComplete reports whether a target built and the output groups it produced
+type TargetComplete struct {
+	Success     bool          `json:"success,omitempty"`
+	OutputGroup []OutputGroup `json:"outputGroup,omitempty"`
+}
+
+type OutputGroup struct {
+	Name     string       `json:"name"`
+	FileSets []NamedSetID `json:"fileSets,omitempty"`
+}
+
+// BuildFinished is the event reporting the outcome of an invocation
+type BuildFinished struct {
+	OverallSuccess   bool        `json:"overallSuccess,omitempty"`
+	ExitCode         *ExitCode   `json:"exitCode,omitempty"`
+	FinishTimeMillis json.Number `json:"finishTimeMillis,omitempty"`
+}
+
+type ExitCode struct {
+	Name string `json:"name"`
+	Code int    `json:"code,omitempty"`
+}
+
+// Target is a target the invocation completed and the files in its output groups
+type Target struct {
+	Label   string
+	Success bool
+	Files   []BuildEventFile
+}
+
+// Build summarizes the events of an invocation
+type Build struct {
+	Started  *BuildStarted
+	Finished *BuildFinished
+	Targets  []Target
+}
+
+// ParseBuildEvents reads a stream of build events written with --build_event_json_file and resolves the files each
+// completed target produced.
+func ParseBuildEvents(r io.Reader) (Build, error) {
+	build := Build{}
+	sets := map[string]*NamedSetOfFiles{}
+	completed := []BuildEvent{}
+	dec := json.NewDecoder(r)
+	for count := 1; ; count++ {
+		event := BuildEvent{}
+		if err := dec.Decode(&event); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			return Build{}, fmt.Errorf("failed to parse build event %d: %w", count, err)
+		}
+
+		switch {
+		case event.Started != nil:
+			build.Started = event.Started
+		case event.Finished != nil:
+			build.Finished = event.Finished
+		case event.NamedSetOfFiles != nil && event.ID.NamedSet != nil:
+			sets[event.ID.NamedSet.ID] = event.NamedSetOfFiles
+		case event.Completed != nil && event.ID.TargetCompleted != nil:
+			completed = append(completed, event)
+		}
+	}
+
+	for _, event := range completed {
+		target := Target{Label: event.ID.TargetCompleted.Label, Success: event.Completed.Success}
+		seen := map[string]bool{}
+		for _, group := range event.Completed.OutputGroup {
+			for _, set := range group.FileSets {
+				target.Files = append(target.Files, resolveFileSet(sets, set.ID, seen)...)
+			}
+		}
+
+		build.Targets = append(build.Targets, target)
+	}
+
+	return build, nil
+}
+
+// resolveFileSet returns the files in the set with id and the sets it includes, skipping sets in seen
+func resolveFileSet(sets map[string]*NamedSetOfFiles, id string, seen map[string]bool) []BuildEventFile {
+	set, ok := sets[id]
+	if !ok || seen[id] {
+		return nil
+	}
+
+	seen[id] = true
+	files := append([]BuildEventFile{}, set.Files...)
+	for _, child := range set.FileSets {
+		files = append(files, resolveFileSet(sets, child.ID, seen)...)
+	}
+
+	return files
+}
@@ -0,0 +1,51 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bazel
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const buildEvents = `{"id":{"started":{}},"children":[{"pattern":{"pattern":["//..."]}}],"started":{"uuid":"c6d4e5f0-1a2b-4c3d-8e9f-0a1b2c3d4e5f","startTimeMillis":"1700000000000","buildToolVersion":"7.0.0","command":"build","workingDirectory":"/src"}}
+{"id":{"namedSet":{"id":"1"}},"namedSetOfFiles":{"files":[{"name":"app.runfiles_manifest","uri":"file:///src/bazel-out/k8-fastbuild/bin/app.runfiles_manifest","pathPrefix":["bazel-out","k8-fastbuild","bin"],"digest":"aaaa","length":"12"}]}}
+{"id":{"namedSet":{"id":"0"}},"namedSetOfFiles":{"files":[{"name":"app","uri":"file:///src/bazel-out/k8-fastbuild/bin/app","pathPrefix":["bazel-out","k8-fastbuild","bin"],"digest":"bbbb","length":"2048"}],"fileSets":[{"id":"1"}]}}
+{"id":{"targetCompleted":{"label":"//:app","configuration":{"id":"k8"}}},"completed":{"success":true,"outputGroup":[{"name":"default","fileSets":[{"id":"0"}]}]}}
+{"id":{"targetCompleted":{"label":"//:broken","configuration":{"id":"k8"}}},"completed":{}}
+{"id":{"buildFinished":{}},"finished":{"overallSuccess":true,"exitCode":{"name":"SUCCESS"},"finishTimeMillis":"1700000005000"}}
+`
+
+func TestParseBuildEvents(t *testing.T) {
+	build, err := ParseBuildEvents(strings.NewReader(buildEvents))
+	require.NoError(t, err)
+	require.NotNil(t, build.Started)
+	assert.Equal(t, "7.0.0", build.Started.BuildToolVersion)
+	require.NotNil(t, build.Finished)
+	assert.True(t, build.Finished.OverallSuccess)
+	assert.Equal(t, "SUCCESS", build.Finished.ExitCode.Name)
+
+	require.Len(t, build.Targets, 2)
+	assert.Equal(t, "//:app", build.Targets[0].Label)
+	assert.True(t, build.Targets[0].Success)
+	require.Len(t, build.Targets[0].Files, 2)
+	assert.Equal(t, "bazel-out/k8-fastbuild/bin/app", build.Targets[0].Files[0].Path())
+	assert.Equal(t, "bazel-out/k8-fastbuild/bin/app.runfiles_manifest", build.Targets[0].Files[1].Path())
+	assert.Equal(t, "//:broken", build.Targets[1].Label)
+	assert.False(t, build.Targets[1].Success)
+	assert.Empty(t, build.Targets[1].Files)
+}
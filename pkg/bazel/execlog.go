@@ -0,0 +1,115 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bazel reads the logs Bazel writes about a build: the JSON execution log written with
+// --execution_log_json_file and the build event protocol stream written with --build_event_json_file.
+package bazel
+
+import (
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+)
+
+// hashFunctions are the digest functions Bazel names in its logs that witness can record
+var hashFunctions = map[string]crypto.Hash{
+	"SHA-256": crypto.SHA256,
+	"SHA256":  crypto.SHA256,
+	"SHA-1":   crypto.SHA1,
+	"SHA1":    crypto.SHA1,
+}
+
+// Digest is the content digest of a file or action in the remote execution API's format
+type Digest struct {
+	Hash             string      `json:"hash"`
+	SizeBytes        json.Number `json:"sizeBytes,omitempty"`
+	HashFunctionName string      `json:"hashFunctionName,omitempty"`
+}
+
+// DigestSet returns the digest as a digest set. Digests without a hash function name are SHA-256, Bazel's default.
+func (d Digest) DigestSet() (cryptoutil.DigestSet, error) {
+	name := strings.ToUpper(d.HashFunctionName)
+	if name == "" {
+		name = "SHA-256"
+	}
+
+	hash, ok := hashFunctions[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported digest function %v", d.HashFunctionName)
+	}
+
+	return cryptoutil.DigestSet{hash: d.Hash}, nil
+}
+
+// File is an input or output of a spawn
+type File struct {
+	Path   string  `json:"path"`
+	Digest *Digest `json:"digest,omitempty"`
+	IsTool bool    `json:"isTool,omitempty"`
+}
+
+// EnvironmentVariable is an environment variable a spawn was run with
+type EnvironmentVariable struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Spawn is one action Bazel executed or fetched from a cache, as recorded in the execution log
+type Spawn struct {
+	CommandArgs          []string              `json:"commandArgs,omitempty"`
+	EnvironmentVariables []EnvironmentVariable `json:"environmentVariables,omitempty"`
+	Inputs               []File                `json:"inputs,omitempty"`
+	ListedOutputs        []string              `json:"listedOutputs,omitempty"`
+	ActualOutputs        []File                `json:"actualOutputs,omitempty"`
+	Remotable            bool                  `json:"remotable,omitempty"`
+	Cacheable            bool                  `json:"cacheable,omitempty"`
+	Mnemonic             string                `json:"mnemonic,omitempty"`
+	Runner               string                `json:"runner,omitempty"`
+	RemoteCacheHit       bool                  `json:"remoteCacheHit,omitempty"`
+	CacheHit             bool                  `json:"cacheHit,omitempty"`
+	Status               string                `json:"status,omitempty"`
+	ExitCode             int                   `json:"exitCode,omitempty"`
+	TargetLabel          string                `json:"targetLabel,omitempty"`
+	// Digest is the digest of the action, which is its key in the action cache. Older versions of Bazel don't record it.
+	Digest *Digest `json:"digest,omitempty"`
+}
+
+// Hit reports whether the spawn's outputs were fetched from a remote or disk cache rather than executed
+func (s Spawn) Hit() bool {
+	return s.RemoteCacheHit || s.CacheHit
+}
+
+// ParseExecutionLog reads the spawns in an execution log written with --execution_log_json_file, which is a stream
+// of JSON objects rather than an array.
+func ParseExecutionLog(r io.Reader) ([]Spawn, error) {
+	spawns := []Spawn{}
+	dec := json.NewDecoder(r)
+	for {
+		spawn := Spawn{}
+		if err := dec.Decode(&spawn); err != nil {
+			if errors.Is(err, io.EOF) {
+				return spawns, nil
+			}
+
+			return nil, fmt.Errorf("failed to parse spawn %d of execution log: %w", len(spawns)+1, err)
+		}
+
+		spawns = append(spawns, spawn)
+	}
+}
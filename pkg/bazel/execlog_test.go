@@ -0,0 +1,96 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bazel
+
+import (
+	"crypto"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/cryptoutil"
+)
+
+const executionLog = `{
+  "commandArgs": ["external/local_config_cc/cc_wrapper.sh", "-c", "main.c", "-o", "bazel-out/k8-fastbuild/bin/_objs/app/main.o"],
+  "inputs": [{
+    "path": "main.c",
+    "digest": {
+      "hash": "5f70bf18a086007016e948b04aed3b82103a36bea41755b6cddfaf10ace3c6ef",
+      "sizeBytes": "13",
+      "hashFunctionName": "SHA-256"
+    }
+  }],
+  "listedOutputs": ["bazel-out/k8-fastbuild/bin/_objs/app/main.o"],
+  "remotable": true,
+  "cacheable": true,
+  "mnemonic": "CppCompile",
+  "actualOutputs": [{
+    "path": "bazel-out/k8-fastbuild/bin/_objs/app/main.o",
+    "digest": {
+      "hash": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+      "sizeBytes": "1024",
+      "hashFunctionName": "SHA-256"
+    }
+  }],
+  "runner": "remote cache hit",
+  "remoteCacheHit": true,
+  "status": "",
+  "exitCode": 0,
+  "targetLabel": "//:app",
+  "digest": {
+    "hash": "b5bb9d8014a0f9b1d61e21e796d78dccdf1352f23cd32812f4850b878ae4944c",
+    "sizeBytes": "142",
+    "hashFunctionName": "SHA-256"
+  }
+}{
+  "commandArgs": ["/usr/bin/gcc", "-o", "bazel-out/k8-fastbuild/bin/app"],
+  "mnemonic": "CppLink",
+  "runner": "linux-sandbox",
+  "exitCode": 0,
+  "targetLabel": "//:app"
+}`
+
+func TestParseExecutionLog(t *testing.T) {
+	spawns, err := ParseExecutionLog(strings.NewReader(executionLog))
+	require.NoError(t, err)
+	require.Len(t, spawns, 2)
+
+	assert.True(t, spawns[0].Hit())
+	assert.Equal(t, "CppCompile", spawns[0].Mnemonic)
+	assert.Equal(t, "//:app", spawns[0].TargetLabel)
+	require.Len(t, spawns[0].ActualOutputs, 1)
+	digest, err := spawns[0].ActualOutputs[0].Digest.DigestSet()
+	require.NoError(t, err)
+	assert.Equal(t, cryptoutil.DigestSet{crypto.SHA256: "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"}, digest)
+	require.NotNil(t, spawns[0].Digest)
+	assert.Equal(t, "142", spawns[0].Digest.SizeBytes.String())
+
+	assert.False(t, spawns[1].Hit())
+	assert.Equal(t, "linux-sandbox", spawns[1].Runner)
+
+	_, err = ParseExecutionLog(strings.NewReader(executionLog[:100]))
+	require.Error(t, err)
+}
+
+func TestDigestSet(t *testing.T) {
+	digest, err := Digest{Hash: "abc"}.DigestSet()
+	require.NoError(t, err)
+	assert.Equal(t, cryptoutil.DigestSet{crypto.SHA256: "abc"}, digest)
+
+	_, err = Digest{Hash: "abc", HashFunctionName: "BLAKE3"}.DigestSet()
+	require.Error(t, err)
+}
@@ -0,0 +1,198 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cache stores responses from remote services, such as Archivist or OCI
+// registries, in a local directory so repeated verifications can skip the fetch.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Cache is a directory of entries keyed by an arbitrary string. Entries older than
+// the cache's TTL are treated as missing. A nil *Cache is valid and caches nothing.
+type Cache struct {
+	dir string
+	ttl time.Duration
+}
+
+// New creates a cache rooted at dir, creating the directory if needed. A ttl of 0
+// means entries never expire, which is appropriate for content addressed data.
+func New(dir string, ttl time.Duration) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	return &Cache{dir: dir, ttl: ttl}, nil
+}
+
+func (c *Cache) path(namespace, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, namespace, hex.EncodeToString(sum[:]))
+}
+
+// Get returns the data stored under key in namespace, and false if it is missing or expired.
+func (c *Cache) Get(namespace, key string) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	path := c.path(namespace, key)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, false
+	}
+
+	if c.ttl > 0 && time.Since(info.ModTime()) > c.ttl {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	return data, true
+}
+
+// Put stores data under key in namespace. The entry is written to a temporary file
+// and renamed into place so concurrent readers never see a partial entry.
+func (c *Cache) Put(namespace, key string, data []byte) error {
+	if c == nil {
+		return nil
+	}
+
+	path := c.path(namespace, key)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// GetJSON unmarshals the entry stored under key into v, reporting whether a usable entry was found.
+func (c *Cache) GetJSON(namespace, key string, v interface{}) bool {
+	data, ok := c.Get(namespace, key)
+	if !ok {
+		return false
+	}
+
+	return json.Unmarshal(data, v) == nil
+}
+
+// PutJSON marshals v and stores it under key.
+func (c *Cache) PutJSON(namespace, key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return c.Put(namespace, key, data)
+}
+
+// Clear removes every entry from the cache.
+func (c *Cache) Clear() error {
+	if c == nil {
+		return nil
+	}
+
+	entries, err := os.ReadDir(c.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(c.dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Prune removes entries that have outlived the cache's TTL and returns their paths relative to the cache
+// directory. Nothing is removed if the cache has no TTL. If dryRun is true the entries are only listed.
+func (c *Cache) Prune(dryRun bool) ([]string, error) {
+	if c == nil || c.ttl <= 0 {
+		return nil, nil
+	}
+
+	pruned := []string{}
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+
+		info, err := d.Info()
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if time.Since(info.ModTime()) <= c.ttl {
+			return nil
+		}
+
+		rel, err := filepath.Rel(c.dir, path)
+		if err != nil {
+			return err
+		}
+
+		if !dryRun {
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+
+		pruned = append(pruned, filepath.ToSlash(rel))
+		return nil
+	})
+
+	if errors.Is(err, os.ErrNotExist) {
+		return pruned, nil
+	}
+
+	return pruned, err
+}
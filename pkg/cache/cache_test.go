@@ -0,0 +1,94 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache(t *testing.T) {
+	c, err := New(t.TempDir(), time.Hour)
+	require.NoError(t, err)
+
+	_, ok := c.Get("archivist", "gitoid")
+	assert.False(t, ok)
+
+	require.NoError(t, c.Put("archivist", "gitoid", []byte("envelope")))
+	data, ok := c.Get("archivist", "gitoid")
+	require.True(t, ok)
+	assert.Equal(t, "envelope", string(data))
+
+	_, ok = c.Get("oci", "gitoid")
+	assert.False(t, ok)
+
+	require.NoError(t, c.Clear())
+	_, ok = c.Get("archivist", "gitoid")
+	assert.False(t, ok)
+}
+
+func TestCacheExpiry(t *testing.T) {
+	c, err := New(t.TempDir(), time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, c.PutJSON("oci", "alpine:latest", []string{"abcd"}))
+
+	past := time.Now().Add(-2 * time.Minute)
+	require.NoError(t, os.Chtimes(c.path("oci", "alpine:latest"), past, past))
+	var out []string
+	assert.False(t, c.GetJSON("oci", "alpine:latest", &out))
+
+	forever, err := New(filepath.Dir(filepath.Dir(c.path("oci", "alpine:latest"))), 0)
+	require.NoError(t, err)
+	assert.True(t, forever.GetJSON("oci", "alpine:latest", &out))
+	assert.Equal(t, []string{"abcd"}, out)
+}
+
+func TestNilCache(t *testing.T) {
+	var c *Cache
+	require.NoError(t, c.Put("oci", "key", []byte("data")))
+	_, ok := c.Get("oci", "key")
+	assert.False(t, ok)
+}
+
+func TestCachePrune(t *testing.T) {
+	c, err := New(t.TempDir(), time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, c.Put("oci", "old", []byte("old")))
+	require.NoError(t, c.Put("oci", "new", []byte("new")))
+	past := time.Now().Add(-2 * time.Minute)
+	require.NoError(t, os.Chtimes(c.path("oci", "old"), past, past))
+
+	pruned, err := c.Prune(true)
+	require.NoError(t, err)
+	require.Len(t, pruned, 1)
+	assert.FileExists(t, c.path("oci", "old"))
+
+	pruned, err = c.Prune(false)
+	require.NoError(t, err)
+	require.Len(t, pruned, 1)
+	assert.NoFileExists(t, c.path("oci", "old"))
+	assert.FileExists(t, c.path("oci", "new"))
+
+	forever, err := New(t.TempDir(), 0)
+	require.NoError(t, err)
+	pruned, err = forever.Prune(false)
+	require.NoError(t, err)
+	assert.Empty(t, pruned)
+}
@@ -0,0 +1,181 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package capsule reads and writes environment capsules: tarballs holding everything
+// witness captured during a run before it was signed. Capsules are useful for debugging
+// attestors, and for signing the same attestations again later with a different key.
+package capsule
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/intoto"
+)
+
+const (
+	metadataFile   = "metadata.json"
+	collectionFile = "collection.json"
+	statementFile  = "statement.json"
+	attestorsDir   = "attestors"
+)
+
+// Metadata describes when and for which step a capsule was captured.
+type Metadata struct {
+	StepName   string    `json:"stepname"`
+	CapturedAt time.Time `json:"capturedat"`
+}
+
+// Capsule is the unsigned output of a witness run.
+type Capsule struct {
+	Metadata Metadata
+	// Collection is the raw attestation collection, including the output of each attestor
+	Collection json.RawMessage
+	// Statement is the in-toto statement that is signed to produce the run's envelope
+	Statement json.RawMessage
+	// Attestors holds the raw output of each attestor keyed by its file name in the capsule
+	Attestors map[string]json.RawMessage
+}
+
+// New builds a capsule from a collection produced by a run.
+func New(collection attestation.Collection) (Capsule, error) {
+	collectionJson, err := json.Marshal(&collection)
+	if err != nil {
+		return Capsule{}, fmt.Errorf("failed to marshal collection: %w", err)
+	}
+
+	stmt, err := intoto.NewStatement(attestation.CollectionType, collectionJson, collection.Subjects())
+	if err != nil {
+		return Capsule{}, fmt.Errorf("failed to create statement: %w", err)
+	}
+
+	stmtJson, err := json.Marshal(&stmt)
+	if err != nil {
+		return Capsule{}, fmt.Errorf("failed to marshal statement: %w", err)
+	}
+
+	c := Capsule{
+		Metadata: Metadata{
+			StepName:   collection.Name,
+			CapturedAt: time.Now().UTC(),
+		},
+		Collection: collectionJson,
+		Statement:  stmtJson,
+		Attestors:  make(map[string]json.RawMessage),
+	}
+
+	for i, att := range collection.Attestations {
+		attJson, err := json.MarshalIndent(att.Attestation, "", "  ")
+		if err != nil {
+			return Capsule{}, fmt.Errorf("failed to marshal %v attestation: %w", att.Attestation.Name(), err)
+		}
+
+		c.Attestors[fmt.Sprintf("%02d-%v.json", i, att.Attestation.Name())] = attJson
+	}
+
+	return c, nil
+}
+
+// Write writes the capsule as a tarball to w.
+func Write(w io.Writer, c Capsule) error {
+	metadataJson, err := json.MarshalIndent(c.Metadata, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(w)
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{metadataFile, metadataJson},
+		{collectionFile, c.Collection},
+		{statementFile, c.Statement},
+	}
+
+	names := make([]string, 0, len(c.Attestors))
+	for name := range c.Attestors {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		files = append(files, struct {
+			name string
+			data []byte
+		}{path.Join(attestorsDir, name), c.Attestors[name]})
+	}
+
+	for _, file := range files {
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    file.name,
+			Mode:    0600,
+			Size:    int64(len(file.data)),
+			ModTime: c.Metadata.CapturedAt,
+		}); err != nil {
+			return err
+		}
+
+		if _, err := tw.Write(file.data); err != nil {
+			return err
+		}
+	}
+
+	return tw.Close()
+}
+
+// Read reads a capsule tarball written by Write.
+func Read(r io.Reader) (Capsule, error) {
+	c := Capsule{Attestors: make(map[string]json.RawMessage)}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return c, fmt.Errorf("failed to read capsule: %w", err)
+		}
+
+		buf := &bytes.Buffer{}
+		if _, err := io.Copy(buf, tr); err != nil {
+			return c, fmt.Errorf("failed to read %v from capsule: %w", hdr.Name, err)
+		}
+
+		switch {
+		case hdr.Name == metadataFile:
+			if err := json.Unmarshal(buf.Bytes(), &c.Metadata); err != nil {
+				return c, fmt.Errorf("failed to parse capsule metadata: %w", err)
+			}
+		case hdr.Name == collectionFile:
+			c.Collection = buf.Bytes()
+		case hdr.Name == statementFile:
+			c.Statement = buf.Bytes()
+		case path.Dir(hdr.Name) == attestorsDir:
+			c.Attestors[path.Base(hdr.Name)] = buf.Bytes()
+		}
+	}
+
+	if len(c.Statement) == 0 {
+		return c, fmt.Errorf("capsule does not contain a statement")
+	}
+
+	return c, nil
+}
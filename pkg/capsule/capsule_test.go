@@ -0,0 +1,54 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capsule
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/attestation/environment"
+	"github.com/testifysec/go-witness/intoto"
+)
+
+func TestWriteRead(t *testing.T) {
+	collection := attestation.NewCollection("build", []attestation.Attestor{environment.New()})
+	c, err := New(collection)
+	require.NoError(t, err)
+	assert.Equal(t, "build", c.Metadata.StepName)
+	assert.Contains(t, c.Attestors, "00-environment.json")
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, Write(buf, c))
+	read, err := Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, c.Metadata.StepName, read.Metadata.StepName)
+	assert.True(t, c.Metadata.CapturedAt.Equal(read.Metadata.CapturedAt))
+	assert.Equal(t, c.Collection, read.Collection)
+	assert.Equal(t, c.Statement, read.Statement)
+	assert.Equal(t, c.Attestors, read.Attestors)
+
+	stmt := intoto.Statement{}
+	require.NoError(t, json.Unmarshal(read.Statement, &stmt))
+	assert.Equal(t, attestation.CollectionType, stmt.PredicateType)
+}
+
+func TestReadWithoutStatement(t *testing.T) {
+	_, err := Read(&bytes.Buffer{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,164 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package checksums reads and writes the checksum files many projects publish with their releases, such as the
+// SHA256SUMS files written by sha256sum and shasum.
+package checksums
+
+import (
+	"bufio"
+	"crypto"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Entry is the digest of one file listed in a checksum file
+type Entry struct {
+	Name   string
+	Hash   crypto.Hash
+	Digest string
+}
+
+// hashesByLength identifies the hash of an entry by the length of its hex digest
+var hashesByLength = map[int]crypto.Hash{
+	crypto.SHA256.Size() * 2: crypto.SHA256,
+	crypto.SHA512.Size() * 2: crypto.SHA512,
+}
+
+// bsdTags are the algorithm names of the BSD tagged format written by shasum --tag and sha256sum --tag
+var bsdTags = map[string]crypto.Hash{
+	"SHA256": crypto.SHA256,
+	"SHA512": crypto.SHA512,
+}
+
+// Parse reads a checksum file in the GNU format of sha256sum, where each line is a digest followed by the file name,
+// or the BSD tagged format, where each line is SHA256 (name) = digest. Blank lines and comments are skipped.
+func Parse(r io.Reader) ([]Entry, error) {
+	entries := []Entry{}
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		entry, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func parseLine(line string) (Entry, error) {
+	if tag, rest, ok := strings.Cut(line, " ("); ok {
+		if hash, ok := bsdTags[tag]; ok {
+			name, digest, ok := cutLast(rest, ") = ")
+			if !ok {
+				return Entry{}, fmt.Errorf("malformed tagged checksum")
+			}
+
+			return newEntry(name, hash, digest)
+		}
+	}
+
+	digest, name, ok := strings.Cut(line, " ")
+	if !ok {
+		return Entry{}, fmt.Errorf("expected a digest and a file name")
+	}
+
+	// the second field marks whether the file was read in binary (*) or text mode, which doesn't change the digest
+	if !strings.HasPrefix(name, " ") && !strings.HasPrefix(name, "*") {
+		return Entry{}, fmt.Errorf("expected a digest and a file name")
+	}
+
+	hash, ok := hashesByLength[len(digest)]
+	if !ok {
+		return Entry{}, fmt.Errorf("unsupported digest length %d", len(digest))
+	}
+
+	return newEntry(name[1:], hash, digest)
+}
+
+func newEntry(name string, hash crypto.Hash, digest string) (Entry, error) {
+	if name == "" {
+		return Entry{}, fmt.Errorf("missing file name")
+	}
+
+	if _, err := hex.DecodeString(digest); err != nil || len(digest) != hash.Size()*2 {
+		return Entry{}, fmt.Errorf("invalid %v digest for %v", hash, name)
+	}
+
+	return Entry{Name: name, Hash: hash, Digest: strings.ToLower(digest)}, nil
+}
+
+func cutLast(s, sep string) (string, string, bool) {
+	i := strings.LastIndex(s, sep)
+	if i < 0 {
+		return s, "", false
+	}
+
+	return s[:i], s[i+len(sep):], true
+}
+
+// Write writes entries in the GNU format of sha256sum, sorted by name, so the file can be checked with sha256sum -c
+func Write(w io.Writer, entries []Entry) error {
+	sorted := append([]Entry{}, entries...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	for _, entry := range sorted {
+		if _, err := fmt.Fprintf(w, "%v  %v\n", entry.Digest, entry.Name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Lookup returns the entry for the file at name. Names in checksum files are relative to the directory the file was
+// written in, so name is first matched exactly, then by its base name if only one entry has that base name.
+func Lookup(entries []Entry, name string) (Entry, bool) {
+	name = filepath.ToSlash(filepath.Clean(name))
+	var byBase []Entry
+	for _, entry := range entries {
+		entryName := path.Clean(strings.TrimPrefix(entry.Name, "./"))
+		if entryName == name {
+			return entry, true
+		}
+
+		if path.Base(entryName) == path.Base(name) {
+			byBase = append(byBase, entry)
+		}
+	}
+
+	if len(byBase) == 1 {
+		return byBase[0], true
+	}
+
+	return Entry{}, false
+}
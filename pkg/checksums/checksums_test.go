@@ -0,0 +1,104 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checksums
+
+import (
+	"bytes"
+	"crypto"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testSHA256 = "b5bb9d8014a0f9b1d61e21e796d78dccdf1352f23cd32812f4850b878ae4944c"
+	testSHA512 = "0cf9180a764aba863a67b6d72f0918bc131c6772642cb2dce5a34f0a702f9470ddc2bf125c12198b1995c233c34b4afd346c54a2334c350a948a51b6e8b4e6b6"
+)
+
+func TestParse(t *testing.T) {
+	input := strings.Join([]string{
+		"# checksums for v1.0.0",
+		testSHA256 + "  app_linux_amd64.tar.gz",
+		strings.ToUpper(testSHA256) + " *app_windows_amd64.zip",
+		"",
+		"SHA256 (dist/app with spaces.tar.gz) = " + testSHA256,
+		testSHA512 + "  app.sbom.json\r",
+	}, "\n")
+
+	entries, err := Parse(strings.NewReader(input))
+	require.NoError(t, err)
+	assert.Equal(t, []Entry{
+		{Name: "app_linux_amd64.tar.gz", Hash: crypto.SHA256, Digest: testSHA256},
+		{Name: "app_windows_amd64.zip", Hash: crypto.SHA256, Digest: testSHA256},
+		{Name: "dist/app with spaces.tar.gz", Hash: crypto.SHA256, Digest: testSHA256},
+		{Name: "app.sbom.json", Hash: crypto.SHA512, Digest: testSHA512},
+	}, entries)
+}
+
+func TestParseErrors(t *testing.T) {
+	for name, input := range map[string]string{
+		"no file name":   testSHA256,
+		"short digest":   "abc123  app.tar.gz",
+		"not hex":        strings.Repeat("z", 64) + "  app.tar.gz",
+		"bad tag format": "SHA256 (app.tar.gz " + testSHA256,
+		"one space":      testSHA256 + " app.tar.gz",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(input))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	buf := &bytes.Buffer{}
+	require.NoError(t, Write(buf, []Entry{
+		{Name: "b.tar.gz", Hash: crypto.SHA256, Digest: testSHA256},
+		{Name: "a.tar.gz", Hash: crypto.SHA256, Digest: testSHA256},
+	}))
+
+	assert.Equal(t, testSHA256+"  a.tar.gz\n"+testSHA256+"  b.tar.gz\n", buf.String())
+	entries, err := Parse(buf)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
+
+func TestLookup(t *testing.T) {
+	entries := []Entry{
+		{Name: "./dist/app.tar.gz", Digest: "1"},
+		{Name: "linux/app", Digest: "2"},
+		{Name: "darwin/app", Digest: "3"},
+	}
+
+	entry, ok := Lookup(entries, "dist/app.tar.gz")
+	require.True(t, ok)
+	assert.Equal(t, "1", entry.Digest)
+
+	entry, ok = Lookup(entries, "downloads/app.tar.gz")
+	require.True(t, ok)
+	assert.Equal(t, "1", entry.Digest)
+
+	entry, ok = Lookup(entries, "darwin/app")
+	require.True(t, ok)
+	assert.Equal(t, "3", entry.Digest)
+
+	_, ok = Lookup(entries, "app")
+	assert.False(t, ok, "ambiguous base names shouldn't match")
+
+	_, ok = Lookup(entries, "missing.tar.gz")
+	assert.False(t, ok)
+}
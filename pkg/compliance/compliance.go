@@ -0,0 +1,198 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package compliance maps verification results to compliance controls, so auditors can review which controls an
+// artifact's attestations support without reading DSSE envelopes. Results are exported as OSCAL assessment results
+// or as a CSV evidence matrix.
+package compliance
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/testifysec/go-witness/attestation/commandrun"
+	"github.com/testifysec/go-witness/attestation/environment"
+	"github.com/testifysec/go-witness/attestation/git"
+	"github.com/testifysec/go-witness/attestation/gitlab"
+	"github.com/testifysec/go-witness/attestation/material"
+	"github.com/testifysec/go-witness/attestation/oci"
+	"github.com/testifysec/go-witness/attestation/product"
+	"github.com/testifysec/go-witness/attestation/sarif"
+	"github.com/testifysec/go-witness/attestation/sbom"
+	"github.com/testifysec/go-witness/cryptoutil"
+)
+
+const (
+	FormatOSCAL = "oscal"
+	FormatCSV   = "csv"
+
+	// AnyAttestation maps controls that every verified collection supports, whatever attestations it holds
+	AnyAttestation = "*"
+
+	StatusSatisfied    = "satisfied"
+	StatusNotSatisfied = "not-satisfied"
+)
+
+// ControlMap maps attestation types to the IDs of the controls they provide evidence for
+type ControlMap map[string][]string
+
+// SSDF maps attestations to the practices of the NIST Secure Software Development Framework (SP 800-218) they
+// provide evidence for. Every signed collection that satisfies a policy is integrity verification and
+// provenance data for the artifact.
+var SSDF = ControlMap{
+	AnyAttestation:   {"PS.2.1", "PS.3.1"},
+	commandrun.Type:  {"PO.3.3"},
+	environment.Type: {"PO.5.1"},
+	git.Type:         {"PS.1.1"},
+	gitlab.Type:      {"PS.1.1"},
+	material.Type:    {"PS.3.2"},
+	product.Type:     {"PS.3.2"},
+	oci.Type:         {"PS.3.2"},
+	sbom.Type:        {"PS.3.2"},
+	sarif.Type:       {"PW.7.2", "PW.8.2"},
+}
+
+// LoadControlMap reads a control map from a JSON object of attestation types to control IDs
+func LoadControlMap(path string) (ControlMap, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m := ControlMap{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse control map: %w", err)
+	}
+
+	return m, nil
+}
+
+// Evidence is a collection that satisfied a step of the policy for an artifact
+type Evidence struct {
+	Step         string
+	Reference    string
+	Attestations []string
+}
+
+// Artifact is the verification result of one artifact
+type Artifact struct {
+	Name     string
+	Subjects []cryptoutil.DigestSet
+	Passed   bool
+	Error    string
+	Evidence []Evidence
+	Waivers  []string
+}
+
+// Report is the result of verifying a set of artifacts against a policy
+type Report struct {
+	Time         time.Time
+	PolicyPath   string
+	PolicyDigest cryptoutil.DigestSet
+	// Required are the attestation types the policy requires, which decide the controls the report assesses
+	Required  []string
+	Artifacts []Artifact
+}
+
+// Row is an entry in the evidence matrix: a control assessed for an artifact and the evidence supporting it
+type Row struct {
+	Control     string
+	Artifact    string
+	Status      string
+	Step        string
+	Attestation string
+	Evidence    string
+}
+
+// Controls returns the sorted IDs of the controls the report assesses: those that the policy's required
+// attestations, or every verified collection, provide evidence for
+func (r Report) Controls(m ControlMap) []string {
+	seen := make(map[string]struct{})
+	for _, attestationType := range append([]string{AnyAttestation}, r.Required...) {
+		for _, control := range m[attestationType] {
+			seen[control] = struct{}{}
+		}
+	}
+
+	controls := make([]string, 0, len(seen))
+	for control := range seen {
+		controls = append(controls, control)
+	}
+
+	sort.Strings(controls)
+	return controls
+}
+
+// Rows returns the evidence matrix of the report. Each control is satisfied for an artifact that passed
+// verification by every collection holding an attestation mapped to the control, with one row per piece of
+// evidence. A control without evidence, or for an artifact that failed, has a single not-satisfied row.
+func (r Report) Rows(m ControlMap) []Row {
+	rows := []Row{}
+	for _, control := range r.Controls(m) {
+		for _, artifact := range r.Artifacts {
+			found := false
+			if artifact.Passed {
+				for _, evidence := range artifact.Evidence {
+					for _, attestationType := range supportingAttestations(m, control, evidence) {
+						found = true
+						rows = append(rows, Row{
+							Control:     control,
+							Artifact:    artifact.Name,
+							Status:      StatusSatisfied,
+							Step:        evidence.Step,
+							Attestation: attestationType,
+							Evidence:    evidence.Reference,
+						})
+					}
+				}
+			}
+
+			if !found {
+				rows = append(rows, Row{Control: control, Artifact: artifact.Name, Status: StatusNotSatisfied})
+			}
+		}
+	}
+
+	return rows
+}
+
+// supportingAttestations returns the attestations in the evidence mapped to control. A control every collection
+// supports is reported once, with the wildcard as its attestation.
+func supportingAttestations(m ControlMap, control string, evidence Evidence) []string {
+	if contains(m[AnyAttestation], control) {
+		return []string{AnyAttestation}
+	}
+
+	supporting := []string{}
+	for _, attestationType := range evidence.Attestations {
+		if contains(m[attestationType], control) {
+			supporting = append(supporting, attestationType)
+		}
+	}
+
+	return supporting
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,103 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compliance
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/attestation/commandrun"
+	"github.com/testifysec/go-witness/attestation/git"
+)
+
+func testReport() Report {
+	return Report{
+		Time:       time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC),
+		PolicyPath: "policy-signed.json",
+		Required:   []string{commandrun.Type, git.Type},
+		Artifacts: []Artifact{
+			{
+				Name:   "app.tar",
+				Passed: true,
+				Evidence: []Evidence{
+					{Step: "build", Reference: "build.json", Attestations: []string{commandrun.Type}},
+					{Step: "test", Reference: "test.json", Attestations: []string{commandrun.Type}},
+				},
+			},
+			{Name: "lib.tar", Error: "policy was denied"},
+		},
+	}
+}
+
+func TestRows(t *testing.T) {
+	m := ControlMap{AnyAttestation: {"PS.2.1"}, commandrun.Type: {"PO.3.3"}, git.Type: {"PS.1.1"}}
+	r := testReport()
+	assert.Equal(t, []string{"PO.3.3", "PS.1.1", "PS.2.1"}, r.Controls(m))
+	assert.Equal(t, []Row{
+		{Control: "PO.3.3", Artifact: "app.tar", Status: StatusSatisfied, Step: "build", Attestation: commandrun.Type, Evidence: "build.json"},
+		{Control: "PO.3.3", Artifact: "app.tar", Status: StatusSatisfied, Step: "test", Attestation: commandrun.Type, Evidence: "test.json"},
+		{Control: "PO.3.3", Artifact: "lib.tar", Status: StatusNotSatisfied},
+		{Control: "PS.1.1", Artifact: "app.tar", Status: StatusNotSatisfied},
+		{Control: "PS.1.1", Artifact: "lib.tar", Status: StatusNotSatisfied},
+		{Control: "PS.2.1", Artifact: "app.tar", Status: StatusSatisfied, Step: "build", Attestation: AnyAttestation, Evidence: "build.json"},
+		{Control: "PS.2.1", Artifact: "app.tar", Status: StatusSatisfied, Step: "test", Attestation: AnyAttestation, Evidence: "test.json"},
+		{Control: "PS.2.1", Artifact: "lib.tar", Status: StatusNotSatisfied},
+	}, r.Rows(m))
+}
+
+func TestWriteCSV(t *testing.T) {
+	buf := &bytes.Buffer{}
+	require.NoError(t, WriteCSV(buf, testReport(), SSDF))
+	records, err := csv.NewReader(buf).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, csvHeader, records[0])
+	assert.Contains(t, records, []string{"PO.3.3", "app.tar", StatusSatisfied, "build", commandrun.Type, "build.json", ""})
+	assert.Contains(t, records, []string{"PS.1.1", "app.tar", StatusNotSatisfied, "", "", "", ""})
+}
+
+func TestWriteOSCAL(t *testing.T) {
+	buf := &bytes.Buffer{}
+	require.NoError(t, WriteOSCAL(buf, testReport(), SSDF))
+	doc := oscalDocument{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &doc))
+	require.Len(t, doc.AssessmentResults.Results, 1)
+	result := doc.AssessmentResults.Results[0]
+	assert.Equal(t, OSCALVersion, doc.AssessmentResults.Metadata.OSCALVersion)
+	assert.Equal(t, "policy-signed.json", doc.AssessmentResults.ImportAP.Href)
+
+	// two observations for the verified collections, and one for the failed artifact
+	require.Len(t, result.Observations, 3)
+	observations := make(map[string]oscalObservation)
+	for _, observation := range result.Observations {
+		observations[observation.UUID] = observation
+	}
+
+	states := make(map[string]string)
+	for _, finding := range result.Findings {
+		states[finding.Title] = finding.Target.Status.State
+		for _, related := range finding.RelatedObservations {
+			assert.Contains(t, observations, related.ObservationUUID)
+		}
+	}
+
+	assert.Equal(t, StatusSatisfied, states["PO.3.3 for app.tar"])
+	assert.Equal(t, StatusNotSatisfied, states["PS.1.1 for app.tar"])
+	assert.Equal(t, StatusNotSatisfied, states["PO.3.3 for lib.tar"])
+}
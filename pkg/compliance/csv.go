@@ -0,0 +1,45 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compliance
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+)
+
+var csvHeader = []string{"control", "artifact", "status", "step", "attestation", "evidence", "waivers"}
+
+// WriteCSV writes the report's evidence matrix as CSV, one row per control, artifact, and piece of evidence
+func WriteCSV(w io.Writer, r Report, m ControlMap) error {
+	waivers := make(map[string]string)
+	for _, artifact := range r.Artifacts {
+		waivers[artifact.Name] = strings.Join(artifact.Waivers, " ")
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, row := range r.Rows(m) {
+		if err := cw.Write([]string{row.Control, row.Artifact, row.Status, row.Step, row.Attestation, row.Evidence, waivers[row.Artifact]}); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,243 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compliance
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OSCALVersion is the version of the OSCAL assessment results model WriteOSCAL produces
+const OSCALVersion = "1.1.2"
+
+type oscalDocument struct {
+	AssessmentResults oscalAssessmentResults `json:"assessment-results"`
+}
+
+type oscalAssessmentResults struct {
+	UUID     string        `json:"uuid"`
+	Metadata oscalMetadata `json:"metadata"`
+	ImportAP oscalLink     `json:"import-ap"`
+	Results  []oscalResult `json:"results"`
+}
+
+type oscalMetadata struct {
+	Title        string    `json:"title"`
+	LastModified time.Time `json:"last-modified"`
+	Version      string    `json:"version"`
+	OSCALVersion string    `json:"oscal-version"`
+}
+
+type oscalLink struct {
+	Href        string `json:"href"`
+	Description string `json:"description,omitempty"`
+}
+
+type oscalProp struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type oscalResult struct {
+	UUID             string               `json:"uuid"`
+	Title            string               `json:"title"`
+	Description      string               `json:"description"`
+	Start            time.Time            `json:"start"`
+	End              time.Time            `json:"end"`
+	Props            []oscalProp          `json:"props,omitempty"`
+	ReviewedControls oscalReviewedControl `json:"reviewed-controls"`
+	Observations     []oscalObservation   `json:"observations,omitempty"`
+	Findings         []oscalFinding       `json:"findings,omitempty"`
+}
+
+type oscalReviewedControl struct {
+	ControlSelections []oscalControlSelection `json:"control-selections"`
+}
+
+type oscalControlSelection struct {
+	IncludeControls []oscalControl `json:"include-controls,omitempty"`
+}
+
+type oscalControl struct {
+	ControlID string `json:"control-id"`
+}
+
+type oscalObservation struct {
+	UUID             string      `json:"uuid"`
+	Title            string      `json:"title"`
+	Description      string      `json:"description"`
+	Props            []oscalProp `json:"props,omitempty"`
+	Methods          []string    `json:"methods"`
+	RelevantEvidence []oscalLink `json:"relevant-evidence,omitempty"`
+	Collected        time.Time   `json:"collected"`
+}
+
+type oscalFinding struct {
+	UUID                string                    `json:"uuid"`
+	Title               string                    `json:"title"`
+	Description         string                    `json:"description"`
+	Target              oscalFindingTarget        `json:"target"`
+	RelatedObservations []oscalRelatedObservation `json:"related-observations,omitempty"`
+}
+
+type oscalFindingTarget struct {
+	Type     string      `json:"type"`
+	TargetID string      `json:"target-id"`
+	Status   oscalStatus `json:"status"`
+}
+
+type oscalStatus struct {
+	State string `json:"state"`
+}
+
+type oscalRelatedObservation struct {
+	ObservationUUID string `json:"observation-uuid"`
+}
+
+// WriteOSCAL writes the report as OSCAL assessment results. Each verified collection, and each failed artifact,
+// is an observation, and each control assessed for each artifact is a finding related to the observations that
+// support it.
+func WriteOSCAL(w io.Writer, r Report, m ControlMap) error {
+	controls := r.Controls(m)
+	included := make([]oscalControl, 0, len(controls))
+	for _, control := range controls {
+		included = append(included, oscalControl{ControlID: control})
+	}
+
+	result := oscalResult{
+		UUID:        uuid.NewString(),
+		Title:       "Witness verification",
+		Description: fmt.Sprintf("Verification of %d artifacts against the witness policy %v", len(r.Artifacts), r.PolicyPath),
+		Start:       r.Time,
+		End:         r.Time,
+		ReviewedControls: oscalReviewedControl{
+			ControlSelections: []oscalControlSelection{{IncludeControls: included}},
+		},
+	}
+
+	if digests, err := r.PolicyDigest.ToNameMap(); err == nil {
+		for algorithm, digest := range digests {
+			result.Props = append(result.Props, oscalProp{Name: "policy-digest", Value: fmt.Sprintf("%v:%v", algorithm, digest)})
+		}
+	}
+
+	// observations are keyed by artifact and evidence reference so findings can refer to them
+	observations := make(map[string]string)
+	for _, artifact := range r.Artifacts {
+		if !artifact.Passed {
+			observation := oscalObservation{
+				UUID:        uuid.NewString(),
+				Title:       fmt.Sprintf("%v failed verification", artifact.Name),
+				Description: artifact.Error,
+				Props:       []oscalProp{{Name: "artifact", Value: artifact.Name}},
+				Methods:     []string{"TEST"},
+				Collected:   r.Time,
+			}
+
+			observations[artifact.Name] = observation.UUID
+			result.Observations = append(result.Observations, observation)
+			continue
+		}
+
+		for _, evidence := range artifact.Evidence {
+			observation := oscalObservation{
+				UUID:        uuid.NewString(),
+				Title:       fmt.Sprintf("Step %v of %v", evidence.Step, artifact.Name),
+				Description: fmt.Sprintf("Signed attestation collection for step %v that satisfied the policy for %v", evidence.Step, artifact.Name),
+				Props: []oscalProp{
+					{Name: "artifact", Value: artifact.Name},
+					{Name: "step", Value: evidence.Step},
+				},
+				Methods:          []string{"TEST"},
+				RelevantEvidence: []oscalLink{{Href: evidence.Reference, Description: "Attestation collection"}},
+				Collected:        r.Time,
+			}
+
+			for _, waiver := range artifact.Waivers {
+				observation.Props = append(observation.Props, oscalProp{Name: "waiver", Value: waiver})
+			}
+
+			observations[artifact.Name+"\x00"+evidence.Reference] = observation.UUID
+			result.Observations = append(result.Observations, observation)
+		}
+	}
+
+	findings := make(map[string]*oscalFinding)
+	order := []string{}
+	for _, row := range r.Rows(m) {
+		key := row.Control + "\x00" + row.Artifact
+		finding, ok := findings[key]
+		if !ok {
+			finding = &oscalFinding{
+				UUID:        uuid.NewString(),
+				Title:       fmt.Sprintf("%v for %v", row.Control, row.Artifact),
+				Description: fmt.Sprintf("Evidence from witness attestations for control %v of %v", row.Control, row.Artifact),
+				Target: oscalFindingTarget{
+					Type:     "objective-id",
+					TargetID: row.Control,
+					Status:   oscalStatus{State: row.Status},
+				},
+			}
+
+			findings[key] = finding
+			order = append(order, key)
+		}
+
+		observationKey := row.Artifact
+		if row.Evidence != "" {
+			observationKey = row.Artifact + "\x00" + row.Evidence
+		}
+
+		if observation, ok := observations[observationKey]; ok && !hasObservation(finding, observation) {
+			finding.RelatedObservations = append(finding.RelatedObservations, oscalRelatedObservation{ObservationUUID: observation})
+		}
+	}
+
+	for _, key := range order {
+		result.Findings = append(result.Findings, *findings[key])
+	}
+
+	doc := oscalDocument{
+		AssessmentResults: oscalAssessmentResults{
+			UUID: uuid.NewString(),
+			Metadata: oscalMetadata{
+				Title:        "Witness verification results",
+				LastModified: r.Time,
+				Version:      "1.0",
+				OSCALVersion: OSCALVersion,
+			},
+			ImportAP: oscalLink{Href: r.PolicyPath, Description: "Witness policy"},
+			Results:  []oscalResult{result},
+		},
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(doc)
+}
+
+func hasObservation(finding *oscalFinding, observation string) bool {
+	for _, related := range finding.RelatedObservations {
+		if related.ObservationUUID == observation {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,211 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package containerexec runs a command inside a container image with docker, or a CLI compatible with it such as
+// podman or nerdctl, and describes the image the command ran in so it can be recorded.
+package containerexec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Image is a container image a command runs in
+type Image struct {
+	// Reference is the image as it was given, such as golang:1.18
+	Reference string `json:"reference"`
+	// ID is the digest of the image's configuration, which the command is run by so a tag moving can't change the
+	// image between inspecting and running it
+	ID string `json:"id"`
+	// RepoDigests are the manifest digests the image was pulled by, as name@sha256:digest
+	RepoDigests []string `json:"repoDigests,omitempty"`
+	Platform    string   `json:"platform,omitempty"`
+	Entrypoint  []string `json:"entrypoint,omitempty"`
+	Cmd         []string `json:"cmd,omitempty"`
+	User        string   `json:"user,omitempty"`
+}
+
+// Mount is a host directory bind mounted into the container
+type Mount struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	ReadOnly    bool   `json:"readOnly,omitempty"`
+}
+
+// ParseMount parses a mount given as source:destination, optionally followed by :ro or :rw. A relative source is
+// relative to the current directory.
+func ParseMount(spec string) (Mount, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return Mount{}, fmt.Errorf("container mount %q must be source:destination[:ro]", spec)
+	}
+
+	if !filepath.IsAbs(parts[1]) {
+		return Mount{}, fmt.Errorf("container mount %q must have an absolute destination", spec)
+	}
+
+	source, err := filepath.Abs(parts[0])
+	if err != nil {
+		return Mount{}, fmt.Errorf("failed to resolve container mount %q: %w", spec, err)
+	}
+
+	m := Mount{Source: source, Destination: parts[1]}
+	if len(parts) == 3 {
+		switch parts[2] {
+		case "ro":
+			m.ReadOnly = true
+		case "rw":
+		default:
+			return Mount{}, fmt.Errorf("container mount %q must end with :ro or :rw", spec)
+		}
+	}
+
+	return m, nil
+}
+
+// ParseMounts parses each spec with ParseMount
+func ParseMounts(specs []string) ([]Mount, error) {
+	mounts := make([]Mount, 0, len(specs))
+	for _, spec := range specs {
+		m, err := ParseMount(spec)
+		if err != nil {
+			return nil, err
+		}
+
+		mounts = append(mounts, m)
+	}
+
+	return mounts, nil
+}
+
+// inspected is the part of the output of docker image inspect that's recorded
+type inspected struct {
+	ID           string   `json:"Id"`
+	RepoDigests  []string `json:"RepoDigests"`
+	Os           string   `json:"Os"`
+	Architecture string   `json:"Architecture"`
+	Variant      string   `json:"Variant"`
+	Config       struct {
+		Entrypoint []string `json:"Entrypoint"`
+		Cmd        []string `json:"Cmd"`
+		User       string   `json:"User"`
+	} `json:"Config"`
+}
+
+// Inspect describes the image named by reference with runtime, pulling it first if it isn't present
+func Inspect(runtime, reference string) (Image, error) {
+	out, err := inspect(runtime, reference)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		pull := exec.Command(runtime, "pull", reference)
+		var stderr bytes.Buffer
+		pull.Stderr = &stderr
+		if pullErr := pull.Run(); pullErr != nil {
+			return Image{}, fmt.Errorf("failed to pull image %v: %w: %s", reference, pullErr, strings.TrimSpace(stderr.String()))
+		}
+
+		if out, err = inspect(runtime, reference); err != nil {
+			return Image{}, err
+		}
+	} else if err != nil {
+		return Image{}, err
+	}
+
+	var images []inspected
+	if err := json.Unmarshal(out, &images); err != nil {
+		return Image{}, fmt.Errorf("failed to parse inspected image %v: %w", reference, err)
+	}
+
+	if len(images) != 1 || images[0].ID == "" {
+		return Image{}, fmt.Errorf("inspecting image %v didn't describe one image", reference)
+	}
+
+	i := images[0]
+	image := Image{
+		Reference:   reference,
+		ID:          i.ID,
+		RepoDigests: i.RepoDigests,
+		Entrypoint:  i.Config.Entrypoint,
+		Cmd:         i.Config.Cmd,
+		User:        i.Config.User,
+	}
+
+	if i.Os != "" && i.Architecture != "" {
+		image.Platform = i.Os + "/" + i.Architecture
+		if i.Variant != "" {
+			image.Platform += "/" + i.Variant
+		}
+	}
+
+	return image, nil
+}
+
+func inspect(runtime, reference string) ([]byte, error) {
+	cmd := exec.Command(runtime, "image", "inspect", reference)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			return nil, fmt.Errorf("failed to run container runtime %v: %w", runtime, err)
+		}
+
+		return nil, fmt.Errorf("failed to inspect image %v: %w: %s", reference, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return out, nil
+}
+
+// Container is how a command is run in an image
+type Container struct {
+	// Runtime is the docker compatible CLI that runs the container
+	Runtime string
+	Image   Image
+	Mounts  []Mount
+	// WorkingDir is the directory in the container the command runs in
+	WorkingDir string
+	// EnvKeys are the environment variables passed from the runtime's environment to the container. Their values
+	// are set in the runtime's environment so they don't appear in its arguments.
+	EnvKeys []string
+}
+
+// Command returns the arguments that run args in the container. The container is removed when args exit, and the
+// runtime exits with their status.
+func (c Container) Command(args []string) []string {
+	cmd := []string{c.Runtime, "run", "--rm", "--interactive"}
+	for _, m := range c.Mounts {
+		mount := fmt.Sprintf("type=bind,source=%v,target=%v", m.Source, m.Destination)
+		if m.ReadOnly {
+			mount += ",readonly"
+		}
+
+		cmd = append(cmd, "--mount", mount)
+	}
+
+	if c.WorkingDir != "" {
+		cmd = append(cmd, "--workdir", c.WorkingDir)
+	}
+
+	for _, key := range c.EnvKeys {
+		cmd = append(cmd, "--env", key)
+	}
+
+	cmd = append(cmd, c.Image.ID)
+	return append(cmd, args...)
+}
@@ -0,0 +1,102 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containerexec
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRuntime writes a docker compatible CLI that knows one image, which it only inspects once it's been pulled
+func fakeRuntime(t *testing.T) string {
+	dir := t.TempDir()
+	runtime := filepath.Join(dir, "docker")
+	script := `#!/bin/sh
+pulled="` + filepath.Join(dir, "pulled") + `"
+case "$1 $2" in
+"image inspect")
+	if [ "$3" != "golang:1.18" ] || [ ! -e "$pulled" ]; then echo "No such image: $3" >&2; exit 1; fi
+	echo '[{"Id":"sha256:1111111111111111111111111111111111111111111111111111111111111111","RepoDigests":["golang@sha256:2222222222222222222222222222222222222222222222222222222222222222"],"Os":"linux","Architecture":"arm64","Variant":"v8","Config":{"Entrypoint":["/entry.sh"],"Cmd":["bash"],"User":"build"}}]'
+	;;
+"pull golang:1.18")
+	touch "$pulled"
+	;;
+*)
+	echo "unknown image" >&2; exit 1
+	;;
+esac
+`
+	require.NoError(t, os.WriteFile(runtime, []byte(script), 0755))
+	return runtime
+}
+
+func TestInspect(t *testing.T) {
+	runtime := fakeRuntime(t)
+	image, err := Inspect(runtime, "golang:1.18")
+	require.NoError(t, err)
+	assert.Equal(t, Image{
+		Reference:   "golang:1.18",
+		ID:          "sha256:1111111111111111111111111111111111111111111111111111111111111111",
+		RepoDigests: []string{"golang@sha256:2222222222222222222222222222222222222222222222222222222222222222"},
+		Platform:    "linux/arm64/v8",
+		Entrypoint:  []string{"/entry.sh"},
+		Cmd:         []string{"bash"},
+		User:        "build",
+	}, image)
+
+	_, err = Inspect(runtime, "missing:latest")
+	assert.ErrorContains(t, err, "failed to pull image missing:latest")
+	_, err = Inspect(filepath.Join(t.TempDir(), "docker"), "golang:1.18")
+	assert.ErrorContains(t, err, "failed to run container runtime")
+}
+
+func TestParseMount(t *testing.T) {
+	m, err := ParseMount("/cache:/root/.cache:ro")
+	require.NoError(t, err)
+	assert.Equal(t, Mount{Source: "/cache", Destination: "/root/.cache", ReadOnly: true}, m)
+	m, err = ParseMount("cache:/cache")
+	require.NoError(t, err)
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	assert.Equal(t, Mount{Source: filepath.Join(wd, "cache"), Destination: "/cache"}, m)
+
+	for _, spec := range []string{"/cache", "/cache:cache", "/cache:/cache:rx", ":/cache"} {
+		_, err := ParseMount(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestCommand(t *testing.T) {
+	c := Container{
+		Runtime:    "podman",
+		Image:      Image{ID: "sha256:1111"},
+		Mounts:     []Mount{{Source: "/src", Destination: "/src"}, {Source: "/cache", Destination: "/cache", ReadOnly: true}},
+		WorkingDir: "/src",
+		EnvKeys:    []string{"TOKEN"},
+	}
+
+	assert.Equal(t, []string{
+		"podman", "run", "--rm", "--interactive",
+		"--mount", "type=bind,source=/src,target=/src",
+		"--mount", "type=bind,source=/cache,target=/cache,readonly",
+		"--workdir", "/src",
+		"--env", "TOKEN",
+		"sha256:1111", "make", "all",
+	}, c.Command([]string{"make", "all"}))
+}
@@ -0,0 +1,332 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cose
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+const (
+	majorUnsigned = 0
+	majorNegative = 1
+	majorBytes    = 2
+	majorText     = 3
+	majorArray    = 4
+	majorMap      = 5
+	majorTag      = 6
+	majorSimple   = 7
+
+	// maxDepth bounds how deeply nested decoded items may be, so malicious messages can't exhaust the stack
+	maxDepth = 32
+)
+
+// Tag is a tagged CBOR item
+type Tag struct {
+	Number  uint64
+	Content interface{}
+}
+
+// Marshal encodes v as deterministic CBOR (RFC 8949 section 4.2): integers and lengths use their shortest form and
+// map keys are sorted by their encoding. Integers, strings, byte strings, booleans, nil, slices, Headers,
+// map[interface{}]interface{}, and Tag are supported, which is everything COSE headers need.
+func Marshal(v interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := encode(buf, v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func encode(buf *bytes.Buffer, v interface{}) error {
+	switch val := v.(type) {
+	case nil:
+		buf.WriteByte(majorSimple<<5 | 22)
+	case bool:
+		if val {
+			buf.WriteByte(majorSimple<<5 | 21)
+		} else {
+			buf.WriteByte(majorSimple<<5 | 20)
+		}
+	case int:
+		encodeInt(buf, int64(val))
+	case int64:
+		encodeInt(buf, val)
+	case uint64:
+		encodeHead(buf, majorUnsigned, val)
+	case string:
+		encodeHead(buf, majorText, uint64(len(val)))
+		buf.WriteString(val)
+	case []byte:
+		encodeHead(buf, majorBytes, uint64(len(val)))
+		buf.Write(val)
+	case []interface{}:
+		encodeHead(buf, majorArray, uint64(len(val)))
+		for _, item := range val {
+			if err := encode(buf, item); err != nil {
+				return err
+			}
+		}
+	case []string:
+		encodeHead(buf, majorArray, uint64(len(val)))
+		for _, item := range val {
+			_ = encode(buf, item)
+		}
+	case [][]byte:
+		encodeHead(buf, majorArray, uint64(len(val)))
+		for _, item := range val {
+			_ = encode(buf, item)
+		}
+	case Headers:
+		return encodeMap(buf, val)
+	case map[interface{}]interface{}:
+		return encodeMap(buf, val)
+	case Tag:
+		encodeHead(buf, majorTag, val.Number)
+		return encode(buf, val.Content)
+	default:
+		return fmt.Errorf("cannot encode %T as cbor", v)
+	}
+
+	return nil
+}
+
+func encodeInt(buf *bytes.Buffer, v int64) {
+	if v < 0 {
+		encodeHead(buf, majorNegative, uint64(-(v + 1)))
+		return
+	}
+
+	encodeHead(buf, majorUnsigned, uint64(v))
+}
+
+func encodeHead(buf *bytes.Buffer, major byte, arg uint64) {
+	switch {
+	case arg < 24:
+		buf.WriteByte(major<<5 | byte(arg))
+	case arg <= 0xff:
+		buf.WriteByte(major<<5 | 24)
+		buf.WriteByte(byte(arg))
+	case arg <= 0xffff:
+		buf.WriteByte(major<<5 | 25)
+		_ = binary.Write(buf, binary.BigEndian, uint16(arg))
+	case arg <= 0xffffffff:
+		buf.WriteByte(major<<5 | 26)
+		_ = binary.Write(buf, binary.BigEndian, uint32(arg))
+	default:
+		buf.WriteByte(major<<5 | 27)
+		_ = binary.Write(buf, binary.BigEndian, arg)
+	}
+}
+
+func encodeMap(buf *bytes.Buffer, m map[interface{}]interface{}) error {
+	type entry struct {
+		key, value []byte
+	}
+
+	entries := make([]entry, 0, len(m))
+	for k, v := range m {
+		key, err := Marshal(k)
+		if err != nil {
+			return err
+		}
+
+		value, err := Marshal(v)
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, entry{key, value})
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return bytes.Compare(entries[i].key, entries[j].key) < 0 })
+	encodeHead(buf, majorMap, uint64(len(entries)))
+	for _, e := range entries {
+		buf.Write(e.key)
+		buf.Write(e.value)
+	}
+
+	return nil
+}
+
+// Unmarshal decodes a single CBOR item that makes up all of data. Unsigned and negative integers decode to int64,
+// byte strings to []byte, text to string, arrays to []interface{}, maps to map[interface{}]interface{}, and tags to
+// Tag. Floats, indefinite length items, and integers that don't fit in an int64 aren't supported.
+func Unmarshal(data []byte) (interface{}, error) {
+	d := &decoder{data: data}
+	v, err := d.decode(0)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.off != len(d.data) {
+		return nil, fmt.Errorf("unexpected data after cbor item")
+	}
+
+	return v, nil
+}
+
+type decoder struct {
+	data []byte
+	off  int
+}
+
+func (d *decoder) decode(depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("cbor item is nested too deeply")
+	}
+
+	major, arg, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case majorUnsigned:
+		if arg > 1<<63-1 {
+			return nil, fmt.Errorf("cbor integer %v is too large", arg)
+		}
+
+		return int64(arg), nil
+	case majorNegative:
+		if arg > 1<<63-1 {
+			return nil, fmt.Errorf("cbor integer -%v is too small", arg)
+		}
+
+		return -1 - int64(arg), nil
+	case majorBytes, majorText:
+		content, err := d.take(arg)
+		if err != nil {
+			return nil, err
+		}
+
+		if major == majorText {
+			return string(content), nil
+		}
+
+		return append([]byte{}, content...), nil
+	case majorArray:
+		if arg > uint64(len(d.data)-d.off) {
+			return nil, fmt.Errorf("cbor array is longer than the data")
+		}
+
+		items := make([]interface{}, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			item, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+
+			items = append(items, item)
+		}
+
+		return items, nil
+	case majorMap:
+		if arg > uint64(len(d.data)-d.off) {
+			return nil, fmt.Errorf("cbor map is longer than the data")
+		}
+
+		m := make(map[interface{}]interface{}, arg)
+		for i := uint64(0); i < arg; i++ {
+			key, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, fmt.Errorf("unsupported cbor map key type %T", key)
+			}
+
+			if _, ok := m[key]; ok {
+				return nil, fmt.Errorf("duplicate cbor map key %v", key)
+			}
+
+			value, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+
+			m[key] = value
+		}
+
+		return m, nil
+	case majorTag:
+		content, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+
+		return Tag{Number: arg, Content: content}, nil
+	default:
+		switch arg {
+		case 20:
+			return false, nil
+		case 21:
+			return true, nil
+		case 22:
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("unsupported cbor simple value or float")
+	}
+}
+
+// head reads the initial byte of an item and its argument
+func (d *decoder) head() (byte, uint64, error) {
+	if d.off >= len(d.data) {
+		return 0, 0, fmt.Errorf("unexpected end of cbor data")
+	}
+
+	initial := d.data[d.off]
+	d.off++
+	major, info := initial>>5, initial&0x1f
+	if major == majorSimple && info > 23 {
+		return 0, 0, fmt.Errorf("unsupported cbor simple value or float")
+	}
+
+	switch {
+	case info < 24:
+		return major, uint64(info), nil
+	case info <= 27:
+		arg, err := d.take(1 << (info - 24))
+		if err != nil {
+			return 0, 0, err
+		}
+
+		var value uint64
+		for _, b := range arg {
+			value = value<<8 | uint64(b)
+		}
+
+		return major, value, nil
+	default:
+		return 0, 0, fmt.Errorf("indefinite length cbor items are not supported")
+	}
+}
+
+func (d *decoder) take(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.off) {
+		return nil, fmt.Errorf("unexpected end of cbor data")
+	}
+
+	out := d.data[d.off : d.off+int(n)]
+	d.off += int(n)
+	return out, nil
+}
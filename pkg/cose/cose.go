@@ -0,0 +1,394 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cose signs and verifies COSE_Sign1 messages (RFC 9052), the CBOR counterpart of DSSE envelopes used by
+// constrained environments and SCITT transparency services.
+package cose
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+)
+
+const (
+	// MediaType is the media type of COSE messages
+	MediaType = "application/cose"
+	// TagSign1 is the CBOR tag that marks a COSE_Sign1 message
+	TagSign1 = 18
+
+	// HeaderAlgorithm is the label of the header holding the signature algorithm
+	HeaderAlgorithm int64 = 1
+	// HeaderCritical is the label of the header listing headers verifiers must understand
+	HeaderCritical int64 = 2
+	// HeaderContentType is the label of the header holding the payload's content type
+	HeaderContentType int64 = 3
+	// HeaderKeyID is the label of the header identifying the signing key
+	HeaderKeyID int64 = 4
+	// HeaderX5Chain is the label of the header holding the signer's certificate chain, leaf first
+	HeaderX5Chain int64 = 33
+)
+
+// Algorithm is a COSE signature algorithm identifier
+type Algorithm int64
+
+const (
+	AlgorithmES256 Algorithm = -7
+	AlgorithmES384 Algorithm = -35
+	AlgorithmES512 Algorithm = -36
+	AlgorithmEdDSA Algorithm = -8
+	AlgorithmPS256 Algorithm = -37
+	AlgorithmPS384 Algorithm = -38
+	AlgorithmPS512 Algorithm = -39
+)
+
+// Headers are the protected or unprotected headers of a message, keyed by int64 labels or string names
+type Headers map[interface{}]interface{}
+
+// Message is a parsed COSE_Sign1 message
+type Message struct {
+	Protected    Headers
+	Unprotected  Headers
+	Payload      []byte
+	Signature    []byte
+	rawProtected []byte
+}
+
+// AlgorithmFor returns the algorithm messages signed by pub use. RSA keys sign with PSS, with a hash sized to the
+// key, and ECDSA keys with the hash matching their curve.
+func AlgorithmFor(pub crypto.PublicKey) (Algorithm, error) {
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		switch bits := key.Size() * 8; {
+		case bits >= 4096:
+			return AlgorithmPS512, nil
+		case bits >= 3072:
+			return AlgorithmPS384, nil
+		case bits >= 2048:
+			return AlgorithmPS256, nil
+		}
+
+		return 0, fmt.Errorf("cose signatures require an RSA key of at least 2048 bits")
+	case *ecdsa.PublicKey:
+		switch key.Curve {
+		case elliptic.P256():
+			return AlgorithmES256, nil
+		case elliptic.P384():
+			return AlgorithmES384, nil
+		case elliptic.P521():
+			return AlgorithmES512, nil
+		}
+
+		return 0, fmt.Errorf("unsupported ecdsa curve %v", key.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return AlgorithmEdDSA, nil
+	default:
+		return 0, fmt.Errorf("unsupported public key type %T", pub)
+	}
+}
+
+func (a Algorithm) hash() crypto.Hash {
+	switch a {
+	case AlgorithmES256, AlgorithmPS256:
+		return crypto.SHA256
+	case AlgorithmES384, AlgorithmPS384:
+		return crypto.SHA384
+	case AlgorithmES512, AlgorithmPS512:
+		return crypto.SHA512
+	default:
+		return 0
+	}
+}
+
+// Sign signs payload with key and returns the tagged COSE_Sign1 message. The algorithm header is set from the key.
+func Sign(key crypto.Signer, protected, unprotected Headers, payload []byte) ([]byte, error) {
+	return sign1(key, protected, unprotected, payload, false)
+}
+
+// SignDetached signs payload with key like Sign, but leaves the payload out of the message. Verifiers have to
+// reconstruct it, as with receipts that sign a Merkle tree root.
+func SignDetached(key crypto.Signer, protected, unprotected Headers, payload []byte) ([]byte, error) {
+	return sign1(key, protected, unprotected, payload, true)
+}
+
+func sign1(key crypto.Signer, protected, unprotected Headers, payload []byte, detached bool) ([]byte, error) {
+	alg, err := AlgorithmFor(key.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	headers := Headers{}
+	for label, value := range protected {
+		headers[label] = value
+	}
+
+	headers[HeaderAlgorithm] = int64(alg)
+	rawProtected, err := Marshal(headers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode protected headers: %w", err)
+	}
+
+	toBeSigned, err := sigStructure(rawProtected, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := sign(key, alg, toBeSigned)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &Message{Unprotected: unprotected, Payload: payload, Signature: sig, rawProtected: rawProtected}
+	if detached {
+		msg.Payload = nil
+	}
+
+	return msg.Encode()
+}
+
+// Encode encodes the message as a tagged COSE_Sign1 message, keeping the protected headers exactly as they were
+// signed
+func (m *Message) Encode() ([]byte, error) {
+	var payload interface{}
+	if m.Payload != nil {
+		payload = m.Payload
+	}
+
+	unprotected := m.Unprotected
+	if unprotected == nil {
+		unprotected = Headers{}
+	}
+
+	return Marshal(Tag{Number: TagSign1, Content: []interface{}{m.rawProtected, unprotected, payload, m.Signature}})
+}
+
+// Parse parses a COSE_Sign1 message, tagged or not. The signature isn't verified.
+func Parse(data []byte) (*Message, error) {
+	decoded, err := Unmarshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode cose message: %w", err)
+	}
+
+	if tag, ok := decoded.(Tag); ok {
+		if tag.Number != TagSign1 {
+			return nil, fmt.Errorf("cbor tag %v is not a COSE_Sign1 message", tag.Number)
+		}
+
+		decoded = tag.Content
+	}
+
+	parts, ok := decoded.([]interface{})
+	if !ok || len(parts) != 4 {
+		return nil, fmt.Errorf("COSE_Sign1 messages are arrays of four items")
+	}
+
+	rawProtected, ok := parts[0].([]byte)
+	if !ok {
+		return nil, fmt.Errorf("protected headers must be a byte string")
+	}
+
+	unprotected, ok := parts[1].(map[interface{}]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unprotected headers must be a map")
+	}
+
+	payload, ok := parts[2].([]byte)
+	if !ok && parts[2] != nil {
+		return nil, fmt.Errorf("payload must be a byte string or nil")
+	}
+
+	sig, ok := parts[3].([]byte)
+	if !ok {
+		return nil, fmt.Errorf("signature must be a byte string")
+	}
+
+	protected := map[interface{}]interface{}{}
+	if len(rawProtected) > 0 {
+		decodedProtected, err := Unmarshal(rawProtected)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode protected headers: %w", err)
+		}
+
+		if protected, ok = decodedProtected.(map[interface{}]interface{}); !ok {
+			return nil, fmt.Errorf("protected headers must be a map")
+		}
+	}
+
+	return &Message{Protected: protected, Unprotected: unprotected, Payload: payload, Signature: sig, rawProtected: rawProtected}, nil
+}
+
+// Algorithm returns the signature algorithm from the message's protected headers
+func (m *Message) Algorithm() (Algorithm, error) {
+	alg, ok := m.Protected[HeaderAlgorithm].(int64)
+	if !ok {
+		return 0, fmt.Errorf("message has no protected algorithm header")
+	}
+
+	return Algorithm(alg), nil
+}
+
+// ContentType returns the payload's content type, or an empty string if it has none or it's a CoAP content format
+func (m *Message) ContentType() string {
+	contentType, _ := m.Protected[HeaderContentType].(string)
+	return contentType
+}
+
+// Certificates returns the certificate chain from the message's x5chain header, which may be protected or not
+func (m *Message) Certificates() ([]*x509.Certificate, error) {
+	chain, ok := m.Protected[HeaderX5Chain]
+	if !ok {
+		chain = m.Unprotected[HeaderX5Chain]
+	}
+
+	ders := []interface{}{}
+	switch c := chain.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		ders = append(ders, c)
+	case []interface{}:
+		ders = c
+	default:
+		return nil, fmt.Errorf("x5chain header must be a byte string or array")
+	}
+
+	certs := make([]*x509.Certificate, 0, len(ders))
+	for _, der := range ders {
+		derBytes, ok := der.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("x5chain certificates must be byte strings")
+		}
+
+		cert, err := x509.ParseCertificate(derBytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate: %w", err)
+		}
+
+		certs = append(certs, cert)
+	}
+
+	return certs, nil
+}
+
+// Verify verifies the message was signed by pub with the algorithm in its protected headers
+func (m *Message) Verify(pub crypto.PublicKey) error {
+	if m.Payload == nil {
+		return fmt.Errorf("message has a detached payload")
+	}
+
+	return m.VerifyDetached(pub, m.Payload)
+}
+
+// VerifyDetached verifies the message was signed by pub over payload, which the message left out
+func (m *Message) VerifyDetached(pub crypto.PublicKey, payload []byte) error {
+	alg, err := m.Algorithm()
+	if err != nil {
+		return err
+	}
+
+	toBeSigned, err := sigStructure(m.rawProtected, payload)
+	if err != nil {
+		return err
+	}
+
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		if alg != AlgorithmPS256 && alg != AlgorithmPS384 && alg != AlgorithmPS512 {
+			return fmt.Errorf("algorithm %v can't be used with an RSA key", alg)
+		}
+
+		if err := rsa.VerifyPSS(key, alg.hash(), digest(alg.hash(), toBeSigned), m.Signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}); err != nil {
+			return fmt.Errorf("signature is invalid: %w", err)
+		}
+	case *ecdsa.PublicKey:
+		if expected, err := AlgorithmFor(key); err != nil || expected != alg {
+			return fmt.Errorf("algorithm %v can't be used with this ECDSA key", alg)
+		}
+
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(m.Signature) != 2*size {
+			return fmt.Errorf("signature is invalid")
+		}
+
+		r, s := new(big.Int).SetBytes(m.Signature[:size]), new(big.Int).SetBytes(m.Signature[size:])
+		if !ecdsa.Verify(key, digest(alg.hash(), toBeSigned), r, s) {
+			return fmt.Errorf("signature is invalid")
+		}
+	case ed25519.PublicKey:
+		if alg != AlgorithmEdDSA {
+			return fmt.Errorf("algorithm %v can't be used with an ed25519 key", alg)
+		}
+
+		if !ed25519.Verify(key, toBeSigned, m.Signature) {
+			return fmt.Errorf("signature is invalid")
+		}
+	default:
+		return fmt.Errorf("unsupported public key type %T", pub)
+	}
+
+	return nil
+}
+
+// sigStructure builds the Sig_structure a COSE_Sign1 signature signs, with no external data
+func sigStructure(rawProtected, payload []byte) ([]byte, error) {
+	return Marshal([]interface{}{"Signature1", rawProtected, []byte{}, payload})
+}
+
+func digest(hash crypto.Hash, data []byte) []byte {
+	h := hash.New()
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func sign(key crypto.Signer, alg Algorithm, toBeSigned []byte) ([]byte, error) {
+	switch pub := key.Public().(type) {
+	case *rsa.PublicKey:
+		return key.Sign(rand.Reader, digest(alg.hash(), toBeSigned), &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: alg.hash()})
+	case *ecdsa.PublicKey:
+		sig, err := key.Sign(rand.Reader, digest(alg.hash(), toBeSigned), alg.hash())
+		if err != nil {
+			return nil, err
+		}
+
+		return rawECDSASignature(pub, sig)
+	case ed25519.PublicKey:
+		return key.Sign(rand.Reader, toBeSigned, crypto.Hash(0))
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", pub)
+	}
+}
+
+// rawECDSASignature converts an ASN.1 ECDSA signature to the fixed size r || s form COSE uses
+func rawECDSASignature(key *ecdsa.PublicKey, sig []byte) ([]byte, error) {
+	parsed := struct {
+		R, S *big.Int
+	}{}
+
+	if _, err := asn1.Unmarshal(sig, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse ecdsa signature: %w", err)
+	}
+
+	size := (key.Curve.Params().BitSize + 7) / 8
+	raw := make([]byte, 2*size)
+	parsed.R.FillBytes(raw[:size])
+	parsed.S.FillBytes(raw[size:])
+	return raw, nil
+}
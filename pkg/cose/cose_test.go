@@ -0,0 +1,169 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cose
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshal(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		expected string
+	}{
+		{int64(0), "00"},
+		{int64(23), "17"},
+		{int64(24), "1818"},
+		{int64(1000), "1903e8"},
+		{int64(-1), "20"},
+		{int64(-37), "3824"},
+		{int64(4294967296), "1b0000000100000000"},
+		{"a", "6161"},
+		{[]byte{1, 2}, "420102"},
+		{[]interface{}{int64(1), "a"}, "82016161"},
+		{map[interface{}]interface{}{"b": int64(1), int64(10): int64(2), int64(-1): int64(3)}, "a30a022003616201"},
+		{Tag{Number: 1, Content: int64(1363896240)}, "c11a514b67b0"},
+		{true, "f5"},
+		{nil, "f6"},
+	}
+
+	for _, test := range tests {
+		encoded, err := Marshal(test.value)
+		require.NoError(t, err)
+		assert.Equal(t, test.expected, hex.EncodeToString(encoded))
+
+		decoded, err := Unmarshal(encoded)
+		require.NoError(t, err)
+		assert.Equal(t, test.value, decoded)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	for name, data := range map[string]string{
+		"truncated":           "1903",
+		"trailing data":       "0000",
+		"indefinite length":   "9f00ff",
+		"float":               "f93c00",
+		"duplicate map key":   "a201020103",
+		"byte string map key": "a1410100",
+		"long array":          "9affffffff",
+		"nested too deeply":   "8181818181818181818181818181818181818181818181818181818181818181818100",
+	} {
+		t.Run(name, func(t *testing.T) {
+			data, err := hex.DecodeString(data)
+			require.NoError(t, err)
+			_, err = Unmarshal(data)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	for name, test := range map[string]struct {
+		key crypto.Signer
+		alg Algorithm
+	}{
+		"rsa":     {rsaKey, AlgorithmPS256},
+		"p-384":   {p384Key, AlgorithmES384},
+		"ed25519": {edKey, AlgorithmEdDSA},
+	} {
+		t.Run(name, func(t *testing.T) {
+			payload := []byte(`{"hello":"world"}`)
+			signed, err := Sign(test.key, Headers{HeaderContentType: "application/json"}, Headers{HeaderKeyID: []byte("key")}, payload)
+			require.NoError(t, err)
+			assert.Equal(t, byte(0xd2), signed[0], "messages are tagged as COSE_Sign1")
+
+			msg, err := Parse(signed)
+			require.NoError(t, err)
+			alg, err := msg.Algorithm()
+			require.NoError(t, err)
+			assert.Equal(t, test.alg, alg)
+			assert.Equal(t, "application/json", msg.ContentType())
+			assert.Equal(t, []byte("key"), msg.Unprotected[HeaderKeyID])
+			assert.Equal(t, payload, msg.Payload)
+			require.NoError(t, msg.Verify(test.key.Public()))
+
+			msg.Payload = []byte(`{"hello":"mallory"}`)
+			assert.Error(t, msg.Verify(test.key.Public()))
+		})
+	}
+}
+
+func TestVerifyRejectsWrongKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+
+	signed, err := Sign(key, nil, nil, []byte("payload"))
+	require.NoError(t, err)
+	msg, err := Parse(signed)
+	require.NoError(t, err)
+	assert.ErrorContains(t, msg.Verify(&other.PublicKey), "signature is invalid")
+	assert.ErrorContains(t, msg.Verify(&p384Key.PublicKey), "can't be used")
+}
+
+func TestCertificates(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "signer"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	for name, chain := range map[string]interface{}{
+		"single certificate": der,
+		"chain":              []interface{}{der},
+	} {
+		t.Run(name, func(t *testing.T) {
+			signed, err := Sign(key, Headers{HeaderX5Chain: chain}, nil, []byte("payload"))
+			require.NoError(t, err)
+			msg, err := Parse(signed)
+			require.NoError(t, err)
+			certs, err := msg.Certificates()
+			require.NoError(t, err)
+			require.Len(t, certs, 1)
+			assert.Equal(t, "signer", certs[0].Subject.CommonName)
+		})
+	}
+}
@@ -0,0 +1,321 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cosign verifies the signatures cosign stores alongside container images, so a policy can require that an
+// image is also signed with cosign by one of its functionaries. Signatures are checked the same way cosign verify
+// checks them with a public key or a certificate. Signatures from short lived Fulcio certificates aren't accepted,
+// since trusting them requires the transparency log entry that proves when they were made.
+package cosign
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/policy"
+	"github.com/testifysec/witness/pkg/trust"
+)
+
+const (
+	// SimpleSigningMediaType is the media type of the layers of a cosign signature manifest
+	SimpleSigningMediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+	// SignatureAnnotation holds the base64 encoded signature of a layer's payload
+	SignatureAnnotation = "dev.cosignproject.cosign/signature"
+	// CertificateAnnotation holds the PEM encoded certificate of the key that made the signature, if it was made
+	// with a certificate
+	CertificateAnnotation = "dev.sigstore.cosign/certificate"
+	// ChainAnnotation holds the PEM encoded intermediates of the certificate
+	ChainAnnotation = "dev.sigstore.cosign/chain"
+
+	signatureType = "cosign container image signature"
+)
+
+// Layer is a layer of a cosign signature manifest. Each layer is a signed payload identifying the image.
+type Layer struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// Signature is a payload cosign signed and the signature and certificate it stored with it
+type Signature struct {
+	Payload     []byte
+	Signature   []byte
+	Certificate []byte
+	Chain       []byte
+}
+
+// SignatureTag returns the tag cosign stores the signatures of the image with manifestDigest under
+func SignatureTag(manifestDigest string) string {
+	return strings.Replace(manifestDigest, ":", "-", 1) + ".sig"
+}
+
+// SignatureLayers returns the signature layers of a cosign signature manifest
+func SignatureLayers(manifest []byte) ([]Layer, error) {
+	parsed := struct {
+		Layers []Layer `json:"layers"`
+	}{}
+
+	if err := json.Unmarshal(manifest, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse signature manifest: %w", err)
+	}
+
+	layers := []Layer{}
+	for _, layer := range parsed.Layers {
+		if layer.MediaType == SimpleSigningMediaType && layer.Annotations[SignatureAnnotation] != "" {
+			layers = append(layers, layer)
+		}
+	}
+
+	return layers, nil
+}
+
+// NewSignature returns the signature of a layer, given the payload stored in the layer's blob
+func NewSignature(layer Layer, payload []byte) (Signature, error) {
+	sig, err := base64.StdEncoding.DecodeString(layer.Annotations[SignatureAnnotation])
+	if err != nil {
+		return Signature{}, fmt.Errorf("failed to decode signature: %w", err)
+	}
+
+	return Signature{
+		Payload:     payload,
+		Signature:   sig,
+		Certificate: []byte(layer.Annotations[CertificateAnnotation]),
+		Chain:       []byte(layer.Annotations[ChainAnnotation]),
+	}, nil
+}
+
+// policyCosign is the subset of a witness policy that requires cosign signatures. cosign isn't part of the
+// go-witness policy type, so it's read separately from the same policy document.
+type policyCosign struct {
+	Cosign *struct {
+		Functionaries []policy.Functionary `json:"functionaries"`
+	} `json:"cosign,omitempty"`
+}
+
+// ErrNoValidSignature is returned when none of an image's cosign signatures were made by a functionary
+type ErrNoValidSignature struct {
+	Reasons []string
+}
+
+func (e ErrNoValidSignature) Error() string {
+	if len(e.Reasons) == 0 {
+		return "no cosign signatures found"
+	}
+
+	return fmt.Sprintf("no valid cosign signature from a trusted functionary: %v", strings.Join(e.Reasons, "; "))
+}
+
+// Requirement checks the cosign signatures of images against the functionaries a policy trusts to sign them
+type Requirement struct {
+	functionaries []policy.Functionary
+	publicKeys    map[string]crypto.PublicKey
+	trusted       trust.Policy
+}
+
+// FromPolicy reads the cosign requirement from a policy document. A nil Requirement is returned if the policy
+// doesn't require cosign signatures.
+func FromPolicy(policyBytes []byte) (*Requirement, error) {
+	p := policyCosign{}
+	if err := json.Unmarshal(policyBytes, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse policy: %w", err)
+	}
+
+	if p.Cosign == nil {
+		return nil, nil
+	}
+
+	if len(p.Cosign.Functionaries) == 0 {
+		return nil, fmt.Errorf("cosign requires at least one functionary")
+	}
+
+	pol := policy.Policy{}
+	if err := json.Unmarshal(policyBytes, &pol); err != nil {
+		return nil, fmt.Errorf("failed to parse policy: %w", err)
+	}
+
+	trusted, err := trust.FromPolicy(pol)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKeys := make(map[string]crypto.PublicKey)
+	for _, functionary := range p.Cosign.Functionaries {
+		if functionary.PublicKeyID == "" {
+			continue
+		}
+
+		key, ok := pol.PublicKeys[functionary.PublicKeyID]
+		if !ok {
+			return nil, fmt.Errorf("cosign functionary %v is not a public key in the policy", functionary.PublicKeyID)
+		}
+
+		pub, err := cryptoutil.TryParseKeyFromReader(bytes.NewReader(key.Key))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse public key %v: %w", functionary.PublicKeyID, err)
+		}
+
+		publicKeys[functionary.PublicKeyID] = pub
+	}
+
+	return &Requirement{
+		functionaries: p.Cosign.Functionaries,
+		publicKeys:    publicKeys,
+		trusted:       trusted,
+	}, nil
+}
+
+// Check returns an error unless one of the signatures is a valid cosign signature of the image with manifestDigest,
+// made by one of the requirement's functionaries
+func (r *Requirement) Check(signatures []Signature, manifestDigest string) error {
+	if r == nil {
+		return nil
+	}
+
+	reasons := []string{}
+	for i, sig := range signatures {
+		if err := checkPayload(sig.Payload, manifestDigest); err != nil {
+			reasons = append(reasons, fmt.Sprintf("signature %d: %v", i, err))
+			continue
+		}
+
+		if err := r.checkSignature(sig); err != nil {
+			reasons = append(reasons, fmt.Sprintf("signature %d: %v", i, err))
+			continue
+		}
+
+		return nil
+	}
+
+	return ErrNoValidSignature{Reasons: reasons}
+}
+
+func (r *Requirement) checkSignature(sig Signature) error {
+	if len(sig.Certificate) == 0 {
+		for _, pub := range r.publicKeys {
+			if verifySignature(pub, sig.Payload, sig.Signature) == nil {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("not signed by a functionary's public key")
+	}
+
+	certs, err := parseCertificates(sig.Certificate)
+	if err != nil || len(certs) == 0 {
+		return fmt.Errorf("failed to parse certificate: %v", err)
+	}
+
+	intermediates := []*x509.Certificate{}
+	if len(sig.Chain) > 0 {
+		if intermediates, err = parseCertificates(sig.Chain); err != nil {
+			return fmt.Errorf("failed to parse certificate chain: %w", err)
+		}
+	}
+
+	verifier, err := cryptoutil.NewX509Verifier(certs[0], intermediates, nil, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if !r.trusted.IsFunctionary(r.functionaries, verifier) {
+		return fmt.Errorf("certificate %v does not belong to a functionary", certs[0].Subject)
+	}
+
+	return verifySignature(certs[0].PublicKey, sig.Payload, sig.Signature)
+}
+
+// checkPayload checks that a simple signing payload identifies the image with manifestDigest
+func checkPayload(payload []byte, manifestDigest string) error {
+	parsed := struct {
+		Critical struct {
+			Image struct {
+				DockerManifestDigest string `json:"docker-manifest-digest"`
+			} `json:"image"`
+			Type string `json:"type"`
+		} `json:"critical"`
+	}{}
+
+	if err := json.Unmarshal(payload, &parsed); err != nil {
+		return fmt.Errorf("failed to parse payload: %w", err)
+	}
+
+	if parsed.Critical.Type != signatureType {
+		return fmt.Errorf("unexpected payload type %q", parsed.Critical.Type)
+	}
+
+	if parsed.Critical.Image.DockerManifestDigest != manifestDigest {
+		return fmt.Errorf("payload signs %v, not %v", parsed.Critical.Image.DockerManifestDigest, manifestDigest)
+	}
+
+	return nil
+}
+
+// verifySignature verifies sig over payload the way cosign signs: ECDSA and RSA PKCS #1 v1.5 signatures over the
+// SHA-256 digest of the payload, or ed25519 signatures over the payload itself
+func verifySignature(pub crypto.PublicKey, payload, sig []byte) error {
+	digest := sha256.Sum256(payload)
+	switch key := pub.(type) {
+	case *ecdsa.PublicKey:
+		if ecdsa.VerifyASN1(key, digest[:], sig) {
+			return nil
+		}
+	case *rsa.PublicKey:
+		if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) == nil {
+			return nil
+		}
+
+		if rsa.VerifyPSS(key, crypto.SHA256, digest[:], sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto}) == nil {
+			return nil
+		}
+	case ed25519.PublicKey:
+		if ed25519.Verify(key, payload, sig) {
+			return nil
+		}
+	default:
+		return fmt.Errorf("unsupported public key type %T", pub)
+	}
+
+	return fmt.Errorf("signature is invalid")
+}
+
+// parseCertificates parses every PEM encoded certificate in pemBytes
+func parseCertificates(pemBytes []byte) ([]*x509.Certificate, error) {
+	certs := []*x509.Certificate{}
+	for block, rest := pem.Decode(pemBytes); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		certs = append(certs, cert)
+	}
+
+	return certs, nil
+}
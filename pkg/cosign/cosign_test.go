@@ -0,0 +1,134 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/policy"
+)
+
+const testDigest = "sha256:b5bb9d8014a0f9b1d61e21e796d78dccdf1352f23cd32812f4850b878ae4944c"
+
+func testPayload(digest string) []byte {
+	return []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"example.com/app"},"image":{"docker-manifest-digest":"%v"},"type":"cosign container image signature"},"optional":null}`, digest))
+}
+
+// testPolicy returns a policy that trusts the RSA key to sign images with cosign
+func testPolicy(t *testing.T, pub *rsa.PublicKey) []byte {
+	keyID, err := cryptoutil.NewRSAVerifier(pub, crypto.SHA256).KeyID()
+	require.NoError(t, err)
+	keyBytes, err := x509.MarshalPKIXPublicKey(pub)
+	require.NoError(t, err)
+	p := map[string]interface{}{
+		"publickeys": map[string]policy.PublicKey{
+			keyID: {KeyID: keyID, Key: pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: keyBytes})},
+		},
+		"cosign": map[string]interface{}{
+			"functionaries": []policy.Functionary{{Type: "PublicKey", PublicKeyID: keyID}},
+		},
+	}
+
+	policyBytes, err := json.Marshal(p)
+	require.NoError(t, err)
+	return policyBytes
+}
+
+func TestCheck(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	requirement, err := FromPolicy(testPolicy(t, &priv.PublicKey))
+	require.NoError(t, err)
+	require.NotNil(t, requirement)
+
+	sign := func(payload []byte) []byte {
+		digest := sha256.Sum256(payload)
+		sig, err := rsa.SignPKCS1v15(rand.Reader, priv, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+		return sig
+	}
+
+	payload := testPayload(testDigest)
+	valid := Signature{Payload: payload, Signature: sign(payload)}
+	assert.NoError(t, requirement.Check([]Signature{valid}, testDigest))
+
+	otherDigest := testPayload("sha256:0000")
+	wrongImage := Signature{Payload: otherDigest, Signature: sign(otherDigest)}
+	tampered := Signature{Payload: testPayload(testDigest + "0"), Signature: valid.Signature}
+	err = requirement.Check([]Signature{wrongImage}, testDigest)
+	assert.ErrorContains(t, err, "payload signs sha256:0000")
+	assert.Error(t, requirement.Check([]Signature{tampered}, testDigest))
+
+	// any valid signature is enough
+	assert.NoError(t, requirement.Check([]Signature{wrongImage, valid}, testDigest))
+
+	err = requirement.Check(nil, testDigest)
+	assert.Equal(t, ErrNoValidSignature{Reasons: []string{}}, err)
+	assert.EqualError(t, err, "no cosign signatures found")
+
+	var nilRequirement *Requirement
+	assert.NoError(t, nilRequirement.Check(nil, testDigest))
+}
+
+func TestVerifySignature(t *testing.T) {
+	payload := testPayload(testDigest)
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	assert.NoError(t, verifySignature(pub, payload, ed25519.Sign(priv, payload)))
+	assert.Error(t, verifySignature(pub, payload, ed25519.Sign(priv, []byte("other"))))
+	assert.Error(t, verifySignature("not a key", payload, nil))
+}
+
+func TestFromPolicy(t *testing.T) {
+	requirement, err := FromPolicy([]byte(`{"steps": {}}`))
+	require.NoError(t, err)
+	assert.Nil(t, requirement)
+
+	_, err = FromPolicy([]byte(`{"cosign": {"functionaries": []}}`))
+	assert.ErrorContains(t, err, "at least one functionary")
+
+	_, err = FromPolicy([]byte(`{"cosign": {"functionaries": [{"type": "PublicKey", "publickeyid": "missing"}]}}`))
+	assert.ErrorContains(t, err, "is not a public key in the policy")
+}
+
+func TestSignatureLayers(t *testing.T) {
+	assert.Equal(t, "sha256-abc.sig", SignatureTag("sha256:abc"))
+	manifest := fmt.Sprintf(`{"layers": [
+		{"mediaType": %q, "digest": "sha256:1", "annotations": {%q: "c2ln"}},
+		{"mediaType": "application/octet-stream", "digest": "sha256:2", "annotations": {%q: "c2ln"}},
+		{"mediaType": %q, "digest": "sha256:3"}
+	]}`, SimpleSigningMediaType, SignatureAnnotation, SignatureAnnotation, SimpleSigningMediaType)
+
+	layers, err := SignatureLayers([]byte(manifest))
+	require.NoError(t, err)
+	require.Len(t, layers, 1)
+	assert.Equal(t, "sha256:1", layers[0].Digest)
+
+	sig, err := NewSignature(layers[0], []byte("payload"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("sig"), sig.Signature)
+}
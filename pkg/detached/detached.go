@@ -0,0 +1,113 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package detached signs attestations over the digest of their predicate rather than the predicate itself, so the
+// signed statement can be published to transparency logs without the predicate's content. The predicate is kept
+// out of band and bound to the statement again when it's verified.
+package detached
+
+import (
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+)
+
+// PredicateType is the type of a statement whose predicate is the digest of a detached predicate
+const PredicateType = "https://witness.dev/detached-predicate/v0.1"
+
+// Predicate links a statement to a predicate stored out of band
+type Predicate struct {
+	// Name is the name of the detached predicate, such as the step a collection was recorded for
+	Name string `json:"name"`
+	// PredicateType is the type of the detached predicate
+	PredicateType string               `json:"predicateType"`
+	Digest        cryptoutil.DigestSet `json:"digest"`
+}
+
+// New returns the predicate that links a statement to body, the detached predicate of predicateType
+func New(name, predicateType string, body []byte) (Predicate, error) {
+	digest, err := cryptoutil.CalculateDigestSetFromBytes(body, []crypto.Hash{crypto.SHA256})
+	if err != nil {
+		return Predicate{}, err
+	}
+
+	return Predicate{Name: name, PredicateType: predicateType, Digest: digest}, nil
+}
+
+// Store holds detached predicates by digest
+type Store struct {
+	bodies map[string][]byte
+}
+
+func NewStore() *Store {
+	return &Store{bodies: make(map[string][]byte)}
+}
+
+// Add adds the detached predicate body to the store
+func (s *Store) Add(body []byte) error {
+	digest, err := cryptoutil.CalculateDigestSetFromBytes(body, []crypto.Hash{crypto.SHA256})
+	if err != nil {
+		return err
+	}
+
+	s.bodies[digest[crypto.SHA256]] = body
+	return nil
+}
+
+// LoadFile adds the detached predicate in the file at path to the store
+func (s *Store) LoadFile(path string) error {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read detached predicate: %w", err)
+	}
+
+	return s.Add(body)
+}
+
+// Resolve returns the body of the detached predicate p links to. It's an error if the store doesn't have it. A nil
+// store has no predicates.
+func (s *Store) Resolve(p Predicate) ([]byte, error) {
+	digest, ok := p.Digest[crypto.SHA256]
+	if !ok {
+		return nil, fmt.Errorf("detached predicate %v has no sha256 digest", p.Name)
+	}
+
+	if s == nil {
+		return nil, fmt.Errorf("the predicate of %v is detached and its body wasn't provided", p.Name)
+	}
+
+	body, ok := s.bodies[digest]
+	if !ok {
+		return nil, fmt.Errorf("the predicate of %v is detached and no body matching sha256:%v was provided", p.Name, digest)
+	}
+
+	return body, nil
+}
+
+// Parse parses the detached predicate link in a statement's predicate
+func Parse(predicate json.RawMessage) (Predicate, error) {
+	p := Predicate{}
+	if err := json.Unmarshal(predicate, &p); err != nil {
+		return p, fmt.Errorf("failed to parse detached predicate: %w", err)
+	}
+
+	if p.PredicateType == "" || len(p.Digest) == 0 {
+		return p, fmt.Errorf("detached predicate must have a predicate type and digest")
+	}
+
+	return p, nil
+}
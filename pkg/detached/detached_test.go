@@ -0,0 +1,72 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package detached
+
+import (
+	"crypto"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreResolve(t *testing.T) {
+	body := []byte(`{"name":"build","attestations":[]}`)
+	p, err := New("build", "https://witness.testifysec.com/attestation-collection/v0.1", body)
+	require.NoError(t, err)
+	assert.Contains(t, p.Digest, crypto.SHA256)
+
+	var nilStore *Store
+	_, err = nilStore.Resolve(p)
+	assert.Error(t, err)
+
+	store := NewStore()
+	_, err = store.Resolve(p)
+	assert.Error(t, err)
+
+	path := filepath.Join(t.TempDir(), "collection.json")
+	require.NoError(t, os.WriteFile(path, body, 0600))
+	require.NoError(t, store.LoadFile(path))
+	resolved, err := store.Resolve(p)
+	require.NoError(t, err)
+	assert.Equal(t, body, resolved)
+
+	require.NoError(t, store.Add(append(body, '\n')))
+	other, err := New("build", p.PredicateType, []byte(`{}`))
+	require.NoError(t, err)
+	_, err = store.Resolve(other)
+	assert.Error(t, err)
+}
+
+func TestParse(t *testing.T) {
+	p, err := New("build", "https://example.com/predicate", []byte("body"))
+	require.NoError(t, err)
+	raw, err := json.Marshal(p)
+	require.NoError(t, err)
+
+	parsed, err := Parse(raw)
+	require.NoError(t, err)
+	assert.Equal(t, p.Name, parsed.Name)
+	assert.Equal(t, p.PredicateType, parsed.PredicateType)
+	assert.True(t, p.Digest.Equal(parsed.Digest))
+
+	_, err = Parse([]byte(`{"name":"build"}`))
+	assert.Error(t, err)
+	_, err = Parse([]byte(`not json`))
+	assert.Error(t, err)
+}
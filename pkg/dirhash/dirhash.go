@@ -0,0 +1,88 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dirhash calculates deterministic digests of directory trees so whole
+// directories can be used as attestation subjects.
+package dirhash
+
+import (
+	"bytes"
+	"crypto"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+)
+
+// Hash calculates a deterministic digest set for the directory tree rooted at dir.
+//
+// Every regular file in the tree is hashed with sha256 and a summary made of one
+// "<hex digest>  <relative path>\n" line per file, sorted by path, is hashed with each
+// of the provided hash functions. Paths are always slash separated so the same tree
+// produces the same digest on every platform. This mirrors the h1 dirhash used by Go
+// modules, encoded in hex like the rest of witness's digests.
+func Hash(dir string, hashes []crypto.Hash) (cryptoutil.DigestSet, error) {
+	summary, err := Summary(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return cryptoutil.CalculateDigestSetFromBytes(summary, hashes)
+}
+
+// Summary returns the sorted file listing that Hash digests.
+func Summary(dir string) ([]byte, error) {
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		digest, err := cryptoutil.CalculateDigestSetFromFile(path, []crypto.Hash{crypto.SHA256})
+		if err != nil {
+			return err
+		}
+
+		files[filepath.ToSlash(relPath)] = digest[crypto.SHA256]
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk directory %v: %w", dir, err)
+	}
+
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+	buf := bytes.Buffer{}
+	for _, path := range paths {
+		fmt.Fprintf(&buf, "%s  %s\n", files[path], path)
+	}
+
+	return buf.Bytes(), nil
+}
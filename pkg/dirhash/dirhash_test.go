@@ -0,0 +1,68 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dirhash
+
+import (
+	"crypto"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	for path, content := range files {
+		fullPath := filepath.Join(dir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0755))
+		require.NoError(t, os.WriteFile(fullPath, []byte(content), 0644))
+	}
+}
+
+func TestHashDeterministic(t *testing.T) {
+	files := map[string]string{
+		"index.html":        "<html></html>",
+		"assets/app.js":     "console.log('hi')",
+		"assets/style.css":  "body {}",
+		"nested/deep/a.txt": "a",
+	}
+
+	first := t.TempDir()
+	second := t.TempDir()
+	writeTree(t, first, files)
+	writeTree(t, second, files)
+
+	firstDigest, err := Hash(first, []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+	secondDigest, err := Hash(second, []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+	assert.Equal(t, firstDigest, secondDigest)
+
+	writeTree(t, second, map[string]string{"assets/app.js": "console.log('bye')"})
+	changedDigest, err := Hash(second, []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+	assert.NotEqual(t, firstDigest, changedDigest)
+}
+
+func TestSummary(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"b.txt": "b", "a/c.txt": "c"})
+	summary, err := Summary(dir)
+	require.NoError(t, err)
+	expected := "2e7d2c03a9507ae265ecf5b5356885a53393a2029d241394997265a1a25aefc6  a/c.txt\n" +
+		"3e23e8160039594a33894f6564e1b1348bbd7a0088d42c4acb73eeaed59c009d  b.txt\n"
+	assert.Equal(t, expected, string(summary))
+}
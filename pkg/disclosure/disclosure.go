@@ -0,0 +1,378 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package disclosure signs attestations over a Merkle tree of their predicate's fields, so the producer can later
+// disclose individual fields, such as the builder ID but not the environment, with proofs that they're part of the
+// signed predicate. Each leaf is salted so undisclosed fields with few possible values can't be guessed from the
+// tree.
+package disclosure
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/testifysec/go-witness/dsse"
+)
+
+const (
+	// PredicateType is the type of a statement whose predicate is the Merkle root of a disclosable predicate
+	PredicateType = "https://witness.dev/disclosable-predicate/v0.1"
+	// BundleType identifies a bundle holding a disclosable predicate and the seed its salts are derived from
+	BundleType = "https://witness.dev/disclosure-bundle/v0.1"
+
+	seedSize = 32
+)
+
+// Predicate links a statement to the Merkle root of a predicate kept in a bundle
+type Predicate struct {
+	// Name is the name of the predicate, such as the step a collection was recorded for
+	Name string `json:"name"`
+	// PredicateType is the type of the disclosable predicate
+	PredicateType string `json:"predicateType"`
+	// Root is the hex encoded Merkle tree hash of the predicate's fields
+	Root   string `json:"root"`
+	Leaves int    `json:"leaves"`
+}
+
+// Bundle holds a disclosable predicate and the secret seed its leaf salts are derived from. It must be kept
+// private; fields are disclosed from it with Disclose.
+type Bundle struct {
+	Type          string          `json:"type"`
+	Name          string          `json:"name"`
+	PredicateType string          `json:"predicateType"`
+	Seed          []byte          `json:"seed"`
+	Predicate     json.RawMessage `json:"predicate"`
+}
+
+// Leaf is a single field of a predicate, addressed by its JSON pointer
+type Leaf struct {
+	Path  string
+	Value json.RawMessage
+	Salt  []byte
+}
+
+// Field is a disclosed leaf with its proof of inclusion in the signed Merkle root
+type Field struct {
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+	Salt  string          `json:"salt"`
+	Index int             `json:"index"`
+	Proof []string        `json:"proof"`
+}
+
+// Disclosure is a signed envelope with a disclosable predicate and the fields disclosed from it
+type Disclosure struct {
+	Envelope dsse.Envelope `json:"envelope"`
+	Fields   []Field       `json:"fields"`
+}
+
+// NewBundle returns a bundle for predicate with a new random seed
+func NewBundle(name, predicateType string, predicate []byte) (Bundle, error) {
+	seed := make([]byte, seedSize)
+	if _, err := rand.Read(seed); err != nil {
+		return Bundle{}, fmt.Errorf("failed to generate disclosure seed: %w", err)
+	}
+
+	return Bundle{Type: BundleType, Name: name, PredicateType: predicateType, Seed: seed, Predicate: predicate}, nil
+}
+
+// IsBundle returns whether data is a disclosure bundle
+func IsBundle(data []byte) bool {
+	b := struct {
+		Type string `json:"type"`
+	}{}
+
+	return json.Unmarshal(data, &b) == nil && b.Type == BundleType
+}
+
+// LoadBundle reads the disclosure bundle in the file at path
+func LoadBundle(path string) (Bundle, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Bundle{}, fmt.Errorf("failed to read disclosure bundle: %w", err)
+	}
+
+	return ParseBundle(data)
+}
+
+// ParseBundle parses a disclosure bundle
+func ParseBundle(data []byte) (Bundle, error) {
+	b := Bundle{}
+	if err := json.Unmarshal(data, &b); err != nil {
+		return b, fmt.Errorf("failed to parse disclosure bundle: %w", err)
+	}
+
+	if b.Type != BundleType {
+		return b, fmt.Errorf("not a disclosure bundle")
+	}
+
+	if len(b.Seed) != seedSize {
+		return b, fmt.Errorf("disclosure bundle seed must be %d bytes", seedSize)
+	}
+
+	return b, nil
+}
+
+// Leaves returns the fields of the bundle's predicate, sorted by path
+func (b Bundle) Leaves() ([]Leaf, error) {
+	dec := json.NewDecoder(bytes.NewReader(b.Predicate))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return nil, fmt.Errorf("failed to parse disclosable predicate: %w", err)
+	}
+
+	leaves := []Leaf{}
+	if err := flatten("", value, &leaves); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(leaves, func(i, j int) bool { return leaves[i].Path < leaves[j].Path })
+	for i := range leaves {
+		mac := hmac.New(sha256.New, b.Seed)
+		mac.Write([]byte(leaves[i].Path))
+		leaves[i].Salt = mac.Sum(nil)
+	}
+
+	return leaves, nil
+}
+
+// SignedPredicate returns the predicate that signs the Merkle root of the bundle's fields
+func (b Bundle) SignedPredicate() (Predicate, error) {
+	leaves, err := b.Leaves()
+	if err != nil {
+		return Predicate{}, err
+	}
+
+	return Predicate{
+		Name:          b.Name,
+		PredicateType: b.PredicateType,
+		Root:          hex.EncodeToString(rootHash(leafHashes(leaves))),
+		Leaves:        len(leaves),
+	}, nil
+}
+
+// Disclose returns the fields at paths with their inclusion proofs. A path also selects every field nested under
+// it, so /attestations/0 discloses the whole first attestation.
+func (b Bundle) Disclose(paths []string) ([]Field, error) {
+	leaves, err := b.Leaves()
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := leafHashes(leaves)
+	selected := make(map[int]bool)
+	for _, path := range paths {
+		if path == "" {
+			return nil, fmt.Errorf("path to disclose must not be empty")
+		}
+
+		found := false
+		for i, leaf := range leaves {
+			if leaf.Path == path || strings.HasPrefix(leaf.Path, path+"/") {
+				selected[i] = true
+				found = true
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("predicate has no field at %v", path)
+		}
+	}
+
+	fields := []Field{}
+	for i, leaf := range leaves {
+		if !selected[i] {
+			continue
+		}
+
+		proof := []string{}
+		for _, h := range inclusionProof(hashes, i) {
+			proof = append(proof, hex.EncodeToString(h))
+		}
+
+		fields = append(fields, Field{
+			Path:  leaf.Path,
+			Value: leaf.Value,
+			Salt:  hex.EncodeToString(leaf.Salt),
+			Index: i,
+			Proof: proof,
+		})
+	}
+
+	return fields, nil
+}
+
+// VerifyFields checks each field is included in the Merkle root of p
+func VerifyFields(p Predicate, fields []Field) error {
+	root, err := hex.DecodeString(p.Root)
+	if err != nil {
+		return fmt.Errorf("failed to decode disclosable predicate root: %w", err)
+	}
+
+	for _, field := range fields {
+		salt, err := hex.DecodeString(field.Salt)
+		if err != nil {
+			return fmt.Errorf("failed to decode salt of %v: %w", field.Path, err)
+		}
+
+		if len(salt) != sha256.Size {
+			return fmt.Errorf("salt of %v must be %d bytes", field.Path, sha256.Size)
+		}
+
+		proof := [][]byte{}
+		for _, h := range field.Proof {
+			decoded, err := hex.DecodeString(h)
+			if err != nil {
+				return fmt.Errorf("failed to decode proof of %v: %w", field.Path, err)
+			}
+
+			proof = append(proof, decoded)
+		}
+
+		leaf := leafHash(Leaf{Path: field.Path, Value: field.Value, Salt: salt})
+		computed, err := rootFromProof(field.Index, p.Leaves, leaf, proof)
+		if err != nil {
+			return fmt.Errorf("failed to verify %v: %w", field.Path, err)
+		}
+
+		if !hashesEqual(computed, root) {
+			return fmt.Errorf("%v is not part of the signed predicate", field.Path)
+		}
+	}
+
+	return nil
+}
+
+// Parse parses the disclosable predicate in a statement's predicate
+func Parse(predicate json.RawMessage) (Predicate, error) {
+	p := Predicate{}
+	if err := json.Unmarshal(predicate, &p); err != nil {
+		return p, fmt.Errorf("failed to parse disclosable predicate: %w", err)
+	}
+
+	if p.PredicateType == "" || p.Root == "" || p.Leaves <= 0 {
+		return p, fmt.Errorf("disclosable predicate must have a predicate type, root, and leaves")
+	}
+
+	return p, nil
+}
+
+// Store holds disclosure bundles by the Merkle root of their predicate
+type Store struct {
+	bundles map[string]Bundle
+}
+
+func NewStore() *Store {
+	return &Store{bundles: make(map[string]Bundle)}
+}
+
+// Add adds the bundle to the store
+func (s *Store) Add(b Bundle) error {
+	p, err := b.SignedPredicate()
+	if err != nil {
+		return err
+	}
+
+	s.bundles[p.Root] = b
+	return nil
+}
+
+// Resolve returns the predicate in the bundle p signs the root of. It's an error if the store doesn't have it. A
+// nil store has no bundles.
+func (s *Store) Resolve(p Predicate) ([]byte, error) {
+	if s == nil {
+		return nil, fmt.Errorf("the predicate of %v is disclosable and its bundle wasn't provided", p.Name)
+	}
+
+	b, ok := s.bundles[p.Root]
+	if !ok {
+		return nil, fmt.Errorf("the predicate of %v is disclosable and no bundle with root %v was provided", p.Name, p.Root)
+	}
+
+	return b.Predicate, nil
+}
+
+func flatten(path string, value interface{}, leaves *[]Leaf) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			*leaves = append(*leaves, Leaf{Path: path, Value: json.RawMessage("{}")})
+			return nil
+		}
+
+		for key, child := range v {
+			if err := flatten(path+"/"+escapePointer(key), child, leaves); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			*leaves = append(*leaves, Leaf{Path: path, Value: json.RawMessage("[]")})
+			return nil
+		}
+
+		for i, child := range v {
+			if err := flatten(path+"/"+strconv.Itoa(i), child, leaves); err != nil {
+				return err
+			}
+		}
+	default:
+		valueBytes, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("failed to encode %v: %w", path, err)
+		}
+
+		*leaves = append(*leaves, Leaf{Path: path, Value: valueBytes})
+	}
+
+	return nil
+}
+
+// escapePointer escapes a key for use in a JSON pointer, as described in RFC 6901
+func escapePointer(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
+
+func leafHashes(leaves []Leaf) [][]byte {
+	hashes := make([][]byte, 0, len(leaves))
+	for _, leaf := range leaves {
+		hashes = append(hashes, leafHash(leaf))
+	}
+
+	return hashes
+}
+
+// leafHash hashes the salt, path, and value of a leaf. The path is length prefixed so it can't run into the value.
+func leafHash(leaf Leaf) []byte {
+	length := make([]byte, 8)
+	binary.BigEndian.PutUint64(length, uint64(len(leaf.Path)))
+	h := sha256.New()
+	h.Write([]byte{leafPrefix})
+	h.Write(leaf.Salt)
+	h.Write(length)
+	h.Write([]byte(leaf.Path))
+	h.Write(leaf.Value)
+	return h.Sum(nil)
+}
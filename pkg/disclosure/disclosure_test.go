@@ -0,0 +1,133 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disclosure
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInclusionProofs(t *testing.T) {
+	for size := 1; size <= 17; size++ {
+		leaves := [][]byte{}
+		for i := 0; i < size; i++ {
+			leaves = append(leaves, leafHash(Leaf{Path: fmt.Sprintf("/%d", i), Value: json.RawMessage("true"), Salt: make([]byte, 32)}))
+		}
+
+		root := rootHash(leaves)
+		for i := range leaves {
+			computed, err := rootFromProof(i, size, leaves[i], inclusionProof(leaves, i))
+			require.NoError(t, err)
+			assert.True(t, hashesEqual(root, computed), "size %d index %d", size, i)
+
+			if size > 1 {
+				computed, err = rootFromProof((i+1)%size, size, leaves[i], inclusionProof(leaves, i))
+				if err == nil {
+					assert.False(t, hashesEqual(root, computed), "size %d index %d verified at the wrong index", size, i)
+				}
+			}
+		}
+	}
+}
+
+func TestDisclose(t *testing.T) {
+	predicate := []byte(`{"name":"build","attestations":[{"type":"builder","attestation":{"id":"ci/runner-1","env":{"TOKEN":"secret","a/b":"c"}}},{"type":"empty","attestation":{}}],"count":3}`)
+	bundle, err := NewBundle("build", "https://example.com/collection", predicate)
+	require.NoError(t, err)
+
+	leaves, err := bundle.Leaves()
+	require.NoError(t, err)
+	paths := []string{}
+	for _, leaf := range leaves {
+		paths = append(paths, leaf.Path)
+	}
+
+	assert.Equal(t, []string{
+		"/attestations/0/attestation/env/TOKEN",
+		"/attestations/0/attestation/env/a~1b",
+		"/attestations/0/attestation/id",
+		"/attestations/0/type",
+		"/attestations/1/attestation",
+		"/attestations/1/type",
+		"/count",
+		"/name",
+	}, paths)
+
+	signed, err := bundle.SignedPredicate()
+	require.NoError(t, err)
+	assert.Equal(t, len(leaves), signed.Leaves)
+
+	fields, err := bundle.Disclose([]string{"/attestations/0/attestation/id", "/attestations/1"})
+	require.NoError(t, err)
+	require.Len(t, fields, 3)
+	assert.Equal(t, json.RawMessage(`"ci/runner-1"`), fields[0].Value)
+	for _, field := range fields {
+		assert.NotContains(t, string(field.Value), "secret")
+	}
+
+	require.NoError(t, VerifyFields(signed, fields))
+
+	tampered := append([]Field{}, fields...)
+	tampered[0].Value = json.RawMessage(`"ci/runner-2"`)
+	assert.Error(t, VerifyFields(signed, tampered))
+
+	moved := append([]Field{}, fields...)
+	moved[0].Path = "/attestations/0/attestation/env/TOKEN"
+	assert.Error(t, VerifyFields(signed, moved))
+
+	_, err = bundle.Disclose([]string{"/attestations/2"})
+	assert.Error(t, err)
+
+	// the same predicate with a different seed has a different root
+	other, err := NewBundle("build", "https://example.com/collection", predicate)
+	require.NoError(t, err)
+	otherSigned, err := other.SignedPredicate()
+	require.NoError(t, err)
+	assert.NotEqual(t, signed.Root, otherSigned.Root)
+	assert.Error(t, VerifyFields(otherSigned, fields))
+}
+
+func TestStore(t *testing.T) {
+	bundle, err := NewBundle("build", "https://example.com/collection", []byte(`{"name":"build"}`))
+	require.NoError(t, err)
+	signed, err := bundle.SignedPredicate()
+	require.NoError(t, err)
+
+	var nilStore *Store
+	_, err = nilStore.Resolve(signed)
+	assert.Error(t, err)
+
+	bundleBytes, err := json.Marshal(bundle)
+	require.NoError(t, err)
+	assert.True(t, IsBundle(bundleBytes))
+	assert.False(t, IsBundle([]byte(`{"name":"build"}`)))
+
+	parsed, err := ParseBundle(bundleBytes)
+	require.NoError(t, err)
+	store := NewStore()
+	require.NoError(t, store.Add(parsed))
+	body, err := store.Resolve(signed)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name":"build"}`, string(body))
+
+	signed.Root = hex.EncodeToString(make([]byte, 32))
+	_, err = store.Resolve(signed)
+	assert.Error(t, err)
+}
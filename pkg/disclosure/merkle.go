@@ -0,0 +1,113 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disclosure
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+)
+
+const (
+	leafPrefix = 0x00
+	nodePrefix = 0x01
+)
+
+// rootHash returns the RFC 6962 Merkle tree hash of the leaf hashes
+func rootHash(leaves [][]byte) []byte {
+	switch len(leaves) {
+	case 0:
+		empty := sha256.Sum256(nil)
+		return empty[:]
+	case 1:
+		return leaves[0]
+	}
+
+	k := splitPoint(len(leaves))
+	return nodeHash(rootHash(leaves[:k]), rootHash(leaves[k:]))
+}
+
+// inclusionProof returns the RFC 6962 audit path of the leaf at index
+func inclusionProof(leaves [][]byte, index int) [][]byte {
+	if len(leaves) <= 1 {
+		return nil
+	}
+
+	k := splitPoint(len(leaves))
+	if index < k {
+		return append(inclusionProof(leaves[:k], index), rootHash(leaves[k:]))
+	}
+
+	return append(inclusionProof(leaves[k:], index-k), rootHash(leaves[:k]))
+}
+
+// rootFromProof returns the root of a tree of size leaves that leaf is at index in, given its audit path
+func rootFromProof(index, size int, leaf []byte, proof [][]byte) ([]byte, error) {
+	if index < 0 || index >= size {
+		return nil, fmt.Errorf("leaf index %d is out of range for a tree of %d leaves", index, size)
+	}
+
+	if size == 1 {
+		if len(proof) != 0 {
+			return nil, fmt.Errorf("inclusion proof is too long")
+		}
+
+		return leaf, nil
+	}
+
+	if len(proof) == 0 {
+		return nil, fmt.Errorf("inclusion proof is too short")
+	}
+
+	sibling, rest := proof[len(proof)-1], proof[:len(proof)-1]
+	k := splitPoint(size)
+	if index < k {
+		left, err := rootFromProof(index, k, leaf, rest)
+		if err != nil {
+			return nil, err
+		}
+
+		return nodeHash(left, sibling), nil
+	}
+
+	right, err := rootFromProof(index-k, size-k, leaf, rest)
+	if err != nil {
+		return nil, err
+	}
+
+	return nodeHash(sibling, right), nil
+}
+
+// splitPoint returns the largest power of two less than n
+func splitPoint(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+
+	return k
+}
+
+func nodeHash(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{nodePrefix})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+func hashesEqual(a, b []byte) bool {
+	return len(a) == sha256.Size && bytes.Equal(a, b)
+}
@@ -0,0 +1,62 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ebpftrace traces the command witness runs with eBPF programs attached to kernel tracepoints. It's a lower
+// overhead alternative to ptrace that also records the addresses the command's processes connect to.
+package ebpftrace
+
+import "time"
+
+// Connection is an address a traced process connected a socket to
+type Connection struct {
+	PID     int    `json:"pid"`
+	Program string `json:"program,omitempty"`
+	// Family is inet, inet6, or unix
+	Family  string `json:"family"`
+	Address string `json:"address"`
+}
+
+// Stats describes what tracing the command cost and how much of it was recorded
+type Stats struct {
+	// Events is how many events the kernel sent
+	Events uint64
+	// LostEvents is how many events the kernel dropped because witness didn't read them quickly enough
+	LostEvents uint64
+	// FileLimitReached is true when file opens stopped being recorded because the file limit was reached
+	FileLimitReached bool
+	// ProgramTime is how long the kernel spent running the tracer's programs, if it measured it
+	ProgramTime time.Duration
+}
+
+type settings struct {
+	sampleRate int
+	maxFiles   int
+}
+
+type Option func(*settings)
+
+// WithSampleRate records one in every rate file opens, chosen at random, to reduce the cost of tracing builds that
+// open many files. Processes and connections are always recorded.
+func WithSampleRate(rate int) Option {
+	return func(s *settings) {
+		s.sampleRate = rate
+	}
+}
+
+// WithMaxFiles stops recording file opens once max files have been recorded. There's no limit if max is 0.
+func WithMaxFiles(max int) Option {
+	return func(s *settings) {
+		s.maxFiles = max
+	}
+}
@@ -0,0 +1,456 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpftrace
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"golang.org/x/sys/unix"
+)
+
+// The programs write events to the stack before sending them to user space. An event is a header followed by a
+// string or socket address:
+//
+//	u32 kind, u32 tgid, u32 tid, u32 aux, u8 data[256]
+const (
+	eventOffset    = -(eventHeaderLen + eventDataLen)
+	eventDataLen   = 256
+	eventHeaderLen = 16
+	dataOffset     = eventOffset + eventHeaderLen
+	scratchOffset  = eventOffset - 8
+	keyOffset      = scratchOffset - 4
+	valueOffset    = keyOffset - 4
+	pointerOffset  = valueOffset - 8
+
+	// maxArgs is how many of a program's arguments are recorded
+	maxArgs = 32
+	// sockaddrLen is how much of a connect address is read, enough for a unix socket path
+	sockaddrLen = 128
+	// atFDCWD means a path is relative to the working directory rather than a directory file descriptor
+	atFDCWD = -100
+)
+
+const (
+	kindFork uint32 = iota + 1
+	kindExec
+	kindArg
+	kindExecResult
+	kindOpen
+	kindOpenResult
+	kindConnect
+	kindChdir
+	kindChdirResult
+)
+
+// Entries of the config map
+const (
+	// configSampleRate is the rate file opens are sampled at, or 0 to record every open
+	configSampleRate uint32 = iota
+	// configFilesDisabled stops recording file opens when it's non-zero, once the file limit is reached
+	configFilesDisabled
+	configEntries
+)
+
+// maps are shared by every program
+type maps struct {
+	// tracked holds the process and thread IDs of witness and its descendants
+	tracked *ebpf.Map
+	// events sends events to user space
+	events *ebpf.Map
+	// config holds the configEntries, which user space can change while the programs run
+	config *ebpf.Map
+}
+
+// tracepoint is a kernel tracepoint and the program attached to it
+type tracepoint struct {
+	group, name string
+	build       func(fields map[string]int16, m maps) asm.Instructions
+	// fields are the fields of the tracepoint the program reads
+	fields   []string
+	optional bool
+}
+
+var tracepoints = []tracepoint{
+	{group: "sched", name: "sched_process_fork", build: forkProgram, fields: []string{"child_pid"}},
+	{group: "sched", name: "sched_process_exit", build: exitProgram},
+	{group: "syscalls", name: "sys_enter_execve", build: execProgram, fields: []string{"filename", "argv"}},
+	{group: "syscalls", name: "sys_enter_execveat", build: execProgram, fields: []string{"fd", "filename", "argv"}, optional: true},
+	{group: "syscalls", name: "sys_exit_execve", build: resultProgram(kindExecResult), fields: []string{"ret"}},
+	{group: "syscalls", name: "sys_exit_execveat", build: resultProgram(kindExecResult), fields: []string{"ret"}, optional: true},
+	{group: "syscalls", name: "sys_enter_openat", build: openProgram, fields: []string{"dfd", "filename"}},
+	{group: "syscalls", name: "sys_exit_openat", build: resultProgram(kindOpenResult), fields: []string{"ret"}},
+	{group: "syscalls", name: "sys_enter_connect", build: connectProgram, fields: []string{"uservaddr", "addrlen"}},
+	{group: "syscalls", name: "sys_enter_chdir", build: pathProgram(kindChdir, ""), fields: []string{"filename"}},
+	{group: "syscalls", name: "sys_exit_chdir", build: resultProgram(kindChdirResult), fields: []string{"ret"}},
+	{group: "syscalls", name: "sys_enter_fchdir", build: fdProgram(kindChdir), fields: []string{"fd"}},
+	{group: "syscalls", name: "sys_exit_fchdir", build: resultProgram(kindChdirResult), fields: []string{"ret"}},
+}
+
+// prologue saves the context, and returns early unless the current process is a descendant of witness. The
+// header of the event is filled in with the process and thread IDs, which are kept in R7 and R8.
+func prologue(tracked *ebpf.Map) asm.Instructions {
+	return asm.Instructions{
+		asm.Mov.Reg(asm.R6, asm.R1),
+		asm.FnGetCurrentPidTgid.Call(),
+		asm.Mov.Reg(asm.R7, asm.R0),
+		asm.RSh.Imm(asm.R7, 32),
+		asm.Mov.Reg32(asm.R8, asm.R0),
+		asm.StoreMem(asm.RFP, keyOffset, asm.R7, asm.Word),
+		asm.LoadMapPtr(asm.R1, tracked.FD()),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, keyOffset),
+		asm.FnMapLookupElem.Call(),
+		asm.JEq.Imm(asm.R0, 0, "exit"),
+		asm.StoreMem(asm.RFP, eventOffset+4, asm.R7, asm.Word),
+		asm.StoreMem(asm.RFP, eventOffset+8, asm.R8, asm.Word),
+	}
+}
+
+func epilogue() asm.Instructions {
+	return asm.Instructions{
+		asm.Mov.Imm(asm.R0, 0).Sym("exit"),
+		asm.Return(),
+	}
+}
+
+// setHeader sets the kind of the event and its auxiliary value from an immediate
+func setHeader(kind uint32, aux int32) asm.Instructions {
+	return asm.Instructions{
+		asm.StoreImm(asm.RFP, eventOffset, int64(kind), asm.Word),
+		asm.StoreImm(asm.RFP, eventOffset+12, int64(aux), asm.Word),
+	}
+}
+
+// output sends the event with size bytes of data, which is in R9 when size is negative
+func output(events *ebpf.Map, size int32) asm.Instructions {
+	insns := asm.Instructions{
+		asm.Mov.Reg(asm.R1, asm.R6),
+		asm.LoadMapPtr(asm.R2, events.FD()),
+		asm.LoadImm(asm.R3, 0xffffffff, asm.DWord),
+		asm.Mov.Reg(asm.R4, asm.RFP),
+		asm.Add.Imm(asm.R4, eventOffset),
+	}
+
+	if size >= 0 {
+		insns = append(insns, asm.Mov.Imm(asm.R5, eventHeaderLen+size))
+	} else {
+		insns = append(insns, asm.Mov.Reg(asm.R5, asm.R9), asm.Add.Imm(asm.R5, eventHeaderLen))
+	}
+
+	return append(insns, asm.FnPerfEventOutput.Call())
+}
+
+// readString reads the user space string pointed to by R3 into the event's data, leaving its length in R9. It
+// jumps to skip if the string couldn't be read.
+func readString(skip string) asm.Instructions {
+	return asm.Instructions{
+		asm.Mov.Reg(asm.R1, asm.RFP),
+		asm.Add.Imm(asm.R1, dataOffset),
+		asm.Mov.Imm(asm.R2, eventDataLen),
+		asm.FnProbeReadUserStr.Call(),
+		asm.Mov.Reg(asm.R9, asm.R0),
+		asm.JSLE.Imm(asm.R9, 0, skip),
+		asm.JGT.Imm(asm.R9, eventDataLen, skip),
+	}
+}
+
+// forkProgram starts tracking processes forked by tracked processes
+func forkProgram(fields map[string]int16, m maps) asm.Instructions {
+	insns := prologue(m.tracked)
+	insns = append(insns,
+		asm.LoadMem(asm.R9, asm.R6, fields["child_pid"], asm.Word),
+		asm.StoreMem(asm.RFP, keyOffset, asm.R9, asm.Word),
+		asm.StoreImm(asm.RFP, valueOffset, 1, asm.Word),
+		asm.LoadMapPtr(asm.R1, m.tracked.FD()),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, keyOffset),
+		asm.Mov.Reg(asm.R3, asm.RFP),
+		asm.Add.Imm(asm.R3, valueOffset),
+		asm.Mov.Imm(asm.R4, 0),
+		asm.FnMapUpdateElem.Call(),
+		asm.StoreImm(asm.RFP, eventOffset, int64(kindFork), asm.Word),
+		asm.StoreMem(asm.RFP, eventOffset+12, asm.R9, asm.Word),
+	)
+
+	insns = append(insns, output(m.events, 0)...)
+	return append(insns, epilogue()...)
+}
+
+// exitProgram stops tracking threads and processes when they exit, so their IDs can be reused
+func exitProgram(fields map[string]int16, m maps) asm.Instructions {
+	insns := asm.Instructions{
+		asm.FnGetCurrentPidTgid.Call(),
+		asm.StoreMem(asm.RFP, keyOffset, asm.R0, asm.Word),
+		asm.LoadMapPtr(asm.R1, m.tracked.FD()),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, keyOffset),
+		asm.FnMapDeleteElem.Call(),
+	}
+
+	return append(insns, epilogue()...)
+}
+
+// execProgram sends the program a process is executing, followed by each of its arguments
+func execProgram(fields map[string]int16, m maps) asm.Instructions {
+	insns := prologue(m.tracked)
+	if fd, ok := fields["fd"]; ok {
+		insns = append(insns,
+			asm.LoadMem(asm.R1, asm.R6, fd, asm.Word),
+			asm.StoreImm(asm.RFP, eventOffset, int64(kindExec), asm.Word),
+			asm.StoreMem(asm.RFP, eventOffset+12, asm.R1, asm.Word),
+		)
+	} else {
+		insns = append(insns, setHeader(kindExec, atFDCWD)...)
+	}
+
+	insns = append(insns, asm.LoadMem(asm.R3, asm.R6, fields["filename"], asm.DWord))
+	insns = append(insns, readString("exit")...)
+	insns = append(insns, output(m.events, -1)...)
+
+	// R9 is reused for each argument's length once the argument array is saved on the stack
+	insns = append(insns,
+		asm.LoadMem(asm.R1, asm.R6, fields["argv"], asm.DWord),
+		asm.StoreMem(asm.RFP, scratchOffset, asm.R1, asm.DWord),
+	)
+
+	for i := 0; i < maxArgs; i++ {
+		next := fmt.Sprintf("arg%d", i+1)
+		insns = append(insns,
+			asm.LoadMem(asm.R3, asm.RFP, scratchOffset, asm.DWord).Sym(fmt.Sprintf("arg%d", i)),
+			asm.Add.Imm(asm.R3, int32(i*8)),
+			asm.Mov.Reg(asm.R1, asm.RFP),
+			asm.Add.Imm(asm.R1, pointerOffset),
+			asm.Mov.Imm(asm.R2, 8),
+			asm.FnProbeReadUser.Call(),
+			asm.JNE.Imm(asm.R0, 0, "exit"),
+			asm.LoadMem(asm.R3, asm.RFP, pointerOffset, asm.DWord),
+			asm.JEq.Imm(asm.R3, 0, "exit"),
+		)
+
+		insns = append(insns, setHeader(kindArg, int32(i))...)
+		insns = append(insns, readString(next)...)
+		insns = append(insns, output(m.events, -1)...)
+	}
+
+	insns = append(insns, asm.Ja.Label("exit").Sym(fmt.Sprintf("arg%d", maxArgs)))
+	return append(insns, epilogue()...)
+}
+
+// pathProgram sends a path a process passed to a system call, and the directory it's relative to from the dirfd
+// field, or the working directory if dirfd is empty
+func pathProgram(kind uint32, dirfd string) func(fields map[string]int16, m maps) asm.Instructions {
+	return func(fields map[string]int16, m maps) asm.Instructions {
+		insns := prologue(m.tracked)
+		insns = append(insns, pathEvent(kind, dirfd, fields, m)...)
+		return append(insns, epilogue()...)
+	}
+}
+
+// openProgram sends the files processes open, unless the open isn't sampled or the file limit has been reached
+func openProgram(fields map[string]int16, m maps) asm.Instructions {
+	insns := prologue(m.tracked)
+	insns = append(insns, configValue(m.config, configSampleRate, asm.R9)...)
+	insns = append(insns,
+		asm.JLE.Imm(asm.R9, 1, "limit"),
+		asm.FnGetPrandomU32.Call(),
+		asm.Mod.Reg(asm.R0, asm.R9),
+		asm.JNE.Imm(asm.R0, 0, "exit"),
+	)
+
+	limit := configValue(m.config, configFilesDisabled, asm.R9)
+	limit[0] = limit[0].Sym("limit")
+	insns = append(insns, limit...)
+	insns = append(insns, asm.JNE.Imm(asm.R9, 0, "exit"))
+	insns = append(insns, pathEvent(kindOpen, "dfd", fields, m)...)
+	return append(insns, epilogue()...)
+}
+
+// configValue loads an entry of the config map into dst, jumping to exit if it can't be read
+func configValue(config *ebpf.Map, key uint32, dst asm.Register) asm.Instructions {
+	return asm.Instructions{
+		asm.StoreImm(asm.RFP, keyOffset, int64(key), asm.Word),
+		asm.LoadMapPtr(asm.R1, config.FD()),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, keyOffset),
+		asm.FnMapLookupElem.Call(),
+		asm.JEq.Imm(asm.R0, 0, "exit"),
+		asm.LoadMem(dst, asm.R0, 0, asm.Word),
+	}
+}
+
+func pathEvent(kind uint32, dirfd string, fields map[string]int16, m maps) asm.Instructions {
+	var insns asm.Instructions
+	if dirfd != "" {
+		insns = append(insns,
+			asm.LoadMem(asm.R1, asm.R6, fields[dirfd], asm.Word),
+			asm.StoreImm(asm.RFP, eventOffset, int64(kind), asm.Word),
+			asm.StoreMem(asm.RFP, eventOffset+12, asm.R1, asm.Word),
+		)
+	} else {
+		insns = append(insns, setHeader(kind, atFDCWD)...)
+	}
+
+	insns = append(insns, asm.LoadMem(asm.R3, asm.R6, fields["filename"], asm.DWord))
+	insns = append(insns, readString("exit")...)
+	return append(insns, output(m.events, -1)...)
+}
+
+// fdProgram sends the file descriptor a process passed to a system call, with an empty path relative to it
+func fdProgram(kind uint32) func(fields map[string]int16, m maps) asm.Instructions {
+	return func(fields map[string]int16, m maps) asm.Instructions {
+		insns := prologue(m.tracked)
+		insns = append(insns,
+			asm.LoadMem(asm.R1, asm.R6, fields["fd"], asm.Word),
+			asm.StoreImm(asm.RFP, eventOffset, int64(kind), asm.Word),
+			asm.StoreMem(asm.RFP, eventOffset+12, asm.R1, asm.Word),
+		)
+
+		insns = append(insns, output(m.events, 0)...)
+		return append(insns, epilogue()...)
+	}
+}
+
+// resultProgram sends the return value of a system call, so user space can discard failed calls
+func resultProgram(kind uint32) func(fields map[string]int16, m maps) asm.Instructions {
+	return func(fields map[string]int16, m maps) asm.Instructions {
+		insns := prologue(m.tracked)
+		insns = append(insns,
+			asm.LoadMem(asm.R1, asm.R6, fields["ret"], asm.DWord),
+			asm.StoreImm(asm.RFP, eventOffset, int64(kind), asm.Word),
+			asm.StoreMem(asm.RFP, eventOffset+12, asm.R1, asm.Word),
+		)
+
+		insns = append(insns, output(m.events, 0)...)
+		return append(insns, epilogue()...)
+	}
+}
+
+// connectProgram sends the address a process is connecting a socket to
+func connectProgram(fields map[string]int16, m maps) asm.Instructions {
+	insns := prologue(m.tracked)
+	insns = append(insns, setHeader(kindConnect, 0)...)
+	for off := 0; off < sockaddrLen; off += 8 {
+		insns = append(insns, asm.StoreImm(asm.RFP, int16(dataOffset+off), 0, asm.DWord))
+	}
+
+	insns = append(insns,
+		asm.LoadMem(asm.R2, asm.R6, fields["addrlen"], asm.Word),
+		asm.JLE.Imm(asm.R2, sockaddrLen, "read"),
+		asm.Mov.Imm(asm.R2, sockaddrLen),
+		asm.LoadMem(asm.R3, asm.R6, fields["uservaddr"], asm.DWord).Sym("read"),
+		asm.Mov.Reg(asm.R1, asm.RFP),
+		asm.Add.Imm(asm.R1, dataOffset),
+		asm.FnProbeReadUser.Call(),
+		asm.JNE.Imm(asm.R0, 0, "exit"),
+	)
+
+	insns = append(insns, output(m.events, sockaddrLen)...)
+	return append(insns, epilogue()...)
+}
+
+// tracefsDirs are where tracefs is usually mounted
+var tracefsDirs = []string{"/sys/kernel/tracing", "/sys/kernel/debug/tracing"}
+
+func findTracefs() (string, error) {
+	for _, dir := range tracefsDirs {
+		if _, err := os.Stat(filepath.Join(dir, "events")); err == nil {
+			return dir, nil
+		}
+	}
+
+	return "", fmt.Errorf("tracefs isn't mounted at %v", strings.Join(tracefsDirs, " or "))
+}
+
+// readFormat returns the ID of a tracepoint and the offsets of its fields in the context programs receive
+func readFormat(tracefs, group, name string) (uint64, map[string]int16, error) {
+	dir := filepath.Join(tracefs, "events", group, name)
+	idBytes, err := os.ReadFile(filepath.Join(dir, "id"))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	id, err := strconv.ParseUint(strings.TrimSpace(string(idBytes)), 10, 64)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to parse %v/%v id: %w", group, name, err)
+	}
+
+	f, err := os.Open(filepath.Join(dir, "format"))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	defer f.Close()
+	fields := make(map[string]int16)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// field:const char * filename;	offset:24;	size:8;	signed:0;
+		parts := strings.Split(strings.TrimSpace(scanner.Text()), ";")
+		if len(parts) < 2 || !strings.HasPrefix(parts[0], "field:") {
+			continue
+		}
+
+		decl := strings.Fields(parts[0])
+		fieldName := strings.TrimLeft(decl[len(decl)-1], "*")
+		if i := strings.Index(fieldName, "["); i >= 0 {
+			fieldName = fieldName[:i]
+		}
+
+		offset, err := strconv.ParseInt(strings.TrimPrefix(strings.TrimSpace(parts[1]), "offset:"), 10, 16)
+		if err != nil {
+			continue
+		}
+
+		fields[fieldName] = int16(offset)
+	}
+
+	return id, fields, scanner.Err()
+}
+
+// attach runs prog whenever the tracepoint with id is hit, until the returned file descriptor is closed
+func attach(id uint64, prog *ebpf.Program) (int, error) {
+	attr := unix.PerfEventAttr{
+		Type:        unix.PERF_TYPE_TRACEPOINT,
+		Config:      id,
+		Size:        uint32(unsafe.Sizeof(unix.PerfEventAttr{})),
+		Sample_type: unix.PERF_SAMPLE_RAW,
+		Sample:      1,
+		Wakeup:      1,
+	}
+
+	fd, err := unix.PerfEventOpen(&attr, -1, 0, -1, unix.PERF_FLAG_FD_CLOEXEC)
+	if err != nil {
+		return -1, fmt.Errorf("failed to open perf event: %w", err)
+	}
+
+	if err := unix.IoctlSetInt(fd, unix.PERF_EVENT_IOC_SET_BPF, prog.FD()); err != nil {
+		unix.Close(fd)
+		return -1, fmt.Errorf("failed to attach program: %w", err)
+	}
+
+	if err := unix.IoctlSetInt(fd, unix.PERF_EVENT_IOC_ENABLE, 0); err != nil {
+		unix.Close(fd)
+		return -1, fmt.Errorf("failed to enable perf event: %w", err)
+	}
+
+	return fd, nil
+}
@@ -0,0 +1,598 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpftrace
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/perf"
+	"github.com/cilium/ebpf/rlimit"
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/attestation/commandrun"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/log"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// perCPUBuffer is the size of the ring each CPU sends events to user space through
+	perCPUBuffer = 256 * 4096
+	// quietPeriod is how long the tracer waits without events before it decides every event has been read
+	quietPeriod = 50 * time.Millisecond
+	// drainTimeout bounds how long the tracer waits for events after the command exits
+	drainTimeout = 2 * time.Second
+	// trackedProcesses is how many processes and threads can be tracked at once
+	trackedProcesses = 1 << 16
+)
+
+// Tracer records the processes the command witness runs starts, the files they open, and the addresses they
+// connect to, from eBPF programs attached to kernel tracepoints.
+type Tracer struct {
+	settings
+	maps
+	programs []loadedProgram
+	reader   *perf.Reader
+	command  *commandrun.CommandRun
+	perfFDs  []int
+	self     int
+	// stats keeps the kernel measuring how long the programs run while the command runs
+	stats       io.Closer
+	programTime time.Duration
+
+	// state is only accessed by the goroutine reading events until it's stopped
+	state     *state
+	done      chan struct{}
+	mu        sync.Mutex
+	lastEvent time.Time
+}
+
+type loadedProgram struct {
+	tracepoint
+	id   uint64
+	prog *ebpf.Program
+}
+
+// New loads the tracer's programs, returning an error if the kernel doesn't support them. Nothing is traced until
+// the command is about to run.
+func New(opts ...Option) (*Tracer, error) {
+	tracefs, err := findTracefs()
+	if err != nil {
+		return nil, err
+	}
+
+	// kernels before 5.11 account eBPF memory against the locked memory limit
+	_ = rlimit.RemoveMemlock()
+	t := &Tracer{self: os.Getpid()}
+	for _, opt := range opts {
+		opt(&t.settings)
+	}
+
+	if t.tracked, err = ebpf.NewMap(&ebpf.MapSpec{Type: ebpf.Hash, KeySize: 4, ValueSize: 4, MaxEntries: trackedProcesses}); err != nil {
+		return nil, fmt.Errorf("failed to create tracked process map: %w", err)
+	}
+
+	if t.events, err = ebpf.NewMap(&ebpf.MapSpec{Type: ebpf.PerfEventArray}); err != nil {
+		t.Close()
+		return nil, fmt.Errorf("failed to create event map: %w", err)
+	}
+
+	if t.config, err = ebpf.NewMap(&ebpf.MapSpec{Type: ebpf.Array, KeySize: 4, ValueSize: 4, MaxEntries: uint32(configEntries)}); err != nil {
+		t.Close()
+		return nil, fmt.Errorf("failed to create config map: %w", err)
+	}
+
+	for _, tp := range tracepoints {
+		loaded, err := load(tracefs, tp, t.maps)
+		if err != nil {
+			if tp.optional {
+				log.Debugf("(ebpftrace) skipping %v/%v: %v", tp.group, tp.name, err)
+				continue
+			}
+
+			t.Close()
+			return nil, fmt.Errorf("failed to load program for %v/%v: %w", tp.group, tp.name, err)
+		}
+
+		t.programs = append(t.programs, loaded)
+	}
+
+	if t.reader, err = perf.NewReader(t.events, perCPUBuffer); err != nil {
+		t.Close()
+		return nil, fmt.Errorf("failed to create event reader: %w", err)
+	}
+
+	return t, nil
+}
+
+func load(tracefs string, tp tracepoint, m maps) (loadedProgram, error) {
+	id, fields, err := readFormat(tracefs, tp.group, tp.name)
+	if err != nil {
+		return loadedProgram{}, err
+	}
+
+	for _, field := range tp.fields {
+		if _, ok := fields[field]; !ok {
+			return loadedProgram{}, fmt.Errorf("tracepoint has no %v field", field)
+		}
+	}
+
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Name:         tp.name,
+		Type:         ebpf.TracePoint,
+		Instructions: tp.build(fields, m),
+		// the helpers that read user memory and send events are only available to GPL compatible programs
+		License: "GPL",
+	})
+	if err != nil {
+		return loadedProgram{}, err
+	}
+
+	return loadedProgram{tracepoint: tp, id: id, prog: prog}, nil
+}
+
+// SetCommand sets the command run attestor the traced processes are recorded in
+func (t *Tracer) SetCommand(command *commandrun.CommandRun) {
+	t.command = command
+}
+
+// PreCommand starts tracing the processes witness starts
+func (t *Tracer) PreCommand(ctx *attestation.AttestationContext) error {
+	if err := t.tracked.Put(uint32(t.self), uint32(1)); err != nil {
+		return fmt.Errorf("failed to track witness: %w", err)
+	}
+
+	if t.sampleRate > 1 {
+		if err := t.config.Put(configSampleRate, uint32(t.sampleRate)); err != nil {
+			return fmt.Errorf("failed to set sample rate: %w", err)
+		}
+	}
+
+	// the kernel only measures the programs' run time while statistics are enabled, which needs Linux 5.8
+	if stats, err := ebpf.EnableStats(unix.BPF_STATS_RUN_TIME); err == nil {
+		t.stats = stats
+	} else {
+		log.Debugf("(ebpftrace) not measuring program run time: %v", err)
+	}
+
+	for _, p := range t.programs {
+		fd, err := attach(p.id, p.prog)
+		if err != nil {
+			t.detach()
+			return fmt.Errorf("failed to attach to %v/%v: %w", p.group, p.name, err)
+		}
+
+		t.perfFDs = append(t.perfFDs, fd)
+	}
+
+	workingDir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	t.state = newState(t.self, workingDir, ctx.Hashes(), t.maxFiles)
+	t.done = make(chan struct{})
+	t.lastEvent = time.Now()
+	go t.read()
+	return nil
+}
+
+// PostCommand stops tracing once the events already sent have been read, and records the traced processes in the
+// command run attestor
+func (t *Tracer) PostCommand(ctx *attestation.AttestationContext) error {
+	t.detach()
+	deadline := time.Now().Add(drainTimeout)
+	for time.Now().Before(deadline) {
+		t.mu.Lock()
+		quiet := time.Since(t.lastEvent) >= quietPeriod
+		t.mu.Unlock()
+		if quiet {
+			break
+		}
+
+		time.Sleep(quietPeriod / 5)
+	}
+
+	t.reader.Close()
+	<-t.done
+	if t.stats != nil {
+		for _, p := range t.programs {
+			if info, err := p.prog.Info(); err == nil {
+				runtime, _ := info.Runtime()
+				t.programTime += runtime
+			}
+		}
+
+		t.stats.Close()
+		t.stats = nil
+	}
+
+	if t.state.lost > 0 {
+		log.Warnf("the eBPF tracer dropped %v events, so some processes or files may be missing", t.state.lost)
+	}
+
+	if t.command != nil {
+		t.command.Processes = t.state.processes()
+	}
+
+	return nil
+}
+
+// Connections returns the addresses the traced processes connected to
+func (t *Tracer) Connections() []Connection {
+	if t.state == nil {
+		return nil
+	}
+
+	return t.state.connections
+}
+
+// Stats returns what tracing the command cost, once it has exited
+func (t *Tracer) Stats() Stats {
+	if t.state == nil {
+		return Stats{}
+	}
+
+	return Stats{
+		Events:           t.state.events,
+		LostEvents:       t.state.lost,
+		FileLimitReached: t.state.fileLimitReached,
+		ProgramTime:      t.programTime,
+	}
+}
+
+// Close releases the tracer's programs and maps
+func (t *Tracer) Close() error {
+	t.detach()
+	if t.reader != nil {
+		t.reader.Close()
+	}
+
+	for _, p := range t.programs {
+		p.prog.Close()
+	}
+
+	if t.stats != nil {
+		t.stats.Close()
+	}
+
+	for _, m := range []*ebpf.Map{t.tracked, t.events, t.config} {
+		if m != nil {
+			m.Close()
+		}
+	}
+
+	return nil
+}
+
+func (t *Tracer) detach() {
+	for _, fd := range t.perfFDs {
+		syscall.Close(fd)
+	}
+
+	t.perfFDs = nil
+}
+
+func (t *Tracer) read() {
+	defer close(t.done)
+	for {
+		record, err := t.reader.Read()
+		if err != nil {
+			if errors.Is(err, perf.ErrClosed) {
+				return
+			}
+
+			continue
+		}
+
+		t.mu.Lock()
+		t.lastEvent = time.Now()
+		t.mu.Unlock()
+		if record.LostSamples > 0 {
+			t.state.lost += record.LostSamples
+			continue
+		}
+
+		limitReached := t.state.fileLimitReached
+		t.state.handle(record.RawSample)
+		// stop the kernel sending file opens once the limit is reached, rather than discarding them here
+		if t.state.fileLimitReached && !limitReached {
+			if err := t.config.Put(configFilesDisabled, uint32(1)); err != nil {
+				log.Debugf("(ebpftrace) failed to stop recording file opens: %v", err)
+			}
+		}
+	}
+}
+
+// state is what's been learned about the traced processes from their events
+type state struct {
+	self    int
+	hashes  []crypto.Hash
+	digests map[fileKey]cryptoutil.DigestSet
+	procs   map[int]*process
+	parents map[int]int
+	execs   map[int]*pendingExec
+	opens   map[int]string
+	chdirs  map[int]string
+	// cwds are the working directories of the traced processes, inherited when they fork and changed by chdir,
+	// so relative paths can be resolved after the process has exited
+	cwds        map[int]string
+	connections []Connection
+	// maxFiles is how many files are recorded before file opens are ignored, or 0 for no limit
+	maxFiles         int
+	files            int
+	fileLimitReached bool
+	events           uint64
+	lost             uint64
+}
+
+type process struct {
+	info commandrun.ProcessInfo
+	// execed is true once the process has run a program, rather than only being forked
+	execed bool
+}
+
+type pendingExec struct {
+	program string
+	argv    []string
+}
+
+// fileKey identifies a version of a file, so files opened many times during a build are only digested once
+type fileKey struct {
+	path       string
+	dev, inode uint64
+	size       int64
+	modified   time.Time
+}
+
+func newState(self int, workingDir string, hashes []crypto.Hash, maxFiles int) *state {
+	return &state{
+		self:     self,
+		maxFiles: maxFiles,
+		hashes:   hashes,
+		digests:  make(map[fileKey]cryptoutil.DigestSet),
+		procs:    make(map[int]*process),
+		parents:  make(map[int]int),
+		execs:    make(map[int]*pendingExec),
+		opens:    make(map[int]string),
+		chdirs:   make(map[int]string),
+		cwds:     map[int]string{self: workingDir},
+	}
+}
+
+func (s *state) handle(sample []byte) {
+	if len(sample) < eventHeaderLen {
+		return
+	}
+
+	s.events++
+	kind := binary.LittleEndian.Uint32(sample[0:])
+	tgid := int(binary.LittleEndian.Uint32(sample[4:]))
+	tid := int(binary.LittleEndian.Uint32(sample[8:]))
+	aux := int32(binary.LittleEndian.Uint32(sample[12:]))
+	data := sample[eventHeaderLen:]
+	switch kind {
+	case kindFork:
+		s.parents[int(aux)] = tgid
+		if cwd, ok := s.cwds[tgid]; ok {
+			s.cwds[int(aux)] = cwd
+		}
+
+		return
+	case kindChdir:
+		s.chdirs[tid] = s.resolve(tgid, aux, cString(data))
+		return
+	case kindChdirResult:
+		dir, ok := s.chdirs[tid]
+		delete(s.chdirs, tid)
+		if ok && aux == 0 {
+			s.cwds[tgid] = dir
+		}
+
+		return
+	}
+
+	// witness' own files and connections aren't the command's
+	if tgid == s.self {
+		return
+	}
+
+	switch kind {
+	case kindExec:
+		s.execs[tgid] = &pendingExec{program: s.resolve(tgid, aux, cString(data))}
+	case kindArg:
+		if exec, ok := s.execs[tgid]; ok && int(aux) == len(exec.argv) {
+			exec.argv = append(exec.argv, cString(data))
+		}
+	case kindExecResult:
+		exec, ok := s.execs[tgid]
+		delete(s.execs, tgid)
+		if !ok || aux != 0 {
+			return
+		}
+
+		p := s.process(tgid)
+		p.execed = true
+		p.info.Program = exec.program
+		p.info.Cmdline = strings.Join(exec.argv, " ")
+		p.info.ProgramDigest = s.digest(exec.program)
+		p.info.ExeDigest = s.digest(fmt.Sprintf("/proc/%d/exe", tgid))
+		if comm, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", tgid)); err == nil {
+			p.info.Comm = strings.TrimSpace(string(comm))
+		}
+	case kindOpen:
+		s.opens[tid] = s.resolve(tgid, aux, cString(data))
+	case kindOpenResult:
+		path, ok := s.opens[tid]
+		delete(s.opens, tid)
+		if !ok || aux < 0 {
+			return
+		}
+
+		p := s.process(tgid)
+		if _, ok := p.info.OpenedFiles[path]; ok {
+			return
+		}
+
+		if s.maxFiles > 0 && s.files >= s.maxFiles {
+			s.fileLimitReached = true
+			return
+		}
+
+		if digest := s.digest(path); len(digest) > 0 {
+			p.info.OpenedFiles[path] = digest
+			s.files++
+		}
+	case kindConnect:
+		if conn, ok := parseSockaddr(data); ok {
+			conn.PID = tgid
+			s.connections = append(s.connections, conn)
+		}
+	}
+}
+
+func (s *state) process(tgid int) *process {
+	p, ok := s.procs[tgid]
+	if !ok {
+		p = &process{info: commandrun.ProcessInfo{ProcessID: tgid, OpenedFiles: make(map[string]cryptoutil.DigestSet)}}
+		s.procs[tgid] = p
+	}
+
+	return p
+}
+
+// resolve makes path absolute using the process' working directory, or the directory dirfd refers to if the
+// process is still running
+func (s *state) resolve(tgid int, dirfd int32, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+
+	dir, ok := s.cwds[tgid]
+	if dirfd != atFDCWD {
+		var err error
+		dir, err = os.Readlink(fmt.Sprintf("/proc/%d/fd/%d", tgid, dirfd))
+		ok = err == nil
+	}
+
+	if !ok {
+		return path
+	}
+
+	return filepath.Join(dir, path)
+}
+
+// digest returns the digest of the regular file at path, or nil if it isn't one
+func (s *state) digest(path string) cryptoutil.DigestSet {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return nil
+	}
+
+	key := fileKey{path: path, size: info.Size(), modified: info.ModTime()}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		key.dev, key.inode = uint64(stat.Dev), stat.Ino
+	}
+
+	if digest, ok := s.digests[key]; ok {
+		return digest
+	}
+
+	digest, err := cryptoutil.CalculateDigestSetFromFile(path, s.hashes)
+	if err != nil {
+		return nil
+	}
+
+	s.digests[key] = digest
+	return digest
+}
+
+// processes returns the processes that ran a program or opened a file, with their connections' programs filled in
+func (s *state) processes() []commandrun.ProcessInfo {
+	infos := make([]commandrun.ProcessInfo, 0, len(s.procs))
+	for tgid, p := range s.procs {
+		if !p.execed && len(p.info.OpenedFiles) == 0 {
+			continue
+		}
+
+		p.info.ParentPID = s.parents[tgid]
+		infos = append(infos, p.info)
+	}
+
+	for i, conn := range s.connections {
+		if p, ok := s.procs[conn.PID]; ok {
+			s.connections[i].Program = p.info.Program
+		}
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ProcessID < infos[j].ProcessID })
+	return infos
+}
+
+// parseSockaddr decodes the inet, inet6, and unix socket addresses connections are made to
+func parseSockaddr(data []byte) (Connection, bool) {
+	if len(data) < 2 {
+		return Connection{}, false
+	}
+
+	switch binary.LittleEndian.Uint16(data) {
+	case syscall.AF_INET:
+		if len(data) < 8 {
+			return Connection{}, false
+		}
+
+		port := binary.BigEndian.Uint16(data[2:])
+		return Connection{Family: "inet", Address: net.JoinHostPort(net.IP(data[4:8]).String(), strconv.Itoa(int(port)))}, true
+	case syscall.AF_INET6:
+		if len(data) < 24 {
+			return Connection{}, false
+		}
+
+		port := binary.BigEndian.Uint16(data[2:])
+		return Connection{Family: "inet6", Address: net.JoinHostPort(net.IP(data[8:24]).String(), strconv.Itoa(int(port)))}, true
+	case syscall.AF_UNIX:
+		path := data[2:]
+		// abstract socket names start with a NUL byte
+		if len(path) > 0 && path[0] == 0 {
+			return Connection{Family: "unix", Address: "@" + cString(path[1:])}, true
+		}
+
+		return Connection{Family: "unix", Address: cString(path)}, true
+	}
+
+	return Connection{}, false
+}
+
+func cString(data []byte) string {
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		data = data[:i]
+	}
+
+	return string(data)
+}
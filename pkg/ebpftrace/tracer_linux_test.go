@@ -0,0 +1,139 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpftrace
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/attestation/commandrun"
+)
+
+func TestTracer(t *testing.T) {
+	tracer, err := New()
+	if err != nil {
+		t.Skipf("eBPF tracing is unavailable: %v", err)
+	}
+
+	defer tracer.Close()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			conn.Close()
+		}
+	}()
+
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.txt")
+	require.NoError(t, os.WriteFile(input, []byte("input\n"), 0644))
+	script := filepath.Join(dir, "build.sh")
+	addr := listener.Addr().(*net.TCPAddr)
+	require.NoError(t, os.WriteFile(script, []byte(fmt.Sprintf("#!/bin/bash\ncat input.txt > /dev/null\nexec 3<>/dev/tcp/%v/%v\n", addr.IP, addr.Port)), 0755))
+
+	ctx, err := attestation.NewContext([]attestation.Attestor{})
+	require.NoError(t, err)
+	command := commandrun.New()
+	tracer.SetCommand(command)
+	require.NoError(t, tracer.PreCommand(ctx))
+	cmd := exec.Command("sh", "-c", "./build.sh 'two words'")
+	cmd.Dir = dir
+	require.NoError(t, cmd.Run())
+	require.NoError(t, tracer.PostCommand(ctx))
+
+	var build, cat *commandrun.ProcessInfo
+	for i, p := range command.Processes {
+		switch filepath.Base(p.Program) {
+		case "build.sh":
+			build = &command.Processes[i]
+		case "cat":
+			cat = &command.Processes[i]
+		}
+	}
+
+	require.NotNil(t, build)
+	assert.Equal(t, script, build.Program)
+	assert.Equal(t, "./build.sh two words", build.Cmdline)
+	assert.NotEmpty(t, build.ProgramDigest)
+	require.NotNil(t, cat)
+	assert.Equal(t, build.ProcessID, cat.ParentPID)
+	assert.Contains(t, cat.OpenedFiles, input)
+	for _, p := range command.Processes {
+		assert.NotEqual(t, os.Getpid(), p.ProcessID)
+	}
+
+	assert.Contains(t, tracer.Connections(), Connection{PID: build.ProcessID, Program: script, Family: "inet", Address: listener.Addr().String()})
+}
+
+func TestTracerLimits(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 20; i++ {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, fmt.Sprintf("%v.txt", i)), []byte("input\n"), 0644))
+	}
+
+	trace := func(opts ...Option) (int, Stats) {
+		tracer, err := New(opts...)
+		if err != nil {
+			t.Skipf("eBPF tracing is unavailable: %v", err)
+		}
+
+		defer tracer.Close()
+		ctx, err := attestation.NewContext([]attestation.Attestor{})
+		require.NoError(t, err)
+		command := commandrun.New()
+		tracer.SetCommand(command)
+		require.NoError(t, tracer.PreCommand(ctx))
+		cmd := exec.Command("sh", "-c", "for f in *.txt; do cat $f > /dev/null; done")
+		cmd.Dir = dir
+		require.NoError(t, cmd.Run())
+		require.NoError(t, tracer.PostCommand(ctx))
+		inputs := 0
+		for _, p := range command.Processes {
+			for path := range p.OpenedFiles {
+				if filepath.Dir(path) == dir {
+					inputs++
+				}
+			}
+		}
+
+		return inputs, tracer.Stats()
+	}
+
+	inputs, stats := trace()
+	assert.Equal(t, 20, inputs)
+	assert.False(t, stats.FileLimitReached)
+	assert.NotZero(t, stats.Events)
+
+	inputs, stats = trace(WithMaxFiles(5))
+	assert.LessOrEqual(t, inputs, 5)
+	assert.True(t, stats.FileLimitReached)
+
+	// the odds of a rate of 1000 sampling any of the 20 inputs are about 2%
+	inputs, _ = trace(WithSampleRate(1000))
+	assert.Less(t, inputs, 20)
+}
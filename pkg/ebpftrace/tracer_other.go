@@ -0,0 +1,53 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux
+
+package ebpftrace
+
+import (
+	"errors"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/attestation/commandrun"
+)
+
+// Tracer is only implemented on linux
+type Tracer struct{}
+
+func New(opts ...Option) (*Tracer, error) {
+	return nil, errors.New("eBPF tracing is only available on linux")
+}
+
+func (t *Tracer) SetCommand(command *commandrun.CommandRun) {}
+
+func (t *Tracer) PreCommand(ctx *attestation.AttestationContext) error {
+	return nil
+}
+
+func (t *Tracer) PostCommand(ctx *attestation.AttestationContext) error {
+	return nil
+}
+
+func (t *Tracer) Connections() []Connection {
+	return nil
+}
+
+func (t *Tracer) Stats() Stats {
+	return Stats{}
+}
+
+func (t *Tracer) Close() error {
+	return nil
+}
@@ -0,0 +1,188 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package envshim runs a command with a different environment than the calling process. Witness can't set the
+// environment of the command go-witness starts, so it starts itself instead through reexec. The copy sets the
+// environment, then replaces itself with the command.
+package envshim
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/testifysec/witness/pkg/reexec"
+)
+
+// ShimArg is the first argument of a witness process that should set the environment and run a command
+const ShimArg = "__witness-env"
+
+// Overrides are the changes to make to the environment of a command
+type Overrides struct {
+	// Clean starts the command from an empty environment instead of the caller's
+	Clean bool `json:"clean"`
+	// Vars are KEY=VALUE pairs to set. Later pairs override earlier ones.
+	Vars []string `json:"vars"`
+}
+
+// Empty returns true if the overrides don't change the environment
+func (o Overrides) Empty() bool {
+	return !o.Clean && len(o.Vars) == 0
+}
+
+// Environ returns base with the overrides applied
+func (o Overrides) Environ(base []string) []string {
+	if o.Clean {
+		base = nil
+	}
+
+	env := []string{}
+	index := make(map[string]int)
+	for _, kv := range append(append([]string{}, base...), o.Vars...) {
+		key, _, _ := strings.Cut(kv, "=")
+		if i, ok := index[key]; ok {
+			env[i] = kv
+			continue
+		}
+
+		index[key] = len(env)
+		env = append(env, kv)
+	}
+
+	return env
+}
+
+// ParseVars checks each var is a KEY=VALUE pair with a non-empty key
+func ParseVars(vars []string) error {
+	for _, kv := range vars {
+		key, _, ok := strings.Cut(kv, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("environment variable %q must be KEY=VALUE", kv)
+		}
+	}
+
+	return nil
+}
+
+// ReadFile reads KEY=VALUE pairs from the file at path, one per line. Blank lines and lines starting with # are
+// skipped, and values are used as they are, without unquoting.
+func ReadFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open env file: %w", err)
+	}
+
+	defer f.Close()
+	vars := []string{}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		if err := ParseVars([]string{text}); err != nil {
+			return nil, fmt.Errorf("%v:%d: %w", path, line, err)
+		}
+
+		vars = append(vars, text)
+	}
+
+	return vars, scanner.Err()
+}
+
+// Command returns the arguments that run args with the overrides by running the current executable as a shim.
+// The overrides are passed in a file rather than as arguments, so values such as tokens aren't visible in the
+// process list. The shim removes the file once it has read it; cleanup removes it if the shim never ran.
+func Command(o Overrides, args []string) ([]string, func(), error) {
+	if len(args) == 0 {
+		return nil, nil, fmt.Errorf("a command is required to set its environment")
+	}
+
+	overridesBytes, err := json.Marshal(o)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f, err := os.CreateTemp("", "witness-env-*.json")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to write command environment: %w", err)
+	}
+
+	cleanup := func() { os.Remove(f.Name()) }
+	if _, err := f.Write(overridesBytes); err != nil {
+		f.Close()
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to write command environment: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to write command environment: %w", err)
+	}
+
+	command, err := reexec.Command(ShimArg, []string{f.Name()}, args)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+
+	return command, cleanup, nil
+}
+
+// Init hands the process to the environment shim if Command started it. The shim reads and removes the overrides
+// file Command wrote, then becomes the command, or exits with status 126 if the command can't be found or started.
+func Init() {
+	reexec.Init(ShimArg, []string{"overrides-file"}, shim)
+}
+
+// shim replaces the process with command, run with the environment in the overrides file params names. It only
+// returns if that fails.
+func shim(params, command []string) (int, error) {
+	overridesBytes, err := os.ReadFile(params[0])
+	if err != nil {
+		return 0, fmt.Errorf("failed to read command environment: %w", err)
+	}
+
+	os.Remove(params[0])
+	o := Overrides{}
+	if err := json.Unmarshal(overridesBytes, &o); err != nil {
+		return 0, fmt.Errorf("failed to parse command environment: %w", err)
+	}
+
+	os.Setenv("PATH", o.SearchPath())
+	path, err := exec.LookPath(command[0])
+	if err != nil {
+		return 0, err
+	}
+
+	env := o.Environ(os.Environ())
+
+	return 0, execWithEnv(path, command, env)
+}
+
+// SearchPath returns the PATH the command is looked up in: its own PATH, or the caller's if the overrides leave it
+// without one, so commands can still be found by name when the environment is clean
+func (o Overrides) SearchPath() string {
+	for _, kv := range o.Environ(os.Environ()) {
+		if strings.HasPrefix(kv, "PATH=") {
+			return strings.TrimPrefix(kv, "PATH=")
+		}
+	}
+
+	return os.Getenv("PATH")
+}
@@ -0,0 +1,84 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package envshim
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	Init()
+	os.Exit(m.Run())
+}
+
+func TestEnviron(t *testing.T) {
+	base := []string{"HOME=/root", "PATH=/bin", "TOKEN=secret"}
+	assert.Equal(t, base, Overrides{}.Environ(base))
+	assert.Equal(t, []string{"HOME=/root", "PATH=/opt/bin", "TOKEN=secret", "CC=clang"}, Overrides{Vars: []string{"PATH=/usr/bin", "CC=clang", "PATH=/opt/bin"}}.Environ(base))
+	assert.Equal(t, []string{"CC=clang"}, Overrides{Clean: true, Vars: []string{"CC=clang"}}.Environ(base))
+	assert.True(t, Overrides{}.Empty())
+	assert.False(t, Overrides{Clean: true}.Empty())
+}
+
+func TestReadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "build.env")
+	require.NoError(t, os.WriteFile(path, []byte("# toolchain\nCC=clang\n\n  CFLAGS=-O2 -g  \nEMPTY=\n"), 0600))
+	vars, err := ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"CC=clang", "CFLAGS=-O2 -g", "EMPTY="}, vars)
+
+	require.NoError(t, os.WriteFile(path, []byte("CC=clang\nnot a variable\n"), 0600))
+	_, err = ReadFile(path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), ":2:")
+
+	assert.Error(t, ParseVars([]string{"=value"}))
+	assert.NoError(t, ParseVars([]string{"KEY=a=b"}))
+}
+
+func TestCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test command needs a posix shell")
+	}
+
+	t.Setenv("WITNESS_ENVSHIM_INHERITED", "inherited")
+	args, cleanup, err := Command(Overrides{Vars: []string{"WITNESS_ENVSHIM_SET=set"}}, []string{"sh", "-c", "echo $WITNESS_ENVSHIM_INHERITED $WITNESS_ENVSHIM_SET"})
+	require.NoError(t, err)
+	defer cleanup()
+	out, err := exec.Command(args[0], args[1:]...).Output()
+	require.NoError(t, err)
+	assert.Equal(t, "inherited set", strings.TrimSpace(string(out)))
+	_, err = os.Stat(args[2])
+	assert.True(t, os.IsNotExist(err), "the shim should remove the overrides file")
+
+	args, cleanup, err = Command(Overrides{Clean: true, Vars: []string{"WITNESS_ENVSHIM_SET=set"}}, []string{"sh", "-c", "env"})
+	require.NoError(t, err)
+	defer cleanup()
+	out, err = exec.Command(args[0], args[1:]...).Output()
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "WITNESS_ENVSHIM_SET=set")
+	assert.NotContains(t, string(out), "WITNESS_ENVSHIM_INHERITED")
+
+	_, _, err = Command(Overrides{Clean: true}, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,37 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package envshim
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// execWithEnv runs args and exits with its status, since processes can't be replaced on windows
+func execWithEnv(path string, args, env []string) error {
+	cmd := exec.Command(path, args[1:]...)
+	cmd.Env = env
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	err := cmd.Run()
+	exitErr := &exec.ExitError{}
+	if err == nil || errors.As(err, &exitErr) {
+		os.Exit(cmd.ProcessState.ExitCode())
+	}
+
+	return err
+}
@@ -0,0 +1,180 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filehash
+
+import (
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+)
+
+// racyWindow is how recently a file can have been modified and still be cached. A file modified again within
+// the resolution of its filesystem's timestamps could keep the same size and modification time, so recently
+// modified files are always hashed.
+const racyWindow = 2 * time.Second
+
+type cacheEntry struct {
+	Size                  int64                `json:"size"`
+	ModTime               int64                `json:"modTime"`
+	NormalizedLineEndings bool                 `json:"normalizedLineEndings,omitempty"`
+	Digest                cryptoutil.DigestSet `json:"digest"`
+}
+
+// Cache remembers the digests of files by their path, size and modification time so files that haven't changed
+// since an earlier run don't need to be hashed again. A nil *Cache is valid and caches nothing.
+type Cache struct {
+	path    string
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	seen    map[string]struct{}
+}
+
+// LoadCache reads the cache stored at path. A missing or unreadable cache file results in an empty cache.
+func LoadCache(path string) *Cache {
+	c := &Cache{
+		path:    path,
+		entries: make(map[string]cacheEntry),
+		seen:    make(map[string]struct{}),
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return c
+	}
+
+	if err := json.Unmarshal(data, &c.entries); err != nil {
+		c.entries = make(map[string]cacheEntry)
+	}
+
+	return c
+}
+
+// Save writes the entries for files seen since the cache was loaded, dropping entries for files that no longer
+// exist or weren't recorded.
+func (c *Cache) Save() error {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	entries := make(map[string]cacheEntry, len(c.seen))
+	for path := range c.seen {
+		if entry, ok := c.entries[path]; ok {
+			entries[path] = entry
+		}
+	}
+
+	c.mu.Unlock()
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0700); err != nil {
+		return fmt.Errorf("failed to create hash cache directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), ".hashcache-*")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), c.path)
+}
+
+// digest returns the digest set of the file at path, using the cached digests if the file's size and
+// modification time haven't changed and every hash in hashes was cached with the same line ending handling.
+func (c *Cache) digest(path string, hashes []crypto.Hash, normalizeLineEndings bool) (cryptoutil.DigestSet, error) {
+	if c == nil {
+		return calculateDigestSet(path, hashes, normalizeLineEndings)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if digestSet, ok := c.get(key, info, hashes, normalizeLineEndings); ok {
+		return digestSet, nil
+	}
+
+	digestSet, err := calculateDigestSet(path, hashes, normalizeLineEndings)
+	if err != nil {
+		return nil, err
+	}
+
+	c.put(key, info, digestSet, normalizeLineEndings)
+	return digestSet, nil
+}
+
+func (c *Cache) get(path string, info fs.FileInfo, hashes []crypto.Hash, normalizeLineEndings bool) (cryptoutil.DigestSet, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seen[path] = struct{}{}
+	entry, ok := c.entries[path]
+	if !ok || entry.Size != info.Size() || entry.ModTime != info.ModTime().UnixNano() || entry.NormalizedLineEndings != normalizeLineEndings {
+		return nil, false
+	}
+
+	digestSet := make(cryptoutil.DigestSet, len(hashes))
+	for _, hash := range hashes {
+		digest, ok := entry.Digest[hash]
+		if !ok {
+			return nil, false
+		}
+
+		digestSet[hash] = digest
+	}
+
+	return digestSet, true
+}
+
+func (c *Cache) put(path string, info fs.FileInfo, digestSet cryptoutil.DigestSet, normalizeLineEndings bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Since(info.ModTime()) < racyWindow {
+		delete(c.entries, path)
+		return
+	}
+
+	c.entries[path] = cacheEntry{
+		Size:                  info.Size(),
+		ModTime:               info.ModTime().UnixNano(),
+		NormalizedLineEndings: normalizeLineEndings,
+		Digest:                digestSet,
+	}
+}
@@ -0,0 +1,413 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package filehash records the digests of every file in a directory tree, hashing files concurrently.
+// Files matched by ignore files, such as .witnessignore, and special files like sockets and devices
+// are skipped.
+package filehash
+
+import (
+	"bufio"
+	"context"
+	"crypto"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/log"
+)
+
+const (
+	WitnessIgnoreFile = ".witnessignore"
+	GitIgnoreFile     = ".gitignore"
+)
+
+// SymlinkMode controls how symlinks in the tree are recorded
+type SymlinkMode string
+
+const (
+	// SymlinkFollow records the file a symlink points to, or every file in the directory it points to, under
+	// the symlink's path. Each target is only followed once.
+	SymlinkFollow SymlinkMode = "follow"
+	// SymlinkRecord records the digest of the symlink's target path instead of following it, like git does.
+	SymlinkRecord SymlinkMode = "record"
+	// SymlinkSkip leaves symlinks out entirely.
+	SymlinkSkip SymlinkMode = "skip"
+)
+
+// EscapeMode controls what happens to symlinks that point outside of the tree being recorded
+// when symlinks are followed
+type EscapeMode string
+
+const (
+	// EscapeRecord records the digest of the symlink's target path instead of reading outside of the tree
+	EscapeRecord EscapeMode = "record"
+	// EscapeFollow follows the symlink like any other
+	EscapeFollow EscapeMode = "follow"
+	// EscapeError fails the recording
+	EscapeError EscapeMode = "error"
+)
+
+type Option func(*options)
+
+type options struct {
+	ctx                  context.Context
+	workers              int
+	ignoreFiles          []string
+	cache                *Cache
+	symlinks             SymlinkMode
+	escapes              EscapeMode
+	normalizeLineEndings bool
+}
+
+// WithNormalizedLineEndings hashes text files as if every CRLF line ending were LF, so files checked out on
+// Windows have the same digests as on other platforms. Files containing a NUL byte in their first 8000 bytes
+// are treated as binary and hashed as they are.
+func WithNormalizedLineEndings() Option {
+	return func(o *options) {
+		o.normalizeLineEndings = true
+	}
+}
+
+// WithSymlinks sets how symlinks are recorded. Defaults to SymlinkFollow.
+func WithSymlinks(mode SymlinkMode) Option {
+	return func(o *options) {
+		o.symlinks = mode
+	}
+}
+
+// WithEscapingSymlinks sets what happens to followed symlinks that point outside of the tree. Defaults to
+// EscapeRecord.
+func WithEscapingSymlinks(mode EscapeMode) Option {
+	return func(o *options) {
+		o.escapes = mode
+	}
+}
+
+// WithWorkers sets how many files are hashed at once. Values less than 1 use the number of CPUs.
+func WithWorkers(workers int) Option {
+	return func(o *options) {
+		o.workers = workers
+	}
+}
+
+// WithIgnoreFiles sets the names of the ignore files read from each directory. Ignore files use
+// gitignore syntax, and files later in the list take precedence. Defaults to .witnessignore.
+func WithIgnoreFiles(names ...string) Option {
+	return func(o *options) {
+		o.ignoreFiles = names
+	}
+}
+
+// WithCache reuses digests from cache for files whose size and modification time haven't changed, and
+// adds the digests of files that were hashed to it.
+func WithCache(cache *Cache) Option {
+	return func(o *options) {
+		o.cache = cache
+	}
+}
+
+// WithContext stops recording files once ctx is canceled.
+func WithContext(ctx context.Context) Option {
+	return func(o *options) {
+		o.ctx = ctx
+	}
+}
+
+// file is a file to hash, or a symlink whose target path should be hashed when linkTarget is set
+type file struct {
+	path       string
+	relPath    string
+	linkTarget string
+}
+
+func (f file) digest(o options, hashes []crypto.Hash) (cryptoutil.DigestSet, error) {
+	if f.linkTarget != "" {
+		return cryptoutil.CalculateDigestSetFromBytes([]byte(filepath.ToSlash(f.linkTarget)), hashes)
+	}
+
+	return o.cache.digest(f.path, hashes, o.normalizeLineEndings)
+}
+
+// Record walks dir and returns the digest set of every file, keyed by its slash separated path relative to dir. Symlinks are
+// handled as set by WithSymlinks and WithEscapingSymlinks. Hardlinks are regular files, and are recorded at
+// each of their paths. Files with the same digests they have in baseArtifacts are left out of the result.
+func Record(dir string, baseArtifacts map[string]cryptoutil.DigestSet, hashes []crypto.Hash, opts ...Option) (map[string]cryptoutil.DigestSet, error) {
+	o := options{
+		ctx:         context.Background(),
+		workers:     runtime.NumCPU(),
+		ignoreFiles: []string{WitnessIgnoreFile},
+		symlinks:    SymlinkFollow,
+		escapes:     EscapeRecord,
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	switch o.symlinks {
+	case SymlinkFollow, SymlinkRecord, SymlinkSkip:
+	default:
+		return nil, fmt.Errorf("unknown symlink mode %v", o.symlinks)
+	}
+
+	switch o.escapes {
+	case EscapeRecord, EscapeFollow, EscapeError:
+	default:
+		return nil, fmt.Errorf("unknown escaping symlink mode %v", o.escapes)
+	}
+
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err = filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.workers < 1 {
+		o.workers = runtime.NumCPU()
+	}
+
+	files := make(chan file)
+	artifacts := make(map[string]cryptoutil.DigestSet)
+	mu := sync.Mutex{}
+	var hashErr error
+	wg := sync.WaitGroup{}
+	for i := 0; i < o.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for f := range files {
+				digestSet, err := f.digest(o, hashes)
+				mu.Lock()
+				if err != nil && hashErr == nil {
+					hashErr = fmt.Errorf("failed to hash %v: %w", f.relPath, err)
+				}
+
+				// paths are always slash separated so attestations match across platforms
+				relPath := filepath.ToSlash(f.relPath)
+				if err == nil && shouldRecord(relPath, digestSet, baseArtifacts) {
+					artifacts[relPath] = digestSet
+				}
+
+				mu.Unlock()
+			}
+		}()
+	}
+
+	w := walker{
+		options:         o,
+		root:            root,
+		files:           files,
+		visitedSymlinks: make(map[string]struct{}),
+	}
+
+	walkErr := w.walk(dir, "", nil)
+	close(files)
+	wg.Wait()
+	if walkErr != nil {
+		return nil, walkErr
+	}
+
+	if hashErr != nil {
+		return nil, hashErr
+	}
+
+	return artifacts, nil
+}
+
+// shouldRecord returns false if artifact is unchanged from the artifact at the same path in baseArtifacts
+func shouldRecord(path string, artifact cryptoutil.DigestSet, baseArtifacts map[string]cryptoutil.DigestSet) bool {
+	if previous, ok := baseArtifacts[path]; ok && artifact.Equal(previous) {
+		return false
+	}
+
+	return true
+}
+
+type walker struct {
+	options
+	root            string
+	files           chan<- file
+	visitedSymlinks map[string]struct{}
+}
+
+// walk sends every file under root to the hashing workers. prefix is the path root is recorded under, and
+// patterns are the ignore patterns inherited from the directories above root.
+func (w *walker) walk(root, prefix string, patterns []gitignore.Pattern) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if err := w.ctx.Err(); err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		relPath = filepath.Join(prefix, relPath)
+		if relPath != "." && len(patterns) > 0 {
+			if gitignore.NewMatcher(patterns).Match(splitPath(relPath), d.IsDir()) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+
+				return nil
+			}
+		}
+
+		switch {
+		case d.IsDir():
+			ignored, err := w.readIgnoreFiles(path, relPath)
+			if err != nil {
+				return err
+			}
+
+			patterns = append(patterns, ignored...)
+			return nil
+
+		case d.Type()&fs.ModeSymlink != 0:
+			return w.followSymlink(path, relPath, patterns)
+
+		case !d.Type().IsRegular():
+			log.Debugf("(filehash) skipping special file %v", relPath)
+			return nil
+		}
+
+		w.files <- file{path: path, relPath: relPath}
+		return nil
+	})
+}
+
+// followSymlink records a symlink as set by the walker's symlink modes. Every target is only followed once
+// to prevent loops.
+func (w *walker) followSymlink(path, relPath string, patterns []gitignore.Pattern) error {
+	switch w.symlinks {
+	case SymlinkSkip:
+		return nil
+	case SymlinkRecord:
+		return w.recordLinkTarget(path, relPath)
+	}
+
+	linkedPath, err := filepath.EvalSymlinks(path)
+	if os.IsNotExist(err) {
+		log.Debugf("(filehash) broken symlink detected: %v", path)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if w.escapes != EscapeFollow && escapes(w.root, linkedPath) {
+		if w.escapes == EscapeError {
+			return fmt.Errorf("symlink %v points outside of %v", relPath, w.root)
+		}
+
+		log.Debugf("(filehash) symlink %v points outside of %v, recording its target", relPath, w.root)
+		return w.recordLinkTarget(path, relPath)
+	}
+
+	if _, ok := w.visitedSymlinks[linkedPath]; ok {
+		return nil
+	}
+
+	w.visitedSymlinks[linkedPath] = struct{}{}
+	info, err := os.Stat(linkedPath)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case info.IsDir():
+		return w.walk(linkedPath, relPath, patterns)
+	case info.Mode().IsRegular():
+		w.files <- file{path: linkedPath, relPath: relPath}
+	default:
+		log.Debugf("(filehash) skipping special file %v", relPath)
+	}
+
+	return nil
+}
+
+func (w *walker) recordLinkTarget(path, relPath string) error {
+	target, err := os.Readlink(path)
+	if err != nil {
+		return err
+	}
+
+	w.files <- file{path: path, relPath: relPath, linkTarget: target}
+	return nil
+}
+
+// escapes returns true if path isn't root or inside of it. Both must be absolute with symlinks evaluated.
+func escapes(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return true
+	}
+
+	return rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// readIgnoreFiles parses the ignore files in dir, scoping their patterns to the directory
+func (w *walker) readIgnoreFiles(dir, relPath string) ([]gitignore.Pattern, error) {
+	domain := []string{}
+	if relPath != "." {
+		domain = splitPath(relPath)
+	}
+
+	patterns := []gitignore.Pattern{}
+	for _, name := range w.ignoreFiles {
+		f, err := os.Open(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
+				continue
+			}
+
+			patterns = append(patterns, gitignore.ParsePattern(line, domain))
+		}
+
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %v: %w", filepath.Join(relPath, name), err)
+		}
+	}
+
+	return patterns, nil
+}
+
+func splitPath(path string) []string {
+	return strings.Split(filepath.ToSlash(path), "/")
+}
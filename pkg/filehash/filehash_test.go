@@ -0,0 +1,204 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filehash
+
+import (
+	"context"
+	"crypto"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/cryptoutil"
+)
+
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	for path, content := range files {
+		fullPath := filepath.Join(dir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0755))
+		require.NoError(t, os.WriteFile(fullPath, []byte(content), 0644))
+	}
+}
+
+func recordedPaths(artifacts map[string]cryptoutil.DigestSet) []string {
+	paths := []string{}
+	for path := range artifacts {
+		paths = append(paths, filepath.ToSlash(path))
+	}
+
+	return paths
+}
+
+func TestRecord(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"a.txt":        "a",
+		"src/b.txt":    "b",
+		"src/deep/c":   "c",
+		"linked/d.txt": "d",
+	})
+
+	require.NoError(t, os.Symlink(filepath.Join(dir, "linked"), filepath.Join(dir, "link")))
+	require.NoError(t, os.Symlink(filepath.Join(dir, "missing"), filepath.Join(dir, "broken")))
+	if listener, err := net.Listen("unix", filepath.Join(dir, "sock")); err == nil {
+		defer listener.Close()
+	}
+
+	artifacts, err := Record(dir, nil, []crypto.Hash{crypto.SHA256}, WithWorkers(2))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a.txt", "src/b.txt", "src/deep/c", "linked/d.txt", "link/d.txt"}, recordedPaths(artifacts))
+
+	expected, err := cryptoutil.CalculateDigestSetFromFile(filepath.Join(dir, "src/b.txt"), []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+	assert.Equal(t, expected, artifacts[filepath.Join("src", "b.txt")])
+
+	writeTree(t, dir, map[string]string{"a.txt": "changed"})
+	changed, err := Record(dir, artifacts, []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.txt"}, recordedPaths(changed))
+}
+
+func TestRecordCanceled(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"a.txt": "a"})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := Record(dir, nil, []crypto.Hash{crypto.SHA256}, WithContext(ctx))
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestRecordIgnoreFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		".gitignore":          "dist/\n*.log\n",
+		".witnessignore":      "# comment\nnode_modules\n!keep.log\n",
+		"keep.log":            "kept",
+		"build.log":           "ignored",
+		"dist/app":            "ignored",
+		"node_modules/x/y.js": "ignored",
+		"src/main.go":         "main",
+		"src/.witnessignore":  "generated.go\n",
+		"src/generated.go":    "ignored",
+		"other/generated.go":  "kept",
+	})
+
+	artifacts, err := Record(dir, nil, []crypto.Hash{crypto.SHA256})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{".gitignore", ".witnessignore", "keep.log", "build.log", "dist/app", "src/main.go", "src/.witnessignore", "other/generated.go"}, recordedPaths(artifacts))
+
+	artifacts, err = Record(dir, nil, []crypto.Hash{crypto.SHA256}, WithIgnoreFiles(GitIgnoreFile, WitnessIgnoreFile))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{".gitignore", ".witnessignore", "keep.log", "src/main.go", "src/.witnessignore", "other/generated.go"}, recordedPaths(artifacts))
+}
+
+func TestRecordCache(t *testing.T) {
+	dir := t.TempDir()
+	cachePath := filepath.Join(t.TempDir(), "cache.json")
+	writeTree(t, dir, map[string]string{"old.txt": "old", "new.txt": "new"})
+	past := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "old.txt"), past, past))
+
+	cache := LoadCache(cachePath)
+	first, err := Record(dir, nil, []crypto.Hash{crypto.SHA256}, WithCache(cache))
+	require.NoError(t, err)
+	require.NoError(t, cache.Save())
+
+	// rewriting a file with the same size and modification time isn't noticed, but recently modified files are rehashed
+	writeTree(t, dir, map[string]string{"old.txt": "OLD", "new.txt": "NEW"})
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "old.txt"), past, past))
+	cache = LoadCache(cachePath)
+	second, err := Record(dir, nil, []crypto.Hash{crypto.SHA256}, WithCache(cache))
+	require.NoError(t, err)
+	assert.Equal(t, first["old.txt"], second["old.txt"])
+	assert.NotEqual(t, first["new.txt"], second["new.txt"])
+
+	// hashes that weren't cached are calculated
+	third, err := Record(dir, nil, []crypto.Hash{crypto.SHA256, crypto.SHA1}, WithCache(cache))
+	require.NoError(t, err)
+	assert.NotEqual(t, first["old.txt"][crypto.SHA256], third["old.txt"][crypto.SHA256])
+
+	writeTree(t, dir, map[string]string{"old.txt": "changed"})
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "old.txt"), past, past))
+	fourth, err := Record(dir, nil, []crypto.Hash{crypto.SHA256}, WithCache(LoadCache(cachePath)))
+	require.NoError(t, err)
+	assert.NotEqual(t, first["old.txt"], fourth["old.txt"])
+}
+
+func TestRecordSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	writeTree(t, dir, map[string]string{"a.txt": "a"})
+	writeTree(t, outside, map[string]string{"secret.txt": "secret"})
+	require.NoError(t, os.Symlink(filepath.Join(dir, "a.txt"), filepath.Join(dir, "in")))
+	require.NoError(t, os.Symlink(outside, filepath.Join(dir, "out")))
+	hashes := []crypto.Hash{crypto.SHA256}
+	fileDigest, err := cryptoutil.CalculateDigestSetFromFile(filepath.Join(dir, "a.txt"), hashes)
+	require.NoError(t, err)
+	inTarget, err := cryptoutil.CalculateDigestSetFromBytes([]byte(filepath.Join(dir, "a.txt")), hashes)
+	require.NoError(t, err)
+	outTarget, err := cryptoutil.CalculateDigestSetFromBytes([]byte(outside), hashes)
+	require.NoError(t, err)
+
+	artifacts, err := Record(dir, nil, hashes)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a.txt", "in", "out"}, recordedPaths(artifacts))
+	assert.Equal(t, fileDigest, artifacts["in"])
+	assert.Equal(t, outTarget, artifacts["out"])
+
+	artifacts, err = Record(dir, nil, hashes, WithEscapingSymlinks(EscapeFollow))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a.txt", "in", "out/secret.txt"}, recordedPaths(artifacts))
+
+	_, err = Record(dir, nil, hashes, WithEscapingSymlinks(EscapeError))
+	assert.Error(t, err)
+
+	artifacts, err = Record(dir, nil, hashes, WithSymlinks(SymlinkRecord), WithEscapingSymlinks(EscapeError))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a.txt", "in", "out"}, recordedPaths(artifacts))
+	assert.Equal(t, inTarget, artifacts["in"])
+	assert.Equal(t, outTarget, artifacts["out"])
+
+	artifacts, err = Record(dir, nil, hashes, WithSymlinks(SymlinkSkip))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a.txt"}, recordedPaths(artifacts))
+
+	_, err = Record(dir, nil, hashes, WithSymlinks("bogus"))
+	assert.Error(t, err)
+}
+
+func TestRecordNormalizedLineEndings(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"windows/file.txt": "line one\r\nline two\r\nlone\rcarriage\r\n",
+		"posix/file.txt":   "line one\nline two\nlone\rcarriage\n",
+		"binary.bin":       "\x00\r\n",
+	})
+
+	hashes := []crypto.Hash{crypto.SHA256}
+	artifacts, err := Record(dir, nil, hashes)
+	require.NoError(t, err)
+	assert.NotEqual(t, artifacts["windows/file.txt"], artifacts["posix/file.txt"])
+	rawBinary := artifacts["binary.bin"]
+
+	cache := LoadCache(filepath.Join(t.TempDir(), "cache.json"))
+	artifacts, err = Record(dir, nil, hashes, WithNormalizedLineEndings(), WithCache(cache))
+	require.NoError(t, err)
+	assert.Equal(t, artifacts["windows/file.txt"], artifacts["posix/file.txt"])
+	assert.Equal(t, rawBinary, artifacts["binary.bin"])
+}
@@ -0,0 +1,80 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filehash
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"io"
+	"os"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+)
+
+// binarySniffLen is how much of a file is checked for a NUL byte to decide if it's binary, matching git
+const binarySniffLen = 8000
+
+// calculateDigestSet hashes the file at path, converting CRLF line endings to LF first if normalizeLineEndings
+// is set and the file looks like text
+func calculateDigestSet(path string, hashes []crypto.Hash, normalizeLineEndings bool) (cryptoutil.DigestSet, error) {
+	if !normalizeLineEndings {
+		return cryptoutil.CalculateDigestSetFromFile(path, hashes)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	r := bufio.NewReaderSize(f, binarySniffLen)
+	head, err := r.Peek(binarySniffLen)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+
+	if bytes.IndexByte(head, 0) >= 0 {
+		return cryptoutil.CalculateDigestSet(r, hashes)
+	}
+
+	return cryptoutil.CalculateDigestSet(&crlfReader{r: r}, hashes)
+}
+
+// crlfReader reads from r with every CRLF replaced by LF
+type crlfReader struct {
+	r *bufio.Reader
+}
+
+func (c *crlfReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return n, err
+		}
+
+		if b == '\r' {
+			if next, err := c.r.Peek(1); err == nil && next[0] == '\n' {
+				continue
+			}
+		}
+
+		p[n] = b
+		n++
+	}
+
+	return n, nil
+}
@@ -0,0 +1,184 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fips restricts keys and certificates to a fixed set of algorithms: ECDSA on P-256 or P-384,
+// RSA keys of at least 3072 bits, and certificates signed with SHA-2 digests. It only enforces this set
+// and doesn't check everything FIPS 186-5 or SP 800-131A requires.
+package fips
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/policy"
+)
+
+// MinRSABits is the smallest RSA modulus accepted.
+const MinRSABits = 3072
+
+// CheckPublicKey returns an error if pub isn't an approved key.
+func CheckPublicKey(pub interface{}) error {
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		if key.N.BitLen() < MinRSABits {
+			return fmt.Errorf("%d bit RSA keys are not allowed in FIPS mode, at least %d bits are required", key.N.BitLen(), MinRSABits)
+		}
+
+		return nil
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() && key.Curve != elliptic.P384() {
+			return fmt.Errorf("ECDSA keys on curve %v are not allowed in FIPS mode, P-256 or P-384 is required", key.Curve.Params().Name)
+		}
+
+		return nil
+	case *x509.Certificate:
+		return CheckCertificate(key)
+	default:
+		return fmt.Errorf("%T keys are not allowed in FIPS mode", pub)
+	}
+}
+
+// CheckCertificate returns an error if cert's key isn't approved or it was signed with a digest other than SHA-2.
+func CheckCertificate(cert *x509.Certificate) error {
+	switch cert.SignatureAlgorithm {
+	case x509.SHA256WithRSA, x509.SHA384WithRSA, x509.SHA512WithRSA,
+		x509.SHA256WithRSAPSS, x509.SHA384WithRSAPSS, x509.SHA512WithRSAPSS,
+		x509.ECDSAWithSHA256, x509.ECDSAWithSHA384, x509.ECDSAWithSHA512:
+	default:
+		return fmt.Errorf("certificate %v is signed with %v, which is not allowed in FIPS mode", cert.Subject, cert.SignatureAlgorithm)
+	}
+
+	if err := CheckPublicKey(cert.PublicKey); err != nil {
+		return fmt.Errorf("certificate %v: %w", cert.Subject, err)
+	}
+
+	return nil
+}
+
+// CheckVerifier returns an error if v verifies with a key or certificate chain that isn't approved.
+func CheckVerifier(v cryptoutil.Verifier) error {
+	if x509Verifier, ok := v.(*cryptoutil.X509Verifier); ok {
+		certs := append([]*x509.Certificate{x509Verifier.Certificate()}, x509Verifier.Intermediates()...)
+		certs = append(certs, x509Verifier.Roots()...)
+		return checkCertificates(certs)
+	}
+
+	keyBytes, err := v.Bytes()
+	if err != nil {
+		return err
+	}
+
+	pub, err := cryptoutil.TryParseKeyFromReader(bytes.NewReader(keyBytes))
+	if err != nil {
+		return err
+	}
+
+	return CheckPublicKey(pub)
+}
+
+// CheckSigner returns an error if s signs with a key or certificate chain that isn't approved.
+func CheckSigner(s cryptoutil.Signer) error {
+	v, err := s.Verifier()
+	if err != nil {
+		return err
+	}
+
+	return CheckVerifier(v)
+}
+
+// CheckPolicy returns an error if any functionary key, root, or timestamp authority in pol isn't approved.
+func CheckPolicy(pol policy.Policy) error {
+	verifiers, err := pol.PublicKeyVerifiers()
+	if err != nil {
+		return err
+	}
+
+	for keyID, v := range verifiers {
+		if err := CheckVerifier(v); err != nil {
+			return fmt.Errorf("policy key %v: %w", keyID, err)
+		}
+	}
+
+	for _, getBundles := range []func() (map[string]policy.TrustBundle, error){pol.TrustBundles, pol.TimestampAuthorityTrustBundles} {
+		bundles, err := getBundles()
+		if err != nil {
+			return err
+		}
+
+		for name, bundle := range bundles {
+			if err := checkCertificates(append([]*x509.Certificate{bundle.Root}, bundle.Intermediates...)); err != nil {
+				return fmt.Errorf("policy root %v: %w", name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// FilterSignatures removes the signatures from env that were made with certificates that aren't approved, and
+// returns the key ids of the removed signatures. Signatures made with bare keys are kept, since they can only be
+// trusted if their key is in a policy, which CheckPolicy covers.
+func FilterSignatures(env dsse.Envelope) (dsse.Envelope, []string) {
+	kept := make([]dsse.Signature, 0, len(env.Signatures))
+	rejected := []string{}
+	for _, sig := range env.Signatures {
+		if err := checkSignatureCertificates(sig); err != nil {
+			rejected = append(rejected, sig.KeyID)
+			continue
+		}
+
+		kept = append(kept, sig)
+	}
+
+	env.Signatures = kept
+	return env, rejected
+}
+
+func checkSignatureCertificates(sig dsse.Signature) error {
+	if len(sig.Certificate) == 0 {
+		return nil
+	}
+
+	certs := []*x509.Certificate{}
+	for _, certBytes := range append([][]byte{sig.Certificate}, sig.Intermediates...) {
+		cert, err := cryptoutil.TryParseCertificate(certBytes)
+		if err != nil {
+			return err
+		}
+
+		certs = append(certs, cert)
+	}
+
+	return checkCertificates(certs)
+}
+
+func checkCertificates(certs []*x509.Certificate) error {
+	for _, cert := range certs {
+		if cert == nil {
+			continue
+		}
+
+		if err := CheckCertificate(cert); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
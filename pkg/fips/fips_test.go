@@ -0,0 +1,95 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fips
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+)
+
+func selfSignedCert(t *testing.T, priv interface{}, pub interface{}) *x509.Certificate {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, template, pub, priv)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(certBytes)
+	require.NoError(t, err)
+	return cert
+}
+
+func TestCheckPublicKey(t *testing.T) {
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	assert.NoError(t, CheckPublicKey(&p256.PublicKey))
+
+	p521, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	require.NoError(t, err)
+	assert.Error(t, CheckPublicKey(&p521.PublicKey))
+
+	rsa2048, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	assert.Error(t, CheckPublicKey(&rsa2048.PublicKey))
+
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	assert.Error(t, CheckPublicKey(edPub))
+
+	assert.NoError(t, CheckSigner(cryptoutil.NewECDSASigner(p256, 0)))
+	assert.Error(t, CheckSigner(cryptoutil.NewRSASigner(rsa2048, 0)))
+}
+
+func TestFilterSignatures(t *testing.T) {
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	approved := selfSignedCert(t, p256, &p256.PublicKey)
+	assert.NoError(t, CheckCertificate(approved))
+
+	p521, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	require.NoError(t, err)
+	rejected := selfSignedCert(t, p521, &p521.PublicKey)
+	assert.Error(t, CheckCertificate(rejected))
+
+	env := dsse.Envelope{Signatures: []dsse.Signature{
+		{KeyID: "key"},
+		{KeyID: "approved", Certificate: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: approved.Raw})},
+		{KeyID: "rejected", Certificate: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: rejected.Raw})},
+	}}
+
+	filtered, removed := FilterSignatures(env)
+	assert.Equal(t, []string{"rejected"}, removed)
+	require.Len(t, filtered.Signatures, 2)
+	assert.Equal(t, "key", filtered.Signatures[0].KeyID)
+	assert.Equal(t, "approved", filtered.Signatures[1].KeyID)
+	assert.Len(t, env.Signatures, 3)
+}
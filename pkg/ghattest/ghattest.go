@@ -0,0 +1,213 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ghattest uploads signed envelopes to GitHub's artifact attestation API, so attestations about a
+// repository's artifacts can be found with gh attestation verify.
+package ghattest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/testifysec/go-witness/dsse"
+)
+
+const (
+	DefaultAPIURL = "https://api.github.com"
+
+	bundleMediaType = "application/vnd.dev.sigstore.bundle+json;version=0.2"
+)
+
+// Bundle is the subset of a Sigstore bundle that carries a DSSE envelope and its verification material
+type Bundle struct {
+	MediaType            string               `json:"mediaType"`
+	VerificationMaterial verificationMaterial `json:"verificationMaterial"`
+	DSSEEnvelope         bundleEnvelope       `json:"dsseEnvelope"`
+}
+
+type verificationMaterial struct {
+	X509CertificateChain      *certificateChain          `json:"x509CertificateChain,omitempty"`
+	PublicKey                 *publicKeyIdentifier       `json:"publicKey,omitempty"`
+	TlogEntries               []json.RawMessage          `json:"tlogEntries"`
+	TimestampVerificationData *timestampVerificationData `json:"timestampVerificationData,omitempty"`
+}
+
+type certificateChain struct {
+	Certificates []rawBytes `json:"certificates"`
+}
+
+type publicKeyIdentifier struct {
+	Hint string `json:"hint"`
+}
+
+type timestampVerificationData struct {
+	RFC3161Timestamps []signedTimestamp `json:"rfc3161Timestamps"`
+}
+
+type signedTimestamp struct {
+	SignedTimestamp []byte `json:"signedTimestamp"`
+}
+
+type rawBytes struct {
+	RawBytes []byte `json:"rawBytes"`
+}
+
+type bundleEnvelope struct {
+	Payload     []byte            `json:"payload"`
+	PayloadType string            `json:"payloadType"`
+	Signatures  []bundleSignature `json:"signatures"`
+}
+
+type bundleSignature struct {
+	Sig   []byte `json:"sig"`
+	KeyID string `json:"keyid"`
+}
+
+// NewBundle wraps a single signature envelope in a Sigstore bundle. Bundles carry the verification material for
+// one signature, so envelopes with multiple signatures can't be uploaded.
+func NewBundle(env dsse.Envelope) (Bundle, error) {
+	if len(env.Signatures) != 1 {
+		return Bundle{}, fmt.Errorf("github attestations require exactly one signature, envelope has %d", len(env.Signatures))
+	}
+
+	sig := env.Signatures[0]
+	bundle := Bundle{
+		MediaType: bundleMediaType,
+		VerificationMaterial: verificationMaterial{
+			TlogEntries: []json.RawMessage{},
+		},
+		DSSEEnvelope: bundleEnvelope{
+			Payload:     env.Payload,
+			PayloadType: env.PayloadType,
+			Signatures:  []bundleSignature{{Sig: sig.Signature, KeyID: sig.KeyID}},
+		},
+	}
+
+	if len(sig.Certificate) > 0 {
+		chain := &certificateChain{}
+		for _, certPem := range append([][]byte{sig.Certificate}, sig.Intermediates...) {
+			block, _ := pem.Decode(certPem)
+			if block == nil {
+				return Bundle{}, fmt.Errorf("failed to decode certificate pem")
+			}
+
+			chain.Certificates = append(chain.Certificates, rawBytes{RawBytes: block.Bytes})
+		}
+
+		bundle.VerificationMaterial.X509CertificateChain = chain
+	} else {
+		bundle.VerificationMaterial.PublicKey = &publicKeyIdentifier{Hint: sig.KeyID}
+	}
+
+	for _, ts := range sig.Timestamps {
+		if ts.Type != dsse.TimestampRFC3161 {
+			continue
+		}
+
+		if bundle.VerificationMaterial.TimestampVerificationData == nil {
+			bundle.VerificationMaterial.TimestampVerificationData = &timestampVerificationData{}
+		}
+
+		tvd := bundle.VerificationMaterial.TimestampVerificationData
+		tvd.RFC3161Timestamps = append(tvd.RFC3161Timestamps, signedTimestamp{SignedTimestamp: ts.Data})
+	}
+
+	return bundle, nil
+}
+
+// RepoURL returns the API URL of a repository given as owner/repo
+func RepoURL(apiURL, repo string) (string, error) {
+	parts := strings.Split(repo, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", fmt.Errorf("invalid repository %v, expected owner/repo", repo)
+	}
+
+	return url.JoinPath(apiURL, "repos", parts[0], parts[1])
+}
+
+type Client struct {
+	token string
+	http  *http.Client
+}
+
+func New(token string) *Client {
+	return &Client{
+		token: token,
+		http:  http.DefaultClient,
+	}
+}
+
+// Upload stores env as an attestation of the repository at repoURL and returns the attestation's id
+func (c *Client) Upload(ctx context.Context, repoURL string, env dsse.Envelope) (string, error) {
+	bundle, err := NewBundle(env)
+	if err != nil {
+		return "", err
+	}
+
+	body, err := json.Marshal(struct {
+		Bundle Bundle `json:"bundle"`
+	}{bundle})
+	if err != nil {
+		return "", err
+	}
+
+	attestationsURL, err := url.JoinPath(repoURL, "attestations")
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, attestationsURL, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("github responded with %v: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+
+	created := struct {
+		ID int64 `json:"id"`
+	}{}
+
+	if err := json.Unmarshal(respBody, &created); err != nil {
+		return "", fmt.Errorf("failed to parse github response: %w", err)
+	}
+
+	return strconv.FormatInt(created.ID, 10), nil
+}
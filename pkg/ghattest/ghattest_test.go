@@ -0,0 +1,68 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ghattest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/dsse"
+)
+
+func TestUpload(t *testing.T) {
+	env := dsse.Envelope{
+		Payload:     []byte(`{"_type":"https://in-toto.io/Statement/v0.1"}`),
+		PayloadType: "application/vnd.in-toto+json",
+		Signatures: []dsse.Signature{{
+			KeyID:      "key",
+			Signature:  []byte("sig"),
+			Timestamps: []dsse.SignatureTimestamp{{Type: dsse.TimestampRFC3161, Data: []byte("token")}},
+		}},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/repos/owner/repo/attestations", r.URL.Path)
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		body := struct {
+			Bundle Bundle `json:"bundle"`
+		}{}
+
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, env.Payload, body.Bundle.DSSEEnvelope.Payload)
+		assert.Equal(t, "key", body.Bundle.VerificationMaterial.PublicKey.Hint)
+		assert.Equal(t, []byte("token"), body.Bundle.VerificationMaterial.TimestampVerificationData.RFC3161Timestamps[0].SignedTimestamp)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": 42}`))
+	}))
+	defer server.Close()
+
+	repoURL, err := RepoURL(server.URL, "owner/repo")
+	require.NoError(t, err)
+	id, err := New("secret").Upload(context.Background(), repoURL, env)
+	require.NoError(t, err)
+	assert.Equal(t, "42", id)
+
+	_, err = RepoURL(server.URL, "repo")
+	assert.Error(t, err)
+
+	env.Signatures = append(env.Signatures, env.Signatures[0])
+	_, err = NewBundle(env)
+	assert.Error(t, err)
+}
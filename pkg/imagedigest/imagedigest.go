@@ -0,0 +1,51 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package imagedigest turns the digests container engines report for an image into witness digest sets.
+package imagedigest
+
+import (
+	"strings"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+)
+
+// Parse parses an OCI digest such as sha256:abc
+func Parse(digest string) (cryptoutil.DigestSet, error) {
+	algorithm, value, _ := strings.Cut(digest, ":")
+	return cryptoutil.NewDigestSet(map[string]string{algorithm: value})
+}
+
+// Materials returns an image as materials, so policies can require it like any other input. Each repo digest,
+// given as name@sha256:digest, is recorded as prefix:name, and the configuration digest id as
+// prefix-config:reference. Digests that can't be parsed are left out.
+func Materials(prefix, reference, id string, repoDigests []string) map[string]cryptoutil.DigestSet {
+	materials := make(map[string]cryptoutil.DigestSet)
+	for _, repoDigest := range repoDigests {
+		name, digest, ok := strings.Cut(repoDigest, "@")
+		if !ok {
+			continue
+		}
+
+		if ds, err := Parse(digest); err == nil {
+			materials[prefix+":"+name] = ds
+		}
+	}
+
+	if ds, err := Parse(id); err == nil {
+		materials[prefix+"-config:"+reference] = ds
+	}
+
+	return materials
+}
@@ -0,0 +1,42 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imagedigest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaterials(t *testing.T) {
+	materials := Materials("image", "alpine:3", "sha256:cfg", []string{"docker.io/library/alpine@sha256:abc", "no-digest", "bad@digest"})
+	require.Len(t, materials, 2)
+
+	expected, err := Parse("sha256:abc")
+	require.NoError(t, err)
+	assert.True(t, materials["image:docker.io/library/alpine"].Equal(expected))
+
+	expected, err = Parse("sha256:cfg")
+	require.NoError(t, err)
+	assert.True(t, materials["image-config:alpine:3"].Equal(expected))
+}
+
+func TestParseRejectsUnknownAlgorithm(t *testing.T) {
+	_, err := Parse("digest")
+	assert.Error(t, err)
+	_, err = Parse("sha256:abc")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,158 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package keywindow enforces the validity windows a policy places on its public keys. A policy may declare
+// that signatures from a key are only trusted for attestations timestamped before a date, so a compromised
+// key can be retired without invalidating every attestation it signed before the compromise.
+package keywindow
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/timestamp"
+)
+
+// policyWindows is the subset of a witness policy that declares key validity windows. notAfter isn't
+// part of the go-witness policy type, so it's read separately from the same policy document.
+type policyWindows struct {
+	TimestampAuthorities map[string]struct {
+		Certificate   []byte   `json:"certificate"`
+		Intermediates [][]byte `json:"intermediates,omitempty"`
+	} `json:"timestampauthorities,omitempty"`
+	PublicKeys map[string]struct {
+		KeyID    string     `json:"keyid"`
+		Key      []byte     `json:"key"`
+		NotAfter *time.Time `json:"notAfter,omitempty"`
+	} `json:"publickeys,omitempty"`
+}
+
+// constrainedKey is a policy key with a validity window
+type constrainedKey struct {
+	keyID    string
+	verifier cryptoutil.Verifier
+	notAfter time.Time
+}
+
+// Windows removes signatures made outside of their key's validity window from envelopes
+type Windows struct {
+	keys     []constrainedKey
+	verifier timestamp.TSPVerifier
+}
+
+// FromPolicy reads the key validity windows from a policy document. A nil Windows is returned if the policy
+// doesn't constrain any keys.
+func FromPolicy(policy []byte) (*Windows, error) {
+	p := policyWindows{}
+	if err := json.Unmarshal(policy, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse policy: %w", err)
+	}
+
+	keys := []constrainedKey{}
+	for _, key := range p.PublicKeys {
+		if key.NotAfter == nil {
+			continue
+		}
+
+		verifier, err := cryptoutil.NewVerifierFromReader(bytes.NewReader(key.Key))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse public key %v: %w", key.KeyID, err)
+		}
+
+		keys = append(keys, constrainedKey{keyID: key.KeyID, verifier: verifier, notAfter: *key.NotAfter})
+	}
+
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	tsaCerts := []*x509.Certificate{}
+	for _, tsa := range p.TimestampAuthorities {
+		for _, certBytes := range append([][]byte{tsa.Certificate}, tsa.Intermediates...) {
+			cert, err := cryptoutil.TryParseCertificate(certBytes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse timestamp authority certificate: %w", err)
+			}
+
+			tsaCerts = append(tsaCerts, cert)
+		}
+	}
+
+	return &Windows{
+		keys:     keys,
+		verifier: timestamp.NewVerifier(timestamp.VerifyWithCerts(tsaCerts)),
+	}, nil
+}
+
+// Filter returns env without the signatures from constrained keys that can't be shown to have been made
+// before the key's notAfter time. A signature is only shown to be in its window by a timestamp from one of
+// the policy's timestamp authorities. Signatures are matched to keys by verifying them, since a signature's
+// keyid isn't signed and can be rewritten. The key ids of the rejected keys are returned.
+func (w *Windows) Filter(ctx context.Context, env dsse.Envelope) (dsse.Envelope, []string) {
+	if w == nil {
+		return env, nil
+	}
+
+	pae := []byte(fmt.Sprintf("DSSEv1 %d %s %d %s", len(env.PayloadType), env.PayloadType, len(env.Payload), env.Payload))
+	rejected := []string{}
+	signatures := make([]dsse.Signature, 0, len(env.Signatures))
+	for _, sig := range env.Signatures {
+		key, ok := w.signedBy(pae, sig)
+		if !ok || w.signedBefore(ctx, sig, key.notAfter) {
+			signatures = append(signatures, sig)
+			continue
+		}
+
+		rejected = append(rejected, key.keyID)
+	}
+
+	env.Signatures = signatures
+	return env, rejected
+}
+
+// signedBy returns the constrained key that made sig, if any
+func (w *Windows) signedBy(pae []byte, sig dsse.Signature) (constrainedKey, bool) {
+	for _, key := range w.keys {
+		if err := key.verifier.Verify(bytes.NewReader(pae), sig.Signature); err == nil {
+			return key, true
+		}
+	}
+
+	return constrainedKey{}, false
+}
+
+func (w *Windows) signedBefore(ctx context.Context, sig dsse.Signature, notAfter time.Time) bool {
+	for _, ts := range sig.Timestamps {
+		if ts.Type != dsse.TimestampRFC3161 {
+			continue
+		}
+
+		signedAt, err := w.verifier.Verify(ctx, bytes.NewReader(ts.Data), bytes.NewReader(sig.Signature))
+		if err != nil {
+			continue
+		}
+
+		if signedAt.Before(notAfter) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,164 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keywindow
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/digitorus/timestamp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+)
+
+// fakeTSA issues RFC 3161 timestamps for a fixed time
+type fakeTSA struct {
+	cert *x509.Certificate
+	key  *rsa.PrivateKey
+	time time.Time
+}
+
+func newFakeTSA(t *testing.T) *fakeTSA {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test tsa"},
+		NotBefore:             time.Now().Add(-24 * time.Hour * 365),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &fakeTSA{cert: cert, key: key}
+}
+
+func (f *fakeTSA) Timestamp(ctx context.Context, r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256(data)
+	ts := timestamp.Timestamp{
+		HashAlgorithm:     crypto.SHA256,
+		HashedMessage:     digest[:],
+		Time:              f.time,
+		Policy:            asn1.ObjectIdentifier{1, 2, 3},
+		AddTSACertificate: true,
+	}
+
+	resp, err := ts.CreateResponse(f.cert, f.key)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := timestamp.ParseResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return parsed.RawToken, nil
+}
+
+func TestFilter(t *testing.T) {
+	compromisedKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	compromisedSigner := cryptoutil.NewRSASigner(compromisedKey, crypto.SHA256)
+	compromisedID, err := compromisedSigner.KeyID()
+	require.NoError(t, err)
+	compromisedVerifier, err := compromisedSigner.Verifier()
+	require.NoError(t, err)
+	compromisedPEM, err := compromisedVerifier.Bytes()
+	require.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	otherSigner := cryptoutil.NewRSASigner(otherKey, crypto.SHA256)
+
+	tsa := newFakeTSA(t)
+	compromisedAt := time.Now().Add(-time.Hour)
+	policy, err := json.Marshal(map[string]interface{}{
+		"timestampauthorities": map[string]interface{}{
+			"tsa": map[string]interface{}{"certificate": pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tsa.cert.Raw})},
+		},
+		"publickeys": map[string]interface{}{
+			compromisedID: map[string]interface{}{"keyid": compromisedID, "key": compromisedPEM, "notAfter": compromisedAt},
+		},
+	})
+	require.NoError(t, err)
+
+	windows, err := FromPolicy(policy)
+	require.NoError(t, err)
+	require.NotNil(t, windows)
+
+	sign := func(signedAt time.Time, signers ...cryptoutil.Signer) dsse.Envelope {
+		tsa.time = signedAt
+		env, err := dsse.Sign("test", bytes.NewReader([]byte("payload")), dsse.SignWithSigners(signers...), dsse.SignWithTimestampers(tsa))
+		require.NoError(t, err)
+		return env
+	}
+
+	before, rejected := windows.Filter(context.Background(), sign(compromisedAt.Add(-time.Hour), compromisedSigner))
+	assert.Empty(t, rejected)
+	assert.Len(t, before.Signatures, 1)
+
+	after, rejected := windows.Filter(context.Background(), sign(compromisedAt.Add(time.Minute), compromisedSigner, otherSigner))
+	assert.Equal(t, []string{compromisedID}, rejected)
+	require.Len(t, after.Signatures, 1)
+	assert.NotEqual(t, compromisedID, after.Signatures[0].KeyID)
+
+	// the keyid isn't signed, so rewriting it mustn't move a signature out of its key's window
+	for _, keyID := range []string{"", "someone-else"} {
+		rewritten := sign(compromisedAt.Add(time.Minute), compromisedSigner)
+		rewritten.Signatures[0].KeyID = keyID
+		filtered, rejected := windows.Filter(context.Background(), rewritten)
+		assert.Equal(t, []string{compromisedID}, rejected)
+		assert.Empty(t, filtered.Signatures)
+	}
+
+	untimestamped, err := dsse.Sign("test", bytes.NewReader([]byte("payload")), dsse.SignWithSigners(compromisedSigner))
+	require.NoError(t, err)
+	filtered, rejected := windows.Filter(context.Background(), untimestamped)
+	assert.Equal(t, []string{compromisedID}, rejected)
+	assert.Empty(t, filtered.Signatures)
+
+	unconstrained, err := FromPolicy([]byte(`{"publickeys": {"a": {"keyid": "a", "key": ""}}}`))
+	require.NoError(t, err)
+	assert.Nil(t, unconstrained)
+
+	_, err = FromPolicy([]byte(`{"publickeys": {"a": {"keyid": "a", "key": "", "notAfter": "2022-01-01T00:00:00Z"}}}`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,307 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package localstore saves signed envelopes on disk by their gitoid, with an index of their subject digests, so
+// attestations can be recorded and verified offline before a remote store such as Archivist is set up.
+package localstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+	"github.com/testifysec/witness/pkg/revocation"
+)
+
+const (
+	envelopesDir   = "envelopes"
+	subjectsDir    = "subjects"
+	envelopeSuffix = ".json"
+	gitoidPrefix   = "gitoid:blob:sha256:"
+	// minPrefixLength is the shortest gitoid prefix Get accepts, like an abbreviated git object ID
+	minPrefixLength = 7
+)
+
+// Entry describes an envelope in the store
+type Entry struct {
+	Gitoid        string           `json:"gitoid"`
+	Name          string           `json:"name,omitempty"`
+	PredicateType string           `json:"predicateType,omitempty"`
+	Subjects      []intoto.Subject `json:"subjects,omitempty"`
+	StoredAt      time.Time        `json:"storedAt"`
+}
+
+// Store is a directory of envelopes addressed by gitoid.
+type Store struct {
+	dir string
+}
+
+// DefaultDir returns the store directory used when none is configured.
+func DefaultDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(homeDir, ".witness", "store"), nil
+}
+
+// New opens the store in dir, creating it if needed.
+func New(dir string) (*Store, error) {
+	for _, sub := range []string{envelopesDir, subjectsDir} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0700); err != nil {
+			return nil, fmt.Errorf("failed to create store directory: %w", err)
+		}
+	}
+
+	return &Store{dir: dir}, nil
+}
+
+// Open opens the store in dir, or in DefaultDir if dir is empty.
+func Open(dir string) (*Store, error) {
+	if dir == "" {
+		var err error
+		if dir, err = DefaultDir(); err != nil {
+			return nil, fmt.Errorf("failed to find default store directory: %w", err)
+		}
+	}
+
+	return New(dir)
+}
+
+// Dir returns the store's directory.
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Put saves the envelope and indexes its subjects, returning its gitoid. Saving an envelope that's already
+// stored does nothing.
+func (s *Store) Put(env dsse.Envelope) (string, error) {
+	gitoid, err := revocation.Gitoid(env)
+	if err != nil {
+		return "", err
+	}
+
+	statement := intoto.Statement{}
+	if err := json.Unmarshal(env.Payload, &statement); err != nil {
+		return "", fmt.Errorf("failed to parse statement: %w", err)
+	}
+
+	// the index is written first so an envelope is never stored without being searchable
+	for _, subject := range statement.Subject {
+		for _, digest := range subject.Digest {
+			if !isHex(digest) {
+				continue
+			}
+
+			indexDir := filepath.Join(s.dir, subjectsDir, digest)
+			if err := os.MkdirAll(indexDir, 0700); err != nil {
+				return "", fmt.Errorf("failed to index subject %v: %w", subject.Name, err)
+			}
+
+			if err := os.WriteFile(filepath.Join(indexDir, gitoid), nil, 0600); err != nil {
+				return "", fmt.Errorf("failed to index subject %v: %w", subject.Name, err)
+			}
+		}
+	}
+
+	path := s.envelopePath(gitoid)
+	if _, err := os.Stat(path); err == nil {
+		return gitoid, nil
+	}
+
+	envBytes, err := json.Marshal(env)
+	if err != nil {
+		return "", err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Join(s.dir, envelopesDir), ".tmp-*")
+	if err != nil {
+		return "", err
+	}
+
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(envBytes); err != nil {
+		tmp.Close()
+		return "", err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	return gitoid, os.Rename(tmp.Name(), path)
+}
+
+// Get returns the envelope with the gitoid. The gitoid may be abbreviated to a unique prefix.
+func (s *Store) Get(gitoid string) (dsse.Envelope, error) {
+	env := dsse.Envelope{}
+	gitoid, err := s.resolve(gitoid)
+	if err != nil {
+		return env, err
+	}
+
+	envBytes, err := os.ReadFile(s.envelopePath(gitoid))
+	if err != nil {
+		return env, err
+	}
+
+	if err := json.Unmarshal(envBytes, &env); err != nil {
+		return env, fmt.Errorf("failed to parse envelope %v: %w", gitoid, err)
+	}
+
+	return env, nil
+}
+
+// Search returns the gitoids of envelopes with a subject that has the digest, sorted.
+func (s *Store) Search(digest string) ([]string, error) {
+	if !isHex(digest) {
+		return nil, nil
+	}
+
+	dirEntries, err := os.ReadDir(filepath.Join(s.dir, subjectsDir, digest))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	gitoids := []string{}
+	for _, dirEntry := range dirEntries {
+		gitoids = append(gitoids, dirEntry.Name())
+	}
+
+	sort.Strings(gitoids)
+	return gitoids, nil
+}
+
+// Entries describes every envelope in the store, oldest first.
+func (s *Store) Entries() ([]Entry, error) {
+	gitoids, err := s.gitoids()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(gitoids))
+	for _, gitoid := range gitoids {
+		info, err := os.Stat(s.envelopePath(gitoid))
+		if err != nil {
+			return nil, err
+		}
+
+		env, err := s.Get(gitoid)
+		if err != nil {
+			return nil, err
+		}
+
+		entry := Entry{Gitoid: gitoid, StoredAt: info.ModTime().UTC()}
+		statement := intoto.Statement{}
+		if err := json.Unmarshal(env.Payload, &statement); err == nil {
+			// collections and the predicates that sign their digests all have the step's name
+			predicate := struct {
+				Name string `json:"name"`
+			}{}
+
+			_ = json.Unmarshal(statement.Predicate, &predicate)
+			entry.Name, entry.PredicateType, entry.Subjects = predicate.Name, statement.PredicateType, statement.Subject
+		}
+
+		entries = append(entries, entry)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].StoredAt.Before(entries[j].StoredAt) })
+	return entries, nil
+}
+
+// resolve returns the full gitoid with the prefix
+func (s *Store) resolve(prefix string) (string, error) {
+	prefix = strings.ToLower(strings.TrimPrefix(prefix, gitoidPrefix))
+	if !isHex(prefix) {
+		return "", fmt.Errorf("%v is not a gitoid", prefix)
+	}
+
+	if _, err := os.Stat(s.envelopePath(prefix)); err == nil {
+		return prefix, nil
+	}
+
+	if len(prefix) < minPrefixLength {
+		return "", fmt.Errorf("gitoid prefix %v must be at least %d characters", prefix, minPrefixLength)
+	}
+
+	gitoids, err := s.gitoids()
+	if err != nil {
+		return "", err
+	}
+
+	matches := []string{}
+	for _, gitoid := range gitoids {
+		if strings.HasPrefix(gitoid, prefix) {
+			matches = append(matches, gitoid)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no envelope with gitoid %v in the store", prefix)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("gitoid prefix %v is ambiguous", prefix)
+	}
+}
+
+func (s *Store) gitoids() ([]string, error) {
+	dirEntries, err := os.ReadDir(filepath.Join(s.dir, envelopesDir))
+	if err != nil {
+		return nil, err
+	}
+
+	gitoids := []string{}
+	for _, dirEntry := range dirEntries {
+		name := dirEntry.Name()
+		if dirEntry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, envelopeSuffix) {
+			continue
+		}
+
+		gitoids = append(gitoids, strings.TrimSuffix(name, envelopeSuffix))
+	}
+
+	sort.Strings(gitoids)
+	return gitoids, nil
+}
+
+func (s *Store) envelopePath(gitoid string) string {
+	return filepath.Join(s.dir, envelopesDir, gitoid+envelopeSuffix)
+}
+
+// isHex reports whether s is a non-empty hex string, so it's safe to use as a file name
+func isHex(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+
+	return true
+}
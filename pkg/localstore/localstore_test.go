@@ -0,0 +1,89 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localstore
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+	"github.com/testifysec/witness/pkg/revocation"
+)
+
+func testEnvelope(t *testing.T, name string, digests ...string) dsse.Envelope {
+	subjects := []intoto.Subject{}
+	for _, digest := range digests {
+		subjects = append(subjects, intoto.Subject{Name: digest, Digest: map[string]string{"sha256": digest}})
+	}
+
+	predicate, err := json.Marshal(map[string]string{"name": name})
+	require.NoError(t, err)
+	statement, err := json.Marshal(intoto.Statement{Type: intoto.StatementType, Subject: subjects, PredicateType: "https://example.com/collection", Predicate: predicate})
+	require.NoError(t, err)
+	return dsse.Envelope{Payload: statement, PayloadType: intoto.PayloadType}
+}
+
+func TestStore(t *testing.T) {
+	s, err := New(t.TempDir())
+	require.NoError(t, err)
+
+	build := testEnvelope(t, "build", "aaaa", "bbbb")
+	test := testEnvelope(t, "test", "bbbb")
+	buildGitoid, err := s.Put(build)
+	require.NoError(t, err)
+	expected, err := revocation.Gitoid(build)
+	require.NoError(t, err)
+	assert.Equal(t, expected, buildGitoid)
+
+	again, err := s.Put(build)
+	require.NoError(t, err)
+	assert.Equal(t, buildGitoid, again)
+
+	testGitoid, err := s.Put(test)
+	require.NoError(t, err)
+
+	gitoids, err := s.Search("aaaa")
+	require.NoError(t, err)
+	assert.Equal(t, []string{buildGitoid}, gitoids)
+	gitoids, err = s.Search("bbbb")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{buildGitoid, testGitoid}, gitoids)
+	gitoids, err = s.Search("cccc")
+	require.NoError(t, err)
+	assert.Empty(t, gitoids)
+	gitoids, err = s.Search("../envelopes")
+	require.NoError(t, err)
+	assert.Empty(t, gitoids)
+
+	env, err := s.Get("gitoid:blob:sha256:" + buildGitoid)
+	require.NoError(t, err)
+	assert.Equal(t, build.Payload, env.Payload)
+	env, err = s.Get(testGitoid[:minPrefixLength])
+	require.NoError(t, err)
+	assert.Equal(t, test.Payload, env.Payload)
+	_, err = s.Get(testGitoid[:3])
+	assert.Error(t, err)
+	_, err = s.Get("../../etc/passwd")
+	assert.Error(t, err)
+
+	entries, err := s.Entries()
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	names := []string{entries[0].Name, entries[1].Name}
+	assert.ElementsMatch(t, []string{"build", "test"}, names)
+}
@@ -0,0 +1,142 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package migrate upgrades attestations recorded with older predicate versions to the
+// versions the current attestors understand, so old attestations remain verifiable
+// after an attestor's schema changes. Migrations only rewrite the parsed predicate;
+// the signed envelope is never modified, so signatures still verify.
+package migrate
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// maxChain bounds how many migrations are applied to a single attestation, protecting against cycles.
+const maxChain = 16
+
+// Func converts an attestation from one predicate version to the next.
+type Func func(attestation json.RawMessage) (json.RawMessage, error)
+
+// Migration upgrades attestations of type From to type To.
+type Migration struct {
+	From    string
+	To      string
+	Migrate Func
+}
+
+var (
+	migrationsMu sync.RWMutex
+	migrations   = make(map[string]Migration)
+)
+
+// Register adds a migration. Registering a second migration from the same type replaces the first.
+func Register(m Migration) {
+	migrationsMu.Lock()
+	defer migrationsMu.Unlock()
+	migrations[m.From] = m
+}
+
+// Unregister removes the migration from the provided type.
+func Unregister(from string) {
+	migrationsMu.Lock()
+	defer migrationsMu.Unlock()
+	delete(migrations, from)
+}
+
+// RenameType returns a migration Func for versions that only changed the attestation's type.
+func RenameType() Func {
+	return func(attestation json.RawMessage) (json.RawMessage, error) {
+		return attestation, nil
+	}
+}
+
+// IsTarget reports whether some older attestation type is migrated to attestationType.
+func IsTarget(attestationType string) bool {
+	migrationsMu.RLock()
+	defer migrationsMu.RUnlock()
+	for _, m := range migrations {
+		if m.To == attestationType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Attestation applies migrations to an attestation until its type has no registered migration,
+// returning the final type and attestation.
+func Attestation(attestationType string, attestation json.RawMessage) (string, json.RawMessage, error) {
+	migrationsMu.RLock()
+	defer migrationsMu.RUnlock()
+	for i := 0; ; i++ {
+		m, ok := migrations[attestationType]
+		if !ok {
+			return attestationType, attestation, nil
+		}
+
+		if i >= maxChain {
+			return "", nil, fmt.Errorf("too many migrations from %v, the migrations may contain a cycle", attestationType)
+		}
+
+		migrated, err := m.Migrate(attestation)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to migrate %v to %v: %w", m.From, m.To, err)
+		}
+
+		attestationType, attestation = m.To, migrated
+	}
+}
+
+// Collection migrates every attestation in a raw attestation collection. The collection is returned
+// unchanged if none of its attestations needed migrating.
+func Collection(collection json.RawMessage) (json.RawMessage, bool, error) {
+	raw := struct {
+		Name         string `json:"name"`
+		Attestations []struct {
+			Type        string          `json:"type"`
+			Attestation json.RawMessage `json:"attestation"`
+		} `json:"attestations"`
+	}{}
+
+	if err := json.Unmarshal(collection, &raw); err != nil {
+		return nil, false, err
+	}
+
+	migrated := false
+	for i, att := range raw.Attestations {
+		newType, newAttestation, err := Attestation(att.Type, att.Attestation)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if newType != att.Type {
+			migrated = true
+			raw.Attestations[i].Type = newType
+			raw.Attestations[i].Attestation = newAttestation
+		}
+	}
+
+	if !migrated {
+		return collection, false, nil
+	}
+
+	out, err := json.Marshal(raw)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return out, true, nil
+}
@@ -0,0 +1,70 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrate
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollection(t *testing.T) {
+	Register(Migration{
+		From: "https://example.com/build/v0.1",
+		To:   "https://example.com/build/v0.2",
+		Migrate: func(attestation json.RawMessage) (json.RawMessage, error) {
+			old := struct {
+				Exit int `json:"exit"`
+			}{}
+
+			if err := json.Unmarshal(attestation, &old); err != nil {
+				return nil, err
+			}
+
+			return json.Marshal(map[string]int{"exitcode": old.Exit})
+		},
+	})
+
+	Register(Migration{From: "https://example.com/build/v0.2", To: "https://example.com/build/v1", Migrate: RenameType()})
+	defer Unregister("https://example.com/build/v0.1")
+	defer Unregister("https://example.com/build/v0.2")
+
+	assert.True(t, IsTarget("https://example.com/build/v1"))
+	assert.False(t, IsTarget("https://example.com/build/v0.1"))
+
+	collection := []byte(`{"name":"build","attestations":[{"type":"https://example.com/build/v0.1","attestation":{"exit":2}},{"type":"https://example.com/other/v0.1","attestation":{}}]}`)
+	migrated, changed, err := Collection(collection)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.JSONEq(t, `{"name":"build","attestations":[{"type":"https://example.com/build/v1","attestation":{"exitcode":2}},{"type":"https://example.com/other/v0.1","attestation":{}}]}`, string(migrated))
+
+	current := []byte(`{"name":"build","attestations":[{"type":"https://example.com/build/v1","attestation":{"exitcode":2}}]}`)
+	unchanged, changed, err := Collection(current)
+	require.NoError(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, current, []byte(unchanged))
+}
+
+func TestCycle(t *testing.T) {
+	Register(Migration{From: "a", To: "b", Migrate: RenameType()})
+	Register(Migration{From: "b", To: "a", Migrate: RenameType()})
+	defer Unregister("a")
+	defer Unregister("b")
+
+	_, _, err := Attestation("a", json.RawMessage(`{}`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,128 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notation
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/testifysec/witness/pkg/cose"
+)
+
+// MediaTypeCOSE is the media type of COSE signature envelopes
+const MediaTypeCOSE = cose.MediaType
+
+// SignCOSE signs target in a COSE_Sign1 envelope with key. As with SignJWS, certs must start with the certificate of
+// key followed by its intermediates.
+func SignCOSE(key crypto.Signer, certs []*x509.Certificate, target Descriptor, signingTime time.Time, agent string) ([]byte, error) {
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("notation signatures require a signing certificate")
+	}
+
+	if _, err := algorithmFor(certs[0].PublicKey); err != nil {
+		return nil, err
+	}
+
+	if !publicKeysEqual(key.Public(), certs[0].PublicKey) {
+		return nil, fmt.Errorf("signing key does not match the signing certificate")
+	}
+
+	payload, err := json.Marshal(Payload{TargetArtifact: target})
+	if err != nil {
+		return nil, err
+	}
+
+	chain := make([]interface{}, 0, len(certs))
+	for _, cert := range certs {
+		chain = append(chain, cert.Raw)
+	}
+
+	protected := cose.Headers{
+		cose.HeaderCritical:    []interface{}{headerSigningScheme},
+		cose.HeaderContentType: PayloadContentType,
+		headerSigningScheme:    SigningSchemeX509,
+		headerSigningTime:      cose.Tag{Number: 1, Content: signingTime.Unix()},
+	}
+
+	unprotected := cose.Headers{cose.HeaderX5Chain: chain}
+	if agent != "" {
+		unprotected[headerSigningAgent] = agent
+	}
+
+	return cose.Sign(key, protected, unprotected, payload)
+}
+
+// ParseCOSE parses a COSE_Sign1 envelope and verifies its signature was made by the leaf certificate of its chain.
+// Whether the certificate is trusted is left to the caller.
+func ParseCOSE(data []byte) (Envelope, error) {
+	msg, err := cose.Parse(data)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("failed to parse envelope: %w", err)
+	}
+
+	if msg.ContentType() != PayloadContentType {
+		return Envelope{}, fmt.Errorf("unexpected payload content type %q", msg.ContentType())
+	}
+
+	if scheme, _ := msg.Protected[headerSigningScheme].(string); scheme != SigningSchemeX509 {
+		return Envelope{}, fmt.Errorf("unsupported signing scheme %q", scheme)
+	}
+
+	signingTime, ok := msg.Protected[headerSigningTime].(cose.Tag)
+	if !ok || signingTime.Number != 1 {
+		return Envelope{}, fmt.Errorf("invalid signing time")
+	}
+
+	seconds, ok := signingTime.Content.(int64)
+	if !ok {
+		return Envelope{}, fmt.Errorf("invalid signing time")
+	}
+
+	certs, err := msg.Certificates()
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	if len(certs) == 0 {
+		return Envelope{}, fmt.Errorf("envelope has no certificate chain")
+	}
+
+	if _, err := algorithmFor(certs[0].PublicKey); err != nil {
+		return Envelope{}, err
+	}
+
+	expected, err := cose.AlgorithmFor(certs[0].PublicKey)
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	if alg, err := msg.Algorithm(); err != nil || alg != expected {
+		return Envelope{}, fmt.Errorf("algorithm does not match the signing certificate")
+	}
+
+	if err := msg.Verify(certs[0].PublicKey); err != nil {
+		return Envelope{}, err
+	}
+
+	payload := Payload{}
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		return Envelope{}, fmt.Errorf("failed to parse payload: %w", err)
+	}
+
+	return Envelope{Target: payload.TargetArtifact, SigningTime: time.Unix(seconds, 0), Certificates: certs}, nil
+}
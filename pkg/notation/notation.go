@@ -0,0 +1,322 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package notation signs and verifies Notary Project (notation) signature envelopes, so organizations standardized on
+// notation rather than Sigstore can sign artifacts with witness keys and require notation signatures in policy.
+package notation
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+const (
+	// MediaTypeJWS is the media type of JWS signature envelopes
+	MediaTypeJWS = "application/jose+json"
+	// ArtifactType is the artifact type of the OCI manifests notation stores signatures in
+	ArtifactType = "application/vnd.cncf.notary.signature"
+	// PayloadContentType is the content type of the payload notation signs
+	PayloadContentType = "application/vnd.cncf.notary.payload.v1+json"
+	// SigningSchemeX509 is the signing scheme where the signing time is asserted by the signer
+	SigningSchemeX509 = "notary.x509"
+
+	headerSigningScheme = "io.cncf.notary.signingScheme"
+	headerSigningTime   = "io.cncf.notary.signingTime"
+	headerSigningAgent  = "io.cncf.notary.signingAgent"
+)
+
+// Descriptor is an OCI content descriptor of the artifact a signature signs
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Payload is what notation signs
+type Payload struct {
+	TargetArtifact Descriptor `json:"targetArtifact"`
+}
+
+// Envelope is a verified signature envelope
+type Envelope struct {
+	Target       Descriptor
+	SigningTime  time.Time
+	Certificates []*x509.Certificate
+}
+
+type protectedHeader struct {
+	Algorithm     string   `json:"alg"`
+	Critical      []string `json:"crit"`
+	ContentType   string   `json:"cty"`
+	SigningScheme string   `json:"io.cncf.notary.signingScheme"`
+	SigningTime   string   `json:"io.cncf.notary.signingTime"`
+}
+
+type unprotectedHeader struct {
+	CertChain    []string `json:"x5c"`
+	SigningAgent string   `json:"io.cncf.notary.signingAgent,omitempty"`
+}
+
+type jwsEnvelope struct {
+	Payload   string            `json:"payload"`
+	Protected string            `json:"protected"`
+	Header    unprotectedHeader `json:"header"`
+	Signature string            `json:"signature"`
+}
+
+// SignJWS signs target in a JWS envelope with key. Notation requires signatures to carry the signer's certificate
+// chain, so certs must start with the certificate of key followed by its intermediates.
+func SignJWS(key crypto.Signer, certs []*x509.Certificate, target Descriptor, signingTime time.Time, agent string) ([]byte, error) {
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("notation signatures require a signing certificate")
+	}
+
+	alg, err := algorithmFor(certs[0].PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if !publicKeysEqual(key.Public(), certs[0].PublicKey) {
+		return nil, fmt.Errorf("signing key does not match the signing certificate")
+	}
+
+	payload, err := json.Marshal(Payload{TargetArtifact: target})
+	if err != nil {
+		return nil, err
+	}
+
+	protected, err := json.Marshal(protectedHeader{
+		Algorithm:     alg.name,
+		Critical:      []string{headerSigningScheme},
+		ContentType:   PayloadContentType,
+		SigningScheme: SigningSchemeX509,
+		SigningTime:   signingTime.UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	env := jwsEnvelope{
+		Payload:   base64.RawURLEncoding.EncodeToString(payload),
+		Protected: base64.RawURLEncoding.EncodeToString(protected),
+		Header:    unprotectedHeader{SigningAgent: agent},
+	}
+
+	sig, err := alg.sign(key, []byte(env.Protected+"."+env.Payload))
+	if err != nil {
+		return nil, err
+	}
+
+	env.Signature = base64.RawURLEncoding.EncodeToString(sig)
+	for _, cert := range certs {
+		env.Header.CertChain = append(env.Header.CertChain, base64.StdEncoding.EncodeToString(cert.Raw))
+	}
+
+	return json.Marshal(env)
+}
+
+// ParseJWS parses a JWS envelope and verifies its signature was made by the leaf certificate of its chain. Whether the
+// certificate is trusted is left to the caller.
+func ParseJWS(data []byte) (Envelope, error) {
+	env := jwsEnvelope{}
+	if err := json.Unmarshal(data, &env); err != nil {
+		return Envelope{}, fmt.Errorf("failed to parse envelope: %w", err)
+	}
+
+	protectedBytes, err := base64.RawURLEncoding.DecodeString(env.Protected)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("failed to decode protected header: %w", err)
+	}
+
+	protected := protectedHeader{}
+	if err := json.Unmarshal(protectedBytes, &protected); err != nil {
+		return Envelope{}, fmt.Errorf("failed to parse protected header: %w", err)
+	}
+
+	if protected.ContentType != PayloadContentType {
+		return Envelope{}, fmt.Errorf("unexpected payload content type %q", protected.ContentType)
+	}
+
+	if protected.SigningScheme != SigningSchemeX509 {
+		return Envelope{}, fmt.Errorf("unsupported signing scheme %q", protected.SigningScheme)
+	}
+
+	signingTime, err := time.Parse(time.RFC3339, protected.SigningTime)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("invalid signing time: %w", err)
+	}
+
+	if len(env.Header.CertChain) == 0 {
+		return Envelope{}, fmt.Errorf("envelope has no certificate chain")
+	}
+
+	certs := make([]*x509.Certificate, 0, len(env.Header.CertChain))
+	for _, encoded := range env.Header.CertChain {
+		der, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return Envelope{}, fmt.Errorf("failed to decode certificate: %w", err)
+		}
+
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return Envelope{}, fmt.Errorf("failed to parse certificate: %w", err)
+		}
+
+		certs = append(certs, cert)
+	}
+
+	alg, err := algorithmFor(certs[0].PublicKey)
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	if alg.name != protected.Algorithm {
+		return Envelope{}, fmt.Errorf("algorithm %v does not match the signing certificate", protected.Algorithm)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(env.Signature)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("failed to decode signature: %w", err)
+	}
+
+	if err := alg.verify(certs[0].PublicKey, []byte(env.Protected+"."+env.Payload), sig); err != nil {
+		return Envelope{}, err
+	}
+
+	payloadBytes, err := base64.RawURLEncoding.DecodeString(env.Payload)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("failed to decode payload: %w", err)
+	}
+
+	payload := Payload{}
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		return Envelope{}, fmt.Errorf("failed to parse payload: %w", err)
+	}
+
+	return Envelope{Target: payload.TargetArtifact, SigningTime: signingTime, Certificates: certs}, nil
+}
+
+// jwsAlgorithm is a signature algorithm notation allows, which is determined by the key type and size
+type jwsAlgorithm struct {
+	name string
+	hash crypto.Hash
+}
+
+// algorithmFor returns the JWS algorithm notation uses for a key
+func algorithmFor(pub crypto.PublicKey) (jwsAlgorithm, error) {
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		switch key.Size() * 8 {
+		case 2048:
+			return jwsAlgorithm{"PS256", crypto.SHA256}, nil
+		case 3072:
+			return jwsAlgorithm{"PS384", crypto.SHA384}, nil
+		case 4096:
+			return jwsAlgorithm{"PS512", crypto.SHA512}, nil
+		}
+	case *ecdsa.PublicKey:
+		switch key.Curve {
+		case elliptic.P256():
+			return jwsAlgorithm{"ES256", crypto.SHA256}, nil
+		case elliptic.P384():
+			return jwsAlgorithm{"ES384", crypto.SHA384}, nil
+		case elliptic.P521():
+			return jwsAlgorithm{"ES512", crypto.SHA512}, nil
+		}
+	}
+
+	return jwsAlgorithm{}, fmt.Errorf("notation signatures require an RSA key of 2048, 3072, or 4096 bits or an ECDSA key on P-256, P-384, or P-521")
+}
+
+func (a jwsAlgorithm) digest(data []byte) []byte {
+	h := a.hash.New()
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func (a jwsAlgorithm) sign(key crypto.Signer, signingInput []byte) ([]byte, error) {
+	digest := a.digest(signingInput)
+	switch pub := key.Public().(type) {
+	case *rsa.PublicKey:
+		return key.Sign(rand.Reader, digest, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: a.hash})
+	case *ecdsa.PublicKey:
+		sig, err := key.Sign(rand.Reader, digest, a.hash)
+		if err != nil {
+			return nil, err
+		}
+
+		return rawECDSASignature(pub, sig)
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", pub)
+	}
+}
+
+func (a jwsAlgorithm) verify(pub crypto.PublicKey, signingInput, sig []byte) error {
+	digest := a.digest(signingInput)
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPSS(key, a.hash, digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto}); err != nil {
+			return fmt.Errorf("signature is invalid: %w", err)
+		}
+
+		return nil
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return fmt.Errorf("signature is invalid")
+		}
+
+		r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return fmt.Errorf("signature is invalid")
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("unsupported public key type %T", pub)
+	}
+}
+
+func publicKeysEqual(a, b crypto.PublicKey) bool {
+	key, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && key.Equal(b)
+}
+
+// rawECDSASignature converts an ASN.1 ECDSA signature to the fixed size r || s form JWS uses
+func rawECDSASignature(key *ecdsa.PublicKey, sig []byte) ([]byte, error) {
+	parsed := struct {
+		R, S *big.Int
+	}{}
+
+	if _, err := asn1.Unmarshal(sig, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse ecdsa signature: %w", err)
+	}
+
+	size := (key.Curve.Params().BitSize + 7) / 8
+	raw := make([]byte, 2*size)
+	parsed.R.FillBytes(raw[:size])
+	parsed.S.FillBytes(raw[size:])
+	return raw, nil
+}
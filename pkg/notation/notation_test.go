@@ -0,0 +1,202 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notation
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/policy"
+)
+
+const testDigest = "sha256:b5bb9d8014a0f9b1d61e21e796d78dccdf1352f23cd32812f4850b878ae4944c"
+
+var testTarget = Descriptor{MediaType: "application/vnd.oci.image.manifest.v1+json", Digest: testDigest, Size: 1024}
+
+// newCertificate creates a certificate for key, issued by parent or self signed if parent is nil
+func newCertificate(t *testing.T, key crypto.Signer, name string, isCA bool, parent *x509.Certificate, parentKey crypto.Signer) *x509.Certificate {
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+func newECDSAKey(t *testing.T, curve elliptic.Curve) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
+	require.NoError(t, err)
+	return key
+}
+
+func TestSignJWSRoundTrip(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	for name, key := range map[string]crypto.Signer{
+		"rsa 2048": rsaKey,
+		"p-256":    newECDSAKey(t, elliptic.P256()),
+		"p-384":    newECDSAKey(t, elliptic.P384()),
+	} {
+		t.Run(name, func(t *testing.T) {
+			cert := newCertificate(t, key, "signer", false, nil, nil)
+			signingTime := time.Now().Truncate(time.Second)
+			envelope, err := SignJWS(key, []*x509.Certificate{cert}, testTarget, signingTime, "witness/test")
+			require.NoError(t, err)
+
+			env, err := ParseJWS(envelope)
+			require.NoError(t, err)
+			assert.Equal(t, testTarget, env.Target)
+			assert.True(t, signingTime.Equal(env.SigningTime))
+			require.Len(t, env.Certificates, 1)
+			assert.Equal(t, cert.Raw, env.Certificates[0].Raw)
+
+			parsed := jwsEnvelope{}
+			require.NoError(t, json.Unmarshal(envelope, &parsed))
+			tampered, err := json.Marshal(Payload{TargetArtifact: Descriptor{MediaType: testTarget.MediaType, Digest: "sha256:0000", Size: 1}})
+			require.NoError(t, err)
+			parsed.Payload = base64.RawURLEncoding.EncodeToString(tampered)
+			tamperedEnvelope, err := json.Marshal(parsed)
+			require.NoError(t, err)
+			_, err = ParseJWS(tamperedEnvelope)
+			assert.ErrorContains(t, err, "signature is invalid")
+		})
+	}
+}
+
+func TestSignCOSERoundTrip(t *testing.T) {
+	key := newECDSAKey(t, elliptic.P256())
+	cert := newCertificate(t, key, "signer", false, nil, nil)
+	signingTime := time.Now().Truncate(time.Second)
+	envelope, err := SignCOSE(key, []*x509.Certificate{cert}, testTarget, signingTime, "witness/test")
+	require.NoError(t, err)
+
+	env, err := ParseCOSE(envelope)
+	require.NoError(t, err)
+	assert.Equal(t, testTarget, env.Target)
+	assert.True(t, signingTime.Equal(env.SigningTime))
+	require.Len(t, env.Certificates, 1)
+	assert.Equal(t, cert.Raw, env.Certificates[0].Raw)
+
+	_, err = ParseCOSE(envelope[:len(envelope)-1])
+	assert.ErrorContains(t, err, "failed to parse envelope")
+
+	_, err = ParseJWS(envelope)
+	assert.Error(t, err)
+}
+
+func TestSignJWSErrors(t *testing.T) {
+	key := newECDSAKey(t, elliptic.P256())
+	cert := newCertificate(t, key, "signer", false, nil, nil)
+	_, err := SignJWS(key, nil, testTarget, time.Now(), "")
+	assert.ErrorContains(t, err, "require a signing certificate")
+
+	_, err = SignJWS(newECDSAKey(t, elliptic.P256()), []*x509.Certificate{cert}, testTarget, time.Now(), "")
+	assert.ErrorContains(t, err, "does not match")
+
+	smallKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	_, err = SignJWS(smallKey, []*x509.Certificate{newCertificate(t, smallKey, "signer", false, nil, nil)}, testTarget, time.Now(), "")
+	assert.ErrorContains(t, err, "notation signatures require")
+}
+
+func TestRequirementCheck(t *testing.T) {
+	rootKey := newECDSAKey(t, elliptic.P256())
+	root := newCertificate(t, rootKey, "root", true, nil, nil)
+	leafKey := newECDSAKey(t, elliptic.P256())
+	leaf := newCertificate(t, leafKey, "release-signer", false, root, rootKey)
+	otherKey := newECDSAKey(t, elliptic.P256())
+	other := newCertificate(t, otherKey, "release-signer", false, nil, nil)
+
+	policyBytes, err := json.Marshal(map[string]interface{}{
+		"roots": map[string]policy.Root{
+			"root": {Certificate: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: root.Raw})},
+		},
+		"notation": map[string]interface{}{
+			"functionaries": []policy.Functionary{{
+				Type:           "root",
+				CertConstraint: policy.CertConstraint{CommonName: "release-signer", Roots: []string{"root"}, DNSNames: []string{"*"}, Emails: []string{"*"}, Organizations: []string{"*"}, URIs: []string{"*"}},
+			}},
+		},
+	})
+	require.NoError(t, err)
+
+	requirement, err := FromPolicy(policyBytes)
+	require.NoError(t, err)
+	require.NotNil(t, requirement)
+
+	sign := func(key crypto.Signer, cert *x509.Certificate, digest string) Signature {
+		target := testTarget
+		target.Digest = digest
+		envelope, err := SignJWS(key, []*x509.Certificate{cert}, target, time.Now(), "")
+		require.NoError(t, err)
+		return Signature{MediaType: MediaTypeJWS, Envelope: envelope}
+	}
+
+	trusted := sign(leafKey, leaf, testDigest)
+	assert.NoError(t, requirement.Check([]Signature{trusted}, testDigest))
+
+	coseEnvelope, err := SignCOSE(leafKey, []*x509.Certificate{leaf}, testTarget, time.Now(), "")
+	require.NoError(t, err)
+	assert.NoError(t, requirement.Check([]Signature{{MediaType: MediaTypeCOSE, Envelope: coseEnvelope}}, testDigest))
+
+	err = requirement.Check([]Signature{sign(otherKey, other, testDigest)}, testDigest)
+	assert.ErrorContains(t, err, "does not belong to a functionary")
+
+	err = requirement.Check([]Signature{sign(leafKey, leaf, "sha256:0000")}, testDigest)
+	assert.ErrorContains(t, err, "signature signs sha256:0000")
+
+	err = requirement.Check([]Signature{{MediaType: "application/pkcs7-signature", Envelope: trusted.Envelope}}, testDigest)
+	assert.ErrorContains(t, err, "unsupported signature envelope")
+
+	assert.EqualError(t, requirement.Check(nil, testDigest), "no notation signatures found")
+}
+
+func TestFromPolicy(t *testing.T) {
+	requirement, err := FromPolicy([]byte(`{"steps": {}}`))
+	require.NoError(t, err)
+	assert.Nil(t, requirement)
+
+	_, err = FromPolicy([]byte(`{"notation": {"functionaries": []}}`))
+	assert.ErrorContains(t, err, "at least one functionary")
+
+	_, err = FromPolicy([]byte(`{"notation": {"functionaries": [{"type": "PublicKey", "publickeyid": "abc"}]}}`))
+	assert.ErrorContains(t, err, "certificate constraints")
+}
@@ -0,0 +1,150 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notation
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/policy"
+	"github.com/testifysec/witness/pkg/trust"
+)
+
+// Signature is a signature envelope notation stored for an artifact
+type Signature struct {
+	MediaType string
+	Envelope  []byte
+}
+
+// policyNotation is the subset of a witness policy that requires notation signatures. notation isn't part of the
+// go-witness policy type, so it's read separately from the same policy document.
+type policyNotation struct {
+	Notation *struct {
+		Functionaries []policy.Functionary `json:"functionaries"`
+	} `json:"notation,omitempty"`
+}
+
+// ErrNoValidSignature is returned when none of an artifact's notation signatures were made by a functionary
+type ErrNoValidSignature struct {
+	Reasons []string
+}
+
+func (e ErrNoValidSignature) Error() string {
+	if len(e.Reasons) == 0 {
+		return "no notation signatures found"
+	}
+
+	return fmt.Sprintf("no valid notation signature from a trusted functionary: %v", strings.Join(e.Reasons, "; "))
+}
+
+// Requirement checks the notation signatures of artifacts against the functionaries a policy trusts to sign them
+type Requirement struct {
+	functionaries []policy.Functionary
+	trusted       trust.Policy
+}
+
+// FromPolicy reads the notation requirement from a policy document. A nil Requirement is returned if the policy
+// doesn't require notation signatures. Notation signatures are always made with certificates, so functionaries must
+// be certificate constraints on the policy's roots.
+func FromPolicy(policyBytes []byte) (*Requirement, error) {
+	p := policyNotation{}
+	if err := json.Unmarshal(policyBytes, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse policy: %w", err)
+	}
+
+	if p.Notation == nil {
+		return nil, nil
+	}
+
+	if len(p.Notation.Functionaries) == 0 {
+		return nil, fmt.Errorf("notation requires at least one functionary")
+	}
+
+	for _, functionary := range p.Notation.Functionaries {
+		if len(functionary.CertConstraint.Roots) == 0 {
+			return nil, fmt.Errorf("notation functionaries must be certificate constraints with roots")
+		}
+	}
+
+	pol := policy.Policy{}
+	if err := json.Unmarshal(policyBytes, &pol); err != nil {
+		return nil, fmt.Errorf("failed to parse policy: %w", err)
+	}
+
+	trusted, err := trust.FromPolicy(pol)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Requirement{
+		functionaries: p.Notation.Functionaries,
+		trusted:       trusted,
+	}, nil
+}
+
+// Check returns an error unless one of the signatures is a valid notation signature of the artifact with digest,
+// made with a certificate that belongs to one of the requirement's functionaries
+func (r *Requirement) Check(signatures []Signature, digest string) error {
+	if r == nil {
+		return nil
+	}
+
+	reasons := []string{}
+	for i, sig := range signatures {
+		if err := r.checkSignature(sig, digest); err != nil {
+			reasons = append(reasons, fmt.Sprintf("signature %d: %v", i, err))
+			continue
+		}
+
+		return nil
+	}
+
+	return ErrNoValidSignature{Reasons: reasons}
+}
+
+func (r *Requirement) checkSignature(sig Signature, digest string) error {
+	var env Envelope
+	var err error
+	switch sig.MediaType {
+	case MediaTypeJWS:
+		env, err = ParseJWS(sig.Envelope)
+	case MediaTypeCOSE:
+		env, err = ParseCOSE(sig.Envelope)
+	default:
+		return fmt.Errorf("unsupported signature envelope %v", sig.MediaType)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if env.Target.Digest != digest {
+		return fmt.Errorf("signature signs %v, not %v", env.Target.Digest, digest)
+	}
+
+	verifier, err := cryptoutil.NewX509Verifier(env.Certificates[0], env.Certificates[1:], nil, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if !r.trusted.IsFunctionary(r.functionaries, verifier) {
+		return fmt.Errorf("certificate %v does not belong to a functionary", env.Certificates[0].Subject)
+	}
+
+	return nil
+}
@@ -0,0 +1,193 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pqsign signs and verifies with the round 3 CRYSTALS-Dilithium post-quantum signature scheme.
+// Signatures aren't compatible with FIPS 204 ML-DSA. Its signers and verifiers satisfy the cryptoutil
+// interfaces, so a Dilithium signature can be added to an envelope alongside a classical one.
+package pqsign
+
+import (
+	"crypto"
+	"crypto/rand"
+	"encoding/pem"
+	"fmt"
+	"io"
+
+	"github.com/cloudflare/circl/sign/dilithium"
+	"github.com/testifysec/go-witness/cryptoutil"
+)
+
+const (
+	PrivateKeyPEMType = "DILITHIUM PRIVATE KEY"
+	PublicKeyPEMType  = "DILITHIUM PUBLIC KEY"
+
+	// DefaultMode is the Dilithium parameter set keys are generated with.
+	DefaultMode = "Dilithium3"
+
+	modeHeader = "Mode"
+)
+
+var (
+	_ cryptoutil.Signer   = &Signer{}
+	_ cryptoutil.Verifier = &Verifier{}
+)
+
+// Modes returns the supported Dilithium parameter sets.
+func Modes() []string {
+	return []string{"Dilithium2", "Dilithium3", "Dilithium5"}
+}
+
+func modeByName(name string) (dilithium.Mode, error) {
+	for _, supported := range Modes() {
+		if name == supported {
+			return dilithium.ModeByName(name), nil
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported dilithium mode %v, expected one of %v", name, Modes())
+}
+
+// GenerateKey creates a key pair for mode and returns the PEM encoded private and public keys.
+func GenerateKey(modeName string) ([]byte, []byte, error) {
+	mode, err := modeByName(modeName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pub, priv, err := mode.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	header := map[string]string{modeHeader: mode.Name()}
+	privPEM := pem.EncodeToMemory(&pem.Block{Type: PrivateKeyPEMType, Headers: header, Bytes: priv.Bytes()})
+	pubPEM := pem.EncodeToMemory(&pem.Block{Type: PublicKeyPEMType, Headers: header, Bytes: pub.Bytes()})
+	return privPEM, pubPEM, nil
+}
+
+func decodePEM(r io.Reader, pemType string, size func(dilithium.Mode) int) (dilithium.Mode, []byte, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != pemType {
+		return nil, nil, fmt.Errorf("expected a %v PEM block", pemType)
+	}
+
+	mode, err := modeByName(block.Headers[modeHeader])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(block.Bytes) != size(mode) {
+		return nil, nil, fmt.Errorf("%v key is %d bytes, expected %d", mode.Name(), len(block.Bytes), size(mode))
+	}
+
+	return mode, block.Bytes, nil
+}
+
+type Signer struct {
+	mode dilithium.Mode
+	priv dilithium.PrivateKey
+}
+
+// LoadSigner reads a PEM encoded private key created by GenerateKey.
+func LoadSigner(r io.Reader) (*Signer, error) {
+	mode, keyBytes, err := decodePEM(r, PrivateKeyPEMType, dilithium.Mode.PrivateKeySize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Signer{mode: mode, priv: mode.PrivateKeyFromBytes(keyBytes)}, nil
+}
+
+func (s *Signer) KeyID() (string, error) {
+	v, err := s.verifier()
+	if err != nil {
+		return "", err
+	}
+
+	return v.KeyID()
+}
+
+func (s *Signer) Sign(r io.Reader) ([]byte, error) {
+	msg, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.mode.Sign(s.priv, msg), nil
+}
+
+func (s *Signer) Verifier() (cryptoutil.Verifier, error) {
+	return s.verifier()
+}
+
+func (s *Signer) verifier() (*Verifier, error) {
+	pub, ok := s.priv.Public().(dilithium.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("failed to get %v public key", s.mode.Name())
+	}
+
+	return &Verifier{mode: s.mode, pub: pub}, nil
+}
+
+type Verifier struct {
+	mode dilithium.Mode
+	pub  dilithium.PublicKey
+}
+
+// LoadVerifier reads a PEM encoded public key created by GenerateKey.
+func LoadVerifier(r io.Reader) (*Verifier, error) {
+	mode, keyBytes, err := decodePEM(r, PublicKeyPEMType, dilithium.Mode.PublicKeySize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Verifier{mode: mode, pub: mode.PublicKeyFromBytes(keyBytes)}, nil
+}
+
+// KeyID is the hex encoded SHA256 digest of the PEM encoded public key, like classical keys.
+func (v *Verifier) KeyID() (string, error) {
+	pemBytes, err := v.Bytes()
+	if err != nil {
+		return "", err
+	}
+
+	digest, err := cryptoutil.DigestBytes(pemBytes, crypto.SHA256)
+	if err != nil {
+		return "", err
+	}
+
+	return string(cryptoutil.HexEncode(digest)), nil
+}
+
+func (v *Verifier) Verify(r io.Reader, sig []byte) error {
+	msg, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	if len(sig) != v.mode.SignatureSize() || !v.mode.Verify(v.pub, msg, sig) {
+		return fmt.Errorf("%v signature verification failed", v.mode.Name())
+	}
+
+	return nil
+}
+
+func (v *Verifier) Bytes() ([]byte, error) {
+	return pem.EncodeToMemory(&pem.Block{Type: PublicKeyPEMType, Headers: map[string]string{modeHeader: v.mode.Name()}, Bytes: v.pub.Bytes()}), nil
+}
@@ -0,0 +1,54 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pqsign
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignVerify(t *testing.T) {
+	privPEM, pubPEM, err := GenerateKey(DefaultMode)
+	require.NoError(t, err)
+
+	signer, err := LoadSigner(bytes.NewReader(privPEM))
+	require.NoError(t, err)
+	verifier, err := LoadVerifier(bytes.NewReader(pubPEM))
+	require.NoError(t, err)
+
+	sig, err := signer.Sign(bytes.NewReader([]byte("payload")))
+	require.NoError(t, err)
+	assert.NoError(t, verifier.Verify(bytes.NewReader([]byte("payload")), sig))
+	assert.Error(t, verifier.Verify(bytes.NewReader([]byte("tampered")), sig))
+	assert.Error(t, verifier.Verify(bytes.NewReader([]byte("payload")), sig[1:]))
+
+	signerKeyID, err := signer.KeyID()
+	require.NoError(t, err)
+	verifierKeyID, err := verifier.KeyID()
+	require.NoError(t, err)
+	assert.Equal(t, verifierKeyID, signerKeyID)
+
+	verifierBytes, err := verifier.Bytes()
+	require.NoError(t, err)
+	assert.Equal(t, pubPEM, verifierBytes)
+
+	_, err = LoadVerifier(bytes.NewReader(privPEM))
+	assert.Error(t, err)
+	_, _, err = GenerateKey("Dilithium4")
+	assert.Error(t, err)
+}
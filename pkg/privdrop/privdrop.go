@@ -0,0 +1,139 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package privdrop runs a command as a less privileged user while the calling process keeps its privileges.
+// Witness can't change the credentials of the command go-witness starts, so it starts itself instead through
+// reexec. The copy drops to the requested user and group, then replaces itself with the command.
+package privdrop
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"runtime"
+	"strconv"
+
+	"github.com/testifysec/witness/pkg/reexec"
+)
+
+// ShimArg is the first argument of a witness process that should drop privileges and run a command
+const ShimArg = "__witness-privdrop"
+
+// Identity is the user and group a command runs as
+type Identity struct {
+	User  string `json:"user"`
+	UID   int    `json:"uid"`
+	Group string `json:"group"`
+	GID   int    `json:"gid"`
+}
+
+// Lookup resolves a user and group given by name or numeric ID. The user's primary group is used if groupName is
+// empty, and the current user if userName is empty.
+func Lookup(userName, groupName string) (Identity, error) {
+	var u *user.User
+	var err error
+	if userName == "" {
+		u, err = user.Current()
+	} else {
+		u, err = lookupUser(userName)
+	}
+
+	if err != nil {
+		return Identity{}, fmt.Errorf("failed to find user %v: %w", userName, err)
+	}
+
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return Identity{}, fmt.Errorf("user %v doesn't have a numeric id", u.Username)
+	}
+
+	gidStr := u.Gid
+	if groupName != "" {
+		g, err := lookupGroup(groupName)
+		if err != nil {
+			return Identity{}, fmt.Errorf("failed to find group %v: %w", groupName, err)
+		}
+
+		gidStr = g.Gid
+	}
+
+	gid, err := strconv.Atoi(gidStr)
+	if err != nil {
+		return Identity{}, fmt.Errorf("group %v doesn't have a numeric id", gidStr)
+	}
+
+	id := Identity{User: u.Username, UID: uid, GID: gid}
+	if g, err := user.LookupGroupId(gidStr); err == nil {
+		id.Group = g.Name
+	}
+
+	return id, nil
+}
+
+func lookupUser(name string) (*user.User, error) {
+	if _, err := strconv.Atoi(name); err == nil {
+		return user.LookupId(name)
+	}
+
+	return user.Lookup(name)
+}
+
+func lookupGroup(name string) (*user.Group, error) {
+	if _, err := strconv.Atoi(name); err == nil {
+		return user.LookupGroupId(name)
+	}
+
+	return user.LookupGroup(name)
+}
+
+// Command returns the arguments that run args as id by running the current executable as a shim.
+func Command(id Identity, args []string) ([]string, error) {
+	if runtime.GOOS != "linux" {
+		return nil, fmt.Errorf("running a command as another user is only supported on linux")
+	}
+
+	if len(args) == 0 {
+		return nil, fmt.Errorf("a command is required to run as another user")
+	}
+
+	return reexec.Command(ShimArg, []string{strconv.Itoa(id.UID), strconv.Itoa(id.GID)}, args)
+}
+
+// Init hands the process to the privilege dropping shim if Command started it. The shim never comes back to witness:
+// it becomes the command, or exits with status 126 if the credentials can't be changed. Nothing in witness may run
+// as the requested user before that, so Init has to be called before main does anything else.
+func Init() {
+	reexec.Init(ShimArg, []string{"uid", "gid"}, shim)
+}
+
+// shim drops to the uid and gid in params and replaces the process with command. It only returns if that fails.
+func shim(params, command []string) (int, error) {
+	uid, err := strconv.Atoi(params[0])
+	if err != nil {
+		return 0, fmt.Errorf("invalid uid %v", params[0])
+	}
+
+	gid, err := strconv.Atoi(params[1])
+	if err != nil {
+		return 0, fmt.Errorf("invalid gid %v", params[1])
+	}
+
+	if u, err := user.LookupId(params[0]); err == nil {
+		os.Setenv("HOME", u.HomeDir)
+		os.Setenv("USER", u.Username)
+		os.Setenv("LOGNAME", u.Username)
+	}
+
+	return 0, execAs(uid, gid, command)
+}
@@ -0,0 +1,44 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privdrop
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// execAs drops to uid and gid, clearing supplementary groups, and replaces the process with args
+func execAs(uid, gid int, args []string) error {
+	path, err := exec.LookPath(args[0])
+	if err != nil {
+		return err
+	}
+
+	if err := syscall.Setgroups([]int{gid}); err != nil {
+		return fmt.Errorf("failed to set groups: %w", err)
+	}
+
+	if err := syscall.Setgid(gid); err != nil {
+		return fmt.Errorf("failed to set group to %v: %w", gid, err)
+	}
+
+	if err := syscall.Setuid(uid); err != nil {
+		return fmt.Errorf("failed to set user to %v: %w", uid, err)
+	}
+
+	return syscall.Exec(path, args, os.Environ())
+}
@@ -0,0 +1,60 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privdrop
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	Init()
+	os.Exit(m.Run())
+}
+
+func TestLookup(t *testing.T) {
+	id, err := Lookup("0", "")
+	require.NoError(t, err)
+	assert.Equal(t, 0, id.UID)
+	assert.Equal(t, "root", id.User)
+
+	_, err = Lookup("witness-no-such-user", "")
+	require.Error(t, err)
+}
+
+func TestCommand(t *testing.T) {
+	if runtime.GOOS != "linux" || os.Geteuid() != 0 {
+		t.Skip("dropping privileges requires root on linux")
+	}
+
+	id, err := Lookup("65534", "65534")
+	require.NoError(t, err)
+
+	args, err := Command(id, []string{"sh", "-c", "id -u; id -g"})
+	require.NoError(t, err)
+
+	out, err := exec.Command(args[0], args[1:]...).Output()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"65534", "65534"}, strings.Fields(string(out)))
+
+	_, err = Command(id, nil)
+	require.Error(t, err)
+}
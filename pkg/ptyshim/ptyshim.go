@@ -0,0 +1,50 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ptyshim runs a command under a pseudo-terminal, so tools that check whether their output is a terminal
+// print colors and progress bars, or run at all. Witness can't give the command go-witness starts a terminal, so it
+// starts itself instead through reexec. The copy opens a pseudo-terminal, runs the command on it, and copies
+// what the command writes to its own stdout, where go-witness records it.
+package ptyshim
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/testifysec/witness/pkg/reexec"
+)
+
+// ShimArg is the first argument of a witness process that should run a command under a pseudo-terminal
+const ShimArg = "__witness-pty"
+
+// Command returns the arguments that run args under a pseudo-terminal by running the current executable as a shim
+func Command(args []string) ([]string, error) {
+	if runtime.GOOS != "linux" {
+		return nil, fmt.Errorf("running a command under a pseudo-terminal is only supported on linux")
+	}
+
+	if len(args) == 0 {
+		return nil, fmt.Errorf("a command is required to run under a pseudo-terminal")
+	}
+
+	return reexec.Command(ShimArg, nil, args)
+}
+
+// Init hands the process to the pseudo-terminal shim if Command started it. The shim exits with the command's exit
+// status once its output is copied, or 126 if no pseudo-terminal can be opened for it.
+func Init() {
+	reexec.Init(ShimArg, nil, func(_, command []string) (int, error) {
+		return run(command)
+	})
+}
@@ -0,0 +1,206 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package ptyshim
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+)
+
+// drainTimeout is how long output is still copied after the command exits, in case processes it left running
+// keep the terminal open
+const drainTimeout = time.Second
+
+// defaultSize is the size of the terminal when witness doesn't run in one
+var defaultSize = unix.Winsize{Row: 24, Col: 80}
+
+func run(args []string) (int, error) {
+	path, err := exec.LookPath(args[0])
+	if err != nil {
+		return 0, err
+	}
+
+	master, masterFd, slave, err := open()
+	if err != nil {
+		return 0, err
+	}
+
+	defer master.Close()
+	// witness' terminal, if it runs in one. go-witness starts the shim with stdin from /dev/null, so input is read
+	// from the terminal directly.
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil || !term.IsTerminal(int(tty.Fd())) {
+		tty = nil
+	}
+
+	if err := setup(slave, tty); err != nil {
+		slave.Close()
+		return 0, err
+	}
+
+	eof := eofChar(slave)
+
+	cmd := exec.Command(path, args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = slave, slave, slave
+	// the command leads a new session with the pseudo-terminal as its controlling terminal, so it gets the
+	// terminal's job control signals
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true, Ctty: 0}
+	if err := cmd.Start(); err != nil {
+		slave.Close()
+		return 0, err
+	}
+
+	slave.Close()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGWINCH)
+	defer signal.Stop(signals)
+	go func() {
+		for sig := range signals {
+			if sig == syscall.SIGWINCH {
+				resize(masterFd, tty)
+				continue
+			}
+
+			syscall.Kill(-cmd.Process.Pid, sig.(syscall.Signal))
+		}
+	}()
+
+	if tty != nil {
+		state, err := term.MakeRaw(int(tty.Fd()))
+		if err == nil {
+			defer term.Restore(int(tty.Fd()), state)
+		}
+
+		go io.Copy(master, tty)
+	} else {
+		// the command's input is at its end, like /dev/null is without a terminal
+		master.Write([]byte{eof})
+	}
+
+	copied := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(os.Stdout, master)
+		copied <- err
+	}()
+
+	waitErr := cmd.Wait()
+	master.SetReadDeadline(time.Now().Add(drainTimeout))
+	// reads fail with EIO once the command and everything it started have closed the terminal
+	if err := <-copied; err != nil && !errors.Is(err, syscall.EIO) && !errors.Is(err, os.ErrDeadlineExceeded) {
+		return 0, fmt.Errorf("failed to copy command output: %w", err)
+	}
+
+	exitErr := &exec.ExitError{}
+	if waitErr != nil && !errors.As(waitErr, &exitErr) {
+		return 0, waitErr
+	}
+
+	if status, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal()), nil
+	}
+
+	return cmd.ProcessState.ExitCode(), nil
+}
+
+// open opens a new pseudo-terminal, returning its master and slave. The master is opened non-blocking and never
+// made blocking by calling Fd, so reading it can time out.
+func open() (*os.File, int, *os.File, error) {
+	fd, err := unix.Open("/dev/ptmx", unix.O_RDWR|unix.O_NOCTTY|unix.O_CLOEXEC|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("failed to open pseudo-terminal: %w", err)
+	}
+
+	master := os.NewFile(uintptr(fd), "/dev/ptmx")
+	if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		master.Close()
+		return nil, 0, nil, fmt.Errorf("failed to unlock pseudo-terminal: %w", err)
+	}
+
+	n, err := unix.IoctlGetInt(fd, unix.TIOCGPTN)
+	if err != nil {
+		master.Close()
+		return nil, 0, nil, fmt.Errorf("failed to find pseudo-terminal: %w", err)
+	}
+
+	slave, err := os.OpenFile("/dev/pts/"+strconv.Itoa(n), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, 0, nil, fmt.Errorf("failed to open pseudo-terminal: %w", err)
+	}
+
+	return master, fd, slave, nil
+}
+
+// setup sizes the pseudo-terminal like tty. Without a terminal, input isn't echoed, since there's none but the end
+// of input.
+func setup(slave, tty *os.File) error {
+	if err := unix.IoctlSetWinsize(int(slave.Fd()), unix.TIOCSWINSZ, size(tty)); err != nil {
+		return fmt.Errorf("failed to size pseudo-terminal: %w", err)
+	}
+
+	if tty != nil {
+		return nil
+	}
+
+	termios, err := unix.IoctlGetTermios(int(slave.Fd()), unix.TCGETS)
+	if err != nil {
+		return fmt.Errorf("failed to configure pseudo-terminal: %w", err)
+	}
+
+	termios.Lflag &^= unix.ECHO
+	if err := unix.IoctlSetTermios(int(slave.Fd()), unix.TCSETS, termios); err != nil {
+		return fmt.Errorf("failed to configure pseudo-terminal: %w", err)
+	}
+
+	return nil
+}
+
+func resize(masterFd int, tty *os.File) {
+	if tty != nil {
+		unix.IoctlSetWinsize(masterFd, unix.TIOCSWINSZ, size(tty))
+	}
+}
+
+func size(tty *os.File) *unix.Winsize {
+	if tty != nil {
+		if ws, err := unix.IoctlGetWinsize(int(tty.Fd()), unix.TIOCGWINSZ); err == nil && ws.Col > 0 && ws.Row > 0 {
+			return ws
+		}
+	}
+
+	ws := defaultSize
+	return &ws
+}
+
+// eofChar returns the character that ends input on the terminal of slave
+func eofChar(slave *os.File) byte {
+	if termios, err := unix.IoctlGetTermios(int(slave.Fd()), unix.TCGETS); err == nil {
+		return termios.Cc[unix.VEOF]
+	}
+
+	return 4
+}
@@ -0,0 +1,69 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package ptyshim
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	Init()
+	os.Exit(m.Run())
+}
+
+// shimCommand runs args under the shim in a new session, so it has no terminal to forward input from
+func shimCommand(t *testing.T, args ...string) *exec.Cmd {
+	shimArgs, err := Command(args)
+	require.NoError(t, err)
+	cmd := exec.Command(shimArgs[0], shimArgs[1:]...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	return cmd
+}
+
+func TestCommand(t *testing.T) {
+	if _, err := os.Stat("/dev/ptmx"); err != nil {
+		t.Skip("pseudo-terminals aren't available")
+	}
+
+	out, err := shimCommand(t, "sh", "-c", "test -t 0 && test -t 1 && test -t 2 && echo terminal; stty size; echo error >&2; exit 3").Output()
+	exitErr := &exec.ExitError{}
+	require.True(t, errors.As(err, &exitErr), "unexpected error %v", err)
+	assert.Equal(t, 3, exitErr.ExitCode())
+	assert.Equal(t, "terminal\r\n24 80\r\nerror\r\n", string(out))
+
+	// input ends immediately without a terminal to forward it from, and isn't echoed
+	out, err = shimCommand(t, "sh", "-c", "cat; echo done").Output()
+	require.NoError(t, err)
+	assert.Equal(t, "done", strings.TrimSpace(string(out)))
+
+	out, err = shimCommand(t, "sh", "-c", "kill -TERM $$").Output()
+	require.True(t, errors.As(err, &exitErr), "unexpected error %v", err)
+	assert.Equal(t, 128+int(syscall.SIGTERM), exitErr.ExitCode())
+	assert.Empty(t, out)
+
+	_, err = shimCommand(t, "witness-no-such-command").Output()
+	require.True(t, errors.As(err, &exitErr), "unexpected error %v", err)
+	assert.Equal(t, 126, exitErr.ExitCode())
+}
@@ -0,0 +1,231 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package purl builds and parses package-url (purl) identifiers, which name packages by their ecosystem
+// coordinates, such as pkg:npm/%40scope/name@1.0.0. See https://github.com/package-url/purl-spec.
+package purl
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const scheme = "pkg:"
+
+type PURL struct {
+	Type       string
+	Namespace  string
+	Name       string
+	Version    string
+	Qualifiers map[string]string
+	Subpath    string
+}
+
+// String formats the purl in its canonical form, with qualifiers sorted by key
+func (p PURL) String() string {
+	sb := strings.Builder{}
+	sb.WriteString(scheme)
+	sb.WriteString(strings.ToLower(p.Type))
+	sb.WriteString("/")
+	if p.Namespace != "" {
+		segments := strings.Split(p.Namespace, "/")
+		for i, segment := range segments {
+			segments[i] = escape(segment)
+		}
+
+		sb.WriteString(strings.Join(segments, "/"))
+		sb.WriteString("/")
+	}
+
+	sb.WriteString(escape(p.Name))
+	if p.Version != "" {
+		sb.WriteString("@")
+		sb.WriteString(escape(p.Version))
+	}
+
+	if len(p.Qualifiers) > 0 {
+		keys := make([]string, 0, len(p.Qualifiers))
+		for key := range p.Qualifiers {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+		qualifiers := make([]string, 0, len(keys))
+		for _, key := range keys {
+			qualifiers = append(qualifiers, fmt.Sprintf("%v=%v", strings.ToLower(key), escape(p.Qualifiers[key])))
+		}
+
+		sb.WriteString("?")
+		sb.WriteString(strings.Join(qualifiers, "&"))
+	}
+
+	if p.Subpath != "" {
+		sb.WriteString("#")
+		sb.WriteString(p.Subpath)
+	}
+
+	return sb.String()
+}
+
+// Parse reads a purl, unescaping each of its components
+func Parse(s string) (PURL, error) {
+	if !strings.HasPrefix(s, scheme) {
+		return PURL{}, fmt.Errorf("purl %v must start with %v", s, scheme)
+	}
+
+	p := PURL{}
+	rest := strings.TrimLeft(strings.TrimPrefix(s, scheme), "/")
+	if i := strings.Index(rest, "#"); i >= 0 {
+		p.Subpath = strings.Trim(rest[i+1:], "/")
+		rest = rest[:i]
+	}
+
+	if i := strings.Index(rest, "?"); i >= 0 {
+		p.Qualifiers = make(map[string]string)
+		for _, pair := range strings.Split(rest[i+1:], "&") {
+			key, value, ok := strings.Cut(pair, "=")
+			if !ok || key == "" {
+				return PURL{}, fmt.Errorf("invalid qualifier %v in purl %v", pair, s)
+			}
+
+			unescaped, err := url.PathUnescape(value)
+			if err != nil {
+				return PURL{}, fmt.Errorf("invalid qualifier %v in purl %v: %w", pair, s, err)
+			}
+
+			p.Qualifiers[strings.ToLower(key)] = unescaped
+		}
+
+		rest = rest[:i]
+	}
+
+	typ, rest, ok := strings.Cut(rest, "/")
+	if !ok || typ == "" {
+		return PURL{}, fmt.Errorf("purl %v is missing a type", s)
+	}
+
+	p.Type = strings.ToLower(typ)
+	if i := strings.LastIndex(rest, "@"); i >= 0 {
+		version, err := url.PathUnescape(rest[i+1:])
+		if err != nil {
+			return PURL{}, fmt.Errorf("invalid version in purl %v: %w", s, err)
+		}
+
+		p.Version = version
+		rest = rest[:i]
+	}
+
+	segments := strings.Split(strings.Trim(rest, "/"), "/")
+	for i, segment := range segments {
+		unescaped, err := url.PathUnescape(segment)
+		if err != nil {
+			return PURL{}, fmt.Errorf("invalid purl %v: %w", s, err)
+		}
+
+		segments[i] = unescaped
+	}
+
+	p.Name = segments[len(segments)-1]
+	p.Namespace = strings.Join(segments[:len(segments)-1], "/")
+	if p.Name == "" {
+		return PURL{}, fmt.Errorf("purl %v is missing a name", s)
+	}
+
+	return p, nil
+}
+
+// Matches reports whether candidate identifies the package described by p. Components p leaves empty, such as the
+// version, match any value, and only the qualifiers p specifies are compared.
+func (p PURL) Matches(candidate PURL) bool {
+	if !strings.EqualFold(p.Type, candidate.Type) {
+		return false
+	}
+
+	if normalizeName(p.Type, p.Namespace) != normalizeName(candidate.Type, candidate.Namespace) ||
+		normalizeName(p.Type, p.Name) != normalizeName(candidate.Type, candidate.Name) {
+		return false
+	}
+
+	if p.Version != "" && p.Version != candidate.Version {
+		return false
+	}
+
+	if p.Subpath != "" && p.Subpath != candidate.Subpath {
+		return false
+	}
+
+	for key, value := range p.Qualifiers {
+		if candidate.Qualifiers[key] != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// normalizeName applies the name normalization rules the purl spec defines for some package types
+func normalizeName(typ, name string) string {
+	switch strings.ToLower(typ) {
+	case "pypi":
+		return NormalizePythonName(name)
+	case "npm", "github", "bitbucket", "golang":
+		return strings.ToLower(name)
+	default:
+		return name
+	}
+}
+
+// NPM returns the purl of an npm package. Scoped package names include their scope, such as @scope/name.
+func NPM(name, version string) PURL {
+	namespace := ""
+	if strings.HasPrefix(name, "@") {
+		if scope, unscoped, ok := strings.Cut(name, "/"); ok {
+			namespace, name = scope, unscoped
+		}
+	}
+
+	return PURL{Type: "npm", Namespace: namespace, Name: name, Version: version}
+}
+
+// PyPI returns the purl of a python package, normalizing its name as PyPI does
+func PyPI(name, version string) PURL {
+	return PURL{Type: "pypi", Name: NormalizePythonName(name), Version: version}
+}
+
+// NormalizePythonName lowercases a python package name and replaces runs of -, _, and . with a single -
+func NormalizePythonName(name string) string {
+	sb := strings.Builder{}
+	separator := false
+	for _, r := range strings.ToLower(name) {
+		if r == '-' || r == '_' || r == '.' {
+			separator = true
+			continue
+		}
+
+		if separator && sb.Len() > 0 {
+			sb.WriteRune('-')
+		}
+
+		separator = false
+		sb.WriteRune(r)
+	}
+
+	return sb.String()
+}
+
+func escape(s string) string {
+	return strings.NewReplacer("+", "%2B", "@", "%40").Replace(url.PathEscape(s))
+}
@@ -0,0 +1,69 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package purl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestString(t *testing.T) {
+	assert.Equal(t, "pkg:npm/%40testifysec/witness@1.0.0", NPM("@testifysec/witness", "1.0.0").String())
+	assert.Equal(t, "pkg:npm/left-pad@1.3.0", NPM("left-pad", "1.3.0").String())
+	assert.Equal(t, "pkg:pypi/zope-interface@5.4.0", PyPI("Zope.Interface", "5.4.0").String())
+	assert.Equal(t, "pkg:pypi/django@1.11.1%2Bpatched?file_name=django-1.11.1.tar.gz",
+		PURL{Type: "pypi", Name: "django", Version: "1.11.1+patched", Qualifiers: map[string]string{"file_name": "django-1.11.1.tar.gz"}}.String())
+}
+
+func TestParse(t *testing.T) {
+	for _, s := range []string{
+		"pkg:npm/%40testifysec/witness@1.0.0",
+		"pkg:pypi/django@1.11.1%2Bpatched?file_name=django-1.11.1.tar.gz",
+		"pkg:maven/org.apache.commons/io@2.6#src/main",
+		"pkg:golang/github.com/testifysec/witness",
+	} {
+		p, err := Parse(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, s, p.String())
+	}
+
+	p, err := Parse("pkg:npm/%40testifysec/witness@1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, PURL{Type: "npm", Namespace: "@testifysec", Name: "witness", Version: "1.0.0"}, p)
+
+	for _, s := range []string{"npm/witness", "pkg:witness", "pkg:npm/", "pkg:npm/a?b"} {
+		_, err := Parse(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	candidate, err := Parse("pkg:pypi/zope-interface@5.4.0?file_name=zope_interface-5.4.0-py3-none-any.whl")
+	require.NoError(t, err)
+	for pattern, expected := range map[string]bool{
+		"pkg:pypi/Zope.Interface":             true,
+		"pkg:pypi/zope-interface@5.4.0":       true,
+		"pkg:pypi/zope-interface@5.4.1":       false,
+		"pkg:npm/zope-interface":              false,
+		"pkg:pypi/zope-interface?file_name=x": false,
+		"pkg:pypi/zope-interface?file_name=zope_interface-5.4.0-py3-none-any.whl": true,
+	} {
+		p, err := Parse(pattern)
+		require.NoError(t, err, pattern)
+		assert.Equal(t, expected, p.Matches(candidate), pattern)
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package reexec is the handshake witness uses to run a command through a copy of itself, for the changes to a
+// command's process that go-witness has no option for. Command builds the arguments that start the witness
+// executable as a named shim, followed by the shim's parameters, "--", and the command. Init, called by each shim
+// package at the start of main before any flags are parsed, recognizes those arguments and runs the shim instead
+// of witness.
+package reexec
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Command returns the arguments that start the current executable as the shim named arg, to run args with the
+// shim's params
+func Command(arg string, params, args []string) ([]string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the witness executable: %w", err)
+	}
+
+	command := append([]string{exe, arg}, params...)
+	command = append(command, "--")
+	return append(command, args...), nil
+}
+
+// Init returns unless the process was started by Command as the shim named arg. Otherwise it calls run with the
+// shim's parameters and the command, and exits with the status run returns, or with 126 if run fails or the
+// arguments don't match params, the names of the parameters the shim takes.
+func Init(arg string, params []string, run func(params, command []string) (int, error)) {
+	if len(os.Args) < 2 || os.Args[1] != arg {
+		return
+	}
+
+	args := os.Args[2:]
+	if len(args) < len(params)+2 || args[len(params)] != "--" {
+		usage := append(append([]string{arg}, params...), "--", "command")
+		fmt.Fprintf(os.Stderr, "witness: usage: %v\n", strings.Join(usage, " "))
+		os.Exit(126)
+	}
+
+	status, err := run(args[:len(params)], args[len(params)+1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "witness: %v\n", err)
+		os.Exit(126)
+	}
+
+	os.Exit(status)
+}
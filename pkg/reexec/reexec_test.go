@@ -0,0 +1,66 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reexec
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testShimArg = "__witness-reexec-test"
+
+func TestMain(m *testing.M) {
+	Init(testShimArg, []string{"status"}, func(params, command []string) (int, error) {
+		if command[0] == "fail" {
+			return 0, errors.New("failed")
+		}
+
+		fmt.Print(strings.Join(command, " "))
+		var status int
+		_, err := fmt.Sscan(params[0], &status)
+		return status, err
+	})
+
+	os.Exit(m.Run())
+}
+
+func TestInit(t *testing.T) {
+	args, err := Command(testShimArg, []string{"3"}, []string{"echo", "--", "hello"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{testShimArg, "3", "--", "echo", "--", "hello"}, args[1:])
+
+	out, err := exec.Command(args[0], args[1:]...).Output()
+	exitErr := &exec.ExitError{}
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 3, exitErr.ExitCode())
+	assert.Equal(t, "echo -- hello", string(out))
+
+	args, err = Command(testShimArg, []string{"0"}, []string{"fail"})
+	require.NoError(t, err)
+	err = exec.Command(args[0], args[1:]...).Run()
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 126, exitErr.ExitCode())
+
+	err = exec.Command(args[0], testShimArg, "echo").Run()
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 126, exitErr.ExitCode())
+}
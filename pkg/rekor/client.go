@@ -0,0 +1,280 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rekor
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/testifysec/go-witness/dsse"
+)
+
+const (
+	entriesPath   = "/api/v1/log/entries"
+	retrievePath  = "/api/v1/index/retrieve"
+	intotoKind    = "intoto"
+	intotoVersion = "0.0.1"
+)
+
+// Client uploads signed envelopes to a Rekor transparency log over its REST API, and finds the entries of envelopes
+// in it. Entries are only trusted if they were signed by the log's key.
+type Client struct {
+	url    string
+	logKey crypto.PublicKey
+	http   *http.Client
+}
+
+type Option func(*Client)
+
+// WithHTTPClient sets the client requests to the log are sent with
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) {
+		c.http = client
+	}
+}
+
+// New creates a client for the log at serverURL whose entries are signed by logKey
+func New(serverURL string, logKey crypto.PublicKey, opts ...Option) *Client {
+	c := &Client{
+		url:    strings.TrimSuffix(serverURL, "/"),
+		logKey: logKey,
+		http:   http.DefaultClient,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Entry is an entry in the log
+type Entry struct {
+	UUID           string `json:"-"`
+	LogIndex       int64  `json:"logIndex"`
+	LogID          string `json:"logID"`
+	IntegratedTime int64  `json:"integratedTime"`
+	Body           string `json:"body"`
+	Verification   struct {
+		SignedEntryTimestamp string          `json:"signedEntryTimestamp"`
+		InclusionProof       *InclusionProof `json:"inclusionProof,omitempty"`
+	} `json:"verification"`
+}
+
+type intotoEntry struct {
+	APIVersion string     `json:"apiVersion"`
+	Kind       string     `json:"kind"`
+	Spec       intotoSpec `json:"spec"`
+}
+
+type intotoSpec struct {
+	Content struct {
+		Envelope string `json:"envelope"`
+	} `json:"content"`
+	// PublicKey is the PEM key or certificate the envelope is verified with. It's base64 encoded when marshaled.
+	PublicKey []byte `json:"publicKey"`
+}
+
+// Upload adds env to the log as an intoto entry along with publicKey, the PEM public key or certificate that signed
+// it, which binds the key to the envelope for anyone who trusts the log. Envelopes the log already holds return their
+// existing entry. The entry is only returned once it's verified to be signed by the log and to record what was
+// submitted.
+func (c *Client) Upload(ctx context.Context, env dsse.Envelope, publicKey []byte) (Entry, error) {
+	envBytes, err := json.Marshal(env)
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to marshal envelope: %w", err)
+	}
+
+	proposed := intotoEntry{APIVersion: intotoVersion, Kind: intotoKind}
+	proposed.Spec.Content.Envelope = string(envBytes)
+	proposed.Spec.PublicKey = publicKey
+	reqBody, err := json.Marshal(proposed)
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to marshal entry: %w", err)
+	}
+
+	resp, body, err := c.do(ctx, http.MethodPost, c.url+entriesPath, reqBody)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	var entry Entry
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		if entry, err = parseEntry(body); err != nil {
+			return Entry{}, err
+		}
+
+		if err := entry.verify(c.logKey); err != nil {
+			return Entry{}, err
+		}
+	case http.StatusConflict:
+		// the log answers with the location of the entry it already holds
+		location := resp.Header.Get("Location")
+		if location == "" {
+			return Entry{}, fmt.Errorf("rekor reported a conflicting entry without its location")
+		}
+
+		if entry, err = c.Get(ctx, location[strings.LastIndex(location, "/")+1:]); err != nil {
+			return Entry{}, err
+		}
+	default:
+		return Entry{}, fmt.Errorf("rekor responded with %v: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	logged, err := entry.logged()
+	if err != nil {
+		return Entry{}, err
+	}
+
+	if err := logged.matches(envBytes, env.Payload, publicKey); err != nil {
+		return Entry{}, err
+	}
+
+	return entry, nil
+}
+
+// Find returns an entry for env that was signed by the log and whose public key signed env. Entries are searched for
+// by the digest of env's payload, since how env is marshaled may differ from when it was uploaded.
+func (c *Client) Find(ctx context.Context, env dsse.Envelope) (Entry, error) {
+	payloadHash := sha256.Sum256(env.Payload)
+	reqBody, err := json.Marshal(map[string]string{"hash": "sha256:" + hex.EncodeToString(payloadHash[:])})
+	if err != nil {
+		return Entry{}, err
+	}
+
+	resp, body, err := c.do(ctx, http.MethodPost, c.url+retrievePath, reqBody)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return Entry{}, fmt.Errorf("rekor responded with %v: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	uuids := []string{}
+	if err := json.Unmarshal(body, &uuids); err != nil {
+		return Entry{}, fmt.Errorf("failed to parse rekor search results: %w", err)
+	}
+
+	if len(uuids) == 0 {
+		return Entry{}, fmt.Errorf("no rekor entry found for the envelope")
+	}
+
+	// other entries may record the same payload signed by other keys, so the first to verify is returned
+	var lastErr error
+	for _, uuid := range uuids {
+		entry, err := c.Get(ctx, uuid)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		logged, err := entry.logged()
+		if err == nil {
+			err = logged.verifyEnvelope(env)
+		}
+
+		if err != nil {
+			lastErr = fmt.Errorf("entry %v: %w", uuid, err)
+			continue
+		}
+
+		return entry, nil
+	}
+
+	return Entry{}, lastErr
+}
+
+// Get fetches the entry with uuid, verifying it was signed by the log
+func (c *Client) Get(ctx context.Context, uuid string) (Entry, error) {
+	resp, body, err := c.do(ctx, http.MethodGet, c.EntryURL(uuid), nil)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return Entry{}, fmt.Errorf("rekor responded with %v: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	entry, err := parseEntry(body)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	if err := entry.verify(c.logKey); err != nil {
+		return Entry{}, fmt.Errorf("entry %v: %w", entry.UUID, err)
+	}
+
+	return entry, nil
+}
+
+// EntryURL returns the URL the entry with uuid can be fetched from
+func (c *Client) EntryURL(uuid string) string {
+	return fmt.Sprintf("%v%v/%v", c.url, entriesPath, url.PathEscape(uuid))
+}
+
+// parseEntry parses a response holding a single entry, keyed by its uuid
+func parseEntry(body []byte) (Entry, error) {
+	entries := map[string]Entry{}
+	if err := json.Unmarshal(body, &entries); err != nil {
+		return Entry{}, fmt.Errorf("failed to parse rekor entry: %w", err)
+	}
+
+	if len(entries) != 1 {
+		return Entry{}, fmt.Errorf("expected rekor to return 1 entry, got %v", len(entries))
+	}
+
+	for uuid, entry := range entries {
+		entry.UUID = uuid
+		return entry, nil
+	}
+
+	return Entry{}, nil
+}
+
+func (c *Client) do(ctx context.Context, method, reqURL string, body []byte) (*http.Response, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return resp, respBody, nil
+}
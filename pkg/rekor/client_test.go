@@ -0,0 +1,120 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rekor
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/witness/pkg/rekor/rekortest"
+)
+
+func newKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return key
+}
+
+func signedEnvelope(t *testing.T, payload string) (dsse.Envelope, []byte) {
+	key := newKey(t)
+	signer, err := cryptoutil.NewSigner(key)
+	require.NoError(t, err)
+	env, err := dsse.Sign("application/vnd.in-toto+json", bytes.NewReader([]byte(payload)), dsse.SignWithSigners(signer))
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	return env, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func TestUpload(t *testing.T) {
+	server := rekortest.NewServer(t)
+	env, publicKey := signedEnvelope(t, "{}")
+	client := New(server.URL+"/", &server.Key.PublicKey)
+	entry, err := client.Upload(context.Background(), env, publicKey)
+	require.NoError(t, err)
+	assert.Equal(t, "entry-1", entry.UUID)
+	assert.Equal(t, int64(1), entry.LogIndex)
+	assert.Equal(t, server.URL+entriesPath+"/entry-1", client.EntryURL(entry.UUID))
+
+	again, err := client.Upload(context.Background(), env, publicKey)
+	require.NoError(t, err)
+	// the log's signatures are randomized, so the entries are compared by what they record
+	assert.Equal(t, entry.UUID, again.UUID)
+	assert.Equal(t, entry.Body, again.Body)
+
+	_, err = New(server.URL+"/missing", &server.Key.PublicKey).Upload(context.Background(), env, publicKey)
+	assert.Error(t, err)
+
+	_, err = New(server.URL, &newKey(t).PublicKey).Upload(context.Background(), env, publicKey)
+	assert.ErrorContains(t, err, "signed entry timestamp wasn't signed by the log's key")
+
+	_, otherKey := signedEnvelope(t, "{}")
+	_, err = client.Upload(context.Background(), env, otherKey)
+	assert.ErrorContains(t, err, "different public key")
+
+	server.Tamper = func(e *rekortest.Entry) { e.Verification.SignedEntryTimestamp = "" }
+	_, err = client.Upload(context.Background(), env, publicKey)
+	assert.ErrorContains(t, err, "no signed entry timestamp")
+
+	server.Tamper = func(e *rekortest.Entry) {
+		e.Verification.InclusionProof.RootHash = hex.EncodeToString(make([]byte, sha256.Size))
+	}
+	_, err = client.Upload(context.Background(), env, publicKey)
+	assert.ErrorContains(t, err, "doesn't lead to root hash")
+
+	server.Tamper = func(e *rekortest.Entry) {
+		e.Verification.InclusionProof.Checkpoint = strings.Replace(e.Verification.InclusionProof.Checkpoint, "rekortest\n", "other\n", 1)
+	}
+	_, err = client.Upload(context.Background(), env, publicKey)
+	assert.ErrorContains(t, err, "checkpoint wasn't signed by the log's key")
+}
+
+func TestFind(t *testing.T) {
+	server := rekortest.NewServer(t)
+	client := New(server.URL, &server.Key.PublicKey)
+	env, publicKey := signedEnvelope(t, `{"step": "build"}`)
+	_, err := client.Find(context.Background(), env)
+	assert.ErrorContains(t, err, "no rekor entry found")
+
+	uploaded, err := client.Upload(context.Background(), env, publicKey)
+	require.NoError(t, err)
+
+	// the envelope may be marshaled differently than when it was uploaded, so it's found by its payload
+	found, err := client.Find(context.Background(), dsse.Envelope{PayloadType: env.PayloadType, Payload: env.Payload, Signatures: env.Signatures})
+	require.NoError(t, err)
+	assert.Equal(t, uploaded.UUID, found.UUID)
+	assert.Equal(t, uploaded.Body, found.Body)
+
+	// an envelope with the same payload signed by another key isn't bound by the entry
+	forged, _ := signedEnvelope(t, `{"step": "build"}`)
+	_, err = client.Find(context.Background(), forged)
+	assert.ErrorContains(t, err, "isn't signed by the entry's public key")
+
+	_, err = New(server.URL, &newKey(t).PublicKey).Find(context.Background(), env)
+	assert.ErrorContains(t, err, "signed entry timestamp wasn't signed by the log's key")
+}
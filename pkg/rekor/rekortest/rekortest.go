@@ -0,0 +1,218 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rekortest provides a Rekor transparency log for testing clients of the log.
+package rekortest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/dsse"
+)
+
+const (
+	entriesPath  = "/api/v1/log/entries"
+	retrievePath = "/api/v1/index/retrieve"
+)
+
+// Entry is an entry as the log returns it
+type Entry struct {
+	LogIndex       int64  `json:"logIndex"`
+	LogID          string `json:"logID"`
+	IntegratedTime int64  `json:"integratedTime"`
+	Body           string `json:"body"`
+	Verification   struct {
+		SignedEntryTimestamp string          `json:"signedEntryTimestamp"`
+		InclusionProof       *InclusionProof `json:"inclusionProof,omitempty"`
+	} `json:"verification"`
+}
+
+type InclusionProof struct {
+	LogIndex   int64    `json:"logIndex"`
+	RootHash   string   `json:"rootHash"`
+	TreeSize   int64    `json:"treeSize"`
+	Hashes     []string `json:"hashes"`
+	Checkpoint string   `json:"checkpoint"`
+}
+
+// Server is a Rekor log that records intoto entries, signing their entry timestamps and checkpoints with Key
+type Server struct {
+	*httptest.Server
+	Key *ecdsa.PrivateKey
+	// Tamper changes an entry before it's returned
+	Tamper func(*Entry)
+
+	t        *testing.T
+	mu       sync.Mutex
+	bodies   [][]byte
+	byHash   map[string]int
+	payloads map[string][]string
+}
+
+// NewServer starts a log that's closed when the test ends. The log holds an unrelated entry, so inclusion proofs of
+// the entries added to it have a path.
+func NewServer(t *testing.T) *Server {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	s := &Server{Key: key, t: t, bodies: [][]byte{[]byte("{}")}, byHash: map[string]int{}, payloads: map[string][]string{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Entries returns how many entries were added to the log
+func (s *Server) Entries() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.bodies) - 1
+}
+
+// WritePublicKey writes the log's PEM public key to a file and returns its path
+func (s *Server) WritePublicKey(t *testing.T) string {
+	der, err := x509.MarshalPKIXPublicKey(&s.Key.PublicKey)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "rekor.pub")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644))
+	return path
+}
+
+// UUID returns the uuid of the entry at index
+func UUID(index int) string {
+	return fmt.Sprintf("entry-%d", index)
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == entriesPath:
+		proposed := struct {
+			Spec struct {
+				Content struct {
+					Envelope string `json:"envelope"`
+				} `json:"content"`
+				PublicKey []byte `json:"publicKey"`
+			} `json:"spec"`
+		}{}
+		require.NoError(s.t, json.NewDecoder(r.Body).Decode(&proposed))
+		env := dsse.Envelope{}
+		require.NoError(s.t, json.Unmarshal([]byte(proposed.Spec.Content.Envelope), &env))
+		envHash := sha256.Sum256([]byte(proposed.Spec.Content.Envelope))
+		if index, ok := s.byHash[hex.EncodeToString(envHash[:])]; ok {
+			w.Header().Set("Location", fmt.Sprintf("%v/%v", entriesPath, UUID(index)))
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+
+		// the log records the canonical form of the entry, which holds hashes of the envelope and its payload
+		payloadHash := sha256.Sum256(env.Payload)
+		body, err := json.Marshal(map[string]interface{}{
+			"apiVersion": "0.0.1",
+			"kind":       "intoto",
+			"spec": map[string]interface{}{
+				"content": map[string]interface{}{
+					"hash":        map[string]string{"algorithm": "sha256", "value": hex.EncodeToString(envHash[:])},
+					"payloadHash": map[string]string{"algorithm": "sha256", "value": hex.EncodeToString(payloadHash[:])},
+				},
+				"publicKey": proposed.Spec.PublicKey,
+			},
+		})
+		require.NoError(s.t, err)
+		s.bodies = append(s.bodies, body)
+		index := len(s.bodies) - 1
+		s.byHash[hex.EncodeToString(envHash[:])] = index
+		key := "sha256:" + hex.EncodeToString(payloadHash[:])
+		s.payloads[key] = append(s.payloads[key], UUID(index))
+		w.WriteHeader(http.StatusCreated)
+		s.writeEntry(w, index)
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, entriesPath+"/"):
+		var index int
+		if _, err := fmt.Sscanf(strings.TrimPrefix(r.URL.Path, entriesPath+"/"), "entry-%d", &index); err != nil || index >= len(s.bodies) {
+			http.NotFound(w, r)
+			return
+		}
+
+		s.writeEntry(w, index)
+	case r.Method == http.MethodPost && r.URL.Path == retrievePath:
+		query := map[string]string{}
+		require.NoError(s.t, json.NewDecoder(r.Body).Decode(&query))
+		require.NoError(s.t, json.NewEncoder(w).Encode(append([]string{}, s.payloads[query["hash"]]...)))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) sign(data []byte) []byte {
+	digest := sha256.Sum256(data)
+	sig, err := ecdsa.SignASN1(rand.Reader, s.Key, digest[:])
+	require.NoError(s.t, err)
+	return sig
+}
+
+func (s *Server) writeEntry(w http.ResponseWriter, index int) {
+	entry := Entry{LogIndex: int64(index), LogID: "log", IntegratedTime: 1700000000, Body: base64.StdEncoding.EncodeToString(s.bodies[index])}
+	// the signed entry timestamp is over the RFC 8785 canonical JSON of these fields
+	payload := fmt.Sprintf(`{"body":%q,"integratedTime":%d,"logID":%q,"logIndex":%d}`, entry.Body, entry.IntegratedTime, entry.LogID, entry.LogIndex)
+	entry.Verification.SignedEntryTimestamp = base64.StdEncoding.EncodeToString(s.sign([]byte(payload)))
+
+	// proofs are built for the trees of at most two entries that tests use
+	leaves := [][]byte{}
+	for _, body := range s.bodies {
+		sum := sha256.Sum256(append([]byte{0}, body...))
+		leaves = append(leaves, sum[:])
+	}
+
+	proof := &InclusionProof{LogIndex: int64(index), TreeSize: int64(len(leaves))}
+	root := leaves[0]
+	switch len(leaves) {
+	case 1:
+	case 2:
+		proof.Hashes = []string{hex.EncodeToString(leaves[1-index])}
+		sum := sha256.Sum256(append(append([]byte{1}, leaves[0]...), leaves[1]...))
+		root = sum[:]
+	default:
+		proof = nil
+	}
+
+	if proof != nil {
+		proof.RootHash = hex.EncodeToString(root)
+		checkpoint := fmt.Sprintf("rekortest\n%d\n%v\n", len(leaves), base64.StdEncoding.EncodeToString(root))
+		sig := append([]byte{0, 0, 0, 0}, s.sign([]byte(checkpoint))...)
+		proof.Checkpoint = fmt.Sprintf("%v\n— rekortest %v\n", checkpoint, base64.StdEncoding.EncodeToString(sig))
+		entry.Verification.InclusionProof = proof
+	}
+
+	if s.Tamper != nil {
+		s.Tamper(&entry)
+	}
+
+	require.NoError(s.t, json.NewEncoder(w).Encode(map[string]Entry{UUID(index): entry}))
+}
@@ -0,0 +1,300 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rekor
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/witness/pkg/scitt"
+)
+
+// ParsePublicKey parses a PEM public key or certificate, returning the certificate's public key
+func ParsePublicKey(pemBytes []byte) (crypto.PublicKey, error) {
+	key, err := cryptoutil.TryParseKeyFromReader(bytes.NewReader(pemBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	if cert, ok := key.(*x509.Certificate); ok {
+		return cert.PublicKey, nil
+	}
+
+	return key, nil
+}
+
+// LoadPublicKey reads the PEM public key or certificate of a log from path
+func LoadPublicKey(path string) (crypto.PublicKey, error) {
+	pemBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rekor public key: %w", err)
+	}
+
+	key, err := ParsePublicKey(pemBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse rekor public key: %w", err)
+	}
+
+	return key, nil
+}
+
+// InclusionProof proves an entry is in the tree described by a checkpoint signed by the log
+type InclusionProof struct {
+	LogIndex   int64    `json:"logIndex"`
+	RootHash   string   `json:"rootHash"`
+	TreeSize   int64    `json:"treeSize"`
+	Hashes     []string `json:"hashes"`
+	Checkpoint string   `json:"checkpoint"`
+}
+
+// setPayload is what the log signs to promise an entry will be included. Its fields are in the order RFC 8785
+// canonicalization puts them, so marshaling it gives the bytes the log signed.
+type setPayload struct {
+	Body           string `json:"body"`
+	IntegratedTime int64  `json:"integratedTime"`
+	LogID          string `json:"logID"`
+	LogIndex       int64  `json:"logIndex"`
+}
+
+// loggedIntoto is the canonical form of an intoto entry the log records, which holds hashes of the envelope rather
+// than the envelope itself
+type loggedIntoto struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Spec       struct {
+		Content struct {
+			Hash        loggedHash `json:"hash"`
+			PayloadHash loggedHash `json:"payloadHash"`
+		} `json:"content"`
+		PublicKey []byte `json:"publicKey"`
+	} `json:"spec"`
+}
+
+type loggedHash struct {
+	Algorithm string `json:"algorithm"`
+	Value     string `json:"value"`
+}
+
+// verify checks the log signed entry with logKey, by its signed entry timestamp and by the checkpoint of its
+// inclusion proof if the log returned one
+func (e Entry) verify(logKey crypto.PublicKey) error {
+	verifier, err := cryptoutil.NewVerifier(logKey)
+	if err != nil {
+		return fmt.Errorf("failed to create verifier for the log's key: %w", err)
+	}
+
+	if e.Verification.SignedEntryTimestamp == "" {
+		return fmt.Errorf("entry has no signed entry timestamp")
+	}
+
+	set, err := base64.StdEncoding.DecodeString(e.Verification.SignedEntryTimestamp)
+	if err != nil {
+		return fmt.Errorf("failed to decode signed entry timestamp: %w", err)
+	}
+
+	payload, err := json.Marshal(setPayload{Body: e.Body, IntegratedTime: e.IntegratedTime, LogID: e.LogID, LogIndex: e.LogIndex})
+	if err != nil {
+		return err
+	}
+
+	if err := verifier.Verify(bytes.NewReader(payload), set); err != nil {
+		return fmt.Errorf("signed entry timestamp wasn't signed by the log's key: %w", err)
+	}
+
+	if e.Verification.InclusionProof == nil {
+		return nil
+	}
+
+	return e.Verification.InclusionProof.verify(verifier, e.Body)
+}
+
+// verify checks the proof places body in the tree the log signed the checkpoint of
+func (p InclusionProof) verify(verifier cryptoutil.Verifier, body string) error {
+	leaf, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return fmt.Errorf("failed to decode entry body: %w", err)
+	}
+
+	proof := scitt.InclusionProof{TreeSize: p.TreeSize, LeafIndex: p.LogIndex}
+	for _, hash := range p.Hashes {
+		hashBytes, err := hex.DecodeString(hash)
+		if err != nil || len(hashBytes) != sha256.Size {
+			return fmt.Errorf("inclusion proof hashes must be hex sha256 digests")
+		}
+
+		proof.Path = append(proof.Path, hashBytes)
+	}
+
+	root, err := proof.Root(scitt.LeafHash(leaf))
+	if err != nil {
+		return err
+	}
+
+	if hex.EncodeToString(root) != strings.ToLower(p.RootHash) {
+		return fmt.Errorf("inclusion proof doesn't lead to root hash %v", p.RootHash)
+	}
+
+	treeSize, checkpointRoot, err := verifyCheckpoint(verifier, p.Checkpoint)
+	if err != nil {
+		return err
+	}
+
+	if treeSize != p.TreeSize || !bytes.Equal(checkpointRoot, root) {
+		return fmt.Errorf("checkpoint is for a different tree than the inclusion proof")
+	}
+
+	return nil
+}
+
+// verifyCheckpoint checks the log signed checkpoint, a signed note of the log's origin, tree size, and base64 root
+// hash, and returns the tree size and root hash
+func verifyCheckpoint(verifier cryptoutil.Verifier, checkpoint string) (int64, []byte, error) {
+	i := strings.Index(checkpoint, "\n\n")
+	if i < 0 {
+		return 0, nil, fmt.Errorf("checkpoint has no signatures")
+	}
+
+	text, signatures := checkpoint[:i+1], checkpoint[i+2:]
+	verified := false
+	scanner := bufio.NewScanner(strings.NewReader(signatures))
+	for scanner.Scan() {
+		// signature lines are "— <name> <base64 of a 4 byte key hint and the signature>"
+		fields := strings.Fields(strings.TrimPrefix(scanner.Text(), "— "))
+		if len(fields) != 2 {
+			continue
+		}
+
+		sig, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil || len(sig) <= 4 {
+			continue
+		}
+
+		if verifier.Verify(strings.NewReader(text), sig[4:]) == nil {
+			verified = true
+			break
+		}
+	}
+
+	if !verified {
+		return 0, nil, fmt.Errorf("checkpoint wasn't signed by the log's key")
+	}
+
+	lines := strings.Split(text, "\n")
+	if len(lines) < 3 {
+		return 0, nil, fmt.Errorf("checkpoint is missing its tree size or root hash")
+	}
+
+	treeSize, err := strconv.ParseInt(lines[1], 10, 64)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to parse checkpoint tree size: %w", err)
+	}
+
+	root, err := base64.StdEncoding.DecodeString(lines[2])
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to parse checkpoint root hash: %w", err)
+	}
+
+	return treeSize, root, nil
+}
+
+// logged decodes the intoto entry the log recorded
+func (e Entry) logged() (loggedIntoto, error) {
+	logged := loggedIntoto{}
+	body, err := base64.StdEncoding.DecodeString(e.Body)
+	if err != nil {
+		return logged, fmt.Errorf("failed to decode entry body: %w", err)
+	}
+
+	if err := json.Unmarshal(body, &logged); err != nil {
+		return logged, fmt.Errorf("failed to parse entry body: %w", err)
+	}
+
+	if logged.Kind != intotoKind || logged.APIVersion != intotoVersion {
+		return logged, fmt.Errorf("entry is a %v %v entry rather than %v %v", logged.Kind, logged.APIVersion, intotoKind, intotoVersion)
+	}
+
+	return logged, nil
+}
+
+// matches checks the logged entry records the envelope with payload and the public key that was submitted. envBytes
+// is the marshaled envelope, which the log hashed as it was submitted.
+func (l loggedIntoto) matches(envBytes, payload, publicKey []byte) error {
+	envHash := sha256.Sum256(envBytes)
+	if l.Spec.Content.Hash.Value != hex.EncodeToString(envHash[:]) {
+		return fmt.Errorf("entry is for a different envelope")
+	}
+
+	payloadHash := sha256.Sum256(payload)
+	if l.Spec.Content.PayloadHash.Value != hex.EncodeToString(payloadHash[:]) {
+		return fmt.Errorf("entry is for a different payload")
+	}
+
+	// the log re-encodes the key, so the keys are compared by their DER
+	submitted, logged := pemBytes(publicKey), pemBytes(l.Spec.PublicKey)
+	if submitted == nil || !bytes.Equal(submitted, logged) {
+		return fmt.Errorf("entry is for a different public key")
+	}
+
+	return nil
+}
+
+// verifyEnvelope checks env has the payload recorded in the logged entry and a signature from the logged key
+func (l loggedIntoto) verifyEnvelope(env dsse.Envelope) error {
+	payloadHash := sha256.Sum256(env.Payload)
+	if l.Spec.Content.PayloadHash.Value != hex.EncodeToString(payloadHash[:]) {
+		return fmt.Errorf("entry is for a different payload")
+	}
+
+	key, err := ParsePublicKey(l.Spec.PublicKey)
+	if err != nil {
+		return fmt.Errorf("failed to parse the entry's public key: %w", err)
+	}
+
+	verifier, err := cryptoutil.NewVerifier(key)
+	if err != nil {
+		return fmt.Errorf("failed to create verifier for the entry's public key: %w", err)
+	}
+
+	pae := fmt.Sprintf("DSSEv1 %d %s %d %s", len(env.PayloadType), env.PayloadType, len(env.Payload), env.Payload)
+	for _, sig := range env.Signatures {
+		if verifier.Verify(strings.NewReader(pae), sig.Signature) == nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("envelope isn't signed by the entry's public key")
+}
+
+func pemBytes(data []byte) []byte {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil
+	}
+
+	return block.Bytes
+}
@@ -0,0 +1,174 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package revocation reads signed lists of attestations that must no longer be trusted, such as
+// attestations produced on a runner while it was compromised, even though their signatures are valid.
+package revocation
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+)
+
+// PayloadType is the DSSE payload type of a signed revocation list, for use with witness sign -t
+const PayloadType = "https://witness.dev/revocations/v0.1"
+
+const gitoidURIPrefix = "gitoid:blob:sha256:"
+
+// Entry identifies a revoked envelope by the sha256 digest of its payload, or by its gitoid as reported by
+// Archivist. Gitoid entries are advisory: the gitoid covers the envelope's unsigned fields, such as key ids,
+// timestamps, and the set of signatures, so changing those gives a revoked envelope a new gitoid without
+// invalidating its signatures. Only the payload digest is covered by the signatures.
+type Entry struct {
+	Gitoid        string    `json:"gitoid,omitempty"`
+	PayloadDigest string    `json:"payloadDigest,omitempty"`
+	Reason        string    `json:"reason,omitempty"`
+	RevokedAt     time.Time `json:"revokedAt,omitempty"`
+}
+
+type List struct {
+	Revoked []Entry `json:"revoked"`
+
+	gitoids        map[string]Entry
+	payloadDigests map[string]Entry
+}
+
+// New indexes the entries of a revocation list
+func New(entries []Entry) *List {
+	l := &List{
+		Revoked:        entries,
+		gitoids:        make(map[string]Entry),
+		payloadDigests: make(map[string]Entry),
+	}
+
+	for _, entry := range entries {
+		if entry.Gitoid != "" {
+			l.gitoids[strings.TrimPrefix(strings.ToLower(entry.Gitoid), gitoidURIPrefix)] = entry
+		}
+
+		if entry.PayloadDigest != "" {
+			l.payloadDigests[strings.TrimPrefix(strings.ToLower(entry.PayloadDigest), "sha256:")] = entry
+		}
+	}
+
+	return l
+}
+
+// Load reads a signed revocation list from a file or http(s) URL. The list is only trusted if it's
+// signed by one of the verifiers.
+func Load(ctx context.Context, location string, verifiers []cryptoutil.Verifier) (*List, error) {
+	data, err := read(ctx, location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read revocation list: %w", err)
+	}
+
+	env := dsse.Envelope{}
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("failed to parse revocation list envelope: %w", err)
+	}
+
+	if env.PayloadType != PayloadType {
+		return nil, fmt.Errorf("revocation list has payload type %v, expected %v", env.PayloadType, PayloadType)
+	}
+
+	if _, err := env.Verify(dsse.VerifyWithVerifiers(verifiers...)); err != nil {
+		return nil, fmt.Errorf("failed to verify revocation list: %w", err)
+	}
+
+	parsed := struct {
+		Revoked []Entry `json:"revoked"`
+	}{}
+
+	if err := json.Unmarshal(env.Payload, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse revocation list: %w", err)
+	}
+
+	return New(parsed.Revoked), nil
+}
+
+func read(ctx context.Context, location string) ([]byte, error) {
+	if !strings.HasPrefix(location, "https://") && !strings.HasPrefix(location, "http://") {
+		return os.ReadFile(location)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server responded with %v", resp.Status)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// Check returns the entry revoking env, if any. A nil list revokes nothing.
+func (l *List) Check(env dsse.Envelope) (Entry, bool, error) {
+	if l == nil {
+		return Entry{}, false, nil
+	}
+
+	if entry, ok := l.payloadDigests[PayloadDigest(env)]; ok {
+		return entry, true, nil
+	}
+
+	if len(l.gitoids) == 0 {
+		return Entry{}, false, nil
+	}
+
+	gitoid, err := Gitoid(env)
+	if err != nil {
+		return Entry{}, false, err
+	}
+
+	entry, ok := l.gitoids[gitoid]
+	return entry, ok, nil
+}
+
+// PayloadDigest is the hex encoded sha256 digest of the envelope's payload
+func PayloadDigest(env dsse.Envelope) string {
+	digest := sha256.Sum256(env.Payload)
+	return hex.EncodeToString(digest[:])
+}
+
+// Gitoid calculates the sha256 gitoid Archivist assigns to the envelope when it's stored
+func Gitoid(env dsse.Envelope) (string, error) {
+	data, err := json.Marshal(env)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "blob %d\x00", len(data))
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package revocation
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+)
+
+func writeList(t *testing.T, signer cryptoutil.Signer, payloadType string, entries []Entry) string {
+	payload, err := json.Marshal(List{Revoked: entries})
+	require.NoError(t, err)
+	env, err := dsse.Sign(payloadType, bytes.NewReader(payload), dsse.SignWithSigners(signer))
+	require.NoError(t, err)
+	envBytes, err := json.Marshal(env)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "revoked.json")
+	require.NoError(t, os.WriteFile(path, envBytes, 0644))
+	return path
+}
+
+func TestLoadAndCheck(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	signer := cryptoutil.NewRSASigner(key, crypto.SHA256)
+	verifier := cryptoutil.NewRSAVerifier(&key.PublicKey, crypto.SHA256)
+
+	byDigest := dsse.Envelope{PayloadType: "test", Payload: []byte(`{"a":1}`)}
+	byPrefixedDigest := dsse.Envelope{PayloadType: "test", Payload: []byte(`{"d":4}`)}
+	byGitoid := dsse.Envelope{PayloadType: "test", Payload: []byte(`{"b":2}`)}
+	trusted := dsse.Envelope{PayloadType: "test", Payload: []byte(`{"c":3}`)}
+	gitoid, err := Gitoid(byGitoid)
+	require.NoError(t, err)
+
+	path := writeList(t, signer, PayloadType, []Entry{
+		{PayloadDigest: PayloadDigest(byDigest), Reason: "compromised runner"},
+		{Gitoid: gitoidURIPrefix + gitoid},
+		{PayloadDigest: "sha256:" + PayloadDigest(byPrefixedDigest)},
+	})
+
+	list, err := Load(context.Background(), path, []cryptoutil.Verifier{verifier})
+	require.NoError(t, err)
+
+	entry, revoked, err := list.Check(byDigest)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+	assert.Equal(t, "compromised runner", entry.Reason)
+
+	_, revoked, err = list.Check(byGitoid)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+
+	// the key ids aren't signed, so changing them gives the envelope a new gitoid but keeps its payload digest
+	byDigest.Signatures = []dsse.Signature{{KeyID: "rewritten", Signature: []byte("sig")}}
+	_, revoked, err = list.Check(byDigest)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+
+	_, revoked, err = list.Check(byPrefixedDigest)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+
+	_, revoked, err = list.Check(trusted)
+	require.NoError(t, err)
+	assert.False(t, revoked)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, err = Load(context.Background(), path, []cryptoutil.Verifier{cryptoutil.NewRSAVerifier(&otherKey.PublicKey, crypto.SHA256)})
+	assert.Error(t, err)
+
+	wrongType := writeList(t, signer, "https://witness.testifysec.com/policy/v0.1", nil)
+	_, err = Load(context.Background(), wrongType, []cryptoutil.Verifier{verifier})
+	assert.Error(t, err)
+}
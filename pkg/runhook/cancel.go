@@ -0,0 +1,67 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runhook
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/testifysec/go-witness/attestation"
+)
+
+type cancelAttestor struct {
+	passthrough
+	grace time.Duration
+}
+
+func (a cancelAttestor) Attest(ctx *attestation.AttestationContext) error {
+	if err := ctx.Context().Err(); err != nil {
+		return fmt.Errorf("command was canceled: %w", err)
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-done:
+			return
+		case <-ctx.Context().Done():
+		}
+
+		terminateChildren()
+		select {
+		case <-done:
+		case <-time.After(a.grace):
+			killChildren()
+		}
+	}()
+
+	err := a.Attestor.Attest(ctx)
+	close(done)
+	<-stopped
+	if ctxErr := ctx.Context().Err(); ctxErr != nil {
+		return fmt.Errorf("command was canceled: %w", ctxErr)
+	}
+
+	return err
+}
+
+// WithCancellation returns command wrapped so the processes it starts are stopped when the attestation context
+// is canceled. They're asked to terminate, then killed if they're still running after grace. Child processes can
+// only be found on Linux, so elsewhere the command runs until it exits. Use Unwrap before recording the attestor.
+func WithCancellation(command attestation.Attestor, grace time.Duration) attestation.Attestor {
+	return cancelAttestor{passthrough{command}, grace}
+}
@@ -0,0 +1,55 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runhook
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+func terminateChildren() {
+	signalChildren(syscall.SIGTERM)
+}
+
+func killChildren() {
+	signalChildren(syscall.SIGKILL)
+}
+
+// signalChildren sends sig to the direct children of every thread of this process
+func signalChildren(sig syscall.Signal) {
+	paths, err := filepath.Glob("/proc/self/task/*/children")
+	if err != nil {
+		return
+	}
+
+	for _, path := range paths {
+		children, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		for _, field := range strings.Fields(string(children)) {
+			pid, err := strconv.Atoi(field)
+			if err != nil {
+				continue
+			}
+
+			_ = syscall.Kill(pid, sig)
+		}
+	}
+}
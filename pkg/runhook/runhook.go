@@ -0,0 +1,219 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package runhook lets attestors choose when they run relative to the command witness wraps. Attestors can be
+// moved between the pre and post run phases, and can implement hooks that run immediately before and after
+// the command regardless of the phase they're recorded in.
+package runhook
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+)
+
+// PreCommandHook is implemented by attestors that need to observe the working directory or environment
+// immediately before the command runs, after materials have been recorded.
+type PreCommandHook interface {
+	PreCommand(ctx *attestation.AttestationContext) error
+}
+
+// PostCommandHook is implemented by attestors that need to observe the working directory or environment
+// immediately after the command exits, before products are recorded.
+type PostCommandHook interface {
+	PostCommand(ctx *attestation.AttestationContext) error
+}
+
+// Spec is an attestor requested on the command line, optionally with the phase it should run in
+type Spec struct {
+	Attestor string
+	RunType  attestation.RunType
+}
+
+func (s Spec) String() string {
+	if s.RunType == "" {
+		return s.Attestor
+	}
+
+	return fmt.Sprintf("%v:%v", s.Attestor, s.RunType)
+}
+
+// ParseSpec parses an attestor name or type with an optional :pre or :post suffix, such as git:pre.
+// Attestor types are URIs, so a suffix containing a / is treated as part of the type.
+func ParseSpec(spec string) (Spec, error) {
+	i := strings.LastIndex(spec, ":")
+	if i < 0 || strings.Contains(spec[i+1:], "/") {
+		return Spec{Attestor: spec}, nil
+	}
+
+	name, runType := spec[:i], attestation.RunType(spec[i+1:])
+	switch runType {
+	case attestation.PreRunType, attestation.PostRunType:
+	default:
+		return Spec{}, fmt.Errorf("unknown run type %q for attestor %v, expected pre or post", runType, name)
+	}
+
+	if name == "" {
+		return Spec{}, fmt.Errorf("attestor name is required in %q", spec)
+	}
+
+	return Spec{Attestor: name, RunType: runType}, nil
+}
+
+// ParseSpecs parses each of specs with ParseSpec
+func ParseSpecs(specs []string) ([]Spec, error) {
+	parsed := make([]Spec, 0, len(specs))
+	for _, spec := range specs {
+		s, err := ParseSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+
+		parsed = append(parsed, s)
+	}
+
+	return parsed, nil
+}
+
+// wrapped is implemented by the attestors this package returns so the original attestor can be recorded
+type wrapped interface {
+	unwrap() attestation.Attestor
+}
+
+// Unwrap returns the attestor wrapped by WithRunType or WithCommandHooks, or attestor itself if it isn't wrapped
+func Unwrap(attestor attestation.Attestor) attestation.Attestor {
+	for {
+		w, ok := attestor.(wrapped)
+		if !ok {
+			return attestor
+		}
+
+		attestor = w.unwrap()
+	}
+}
+
+// passthrough forwards the optional attestor interfaces the attestation context uses while attestors run
+type passthrough struct {
+	attestation.Attestor
+}
+
+func (p passthrough) unwrap() attestation.Attestor {
+	return p.Attestor
+}
+
+func (p passthrough) Materials() map[string]cryptoutil.DigestSet {
+	if materialer, ok := p.Attestor.(attestation.Materialer); ok {
+		return materialer.Materials()
+	}
+
+	return nil
+}
+
+func (p passthrough) Products() map[string]attestation.Product {
+	if producer, ok := p.Attestor.(attestation.Producer); ok {
+		return producer.Products()
+	}
+
+	return nil
+}
+
+type runTypeAttestor struct {
+	passthrough
+	runType attestation.RunType
+}
+
+func (a runTypeAttestor) RunType() attestation.RunType {
+	return a.runType
+}
+
+// WithRunType returns attestor set to run in the runType phase. Use Unwrap before recording the attestor.
+func WithRunType(attestor attestation.Attestor, runType attestation.RunType) attestation.Attestor {
+	if runType == "" || attestor.RunType() == runType {
+		return attestor
+	}
+
+	return runTypeAttestor{passthrough{attestor}, runType}
+}
+
+type commandAttestor struct {
+	passthrough
+	attestors []attestation.Attestor
+}
+
+func (a commandAttestor) Attest(ctx *attestation.AttestationContext) error {
+	for _, attestor := range a.attestors {
+		if hook, ok := Unwrap(attestor).(PreCommandHook); ok {
+			if err := hook.PreCommand(ctx); err != nil {
+				return fmt.Errorf("%v pre command hook failed: %w", attestor.Name(), err)
+			}
+		}
+	}
+
+	if err := a.Attestor.Attest(ctx); err != nil {
+		return err
+	}
+
+	for _, attestor := range a.attestors {
+		if hook, ok := Unwrap(attestor).(PostCommandHook); ok {
+			if err := hook.PostCommand(ctx); err != nil {
+				return fmt.Errorf("%v post command hook failed: %w", attestor.Name(), err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// WithCommandHooks returns command wrapped so the hooks implemented by attestors run immediately before and
+// after it. Use Unwrap before recording the attestor.
+func WithCommandHooks(command attestation.Attestor, attestors []attestation.Attestor) attestation.Attestor {
+	return commandAttestor{passthrough{command}, attestors}
+}
+
+type hookAttestor struct {
+	passthrough
+	hooks []interface{}
+}
+
+func (a hookAttestor) Attest(ctx *attestation.AttestationContext) error {
+	for _, hook := range a.hooks {
+		if pre, ok := hook.(PreCommandHook); ok {
+			if err := pre.PreCommand(ctx); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := a.Attestor.Attest(ctx); err != nil {
+		return err
+	}
+
+	for _, hook := range a.hooks {
+		if post, ok := hook.(PostCommandHook); ok {
+			if err := post.PostCommand(ctx); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// WithHooks returns command wrapped so hooks that aren't attestors, such as a tracer, run immediately before and
+// after it. Each hook implements PreCommandHook, PostCommandHook, or both. Use Unwrap before recording the attestor.
+func WithHooks(command attestation.Attestor, hooks ...interface{}) attestation.Attestor {
+	return hookAttestor{passthrough{command}, hooks}
+}
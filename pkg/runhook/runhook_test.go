@@ -0,0 +1,127 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runhook
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/attestation/commandrun"
+)
+
+func TestParseSpec(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected Spec
+		wantErr  bool
+	}{
+		{spec: "git", expected: Spec{Attestor: "git"}},
+		{spec: "git:pre", expected: Spec{Attestor: "git", RunType: attestation.PreRunType}},
+		{spec: "product:post", expected: Spec{Attestor: "product", RunType: attestation.PostRunType}},
+		{spec: "https://witness.dev/attestations/git/v0.1", expected: Spec{Attestor: "https://witness.dev/attestations/git/v0.1"}},
+		{spec: "https://witness.dev/attestations/git/v0.1:post", expected: Spec{Attestor: "https://witness.dev/attestations/git/v0.1", RunType: attestation.PostRunType}},
+		{spec: "git:during", wantErr: true},
+		{spec: "git:internal", wantErr: true},
+		{spec: ":pre", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.spec, func(t *testing.T) {
+			spec, err := ParseSpec(test.spec)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, spec)
+			assert.Equal(t, test.spec, spec.String())
+		})
+	}
+}
+
+type recordingAttestor struct {
+	name    string
+	runType attestation.RunType
+	calls   *[]string
+}
+
+func (a *recordingAttestor) Name() string                 { return a.name }
+func (a *recordingAttestor) Type() string                 { return "https://example.com/" + a.name }
+func (a *recordingAttestor) RunType() attestation.RunType { return a.runType }
+
+func (a *recordingAttestor) Attest(ctx *attestation.AttestationContext) error {
+	*a.calls = append(*a.calls, a.name)
+	return nil
+}
+
+type hookedAttestor struct {
+	recordingAttestor
+}
+
+func (a *hookedAttestor) PreCommand(ctx *attestation.AttestationContext) error {
+	*a.calls = append(*a.calls, a.name+" pre command")
+	return nil
+}
+
+func (a *hookedAttestor) PostCommand(ctx *attestation.AttestationContext) error {
+	*a.calls = append(*a.calls, a.name+" post command")
+	return nil
+}
+
+func TestRunOrder(t *testing.T) {
+	calls := []string{}
+	early := &recordingAttestor{name: "early", runType: attestation.PostRunType, calls: &calls}
+	hooked := &hookedAttestor{recordingAttestor{name: "hooked", runType: attestation.PostRunType, calls: &calls}}
+	command := &recordingAttestor{name: "command", runType: attestation.Internal, calls: &calls}
+
+	attestors := []attestation.Attestor{hooked, WithRunType(early, attestation.PreRunType)}
+	ctx, err := attestation.NewContext(attestors, attestation.WithCommandAttestor(WithCommandHooks(command, attestors)))
+	require.NoError(t, err)
+	require.NoError(t, ctx.RunAttestors())
+	assert.Equal(t, []string{"early", "hooked pre command", "command", "hooked post command", "hooked"}, calls)
+
+	completed := ctx.CompletedAttestors()
+	require.Len(t, completed, 3)
+	assert.Same(t, early, Unwrap(completed[0]))
+	assert.Same(t, command, Unwrap(completed[1]))
+	assert.Same(t, hooked, Unwrap(completed[2]))
+}
+
+func TestWithCancellation(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("child processes can only be stopped on linux")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	actx, err := attestation.NewContext(nil, attestation.WithContext(ctx))
+	require.NoError(t, err)
+
+	command := commandrun.New(commandrun.WithCommand([]string{"sleep", "30"}), commandrun.WithSilent(true))
+	start := time.Now()
+	err = WithCancellation(command, 5*time.Second).Attest(actx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.Same(t, command, Unwrap(WithCancellation(command, time.Second)))
+
+	err = WithCancellation(commandrun.New(commandrun.WithCommand([]string{"true"})), time.Second).Attest(actx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
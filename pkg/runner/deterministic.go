@@ -0,0 +1,100 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/attestation/commandrun"
+	"github.com/testifysec/go-witness/attestation/environment"
+	"github.com/testifysec/go-witness/intoto"
+	"github.com/testifysec/witness/attestation/timesource"
+	"github.com/testifysec/witness/attestation/witness"
+)
+
+const (
+	// sourceDateEpochEnv follows the reproducible builds convention for fixing timestamps
+	sourceDateEpochEnv = "SOURCE_DATE_EPOCH"
+	// deterministicHost replaces the hostname and username recorded in deterministic mode
+	deterministicHost = "witness"
+)
+
+// deterministicTime returns the time used in place of the current time in deterministic mode:
+// SOURCE_DATE_EPOCH if it is set, otherwise the Unix epoch.
+func deterministicTime() (time.Time, error) {
+	epoch, ok := os.LookupEnv(sourceDateEpochEnv)
+	if !ok || epoch == "" {
+		return time.Unix(0, 0).UTC(), nil
+	}
+
+	seconds, err := strconv.ParseInt(epoch, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %v %q: %w", sourceDateEpochEnv, epoch, err)
+	}
+
+	return time.Unix(seconds, 0).UTC(), nil
+}
+
+// normalizeAttestors rewrites the parts of completed attestors that differ between otherwise identical
+// runs, and sorts them by type so the collection is the same regardless of the order attestors were requested in.
+// Times recorded by attestors are replaced with now.
+func normalizeAttestors(attestors []attestation.Attestor, now time.Time) {
+	for _, attestor := range attestors {
+		switch a := attestor.(type) {
+		case *environment.Attestor:
+			a.Hostname = deterministicHost
+			a.Username = deterministicHost
+			a.Variables = nil
+		case *commandrun.CommandRun:
+			normalizeProcesses(a.Processes)
+		case *witness.Attestor:
+			a.Executable = filepath.Base(a.Executable)
+		case *timesource.Attestor:
+			a.SystemTime = now
+			a.Clock = nil
+		}
+	}
+
+	sort.SliceStable(attestors, func(i, j int) bool {
+		return attestors[i].Type() < attestors[j].Type()
+	})
+}
+
+// normalizeProcesses renumbers traced processes in the order they were seen and drops their environments
+func normalizeProcesses(processes []commandrun.ProcessInfo) {
+	pids := make(map[int]int, len(processes))
+	for i, process := range processes {
+		pids[process.ProcessID] = i + 1
+	}
+
+	for i := range processes {
+		processes[i].ProcessID = pids[processes[i].ProcessID]
+		processes[i].ParentPID = pids[processes[i].ParentPID]
+		processes[i].Environ = ""
+	}
+}
+
+// sortSubjects orders a statement's subjects by name, since they're built from a map
+func sortSubjects(subjects []intoto.Subject) {
+	sort.Slice(subjects, func(i, j int) bool {
+		return subjects[i].Name < subjects[j].Name
+	})
+}
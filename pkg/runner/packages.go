@@ -0,0 +1,142 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+	"github.com/testifysec/witness/attestation/packages"
+)
+
+const (
+	// NPMPublishPredicateType is the predicate type of npm publish attestations written with --package-attestations
+	NPMPublishPredicateType = "https://github.com/npm/attestation/tree/main/specs/publish/v0.1"
+	// PyPIPublishPredicateType is the predicate type of PEP 740 publish attestations written with --package-attestations
+	PyPIPublishPredicateType = "https://docs.pypi.org/attestations/publish/v1"
+
+	npmRegistry = "https://registry.npmjs.org"
+)
+
+// writePublishAttestations signs a registry publish attestation for every package the packages attestor found,
+// using the subject naming and digests npm provenance and PEP 740 expect, and writes them to the package
+// attestation directory.
+func (r *runner) writePublishAttestations(collection attestation.Collection, signers []cryptoutil.Signer, timestampers []dsse.Timestamper) error {
+	dir := r.ro.PackageAttestDir
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	for _, att := range collection.Attestations {
+		packagesAttestor, ok := att.Attestation.(*packages.Attestor)
+		if !ok {
+			continue
+		}
+
+		for _, pkg := range packagesAttestor.Packages {
+			statement, err := publishStatement(r.ro.WorkingDir, pkg)
+			if err != nil {
+				return fmt.Errorf("failed to create publish attestation for %v: %w", pkg.File, err)
+			}
+
+			statementBytes, err := json.Marshal(statement)
+			if err != nil {
+				return err
+			}
+
+			env, err := dsse.Sign(intoto.PayloadType, bytes.NewReader(statementBytes), dsse.SignWithSigners(signers...), dsse.SignWithTimestampers(timestampers...))
+			if err != nil {
+				return fmt.Errorf("failed to sign publish attestation for %v: %w", pkg.File, err)
+			}
+
+			envBytes, err := json.Marshal(env)
+			if err != nil {
+				return err
+			}
+
+			path := filepath.Join(dir, filepath.Base(pkg.File)+".publish.intoto.json")
+			if err := os.WriteFile(path, envBytes, 0644); err != nil {
+				return err
+			}
+
+			r.logger.Infof("Wrote publish attestation for %v to %v", pkg.PURL, path)
+		}
+	}
+
+	return nil
+}
+
+func publishStatement(workingDir string, pkg packages.Package) (intoto.Statement, error) {
+	switch pkg.Ecosystem {
+	case packages.EcosystemNPM:
+		digest, err := fileDigest(filepath.Join(workingDir, pkg.File), sha512.New())
+		if err != nil {
+			return intoto.Statement{}, err
+		}
+
+		predicate, err := json.Marshal(map[string]string{"name": pkg.Name, "version": pkg.Version, "registry": npmRegistry})
+		if err != nil {
+			return intoto.Statement{}, err
+		}
+
+		return intoto.Statement{
+			Type:          intoto.StatementType,
+			Subject:       []intoto.Subject{{Name: pkg.PURL, Digest: map[string]string{"sha512": digest}}},
+			PredicateType: NPMPublishPredicateType,
+			Predicate:     predicate,
+		}, nil
+	case packages.EcosystemPyPI:
+		digest, err := fileDigest(filepath.Join(workingDir, pkg.File), sha256.New())
+		if err != nil {
+			return intoto.Statement{}, err
+		}
+
+		// PEP 740 names the distribution by its file name rather than a purl
+		return intoto.Statement{
+			Type:          intoto.StatementType,
+			Subject:       []intoto.Subject{{Name: filepath.Base(pkg.File), Digest: map[string]string{"sha256": digest}}},
+			PredicateType: PyPIPublishPredicateType,
+			Predicate:     json.RawMessage("{}"),
+		}, nil
+	default:
+		return intoto.Statement{}, fmt.Errorf("unknown package ecosystem %v", pkg.Ecosystem)
+	}
+}
+
+func fileDigest(path string, h hash.Hash) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
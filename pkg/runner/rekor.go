@@ -0,0 +1,79 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/witness/pkg/rekor"
+	"github.com/testifysec/witness/pkg/transport"
+)
+
+// rekorPublicKey loads the key of the Rekor log and returns the PEM public key or certificate of signer that's
+// uploaded to it with the envelope, or nil if the envelope isn't uploaded to Rekor
+func (r *runner) rekorPublicKey(signer cryptoutil.Signer) ([]byte, error) {
+	ro := r.ro
+	if ro.RekorOptions.Server == "" {
+		return nil, nil
+	}
+
+	if ro.RekorOptions.PublicKeyPath == "" {
+		return nil, fmt.Errorf("--rekor-server requires --rekor-public-key to verify the log's entries")
+	}
+
+	logKey, err := rekor.LoadPublicKey(ro.RekorOptions.PublicKeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	r.rekorLogKey = logKey
+
+	// rekor verifies every signature on an entry's envelope, and can't verify post-quantum ones
+	if ro.KeyOptions.PQKeyPath != "" {
+		return nil, fmt.Errorf("--pq-key can't be used with --rekor-server")
+	}
+
+	verifier, err := signer.Verifier()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get verifier for rekor: %w", err)
+	}
+
+	publicKey, err := verifier.Bytes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode public key for rekor: %w", err)
+	}
+
+	return publicKey, nil
+}
+
+// uploadRekor uploads env to the Rekor log with publicKey, and returns the location of its log entry once it's
+// verified to be signed by the log
+func (r *runner) uploadRekor(ctx context.Context, env dsse.Envelope, publicKey []byte) (string, error) {
+	server, err := transport.ResolveURL(r.ro.RekorOptions.Server)
+	if err != nil {
+		return "", err
+	}
+
+	entry, err := rekor.New(server, r.rekorLogKey).Upload(ctx, env, publicKey)
+	if err != nil {
+		return "", err
+	}
+
+	r.logger.Infof("Uploaded to %v at log index %v", r.ro.RekorOptions.Server, entry.LogIndex)
+	return rekor.New(r.ro.RekorOptions.Server, r.rekorLogKey).EntryURL(entry.UUID), nil
+}
@@ -0,0 +1,1250 @@
+// Copyright 2021 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package runner runs a command under witness: it loads signers, runs attestors around the command, signs the
+// collection they record, and stores the signed envelope. It lets Go programs embed witness run without shelling
+// out to the CLI. Errors are returned rather than logged, and nothing is written to stdout.
+package runner
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	witness "github.com/testifysec/go-witness"
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/attestation/commandrun"
+	"github.com/testifysec/go-witness/attestation/material"
+	"github.com/testifysec/go-witness/attestation/product"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/go-witness/timestamp"
+	"github.com/testifysec/witness/attestation/annotations"
+	"github.com/testifysec/witness/attestation/bazel"
+	"github.com/testifysec/witness/attestation/buildcache"
+	"github.com/testifysec/witness/attestation/buildkit"
+	"github.com/testifysec/witness/attestation/checksums"
+	containerexecattestor "github.com/testifysec/witness/attestation/containerexec"
+	"github.com/testifysec/witness/attestation/dirhash"
+	"github.com/testifysec/witness/attestation/files"
+	"github.com/testifysec/witness/attestation/network"
+	"github.com/testifysec/witness/attestation/nix"
+	"github.com/testifysec/witness/attestation/oidc"
+	"github.com/testifysec/witness/attestation/packages"
+	"github.com/testifysec/witness/attestation/processtree"
+	"github.com/testifysec/witness/attestation/runas"
+	"github.com/testifysec/witness/attestation/scai"
+	"github.com/testifysec/witness/attestation/timesource"
+	"github.com/testifysec/witness/attestation/tools"
+	"github.com/testifysec/witness/attestation/tracing"
+	"github.com/testifysec/witness/attestation/vex"
+	witnessattestor "github.com/testifysec/witness/attestation/witness"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/capsule"
+	"github.com/testifysec/witness/pkg/containerexec"
+	"github.com/testifysec/witness/pkg/detached"
+	"github.com/testifysec/witness/pkg/disclosure"
+	"github.com/testifysec/witness/pkg/ebpftrace"
+	"github.com/testifysec/witness/pkg/envshim"
+	"github.com/testifysec/witness/pkg/filehash"
+	"github.com/testifysec/witness/pkg/ghattest"
+	"github.com/testifysec/witness/pkg/localstore"
+	"github.com/testifysec/witness/pkg/privdrop"
+	"github.com/testifysec/witness/pkg/ptyshim"
+	"github.com/testifysec/witness/pkg/runhook"
+	"github.com/testifysec/witness/pkg/spool"
+)
+
+const (
+	storeFailurePolicyFail       = "fail"
+	storeFailurePolicyWarn       = "warn"
+	storeFailurePolicyRetryLater = "retry-later"
+
+	storeRequireAll    = "all"
+	storeRequireAny    = "any"
+	storeRequireQuorum = "quorum"
+
+	traceBackendPtrace = "ptrace"
+	traceBackendEBPF   = "ebpf"
+
+	// commandStopGrace is how long the command has to exit after it's asked to terminate before it's killed
+	commandStopGrace = 10 * time.Second
+)
+
+// Result is the outcome of a run.
+type Result struct {
+	witness.RunResult
+	// Storage lists where the signed envelope was written or stored, in the order it was stored
+	Storage []string
+}
+
+type runner struct {
+	ro     options.RunOptions
+	fips   bool
+	logger log.Logger
+	// runAs is the identity the command is run as, or nil to run it as the current user
+	runAs *privdrop.Identity
+	// envOverrides change the environment the command is run with
+	envOverrides envshim.Overrides
+	// tracer traces the command with eBPF instead of ptrace, or is nil
+	tracer *ebpftrace.Tracer
+	// container is the container the command is run in, or nil to run it on the host
+	container *containerexec.Container
+	// rekorLogKey is the key entries from the Rekor log must be signed by
+	rekorLogKey crypto.PublicKey
+	// rekorKey is the public key Rekor verifies the envelope's signature with, or nil without a Rekor server
+	rekorKey []byte
+	// signers sign the envelope
+	signers []cryptoutil.Signer
+	// timestampers timestamp the envelope's signatures
+	timestampers []dsse.Timestamper
+	// annotations are added to the collection by the annotations attestor
+	annotations map[string]string
+	// capturedAt replaces the time a capsule was captured at in deterministic mode, or is zero
+	capturedAt time.Time
+	// targets are the backends the signed envelope is stored in
+	targets []storeTarget
+	// queueOpts are applied to uploads queued for later
+	queueOpts []spool.EnqueueOption
+	// scittPath is where the statement registered with a SCITT transparency service is written
+	scittPath string
+}
+
+type Option func(*runner)
+
+// WithFIPS rejects signers that don't use algorithms approved for FIPS 140.
+func WithFIPS(fips bool) Option {
+	return func(r *runner) {
+		r.fips = fips
+	}
+}
+
+// WithLogger sets the logger progress and warnings are reported to. Nothing is logged by default.
+func WithLogger(logger log.Logger) Option {
+	return func(r *runner) {
+		r.logger = logger
+	}
+}
+
+// Run runs the command in args, or signs the capsule in ro.AttestFromCapsule, and records the attestations requested
+// in ro. The signed envelope is uploaded to Rekor and written to ro.OutFilePath if they're set, then stored in
+// Archivist and GitHub and registered with a SCITT transparency service as configured. On error, Result holds whatever completed before the run failed.
+func Run(ctx context.Context, ro options.RunOptions, args []string, opts ...Option) (Result, error) {
+	r := &runner{
+		ro:     ro,
+		logger: log.SilentLogger{},
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r.run(ctx, args)
+}
+
+func (r *runner) run(ctx context.Context, args []string) (Result, error) {
+	result := Result{}
+	if err := r.prepare(ctx); err != nil {
+		return result, err
+	}
+
+	var out *os.File
+	if r.ro.OutFilePath != "" {
+		var err error
+		if out, err = os.Create(r.ro.OutFilePath); err != nil {
+			return result, fmt.Errorf("failed to create out file: %w", err)
+		}
+
+		defer out.Close()
+	}
+
+	if r.ro.AttestFromCapsule != "" {
+		env, err := r.attestCapsule(args)
+		if err != nil {
+			return result, err
+		}
+
+		result.SignedEnvelope = env
+	} else {
+		attestors, err := r.prepareAttestors(args)
+		if r.tracer != nil {
+			defer r.tracer.Close()
+		}
+
+		if err != nil {
+			return result, err
+		}
+
+		if result.RunResult, err = r.execute(ctx, args, attestors); err != nil {
+			return result, err
+		}
+	}
+
+	return result, r.store(ctx, out, &result)
+}
+
+// prepare loads the signers and checks the run's options, so a run that can't be signed or stored fails before
+// the command is started
+func (r *runner) prepare(ctx context.Context) error {
+	ro := r.ro
+	signers, errs := LoadSigners(ctx, ro.KeyOptions, r.fips)
+	if len(errs) > 0 {
+		msgs := make([]string, 0, len(errs))
+		for _, err := range errs {
+			msgs = append(msgs, err.Error())
+		}
+
+		return fmt.Errorf("failed to load signers: %v", strings.Join(msgs, "; "))
+	}
+
+	if ro.EphemeralSigner {
+		if len(signers) > 0 {
+			return fmt.Errorf("--signer-ephemeral can't be used with another signer")
+		}
+
+		if ro.RekorOptions.Server == "" {
+			return fmt.Errorf("--signer-ephemeral requires --rekor-server, since only the log binds the ephemeral key to the attestation")
+		}
+
+		signer, err := EphemeralSigner()
+		if err != nil {
+			return err
+		}
+
+		signers = append(signers, signer)
+	}
+
+	if len(signers) > 1 {
+		return fmt.Errorf("only one signer is supported")
+	}
+
+	if len(signers) == 0 {
+		return fmt.Errorf("no signers found")
+	}
+
+	var err error
+	if r.signers, err = EnvelopeSigners(ro.KeyOptions, signers[0], r.fips); err != nil {
+		return err
+	}
+
+	if r.rekorKey, err = r.rekorPublicKey(signers[0]); err != nil {
+		return err
+	}
+
+	switch ro.StoreFailurePolicy {
+	case "", storeFailurePolicyFail, storeFailurePolicyWarn, storeFailurePolicyRetryLater:
+	default:
+		return fmt.Errorf("unknown store failure policy %v", ro.StoreFailurePolicy)
+	}
+
+	switch ro.StoreRequirement {
+	case "", storeRequireAll, storeRequireAny, storeRequireQuorum:
+	default:
+		return fmt.Errorf("unknown store requirement %v, expected %v, %v, or %v", ro.StoreRequirement, storeRequireAll, storeRequireAny, storeRequireQuorum)
+	}
+
+	switch filehash.SymlinkMode(ro.Symlinks) {
+	case "", filehash.SymlinkFollow, filehash.SymlinkRecord, filehash.SymlinkSkip:
+	default:
+		return fmt.Errorf("unknown symlink mode %v", ro.Symlinks)
+	}
+
+	switch filehash.EscapeMode(ro.EscapingSymlinks) {
+	case "", filehash.EscapeRecord, filehash.EscapeFollow, filehash.EscapeError:
+	default:
+		return fmt.Errorf("unknown escaping symlink mode %v", ro.EscapingSymlinks)
+	}
+
+	now := time.Now().UTC()
+	if ro.Deterministic {
+		if len(ro.TimestampServers) > 0 {
+			return fmt.Errorf("timestamp servers cannot be used in deterministic mode")
+		}
+
+		if now, err = deterministicTime(); err != nil {
+			return err
+		}
+
+		r.capturedAt = now
+	}
+
+	annotationPairs := ro.Annotations
+	if ro.Retention > 0 {
+		expiresAt := now.Add(ro.Retention)
+		annotationPairs = append(append([]string{}, ro.Annotations...), fmt.Sprintf("%v=%v", annotations.ExpiresAtKey, expiresAt.Format(time.RFC3339)))
+		r.queueOpts = append(r.queueOpts, spool.WithExpiry(expiresAt))
+	}
+
+	if r.annotations, err = annotations.Parse(annotationPairs); err != nil {
+		return err
+	}
+
+	if ro.User != "" || ro.Group != "" {
+		id, err := privdrop.Lookup(ro.User, ro.Group)
+		if err != nil {
+			return err
+		}
+
+		r.runAs = &id
+	}
+
+	r.envOverrides = envshim.Overrides{Clean: ro.CleanEnv}
+	if ro.EnvFile != "" {
+		if r.envOverrides.Vars, err = envshim.ReadFile(ro.EnvFile); err != nil {
+			return err
+		}
+	}
+
+	if err := envshim.ParseVars(ro.Env); err != nil {
+		return err
+	}
+
+	r.envOverrides.Vars = append(r.envOverrides.Vars, ro.Env...)
+	if r.targets, err = storeTargets(ro); err != nil {
+		return err
+	}
+
+	if r.scittPath, err = scittStatementPath(ro); err != nil {
+		return err
+	}
+
+	for _, url := range ro.TimestampServers {
+		r.timestampers = append(r.timestampers, timestamp.NewTimestamper(timestamp.TimestampWithUrl(url)))
+	}
+
+	if ro.ToolsTraced && !ro.Tracing {
+		return fmt.Errorf("--attestor-tools-traced requires --trace")
+	}
+
+	switch ro.TraceBackend {
+	case "", traceBackendPtrace, traceBackendEBPF:
+	default:
+		return fmt.Errorf("unknown trace backend %q, expected %v or %v", ro.TraceBackend, traceBackendPtrace, traceBackendEBPF)
+	}
+
+	if ro.TraceBackend == traceBackendEBPF && !ro.Tracing {
+		return fmt.Errorf("--trace-backend %v requires --trace", traceBackendEBPF)
+	}
+
+	if ro.TraceSampleRate < 0 {
+		return fmt.Errorf("--trace-sample-rate must not be negative")
+	}
+
+	if ro.TraceMaxFiles < 0 {
+		return fmt.Errorf("--trace-max-files must not be negative")
+	}
+
+	if ro.InContainer == "" && len(ro.ContainerMounts) > 0 {
+		return fmt.Errorf("--container-mount requires --in-container")
+	}
+
+	// the container's processes are started by the container runtime's daemon, not by witness
+	if ro.InContainer != "" && ro.Tracing {
+		return fmt.Errorf("--trace can't trace a command run with --in-container")
+	}
+
+	if ro.InContainer != "" && ro.TTY {
+		return fmt.Errorf("--tty can't be used with --in-container, the command's terminal is set up by the container runtime")
+	}
+
+	if ro.InContainer != "" && r.runAs != nil {
+		return fmt.Errorf("--user and --group can't be used with --in-container, the command runs as the image's user")
+	}
+
+	if ro.Disclosable && ro.DetachPredicatePath == "" {
+		return fmt.Errorf("--disclosable requires --detach-predicate")
+	}
+
+	return nil
+}
+
+// attestCapsule signs the capsule in ro.AttestFromCapsule. Nothing is run, so options that only apply to a command
+// are rejected.
+func (r *runner) attestCapsule(args []string) (dsse.Envelope, error) {
+	if len(args) > 0 {
+		return dsse.Envelope{}, fmt.Errorf("a command cannot be run when attesting from a capsule")
+	}
+
+	if len(r.annotations) > 0 {
+		return dsse.Envelope{}, fmt.Errorf("annotations cannot be added when attesting from a capsule")
+	}
+
+	if r.runAs != nil {
+		return dsse.Envelope{}, fmt.Errorf("a user or group cannot be set when attesting from a capsule")
+	}
+
+	if !r.envOverrides.Empty() {
+		return dsse.Envelope{}, fmt.Errorf("the command's environment cannot be set when attesting from a capsule")
+	}
+
+	if r.ro.DetachPredicatePath != "" {
+		return dsse.Envelope{}, fmt.Errorf("the predicate cannot be detached when attesting from a capsule")
+	}
+
+	return r.signCapsule(r.ro.AttestFromCapsule, r.signers, r.timestampers)
+}
+
+// prepareAttestors creates the attestors the run's options ask for, along with any the options imply, such as the
+// runas attestor when the command runs as another user. The eBPF tracer is left in r.tracer for the caller to close.
+func (r *runner) prepareAttestors(args []string) ([]attestation.Attestor, error) {
+	ro := r.ro
+	specs, err := runhook.ParseSpecs(ro.Attestations)
+	if err != nil {
+		return nil, err
+	}
+
+	specs = withWitnessAttestor(specs)
+	configured := configuredAttestors{}
+	if len(ro.DirSubjects) > 0 {
+		configured.add(dirhash.Name, dirhash.Type, func() attestation.Attestor {
+			return dirhash.New(dirhash.WithDirectories(ro.DirSubjects))
+		})
+
+		specs = append(specs, runhook.Spec{Attestor: dirhash.Name})
+	}
+
+	if len(ro.BuildKitDirs) > 0 {
+		configured.add(buildkit.Name, buildkit.Type, func() attestation.Attestor {
+			return buildkit.New(buildkit.WithDirectories(ro.BuildKitDirs))
+		})
+
+		specs = append(specs, runhook.Spec{Attestor: buildkit.Name})
+	}
+
+	if len(r.annotations) > 0 {
+		configured.add(annotations.Name, annotations.Type, func() attestation.Attestor {
+			return annotations.New(annotations.WithAnnotations(r.annotations))
+		})
+
+		if !hasAttestor(specs, annotations.Name, annotations.Type) {
+			specs = append(specs, runhook.Spec{Attestor: annotations.Name})
+		}
+	}
+
+	if r.runAs != nil {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("a command is required to run as another user or group")
+		}
+
+		id := *r.runAs
+		configured.add(runas.Name, runas.Type, func() attestation.Attestor {
+			return runas.New(runas.WithIdentity(id))
+		})
+
+		if !hasAttestor(specs, runas.Name, runas.Type) {
+			specs = append(specs, runhook.Spec{Attestor: runas.Name})
+		}
+	}
+
+	if ro.InContainer != "" {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("a command is required to run in a container")
+		}
+
+		container, err := r.containerFor(ro)
+		if err != nil {
+			return nil, err
+		}
+
+		r.container = &container
+		configured.add(containerexecattestor.Name, containerexecattestor.Type, func() attestation.Attestor {
+			return containerexecattestor.New(containerexecattestor.WithContainer(container, args))
+		})
+
+		if !hasAttestor(specs, containerexecattestor.Name, containerexecattestor.Type) {
+			specs = append(specs, runhook.Spec{Attestor: containerexecattestor.Name})
+		}
+	}
+
+	if len(ro.TimestampServers) > 0 {
+		configured.add(timesource.Name, timesource.Type, func() attestation.Attestor {
+			return timesource.New(timesource.WithTimestampServers(ro.TimestampServers))
+		})
+	}
+
+	if ro.PackageAttestDir != "" && !hasAttestor(specs, packages.Name, packages.Type) {
+		specs = append(specs, runhook.Spec{Attestor: packages.Name})
+	}
+
+	if ro.ScaiAttributesPath != "" {
+		configured.add(scai.Name, scai.Type, func() attestation.Attestor {
+			return scai.New(scai.WithAttributesFile(ro.ScaiAttributesPath))
+		})
+
+		if !hasAttestor(specs, scai.Name, scai.Type) {
+			specs = append(specs, runhook.Spec{Attestor: scai.Name})
+		}
+	}
+
+	if len(ro.VEXDocuments) > 0 {
+		configured.add(vex.Name, vex.Type, func() attestation.Attestor {
+			return vex.New(vex.WithDocuments(ro.VEXDocuments))
+		})
+
+		if !hasAttestor(specs, vex.Name, vex.Type) {
+			specs = append(specs, runhook.Spec{Attestor: vex.Name})
+		}
+	}
+
+	if len(ro.ChecksumFiles) > 0 {
+		configured.add(checksums.Name, checksums.Type, func() attestation.Attestor {
+			return checksums.New(checksums.WithFiles(ro.ChecksumFiles))
+		})
+
+		if !hasAttestor(specs, checksums.Name, checksums.Type) {
+			specs = append(specs, runhook.Spec{Attestor: checksums.Name})
+		}
+	}
+
+	if len(ro.BuildCacheLogs) > 0 {
+		logs, err := buildcache.ParseLogSpecs(ro.BuildCacheLogs)
+		if err != nil {
+			return nil, err
+		}
+
+		configured.add(buildcache.Name, buildcache.Type, func() attestation.Attestor {
+			return buildcache.New(buildcache.WithLogs(logs))
+		})
+
+		if !hasAttestor(specs, buildcache.Name, buildcache.Type) {
+			specs = append(specs, runhook.Spec{Attestor: buildcache.Name})
+		}
+	}
+
+	if ro.BazelBEPPath != "" || ro.BazelExecutionLog != "" {
+		configured.add(bazel.Name, bazel.Type, func() attestation.Attestor {
+			return bazel.New(bazel.WithBuildEventFile(ro.BazelBEPPath), bazel.WithExecutionLog(ro.BazelExecutionLog))
+		})
+
+		if !hasAttestor(specs, bazel.Name, bazel.Type) {
+			specs = append(specs, runhook.Spec{Attestor: bazel.Name})
+		}
+	}
+
+	if len(args) > 0 {
+		configured.add(nix.Name, nix.Type, func() attestation.Attestor {
+			return nix.New(nix.WithCommand(args))
+		})
+
+		// nix builds are recognized from the command so their store paths are recorded without asking
+		if nix.IsBuildCommand(args) && !hasAttestor(specs, nix.Name, nix.Type) {
+			specs = append(specs, runhook.Spec{Attestor: nix.Name})
+		}
+	}
+
+	configured.add(oidc.Name, oidc.Type, func() attestation.Attestor {
+		return oidc.New(oidc.WithAudience(ro.OIDCAudience), oidc.WithTokenEnv(ro.OIDCTokenEnv), oidc.WithTokenFile(ro.OIDCTokenFile), oidc.WithIssuer(ro.OIDCIssuer))
+	})
+
+	if (ro.OIDCTokenEnv != "" || ro.OIDCTokenFile != "") && ro.OIDCIssuer == "" {
+		return nil, fmt.Errorf("--attestor-oidc-token-env and --attestor-oidc-token-file require --attestor-oidc-issuer, since anyone can issue a token")
+	}
+
+	if (ro.OIDCTokenEnv != "" || ro.OIDCTokenFile != "") && !hasAttestor(specs, oidc.Name, oidc.Type) {
+		specs = append(specs, runhook.Spec{Attestor: oidc.Name})
+	}
+
+	if len(args) > 0 {
+		searchPath := r.envOverrides.SearchPath()
+		configured.add(tools.Name, tools.Type, func() attestation.Attestor {
+			return tools.New(tools.WithCommand(args), tools.WithSearchPath(searchPath), tools.WithTracedExecutables(ro.ToolsTraced))
+		})
+
+		if ro.ToolsTraced && !hasAttestor(specs, tools.Name, tools.Type) {
+			specs = append(specs, runhook.Spec{Attestor: tools.Name})
+		}
+	}
+
+	if len(args) > 0 && ro.Tracing && ro.TraceBackend == traceBackendEBPF {
+		tracer, err := ebpftrace.New(ebpftrace.WithSampleRate(ro.TraceSampleRate), ebpftrace.WithMaxFiles(ro.TraceMaxFiles))
+		if err != nil {
+			r.logger.Warnf("eBPF tracing is unavailable, falling back to ptrace: %v", err)
+		} else {
+			r.tracer = tracer
+			configured.add(network.Name, network.Type, func() attestation.Attestor {
+				return network.New(network.WithSource(tracer))
+			})
+
+			if !hasAttestor(specs, network.Name, network.Type) {
+				specs = append(specs, runhook.Spec{Attestor: network.Name})
+			}
+		}
+	}
+
+	if len(args) > 0 && ro.Tracing {
+		tracingOpts := []tracing.Option{tracing.WithBackend(traceBackendPtrace)}
+		if r.tracer != nil {
+			tracingOpts = []tracing.Option{
+				tracing.WithBackend(traceBackendEBPF),
+				tracing.WithStats(r.tracer),
+				tracing.WithMaxFiles(ro.TraceMaxFiles),
+			}
+
+			if ro.TraceSampleRate > 1 {
+				tracingOpts = append(tracingOpts, tracing.WithSampleRate(ro.TraceSampleRate))
+			}
+		} else if ro.TraceSampleRate > 1 || ro.TraceMaxFiles > 0 {
+			r.logger.Warnf("--trace-sample-rate and --trace-max-files are ignored by the %v trace backend", traceBackendPtrace)
+		}
+
+		configured.add(tracing.Name, tracing.Type, func() attestation.Attestor {
+			return tracing.New(tracingOpts...)
+		})
+
+		// the cost and completeness of the trace are recorded whenever the command is traced
+		if !hasAttestor(specs, tracing.Name, tracing.Type) {
+			specs = append(specs, runhook.Spec{Attestor: tracing.Name})
+		}
+	}
+
+	if hasAttestor(specs, tracing.Name, tracing.Type) && !ro.Tracing {
+		return nil, fmt.Errorf("the %v attestor requires --trace", tracing.Name)
+	}
+
+	if hasAttestor(specs, processtree.Name, processtree.Type) && !ro.Tracing {
+		return nil, fmt.Errorf("the %v attestor requires --trace", processtree.Name)
+	}
+
+	return attestorsFromSpecs(specs, configured)
+}
+
+// execute runs attestors around the command in args and signs the collection they record, then writes the
+// outputs made from the collection: the capsule and the package publish attestations.
+func (r *runner) execute(ctx context.Context, args []string, attestors []attestation.Attestor) (witness.RunResult, error) {
+	result, err := r.runAttestors(ctx, r.signers, args, attestors, r.timestampers)
+	if err != nil {
+		return result, err
+	}
+
+	if r.ro.CapsulePath != "" {
+		if err := writeCapsule(r.ro.CapsulePath, result.Collection, r.capturedAt); err != nil {
+			return result, fmt.Errorf("failed to write capsule: %w", err)
+		}
+	}
+
+	if r.ro.PackageAttestDir != "" {
+		if err := r.writePublishAttestations(result.Collection, r.signers, r.timestampers); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// store uploads the signed envelope to Rekor, writes it to out, saves it in the local store, stores it in every
+// configured backend, and registers it with a SCITT transparency service. Where it ends up is added to
+// result.Storage as each step succeeds.
+func (r *runner) store(ctx context.Context, out *os.File, result *Result) error {
+	ro := r.ro
+	if ro.RekorOptions.Server != "" {
+		location, err := r.uploadRekor(ctx, result.SignedEnvelope, r.rekorKey)
+		if err != nil {
+			// nothing but the log entry binds an ephemeral key to the envelope, so its upload can't only warn
+			err = fmt.Errorf("failed to upload to rekor: %w", err)
+			if ro.EphemeralSigner || ro.StoreFailurePolicy == "" || ro.StoreFailurePolicy == storeFailurePolicyFail {
+				return err
+			}
+
+			r.logger.Warnf("%v", err)
+		} else {
+			result.Storage = append(result.Storage, location)
+		}
+	}
+
+	if out != nil {
+		signedBytes, err := json.Marshal(&result.SignedEnvelope)
+		if err != nil {
+			return fmt.Errorf("failed to marshal envelope: %w", err)
+		}
+
+		if _, err := out.Write(signedBytes); err != nil {
+			return fmt.Errorf("failed to write envelope to out file: %w", err)
+		}
+
+		result.Storage = append(result.Storage, ro.OutFilePath)
+	}
+
+	if ro.LocalStoreOptions.Enable {
+		store, err := localstore.Open(ro.LocalStoreOptions.StoreDirOptions.Dir)
+		if err != nil {
+			return err
+		}
+
+		gitoid, err := store.Put(result.SignedEnvelope)
+		if err != nil {
+			return fmt.Errorf("failed to save envelope in local store: %w", err)
+		}
+
+		r.logger.Infof("Saved in local store %v as %v", store.Dir(), gitoid)
+	}
+
+	if ro.AsyncUpload {
+		for _, target := range r.targets {
+			if err := r.queueUpload(target, result.SignedEnvelope, r.queueOpts...); err != nil {
+				return err
+			}
+		}
+	} else {
+		locations, err := r.storeEnvelope(ctx, r.targets, result.SignedEnvelope, r.queueOpts...)
+		result.Storage = append(result.Storage, locations...)
+		if err != nil {
+			return err
+		}
+	}
+
+	if ro.SCITTOptions.Server != "" {
+		location, err := r.registerSCITT(ctx, result.SignedEnvelope, r.scittPath)
+		if err != nil {
+			// registrations can't be queued for witness flush, so any policy but fail only warns
+			err = fmt.Errorf("failed to register with scitt transparency service: %w", err)
+			if ro.StoreFailurePolicy == "" || ro.StoreFailurePolicy == storeFailurePolicyFail {
+				return err
+			}
+
+			r.logger.Warnf("%v", err)
+		} else {
+			result.Storage = append(result.Storage, location, r.scittPath)
+		}
+	}
+
+	return nil
+}
+
+// configuredAttestors holds factories for attestors configured by the run's options. They're used in place of the
+// factories the attestor packages register, since that registry is shared by every run in the process.
+type configuredAttestors []configuredAttestor
+
+type configuredAttestor struct {
+	name    string
+	typ     string
+	factory attestation.AttestorFactory
+}
+
+func (c *configuredAttestors) add(name, typ string, factory attestation.AttestorFactory) {
+	*c = append(*c, configuredAttestor{name: name, typ: typ, factory: factory})
+}
+
+func (c configuredAttestors) factory(nameOrType string) (attestation.AttestorFactory, bool) {
+	for _, attestor := range c {
+		if attestor.name == nameOrType || attestor.typ == nameOrType {
+			return attestor.factory, true
+		}
+	}
+
+	return nil, false
+}
+
+// attestorsFromSpecs creates the requested attestors, moving any that were requested with a run type to that phase.
+// The material and product attestors always run around a command, so requesting them only checks the phase.
+func attestorsFromSpecs(specs []runhook.Spec, configured configuredAttestors) ([]attestation.Attestor, error) {
+	attestors := []attestation.Attestor{}
+	for _, spec := range specs {
+		switch spec.Attestor {
+		case material.Name, material.Type:
+			if spec.RunType != "" && spec.RunType != attestation.PreRunType {
+				return nil, fmt.Errorf("the material attestor always runs before the command")
+			}
+
+			continue
+		case product.Name, product.Type:
+			if spec.RunType != "" && spec.RunType != attestation.PostRunType {
+				return nil, fmt.Errorf("the product attestor always runs after the command")
+			}
+
+			continue
+		}
+
+		if factory, ok := configured.factory(spec.Attestor); ok {
+			attestors = append(attestors, runhook.WithRunType(factory(), spec.RunType))
+			continue
+		}
+
+		created, err := attestation.Attestors([]string{spec.Attestor})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get attestors: %w", err)
+		}
+
+		attestors = append(attestors, runhook.WithRunType(created[0], spec.RunType))
+	}
+
+	return attestors, nil
+}
+
+// runAttestors runs attestors around the command in args and signs the collection they record. Attestors implementing
+// the runhook command hooks are called immediately before and after the command, which is stopped if ctx is canceled.
+func (r *runner) runAttestors(ctx context.Context, signers []cryptoutil.Signer, args []string, attestors []attestation.Attestor, timestampers []dsse.Timestamper) (witness.RunResult, error) {
+	ro := r.ro
+	result := witness.RunResult{}
+	if ro.StepName == "" {
+		return result, fmt.Errorf("step name is required")
+	}
+
+	var hashCache *filehash.Cache
+	opts := []attestation.AttestationContextOption{attestation.WithContext(ctx), attestation.WithWorkingDir(ro.WorkingDir)}
+	if len(args) > 0 {
+		hashOpts := []filehash.Option{filehash.WithWorkers(ro.HashWorkers)}
+		if ro.Symlinks != "" {
+			hashOpts = append(hashOpts, filehash.WithSymlinks(filehash.SymlinkMode(ro.Symlinks)))
+		}
+
+		if ro.EscapingSymlinks != "" {
+			hashOpts = append(hashOpts, filehash.WithEscapingSymlinks(filehash.EscapeMode(ro.EscapingSymlinks)))
+		}
+
+		if ro.Gitignore {
+			hashOpts = append(hashOpts, filehash.WithIgnoreFiles(filehash.GitIgnoreFile, filehash.WitnessIgnoreFile))
+		}
+
+		if ro.NormalizeLineEndings {
+			hashOpts = append(hashOpts, filehash.WithNormalizedLineEndings())
+		}
+
+		if ro.HashCacheDir != "" {
+			cachePath, err := hashCachePath(ro.HashCacheDir, ro.WorkingDir)
+			if err != nil {
+				return result, err
+			}
+
+			hashCache = filehash.LoadCache(cachePath)
+			hashOpts = append(hashOpts, filehash.WithCache(hashCache))
+		}
+
+		cmdArgs := args
+		if r.container != nil {
+			cmdArgs = r.container.Command(args)
+		}
+
+		if r.runAs != nil {
+			var err error
+			if cmdArgs, err = privdrop.Command(*r.runAs, args); err != nil {
+				return result, err
+			}
+		}
+
+		// the environment is set outside the privilege dropping shim, so it can still set HOME and USER. In a
+		// container, it's set for the runtime, which passes it on to the container without cleaning its own.
+		envOverrides := r.envOverrides
+		if r.container != nil {
+			envOverrides.Clean = false
+		}
+
+		if !envOverrides.Empty() {
+			var cleanup func()
+			var err error
+			if cmdArgs, cleanup, err = envshim.Command(envOverrides, cmdArgs); err != nil {
+				return result, err
+			}
+
+			defer cleanup()
+		}
+
+		// the pseudo-terminal is opened outside the other shims, which replace themselves with the command on it
+		if ro.TTY {
+			var err error
+			if cmdArgs, err = ptyshim.Command(cmdArgs); err != nil {
+				return result, err
+			}
+		}
+
+		command := commandrun.New(commandrun.WithCommand(cmdArgs), commandrun.WithTracing(ro.Tracing && r.tracer == nil))
+		commandAttestor := runhook.WithCancellation(command, commandStopGrace)
+		if r.tracer != nil {
+			r.tracer.SetCommand(command)
+			commandAttestor = runhook.WithHooks(commandAttestor, r.tracer)
+		}
+
+		opts = append(opts,
+			attestation.WithCommandAttestor(runhook.WithCommandHooks(commandAttestor, attestors)),
+			attestation.WithMaterialAttestor(files.NewMaterial(hashOpts...)),
+			attestation.WithProductAttestor(files.NewProduct(hashOpts...)),
+		)
+	}
+
+	runCtx, err := attestation.NewContext(attestors, opts...)
+	if err != nil {
+		return result, fmt.Errorf("failed to create attestation context: %w", err)
+	}
+
+	if err := runCtx.RunAttestors(); err != nil {
+		return result, fmt.Errorf("failed to run attestors: %w", err)
+	}
+
+	if err := hashCache.Save(); err != nil {
+		r.logger.Warnf("failed to save hash cache: %v", err)
+	}
+
+	completed := runCtx.CompletedAttestors()
+	for i, attestor := range completed {
+		completed[i] = runhook.Unwrap(attestor)
+		// record the command that was asked for rather than the shims that ran it
+		if cr, ok := completed[i].(*commandrun.CommandRun); ok && (r.runAs != nil || !r.envOverrides.Empty() || ro.TTY) {
+			cr.Cmd = args
+		}
+	}
+
+	if ro.Deterministic {
+		now, err := deterministicTime()
+		if err != nil {
+			return result, err
+		}
+
+		normalizeAttestors(completed, now)
+	}
+
+	result.Collection = attestation.NewCollection(ro.StepName, completed)
+	collectionBytes, err := json.Marshal(&result.Collection)
+	if err != nil {
+		return result, err
+	}
+
+	predicateType, predicate := attestation.CollectionType, json.RawMessage(collectionBytes)
+	if ro.DetachPredicatePath != "" {
+		if predicateType, predicate, err = detachPredicate(ro.DetachPredicatePath, ro.StepName, collectionBytes, ro.Disclosable); err != nil {
+			return result, err
+		}
+	}
+
+	stmt, err := intoto.NewStatement(predicateType, predicate, result.Collection.Subjects())
+	if err != nil {
+		return result, err
+	}
+
+	if ro.Deterministic {
+		sortSubjects(stmt.Subject)
+	}
+
+	stmtBytes, err := json.Marshal(&stmt)
+	if err != nil {
+		return result, err
+	}
+
+	result.SignedEnvelope, err = dsse.Sign(intoto.PayloadType, bytes.NewReader(stmtBytes), dsse.SignWithSigners(signers...), dsse.SignWithTimestampers(timestampers...))
+	if err != nil {
+		return result, fmt.Errorf("failed to sign collection: %w", err)
+	}
+
+	return result, nil
+}
+
+// detachPredicate writes the collection to path and returns the predicate that signs only its digest. If
+// disclosable, a disclosure bundle is written instead and the predicate signs the Merkle root of its fields.
+func detachPredicate(path, step string, collectionBytes []byte, disclosable bool) (string, json.RawMessage, error) {
+	if disclosable {
+		return disclosablePredicate(path, step, collectionBytes)
+	}
+
+	if err := os.WriteFile(path, collectionBytes, 0600); err != nil {
+		return "", nil, fmt.Errorf("failed to write detached predicate: %w", err)
+	}
+
+	link, err := detached.New(step, attestation.CollectionType, collectionBytes)
+	if err != nil {
+		return "", nil, err
+	}
+
+	linkBytes, err := json.Marshal(link)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return detached.PredicateType, linkBytes, nil
+}
+
+func disclosablePredicate(path, step string, collectionBytes []byte) (string, json.RawMessage, error) {
+	bundle, err := disclosure.NewBundle(step, attestation.CollectionType, collectionBytes)
+	if err != nil {
+		return "", nil, err
+	}
+
+	signed, err := bundle.SignedPredicate()
+	if err != nil {
+		return "", nil, err
+	}
+
+	bundleBytes, err := json.Marshal(bundle)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if err := os.WriteFile(path, bundleBytes, 0600); err != nil {
+		return "", nil, fmt.Errorf("failed to write disclosure bundle: %w", err)
+	}
+
+	signedBytes, err := json.Marshal(signed)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return disclosure.PredicateType, signedBytes, nil
+}
+
+// hashCachePath returns the file in dir that caches digests for workingDir, so each workspace has its own cache
+func hashCachePath(dir, workingDir string) (string, error) {
+	absWorkingDir, err := filepath.Abs(workingDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve working directory: %w", err)
+	}
+
+	sum := sha256.Sum256([]byte(absWorkingDir))
+	return filepath.Join(dir, hex.EncodeToString(sum[:])+".json"), nil
+}
+
+// storeTarget is a backend that the signed envelope is uploaded to
+type storeTarget struct {
+	backend spool.Backend
+	server  string
+}
+
+// location returns where an envelope stored as ref can be downloaded from
+func (t storeTarget) location(ref string) string {
+	switch t.backend {
+	case spool.BackendArchivist:
+		location, err := url.JoinPath(t.server, "download", ref)
+		if err != nil {
+			return ""
+		}
+
+		return location
+	default:
+		return ""
+	}
+}
+
+func storeTargets(ro options.RunOptions) ([]storeTarget, error) {
+	targets := []storeTarget{}
+	if ro.ArchivistOptions.Enable {
+		for _, server := range ro.ArchivistOptions.Urls {
+			targets = append(targets, storeTarget{spool.BackendArchivist, server})
+		}
+	}
+
+	if ro.GitHubOptions.Repo != "" {
+		repoURL, err := ghattest.RepoURL(ro.GitHubOptions.APIURL, ro.GitHubOptions.Repo)
+		if err != nil {
+			return nil, err
+		}
+
+		targets = append(targets, storeTarget{spool.BackendGitHub, repoURL})
+	}
+
+	return targets, nil
+}
+
+// storeEnvelope uploads env to every target at once and returns where it can be downloaded from. If fewer uploads
+// succeeded than the store requirement asks for, the store failure policy decides what happens to each failed
+// upload. Otherwise failed uploads only warn, or are queued to retry later so every store eventually has a copy.
+func (r *runner) storeEnvelope(ctx context.Context, targets []storeTarget, env dsse.Envelope, opts ...spool.EnqueueOption) ([]string, error) {
+	refs := make([]string, len(targets))
+	errs := make([]error, len(targets))
+	wg := sync.WaitGroup{}
+	for i := range targets {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			refs[i], errs[i] = StoreEnvelope(ctx, targets[i].backend, targets[i].server, r.ro.GitHubOptions.Token, env)
+		}(i)
+	}
+
+	wg.Wait()
+	locations := []string{}
+	stored := 0
+	for i, target := range targets {
+		if errs[i] != nil {
+			continue
+		}
+
+		stored++
+		r.logger.Infof("Stored in %v %v as %v", target.backend, target.server, refs[i])
+		if location := target.location(refs[i]); location != "" {
+			locations = append(locations, location)
+		}
+	}
+
+	met := storeRequirementMet(r.ro.StoreRequirement, stored, len(targets))
+	for i, target := range targets {
+		if errs[i] == nil {
+			continue
+		}
+
+		storeErr := fmt.Errorf("failed to store artifact in %v %v: %w", target.backend, target.server, errs[i])
+		switch {
+		case !met:
+			if err := r.handleStoreFailure(target, env, storeErr, opts...); err != nil {
+				return locations, err
+			}
+		case r.ro.StoreFailurePolicy == storeFailurePolicyRetryLater:
+			r.logger.Warnf("%v, queueing upload to retry later with witness flush", storeErr)
+			if err := r.queueUpload(target, env, opts...); err != nil {
+				return locations, err
+			}
+		default:
+			r.logger.Warnf("%v, stored in %d of %d stores", storeErr, stored, len(targets))
+		}
+	}
+
+	return locations, nil
+}
+
+// storeRequirementMet is true if stored of total uploads succeeding satisfies requirement
+func storeRequirementMet(requirement string, stored, total int) bool {
+	switch requirement {
+	case storeRequireAny:
+		return stored > 0 || total == 0
+	case storeRequireQuorum:
+		return stored > total/2 || total == 0
+	default:
+		return stored == total
+	}
+}
+
+func (r *runner) queueUpload(target storeTarget, env dsse.Envelope, opts ...spool.EnqueueOption) error {
+	envSpool, err := spool.Open(r.ro.SpoolOptions.Dir)
+	if err != nil {
+		return err
+	}
+
+	entry, err := envSpool.Enqueue(target.backend, target.server, env, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to queue upload: %w", err)
+	}
+
+	r.logger.Infof("Queued %v upload %v in %v", target.backend, entry.ID, envSpool.Dir())
+	return nil
+}
+
+// handleStoreFailure applies the configured store failure policy to an upload error
+func (r *runner) handleStoreFailure(target storeTarget, env dsse.Envelope, storeErr error, opts ...spool.EnqueueOption) error {
+	switch r.ro.StoreFailurePolicy {
+	case storeFailurePolicyWarn:
+		r.logger.Warnf("%v", storeErr)
+		return nil
+	case storeFailurePolicyRetryLater:
+		r.logger.Warnf("%v, queueing upload to retry later with witness flush", storeErr)
+		return r.queueUpload(target, env, opts...)
+	default:
+		return storeErr
+	}
+}
+
+// writeCapsule saves the unsigned output of a run so it can be inspected or signed again later. A non-zero
+// capturedAt replaces the time the capsule was captured at
+func writeCapsule(path string, collection attestation.Collection, capturedAt time.Time) error {
+	c, err := capsule.New(collection)
+	if err != nil {
+		return err
+	}
+
+	if !capturedAt.IsZero() {
+		c.Metadata.CapturedAt = capturedAt
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+	return capsule.Write(f, c)
+}
+
+// signCapsule signs the statement captured in a capsule without running any attestors
+func (r *runner) signCapsule(path string, signers []cryptoutil.Signer, timestampers []dsse.Timestamper) (dsse.Envelope, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return dsse.Envelope{}, fmt.Errorf("failed to open capsule: %w", err)
+	}
+
+	defer f.Close()
+	c, err := capsule.Read(f)
+	if err != nil {
+		return dsse.Envelope{}, err
+	}
+
+	r.logger.Infof("Signing capsule for step %v captured at %v", c.Metadata.StepName, c.Metadata.CapturedAt)
+	return dsse.Sign(intoto.PayloadType, bytes.NewReader(c.Statement), dsse.SignWithSigners(signers...), dsse.SignWithTimestampers(timestampers...))
+}
+
+// withWitnessAttestor adds the witness attestor to attestors so every collection records the witness binary that produced it
+func withWitnessAttestor(attestors []runhook.Spec) []runhook.Spec {
+	if hasAttestor(attestors, witnessattestor.Name, witnessattestor.Type) {
+		return attestors
+	}
+
+	return append(append([]runhook.Spec{}, attestors...), runhook.Spec{Attestor: witnessattestor.Name})
+}
+
+// containerFor describes the container ro.InContainer runs the command in. The working directory is mounted at the
+// same path, so paths recorded by the material and product attestors are the same inside and outside the container.
+func (r *runner) containerFor(ro options.RunOptions) (containerexec.Container, error) {
+	workingDir, err := filepath.Abs(ro.WorkingDir)
+	if err != nil {
+		return containerexec.Container{}, fmt.Errorf("failed to resolve working directory: %w", err)
+	}
+
+	mounts, err := containerexec.ParseMounts(ro.ContainerMounts)
+	if err != nil {
+		return containerexec.Container{}, err
+	}
+
+	runtime := ro.ContainerRuntime
+	if runtime == "" {
+		runtime = "docker"
+	}
+
+	image, err := containerexec.Inspect(runtime, ro.InContainer)
+	if err != nil {
+		return containerexec.Container{}, err
+	}
+
+	container := containerexec.Container{
+		Runtime:    runtime,
+		Image:      image,
+		Mounts:     append([]containerexec.Mount{{Source: workingDir, Destination: workingDir}}, mounts...),
+		WorkingDir: workingDir,
+	}
+
+	seen := make(map[string]struct{})
+	for _, kv := range r.envOverrides.Vars {
+		key, _, _ := strings.Cut(kv, "=")
+		if _, ok := seen[key]; !ok {
+			seen[key] = struct{}{}
+			container.EnvKeys = append(container.EnvKeys, key)
+		}
+	}
+
+	return container, nil
+}
+
+// hasAttestor returns true if attestors requests the attestor with name or typ
+func hasAttestor(attestors []runhook.Spec, name, typ string) bool {
+	for _, attestor := range attestors {
+		if attestor.Attestor == name || attestor.Attestor == typ {
+			return true
+		}
+	}
+
+	return false
+}
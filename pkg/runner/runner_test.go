@@ -0,0 +1,275 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/witness/attestation/annotations"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/rekor/rekortest"
+	"github.com/testifysec/witness/pkg/runhook"
+)
+
+type recordingLogger struct {
+	warnings []string
+}
+
+func (l *recordingLogger) Errorf(format string, args ...interface{}) {}
+func (l *recordingLogger) Error(args ...interface{})                 {}
+func (l *recordingLogger) Warnf(format string, args ...interface{}) {
+	l.warnings = append(l.warnings, fmt.Sprintf(format, args...))
+}
+func (l *recordingLogger) Warn(args ...interface{})                  {}
+func (l *recordingLogger) Debugf(format string, args ...interface{}) {}
+func (l *recordingLogger) Debug(args ...interface{})                 {}
+func (l *recordingLogger) Infof(format string, args ...interface{})  {}
+func (l *recordingLogger) Info(args ...interface{})                  {}
+
+func writeKey(t *testing.T) string {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	keyBytes, err := x509.MarshalPKCS8PrivateKey(priv)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "key.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyBytes}), 0600))
+	return path
+}
+
+func TestRun(t *testing.T) {
+	workingDir := t.TempDir()
+	outPath := filepath.Join(t.TempDir(), "out.json")
+	ro := options.RunOptions{
+		KeyOptions:  options.KeyOptions{KeyPath: writeKey(t)},
+		WorkingDir:  workingDir,
+		OutFilePath: outPath,
+		StepName:    "build",
+	}
+
+	result, err := Run(context.Background(), ro, []string{"bash", "-c", "echo test > test.txt"})
+	require.NoError(t, err)
+	assert.Equal(t, "build", result.Collection.Name)
+	assert.Equal(t, []string{outPath}, result.Storage)
+	assert.Len(t, result.SignedEnvelope.Signatures, 1)
+
+	written := dsse.Envelope{}
+	outBytes, err := os.ReadFile(outPath)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(outBytes, &written))
+	assert.Equal(t, result.SignedEnvelope.Payload, written.Payload)
+
+	ro.OutFilePath = ""
+	result, err = Run(context.Background(), ro, []string{"true"})
+	require.NoError(t, err)
+	assert.Empty(t, result.Storage)
+
+	noKey := ro
+	noKey.KeyOptions = options.KeyOptions{}
+	_, err = Run(context.Background(), noKey, []string{"true"})
+	assert.Error(t, err)
+
+	noStep := ro
+	noStep.StepName = ""
+	_, err = Run(context.Background(), noStep, []string{"true"})
+	assert.Error(t, err)
+}
+
+func TestRunConcurrentOptions(t *testing.T) {
+	keyPath := writeKey(t)
+	results := make([]Result, 4)
+	errs := make([]error, len(results))
+	wg := sync.WaitGroup{}
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ro := options.RunOptions{
+				KeyOptions:  options.KeyOptions{KeyPath: keyPath},
+				WorkingDir:  t.TempDir(),
+				StepName:    "build",
+				Annotations: []string{fmt.Sprintf("run=%d", i)},
+			}
+
+			results[i], errs[i] = Run(context.Background(), ro, []string{"true"})
+		}(i)
+	}
+
+	wg.Wait()
+	for i, result := range results {
+		require.NoError(t, errs[i])
+		found := false
+		for _, collected := range result.Collection.Attestations {
+			if attestor, ok := runhook.Unwrap(collected.Attestation).(*annotations.Attestor); ok {
+				found = true
+				assert.Equal(t, map[string]string{"run": fmt.Sprint(i)}, attestor.Annotations)
+			}
+		}
+
+		assert.True(t, found)
+	}
+}
+
+func TestRunStoreFailureWarns(t *testing.T) {
+	logger := &recordingLogger{}
+	ro := options.RunOptions{
+		KeyOptions:         options.KeyOptions{KeyPath: writeKey(t)},
+		ArchivistOptions:   options.ArchivistOptions{Enable: true, Urls: []string{"http://127.0.0.1:1"}},
+		WorkingDir:         t.TempDir(),
+		StepName:           "build",
+		StoreFailurePolicy: storeFailurePolicyWarn,
+	}
+
+	result, err := Run(context.Background(), ro, []string{"true"}, WithLogger(logger))
+	require.NoError(t, err)
+	assert.NotEmpty(t, result.SignedEnvelope.Signatures)
+	assert.Len(t, logger.warnings, 1)
+
+	ro.StoreFailurePolicy = storeFailurePolicyFail
+	_, err = Run(context.Background(), ro, []string{"true"})
+	assert.Error(t, err)
+}
+
+func TestRunStoreRequirement(t *testing.T) {
+	uploads := int32(0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&uploads, 1)
+		fmt.Fprintf(w, `{"gitoid": "gitoid-%d"}`, n)
+	}))
+	defer server.Close()
+
+	down := "http://127.0.0.1:1"
+	ro := options.RunOptions{
+		KeyOptions:       options.KeyOptions{KeyPath: writeKey(t)},
+		ArchivistOptions: options.ArchivistOptions{Enable: true, Urls: []string{server.URL, server.URL + "/replica"}},
+		WorkingDir:       t.TempDir(),
+		StepName:         "build",
+	}
+
+	result, err := Run(context.Background(), ro, []string{"true"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&uploads))
+	assert.Len(t, result.Storage, 2)
+
+	cases := []struct {
+		requirement string
+		servers     []string
+		fails       bool
+	}{
+		{storeRequireAll, []string{server.URL, down}, true},
+		{storeRequireAny, []string{server.URL, down}, false},
+		{storeRequireAny, []string{down, down}, true},
+		{storeRequireQuorum, []string{server.URL, down}, true},
+		{storeRequireQuorum, []string{server.URL, server.URL, down}, false},
+	}
+
+	for _, c := range cases {
+		logger := &recordingLogger{}
+		ro.ArchivistOptions.Urls = c.servers
+		ro.StoreRequirement = c.requirement
+		result, err := Run(context.Background(), ro, []string{"true"}, WithLogger(logger))
+		if c.fails {
+			assert.Error(t, err, "%v of %v", c.requirement, c.servers)
+			continue
+		}
+
+		require.NoError(t, err, "%v of %v", c.requirement, c.servers)
+		assert.Len(t, logger.warnings, 1)
+		assert.Len(t, result.Storage, len(c.servers)-1)
+	}
+
+	ro.StoreRequirement = "most"
+	_, err = Run(context.Background(), ro, []string{"true"})
+	assert.Error(t, err)
+}
+
+func TestRunCanceled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	ro := options.RunOptions{
+		KeyOptions: options.KeyOptions{KeyPath: writeKey(t)},
+		WorkingDir: t.TempDir(),
+		StepName:   "build",
+	}
+
+	start := time.Now()
+	result, err := Run(ctx, ro, []string{"sleep", "30"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), commandStopGrace)
+	assert.Empty(t, result.SignedEnvelope.Signatures)
+}
+
+func TestRunEphemeralSigner(t *testing.T) {
+	server := rekortest.NewServer(t)
+	ro := options.RunOptions{
+		EphemeralSigner: true,
+		RekorOptions:    options.RekorOptions{Server: server.URL, PublicKeyPath: server.WritePublicKey(t)},
+		WorkingDir:      t.TempDir(),
+		StepName:        "build",
+	}
+
+	first, err := Run(context.Background(), ro, []string{"true"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{server.URL + "/api/v1/log/entries/" + rekortest.UUID(1)}, first.Storage)
+	second, err := Run(context.Background(), ro, []string{"true"})
+	require.NoError(t, err)
+	assert.NotEqual(t, first.SignedEnvelope.Signatures[0].KeyID, second.SignedEnvelope.Signatures[0].KeyID)
+
+	withKey := ro
+	withKey.KeyOptions = options.KeyOptions{KeyPath: writeKey(t)}
+	_, err = Run(context.Background(), withKey, []string{"true"})
+	assert.Error(t, err)
+
+	noRekor := ro
+	noRekor.RekorOptions = options.RekorOptions{}
+	_, err = Run(context.Background(), noRekor, []string{"true"})
+	assert.Error(t, err)
+
+	// the upload must succeed whatever the store failure policy, since nothing else binds the key
+	unreachable := ro
+	unreachable.RekorOptions.Server = "http://127.0.0.1:1"
+	unreachable.StoreFailurePolicy = storeFailurePolicyWarn
+	_, err = Run(context.Background(), unreachable, []string{"true"})
+	assert.Error(t, err)
+	assert.Equal(t, 2, server.Entries())
+
+	// entries that aren't signed by the log's key don't bind the ephemeral key either
+	untrusted := ro
+	untrusted.RekorOptions.PublicKeyPath = rekortest.NewServer(t).WritePublicKey(t)
+	untrusted.StoreFailurePolicy = storeFailurePolicyWarn
+	_, err = Run(context.Background(), untrusted, []string{"true"})
+	assert.ErrorContains(t, err, "signed entry timestamp wasn't signed by the log's key")
+
+	noLogKey := ro
+	noLogKey.RekorOptions.PublicKeyPath = ""
+	_, err = Run(context.Background(), noLogKey, []string{"true"})
+	assert.ErrorContains(t, err, "--rekor-public-key")
+}
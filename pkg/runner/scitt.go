@@ -0,0 +1,93 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"os"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/scitt"
+	"github.com/testifysec/witness/pkg/transport"
+)
+
+// scittStatementPath returns where the transparent statement of a run registered with a SCITT transparency service
+// is written, or an error if registration is enabled but can't be done
+func scittStatementPath(ro options.RunOptions) (string, error) {
+	if ro.SCITTOptions.Server == "" {
+		return "", nil
+	}
+
+	if ro.KeyOptions.KeyPath == "" {
+		return "", fmt.Errorf("scitt signed statements are signed with --key, which must be set")
+	}
+
+	if ro.SCITTOptions.StatementPath != "" {
+		return ro.SCITTOptions.StatementPath, nil
+	}
+
+	if ro.OutFilePath == "" {
+		return "", fmt.Errorf("--scitt-statement must be set when the envelope isn't written to an out file")
+	}
+
+	return ro.OutFilePath + ".scitt", nil
+}
+
+// registerSCITT registers env with the SCITT transparency service as a signed statement, and writes the statement
+// with the service's receipt to statementPath. It returns the location of the statement's log entry.
+func (r *runner) registerSCITT(ctx context.Context, env dsse.Envelope, statementPath string) (string, error) {
+	key, certs, err := LoadKeyFile(r.ro.KeyOptions)
+	if err != nil {
+		return "", err
+	}
+
+	issuer := r.ro.SCITTOptions.Issuer
+	if issuer == "" {
+		if issuer, err = cryptoutil.GeneratePublicKeyID(key.Public(), crypto.SHA256); err != nil {
+			return "", fmt.Errorf("failed to generate key id: %w", err)
+		}
+	}
+
+	statement, err := scitt.NewStatement(key, certs, issuer, r.ro.StepName, env)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign statement: %w", err)
+	}
+
+	server, err := transport.ResolveURL(r.ro.SCITTOptions.Server)
+	if err != nil {
+		return "", err
+	}
+
+	entryID, receipt, err := scitt.New(server).Register(ctx, statement)
+	if err != nil {
+		return "", err
+	}
+
+	transparent, err := scitt.AddReceipt(statement, receipt)
+	if err != nil {
+		return "", fmt.Errorf("failed to add receipt to statement: %w", err)
+	}
+
+	if err := os.WriteFile(statementPath, transparent, 0644); err != nil {
+		return "", fmt.Errorf("failed to write transparent statement: %w", err)
+	}
+
+	r.logger.Infof("Registered with %v as entry %v", r.ro.SCITTOptions.Server, entryID)
+	return fmt.Sprintf("%v/entries/%v", r.ro.SCITTOptions.Server, entryID), nil
+}
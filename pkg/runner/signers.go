@@ -0,0 +1,172 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/signer/file"
+	"github.com/testifysec/go-witness/signer/fulcio"
+	"github.com/testifysec/go-witness/signer/spiffe"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/fips"
+	"github.com/testifysec/witness/pkg/pqsign"
+)
+
+// LoadSigners loads the signers configured in ko. Each error describes a signer that couldn't be loaded. In FIPS mode,
+// signers that don't use approved algorithms are rejected.
+func LoadSigners(ctx context.Context, ko options.KeyOptions, fipsMode bool) ([]cryptoutil.Signer, []error) {
+	signers := []cryptoutil.Signer{}
+	errors := []error{}
+
+	//Load key from fulcio
+	if ko.FulcioURL != "" {
+		fulcioSigner, err := fulcio.Signer(ctx, ko.FulcioURL, ko.OIDCClientID, ko.OIDCIssuer)
+		if err != nil {
+			err := fmt.Errorf("failed to create signer from Fulcio: %w", err)
+			errors = append(errors, err)
+		} else {
+			signers = append(signers, fulcioSigner)
+		}
+	}
+
+	//Load key from file
+	if ko.KeyPath != "" {
+		fileSigner, err := file.Signer(ctx, ko.KeyPath, ko.CertPath, ko.IntermediatePaths)
+		if err != nil {
+			err := fmt.Errorf("failed to create signer from file: %w", err)
+			errors = append(errors, err)
+		} else {
+			signers = append(signers, fileSigner)
+		}
+	}
+
+	//Load key from spire agent
+	if ko.SpiffePath != "" {
+		spiffeSigner, err := spiffe.Signer(ctx, ko.SpiffePath)
+		if err != nil {
+			err := fmt.Errorf("failed to create signer from spiffe: %w", err)
+			errors = append(errors, err)
+		} else {
+			signers = append(signers, spiffeSigner)
+		}
+	}
+
+	if fipsMode {
+		approved := make([]cryptoutil.Signer, 0, len(signers))
+		for _, signer := range signers {
+			if err := fips.CheckSigner(signer); err != nil {
+				errors = append(errors, fmt.Errorf("signer is not FIPS compliant: %w", err))
+				continue
+			}
+
+			approved = append(approved, signer)
+		}
+
+		signers = approved
+	}
+
+	return signers, errors
+}
+
+// EphemeralSigner generates an ECDSA P-256 key that only lives in memory. The key is gone once the signer is, so
+// envelopes it signs must be uploaded to a transparency log to bind the key to them.
+func EphemeralSigner() (cryptoutil.Signer, error) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+
+	return cryptoutil.NewSigner(priv, cryptoutil.SignWithHash(crypto.SHA256))
+}
+
+// EnvelopeSigners returns the signers envelopes are signed with: signer, and a post-quantum signer if a
+// post-quantum key was provided, so envelopes carry both a classical and a post-quantum signature
+func EnvelopeSigners(ko options.KeyOptions, signer cryptoutil.Signer, fipsMode bool) ([]cryptoutil.Signer, error) {
+	if ko.PQKeyPath == "" {
+		return []cryptoutil.Signer{signer}, nil
+	}
+
+	if fipsMode {
+		return nil, fmt.Errorf("post-quantum keys are not supported in FIPS mode")
+	}
+
+	keyFile, err := os.Open(ko.PQKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open post-quantum key: %w", err)
+	}
+
+	defer keyFile.Close()
+	pqSigner, err := pqsign.LoadSigner(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load post-quantum key: %w", err)
+	}
+
+	return []cryptoutil.Signer{signer, pqSigner}, nil
+}
+
+// LoadKeyFile loads the private key file and certificate chain configured in ko. COSE, notation, and SCITT
+// envelopes are signed with the key directly rather than a witness signer, since they fix the hash and padding for
+// each key type.
+func LoadKeyFile(ko options.KeyOptions) (crypto.Signer, []*x509.Certificate, error) {
+	if ko.KeyPath == "" {
+		return nil, nil, fmt.Errorf("cose envelopes require a key file")
+	}
+
+	keyFile, err := os.Open(ko.KeyPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open key file: %w", err)
+	}
+
+	defer keyFile.Close()
+	parsedKey, err := cryptoutil.TryParseKeyFromReader(keyFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse key: %w", err)
+	}
+
+	key, ok := parsedKey.(crypto.Signer)
+	if !ok {
+		return nil, nil, fmt.Errorf("%v is not a private key", ko.KeyPath)
+	}
+
+	if ko.CertPath == "" {
+		return key, nil, nil
+	}
+
+	certs := []*x509.Certificate{}
+	for _, path := range append([]string{ko.CertPath}, ko.IntermediatePaths...) {
+		certBytes, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read certificate: %w", err)
+		}
+
+		cert, err := cryptoutil.TryParseCertificate(certBytes)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse certificate %v: %w", path, err)
+		}
+
+		certs = append(certs, cert)
+	}
+
+	return key, certs, nil
+}
@@ -0,0 +1,49 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/testifysec/go-witness/archivist"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/witness/pkg/ghattest"
+	"github.com/testifysec/witness/pkg/spool"
+	"github.com/testifysec/witness/pkg/transport"
+)
+
+// StoreEnvelope uploads env to a backend and returns the reference the backend stored it as. GitHub uploads use
+// githubToken, or the GITHUB_TOKEN environment variable if it's empty.
+func StoreEnvelope(ctx context.Context, backend spool.Backend, server, githubToken string, env dsse.Envelope) (string, error) {
+	switch backend {
+	case spool.BackendArchivist:
+		server, err := transport.ResolveURL(server)
+		if err != nil {
+			return "", err
+		}
+
+		return archivist.New(server).Store(ctx, env)
+	case spool.BackendGitHub:
+		if githubToken == "" {
+			githubToken = os.Getenv("GITHUB_TOKEN")
+		}
+
+		return ghattest.New(githubToken).Upload(ctx, server, env)
+	default:
+		return "", fmt.Errorf("unknown backend %v", backend)
+	}
+}
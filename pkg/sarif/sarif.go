@@ -0,0 +1,122 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sarif writes findings as a SARIF 2.1.0 log, so code scanning dashboards such as GitHub's display them.
+// Only the parts of the format witness reports are modelled.
+package sarif
+
+import (
+	"encoding/json"
+	"io"
+)
+
+const (
+	Version = "2.1.0"
+	Schema  = "https://json.schemastore.org/sarif-2.1.0.json"
+
+	LevelError   = "error"
+	LevelWarning = "warning"
+	LevelNote    = "note"
+)
+
+type Log struct {
+	Schema  string `json:"$schema"`
+	Version string `json:"version"`
+	Runs    []Run  `json:"runs"`
+}
+
+type Run struct {
+	Tool    Tool     `json:"tool"`
+	Results []Result `json:"results"`
+}
+
+type Tool struct {
+	Driver Driver `json:"driver"`
+}
+
+type Driver struct {
+	Name           string `json:"name"`
+	Version        string `json:"version,omitempty"`
+	InformationURI string `json:"informationUri,omitempty"`
+	Rules          []Rule `json:"rules"`
+}
+
+// Rule describes a kind of finding. Results refer to it by ID.
+type Rule struct {
+	ID                   string         `json:"id"`
+	Name                 string         `json:"name,omitempty"`
+	ShortDescription     Message        `json:"shortDescription"`
+	FullDescription      *Message       `json:"fullDescription,omitempty"`
+	DefaultConfiguration *Configuration `json:"defaultConfiguration,omitempty"`
+}
+
+type Configuration struct {
+	Level string `json:"level"`
+}
+
+type Message struct {
+	Text string `json:"text"`
+}
+
+type Result struct {
+	RuleID    string     `json:"ruleId"`
+	Level     string     `json:"level"`
+	Message   Message    `json:"message"`
+	Locations []Location `json:"locations,omitempty"`
+	// PartialFingerprints let dashboards track a finding across runs
+	PartialFingerprints map[string]string      `json:"partialFingerprints,omitempty"`
+	Properties          map[string]interface{} `json:"properties,omitempty"`
+}
+
+type Location struct {
+	PhysicalLocation PhysicalLocation `json:"physicalLocation"`
+}
+
+type PhysicalLocation struct {
+	ArtifactLocation ArtifactLocation `json:"artifactLocation"`
+}
+
+type ArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// New returns a log of one run of the tool, with no results
+func New(driver Driver) *Log {
+	if driver.Rules == nil {
+		driver.Rules = []Rule{}
+	}
+
+	return &Log{
+		Schema:  Schema,
+		Version: Version,
+		Runs:    []Run{{Tool: Tool{Driver: driver}, Results: []Result{}}},
+	}
+}
+
+// Add records a result in the log's run
+func (l *Log) Add(result Result) {
+	l.Runs[0].Results = append(l.Runs[0].Results, result)
+}
+
+// FileLocation returns the location of a file, given by a path relative to the root of the repository
+func FileLocation(path string) Location {
+	return Location{PhysicalLocation: PhysicalLocation{ArtifactLocation: ArtifactLocation{URI: path}}}
+}
+
+// Write writes the log as indented JSON
+func (l *Log) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(l)
+}
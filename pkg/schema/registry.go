@@ -0,0 +1,131 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//go:embed schemas/*.json
+var schemaFiles embed.FS
+
+var (
+	loadOnce      sync.Once
+	loadErr       error
+	schemasByType map[string]*Schema
+)
+
+func load() {
+	schemasByType = make(map[string]*Schema)
+	entries, err := schemaFiles.ReadDir("schemas")
+	if err != nil {
+		loadErr = err
+		return
+	}
+
+	for _, entry := range entries {
+		data, err := schemaFiles.ReadFile(path.Join("schemas", entry.Name()))
+		if err != nil {
+			loadErr = err
+			return
+		}
+
+		s, err := Parse(data)
+		if err != nil {
+			loadErr = fmt.Errorf("failed to parse schema %v: %w", entry.Name(), err)
+			return
+		}
+
+		schemasByType[s.ID] = s
+	}
+}
+
+// ForType returns the published schema for an attestor type.
+func ForType(attestorType string) (*Schema, bool, error) {
+	loadOnce.Do(load)
+	if loadErr != nil {
+		return nil, false, loadErr
+	}
+
+	s, ok := schemasByType[attestorType]
+	return s, ok, nil
+}
+
+// CollectionError lists every attestation in a collection that failed validation.
+type CollectionError struct {
+	Failures map[string][]ValidationError
+}
+
+func (e CollectionError) Error() string {
+	parts := []string{}
+	for attestorType, errs := range e.Failures {
+		for _, err := range errs {
+			parts = append(parts, fmt.Sprintf("%v: %v", attestorType, err))
+		}
+	}
+
+	sort.Strings(parts)
+	return fmt.Sprintf("attestations do not match their schemas: %v", strings.Join(parts, "; "))
+}
+
+// ValidateCollection validates each attestation in a raw attestation collection against the schema
+// for its type. Attestations without a published schema are returned in unknown rather than failing.
+func ValidateCollection(collection []byte) (unknown []string, err error) {
+	raw := struct {
+		Attestations []struct {
+			Type        string          `json:"type"`
+			Attestation json.RawMessage `json:"attestation"`
+		} `json:"attestations"`
+	}{}
+
+	if err := json.Unmarshal(collection, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse collection: %w", err)
+	}
+
+	failures := make(map[string][]ValidationError)
+	for _, att := range raw.Attestations {
+		s, ok, err := ForType(att.Type)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			unknown = append(unknown, att.Type)
+			continue
+		}
+
+		errs, err := s.Validate(att.Attestation)
+		if err != nil {
+			failures[att.Type] = append(failures[att.Type], ValidationError{Path: "$", Message: err.Error()})
+			continue
+		}
+
+		if len(errs) > 0 {
+			failures[att.Type] = append(failures[att.Type], errs...)
+		}
+	}
+
+	if len(failures) > 0 {
+		return unknown, CollectionError{Failures: failures}
+	}
+
+	return unknown, nil
+}
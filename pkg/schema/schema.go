@@ -0,0 +1,263 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package schema validates attestor predicates against the JSON schemas published
+// with witness. Only the subset of JSON Schema used by those schemas is supported:
+// type, properties, required, additionalProperties, items, enum, minLength,
+// minProperties, minimum, and local $refs into $defs.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Schema is a parsed JSON schema.
+type Schema struct {
+	ID                   string             `json:"$id,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Defs                 map[string]*Schema `json:"$defs,omitempty"`
+	Type                 typeList           `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *additional        `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MinProperties        *int               `json:"minProperties,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+}
+
+// typeList is the schema's type keyword, which may be a single type or a list of types.
+type typeList []string
+
+func (t *typeList) UnmarshalJSON(data []byte) error {
+	single := ""
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = typeList{single}
+		return nil
+	}
+
+	list := []string{}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("type must be a string or array of strings")
+	}
+
+	*t = list
+	return nil
+}
+
+// additional is the additionalProperties keyword, which may be a boolean or a schema.
+type additional struct {
+	Allowed bool
+	Schema  *Schema
+}
+
+func (a *additional) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &a.Allowed); err == nil {
+		return nil
+	}
+
+	a.Allowed = true
+	return json.Unmarshal(data, &a.Schema)
+}
+
+// ValidationError describes where in a document validation failed.
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Path, e.Message)
+}
+
+// Parse parses a JSON schema.
+func Parse(data []byte) (*Schema, error) {
+	s := &Schema{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Validate validates the JSON document data against the schema, returning every violation found.
+func (s *Schema) Validate(data []byte) ([]ValidationError, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	errs := []ValidationError{}
+	s.validate(s, "$", doc, &errs)
+	return errs, nil
+}
+
+func (s *Schema) validate(root *Schema, path string, value interface{}, errs *[]ValidationError) {
+	if s.Ref != "" {
+		ref, err := root.resolve(s.Ref)
+		if err != nil {
+			*errs = append(*errs, ValidationError{path, err.Error()})
+			return
+		}
+
+		ref.validate(root, path, value, errs)
+		return
+	}
+
+	if len(s.Type) > 0 && !s.Type.matches(value) {
+		*errs = append(*errs, ValidationError{path, fmt.Sprintf("expected %v but found %v", strings.Join(s.Type, " or "), jsonType(value))})
+		return
+	}
+
+	if len(s.Enum) > 0 && !s.enumContains(value) {
+		*errs = append(*errs, ValidationError{path, fmt.Sprintf("%v is not one of %v", value, s.Enum)})
+	}
+
+	switch v := value.(type) {
+	case json.Number:
+		if n, err := v.Float64(); err == nil && s.Minimum != nil && n < *s.Minimum {
+			*errs = append(*errs, ValidationError{path, fmt.Sprintf("%v is less than the minimum of %v", v, *s.Minimum)})
+		}
+	case string:
+		// lengths are counted in characters, not bytes
+		if s.MinLength != nil && utf8.RuneCountInString(v) < *s.MinLength {
+			*errs = append(*errs, ValidationError{path, fmt.Sprintf("expected at least %d characters but found %d", *s.MinLength, utf8.RuneCountInString(v))})
+		}
+	case map[string]interface{}:
+		if s.MinProperties != nil && len(v) < *s.MinProperties {
+			*errs = append(*errs, ValidationError{path, fmt.Sprintf("expected at least %d properties but found %d", *s.MinProperties, len(v))})
+		}
+
+		for _, required := range s.Required {
+			if _, ok := v[required]; !ok {
+				*errs = append(*errs, ValidationError{path, fmt.Sprintf("missing required property %v", required)})
+			}
+		}
+
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+		for _, key := range keys {
+			propPath := fmt.Sprintf("%v.%v", path, key)
+			if prop, ok := s.Properties[key]; ok {
+				prop.validate(root, propPath, v[key], errs)
+				continue
+			}
+
+			if s.AdditionalProperties == nil {
+				continue
+			}
+
+			if !s.AdditionalProperties.Allowed {
+				*errs = append(*errs, ValidationError{propPath, "unexpected property"})
+			} else if s.AdditionalProperties.Schema != nil {
+				s.AdditionalProperties.Schema.validate(root, propPath, v[key], errs)
+			}
+		}
+	case []interface{}:
+		if s.Items == nil {
+			return
+		}
+
+		for i, item := range v {
+			s.Items.validate(root, fmt.Sprintf("%v[%d]", path, i), item, errs)
+		}
+	}
+}
+
+func (s *Schema) resolve(ref string) (*Schema, error) {
+	const prefix = "#/$defs/"
+	if !strings.HasPrefix(ref, prefix) {
+		return nil, fmt.Errorf("unsupported $ref %v", ref)
+	}
+
+	def, ok := s.Defs[strings.TrimPrefix(ref, prefix)]
+	if !ok {
+		return nil, fmt.Errorf("undefined $ref %v", ref)
+	}
+
+	return def, nil
+}
+
+func (s *Schema) enumContains(value interface{}) bool {
+	for _, allowed := range s.Enum {
+		if reflect.DeepEqual(normalize(allowed), normalize(value)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// normalize converts json.Number to a string so values decoded with and without UseNumber compare equal
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		return v.String()
+	case float64:
+		return json.Number(fmt.Sprint(v)).String()
+	default:
+		return v
+	}
+}
+
+func (t typeList) matches(value interface{}) bool {
+	actual := jsonType(value)
+	for _, expected := range t {
+		if expected == actual {
+			return true
+		}
+
+		if expected == "number" && actual == "integer" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "integer"
+		}
+
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
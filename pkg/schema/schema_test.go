@@ -0,0 +1,121 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	s, err := Parse([]byte(`{
+		"type": "object",
+		"properties": {
+			"name": {"type": "string", "minLength": 1},
+			"count": {"type": "integer", "minimum": 0},
+			"kind": {"enum": ["a", "b"]},
+			"tags": {"type": ["array", "null"], "items": {"type": "string"}},
+			"digest": {"$ref": "#/$defs/digestSet"},
+			"labels": {"type": "object", "minProperties": 1}
+		},
+		"required": ["name"],
+		"additionalProperties": false,
+		"$defs": {
+			"digestSet": {"type": "object", "additionalProperties": {"type": "string"}}
+		}
+	}`))
+	require.NoError(t, err)
+
+	errs, err := s.Validate([]byte(`{"name": "é", "count": 3, "kind": "a", "tags": null, "digest": {"sha256": "abcd"}, "labels": {"a": "b"}}`))
+	require.NoError(t, err)
+	assert.Empty(t, errs)
+
+	errs, err = s.Validate([]byte(`{"count": 1.5, "kind": "c", "tags": ["ok", 1], "digest": {"sha256": 1}, "extra": true}`))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []ValidationError{
+		{"$", "missing required property name"},
+		{"$.count", "expected integer but found number"},
+		{"$.kind", "c is not one of [a b]"},
+		{"$.tags[1]", "expected string but found integer"},
+		{"$.digest.sha256", "expected string but found integer"},
+		{"$.extra", "unexpected property"},
+	}, errs)
+
+	errs, err = s.Validate([]byte(`{"name": "", "count": -1, "labels": {}}`))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []ValidationError{
+		{"$.count", "-1 is less than the minimum of 0"},
+		{"$.name", "expected at least 1 characters but found 0"},
+		{"$.labels", "expected at least 1 properties but found 0"},
+	}, errs)
+}
+
+func TestValidateCollection(t *testing.T) {
+	collection := []byte(`{
+		"name": "build",
+		"attestations": [
+			{"type": "https://witness.dev/attestations/command-run/v0.1", "attestation": {"cmd": ["make"], "exitcode": 0}},
+			{"type": "https://witness.dev/attestations/material/v0.1", "attestation": {"main.go": {"sha256": "abcd"}}},
+			{"type": "https://example.com/custom/v0.1", "attestation": {}}
+		]
+	}`)
+
+	unknown, err := ValidateCollection(collection)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https://example.com/custom/v0.1"}, unknown)
+
+	malformed := []byte(`{
+		"name": "build",
+		"attestations": [
+			{"type": "https://witness.dev/attestations/command-run/v0.1", "attestation": {"cmd": "make", "exitcode": "0"}}
+		]
+	}`)
+
+	_, err = ValidateCollection(malformed)
+	collectionErr := CollectionError{}
+	require.ErrorAs(t, err, &collectionErr)
+	assert.Len(t, collectionErr.Failures["https://witness.dev/attestations/command-run/v0.1"], 2)
+}
+
+func TestPublishedSchemasParse(t *testing.T) {
+	entries, err := schemaFiles.ReadDir("schemas")
+	require.NoError(t, err)
+	require.NotEmpty(t, entries)
+
+	ids := map[string]string{}
+	for _, entry := range entries {
+		data, err := schemaFiles.ReadFile(path.Join("schemas", entry.Name()))
+		require.NoError(t, err)
+		s, err := Parse(data)
+		require.NoError(t, err, entry.Name())
+
+		// each schema is found by its attestor type, which names the attestor the file is named after
+		name := strings.TrimSuffix(entry.Name(), ".json")
+		assert.Contains(t, s.ID, "/"+name+"/", entry.Name())
+		if other, ok := ids[s.ID]; ok {
+			assert.Failf(t, "duplicate schema id", "%v and %v are both %v", other, entry.Name(), s.ID)
+		}
+
+		ids[s.ID] = entry.Name()
+		found, ok, err := ForType(s.ID)
+		require.NoError(t, err)
+		assert.True(t, ok, s.ID)
+		assert.Equal(t, s, found)
+	}
+}
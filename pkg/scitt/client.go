@@ -0,0 +1,193 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scitt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/testifysec/witness/pkg/cose"
+)
+
+const defaultPollInterval = time.Second
+
+// Client registers signed statements with a transparency service over the SCITT reference API (SCRAPI)
+type Client struct {
+	url          string
+	http         *http.Client
+	pollInterval time.Duration
+}
+
+type Option func(*Client)
+
+// WithPollInterval sets how often the status of a registration the service is still running is checked
+func WithPollInterval(interval time.Duration) Option {
+	return func(c *Client) {
+		c.pollInterval = interval
+	}
+}
+
+func New(serviceURL string, opts ...Option) *Client {
+	c := &Client{
+		url:          strings.TrimSuffix(serviceURL, "/"),
+		http:         http.DefaultClient,
+		pollInterval: defaultPollInterval,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+type operation struct {
+	OperationID string `json:"operationID"`
+	Status      string `json:"status"`
+	EntryID     string `json:"entryID"`
+	Error       *struct {
+		Detail string `json:"detail"`
+	} `json:"error"`
+}
+
+// Register registers statement and returns the id of its log entry and the receipt of its registration. Services
+// that register statements asynchronously are polled until the registration finishes or ctx is done.
+func (c *Client) Register(ctx context.Context, statement []byte) (string, []byte, error) {
+	resp, body, err := c.do(ctx, http.MethodPost, c.url+"/entries", statement)
+	if err != nil {
+		return "", nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusCreated, http.StatusOK:
+		entryID := ""
+		if location := resp.Header.Get("Location"); location != "" {
+			entryID = path.Base(location)
+		}
+
+		return entryID, body, nil
+	case http.StatusAccepted:
+		op := operation{}
+		if err := json.Unmarshal(body, &op); err != nil {
+			return "", nil, fmt.Errorf("failed to parse registration operation: %w", err)
+		}
+
+		entryID, err := c.waitForEntry(ctx, op)
+		if err != nil {
+			return "", nil, err
+		}
+
+		receipt, err := c.Receipt(ctx, entryID)
+		return entryID, receipt, err
+	default:
+		return "", nil, fmt.Errorf("transparency service responded with %v: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+}
+
+// Receipt fetches the receipt of the entry with entryID
+func (c *Client) Receipt(ctx context.Context, entryID string) ([]byte, error) {
+	resp, body, err := c.do(ctx, http.MethodGet, fmt.Sprintf("%v/entries/%v/receipt", c.url, url.PathEscape(entryID)), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("transparency service responded with %v: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return body, nil
+}
+
+func (c *Client) waitForEntry(ctx context.Context, op operation) (string, error) {
+	for {
+		switch op.Status {
+		case "succeeded":
+			if op.EntryID == "" {
+				return "", fmt.Errorf("registration %v succeeded without an entry id", op.OperationID)
+			}
+
+			return op.EntryID, nil
+		case "failed":
+			detail := "unknown error"
+			if op.Error != nil {
+				detail = op.Error.Detail
+			}
+
+			return "", fmt.Errorf("registration %v failed: %v", op.OperationID, detail)
+		}
+
+		if op.OperationID == "" {
+			return "", fmt.Errorf("transparency service returned a running registration without an operation id")
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(c.pollInterval):
+		}
+
+		resp, body, err := c.do(ctx, http.MethodGet, fmt.Sprintf("%v/operations/%v", c.url, url.PathEscape(op.OperationID)), nil)
+		if err != nil {
+			return "", err
+		}
+
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+			return "", fmt.Errorf("transparency service responded with %v: %s", resp.Status, strings.TrimSpace(string(body)))
+		}
+
+		id := op.OperationID
+		op = operation{}
+		if err := json.Unmarshal(body, &op); err != nil {
+			return "", fmt.Errorf("failed to parse registration operation: %w", err)
+		}
+
+		if op.OperationID == "" {
+			op.OperationID = id
+		}
+	}
+}
+
+func (c *Client) do(ctx context.Context, method, reqURL string, body []byte) (*http.Response, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", cose.MediaType)
+	}
+
+	req.Header.Set("Accept", cose.MediaType+", application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return resp, respBody, nil
+}
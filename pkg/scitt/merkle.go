@@ -0,0 +1,114 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scitt
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/testifysec/witness/pkg/cose"
+)
+
+// InclusionProof proves a leaf is in an RFC 9162 Merkle tree
+type InclusionProof struct {
+	TreeSize  int64
+	LeafIndex int64
+	Path      [][]byte
+}
+
+// Encode encodes the proof as it appears in a receipt's verifiable data proofs header
+func (p InclusionProof) Encode() ([]byte, error) {
+	path := make([]interface{}, 0, len(p.Path))
+	for _, hash := range p.Path {
+		path = append(path, hash)
+	}
+
+	return cose.Marshal([]interface{}{p.TreeSize, p.LeafIndex, path})
+}
+
+func parseInclusionProof(data []byte) (InclusionProof, error) {
+	decoded, err := cose.Unmarshal(data)
+	if err != nil {
+		return InclusionProof{}, fmt.Errorf("failed to decode inclusion proof: %w", err)
+	}
+
+	parts, ok := decoded.([]interface{})
+	if !ok || len(parts) != 3 {
+		return InclusionProof{}, fmt.Errorf("inclusion proofs are arrays of tree size, leaf index, and path")
+	}
+
+	proof := InclusionProof{}
+	treeSize, ok1 := parts[0].(int64)
+	leafIndex, ok2 := parts[1].(int64)
+	path, ok3 := parts[2].([]interface{})
+	if !ok1 || !ok2 || !ok3 {
+		return InclusionProof{}, fmt.Errorf("inclusion proofs are arrays of tree size, leaf index, and path")
+	}
+
+	proof.TreeSize, proof.LeafIndex = treeSize, leafIndex
+	for _, hash := range path {
+		hashBytes, ok := hash.([]byte)
+		if !ok || len(hashBytes) != sha256.Size {
+			return InclusionProof{}, fmt.Errorf("inclusion proof hashes must be sha256 digests")
+		}
+
+		proof.Path = append(proof.Path, hashBytes)
+	}
+
+	return proof, nil
+}
+
+// Root computes the root of the tree the proof places leafHash in, as in RFC 9162 section 2.1.3.2
+func (p InclusionProof) Root(leafHash []byte) ([]byte, error) {
+	if p.LeafIndex < 0 || p.LeafIndex >= p.TreeSize {
+		return nil, fmt.Errorf("leaf index %v is outside a tree of size %v", p.LeafIndex, p.TreeSize)
+	}
+
+	fn, sn := p.LeafIndex, p.TreeSize-1
+	r := leafHash
+	for _, hash := range p.Path {
+		if sn == 0 {
+			return nil, fmt.Errorf("inclusion proof is longer than the tree is deep")
+		}
+
+		if fn%2 == 1 || fn == sn {
+			r = nodeHash(hash, r)
+			for fn%2 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = nodeHash(r, hash)
+		}
+
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 {
+		return nil, fmt.Errorf("inclusion proof is shorter than the tree is deep")
+	}
+
+	return r, nil
+}
+
+func nodeHash(left, right []byte) []byte {
+	buf := bytes.NewBuffer([]byte{1})
+	buf.Write(left)
+	buf.Write(right)
+	sum := sha256.Sum256(buf.Bytes())
+	return sum[:]
+}
@@ -0,0 +1,216 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package scitt registers signed attestations with SCITT transparency services and verifies the receipts they
+// return. Witness envelopes are wrapped in COSE signed statements, and the receipt proving a statement's inclusion
+// in the service's log is kept with it as a transparent statement.
+package scitt
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/witness/pkg/cose"
+)
+
+const (
+	// ContentTypeDSSE is the content type of signed statements that carry a DSSE envelope
+	ContentTypeDSSE = "application/vnd.dsse.envelope.v1+json"
+
+	// HeaderCWTClaims is the label of the protected header holding the statement's issuer and subject
+	HeaderCWTClaims int64 = 15
+	// HeaderReceipts is the label of the unprotected header transparent statements keep their receipts in
+	HeaderReceipts int64 = 394
+	// HeaderVerifiableDataStructure is the label of the receipt header naming the log's tree algorithm
+	HeaderVerifiableDataStructure int64 = 395
+	// HeaderVerifiableDataProofs is the label of the receipt header holding inclusion proofs
+	HeaderVerifiableDataProofs int64 = 396
+
+	// RFC9162SHA256 is the verifiable data structure of logs built as RFC 9162 Merkle trees
+	RFC9162SHA256 int64 = 1
+	// ProofTypeInclusion labels inclusion proofs in the verifiable data proofs header
+	ProofTypeInclusion int64 = -1
+
+	claimIssuer  int64 = 1
+	claimSubject int64 = 2
+)
+
+// TransparentStatement is a signed statement and the receipts of its registration
+type TransparentStatement struct {
+	// Statement is the signed statement as it was registered, without its receipts
+	Statement []byte
+	Issuer    string
+	Subject   string
+	Envelope  dsse.Envelope
+	Receipts  [][]byte
+}
+
+// NewStatement wraps env in a signed statement from issuer about subject, signed with key. The certificate chain of
+// key, if any, is included so verifiers can identify the issuer.
+func NewStatement(key crypto.Signer, certs []*x509.Certificate, issuer, subject string, env dsse.Envelope) ([]byte, error) {
+	payload, err := json.Marshal(env)
+	if err != nil {
+		return nil, err
+	}
+
+	protected := cose.Headers{
+		cose.HeaderContentType: ContentTypeDSSE,
+		HeaderCWTClaims:        cose.Headers{claimIssuer: issuer, claimSubject: subject},
+	}
+
+	if len(certs) > 0 {
+		chain := make([]interface{}, 0, len(certs))
+		for _, cert := range certs {
+			chain = append(chain, cert.Raw)
+		}
+
+		protected[cose.HeaderX5Chain] = chain
+	}
+
+	return cose.Sign(key, protected, nil, payload)
+}
+
+// AddReceipt adds receipt to a signed or transparent statement
+func AddReceipt(statement, receipt []byte) ([]byte, error) {
+	msg, err := cose.Parse(statement)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse statement: %w", err)
+	}
+
+	receipts, _ := msg.Unprotected[HeaderReceipts].([]interface{})
+	unprotected := cose.Headers{}
+	for label, value := range msg.Unprotected {
+		unprotected[label] = value
+	}
+
+	unprotected[HeaderReceipts] = append(receipts, receipt)
+	msg.Unprotected = unprotected
+	return msg.Encode()
+}
+
+// ParseTransparentStatement parses a transparent statement, or a signed statement with no receipts. Neither the
+// statement's signature nor its receipts are verified.
+func ParseTransparentStatement(data []byte) (TransparentStatement, error) {
+	msg, err := cose.Parse(data)
+	if err != nil {
+		return TransparentStatement{}, fmt.Errorf("failed to parse statement: %w", err)
+	}
+
+	if msg.ContentType() != ContentTypeDSSE {
+		return TransparentStatement{}, fmt.Errorf("statement content type %q is not a dsse envelope", msg.ContentType())
+	}
+
+	ts := TransparentStatement{}
+	if err := json.Unmarshal(msg.Payload, &ts.Envelope); err != nil {
+		return TransparentStatement{}, fmt.Errorf("failed to parse dsse envelope: %w", err)
+	}
+
+	if claims, ok := msg.Protected[HeaderCWTClaims].(map[interface{}]interface{}); ok {
+		ts.Issuer, _ = claims[claimIssuer].(string)
+		ts.Subject, _ = claims[claimSubject].(string)
+	}
+
+	receipts, _ := msg.Unprotected[HeaderReceipts].([]interface{})
+	for _, receipt := range receipts {
+		receiptBytes, ok := receipt.([]byte)
+		if !ok {
+			return TransparentStatement{}, fmt.Errorf("receipts must be byte strings")
+		}
+
+		ts.Receipts = append(ts.Receipts, receiptBytes)
+	}
+
+	// the statement was registered before any receipts were added to it
+	unprotected := cose.Headers{}
+	for label, value := range msg.Unprotected {
+		if label != HeaderReceipts {
+			unprotected[label] = value
+		}
+	}
+
+	msg.Unprotected = unprotected
+	if ts.Statement, err = msg.Encode(); err != nil {
+		return TransparentStatement{}, err
+	}
+
+	return ts, nil
+}
+
+// Verify returns nil if one of the statement's receipts was issued by the service with serviceKey
+func (ts TransparentStatement) Verify(serviceKey crypto.PublicKey) error {
+	if len(ts.Receipts) == 0 {
+		return fmt.Errorf("statement has no receipts")
+	}
+
+	var err error
+	for _, receipt := range ts.Receipts {
+		if err = VerifyReceipt(receipt, ts.Statement, serviceKey); err == nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no receipt was issued by the transparency service: %w", err)
+}
+
+// VerifyReceipt verifies receipt proves statement was included in the log of the service with serviceKey. Receipts
+// sign the root of an RFC 9162 Merkle tree, and carry the proof that the statement's leaf is in that tree.
+func VerifyReceipt(receipt, statement []byte, serviceKey crypto.PublicKey) error {
+	msg, err := cose.Parse(receipt)
+	if err != nil {
+		return fmt.Errorf("failed to parse receipt: %w", err)
+	}
+
+	if vds, _ := msg.Protected[HeaderVerifiableDataStructure].(int64); vds != RFC9162SHA256 {
+		return fmt.Errorf("unsupported verifiable data structure %v", msg.Protected[HeaderVerifiableDataStructure])
+	}
+
+	proofs, _ := msg.Unprotected[HeaderVerifiableDataProofs].(map[interface{}]interface{})
+	inclusionProofs, _ := proofs[ProofTypeInclusion].([]interface{})
+	if len(inclusionProofs) == 0 {
+		return fmt.Errorf("receipt has no inclusion proof")
+	}
+
+	for _, encoded := range inclusionProofs {
+		encodedBytes, ok := encoded.([]byte)
+		if !ok {
+			return fmt.Errorf("inclusion proofs must be byte strings")
+		}
+
+		proof, err := parseInclusionProof(encodedBytes)
+		if err != nil {
+			return err
+		}
+
+		root, err := proof.Root(LeafHash(statement))
+		if err != nil {
+			return err
+		}
+
+		if err := msg.VerifyDetached(serviceKey, root); err != nil {
+			return fmt.Errorf("receipt signature is invalid: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// LeafHash returns the hash of the log leaf a registered statement is recorded as
+func LeafHash(statement []byte) []byte {
+	sum := sha256.Sum256(append([]byte{0}, statement...))
+	return sum[:]
+}
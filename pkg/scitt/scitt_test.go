@@ -0,0 +1,207 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scitt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/witness/pkg/cose"
+)
+
+// treeHash is the RFC 9162 Merkle tree hash of leaves
+func treeHash(leaves [][]byte) []byte {
+	if len(leaves) == 0 {
+		sum := sha256.Sum256(nil)
+		return sum[:]
+	}
+
+	if len(leaves) == 1 {
+		return LeafHash(leaves[0])
+	}
+
+	k := splitPoint(len(leaves))
+	return nodeHash(treeHash(leaves[:k]), treeHash(leaves[k:]))
+}
+
+// inclusionPath is the RFC 9162 audit path of the leaf at index
+func inclusionPath(index int, leaves [][]byte) [][]byte {
+	if len(leaves) <= 1 {
+		return nil
+	}
+
+	k := splitPoint(len(leaves))
+	if index < k {
+		return append(inclusionPath(index, leaves[:k]), treeHash(leaves[k:]))
+	}
+
+	return append(inclusionPath(index-k, leaves[k:]), treeHash(leaves[:k]))
+}
+
+// splitPoint is the largest power of two smaller than n
+func splitPoint(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+
+	return k
+}
+
+// newReceipt issues a receipt for the leaf at index of a log holding leaves
+func newReceipt(t *testing.T, key crypto.Signer, leaves [][]byte, index int) []byte {
+	proof, err := InclusionProof{TreeSize: int64(len(leaves)), LeafIndex: int64(index), Path: inclusionPath(index, leaves)}.Encode()
+	require.NoError(t, err)
+	receipt, err := cose.SignDetached(key,
+		cose.Headers{HeaderVerifiableDataStructure: RFC9162SHA256},
+		cose.Headers{HeaderVerifiableDataProofs: cose.Headers{ProofTypeInclusion: []interface{}{proof}}},
+		treeHash(leaves))
+	require.NoError(t, err)
+	return receipt
+}
+
+func newKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return key
+}
+
+func TestInclusionProof(t *testing.T) {
+	for size := 1; size <= 9; size++ {
+		leaves := [][]byte{}
+		for i := 0; i < size; i++ {
+			leaves = append(leaves, []byte(fmt.Sprintf("leaf %d", i)))
+		}
+
+		root := treeHash(leaves)
+		for index := 0; index < size; index++ {
+			proof := InclusionProof{TreeSize: int64(size), LeafIndex: int64(index), Path: inclusionPath(index, leaves)}
+			computed, err := proof.Root(LeafHash(leaves[index]))
+			require.NoError(t, err, "size %d index %d", size, index)
+			assert.Equal(t, root, computed, "size %d index %d", size, index)
+
+			wrongLeaf, err := proof.Root(LeafHash([]byte("other")))
+			require.NoError(t, err)
+			assert.NotEqual(t, root, wrongLeaf)
+		}
+	}
+
+	_, err := InclusionProof{TreeSize: 2, LeafIndex: 2}.Root(LeafHash(nil))
+	assert.ErrorContains(t, err, "outside a tree")
+	_, err = InclusionProof{TreeSize: 4, LeafIndex: 0, Path: [][]byte{make([]byte, 32)}}.Root(LeafHash(nil))
+	assert.ErrorContains(t, err, "shorter than the tree")
+}
+
+func TestTransparentStatement(t *testing.T) {
+	issuerKey := newKey(t)
+	serviceKey := newKey(t)
+	env := dsse.Envelope{PayloadType: "application/vnd.in-toto+json", Payload: []byte(`{"_type":"https://in-toto.io/Statement/v0.1"}`)}
+	statement, err := NewStatement(issuerKey, nil, "issuer", "build", env)
+	require.NoError(t, err)
+
+	leaves := [][]byte{[]byte("first"), statement, []byte("third")}
+	transparent, err := AddReceipt(statement, newReceipt(t, serviceKey, leaves, 1))
+	require.NoError(t, err)
+
+	ts, err := ParseTransparentStatement(transparent)
+	require.NoError(t, err)
+	assert.Equal(t, statement, ts.Statement)
+	assert.Equal(t, "issuer", ts.Issuer)
+	assert.Equal(t, "build", ts.Subject)
+	assert.Equal(t, env.Payload, ts.Envelope.Payload)
+	assert.Len(t, ts.Receipts, 1)
+	assert.NoError(t, ts.Verify(&serviceKey.PublicKey))
+	assert.ErrorContains(t, ts.Verify(&newKey(t).PublicKey), "no receipt was issued")
+
+	// a receipt for a different leaf doesn't prove the statement was registered
+	forged, err := AddReceipt(statement, newReceipt(t, serviceKey, leaves, 0))
+	require.NoError(t, err)
+	ts, err = ParseTransparentStatement(forged)
+	require.NoError(t, err)
+	assert.Error(t, ts.Verify(&serviceKey.PublicKey))
+
+	ts, err = ParseTransparentStatement(statement)
+	require.NoError(t, err)
+	assert.ErrorContains(t, ts.Verify(&serviceKey.PublicKey), "no receipts")
+}
+
+func TestRegister(t *testing.T) {
+	serviceKey := newKey(t)
+	statement := []byte("statement")
+	receipt := newReceipt(t, serviceKey, [][]byte{statement}, 0)
+	polls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/sync/entries":
+			body, _ := io.ReadAll(r.Body)
+			assert.Equal(t, statement, body)
+			assert.Equal(t, cose.MediaType, r.Header.Get("Content-Type"))
+			w.Header().Set("Location", "/sync/entries/1")
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write(receipt)
+		case r.Method == http.MethodPost && r.URL.Path == "/async/entries":
+			w.WriteHeader(http.StatusAccepted)
+			_, _ = w.Write([]byte(`{"operationID": "op1", "status": "running"}`))
+		case r.URL.Path == "/async/operations/op1":
+			polls++
+			if polls < 2 {
+				_, _ = w.Write([]byte(`{"operationID": "op1", "status": "running"}`))
+				return
+			}
+
+			_, _ = w.Write([]byte(`{"operationID": "op1", "status": "succeeded", "entryID": "2"}`))
+		case r.URL.Path == "/async/entries/2/receipt":
+			_, _ = w.Write(receipt)
+		case r.Method == http.MethodPost && r.URL.Path == "/failed/entries":
+			w.WriteHeader(http.StatusAccepted)
+			_, _ = w.Write([]byte(`{"operationID": "op2", "status": "failed", "error": {"detail": "unsupported issuer"}}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("bad request"))
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	entryID, got, err := New(server.URL+"/sync/").Register(ctx, statement)
+	require.NoError(t, err)
+	assert.Equal(t, "1", entryID)
+	assert.Equal(t, receipt, got)
+
+	entryID, got, err = New(server.URL+"/async", WithPollInterval(time.Millisecond)).Register(ctx, statement)
+	require.NoError(t, err)
+	assert.Equal(t, "2", entryID)
+	assert.Equal(t, receipt, got)
+	assert.Equal(t, 2, polls)
+
+	_, _, err = New(server.URL+"/failed").Register(ctx, statement)
+	assert.ErrorContains(t, err, "unsupported issuer")
+
+	_, _, err = New(server.URL+"/other").Register(ctx, statement)
+	assert.ErrorContains(t, err, "bad request")
+}
@@ -0,0 +1,430 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sct checks the signed certificate timestamps (SCTs) embedded in signing certificates against the
+// certificate transparency logs a policy trusts. Fulcio embeds SCTs in the certificates it issues to prove they were
+// submitted to a log, where anyone can audit what it issued. A policy that requires SCTs doesn't trust certificates
+// that a compromised or rogue Fulcio issued without logging them.
+package sct
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// sctListOID identifies the certificate extension that embeds SCTs, defined by RFC 6962 section 3.3
+var sctListOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+
+const (
+	sctVersion1          = 0
+	certificateTimestamp = 0
+	precertEntry         = 1
+	hashSHA256           = 4
+	signatureRSA         = 1
+	signatureECDSA       = 3
+)
+
+// policyRoots is the subset of a witness policy that configures certificate transparency for its roots. ctLogs and
+// requireSCT aren't part of the go-witness policy type, so they're read separately from the same policy document.
+type policyRoots struct {
+	Roots map[string]struct {
+		Certificate   []byte   `json:"certificate"`
+		Intermediates [][]byte `json:"intermediates,omitempty"`
+		CTLogs        [][]byte `json:"ctLogs,omitempty"`
+		RequireSCT    bool     `json:"requireSCT,omitempty"`
+	} `json:"roots,omitempty"`
+}
+
+type root struct {
+	id            string
+	cert          *x509.Certificate
+	intermediates []*x509.Certificate
+	logs          map[[sha256.Size]byte]crypto.PublicKey
+	requireSCT    bool
+}
+
+// Requirements are the certificate transparency logs a policy trusts for the certificates its roots issue
+type Requirements struct {
+	roots []root
+}
+
+// Rejection is a signature removed from an envelope because its certificate's SCTs didn't meet the requirements
+type Rejection struct {
+	KeyID  string
+	Reason error
+}
+
+// SCT is a signed certificate timestamp, a log's promise to add a certificate to the log
+type SCT struct {
+	Version            uint8
+	LogID              [sha256.Size]byte
+	Timestamp          uint64
+	Extensions         []byte
+	HashAlgorithm      uint8
+	SignatureAlgorithm uint8
+	Signature          []byte
+}
+
+// FromPolicy reads the certificate transparency logs each root in a policy document trusts. A nil Requirements is
+// returned if no root configures any logs.
+func FromPolicy(policy []byte) (*Requirements, error) {
+	p := policyRoots{}
+	if err := json.Unmarshal(policy, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse policy: %w", err)
+	}
+
+	roots := []root{}
+	for id, policyRoot := range p.Roots {
+		if len(policyRoot.CTLogs) == 0 {
+			if policyRoot.RequireSCT {
+				return nil, fmt.Errorf("root %v requires SCTs but doesn't trust any ctLogs", id)
+			}
+
+			continue
+		}
+
+		r := root{id: id, logs: make(map[[sha256.Size]byte]crypto.PublicKey), requireSCT: policyRoot.RequireSCT}
+		var err error
+		if r.cert, err = cryptoutil.TryParseCertificate(policyRoot.Certificate); err != nil {
+			return nil, fmt.Errorf("failed to parse certificate of root %v: %w", id, err)
+		}
+
+		for _, intermediate := range policyRoot.Intermediates {
+			cert, err := cryptoutil.TryParseCertificate(intermediate)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse intermediate of root %v: %w", id, err)
+			}
+
+			r.intermediates = append(r.intermediates, cert)
+		}
+
+		for _, logKey := range policyRoot.CTLogs {
+			logID, key, err := parseLogKey(logKey)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse ct log key of root %v: %w", id, err)
+			}
+
+			r.logs[logID] = key
+		}
+
+		roots = append(roots, r)
+	}
+
+	if len(roots) == 0 {
+		return nil, nil
+	}
+
+	return &Requirements{roots: roots}, nil
+}
+
+// parseLogKey parses the PEM public key of a log, and returns the log's ID: the sha256 digest of the key's DER
+// encoding
+func parseLogKey(keyPEM []byte) ([sha256.Size]byte, crypto.PublicKey, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return [sha256.Size]byte{}, nil, fmt.Errorf("no PEM block found")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return [sha256.Size]byte{}, nil, err
+	}
+
+	return sha256.Sum256(block.Bytes), key, nil
+}
+
+// Filter returns env without the signatures whose certificates were issued by a root that trusts certificate
+// transparency logs, and either carry an SCT from one of the logs that doesn't verify or don't carry one when the
+// root requires it. SCTs from logs the root doesn't trust are ignored.
+func (r *Requirements) Filter(env dsse.Envelope) (dsse.Envelope, []Rejection) {
+	if r == nil {
+		return env, nil
+	}
+
+	rejected := []Rejection{}
+	signatures := make([]dsse.Signature, 0, len(env.Signatures))
+	for _, sig := range env.Signatures {
+		if err := r.check(sig); err != nil {
+			rejected = append(rejected, Rejection{KeyID: sig.KeyID, Reason: err})
+			continue
+		}
+
+		signatures = append(signatures, sig)
+	}
+
+	env.Signatures = signatures
+	return env, rejected
+}
+
+func (r *Requirements) check(sig dsse.Signature) error {
+	if len(sig.Certificate) == 0 {
+		return nil
+	}
+
+	leaf, err := cryptoutil.TryParseCertificate(sig.Certificate)
+	if err != nil {
+		return fmt.Errorf("failed to parse certificate: %w", err)
+	}
+
+	sigIntermediates := []*x509.Certificate{}
+	for _, intermediate := range sig.Intermediates {
+		cert, err := cryptoutil.TryParseCertificate(intermediate)
+		if err != nil {
+			return fmt.Errorf("failed to parse intermediate: %w", err)
+		}
+
+		sigIntermediates = append(sigIntermediates, cert)
+	}
+
+	for _, root := range r.roots {
+		issuer, ok := root.issuer(leaf, sigIntermediates)
+		if !ok {
+			continue
+		}
+
+		if err := root.checkSCTs(leaf, issuer); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// issuer returns the certificate that issued leaf if leaf chains to the root. Fulcio certificates expire minutes
+// after they're issued, so the chain is checked when leaf became valid. Its validity at signing is checked by
+// go-witness.
+func (r root) issuer(leaf *x509.Certificate, sigIntermediates []*x509.Certificate) (*x509.Certificate, bool) {
+	roots := x509.NewCertPool()
+	roots.AddCert(r.cert)
+	intermediates := x509.NewCertPool()
+	for _, cert := range append(append([]*x509.Certificate{}, sigIntermediates...), r.intermediates...) {
+		intermediates.AddCert(cert)
+	}
+
+	chains, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   leaf.NotBefore,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+
+	if err != nil || len(chains) == 0 || len(chains[0]) < 2 {
+		return nil, false
+	}
+
+	return chains[0][1], true
+}
+
+func (r root) checkSCTs(leaf, issuer *x509.Certificate) error {
+	scts, err := Embedded(leaf)
+	if err != nil {
+		return err
+	}
+
+	verified := 0
+	for _, sct := range scts {
+		key, ok := r.logs[sct.LogID]
+		if !ok {
+			continue
+		}
+
+		if err := Verify(sct, leaf, issuer, key); err != nil {
+			return fmt.Errorf("sct from log %x doesn't verify: %w", sct.LogID, err)
+		}
+
+		verified++
+	}
+
+	if verified == 0 && r.requireSCT {
+		return fmt.Errorf("certificate has no sct from a ct log trusted by root %v", r.id)
+	}
+
+	return nil
+}
+
+// Embedded returns the SCTs embedded in cert
+func Embedded(cert *x509.Certificate) ([]SCT, error) {
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(sctListOID) {
+			continue
+		}
+
+		var list []byte
+		if rest, err := asn1.Unmarshal(ext.Value, &list); err != nil || len(rest) > 0 {
+			return nil, fmt.Errorf("failed to parse sct list extension")
+		}
+
+		return parseList(list)
+	}
+
+	return nil, nil
+}
+
+// parseList parses a SignedCertificateTimestampList, defined by RFC 6962 section 3.3
+func parseList(list []byte) ([]SCT, error) {
+	input := cryptobyte.String(list)
+	var scts cryptobyte.String
+	if !input.ReadUint16LengthPrefixed(&scts) || !input.Empty() {
+		return nil, fmt.Errorf("malformed sct list")
+	}
+
+	parsed := []SCT{}
+	for !scts.Empty() {
+		var serialized cryptobyte.String
+		if !scts.ReadUint16LengthPrefixed(&serialized) {
+			return nil, fmt.Errorf("malformed sct list")
+		}
+
+		sct := SCT{}
+		var logID, extensions, signature []byte
+		if !serialized.ReadUint8(&sct.Version) || !serialized.ReadBytes(&logID, sha256.Size) ||
+			!readUint64(&serialized, &sct.Timestamp) || !readUint16Bytes(&serialized, &extensions) ||
+			!serialized.ReadUint8(&sct.HashAlgorithm) || !serialized.ReadUint8(&sct.SignatureAlgorithm) ||
+			!readUint16Bytes(&serialized, &signature) || !serialized.Empty() {
+			return nil, fmt.Errorf("malformed sct")
+		}
+
+		copy(sct.LogID[:], logID)
+		sct.Extensions, sct.Signature = extensions, signature
+		parsed = append(parsed, sct)
+	}
+
+	return parsed, nil
+}
+
+// readUint64 reads a big-endian uint64, which this version of cryptobyte can't read itself
+func readUint64(s *cryptobyte.String, out *uint64) bool {
+	var high, low uint32
+	if !s.ReadUint32(&high) || !s.ReadUint32(&low) {
+		return false
+	}
+
+	*out = uint64(high)<<32 | uint64(low)
+	return true
+}
+
+func readUint16Bytes(s *cryptobyte.String, out *[]byte) bool {
+	var value cryptobyte.String
+	if !s.ReadUint16LengthPrefixed(&value) {
+		return false
+	}
+
+	*out = value
+	return true
+}
+
+// Verify checks sct is the signature of the log with key over the precertificate of leaf, which issuer issued.
+// The precertificate is leaf's TBSCertificate without its embedded SCTs, defined by RFC 6962 section 3.2.
+func Verify(sct SCT, leaf, issuer *x509.Certificate, key crypto.PublicKey) error {
+	if sct.Version != sctVersion1 {
+		return fmt.Errorf("unsupported sct version %v", sct.Version)
+	}
+
+	if sct.HashAlgorithm != hashSHA256 {
+		return fmt.Errorf("unsupported sct hash algorithm %v", sct.HashAlgorithm)
+	}
+
+	tbs, err := removeSCTList(leaf.RawTBSCertificate)
+	if err != nil {
+		return err
+	}
+
+	issuerKeyHash := sha256.Sum256(issuer.RawSubjectPublicKeyInfo)
+	b := cryptobyte.NewBuilder(nil)
+	b.AddUint8(sct.Version)
+	b.AddUint8(certificateTimestamp)
+	b.AddUint32(uint32(sct.Timestamp >> 32))
+	b.AddUint32(uint32(sct.Timestamp))
+	b.AddUint16(precertEntry)
+	b.AddBytes(issuerKeyHash[:])
+	b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(tbs)
+	})
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(sct.Extensions)
+	})
+
+	signed, err := b.Bytes()
+	if err != nil {
+		return fmt.Errorf("failed to encode signed data: %w", err)
+	}
+
+	digest := sha256.Sum256(signed)
+	switch pub := key.(type) {
+	case *ecdsa.PublicKey:
+		if sct.SignatureAlgorithm != signatureECDSA || !ecdsa.VerifyASN1(pub, digest[:], sct.Signature) {
+			return fmt.Errorf("invalid signature")
+		}
+	case *rsa.PublicKey:
+		if sct.SignatureAlgorithm != signatureRSA {
+			return fmt.Errorf("invalid signature")
+		}
+
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sct.Signature); err != nil {
+			return fmt.Errorf("invalid signature: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported ct log key type %T", key)
+	}
+
+	return nil
+}
+
+// tbsCertificate is a TBSCertificate, defined by RFC 5280 section 4.1, with fields it doesn't change kept raw so
+// they're encoded again exactly as they were
+type tbsCertificate struct {
+	Raw                asn1.RawContent
+	Version            int `asn1:"optional,explicit,default:0,tag:0"`
+	SerialNumber       *big.Int
+	SignatureAlgorithm asn1.RawValue
+	Issuer             asn1.RawValue
+	Validity           asn1.RawValue
+	Subject            asn1.RawValue
+	PublicKey          asn1.RawValue
+	IssuerUniqueID     asn1.BitString   `asn1:"optional,tag:1"`
+	SubjectUniqueID    asn1.BitString   `asn1:"optional,tag:2"`
+	Extensions         []pkix.Extension `asn1:"omitempty,optional,explicit,tag:3"`
+}
+
+// removeSCTList returns the DER encoding of tbs without its SCT list extension
+func removeSCTList(rawTBS []byte) ([]byte, error) {
+	tbs := tbsCertificate{}
+	if rest, err := asn1.Unmarshal(rawTBS, &tbs); err != nil || len(rest) > 0 {
+		return nil, fmt.Errorf("failed to parse tbs certificate")
+	}
+
+	extensions := make([]pkix.Extension, 0, len(tbs.Extensions))
+	for _, ext := range tbs.Extensions {
+		if !ext.Id.Equal(sctListOID) {
+			extensions = append(extensions, ext)
+		}
+	}
+
+	tbs.Raw = nil
+	tbs.Extensions = extensions
+	return asn1.Marshal(tbs)
+}
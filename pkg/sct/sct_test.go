@@ -0,0 +1,259 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sct
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/dsse"
+	"golang.org/x/crypto/cryptobyte"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newCA(t *testing.T, name string) testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return testCA{cert: cert, key: key}
+}
+
+func pemBytes(blockType string, der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+}
+
+// issue issues a leaf certificate embedding an SCT signed by each of logs, the way Fulcio does: the logs sign the
+// certificate without SCTs, which are then added to the certificate that's issued
+func issue(t *testing.T, ca testCA, logs ...*ecdsa.PrivateKey) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:   big.NewInt(2),
+		Subject:        pkix.Name{CommonName: "signer"},
+		EmailAddresses: []string{"signer@example.com"},
+		NotBefore:      time.Now().Add(-time.Minute).Truncate(time.Second),
+		NotAfter:       time.Now().Add(10 * time.Minute).Truncate(time.Second),
+		KeyUsage:       x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}
+
+	if len(logs) > 0 {
+		der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, key.Public(), ca.key)
+		require.NoError(t, err)
+		precert, err := x509.ParseCertificate(der)
+		require.NoError(t, err)
+		list := cryptobyte.NewBuilder(nil)
+		list.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			for _, log := range logs {
+				b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+					b.AddBytes(signSCT(t, log, precert.RawTBSCertificate, ca.cert))
+				})
+			}
+		})
+
+		listBytes, err := list.Bytes()
+		require.NoError(t, err)
+		value, err := asn1.Marshal(listBytes)
+		require.NoError(t, err)
+		template.ExtraExtensions = []pkix.Extension{{Id: sctListOID, Value: value}}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, key.Public(), ca.key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+func logID(t *testing.T, log *ecdsa.PrivateKey) [sha256.Size]byte {
+	der, err := x509.MarshalPKIXPublicKey(log.Public())
+	require.NoError(t, err)
+	return sha256.Sum256(der)
+}
+
+// signSCT returns a serialized SCT signed by log over the precertificate tbs
+func signSCT(t *testing.T, log *ecdsa.PrivateKey, tbs []byte, issuer *x509.Certificate) []byte {
+	timestamp := uint64(time.Now().UnixMilli())
+	issuerKeyHash := sha256.Sum256(issuer.RawSubjectPublicKeyInfo)
+	signed := cryptobyte.NewBuilder(nil)
+	signed.AddUint8(sctVersion1)
+	signed.AddUint8(certificateTimestamp)
+	signed.AddUint32(uint32(timestamp >> 32))
+	signed.AddUint32(uint32(timestamp))
+	signed.AddUint16(precertEntry)
+	signed.AddBytes(issuerKeyHash[:])
+	signed.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(tbs) })
+	signed.AddUint16(0)
+	signedBytes, err := signed.Bytes()
+	require.NoError(t, err)
+	digest := sha256.Sum256(signedBytes)
+	sig, err := log.Sign(rand.Reader, digest[:], crypto.SHA256)
+	require.NoError(t, err)
+
+	id := logID(t, log)
+	sct := cryptobyte.NewBuilder(nil)
+	sct.AddUint8(sctVersion1)
+	sct.AddBytes(id[:])
+	sct.AddUint32(uint32(timestamp >> 32))
+	sct.AddUint32(uint32(timestamp))
+	sct.AddUint16(0)
+	sct.AddUint8(hashSHA256)
+	sct.AddUint8(signatureECDSA)
+	sct.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(sig) })
+	sctBytes, err := sct.Bytes()
+	require.NoError(t, err)
+	return sctBytes
+}
+
+func policyWithLogs(t *testing.T, ca testCA, requireSCT bool, logs ...*ecdsa.PrivateKey) []byte {
+	logKeys := [][]byte{}
+	for _, log := range logs {
+		der, err := x509.MarshalPKIXPublicKey(log.Public())
+		require.NoError(t, err)
+		logKeys = append(logKeys, pemBytes("PUBLIC KEY", der))
+	}
+
+	p := map[string]interface{}{
+		"roots": map[string]interface{}{
+			"fulcio": map[string]interface{}{
+				"certificate": pemBytes("CERTIFICATE", ca.cert.Raw),
+				"ctLogs":      logKeys,
+				"requireSCT":  requireSCT,
+			},
+		},
+	}
+
+	policy, err := json.Marshal(p)
+	require.NoError(t, err)
+	return policy
+}
+
+func signed(certs ...*x509.Certificate) dsse.Envelope {
+	env := dsse.Envelope{}
+	for i, cert := range certs {
+		env.Signatures = append(env.Signatures, dsse.Signature{KeyID: string(rune('a' + i)), Certificate: pemBytes("CERTIFICATE", cert.Raw)})
+	}
+
+	return env
+}
+
+func TestEmbedded(t *testing.T) {
+	ca := newCA(t, "fulcio")
+	log, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	cert := issue(t, ca, log, other)
+	scts, err := Embedded(cert)
+	require.NoError(t, err)
+	require.Len(t, scts, 2)
+	assert.Equal(t, logID(t, log), scts[0].LogID)
+	assert.NoError(t, Verify(scts[0], cert, ca.cert, log.Public()))
+	assert.NoError(t, Verify(scts[1], cert, ca.cert, other.Public()))
+	assert.Error(t, Verify(scts[0], cert, ca.cert, other.Public()))
+	assert.Error(t, Verify(scts[0], issue(t, ca, log), ca.cert, log.Public()))
+
+	scts, err = Embedded(issue(t, ca))
+	require.NoError(t, err)
+	assert.Empty(t, scts)
+}
+
+func TestFilter(t *testing.T) {
+	ca := newCA(t, "fulcio")
+	log, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	untrusted, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	requirements, err := FromPolicy(policyWithLogs(t, ca, true, log))
+	require.NoError(t, err)
+	require.NotNil(t, requirements)
+
+	logged := issue(t, ca, log)
+	unlogged := issue(t, ca)
+	loggedElsewhere := issue(t, ca, untrusted)
+	otherRoot := issue(t, newCA(t, "other"))
+	env, rejected := requirements.Filter(signed(logged, unlogged, loggedElsewhere, otherRoot))
+	assert.Len(t, env.Signatures, 2)
+	assert.Equal(t, "a", env.Signatures[0].KeyID)
+	assert.Equal(t, "d", env.Signatures[1].KeyID)
+	require.Len(t, rejected, 2)
+	assert.Equal(t, "b", rejected[0].KeyID)
+	assert.Equal(t, "c", rejected[1].KeyID)
+
+	// without requireSCT, only SCTs from trusted logs have to verify
+	optional, err := FromPolicy(policyWithLogs(t, ca, false, log))
+	require.NoError(t, err)
+	env, rejected = optional.Filter(signed(logged, unlogged, loggedElsewhere))
+	assert.Len(t, env.Signatures, 3)
+	assert.Empty(t, rejected)
+
+	// an SCT from a trusted log for a different certificate doesn't verify
+	template := *unlogged
+	for _, ext := range logged.Extensions {
+		if ext.Id.Equal(sctListOID) {
+			template.ExtraExtensions = []pkix.Extension{ext}
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, ca.cert, unlogged.PublicKey, ca.key)
+	require.NoError(t, err)
+	forged, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	_, rejected = optional.Filter(signed(forged))
+	require.Len(t, rejected, 1)
+	assert.Contains(t, rejected[0].Reason.Error(), "doesn't verify")
+
+	none, err := FromPolicy(policyWithLogs(t, ca, false))
+	require.NoError(t, err)
+	assert.Nil(t, none)
+	env, rejected = none.Filter(signed(unlogged))
+	assert.Len(t, env.Signatures, 1)
+	assert.Empty(t, rejected)
+
+	_, err = FromPolicy(policyWithLogs(t, ca, true))
+	assert.Error(t, err)
+}
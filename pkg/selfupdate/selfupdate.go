@@ -0,0 +1,208 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package selfupdate downloads witness releases and installs them over the running binary.
+package selfupdate
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// DefaultReleaseURL is where witness releases are published.
+const DefaultReleaseURL = "https://github.com/testifysec/witness/releases"
+
+// maxBinarySize bounds how much is extracted from a release archive, so a malicious archive can't fill the disk.
+const maxBinarySize = 512 << 20
+
+// ArchiveName returns the name goreleaser gives the archive of a release for an OS and architecture.
+func ArchiveName(tag, goos, goarch string) string {
+	return fmt.Sprintf("witness_%v_%v_%v.tar.gz", strings.TrimPrefix(tag, "v"), goos, goarch)
+}
+
+// BinaryName returns the name of the witness binary in a release archive for an OS.
+func BinaryName(goos string) string {
+	if goos == "windows" {
+		return "witness.exe"
+	}
+
+	return "witness"
+}
+
+// AssetURL returns the download URL of a file attached to a release.
+func AssetURL(releaseURL, tag, name string) (string, error) {
+	return url.JoinPath(releaseURL, "download", tag, name)
+}
+
+// LatestTag returns the tag of the newest release by following the releases page's latest redirect.
+func LatestTag(ctx context.Context, releaseURL string) (string, error) {
+	latestURL, err := url.JoinPath(releaseURL, "latest")
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, latestURL, nil)
+	if err != nil {
+		return "", err
+	}
+
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to find latest release: %w", err)
+	}
+
+	defer resp.Body.Close()
+	location := resp.Header.Get("Location")
+	if resp.StatusCode < 300 || resp.StatusCode >= 400 || location == "" {
+		return "", fmt.Errorf("failed to find latest release: %v", resp.Status)
+	}
+
+	tag := path.Base(location)
+	if tag == "" || tag == "." || tag == "/" || tag == "latest" || tag == "releases" {
+		return "", fmt.Errorf("failed to find latest release in %v", location)
+	}
+
+	return tag, nil
+}
+
+// Download writes the contents of url to dest.
+func Download(ctx context.Context, url, dest string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download %v: %w", url, err)
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download %v: %v", url, resp.Status)
+	}
+
+	f, err := os.OpenFile(dest, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to download %v: %w", url, err)
+	}
+
+	return f.Close()
+}
+
+// ExtractBinary copies the file named binaryName at the root of a gzipped tar archive to dest.
+func ExtractBinary(archivePath, binaryName, dest string) error {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("failed to read release archive: %w", err)
+	}
+
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("release archive does not contain %v", binaryName)
+		} else if err != nil {
+			return fmt.Errorf("failed to read release archive: %w", err)
+		}
+
+		if hdr.Typeflag != tar.TypeReg || path.Clean(hdr.Name) != binaryName {
+			continue
+		}
+
+		if hdr.Size > maxBinarySize {
+			return fmt.Errorf("%v in release archive is larger than %d bytes", binaryName, maxBinarySize)
+		}
+
+		out, err := os.OpenFile(dest, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0700)
+		if err != nil {
+			return err
+		}
+
+		if _, err := io.Copy(out, io.LimitReader(tr, maxBinarySize)); err != nil {
+			out.Close()
+			return fmt.Errorf("failed to extract %v: %w", binaryName, err)
+		}
+
+		return out.Close()
+	}
+}
+
+// Replace installs the binary at src as dst. The binary is copied next to dst and renamed over it, so dst is
+// never left partially written. Windows won't replace a running executable, so the old one is moved aside first.
+func Replace(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+
+	defer in.Close()
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".witness-update-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file next to %v: %w", dst, err)
+	}
+
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return err
+	}
+
+	if runtime.GOOS == "windows" {
+		old := dst + ".old"
+		os.Remove(old)
+		if err := os.Rename(dst, old); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to move %v aside: %w", dst, err)
+		}
+	}
+
+	return os.Rename(tmp.Name(), dst)
+}
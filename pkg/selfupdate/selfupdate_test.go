@@ -0,0 +1,54 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package selfupdate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchiveName(t *testing.T) {
+	assert.Equal(t, "witness_0.1.12_linux_arm64.tar.gz", ArchiveName("v0.1.12", "linux", "arm64"))
+	assert.Equal(t, "witness.exe", BinaryName("windows"))
+	assert.Equal(t, "witness", BinaryName("darwin"))
+
+	assetURL, err := AssetURL(DefaultReleaseURL, "v0.1.12", "policy-signed.json")
+	require.NoError(t, err)
+	assert.Equal(t, "https://github.com/testifysec/witness/releases/download/v0.1.12/policy-signed.json", assetURL)
+}
+
+func TestReplace(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "new")
+	dst := filepath.Join(dir, "witness")
+	require.NoError(t, os.WriteFile(src, []byte("new"), 0600))
+	require.NoError(t, os.WriteFile(dst, []byte("old"), 0755))
+
+	require.NoError(t, Replace(src, dst))
+	installed, err := os.ReadFile(dst)
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(installed))
+	info, err := os.Stat(dst)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
@@ -0,0 +1,207 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package spool queues signed envelopes on disk so they can be uploaded to
+// attestation stores later, outside of the build's critical path.
+package spool
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/testifysec/go-witness/dsse"
+)
+
+const entrySuffix = ".json"
+
+// Backend names the kind of store an entry should be uploaded to.
+type Backend string
+
+const (
+	BackendArchivist Backend = "archivist"
+	// BackendGitHub entries are uploaded to GitHub's artifact attestation API. Server is the repository's API URL.
+	BackendGitHub Backend = "github"
+)
+
+// Entry is a single envelope waiting to be uploaded.
+type Entry struct {
+	// ID is the entry's file name in the spool, without the suffix
+	ID         string        `json:"-"`
+	Backend    Backend       `json:"backend"`
+	Server     string        `json:"server"`
+	Envelope   dsse.Envelope `json:"envelope"`
+	EnqueuedAt time.Time     `json:"enqueuedat"`
+	Attempts   int           `json:"attempts"`
+	LastError  string        `json:"lasterror,omitempty"`
+	// ExpiresAt is when the envelope is no longer needed and the entry may be pruned, even if it wasn't uploaded
+	ExpiresAt *time.Time `json:"expiresat,omitempty"`
+}
+
+// Expired returns true if the entry has an expiry at or before now.
+func (e Entry) Expired(now time.Time) bool {
+	return e.ExpiresAt != nil && !e.ExpiresAt.After(now)
+}
+
+type EnqueueOption func(*Entry)
+
+// WithExpiry sets when a queued envelope expires.
+func WithExpiry(expiresAt time.Time) EnqueueOption {
+	return func(e *Entry) {
+		expiresAt = expiresAt.UTC()
+		e.ExpiresAt = &expiresAt
+	}
+}
+
+// Spool is a directory of entries.
+type Spool struct {
+	dir string
+}
+
+// DefaultDir returns the spool directory used when none is configured.
+func DefaultDir() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(cacheDir, "witness", "spool"), nil
+}
+
+// New opens the spool in dir, creating it if needed.
+func New(dir string) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+
+	return &Spool{dir: dir}, nil
+}
+
+// Open opens the spool in dir, or in DefaultDir if dir is empty.
+func Open(dir string) (*Spool, error) {
+	if dir == "" {
+		var err error
+		if dir, err = DefaultDir(); err != nil {
+			return nil, fmt.Errorf("failed to find default spool directory: %w", err)
+		}
+	}
+
+	return New(dir)
+}
+
+// Dir returns the spool's directory.
+func (s *Spool) Dir() string {
+	return s.dir
+}
+
+// Enqueue adds an envelope to the spool to be uploaded to server.
+func (s *Spool) Enqueue(backend Backend, server string, env dsse.Envelope, opts ...EnqueueOption) (Entry, error) {
+	random := make([]byte, 4)
+	if _, err := rand.Read(random); err != nil {
+		return Entry{}, err
+	}
+
+	now := time.Now().UTC()
+	entry := Entry{
+		// entries sort by the time they were enqueued so they are flushed in order
+		ID:         fmt.Sprintf("%v-%v", now.Format("20060102T150405.000000000"), hex.EncodeToString(random)),
+		Backend:    backend,
+		Server:     server,
+		Envelope:   env,
+		EnqueuedAt: now,
+	}
+
+	for _, opt := range opts {
+		opt(&entry)
+	}
+
+	return entry, s.Update(entry)
+}
+
+// Update rewrites an entry, such as after a failed upload attempt.
+func (s *Spool) Update(entry Entry) error {
+	entryBytes, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(entryBytes); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path(entry.ID))
+}
+
+// Entries returns every entry in the spool, oldest first.
+func (s *Spool) Entries() ([]Entry, error) {
+	dirEntries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	for _, dirEntry := range dirEntries {
+		name := dirEntry.Name()
+		if dirEntry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, entrySuffix) {
+			continue
+		}
+
+		ids = append(ids, strings.TrimSuffix(name, entrySuffix))
+	}
+
+	sort.Strings(ids)
+	entries := make([]Entry, 0, len(ids))
+	for _, id := range ids {
+		entryBytes, err := os.ReadFile(s.path(id))
+		if err != nil {
+			return nil, err
+		}
+
+		entry := Entry{}
+		if err := json.Unmarshal(entryBytes, &entry); err != nil {
+			return nil, fmt.Errorf("failed to parse spool entry %v: %w", id, err)
+		}
+
+		entry.ID = id
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// Remove deletes an entry once it has been uploaded.
+func (s *Spool) Remove(entry Entry) error {
+	return os.Remove(s.path(entry.ID))
+}
+
+func (s *Spool) path(id string) string {
+	return filepath.Join(s.dir, id+entrySuffix)
+}
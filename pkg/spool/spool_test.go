@@ -0,0 +1,68 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/dsse"
+)
+
+func TestSpool(t *testing.T) {
+	s, err := New(t.TempDir())
+	require.NoError(t, err)
+
+	first, err := s.Enqueue(BackendArchivist, "https://archivist.example.com", dsse.Envelope{Payload: []byte("first")})
+	require.NoError(t, err)
+	_, err = s.Enqueue(BackendArchivist, "https://archivist.example.com", dsse.Envelope{Payload: []byte("second")})
+	require.NoError(t, err)
+
+	entries, err := s.Entries()
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, first.ID, entries[0].ID)
+	assert.Equal(t, "first", string(entries[0].Envelope.Payload))
+	assert.Equal(t, "second", string(entries[1].Envelope.Payload))
+
+	entries[0].Attempts++
+	entries[0].LastError = "unavailable"
+	require.NoError(t, s.Update(entries[0]))
+	require.NoError(t, s.Remove(entries[1]))
+
+	entries, err = s.Entries()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, 1, entries[0].Attempts)
+	assert.Equal(t, "unavailable", entries[0].LastError)
+}
+
+func TestSpoolExpiry(t *testing.T) {
+	s, err := New(t.TempDir())
+	require.NoError(t, err)
+
+	expiresAt := time.Now().Add(time.Hour)
+	_, err = s.Enqueue(BackendArchivist, "https://archivist.example.com", dsse.Envelope{}, WithExpiry(expiresAt))
+	require.NoError(t, err)
+
+	entries, err := s.Entries()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.False(t, entries[0].Expired(time.Now()))
+	assert.True(t, entries[0].Expired(expiresAt))
+	assert.False(t, Entry{}.Expired(expiresAt))
+}
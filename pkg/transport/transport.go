@@ -0,0 +1,118 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package transport lets witness' HTTP clients reach servers listening on Unix domain sockets, such as an
+// Archivist sidecar. Clients use http.DefaultTransport, so proxies set with HTTP_PROXY, HTTPS_PROXY, and
+// NO_PROXY apply to every other connection.
+package transport
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+const (
+	// UnixScheme is the scheme of URLs naming a Unix domain socket, such as unix:///run/archivist.sock
+	UnixScheme = "unix"
+
+	// socketHostSuffix ends the host names standing in for sockets. The .invalid TLD can never resolve, so
+	// requests for a socket that wasn't registered fail instead of going to the network.
+	socketHostSuffix = ".sock.invalid"
+)
+
+var (
+	mu          sync.RWMutex
+	sockets     = map[string]string{}
+	installOnce sync.Once
+	installErr  error
+)
+
+// ResolveURL returns rawURL unchanged unless it names a Unix domain socket with the unix scheme. Socket URLs are
+// returned as an http URL that http.DefaultTransport connects to over the socket.
+func ResolveURL(rawURL string) (string, error) {
+	if !strings.HasPrefix(rawURL, UnixScheme+"://") {
+		return rawURL, nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse socket url: %w", err)
+	}
+
+	if u.Host != "" || u.Path == "" {
+		return "", fmt.Errorf("socket url %v must name an absolute path, such as unix:///run/archivist.sock", rawURL)
+	}
+
+	installOnce.Do(install)
+	if installErr != nil {
+		return "", installErr
+	}
+
+	sum := sha256.Sum256([]byte(u.Path))
+	host := hex.EncodeToString(sum[:8]) + socketHostSuffix
+	mu.Lock()
+	sockets[host] = u.Path
+	mu.Unlock()
+	return "http://" + host, nil
+}
+
+func socketPath(host string) (string, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	path, ok := sockets[host]
+	return path, ok
+}
+
+// install replaces http.DefaultTransport with a copy that dials registered sockets and never proxies them
+func install() {
+	base, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		installErr = fmt.Errorf("unix sockets are not supported with a custom http.DefaultTransport")
+		return
+	}
+
+	t := base.Clone()
+	dial := t.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			if path, ok := socketPath(host); ok {
+				return (&net.Dialer{}).DialContext(ctx, UnixScheme, path)
+			}
+		}
+
+		return dial(ctx, network, addr)
+	}
+
+	proxy := t.Proxy
+	t.Proxy = func(req *http.Request) (*url.URL, error) {
+		if _, ok := socketPath(req.URL.Hostname()); ok || proxy == nil {
+			return nil, nil
+		}
+
+		return proxy(req)
+	}
+
+	http.DefaultTransport = t
+}
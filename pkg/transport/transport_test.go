@@ -0,0 +1,55 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveURL(t *testing.T) {
+	resolved, err := ResolveURL("https://archivist.testifysec.io")
+	require.NoError(t, err)
+	assert.Equal(t, "https://archivist.testifysec.io", resolved)
+
+	_, err = ResolveURL("unix://archivist.sock")
+	assert.Error(t, err)
+
+	socket := filepath.Join(t.TempDir(), "archivist.sock")
+	listener, err := net.Listen(UnixScheme, socket)
+	require.NoError(t, err)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.URL.Path)
+	})}
+
+	go func() { _ = server.Serve(listener) }()
+	defer server.Close()
+
+	t.Setenv("HTTP_PROXY", "http://127.0.0.1:1")
+	resolved, err = ResolveURL("unix://" + socket)
+	require.NoError(t, err)
+	resp, err := http.Get(resolved + "/download/abc")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "/download/abc", string(body))
+}
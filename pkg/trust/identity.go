@@ -0,0 +1,157 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trust
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/json"
+	"fmt"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/policy"
+)
+
+// The extensions Fulcio adds to keyless certificates with the OIDC issuer that authenticated the signer. The first is
+// deprecated and holds the issuer as raw bytes, the second as a DER encoded string.
+var (
+	oidFulcioIssuer   = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	oidFulcioIssuerV2 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+)
+
+// Identity is who made a signature, in the terms a policy's functionaries match signers by
+type Identity struct {
+	KeyID       string               `json:"keyid"`
+	Certificate *CertificateIdentity `json:"certificate,omitempty"`
+	// Functionary matches the signer exactly, and can be copied into a step of a policy. Certificates are matched
+	// under any of the policy's roots.
+	Functionary Functionary `json:"functionary"`
+}
+
+// Functionary is a policy functionary that's written without the constraints of the other functionary type, so it
+// can be copied into a policy as is
+type Functionary policy.Functionary
+
+func (f Functionary) MarshalJSON() ([]byte, error) {
+	if f.Type == "PublicKey" {
+		return json.Marshal(struct {
+			Type        string `json:"type"`
+			PublicKeyID string `json:"publickeyid"`
+		}{f.Type, f.PublicKeyID})
+	}
+
+	return json.Marshal(struct {
+		Type           string                `json:"type"`
+		CertConstraint policy.CertConstraint `json:"certConstraint"`
+	}{f.Type, f.CertConstraint})
+}
+
+// CertificateIdentity is the subject and alternative names of a signing certificate, and for keyless certificates
+// the OIDC issuer of the signer's identity
+type CertificateIdentity struct {
+	CommonName    string   `json:"commonName,omitempty"`
+	Organizations []string `json:"organizations,omitempty"`
+	DNSNames      []string `json:"dnsNames,omitempty"`
+	Emails        []string `json:"emails,omitempty"`
+	URIs          []string `json:"uris,omitempty"`
+	OIDCIssuer    string   `json:"oidcIssuer,omitempty"`
+}
+
+// SignatureIdentity returns the identity of sig. The key ID of a signature made with a certificate is computed from
+// the certificate's key, and otherwise it's the key ID recorded in the signature.
+func SignatureIdentity(sig dsse.Signature) (Identity, error) {
+	if len(sig.Certificate) == 0 {
+		return Identity{KeyID: sig.KeyID, Functionary: Functionary{Type: "PublicKey", PublicKeyID: sig.KeyID}}, nil
+	}
+
+	verifier, err := cryptoutil.NewVerifierFromReader(bytes.NewReader(sig.Certificate))
+	if err != nil {
+		return Identity{}, fmt.Errorf("failed to load signature's certificate: %w", err)
+	}
+
+	return VerifierIdentity(verifier)
+}
+
+// VerifierIdentity returns the identity of the signer verifier verifies
+func VerifierIdentity(verifier cryptoutil.Verifier) (Identity, error) {
+	keyID, err := verifier.KeyID()
+	if err != nil {
+		return Identity{}, fmt.Errorf("failed to compute key id: %w", err)
+	}
+
+	x509Verifier, ok := verifier.(*cryptoutil.X509Verifier)
+	if !ok {
+		return Identity{KeyID: keyID, Functionary: Functionary{Type: "PublicKey", PublicKeyID: keyID}}, nil
+	}
+
+	cert := x509Verifier.Certificate()
+	certIdentity := CertificateIdentity{
+		CommonName:    cert.Subject.CommonName,
+		Organizations: cert.Subject.Organization,
+		DNSNames:      cert.DNSNames,
+		Emails:        cert.EmailAddresses,
+		OIDCIssuer:    oidcIssuer(cert),
+	}
+
+	for _, uri := range cert.URIs {
+		certIdentity.URIs = append(certIdentity.URIs, uri.String())
+	}
+
+	return Identity{
+		KeyID:       keyID,
+		Certificate: &certIdentity,
+		Functionary: Functionary{
+			Type: "root",
+			CertConstraint: policy.CertConstraint{
+				CommonName:    certIdentity.CommonName,
+				Organizations: orEmpty(certIdentity.Organizations),
+				DNSNames:      orEmpty(certIdentity.DNSNames),
+				Emails:        orEmpty(certIdentity.Emails),
+				URIs:          orEmpty(certIdentity.URIs),
+				Roots:         []string{policy.AllowAllConstraint},
+			},
+		},
+	}, nil
+}
+
+// oidcIssuer returns the OIDC issuer Fulcio recorded in cert, if it's a keyless certificate
+func oidcIssuer(cert *x509.Certificate) string {
+	deprecated := ""
+	for _, ext := range cert.Extensions {
+		switch {
+		case ext.Id.Equal(oidFulcioIssuerV2):
+			issuer := ""
+			if _, err := asn1.Unmarshal(ext.Value, &issuer); err == nil {
+				return issuer
+			}
+		case ext.Id.Equal(oidFulcioIssuer):
+			deprecated = string(ext.Value)
+		}
+	}
+
+	return deprecated
+}
+
+// orEmpty returns values, or an empty list if there are none, so constraints on an attribute the certificate
+// doesn't have are written as [] rather than null
+func orEmpty(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+
+	return values
+}
@@ -0,0 +1,83 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trust
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"math/big"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/policy"
+)
+
+func TestVerifierIdentity(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	keyVerifier := cryptoutil.NewECDSAVerifier(&key.PublicKey, crypto.SHA256)
+	keyID, err := keyVerifier.KeyID()
+	require.NoError(t, err)
+
+	identity, err := VerifierIdentity(keyVerifier)
+	require.NoError(t, err)
+	assert.Equal(t, keyID, identity.KeyID)
+	assert.Nil(t, identity.Certificate)
+	b, err := json.Marshal(identity.Functionary)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"PublicKey","publickeyid":"`+keyID+`"}`, string(b))
+
+	issuer, err := asn1.Marshal("https://token.actions.githubusercontent.com")
+	require.NoError(t, err)
+	workflow, err := url.Parse("https://github.com/testifysec/witness/.github/workflows/release.yml@refs/heads/main")
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:    big.NewInt(1),
+		Subject:         pkix.Name{},
+		NotBefore:       time.Now(),
+		NotAfter:        time.Now().Add(10 * time.Minute),
+		URIs:            []*url.URL{workflow},
+		ExtraExtensions: []pkix.Extension{{Id: oidFulcioIssuer, Value: []byte("https://deprecated.example.com")}, {Id: oidFulcioIssuerV2, Value: issuer}},
+	}
+
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(certBytes)
+	require.NoError(t, err)
+	certVerifier, err := cryptoutil.NewX509Verifier(cert, nil, nil, time.Time{})
+	require.NoError(t, err)
+
+	identity, err = VerifierIdentity(certVerifier)
+	require.NoError(t, err)
+	assert.Equal(t, keyID, identity.KeyID)
+	require.NotNil(t, identity.Certificate)
+	assert.Equal(t, "https://token.actions.githubusercontent.com", identity.Certificate.OIDCIssuer)
+	assert.Equal(t, []string{workflow.String()}, identity.Certificate.URIs)
+	assert.Equal(t, "root", identity.Functionary.Type)
+	assert.Equal(t, []string{workflow.String()}, identity.Functionary.CertConstraint.URIs)
+	assert.Equal(t, []string{}, identity.Functionary.CertConstraint.Emails)
+	assert.Equal(t, []string{policy.AllowAllConstraint}, identity.Functionary.CertConstraint.Roots)
+	assert.NoError(t, identity.Functionary.CertConstraint.Check(certVerifier, map[string]policy.TrustBundle{"root": {Root: cert}}))
+}
@@ -0,0 +1,106 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package trust derives what a witness policy trusts to sign attestations: its public keys, roots, and timestamp
+// authorities, and which functionaries a signature belongs to. It's used to verify signed statements that the
+// go-witness policy type doesn't know about, such as approvals and waivers.
+package trust
+
+import (
+	"crypto/x509"
+	"fmt"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/policy"
+	"github.com/testifysec/go-witness/timestamp"
+)
+
+// Policy is what a policy trusts to sign attestations
+type Policy struct {
+	TrustBundles map[string]policy.TrustBundle
+	VerifyOpts   []dsse.VerificationOption
+}
+
+// FromPolicy returns the keys, roots, and timestamp authorities p trusts
+func FromPolicy(p policy.Policy) (Policy, error) {
+	pubKeysByID, err := p.PublicKeyVerifiers()
+	if err != nil {
+		return Policy{}, fmt.Errorf("failed to get public keys from policy: %w", err)
+	}
+
+	pubKeys := make([]cryptoutil.Verifier, 0, len(pubKeysByID))
+	for _, pubKey := range pubKeysByID {
+		pubKeys = append(pubKeys, pubKey)
+	}
+
+	trustBundles, err := p.TrustBundles()
+	if err != nil {
+		return Policy{}, fmt.Errorf("failed to load policy trust bundles: %w", err)
+	}
+
+	roots := make([]*x509.Certificate, 0)
+	intermediates := make([]*x509.Certificate, 0)
+	for _, trustBundle := range trustBundles {
+		roots = append(roots, trustBundle.Root)
+		intermediates = append(intermediates, trustBundle.Intermediates...)
+	}
+
+	timestampAuthorities, err := p.TimestampAuthorityTrustBundles()
+	if err != nil {
+		return Policy{}, fmt.Errorf("failed to load policy timestamp authorities: %w", err)
+	}
+
+	timestampVerifiers := make([]dsse.TimestampVerifier, 0)
+	for _, timestampAuthority := range timestampAuthorities {
+		certs := append([]*x509.Certificate{timestampAuthority.Root}, timestampAuthority.Intermediates...)
+		timestampVerifiers = append(timestampVerifiers, timestamp.NewVerifier(timestamp.VerifyWithCerts(certs)))
+	}
+
+	return Policy{
+		TrustBundles: trustBundles,
+		VerifyOpts: []dsse.VerificationOption{
+			dsse.VerifyWithVerifiers(pubKeys...),
+			dsse.VerifyWithRoots(roots...),
+			dsse.VerifyWithIntermediates(intermediates...),
+			dsse.VerifyWithTimestampVerifiers(timestampVerifiers...),
+		},
+	}, nil
+}
+
+// IsFunctionary reports whether verifier belongs to one of the functionaries, matching them the same way as a
+// step's functionaries: by public key ID, or by certificate constraint for certificates issued by a policy root.
+func (p Policy) IsFunctionary(functionaries []policy.Functionary, verifier cryptoutil.Verifier) bool {
+	keyID, err := verifier.KeyID()
+	if err != nil {
+		return false
+	}
+
+	for _, functionary := range functionaries {
+		if functionary.PublicKeyID != "" && functionary.PublicKeyID == keyID {
+			return true
+		}
+
+		x509Verifier, ok := verifier.(*cryptoutil.X509Verifier)
+		if !ok || len(functionary.CertConstraint.Roots) == 0 {
+			continue
+		}
+
+		if err := functionary.CertConstraint.Check(x509Verifier, p.TrustBundles); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
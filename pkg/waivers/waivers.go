@@ -0,0 +1,227 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package waivers applies signed waivers that temporarily exempt artifacts from a policy constraint. A policy
+// that trusts waivers names the functionaries who may sign them; a waiver exempts a step, or one attestation a
+// step requires, for specific artifacts until it expires.
+package waivers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/go-witness/policy"
+	"github.com/testifysec/go-witness/source"
+	"github.com/testifysec/witness/attestation/waiver"
+	"github.com/testifysec/witness/pkg/trust"
+)
+
+// policyWaivers is the subset of a witness policy that declares who may sign waivers. waivers isn't part of
+// the go-witness policy type, so it's read separately from the same policy document.
+type policyWaivers struct {
+	Waivers *struct {
+		Functionaries []policy.Functionary `json:"functionaries"`
+	} `json:"waivers,omitempty"`
+}
+
+// Waiver is a verified waiver signed by one of the policy's waiver functionaries
+type Waiver struct {
+	Reference   string
+	Step        string
+	Attestation string
+	Reason      string
+	Expires     time.Time
+}
+
+// Constraint describes the policy constraint the waiver exempts
+func (w Waiver) Constraint() string {
+	if w.Attestation != "" {
+		return fmt.Sprintf("attestation %v of step %v", w.Attestation, w.Step)
+	}
+
+	return fmt.Sprintf("step %v", w.Step)
+}
+
+func (w Waiver) String() string {
+	return fmt.Sprintf("%v waived until %v: %v", w.Constraint(), w.Expires.Format(time.RFC3339), w.Reason)
+}
+
+// Evaluator finds the waivers that apply to artifacts and evaluates a policy with their constraints exempted
+type Evaluator struct {
+	policy        policy.Policy
+	functionaries []policy.Functionary
+	trusted       trust.Policy
+}
+
+// FromPolicy reads who may sign waivers from a policy document. A nil Evaluator is returned if the policy
+// doesn't trust any waivers.
+func FromPolicy(policyBytes []byte) (*Evaluator, error) {
+	p := policyWaivers{}
+	if err := json.Unmarshal(policyBytes, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse policy: %w", err)
+	}
+
+	if p.Waivers == nil || len(p.Waivers.Functionaries) == 0 {
+		return nil, nil
+	}
+
+	pol := policy.Policy{}
+	if err := json.Unmarshal(policyBytes, &pol); err != nil {
+		return nil, fmt.Errorf("failed to parse policy: %w", err)
+	}
+
+	trusted, err := trust.FromPolicy(pol)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Evaluator{
+		policy:        pol,
+		functionaries: p.Waivers.Functionaries,
+		trusted:       trusted,
+	}, nil
+}
+
+// Find searches src for waivers of any of the subject digests signed by a waiver functionary. Waivers that
+// haven't expired by now are returned as active; the rest as expired. Waivers for steps the policy doesn't have
+// are ignored.
+func (e *Evaluator) Find(ctx context.Context, src source.Sourcer, subjectDigests []string, now time.Time) (active []Waiver, expired []Waiver, err error) {
+	if e == nil {
+		return nil, nil, nil
+	}
+
+	verified, err := source.NewVerifiedSource(src, e.trusted.VerifyOpts...).Search(ctx, waiver.CollectionName, subjectDigests, []string{waiver.Type})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to search for waivers: %w", err)
+	}
+
+	for _, collection := range verified {
+		if collection.Statement.PredicateType != attestation.CollectionType || !e.signedByFunctionary(collection) {
+			log.Debugf("(waivers) skipping %v: not signed by a waiver functionary", collection.Reference)
+			continue
+		}
+
+		for _, a := range collection.Collection.Attestations {
+			w, ok := a.Attestation.(*waiver.Attestor)
+			if !ok {
+				continue
+			}
+
+			if _, ok := e.policy.Steps[w.Step]; !ok {
+				log.Debugf("(waivers) skipping %v: policy has no step %v", collection.Reference, w.Step)
+				continue
+			}
+
+			found := Waiver{
+				Reference:   collection.Reference,
+				Step:        w.Step,
+				Attestation: w.Attestation,
+				Reason:      w.Reason,
+				Expires:     w.Expires,
+			}
+
+			if now.Before(w.Expires) {
+				active = append(active, found)
+			} else {
+				expired = append(expired, found)
+			}
+		}
+	}
+
+	sortWaivers(active)
+	sortWaivers(expired)
+	return active, expired, nil
+}
+
+func (e *Evaluator) signedByFunctionary(collection source.VerifiedCollection) bool {
+	for _, verifier := range collection.Verifiers {
+		if e.trusted.IsFunctionary(e.functionaries, verifier) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Verify evaluates the policy with the constraints the waivers exempt removed, using the collections in src.
+func (e *Evaluator) Verify(ctx context.Context, src source.Sourcer, subjectDigests []string, waivers []Waiver) (map[string][]source.VerifiedCollection, error) {
+	return Apply(e.policy, waivers).Verify(
+		ctx,
+		policy.WithVerifiedSource(source.NewVerifiedSource(src, e.trusted.VerifyOpts...)),
+		policy.WithSubjectDigests(subjectDigests),
+	)
+}
+
+// Apply returns a copy of p with the constraints the waivers exempt removed. A waived step is removed, along
+// with any other step's requirement that its materials come from it. A waived attestation is removed from the
+// attestations its step requires.
+func Apply(p policy.Policy, waivers []Waiver) policy.Policy {
+	waivedSteps := make(map[string]bool)
+	waivedAttestations := make(map[string]map[string]bool)
+	for _, w := range waivers {
+		if w.Attestation == "" {
+			waivedSteps[w.Step] = true
+			continue
+		}
+
+		if waivedAttestations[w.Step] == nil {
+			waivedAttestations[w.Step] = make(map[string]bool)
+		}
+
+		waivedAttestations[w.Step][w.Attestation] = true
+	}
+
+	steps := make(map[string]policy.Step, len(p.Steps))
+	for name, step := range p.Steps {
+		if waivedSteps[name] {
+			continue
+		}
+
+		artifactsFrom := make([]string, 0, len(step.ArtifactsFrom))
+		for _, from := range step.ArtifactsFrom {
+			if !waivedSteps[from] {
+				artifactsFrom = append(artifactsFrom, from)
+			}
+		}
+
+		attestations := make([]policy.Attestation, 0, len(step.Attestations))
+		for _, a := range step.Attestations {
+			if !waivedAttestations[name][a.Type] {
+				attestations = append(attestations, a)
+			}
+		}
+
+		step.ArtifactsFrom = artifactsFrom
+		step.Attestations = attestations
+		steps[name] = step
+	}
+
+	p.Steps = steps
+	return p
+}
+
+func sortWaivers(waivers []Waiver) {
+	sort.Slice(waivers, func(i, j int) bool {
+		if waivers[i].Step != waivers[j].Step {
+			return waivers[i].Step < waivers[j].Step
+		}
+
+		return waivers[i].Attestation < waivers[j].Attestation
+	})
+}
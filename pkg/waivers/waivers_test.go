@@ -0,0 +1,114 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package waivers
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+	"github.com/testifysec/go-witness/policy"
+	"github.com/testifysec/go-witness/source"
+	"github.com/testifysec/witness/attestation/waiver"
+)
+
+func TestApply(t *testing.T) {
+	p := policy.Policy{Steps: map[string]policy.Step{
+		"build": {Name: "build", Attestations: []policy.Attestation{{Type: "a"}, {Type: "b"}}},
+		"scan":  {Name: "scan", ArtifactsFrom: []string{"build"}},
+		"test":  {Name: "test", ArtifactsFrom: []string{"build", "scan"}},
+	}}
+
+	waived := Apply(p, []Waiver{{Step: "scan"}, {Step: "build", Attestation: "b"}})
+	assert.Len(t, waived.Steps, 2)
+	assert.Equal(t, []policy.Attestation{{Type: "a"}}, waived.Steps["build"].Attestations)
+	assert.Equal(t, []string{"build"}, waived.Steps["test"].ArtifactsFrom)
+
+	// the original policy is left alone
+	assert.Len(t, p.Steps, 3)
+	assert.Len(t, p.Steps["build"].Attestations, 2)
+	assert.Equal(t, []string{"build", "scan"}, p.Steps["test"].ArtifactsFrom)
+}
+
+func TestFind(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	signer := cryptoutil.NewRSASigner(key, crypto.SHA256)
+	verifier := cryptoutil.NewRSAVerifier(&key.PublicKey, crypto.SHA256)
+	keyID, err := verifier.KeyID()
+	require.NoError(t, err)
+	pubDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+
+	policyBytes, err := json.Marshal(map[string]interface{}{
+		"steps":      map[string]policy.Step{"scan": {Name: "scan"}},
+		"publickeys": map[string]policy.PublicKey{keyID: {KeyID: keyID, Key: pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER})}},
+		"waivers":    map[string][]policy.Functionary{"functionaries": {{Type: "PublicKey", PublicKeyID: keyID}}},
+	})
+	require.NoError(t, err)
+	evaluator, err := FromPolicy(policyBytes)
+	require.NoError(t, err)
+	require.NotNil(t, evaluator)
+
+	expires := time.Date(2022, 12, 1, 0, 0, 0, 0, time.UTC)
+	src := source.NewMemorySource()
+	for _, step := range []string{"scan", "missing"} {
+		w := waiver.New(
+			waiver.WithStep(step),
+			waiver.WithReason("outage"),
+			waiver.WithExpires(expires),
+			waiver.WithSubjects(map[string]cryptoutil.DigestSet{"sha256:abc": {crypto.SHA256: "abc"}}),
+		)
+
+		collection := attestation.NewCollection(waiver.CollectionName, []attestation.Attestor{w})
+		predicate, err := json.Marshal(collection)
+		require.NoError(t, err)
+		statement, err := intoto.NewStatement(attestation.CollectionType, predicate, collection.Subjects())
+		require.NoError(t, err)
+		statementBytes, err := json.Marshal(statement)
+		require.NoError(t, err)
+		env, err := dsse.Sign(intoto.PayloadType, bytes.NewReader(statementBytes), dsse.SignWithSigners(signer))
+		require.NoError(t, err)
+		require.NoError(t, src.LoadEnvelope(step, env))
+	}
+
+	active, expired, err := evaluator.Find(context.Background(), src, []string{"abc"}, expires.Add(-time.Hour))
+	require.NoError(t, err)
+	require.Len(t, active, 1)
+	assert.Equal(t, "scan", active[0].Step)
+	assert.Empty(t, expired)
+
+	active, expired, err = evaluator.Find(context.Background(), src, []string{"abc"}, expires)
+	require.NoError(t, err)
+	assert.Empty(t, active)
+	assert.Len(t, expired, 1)
+
+	active, _, err = evaluator.Find(context.Background(), src, []string{"def"}, expires.Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, active)
+}